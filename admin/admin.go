@@ -0,0 +1,611 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ortuman/jackal/auth"
+	"github.com/ortuman/jackal/errors"
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/model/rostermodel"
+	"github.com/ortuman/jackal/module"
+	"github.com/ortuman/jackal/module/roster"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+	"github.com/pborman/uuid"
+)
+
+// PathPrefix represents the URL path under which admin API is served.
+const PathPrefix = "/admin/"
+
+const maxRequestBodySize = 65536
+
+type userRequest struct {
+	Username string `json:"username"`
+	Domain   string `json:"domain,omitempty"`
+	Password string `json:"password"`
+}
+
+type userResponse struct {
+	Username       string     `json:"username"`
+	Domain         string     `json:"domain"`
+	Online         bool       `json:"online"`
+	LastPresenceAt *time.Time `json:"last_presence_at,omitempty"`
+}
+
+type sessionResponse struct {
+	JID       string `json:"jid"`
+	Resource  string `json:"resource"`
+	Available bool   `json:"available"`
+	Priority  int    `json:"priority"`
+	Show      string `json:"show,omitempty"`
+	Status    string `json:"status,omitempty"`
+}
+
+type rosterItem struct {
+	JID          string   `json:"jid"`
+	Name         string   `json:"name,omitempty"`
+	Subscription string   `json:"subscription"`
+	Ask          bool     `json:"ask"`
+	Groups       []string `json:"groups,omitempty"`
+}
+
+type messageRequest struct {
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Type    string `json:"type"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Handler serves admin REST API requests.
+type Handler struct {
+	cfg *Config
+}
+
+// New returns an admin REST API handler.
+func New(cfg *Config) *Handler {
+	return &Handler{cfg: cfg}
+}
+
+// ServeHTTP satisfies http.Handler interface.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.isAuthorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="jackal"`)
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	segments, err := pathSegments(r.URL)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "malformed path")
+		return
+	}
+	switch {
+	case len(segments) == 1 && segments[0] == "users":
+		switch r.Method {
+		case http.MethodGet:
+			h.listUsers(w)
+		case http.MethodPost:
+			h.createUser(w, r)
+		default:
+			writeMethodNotAllowed(w)
+		}
+
+	case len(segments) == 1 && segments[0] == "messages":
+		if r.Method != http.MethodPost {
+			writeMethodNotAllowed(w)
+			return
+		}
+		h.sendMessage(w, r)
+
+	case len(segments) >= 2 && segments[0] == "users":
+		h.serveUser(w, r, segments[1], segments[2:])
+
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (h *Handler) serveUser(w http.ResponseWriter, r *http.Request, address string, segments []string) {
+	username, domain := splitUserAddress(address)
+	user, err := storage.Instance().FetchUser(username, domain)
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	if user == nil {
+		// user might be only known by authentication provider
+		exists, err := auth.Instance().UserExists(username, domain)
+		if err != nil {
+			writeInternalError(w, err)
+			return
+		}
+		if !exists {
+			writeError(w, http.StatusNotFound, "user not found")
+			return
+		}
+		user = &model.User{Username: username, Domain: domain}
+	}
+	if len(segments) == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, newUserResponse(user))
+		case http.MethodPut:
+			h.updateUser(w, r, user)
+		case http.MethodDelete:
+			h.deleteUser(w, user)
+		default:
+			writeMethodNotAllowed(w)
+		}
+		return
+	}
+	var resource string
+	switch len(segments) {
+	case 1:
+	case 2:
+		resource = segments[1]
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	switch segments[0] {
+	case "sessions":
+		switch {
+		case len(resource) == 0 && r.Method == http.MethodGet:
+			h.listSessions(w, username, domain)
+		case len(resource) > 0 && r.Method == http.MethodDelete:
+			h.kickSession(w, username, domain, resource)
+		default:
+			writeMethodNotAllowed(w)
+		}
+
+	case "roster":
+		switch {
+		case len(resource) == 0 && r.Method == http.MethodGet:
+			h.listRosterItems(w, username, domain)
+		case len(resource) > 0 && r.Method == http.MethodPut:
+			h.updateRosterItem(w, r, username, domain, resource)
+		case len(resource) > 0 && r.Method == http.MethodDelete:
+			h.deleteRosterItem(w, username, domain, resource)
+		default:
+			writeMethodNotAllowed(w)
+		}
+
+	case "blocklist":
+		switch {
+		case len(resource) == 0 && r.Method == http.MethodGet:
+			h.listBlockListItems(w, username, domain)
+		case len(resource) > 0 && r.Method == http.MethodPut:
+			h.blockJID(w, username, domain, resource)
+		case len(resource) > 0 && r.Method == http.MethodDelete:
+			h.unblockJID(w, username, domain, resource)
+		default:
+			writeMethodNotAllowed(w)
+		}
+
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (h *Handler) listUsers(w http.ResponseWriter) {
+	users, err := storage.Instance().FetchUsers()
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	resp := make([]userResponse, 0, len(users))
+	for i := range users {
+		resp = append(resp, newUserResponse(&users[i]))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) createUser(w http.ResponseWriter, r *http.Request) {
+	var req userRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if !isValidUsername(req.Username) {
+		writeError(w, http.StatusBadRequest, "invalid username")
+		return
+	}
+	if len(req.Domain) == 0 {
+		req.Domain = host.DefaultHostName()
+	} else if !host.IsLocalHost(req.Domain) {
+		writeError(w, http.StatusBadRequest, "unknown domain")
+		return
+	}
+	if len(req.Password) == 0 {
+		writeError(w, http.StatusBadRequest, "password must be specified")
+		return
+	}
+	exists, err := auth.Instance().UserExists(req.Username, req.Domain)
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	if exists {
+		writeError(w, http.StatusConflict, "user already exists")
+		return
+	}
+	user := model.User{Username: req.Username, Domain: req.Domain}
+	auth.SetUserPassword(&user, req.Password)
+	if err := storage.Instance().InsertOrUpdateUser(&user); err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	log.Infof("admin: created user... (%s@%s)", user.Username, user.Domain)
+	writeJSON(w, http.StatusCreated, newUserResponse(&user))
+}
+
+func (h *Handler) updateUser(w http.ResponseWriter, r *http.Request, user *model.User) {
+	var req userRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if len(req.Username) > 0 && req.Username != user.Username {
+		writeError(w, http.StatusBadRequest, "username cannot be changed")
+		return
+	}
+	if len(req.Password) == 0 {
+		writeError(w, http.StatusBadRequest, "password must be specified")
+		return
+	}
+	if err := auth.Instance().SetPassword(user.Username, user.Domain, req.Password); err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	log.Infof("admin: updated user password... (%s@%s)", user.Username, user.Domain)
+	writeJSON(w, http.StatusOK, newUserResponse(user))
+}
+
+func (h *Handler) deleteUser(w http.ResponseWriter, user *model.User) {
+	if err := storage.Instance().DeleteUser(user.Username, user.Domain); err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	for _, stm := range router.UserStreams(user.Username, user.Domain) {
+		stm.Disconnect(streamerror.ErrNotAuthorized)
+	}
+	log.Infof("admin: deleted user... (%s@%s)", user.Username, user.Domain)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) listSessions(w http.ResponseWriter, username, domain string) {
+	stms := router.UserStreams(username, domain)
+	resp := make([]sessionResponse, 0, len(stms))
+	for _, stm := range stms {
+		s := sessionResponse{
+			JID:      stm.JID().String(),
+			Resource: stm.Resource(),
+		}
+		if presence := stm.Presence(); presence != nil && presence.IsAvailable() {
+			s.Available = true
+			s.Priority = int(presence.Priority())
+			s.Show = showStateString(presence.ShowState())
+			s.Status = presence.Status()
+		}
+		resp = append(resp, s)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) kickSession(w http.ResponseWriter, username, domain, resource string) {
+	for _, stm := range router.UserStreams(username, domain) {
+		if stm.Resource() != resource {
+			continue
+		}
+		stm.Disconnect(streamerror.ErrPolicyViolation)
+		log.Infof("admin: kicked session... (%s)", stm.JID().String())
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeError(w, http.StatusNotFound, "session not found")
+}
+
+func (h *Handler) listRosterItems(w http.ResponseWriter, username, domain string) {
+	items, _, err := storage.Instance().FetchRosterItems(username, domain)
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	resp := make([]rosterItem, 0, len(items))
+	for _, ri := range items {
+		resp = append(resp, rosterItem{
+			JID:          ri.JID,
+			Name:         ri.Name,
+			Subscription: ri.Subscription,
+			Ask:          ri.Ask,
+			Groups:       ri.Groups,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) updateRosterItem(w http.ResponseWriter, r *http.Request, username, domain, contact string) {
+	contactJID, err := jid.NewWithString(contact, false)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid jid")
+		return
+	}
+	var req rosterItem
+	if !readJSON(w, r, &req) {
+		return
+	}
+	switch req.Subscription {
+	case "":
+		req.Subscription = rostermodel.SubscriptionNone
+	case rostermodel.SubscriptionNone, rostermodel.SubscriptionFrom, rostermodel.SubscriptionTo, rostermodel.SubscriptionBoth:
+		break
+	default:
+		writeError(w, http.StatusBadRequest, "invalid subscription")
+		return
+	}
+	ri := &rostermodel.Item{
+		Username:     username,
+		Domain:       domain,
+		JID:          contactJID.ToBareJID().String(),
+		Name:         req.Name,
+		Subscription: req.Subscription,
+		Ask:          req.Ask,
+		Groups:       req.Groups,
+	}
+	if rst := rosterModule(domain); rst != nil {
+		err = rst.InsertOrUpdateItem(ri)
+	} else {
+		_, err = storage.Instance().InsertOrUpdateRosterItem(ri)
+	}
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	req.JID = ri.JID
+	writeJSON(w, http.StatusOK, req)
+}
+
+func (h *Handler) deleteRosterItem(w http.ResponseWriter, username, domain, contact string) {
+	ri, err := storage.Instance().FetchRosterItem(username, domain, contact)
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	if ri == nil {
+		writeError(w, http.StatusNotFound, "roster item not found")
+		return
+	}
+	if rst := rosterModule(domain); rst != nil {
+		err = rst.DeleteItem(username, domain, ri.JID)
+	} else {
+		_, err = storage.Instance().DeleteRosterItem(username, domain, ri.JID)
+	}
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) listBlockListItems(w http.ResponseWriter, username, domain string) {
+	items, err := storage.Instance().FetchBlockListItems(username, domain)
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	resp := make([]string, 0, len(items))
+	for _, item := range items {
+		resp = append(resp, item.JID)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) blockJID(w http.ResponseWriter, username, domain, blocked string) {
+	j, err := jid.NewWithString(blocked, false)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid jid")
+		return
+	}
+	items := []model.BlockListItem{{Username: username, Domain: domain, JID: j.String()}}
+	if err := storage.Instance().InsertBlockListItems(items); err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	router.ReloadBlockList(username, domain)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) unblockJID(w http.ResponseWriter, username, domain, blocked string) {
+	j, err := jid.NewWithString(blocked, false)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid jid")
+		return
+	}
+	items := []model.BlockListItem{{Username: username, Domain: domain, JID: j.String()}}
+	if err := storage.Instance().DeleteBlockListItems(items); err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	router.ReloadBlockList(username, domain)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) sendMessage(w http.ResponseWriter, r *http.Request) {
+	var req messageRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	toJID, err := jid.NewWithString(req.To, false)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid 'to' jid")
+		return
+	}
+	var fromJID *jid.JID
+	if len(req.From) > 0 {
+		fromJID, err = jid.NewWithString(req.From, false)
+		if err != nil || !host.IsLocalHost(fromJID.Domain()) {
+			writeError(w, http.StatusBadRequest, "invalid 'from' jid")
+			return
+		}
+	} else if host.IsLocalHost(toJID.Domain()) {
+		fromJID, _ = jid.New("", toJID.Domain(), "", true)
+	} else {
+		writeError(w, http.StatusBadRequest, "'from' jid must be specified")
+		return
+	}
+	switch req.Type {
+	case "":
+		req.Type = xml.NormalType
+	case xml.NormalType, xml.ChatType, xml.HeadlineType:
+		break
+	default:
+		writeError(w, http.StatusBadRequest, "invalid message type")
+		return
+	}
+	message := xml.NewMessageType(uuid.New(), req.Type)
+	message.SetFromJID(fromJID)
+	message.SetToJID(toJID)
+	if len(req.Subject) > 0 {
+		subject := xml.NewElementName("subject")
+		subject.SetText(req.Subject)
+		message.AppendElement(subject)
+	}
+	if len(req.Body) > 0 {
+		body := xml.NewElementName("body")
+		body.SetText(req.Body)
+		message.AppendElement(body)
+	}
+	switch router.Route(message) {
+	case nil:
+		w.WriteHeader(http.StatusAccepted)
+	case router.ErrNotExistingAccount:
+		writeError(w, http.StatusNotFound, "recipient does not exist")
+	case router.ErrNotAuthenticated, router.ErrResourceNotFound:
+		writeError(w, http.StatusNotFound, "recipient not available")
+	case router.ErrBlockedJID:
+		writeError(w, http.StatusForbidden, "recipient is blocked")
+	case router.ErrFailedRemoteConnect:
+		writeError(w, http.StatusBadGateway, "remote server not found")
+	default:
+		writeError(w, http.StatusInternalServerError, "internal server error")
+	}
+}
+
+func (h *Handler) isAuthorized(r *http.Request) bool {
+	const prefix = "Bearer "
+	authz := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authz, prefix) || !h.cfg.Enabled() {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(authz[len(prefix):]), []byte(h.cfg.Token)) == 1
+}
+
+func rosterModule(domain string) *roster.Roster {
+	rst, _ := module.Lookup(domain, "roster").(*roster.Roster)
+	return rst
+}
+
+// splitUserAddress splits a user address into its username and domain parts.
+// Default local domain is assumed in case none has been specified.
+func splitUserAddress(address string) (username, domain string) {
+	if i := strings.IndexByte(address, '@'); i != -1 {
+		return address[:i], address[i+1:]
+	}
+	return address, host.DefaultHostName()
+}
+
+func newUserResponse(user *model.User) userResponse {
+	resp := userResponse{
+		Username: user.Username,
+		Domain:   user.Domain,
+		Online:   len(router.UserStreams(user.Username, user.Domain)) > 0,
+	}
+	if !user.LastPresenceAt.IsZero() {
+		t := user.LastPresenceAt
+		resp.LastPresenceAt = &t
+	}
+	return resp
+}
+
+func isValidUsername(username string) bool {
+	if len(username) == 0 {
+		return false
+	}
+	// validate node part only
+	j, err := jid.New(username, "localhost", "", false)
+	return err == nil && j.Node() == username
+}
+
+func showStateString(showState xml.ShowState) string {
+	switch showState {
+	case xml.AwayShowState:
+		return "away"
+	case xml.ChatShowState:
+		return "chat"
+	case xml.DoNotDisturbShowState:
+		return "dnd"
+	case xml.ExtendedAwaysShowState:
+		return "xa"
+	}
+	return ""
+}
+
+func pathSegments(u *url.URL) ([]string, error) {
+	p := strings.Trim(strings.TrimPrefix(u.EscapedPath(), PathPrefix), "/")
+	if len(p) == 0 {
+		return nil, nil
+	}
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		us, err := url.PathUnescape(s)
+		if err != nil {
+			return nil, err
+		}
+		segments[i] = us
+	}
+	return segments, nil
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "malformed request body")
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, statusCode int, errText string) {
+	writeJSON(w, statusCode, &errorResponse{Error: errText})
+}
+
+func writeInternalError(w http.ResponseWriter, err error) {
+	log.Error(err)
+	writeError(w, http.StatusInternalServerError, "internal server error")
+}
+
+func writeMethodNotAllowed(w http.ResponseWriter) {
+	writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+}
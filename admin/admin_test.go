@@ -0,0 +1,391 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/auth"
+	"github.com/ortuman/jackal/errors"
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/module"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+const testToken = "s3cr3t"
+
+func TestAdmin_Authorization(t *testing.T) {
+	h, shutdown := tUtilAdminInit()
+	defer shutdown()
+
+	rec := tUtilRequest(h, http.MethodGet, "/admin/users", "", nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = tUtilRequest(h, http.MethodGet, "/admin/users", "b4d", nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = tUtilRequest(h, http.MethodGet, "/admin/users", testToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = tUtilRequest(h, http.MethodGet, "/admin/unknown", testToken, nil)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	// admin API disabled
+	rec = tUtilRequest(New(&Config{}), http.MethodGet, "/admin/users", "", nil)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestAdmin_Users(t *testing.T) {
+	h, shutdown := tUtilAdminInit()
+	defer shutdown()
+
+	rec := tUtilRequest(h, http.MethodPost, "/admin/users", testToken, &userRequest{Username: "ortuman"})
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = tUtilRequest(h, http.MethodPost, "/admin/users", testToken, &userRequest{Username: "ort@uman", Password: "1234"})
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = tUtilRequest(h, http.MethodPost, "/admin/users", testToken, &userRequest{Username: "ortuman", Password: "1234"})
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	rec = tUtilRequest(h, http.MethodPost, "/admin/users", testToken, &userRequest{Username: "ortuman", Password: "1234"})
+	require.Equal(t, http.StatusConflict, rec.Code)
+
+	user, _ := storage.Instance().FetchUser("ortuman", "jackal.im")
+	require.NotNil(t, user)
+	require.True(t, user.HasScramCredentials())
+	require.True(t, auth.VerifyPassword(user, "1234"))
+
+	rec = tUtilRequest(h, http.MethodGet, "/admin/users", testToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var users []userResponse
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&users))
+	require.Equal(t, 1, len(users))
+	require.Equal(t, "ortuman", users[0].Username)
+	require.False(t, users[0].Online)
+
+	rec = tUtilRequest(h, http.MethodPut, "/admin/users/ortuman", testToken, &userRequest{Password: "4321"})
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	user, _ = storage.Instance().FetchUser("ortuman", "jackal.im")
+	require.True(t, auth.VerifyPassword(user, "4321"))
+
+	stm := tUtilBindStream("ortuman", "balcony")
+
+	rec = tUtilRequest(h, http.MethodGet, "/admin/users/ortuman", testToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp userResponse
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&resp))
+	require.True(t, resp.Online)
+
+	rec = tUtilRequest(h, http.MethodDelete, "/admin/users/ortuman", testToken, nil)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	require.Equal(t, streamerror.ErrNotAuthorized, stm.disconnectErr())
+
+	rec = tUtilRequest(h, http.MethodGet, "/admin/users/ortuman", testToken, nil)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestAdmin_ProviderUsers(t *testing.T) {
+	h, shutdown := tUtilAdminInit()
+	defer shutdown()
+
+	// external backend only knows noelia
+	passwords := map[string]string{"noelia@jackal.im": "1234"}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Action   string `json:"action"`
+			Username string `json:"username"`
+			Domain   string `json:"domain"`
+			Password string `json:"password"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		_, ok := passwords[req.Username+"@"+req.Domain]
+		if ok && req.Action == "setpass" {
+			passwords[req.Username+"@"+req.Domain] = req.Password
+		}
+		json.NewEncoder(w).Encode(map[string]bool{"result": ok})
+	}))
+	defer srv.Close()
+
+	auth.Initialize(&auth.Config{Provider: auth.ProviderConfig{Type: auth.HTTPProvider, URL: srv.URL, Timeout: time.Second}})
+
+	rec := tUtilRequest(h, http.MethodPost, "/admin/users", testToken, &userRequest{Username: "noelia", Password: "4321"})
+	require.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = tUtilRequest(h, http.MethodGet, "/admin/users/noelia", testToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	rec = tUtilRequest(h, http.MethodPut, "/admin/users/noelia", testToken, &userRequest{Password: "4321"})
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "4321", passwords["noelia@jackal.im"])
+
+	rec = tUtilRequest(h, http.MethodGet, "/admin/users/ortuman", testToken, nil)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestAdmin_UserDomains(t *testing.T) {
+	h, shutdown := tUtilAdminInit()
+	defer shutdown()
+
+	host.Shutdown()
+	host.Initialize([]host.Config{{Name: "jackal.im"}, {Name: "example.org"}})
+
+	rec := tUtilRequest(h, http.MethodPost, "/admin/users", testToken, &userRequest{Username: "ortuman", Domain: "jabber.org", Password: "1234"})
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = tUtilRequest(h, http.MethodPost, "/admin/users", testToken, &userRequest{Username: "ortuman", Password: "1234"})
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	rec = tUtilRequest(h, http.MethodPost, "/admin/users", testToken, &userRequest{Username: "ortuman", Domain: "example.org", Password: "4321"})
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	user1, _ := storage.Instance().FetchUser("ortuman", "jackal.im")
+	require.NotNil(t, user1)
+	require.True(t, auth.VerifyPassword(user1, "1234"))
+
+	user2, _ := storage.Instance().FetchUser("ortuman", "example.org")
+	require.NotNil(t, user2)
+	require.True(t, auth.VerifyPassword(user2, "4321"))
+
+	rec = tUtilRequest(h, http.MethodGet, "/admin/users/ortuman@example.org", testToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp userResponse
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&resp))
+	require.Equal(t, "example.org", resp.Domain)
+
+	rec = tUtilRequest(h, http.MethodDelete, "/admin/users/ortuman@example.org", testToken, nil)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	user1, _ = storage.Instance().FetchUser("ortuman", "jackal.im")
+	require.NotNil(t, user1)
+	user2, _ = storage.Instance().FetchUser("ortuman", "example.org")
+	require.Nil(t, user2)
+}
+
+func TestAdmin_Sessions(t *testing.T) {
+	h, shutdown := tUtilAdminInit()
+	defer shutdown()
+
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "ortuman", Domain: "jackal.im", Password: "1234"})
+
+	stm1 := tUtilBindStream("ortuman", "balcony")
+	stm2 := tUtilBindStream("ortuman", "garden")
+	defer stm2.Disconnect(nil)
+
+	p := xml.NewPresence(stm1.JID(), stm1.JID().ToBareJID(), xml.AvailableType)
+	priority := xml.NewElementName("priority")
+	priority.SetText("8")
+	p.AppendElement(priority)
+	presence, _ := xml.NewPresenceFromElement(p, stm1.JID(), stm1.JID().ToBareJID())
+	stm1.SetPresence(presence)
+
+	rec := tUtilRequest(h, http.MethodGet, "/admin/users/ortuman/sessions", testToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var sessions []sessionResponse
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&sessions))
+	require.Equal(t, 2, len(sessions))
+	for _, s := range sessions {
+		if s.Resource == "balcony" {
+			require.True(t, s.Available)
+			require.Equal(t, 8, s.Priority)
+		} else {
+			require.False(t, s.Available)
+		}
+	}
+
+	rec = tUtilRequest(h, http.MethodDelete, "/admin/users/ortuman/sessions/yard", testToken, nil)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = tUtilRequest(h, http.MethodDelete, "/admin/users/ortuman/sessions/balcony", testToken, nil)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	require.Equal(t, streamerror.ErrPolicyViolation, stm1.disconnectErr())
+	require.Nil(t, stm2.disconnectErr())
+
+	rec = tUtilRequest(h, http.MethodGet, "/admin/users/noelia/sessions", testToken, nil)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestAdmin_SendMessage(t *testing.T) {
+	h, shutdown := tUtilAdminInit()
+	defer shutdown()
+
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "ortuman", Domain: "jackal.im", Password: "1234"})
+
+	stm := tUtilBindStream("ortuman", "balcony")
+	defer stm.Disconnect(nil)
+
+	rec := tUtilRequest(h, http.MethodPost, "/admin/messages", testToken, &messageRequest{To: "noelia@jackal.im", Body: "Hi!"})
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = tUtilRequest(h, http.MethodPost, "/admin/messages", testToken, &messageRequest{To: "ortuman@jabber.org", Body: "Hi!"})
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = tUtilRequest(h, http.MethodPost, "/admin/messages", testToken, &messageRequest{To: "ortuman@jackal.im", Type: "groupchat"})
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = tUtilRequest(h, http.MethodPost, "/admin/messages", testToken, &messageRequest{
+		To:      "ortuman@jackal.im/balcony",
+		Type:    xml.HeadlineType,
+		Subject: "Maintenance",
+		Body:    "Server will be restarted in 5 minutes",
+	})
+	require.Equal(t, http.StatusAccepted, rec.Code)
+
+	elem := stm.FetchElement()
+	require.Equal(t, "message", elem.Name())
+	require.Equal(t, xml.HeadlineType, elem.Type())
+	require.Equal(t, "jackal.im", elem.From())
+	require.Equal(t, "Maintenance", elem.Elements().Child("subject").Text())
+	require.Equal(t, "Server will be restarted in 5 minutes", elem.Elements().Child("body").Text())
+}
+
+func TestAdmin_Roster(t *testing.T) {
+	h, shutdown := tUtilAdminInit()
+	defer shutdown()
+
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "ortuman", Domain: "jackal.im", Password: "1234"})
+
+	stm := tUtilBindStream("ortuman", "balcony")
+	defer stm.Disconnect(nil)
+	stm.Context().SetBool(true, "roster:requested")
+
+	rec := tUtilRequest(h, http.MethodPut, "/admin/users/ortuman/roster/noelia@jackal.im", testToken, &rosterItem{Subscription: "bad"})
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = tUtilRequest(h, http.MethodPut, "/admin/users/ortuman/roster/noelia@jackal.im", testToken, &rosterItem{
+		Name:         "My Juliet",
+		Subscription: "both",
+		Groups:       []string{"Family"},
+	})
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// expecting roster push...
+	elem := stm.FetchElement()
+	require.Equal(t, "iq", elem.Name())
+	require.Equal(t, xml.SetType, elem.Type())
+
+	rec = tUtilRequest(h, http.MethodGet, "/admin/users/ortuman/roster", testToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var items []rosterItem
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&items))
+	require.Equal(t, 1, len(items))
+	require.Equal(t, "noelia@jackal.im", items[0].JID)
+	require.Equal(t, "My Juliet", items[0].Name)
+	require.Equal(t, "both", items[0].Subscription)
+	require.Equal(t, []string{"Family"}, items[0].Groups)
+
+	rec = tUtilRequest(h, http.MethodDelete, "/admin/users/ortuman/roster/noelia@jackal.im", testToken, nil)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	elem = stm.FetchElement()
+	require.Equal(t, "iq", elem.Name())
+
+	rec = tUtilRequest(h, http.MethodDelete, "/admin/users/ortuman/roster/noelia@jackal.im", testToken, nil)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestAdmin_BlockList(t *testing.T) {
+	h, shutdown := tUtilAdminInit()
+	defer shutdown()
+
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "ortuman", Domain: "jackal.im", Password: "1234"})
+
+	rec := tUtilRequest(h, http.MethodPut, "/admin/users/ortuman/blocklist/jabber.org%2Fhome", testToken, nil)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	rec = tUtilRequest(h, http.MethodPut, "/admin/users/ortuman/blocklist/romeo@jackal.im", testToken, nil)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	romeoJID, _ := jid.NewWithString("romeo@jackal.im/balcony", true)
+	require.True(t, router.IsBlockedJID(romeoJID, "ortuman", "jackal.im"))
+
+	rec = tUtilRequest(h, http.MethodGet, "/admin/users/ortuman/blocklist", testToken, nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var items []string
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&items))
+	require.Equal(t, []string{"jabber.org/home", "romeo@jackal.im"}, items)
+
+	rec = tUtilRequest(h, http.MethodDelete, "/admin/users/ortuman/blocklist/romeo@jackal.im", testToken, nil)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	require.False(t, router.IsBlockedJID(romeoJID, "ortuman", "jackal.im"))
+
+	rec = tUtilRequest(h, http.MethodPost, "/admin/users/ortuman/blocklist", testToken, nil)
+	require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func tUtilAdminInit() (*Handler, func()) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	router.Initialize(&router.Config{})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	module.Initialize(&module.Config{Enabled: map[string]struct{}{"roster": {}}})
+	auth.Initialize(&auth.Config{ScramOnly: true})
+
+	h := New(&Config{Token: testToken})
+	return h, func() {
+		auth.Shutdown()
+		module.Shutdown()
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}
+}
+
+type tUtilStream struct {
+	*stream.MockC2S
+	mu      sync.Mutex
+	discErr error
+}
+
+func (s *tUtilStream) Disconnect(err error) {
+	s.mu.Lock()
+	s.discErr = err
+	s.mu.Unlock()
+	s.MockC2S.Disconnect(err)
+}
+
+func (s *tUtilStream) disconnectErr() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.discErr
+}
+
+func tUtilBindStream(username, resource string) *tUtilStream {
+	j, _ := jid.New(username, "jackal.im", resource, true)
+	stm := &tUtilStream{MockC2S: stream.NewMockC2S(uuid.New(), j)}
+	stm.SetAuthenticated(true)
+	router.Bind(stm)
+	return stm
+}
+
+func tUtilRequest(h http.Handler, method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	if len(token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
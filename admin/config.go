@@ -0,0 +1,35 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package admin
+
+import "errors"
+
+// Config represents admin REST API configuration.
+type Config struct {
+	Token string
+}
+
+type configProxy struct {
+	Token string `yaml:"token"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := configProxy{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	if len(p.Token) == 0 {
+		return errors.New("admin.Config: token must be specified")
+	}
+	c.Token = p.Token
+	return nil
+}
+
+// Enabled returns whether or not admin API has been configured.
+func (c *Config) Enabled() bool {
+	return len(c.Token) > 0
+}
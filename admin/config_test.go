@@ -0,0 +1,26 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package admin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestConfig(t *testing.T) {
+	var cfg Config
+	require.False(t, cfg.Enabled())
+
+	err := yaml.Unmarshal([]byte("{}"), &cfg)
+	require.NotNil(t, err)
+
+	err = yaml.Unmarshal([]byte("token: s3cr3t"), &cfg)
+	require.Nil(t, err)
+	require.True(t, cfg.Enabled())
+	require.Equal(t, "s3cr3t", cfg.Token)
+}
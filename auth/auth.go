@@ -9,6 +9,10 @@ import "github.com/ortuman/jackal/xml"
 
 const saslNamespace = "urn:ietf:params:xml:ns:xmpp-sasl"
 
+// MechanismCtxKey is the stream context key under which
+// the mechanism used to authenticate the stream is stored.
+const MechanismCtxKey = "auth:mechanism"
+
 // Authenticator defines a generic authenticator state machine.
 type Authenticator interface {
 
@@ -56,6 +60,9 @@ var (
 	// ErrSASLIncorrectEncoding represents a 'incorrect-encoding' authentication error.
 	ErrSASLIncorrectEncoding = newSASLError("incorrect-encoding")
 
+	// ErrSASLInvalidAuthzID represents a 'invalid-authzid' authentication error.
+	ErrSASLInvalidAuthzID = newSASLError("invalid-authzid")
+
 	// ErrSASLMalformedRequest represents a 'malformed-request' authentication error.
 	ErrSASLMalformedRequest = newSASLError("malformed-request")
 
@@ -33,16 +33,18 @@ func authTestSetup(user *model.User) *stream.MockC2S {
 }
 
 func authTestTeardown() {
-	storage.Instance().Shutdown()
+	storage.Shutdown()
 }
 
 func TestAuthError(t *testing.T) {
 	require.Equal(t, "incorrect-encoding", ErrSASLIncorrectEncoding.(*SASLError).Error())
+	require.Equal(t, "invalid-authzid", ErrSASLInvalidAuthzID.(*SASLError).Error())
 	require.Equal(t, "malformed-request", ErrSASLMalformedRequest.(*SASLError).Error())
 	require.Equal(t, "not-authorized", ErrSASLNotAuthorized.(*SASLError).Error())
 	require.Equal(t, "temporary-auth-failure", ErrSASLTemporaryAuthFailure.(*SASLError).Error())
 
 	require.Equal(t, "incorrect-encoding", ErrSASLIncorrectEncoding.(*SASLError).Element().Name())
+	require.Equal(t, "invalid-authzid", ErrSASLInvalidAuthzID.(*SASLError).Element().Name())
 	require.Equal(t, "malformed-request", ErrSASLMalformedRequest.(*SASLError).Element().Name())
 	require.Equal(t, "not-authorized", ErrSASLNotAuthorized.(*SASLError).Element().Name())
 	require.Equal(t, "temporary-auth-failure", ErrSASLTemporaryAuthFailure.(*SASLError).Element().Name())
@@ -0,0 +1,77 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package auth
+
+import (
+	"io"
+	"sync"
+)
+
+// Config represents server authentication configuration.
+type Config struct {
+	// ScramOnly determines whether or not only salted SCRAM credentials
+	// will be stored when setting user passwords.
+	ScramOnly bool `yaml:"scram_only"`
+
+	// Provider represents the user accounts backend
+	// used to verify user credentials.
+	Provider ProviderConfig `yaml:"provider"`
+}
+
+var (
+	cfgMu    sync.RWMutex
+	cfg      Config
+	provider Provider
+)
+
+// Initialize sets server authentication configuration,
+// instantiating its associated authentication provider.
+func Initialize(config *Config) error {
+	prv, err := NewProvider(&config.Provider)
+	if err != nil {
+		return err
+	}
+	cfgMu.Lock()
+	defer cfgMu.Unlock()
+	closeProvider()
+	cfg = *config
+	provider = prv
+	return nil
+}
+
+// Instance returns server authentication provider.
+// Storage provider will be returned in case none has been initialized.
+func Instance() Provider {
+	cfgMu.RLock()
+	defer cfgMu.RUnlock()
+	if provider == nil {
+		return NewStorage()
+	}
+	return provider
+}
+
+// Shutdown closes server authentication provider
+// restoring default authentication configuration.
+// This method should be used only for testing purposes.
+func Shutdown() {
+	cfgMu.Lock()
+	defer cfgMu.Unlock()
+	closeProvider()
+	cfg = Config{}
+	provider = nil
+}
+
+func scramOnly() bool {
+	cfgMu.RLock()
+	defer cfgMu.RUnlock()
+	return cfg.ScramOnly
+}
+
+func closeProvider() {
+	if c, ok := provider.(io.Closer); ok {
+		c.Close()
+	}
+}
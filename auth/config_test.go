@@ -0,0 +1,41 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestConfig(t *testing.T) {
+	var cfg Config
+	require.Nil(t, yaml.Unmarshal([]byte("scram_only: yes\nprovider:\n  type: http\n  url: http://127.0.0.1:8080/auth"), &cfg))
+	require.True(t, cfg.ScramOnly)
+	require.Equal(t, HTTPProvider, cfg.Provider.Type)
+
+	require.NotNil(t, yaml.Unmarshal([]byte("provider:\n  type: extauth"), &cfg))
+}
+
+func TestInitialize(t *testing.T) {
+	require.IsType(t, &Storage{}, Instance())
+	require.False(t, scramOnly())
+
+	require.Nil(t, Initialize(&Config{ScramOnly: true, Provider: ProviderConfig{Type: HTTPProvider, URL: "http://127.0.0.1:8080/auth"}}))
+	require.IsType(t, &HTTP{}, Instance())
+	require.True(t, scramOnly())
+
+	// authenticators use server provider by default
+	require.IsType(t, &HTTP{}, NewPlain(nil, nil).provider)
+
+	Shutdown()
+	require.IsType(t, &Storage{}, Instance())
+	require.False(t, scramOnly())
+
+	require.NotNil(t, Initialize(&Config{Provider: ProviderConfig{Type: ExtAuthProvider, Program: "/not/a/program"}}))
+	require.IsType(t, &Storage{}, Instance())
+}
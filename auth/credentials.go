@@ -0,0 +1,121 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"hash"
+
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/storage"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const scramSaltLength = 32
+
+// NewScramCredentials derives a new set of salted SCRAM credentials
+// from a cleartext password.
+func NewScramCredentials(password string, scramType ScramType) *model.ScramCredentials {
+	h := scramHash(scramType)
+	salt := make([]byte, scramSaltLength)
+	rand.Read(salt)
+	saltedPassword := pbkdf2.Key([]byte(password), salt, iterationsCount, h().Size(), h)
+	return &model.ScramCredentials{
+		Salt:       salt,
+		Iterations: iterationsCount,
+		StoredKey:  scramStoredKey(saltedPassword, h),
+		ServerKey:  scramServerKey(saltedPassword, h),
+	}
+}
+
+// SetUserPassword updates user credentials with a new password.
+// In case server has been configured to store SCRAM credentials only,
+// or user credentials have been already migrated, cleartext password
+// will be discarded keeping only SCRAM-SHA-1 and SCRAM-SHA-256 derived credentials.
+func SetUserPassword(user *model.User, password string) {
+	setUserPassword(user, password, scramOnly() || user.HasScramCredentials())
+}
+
+func setUserPassword(user *model.User, password string, scramOnly bool) {
+	if !scramOnly {
+		user.Password = password
+		user.ScramSHA1 = nil
+		user.ScramSHA256 = nil
+		return
+	}
+	user.Password = ""
+	user.ScramSHA1 = NewScramCredentials(password, ScramSHA1)
+	user.ScramSHA256 = NewScramCredentials(password, ScramSHA256)
+}
+
+// VerifyPassword returns whether or not a cleartext password
+// matches user stored credentials.
+func VerifyPassword(user *model.User, password string) bool {
+	if user.HasScramCredentials() {
+		c, scramType := user.ScramSHA256, ScramSHA256
+		if c == nil {
+			c, scramType = user.ScramSHA1, ScramSHA1
+		}
+		h := scramHash(scramType)
+		saltedPassword := pbkdf2.Key([]byte(password), c.Salt, c.Iterations, h().Size(), h)
+		return hmac.Equal(scramStoredKey(saltedPassword, h), c.StoredKey)
+	}
+	if len(user.Password) == 0 {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(user.Password), []byte(password)) == 1
+}
+
+// MigrateScramCredentials converts every user stored in cleartext
+// into SCRAM credentials, returning the number of migrated users.
+func MigrateScramCredentials() (int, error) {
+	users, err := storage.Instance().FetchUsers()
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	for i := range users {
+		user := &users[i]
+		if len(user.Password) == 0 {
+			continue
+		}
+		setUserPassword(user, user.Password, true)
+		if err := storage.Instance().InsertOrUpdateUser(user); err != nil {
+			return count, err
+		}
+		log.Infof("migrated user credentials... (%s)", user.Username)
+		count++
+	}
+	return count, nil
+}
+
+func scramHash(scramType ScramType) func() hash.Hash {
+	if scramType == ScramSHA1 {
+		return sha1.New
+	}
+	return sha256.New
+}
+
+func scramStoredKey(saltedPassword []byte, h func() hash.Hash) []byte {
+	hs := h()
+	hs.Write(scramHMAC([]byte("Client Key"), saltedPassword, h))
+	return hs.Sum(nil)
+}
+
+func scramServerKey(saltedPassword []byte, h func() hash.Hash) []byte {
+	return scramHMAC([]byte("Server Key"), saltedPassword, h)
+}
+
+func scramHMAC(b []byte, key []byte, h func() hash.Hash) []byte {
+	m := hmac.New(h, key)
+	m.Write(b)
+	return m.Sum(nil)
+}
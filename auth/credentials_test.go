@@ -0,0 +1,89 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package auth
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthCredentials_ScramCredentials(t *testing.T) {
+	c1 := NewScramCredentials("1234", ScramSHA1)
+	require.Equal(t, iterationsCount, c1.Iterations)
+	require.Equal(t, scramSaltLength, len(c1.Salt))
+	require.Equal(t, 20, len(c1.StoredKey))
+	require.Equal(t, 20, len(c1.ServerKey))
+
+	c2 := NewScramCredentials("1234", ScramSHA256)
+	require.Equal(t, 32, len(c2.StoredKey))
+	require.Equal(t, 32, len(c2.ServerKey))
+
+	// salts must differ between derivations
+	c3 := NewScramCredentials("1234", ScramSHA256)
+	require.NotEqual(t, c2.Salt, c3.Salt)
+	require.NotEqual(t, c2.StoredKey, c3.StoredKey)
+}
+
+func TestAuthCredentials_SetAndVerifyPassword(t *testing.T) {
+	user := &model.User{Username: "ortuman", Domain: "localhost"}
+
+	SetUserPassword(user, "1234")
+	require.Equal(t, "1234", user.Password)
+	require.False(t, user.HasScramCredentials())
+	require.True(t, VerifyPassword(user, "1234"))
+	require.False(t, VerifyPassword(user, "12345"))
+
+	Initialize(&Config{ScramOnly: true})
+	SetUserPassword(user, "4321")
+	Shutdown()
+	require.Equal(t, "", user.Password)
+	require.NotNil(t, user.ScramSHA1)
+	require.NotNil(t, user.ScramSHA256)
+	require.True(t, VerifyPassword(user, "4321"))
+	require.False(t, VerifyPassword(user, "1234"))
+
+	// migrated credentials are never downgraded
+	SetUserPassword(user, "4321")
+	require.Equal(t, "", user.Password)
+	require.True(t, user.HasScramCredentials())
+
+	// only SCRAM-SHA-1 credentials available
+	user.ScramSHA256 = nil
+	require.True(t, VerifyPassword(user, "4321"))
+
+	require.False(t, VerifyPassword(&model.User{Username: "noelia", Domain: "localhost"}, ""))
+}
+
+func TestAuthCredentials_Migrate(t *testing.T) {
+	authTestSetup(&model.User{Username: "ortuman", Domain: "localhost", Password: "1234"})
+	defer authTestTeardown()
+
+	scramUser := &model.User{Username: "noelia", Domain: "localhost"}
+	setUserPassword(scramUser, "4321", true)
+	storage.Instance().InsertOrUpdateUser(scramUser)
+
+	storage.ActivateMockedError()
+	_, err := MigrateScramCredentials()
+	require.NotNil(t, err)
+	storage.DeactivateMockedError()
+
+	count, err := MigrateScramCredentials()
+	require.Nil(t, err)
+	require.Equal(t, 1, count)
+
+	user, _ := storage.Instance().FetchUser("ortuman", "localhost")
+	require.Equal(t, "", user.Password)
+	require.True(t, user.HasScramCredentials())
+	require.True(t, VerifyPassword(user, "1234"))
+
+	// already migrated users are left untouched
+	count, err = MigrateScramCredentials()
+	require.Nil(t, err)
+	require.Equal(t, 0, count)
+}
@@ -186,11 +186,12 @@ func (d *DigestMD5) handleChallenged(elem xml.XElement) error {
 		return ErrSASLNotAuthorized
 	}
 	// validate user
-	user, err := storage.Instance().FetchUser(params.username)
+	user, err := storage.Instance().FetchUser(params.username, d.stm.Domain())
 	if err != nil {
 		return err
 	}
-	if user == nil {
+	if user == nil || user.Disabled || len(user.Password) == 0 {
+		// DIGEST-MD5 requires cleartext password to be stored
 		return ErrSASLNotAuthorized
 	}
 	// validate response
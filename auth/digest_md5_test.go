@@ -62,7 +62,7 @@ func (h *digestMD5AuthTestHelper) serializeParams(params *digestMD5Parameters) s
 }
 
 func TestDigesMD5Authentication(t *testing.T) {
-	user := &model.User{Username: "mariana", Password: "1234"}
+	user := &model.User{Username: "mariana", Domain: "localhost", Password: "1234"}
 	testStrm := authTestSetup(user)
 	defer authTestTeardown()
 
@@ -131,7 +131,7 @@ func TestDigesMD5Authentication(t *testing.T) {
 
 	// invalid password...
 	cl7 := *clParams
-	user2 := &model.User{Username: "mariana", Password: "bad_password"}
+	user2 := &model.User{Username: "mariana", Domain: "localhost", Password: "bad_password"}
 	badClientResp := authr.computeResponse(&cl7, user2, true)
 	cl7.setParameter("response=" + badClientResp)
 	require.Equal(t, ErrSASLNotAuthorized, helper.sendClientParamsResponse(&cl7))
@@ -0,0 +1,184 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package auth
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ortuman/jackal/log"
+)
+
+var (
+	errExtAuthNotRunning  = errors.New("auth.ExtAuth: program not running")
+	errExtAuthTimeout     = errors.New("auth.ExtAuth: request timed out")
+	errExtAuthBadResponse = errors.New("auth.ExtAuth: bad response")
+	errExtAuthTooLarge    = errors.New("auth.ExtAuth: request too large")
+	errExtAuthBadArgument = errors.New("auth.ExtAuth: invalid username or domain")
+)
+
+// ExtAuth represents an authentication provider that delegates
+// credentials verification to an external program, using ejabberd's
+// extauth wire protocol over program's standard input and output.
+//
+// Every request is a 2 byte big-endian length prefixed string
+// (auth:User:Server:Password, isuser:User:Server or
+// setpass:User:Server:Password), answered with a 2 byte length
+// prefix followed by a 2 byte result (1 on success, 0 otherwise).
+type ExtAuth struct {
+	program string
+	timeout time.Duration
+	mu      sync.Mutex
+	cmd     *exec.Cmd
+	r       io.Reader
+	w       io.Writer
+}
+
+// NewExtAuth spawns an external authentication program
+// returning its associated provider instance.
+func NewExtAuth(program string, timeout time.Duration) (*ExtAuth, error) {
+	e := &ExtAuth{program: program, timeout: timeout}
+	if err := e.start(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func newExtAuthWithPipe(r io.Reader, w io.Writer, timeout time.Duration) *ExtAuth {
+	return &ExtAuth{timeout: timeout, r: r, w: w}
+}
+
+// Authenticate returns whether or not external program
+// accepts user cleartext password.
+func (e *ExtAuth) Authenticate(username, domain, password string) (bool, error) {
+	return e.request("auth", username, domain, password)
+}
+
+// UserExists returns whether or not external program
+// recognizes a user account.
+func (e *ExtAuth) UserExists(username, domain string) (bool, error) {
+	return e.request("isuser", username, domain)
+}
+
+// SetPassword requests external program to update
+// a user account password.
+func (e *ExtAuth) SetPassword(username, domain, password string) error {
+	ok, err := e.request("setpass", username, domain, password)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("auth.ExtAuth: setpass rejected for %s@%s", username, domain)
+	}
+	return nil
+}
+
+// Close terminates external program.
+func (e *ExtAuth) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.stop()
+	return nil
+}
+
+func (e *ExtAuth) request(op, username, domain string, args ...string) (bool, error) {
+	// username and domain can't contain field separators,
+	// otherwise they could inject arbitrary arguments
+	if strings.ContainsAny(username, ":\n") || strings.ContainsAny(domain, ":\n") {
+		return false, errExtAuthBadArgument
+	}
+	msg := strings.Join(append([]string{op, username, domain}, args...), ":")
+	if len(msg) > math.MaxUint16 {
+		return false, errExtAuthTooLarge
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.w == nil {
+		// restart program in case it previously failed
+		if err := e.start(); err != nil {
+			return false, err
+		}
+	}
+	type result struct {
+		ok  bool
+		err error
+	}
+	resCh := make(chan result, 1)
+	go func(r io.Reader, w io.Writer) {
+		ok, err := extAuthExchange(r, w, msg)
+		resCh <- result{ok, err}
+	}(e.r, e.w)
+
+	select {
+	case res := <-resCh:
+		if res.err != nil {
+			e.stop()
+		}
+		return res.ok, res.err
+	case <-time.After(e.timeout):
+		e.stop()
+		return false, errExtAuthTimeout
+	}
+}
+
+func (e *ExtAuth) start() error {
+	args := strings.Fields(e.program)
+	if len(args) == 0 {
+		return errExtAuthNotRunning
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	w, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	r, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	log.Infof("extauth: started program... (pid: %d)", cmd.Process.Pid)
+	e.cmd = cmd
+	e.r = r
+	e.w = w
+	return nil
+}
+
+func (e *ExtAuth) stop() {
+	if e.cmd != nil {
+		e.cmd.Process.Kill()
+		e.cmd.Wait()
+		e.cmd = nil
+	}
+	e.r = nil
+	e.w = nil
+}
+
+func extAuthExchange(r io.Reader, w io.Writer, msg string) (bool, error) {
+	b := make([]byte, 2+len(msg))
+	binary.BigEndian.PutUint16(b, uint16(len(msg)))
+	copy(b[2:], msg)
+	if _, err := w.Write(b); err != nil {
+		return false, err
+	}
+	var resp [4]byte
+	if _, err := io.ReadFull(r, resp[:]); err != nil {
+		return false, err
+	}
+	if binary.BigEndian.Uint16(resp[:2]) != 2 {
+		return false, errExtAuthBadResponse
+	}
+	return binary.BigEndian.Uint16(resp[2:]) == 1, nil
+}
@@ -0,0 +1,164 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package auth
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var tExtAuthPasswords = map[string]string{"mariana@localhost": "1234"}
+
+func TestExtAuthProvider(t *testing.T) {
+	reqR, reqW := io.Pipe()
+	respR, respW := io.Pipe()
+	go tUtilExtAuthServe(reqR, respW)
+
+	prv := newExtAuthWithPipe(respR, reqW, time.Second)
+
+	ok, err := prv.Authenticate("mariana", "localhost", "1234")
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	ok, err = prv.Authenticate("mariana", "localhost", "12345")
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	ok, err = prv.UserExists("mariana", "localhost")
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	ok, err = prv.UserExists("ortuman", "localhost")
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	// passwords may contain separator
+	require.Nil(t, prv.SetPassword("mariana", "localhost", "ab:cd"))
+	ok, _ = prv.Authenticate("mariana", "localhost", "ab:cd")
+	require.True(t, ok)
+
+	require.NotNil(t, prv.SetPassword("ortuman", "localhost", "abcd"))
+
+	_, err = prv.Authenticate("mariana", "localhost", strings.Repeat("a", 65536))
+	require.Equal(t, errExtAuthTooLarge, err)
+
+	// separators within username or domain
+	_, err = prv.Authenticate("mariana:localhost:1234", "localhost", "1234")
+	require.Equal(t, errExtAuthBadArgument, err)
+	_, err = prv.UserExists("mariana", "localhost\nisuser")
+	require.Equal(t, errExtAuthBadArgument, err)
+	require.Equal(t, errExtAuthBadArgument, prv.SetPassword("mari\nana", "localhost", "abcd"))
+
+	// broken program...
+	respW.Close()
+	_, err = prv.Authenticate("mariana", "localhost", "1234")
+	require.NotNil(t, err)
+
+	// ...can't be restarted
+	_, err = prv.Authenticate("mariana", "localhost", "1234")
+	require.Equal(t, errExtAuthNotRunning, err)
+}
+
+func TestExtAuthProviderTimeout(t *testing.T) {
+	_, reqW := io.Pipe() // request is never read
+	respR, _ := io.Pipe()
+
+	prv := newExtAuthWithPipe(respR, reqW, time.Millisecond*100)
+	_, err := prv.Authenticate("mariana", "localhost", "1234")
+	require.Equal(t, errExtAuthTimeout, err)
+}
+
+func TestExtAuthProviderBadResponse(t *testing.T) {
+	reqR, reqW := io.Pipe()
+	respR, respW := io.Pipe()
+	go func() {
+		var hdr [2]byte
+		io.ReadFull(reqR, hdr[:])
+		io.ReadFull(reqR, make([]byte, binary.BigEndian.Uint16(hdr[:])))
+		respW.Write([]byte{0, 1, 0, 1})
+	}()
+	prv := newExtAuthWithPipe(respR, reqW, time.Second)
+	_, err := prv.Authenticate("mariana", "localhost", "1234")
+	require.Equal(t, errExtAuthBadResponse, err)
+}
+
+func TestExtAuthProgram(t *testing.T) {
+	os.Setenv("JACKAL_EXTAUTH_HELPER", "1")
+	defer os.Unsetenv("JACKAL_EXTAUTH_HELPER")
+
+	prv, err := NewExtAuth(os.Args[0]+" -test.run=TestExtAuthHelperProgram", time.Second*5)
+	require.Nil(t, err)
+	defer prv.Close()
+
+	ok, err := prv.Authenticate("mariana", "localhost", "1234")
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	// restart after program termination
+	prv.mu.Lock()
+	prv.cmd.Process.Kill()
+	prv.mu.Unlock()
+
+	_, err = prv.UserExists("mariana", "localhost")
+	require.NotNil(t, err)
+
+	ok, err = prv.UserExists("mariana", "localhost")
+	require.Nil(t, err)
+	require.True(t, ok)
+}
+
+// TestExtAuthHelperProgram isn't a real test. It's used as
+// an external authentication program by TestExtAuthProgram.
+func TestExtAuthHelperProgram(t *testing.T) {
+	if os.Getenv("JACKAL_EXTAUTH_HELPER") != "1" {
+		return
+	}
+	tUtilExtAuthServe(os.Stdin, os.Stdout)
+	os.Exit(0)
+}
+
+func tUtilExtAuthServe(r io.Reader, w io.Writer) {
+	passwords := make(map[string]string)
+	for k, v := range tExtAuthPasswords {
+		passwords[k] = v
+	}
+	for {
+		var hdr [2]byte
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return
+		}
+		b := make([]byte, binary.BigEndian.Uint16(hdr[:]))
+		if _, err := io.ReadFull(r, b); err != nil {
+			return
+		}
+		var ok bool
+		args := strings.SplitN(string(b), ":", 4)
+		key := args[1] + "@" + args[2]
+		switch args[0] {
+		case "auth":
+			ok = passwords[key] == args[3]
+		case "isuser":
+			_, ok = passwords[key]
+		case "setpass":
+			if _, ok = passwords[key]; ok {
+				passwords[key] = args[3]
+			}
+		}
+		resp := []byte{0, 2, 0, 0}
+		if ok {
+			resp[3] = 1
+		}
+		if _, err := w.Write(resp); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,182 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package auth
+
+import (
+	"encoding/base64"
+
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/transport"
+	"github.com/ortuman/jackal/util"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+)
+
+// CertificateMapping represents the certificate field
+// used to map a client certificate into a user account.
+type CertificateMapping int
+
+const (
+	// XMPPAddrMapping maps accounts from id-on-xmppAddr subject alternative names.
+	XMPPAddrMapping CertificateMapping = iota
+
+	// EmailMapping maps accounts from rfc822Name subject alternative names.
+	EmailMapping
+)
+
+// External represents an EXTERNAL authenticator (XEP-0178).
+type External struct {
+	stm           stream.C2S
+	tr            transport.Transport
+	mapping       CertificateMapping
+	autoProvision bool
+	username      string
+	authenticated bool
+}
+
+// NewExternal returns a new external authenticator instance.
+// Client certificate chain is expected to be already verified
+// at TLS negotiation time.
+func NewExternal(stm stream.C2S, tr transport.Transport, mapping CertificateMapping, autoProvision bool) *External {
+	return &External{
+		stm:           stm,
+		tr:            tr,
+		mapping:       mapping,
+		autoProvision: autoProvision,
+	}
+}
+
+// Mechanism returns authenticator mechanism name.
+func (e *External) Mechanism() string {
+	return "EXTERNAL"
+}
+
+// Username returns authenticated username in case
+// authentication process has been completed.
+func (e *External) Username() string {
+	return e.username
+}
+
+// Authenticated returns whether or not user has been authenticated.
+func (e *External) Authenticated() bool {
+	return e.authenticated
+}
+
+// UsesChannelBinding returns whether or not external authenticator
+// requires channel binding bytes.
+func (e *External) UsesChannelBinding() bool {
+	return false
+}
+
+// ProcessElement process an incoming authenticator element.
+func (e *External) ProcessElement(elem xml.XElement) error {
+	if e.authenticated {
+		return nil
+	}
+	var authzID string
+	if txt := elem.Text(); len(txt) > 0 && txt != "=" {
+		b, err := base64.StdEncoding.DecodeString(txt)
+		if err != nil {
+			return ErrSASLIncorrectEncoding
+		}
+		authzID = string(b)
+	}
+	certs := e.tr.PeerCertificates()
+	if len(certs) == 0 {
+		return ErrSASLNotAuthorized
+	}
+	identities := e.certificateIdentities(certs[0].EmailAddresses, util.XMPPAddresses(certs[0]))
+
+	var username string
+	switch {
+	case len(authzID) > 0:
+		j, err := jid.NewWithString(authzID, false)
+		if err != nil || !j.IsBare() || j.Domain() != e.stm.Domain() {
+			return ErrSASLInvalidAuthzID
+		}
+		for _, identity := range identities {
+			if identity.Node() == j.Node() {
+				username = j.Node()
+				break
+			}
+		}
+		if len(username) == 0 {
+			return ErrSASLInvalidAuthzID
+		}
+	case len(identities) == 1:
+		username = identities[0].Node()
+	case len(identities) > 1:
+		// ambiguous certificate identity
+		return ErrSASLInvalidAuthzID
+	default:
+		return ErrSASLNotAuthorized
+	}
+	if err := e.provisionUser(username); err != nil {
+		return err
+	}
+	e.username = username
+	e.authenticated = true
+
+	e.stm.SendElement(xml.NewElementNamespace("success", saslNamespace))
+	return nil
+}
+
+// Reset resets external authenticator internal state.
+func (e *External) Reset() {
+	e.username = ""
+	e.authenticated = false
+}
+
+func (e *External) certificateIdentities(emails, xmppAddrs []string) []*jid.JID {
+	var addrs []string
+	switch e.mapping {
+	case XMPPAddrMapping:
+		addrs = xmppAddrs
+	case EmailMapping:
+		addrs = emails
+	}
+	var identities []*jid.JID
+	for _, addr := range addrs {
+		j, err := jid.NewWithString(addr, false)
+		if err != nil || !j.IsBare() {
+			continue
+		}
+		if j.Domain() == e.stm.Domain() {
+			identities = append(identities, j)
+		}
+	}
+	return identities
+}
+
+func (e *External) provisionUser(username string) error {
+	user, err := storage.Instance().FetchUser(username, e.stm.Domain())
+	if err != nil {
+		return err
+	}
+	if user != nil {
+		if user.Disabled {
+			return ErrSASLNotAuthorized
+		}
+		return nil
+	}
+	if !e.autoProvision {
+		return ErrSASLNotAuthorized
+	}
+	userJID, _ := jid.New(username, e.stm.Domain(), "", true)
+	user = &model.User{
+		Username:     username,
+		Domain:       e.stm.Domain(),
+		LastPresence: xml.NewPresence(userJID, userJID, xml.UnavailableType),
+	}
+	if err := storage.Instance().InsertOrUpdateUser(user); err != nil {
+		return err
+	}
+	log.Infof("external: provisioned user... (%s@%s)", user.Username, user.Domain)
+	return nil
+}
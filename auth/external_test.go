@@ -0,0 +1,113 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package auth
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"testing"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/storage/memstorage"
+	"github.com/ortuman/jackal/xml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthExternalAuthentication(t *testing.T) {
+	testStm := authTestSetup(&model.User{Username: "user", Domain: "localhost"})
+	defer authTestTeardown()
+
+	tr := &fakeTransport{}
+	authr := NewExternal(testStm, tr, XMPPAddrMapping, false)
+	require.Equal(t, "EXTERNAL", authr.Mechanism())
+	require.False(t, authr.UsesChannelBinding())
+
+	elem := xml.NewElementNamespace("auth", "urn:ietf:params:xml:ns:xmpp-sasl")
+	elem.SetAttribute("mechanism", "EXTERNAL")
+	elem.SetText("=")
+
+	// no client certificate...
+	require.Equal(t, ErrSASLNotAuthorized, authr.ProcessElement(elem))
+
+	tr.peerCerts = []*x509.Certificate{tUtilClientCertificate(t)}
+
+	// storage error...
+	storage.ActivateMockedError()
+	require.Equal(t, memstorage.ErrMockedError, authr.ProcessElement(elem))
+	storage.DeactivateMockedError()
+
+	// certificate identity...
+	require.Nil(t, authr.ProcessElement(elem))
+	require.True(t, authr.Authenticated())
+	require.Equal(t, "user", authr.Username())
+	require.Equal(t, "success", testStm.FetchElement().Name())
+
+	// already authenticated...
+	require.Nil(t, authr.ProcessElement(elem))
+
+	// matching authorization identity...
+	authr.Reset()
+	elem.SetText(base64.StdEncoding.EncodeToString([]byte("user@localhost")))
+	require.Nil(t, authr.ProcessElement(elem))
+	require.Equal(t, "user", authr.Username())
+
+	// foreign authorization identity...
+	authr.Reset()
+	elem.SetText(base64.StdEncoding.EncodeToString([]byte("mariana@localhost")))
+	require.Equal(t, ErrSASLInvalidAuthzID, authr.ProcessElement(elem))
+
+	authr.Reset()
+	elem.SetText(base64.StdEncoding.EncodeToString([]byte("user@jackal.im")))
+	require.Equal(t, ErrSASLInvalidAuthzID, authr.ProcessElement(elem))
+
+	// bad encoding...
+	authr.Reset()
+	elem.SetText("bad formed base64")
+	require.Equal(t, ErrSASLIncorrectEncoding, authr.ProcessElement(elem))
+
+	// disabled user...
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Domain: "localhost", Disabled: true})
+	authr.Reset()
+	elem.SetText("=")
+	require.Equal(t, ErrSASLNotAuthorized, authr.ProcessElement(elem))
+}
+
+func TestAuthExternalMapping(t *testing.T) {
+	testStm := authTestSetup(&model.User{Username: "user", Domain: "localhost"})
+	defer authTestTeardown()
+
+	tr := &fakeTransport{peerCerts: []*x509.Certificate{tUtilClientCertificate(t)}}
+
+	elem := xml.NewElementNamespace("auth", "urn:ietf:params:xml:ns:xmpp-sasl")
+	elem.SetAttribute("mechanism", "EXTERNAL")
+
+	// email mapped account doesn't exist...
+	authr := NewExternal(testStm, tr, EmailMapping, false)
+	require.Equal(t, ErrSASLNotAuthorized, authr.ProcessElement(elem))
+
+	// ...unless auto-provisioning is enabled
+	authr = NewExternal(testStm, tr, EmailMapping, true)
+	require.Nil(t, authr.ProcessElement(elem))
+	require.Equal(t, "device", authr.Username())
+
+	user, _ := storage.Instance().FetchUser("device", "localhost")
+	require.NotNil(t, user)
+	require.Equal(t, "localhost", user.Domain)
+	require.Equal(t, 0, len(user.Password))
+}
+
+func tUtilClientCertificate(t *testing.T) *x509.Certificate {
+	b, err := ioutil.ReadFile("../testdata/cert/test.client.crt")
+	require.Nil(t, err)
+	block, _ := pem.Decode(b)
+	require.NotNil(t, block)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.Nil(t, err)
+	return cert
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type httpRequest struct {
+	Action   string `json:"action"`
+	Username string `json:"username"`
+	Domain   string `json:"domain"`
+	Password string `json:"password,omitempty"`
+}
+
+type httpResponse struct {
+	Result bool `json:"result"`
+}
+
+// HTTP represents an authentication provider that delegates
+// credentials verification to an HTTP service.
+//
+// Every request is a JSON encoded POST containing action
+// (auth, isuser or setpass), username, domain and password fields,
+// expected to be answered with a 200 status code and a JSON encoded
+// {"result": true|false} body.
+type HTTP struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTP returns a new HTTP authentication provider instance.
+func NewHTTP(url string, timeout time.Duration) *HTTP {
+	return &HTTP{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+// Authenticate returns whether or not HTTP service
+// accepts user cleartext password.
+func (h *HTTP) Authenticate(username, domain, password string) (bool, error) {
+	return h.request(&httpRequest{Action: "auth", Username: username, Domain: domain, Password: password})
+}
+
+// UserExists returns whether or not HTTP service
+// recognizes a user account.
+func (h *HTTP) UserExists(username, domain string) (bool, error) {
+	return h.request(&httpRequest{Action: "isuser", Username: username, Domain: domain})
+}
+
+// SetPassword requests HTTP service to update
+// a user account password.
+func (h *HTTP) SetPassword(username, domain, password string) error {
+	ok, err := h.request(&httpRequest{Action: "setpass", Username: username, Domain: domain, Password: password})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("auth.HTTP: setpass rejected for %s@%s", username, domain)
+	}
+	return nil
+}
+
+func (h *HTTP) request(req *httpRequest) (bool, error) {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return false, err
+	}
+	resp, err := h.client.Post(h.url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("auth.HTTP: unexpected status code: %d", resp.StatusCode)
+	}
+	var res httpResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return false, err
+	}
+	return res.Result, nil
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPProvider(t *testing.T) {
+	srv := tUtilHTTPServer(map[string]string{"mariana@localhost": "1234"})
+	defer srv.Close()
+
+	prv := NewHTTP(srv.URL, time.Second)
+
+	ok, err := prv.Authenticate("mariana", "localhost", "1234")
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	ok, err = prv.Authenticate("mariana", "localhost", "12345")
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	ok, err = prv.UserExists("mariana", "localhost")
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	ok, err = prv.UserExists("ortuman", "localhost")
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	require.Nil(t, prv.SetPassword("mariana", "localhost", "abcd"))
+	ok, _ = prv.Authenticate("mariana", "localhost", "abcd")
+	require.True(t, ok)
+
+	require.NotNil(t, prv.SetPassword("ortuman", "localhost", "abcd"))
+
+	// unexpected status code...
+	_, err = NewHTTP(srv.URL+"/foo", time.Second).Authenticate("mariana", "localhost", "abcd")
+	require.NotNil(t, err)
+
+	// bad response...
+	_, err = NewHTTP(srv.URL+"/bad", time.Second).Authenticate("mariana", "localhost", "abcd")
+	require.NotNil(t, err)
+
+	// unreachable service...
+	srv.Close()
+	_, err = prv.Authenticate("mariana", "localhost", "abcd")
+	require.NotNil(t, err)
+}
+
+func tUtilHTTPServer(passwords map[string]string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" || r.Method != http.MethodPost {
+			http.NotFound(w, r)
+			return
+		}
+		var req httpRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var res httpResponse
+		key := req.Username + "@" + req.Domain
+		switch req.Action {
+		case "auth":
+			pass, ok := passwords[key]
+			res.Result = ok && pass == req.Password
+		case "isuser":
+			_, res.Result = passwords[key]
+		case "setpass":
+			if _, res.Result = passwords[key]; res.Result {
+				passwords[key] = req.Password
+			}
+		}
+		json.NewEncoder(w).Encode(&res)
+	})
+	mux.HandleFunc("/bad", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("bad response"))
+	})
+	return httptest.NewServer(mux)
+}
@@ -9,7 +9,6 @@ import (
 	"bytes"
 	"encoding/base64"
 
-	"github.com/ortuman/jackal/storage"
 	"github.com/ortuman/jackal/stream"
 	"github.com/ortuman/jackal/xml"
 )
@@ -17,13 +16,16 @@ import (
 // Plain represents a PLAIN authenticator.
 type Plain struct {
 	stm           stream.C2S
+	provider      Provider
 	username      string
 	authenticated bool
 }
 
 // NewPlain returns a new plain authenticator instance.
-func NewPlain(stm stream.C2S) *Plain {
-	return &Plain{stm: stm}
+// User credentials are verified against provider,
+// or against server authentication provider in case none is specified.
+func NewPlain(stm stream.C2S, provider Provider) *Plain {
+	return &Plain{stm: stm, provider: providerOrDefault(provider)}
 }
 
 // Mechanism returns authenticator mechanism name.
@@ -68,11 +70,18 @@ func (p *Plain) ProcessElement(elem xml.XElement) error {
 	password := string(s[2])
 
 	// validate user and password
-	user, err := storage.Instance().FetchUser(username)
+	ok, err := p.provider.Authenticate(username, p.stm.Domain(), password)
 	if err != nil {
 		return err
 	}
-	if user == nil || user.Password != password {
+	if !ok {
+		return ErrSASLNotAuthorized
+	}
+	user, err := localUser(username, p.stm.Domain())
+	if err != nil {
+		return err
+	}
+	if user.Disabled {
 		return ErrSASLNotAuthorized
 	}
 	p.username = username
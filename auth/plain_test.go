@@ -20,10 +20,10 @@ import (
 func TestAuthPlainAuthentication(t *testing.T) {
 	var err error
 
-	testStm := authTestSetup(&model.User{Username: "mariana", Password: "1234"})
+	testStm := authTestSetup(&model.User{Username: "mariana", Domain: "localhost", Password: "1234"})
 	defer authTestTeardown()
 
-	authr := NewPlain(testStm)
+	authr := NewPlain(testStm, nil)
 	require.Equal(t, authr.Mechanism(), "PLAIN")
 	require.False(t, authr.UsesChannelBinding())
 
@@ -101,4 +101,45 @@ func TestAuthPlainAuthentication(t *testing.T) {
 	authr.Reset()
 	err = authr.ProcessElement(elem)
 	require.Equal(t, ErrSASLNotAuthorized, err)
+
+	// disabled user
+	buf.Reset()
+	buf.WriteByte(0)
+	buf.WriteString("mariana")
+	buf.WriteByte(0)
+	buf.WriteString("1234")
+	elem.SetText(base64.StdEncoding.EncodeToString(buf.Bytes()))
+
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "mariana", Domain: "localhost", Password: "1234", Disabled: true})
+	authr.Reset()
+	err = authr.ProcessElement(elem)
+	require.Equal(t, ErrSASLNotAuthorized, err)
+}
+
+func TestAuthPlainScramCredentials(t *testing.T) {
+	user := &model.User{Username: "mariana", Domain: "localhost"}
+	setUserPassword(user, "1234", true)
+
+	testStm := authTestSetup(user)
+	defer authTestTeardown()
+
+	authr := NewPlain(testStm, nil)
+
+	elem := xml.NewElementNamespace("auth", "urn:ietf:params:xml:ns:xmpp-sasl")
+	elem.SetAttribute("mechanism", "PLAIN")
+	elem.SetText(base64.StdEncoding.EncodeToString([]byte("\x00mariana\x0012345")))
+
+	// incorrect password
+	require.Equal(t, ErrSASLNotAuthorized, authr.ProcessElement(elem))
+
+	// empty password
+	authr.Reset()
+	elem.SetText(base64.StdEncoding.EncodeToString([]byte("\x00mariana\x00")))
+	require.Equal(t, ErrSASLNotAuthorized, authr.ProcessElement(elem))
+
+	// valid credentials...
+	authr.Reset()
+	elem.SetText(base64.StdEncoding.EncodeToString([]byte("\x00mariana\x001234")))
+	require.Nil(t, authr.ProcessElement(elem))
+	require.True(t, authr.Authenticated())
 }
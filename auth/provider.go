@@ -0,0 +1,191 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package auth
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+)
+
+const defaultProviderTimeout = time.Duration(5) * time.Second
+
+// ProviderType represents an authentication provider type.
+type ProviderType int
+
+const (
+	// StorageProvider represents a storage backed authentication provider.
+	StorageProvider ProviderType = iota
+
+	// ExtAuthProvider represents an external program authentication provider.
+	ExtAuthProvider
+
+	// HTTPProvider represents an HTTP service authentication provider.
+	HTTPProvider
+)
+
+// ProviderConfig represents an authentication provider configuration.
+type ProviderConfig struct {
+	Type    ProviderType
+	Program string
+	URL     string
+	Timeout time.Duration
+}
+
+type providerProxyType struct {
+	Type    string `yaml:"type"`
+	Program string `yaml:"program"`
+	URL     string `yaml:"url"`
+	Timeout int    `yaml:"timeout"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (c *ProviderConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := providerProxyType{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	switch p.Type {
+	case "", "storage":
+		c.Type = StorageProvider
+
+	case "extauth":
+		if len(p.Program) == 0 {
+			return fmt.Errorf("auth.ProviderConfig: extauth provider requires a program")
+		}
+		c.Type = ExtAuthProvider
+
+	case "http":
+		if len(p.URL) == 0 {
+			return fmt.Errorf("auth.ProviderConfig: http provider requires a url")
+		}
+		c.Type = HTTPProvider
+
+	default:
+		return fmt.Errorf("auth.ProviderConfig: unrecognized provider type: %s", p.Type)
+	}
+	c.Program = p.Program
+	c.URL = p.URL
+	c.Timeout = time.Duration(p.Timeout) * time.Second
+	if c.Timeout == 0 {
+		c.Timeout = defaultProviderTimeout
+	}
+	return nil
+}
+
+// Provider defines a pluggable user accounts backend
+// used to verify user credentials.
+type Provider interface {
+
+	// Authenticate returns whether or not a cleartext password
+	// is valid for a given user account.
+	Authenticate(username, domain, password string) (bool, error)
+
+	// UserExists returns whether or not a user account exists.
+	UserExists(username, domain string) (bool, error)
+
+	// SetPassword updates a user account password.
+	SetPassword(username, domain, password string) error
+}
+
+// CredentialsProvider is implemented by those providers able to
+// supply stored user credentials, as required by challenge-response
+// mechanisms such as SCRAM.
+type CredentialsProvider interface {
+	Provider
+
+	// FetchUser retrieves a user entity containing its stored credentials.
+	FetchUser(username, domain string) (*model.User, error)
+}
+
+// NewProvider returns a new authentication provider
+// instance associated to a given configuration.
+func NewProvider(cfg *ProviderConfig) (Provider, error) {
+	switch cfg.Type {
+	case ExtAuthProvider:
+		return NewExtAuth(cfg.Program, cfg.Timeout)
+	case HTTPProvider:
+		return NewHTTP(cfg.URL, cfg.Timeout), nil
+	default:
+		return NewStorage(), nil
+	}
+}
+
+// Storage represents a storage backed authentication provider.
+type Storage struct{}
+
+// NewStorage returns a new storage authentication provider instance.
+func NewStorage() *Storage {
+	return &Storage{}
+}
+
+// Authenticate returns whether or not a cleartext password
+// matches user stored credentials.
+func (s *Storage) Authenticate(username, domain, password string) (bool, error) {
+	user, err := storage.Instance().FetchUser(username, domain)
+	if err != nil {
+		return false, err
+	}
+	return user != nil && VerifyPassword(user, password), nil
+}
+
+// UserExists returns whether or not a user exists within storage.
+func (s *Storage) UserExists(username, domain string) (bool, error) {
+	return storage.Instance().UserExists(username, domain)
+}
+
+// SetPassword updates user stored credentials.
+func (s *Storage) SetPassword(username, domain, password string) error {
+	user, err := storage.Instance().FetchUser(username, domain)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fmt.Errorf("auth: user not found: %s@%s", username, domain)
+	}
+	SetUserPassword(user, password)
+	return storage.Instance().InsertOrUpdateUser(user)
+}
+
+// FetchUser retrieves from storage a user entity.
+func (s *Storage) FetchUser(username, domain string) (*model.User, error) {
+	return storage.Instance().FetchUser(username, domain)
+}
+
+func providerOrDefault(provider Provider) Provider {
+	if provider == nil {
+		return Instance()
+	}
+	return provider
+}
+
+// localUser returns the storage entity of an account whose credentials
+// have been verified by a provider, inserting it in case the account
+// is only known by that provider. This way every authenticated account
+// can be routed to and disabled as any other local account.
+func localUser(username, domain string) (*model.User, error) {
+	user, err := storage.Instance().FetchUser(username, domain)
+	if err != nil {
+		return nil, err
+	}
+	if user != nil {
+		return user, nil
+	}
+	userJID, _ := jid.New(username, domain, "", true)
+	user = &model.User{
+		Username:     username,
+		Domain:       domain,
+		LastPresence: xml.NewPresence(userJID, userJID, xml.UnavailableType),
+	}
+	if err := storage.Instance().InsertOrUpdateUser(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,196 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package auth
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/storage/memstorage"
+	"github.com/ortuman/jackal/xml"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+var errFakeProvider = errors.New("fakeProvider: unavailable")
+
+type fakeProvider struct {
+	passwords   map[string]string
+	unavailable bool
+}
+
+func (p *fakeProvider) Authenticate(username, domain, password string) (bool, error) {
+	if p.unavailable {
+		return false, errFakeProvider
+	}
+	pass, ok := p.passwords[username+"@"+domain]
+	return ok && pass == password, nil
+}
+
+func (p *fakeProvider) UserExists(username, domain string) (bool, error) {
+	if p.unavailable {
+		return false, errFakeProvider
+	}
+	_, ok := p.passwords[username+"@"+domain]
+	return ok, nil
+}
+
+func (p *fakeProvider) SetPassword(username, domain, password string) error {
+	if p.unavailable {
+		return errFakeProvider
+	}
+	p.passwords[username+"@"+domain] = password
+	return nil
+}
+
+type fakeCredentialsProvider struct {
+	fakeProvider
+	user *model.User
+}
+
+func (p *fakeCredentialsProvider) FetchUser(username, domain string) (*model.User, error) {
+	if p.user.Username == username && p.user.Domain == domain {
+		return p.user, nil
+	}
+	return nil, nil
+}
+
+func TestProviderConfig(t *testing.T) {
+	var cfg ProviderConfig
+	require.Nil(t, yaml.Unmarshal([]byte(`{}`), &cfg))
+	require.Equal(t, StorageProvider, cfg.Type)
+	require.Equal(t, defaultProviderTimeout, cfg.Timeout)
+
+	require.Nil(t, yaml.Unmarshal([]byte("type: extauth\nprogram: /usr/bin/auth.py\ntimeout: 2"), &cfg))
+	require.Equal(t, ExtAuthProvider, cfg.Type)
+	require.Equal(t, "/usr/bin/auth.py", cfg.Program)
+	require.Equal(t, time.Second*2, cfg.Timeout)
+
+	require.Nil(t, yaml.Unmarshal([]byte("type: http\nurl: http://127.0.0.1:8080/auth"), &cfg))
+	require.Equal(t, HTTPProvider, cfg.Type)
+	require.Equal(t, "http://127.0.0.1:8080/auth", cfg.URL)
+
+	cfg = ProviderConfig{}
+	require.NotNil(t, yaml.Unmarshal([]byte("type: extauth"), &cfg))
+	require.NotNil(t, yaml.Unmarshal([]byte("type: http"), &cfg))
+	require.NotNil(t, yaml.Unmarshal([]byte("type: ldap"), &cfg))
+	require.NotNil(t, yaml.Unmarshal([]byte("type"), &cfg))
+}
+
+func TestNewProvider(t *testing.T) {
+	prv, err := NewProvider(&ProviderConfig{})
+	require.Nil(t, err)
+	require.IsType(t, &Storage{}, prv)
+
+	prv, err = NewProvider(&ProviderConfig{Type: HTTPProvider, URL: "http://127.0.0.1:8080/auth"})
+	require.Nil(t, err)
+	require.IsType(t, &HTTP{}, prv)
+
+	_, err = NewProvider(&ProviderConfig{Type: ExtAuthProvider, Program: "/not/a/program"})
+	require.NotNil(t, err)
+}
+
+func TestStorageProvider(t *testing.T) {
+	user := &model.User{Username: "mariana", Domain: "localhost"}
+	setUserPassword(user, "1234", true)
+	authTestSetup(user)
+	defer authTestTeardown()
+
+	prv := NewStorage()
+
+	ok, err := prv.Authenticate("mariana", "localhost", "1234")
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	ok, _ = prv.Authenticate("mariana", "localhost", "12345")
+	require.False(t, ok)
+	ok, _ = prv.Authenticate("mariana", "jackal.im", "1234")
+	require.False(t, ok)
+
+	ok, err = prv.UserExists("mariana", "localhost")
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	require.Nil(t, prv.SetPassword("mariana", "localhost", "abcd"))
+	ok, _ = prv.Authenticate("mariana", "localhost", "abcd")
+	require.True(t, ok)
+
+	// credentials remain migrated...
+	usr, err := prv.FetchUser("mariana", "localhost")
+	require.Nil(t, err)
+	require.Equal(t, 0, len(usr.Password))
+	require.True(t, usr.HasScramCredentials())
+
+	require.NotNil(t, prv.SetPassword("ortuman", "localhost", "abcd"))
+
+	storage.ActivateMockedError()
+	defer storage.DeactivateMockedError()
+	_, err = prv.Authenticate("mariana", "localhost", "abcd")
+	require.Equal(t, memstorage.ErrMockedError, err)
+	require.Equal(t, memstorage.ErrMockedError, prv.SetPassword("mariana", "localhost", "abcd"))
+}
+
+func TestAuthPlainProvider(t *testing.T) {
+	// user only known by provider...
+	testStm := authTestSetup(&model.User{Username: "ortuman", Domain: "localhost", Password: "1234"})
+	defer authTestTeardown()
+
+	prv := &fakeProvider{passwords: map[string]string{"mariana@localhost": "abcd"}}
+	authr := NewPlain(testStm, prv)
+
+	elem := xml.NewElementNamespace("auth", "urn:ietf:params:xml:ns:xmpp-sasl")
+	elem.SetAttribute("mechanism", "PLAIN")
+
+	elem.SetText(base64.StdEncoding.EncodeToString([]byte("\x00ortuman\x001234")))
+	require.Equal(t, ErrSASLNotAuthorized, authr.ProcessElement(elem))
+
+	authr.Reset()
+	elem.SetText(base64.StdEncoding.EncodeToString([]byte("\x00mariana\x00abcd")))
+	require.Nil(t, authr.ProcessElement(elem))
+	require.True(t, authr.Authenticated())
+	require.Equal(t, "mariana", authr.Username())
+	require.Equal(t, "success", testStm.FetchElement().Name())
+
+	// provisioned into storage...
+	exists, _ := storage.Instance().UserExists("mariana", "localhost")
+	require.True(t, exists)
+
+	// provider unavailable...
+	prv.unavailable = true
+	authr.Reset()
+	require.Equal(t, errFakeProvider, authr.ProcessElement(elem))
+}
+
+func TestScramProviderFallback(t *testing.T) {
+	testStm := authTestSetup(&model.User{Username: "ortuman", Domain: "localhost", Password: "1234"})
+	defer authTestTeardown()
+
+	auth := xml.NewElementNamespace("auth", saslNamespace)
+	auth.SetText(base64.StdEncoding.EncodeToString([]byte("n,,n=ortuman,r=bb769406-eaa4-4f38-a279-2b90e596f6dd")))
+
+	// provider can't supply credentials: fallback to storage
+	authr := NewScram(testStm, &fakeTransport{}, ScramSHA1, false, &fakeProvider{})
+	require.Nil(t, authr.ProcessElement(auth))
+	require.Equal(t, "challenge", testStm.FetchElement().Name())
+
+	// credentials supplied by provider
+	user := &model.User{Username: "ortuman", Domain: "localhost"}
+	setUserPassword(user, "abcd", true)
+	prv := &fakeCredentialsProvider{user: user}
+
+	authr = NewScram(testStm, &fakeTransport{}, ScramSHA1, false, prv)
+	require.Nil(t, authr.ProcessElement(auth))
+	require.Equal(t, "challenge", testStm.FetchElement().Name())
+	require.Equal(t, user.ScramSHA1.Salt, authr.salt)
+
+	prv.user = &model.User{Username: "mariana", Domain: "localhost"}
+	authr.Reset()
+	require.Equal(t, ErrSASLNotAuthorized, authr.ProcessElement(auth))
+}
@@ -83,25 +83,32 @@ type Scram struct {
 	tr            transport.Transport
 	tp            ScramType
 	usesCb        bool
+	provider      Provider
 	h             func() hash.Hash
 	hKeyLen       int
 	state         scramState
 	params        *scramParameters
 	user          *model.User
 	salt          []byte
+	iterations    int
+	storedKey     []byte
+	serverKey     []byte
 	srvNonce      string
 	firstMessage  string
 	authenticated bool
 }
 
 // NewScram returns a new scram authenticator instance.
-func NewScram(stm stream.C2S, tr transport.Transport, scramType ScramType, usesChannelBinding bool) *Scram {
+// User credentials are fetched from provider in case it's able
+// to supply them, falling back to storage otherwise.
+func NewScram(stm stream.C2S, tr transport.Transport, scramType ScramType, usesChannelBinding bool, provider Provider) *Scram {
 	s := &Scram{
-		stm:    stm,
-		tr:     tr,
-		tp:     scramType,
-		usesCb: usesChannelBinding,
-		state:  startScramState,
+		stm:      stm,
+		tr:       tr,
+		tp:       scramType,
+		usesCb:   usesChannelBinding,
+		provider: providerOrDefault(provider),
+		state:    startScramState,
 	}
 	if s.tp == ScramSHA1 {
 		s.h = sha1.New
@@ -177,6 +184,9 @@ func (s *Scram) Reset() {
 	s.params = nil
 	s.user = nil
 	s.salt = nil
+	s.iterations = 0
+	s.storedKey = nil
+	s.serverKey = nil
 	s.srvNonce = ""
 	s.firstMessage = ""
 }
@@ -195,19 +205,20 @@ func (s *Scram) handleStart(elem xml.XElement) error {
 	if len(username) == 0 || len(cNonce) == 0 {
 		return ErrSASLMalformedRequest
 	}
-	user, err := storage.Instance().FetchUser(username)
+	user, err := s.fetchUser(username)
 	if err != nil {
 		return err
 	}
-	if user == nil {
+	if user == nil || user.Disabled {
 		return ErrSASLNotAuthorized
 	}
 	s.user = user
-
+	if !s.loadCredentials() {
+		return ErrSASLNotAuthorized
+	}
 	s.srvNonce = cNonce + "-" + uuid.New()
-	s.salt = util.RandomBytes(32)
 	sb64 := base64.StdEncoding.EncodeToString(s.salt)
-	s.firstMessage = fmt.Sprintf("r=%s,s=%s,i=%d", s.srvNonce, sb64, iterationsCount)
+	s.firstMessage = fmt.Sprintf("r=%s,s=%s,i=%d", s.srvNonce, sb64, s.iterations)
 
 	respElem := xml.NewElementNamespace("challenge", saslNamespace)
 	respElem.SetText(base64.StdEncoding.EncodeToString([]byte(s.firstMessage)))
@@ -226,23 +237,26 @@ func (s *Scram) handleChallenged(elem xml.XElement) error {
 	initialMessage := s.params.String()
 	clientFinalMessageBare := fmt.Sprintf("c=%s,r=%s", c, s.srvNonce)
 
-	saltedPassword := s.pbkdf2([]byte(s.user.Password))
-	clientKey := s.hmac([]byte("Client Key"), saltedPassword)
-	storedKey := s.hash(clientKey)
+	proofPrefix := clientFinalMessageBare + ",p="
+	if !strings.HasPrefix(p, proofPrefix) {
+		return ErrSASLNotAuthorized
+	}
+	clientProof, err := base64.StdEncoding.DecodeString(p[len(proofPrefix):])
+	if err != nil || len(clientProof) != s.hKeyLen {
+		return ErrSASLNotAuthorized
+	}
 	authMessage := initialMessage + "," + s.firstMessage + "," + clientFinalMessageBare
-	clientSignature := s.hmac([]byte(authMessage), storedKey)
+	clientSignature := s.hmac([]byte(authMessage), s.storedKey)
 
-	clientProof := make([]byte, len(clientKey))
-	for i := 0; i < len(clientKey); i++ {
-		clientProof[i] = clientKey[i] ^ clientSignature[i]
+	// recover client key from proof and check it against stored key
+	clientKey := make([]byte, len(clientProof))
+	for i := 0; i < len(clientProof); i++ {
+		clientKey[i] = clientProof[i] ^ clientSignature[i]
 	}
-	serverKey := s.hmac([]byte("Server Key"), saltedPassword)
-	serverSignature := s.hmac([]byte(authMessage), serverKey)
-
-	clientFinalMessage := clientFinalMessageBare + ",p=" + base64.StdEncoding.EncodeToString(clientProof)
-	if clientFinalMessage != p {
+	if !hmac.Equal(s.hash(clientKey), s.storedKey) {
 		return ErrSASLNotAuthorized
 	}
+	serverSignature := s.hmac([]byte(authMessage), s.serverKey)
 	v := "v=" + base64.StdEncoding.EncodeToString(serverSignature)
 
 	respElem := xml.NewElementNamespace("success", saslNamespace)
@@ -253,6 +267,42 @@ func (s *Scram) handleChallenged(elem xml.XElement) error {
 	return nil
 }
 
+func (s *Scram) fetchUser(username string) (*model.User, error) {
+	if cp, ok := s.provider.(CredentialsProvider); ok {
+		return cp.FetchUser(username, s.stm.Domain())
+	}
+	// provider can't supply stored credentials
+	return storage.Instance().FetchUser(username, s.stm.Domain())
+}
+
+// loadCredentials sets up salted credentials used to authenticate
+// current user, deriving them from cleartext password in case
+// no SCRAM credentials were stored.
+func (s *Scram) loadCredentials() bool {
+	var c *model.ScramCredentials
+	if s.tp == ScramSHA1 {
+		c = s.user.ScramSHA1
+	} else {
+		c = s.user.ScramSHA256
+	}
+	if c != nil {
+		s.salt = c.Salt
+		s.iterations = c.Iterations
+		s.storedKey = c.StoredKey
+		s.serverKey = c.ServerKey
+		return true
+	}
+	if len(s.user.Password) == 0 {
+		return false
+	}
+	s.salt = util.RandomBytes(scramSaltLength)
+	s.iterations = iterationsCount
+	saltedPassword := s.pbkdf2([]byte(s.user.Password))
+	s.storedKey = scramStoredKey(saltedPassword, s.h)
+	s.serverKey = scramServerKey(saltedPassword, s.h)
+	return true
+}
+
 func (s *Scram) getElementPayload(elem xml.XElement) (string, error) {
 	if len(elem.Text()) == 0 {
 		return "", ErrSASLIncorrectEncoding
@@ -320,13 +370,11 @@ func (s *Scram) getCBindInputString() string {
 }
 
 func (s *Scram) pbkdf2(b []byte) []byte {
-	return pbkdf2.Key(b, s.salt, iterationsCount, s.hKeyLen, s.h)
+	return pbkdf2.Key(b, s.salt, s.iterations, s.hKeyLen, s.h)
 }
 
 func (s *Scram) hmac(b []byte, key []byte) []byte {
-	m := hmac.New(s.h, key)
-	m.Write(b)
-	return m.Sum(nil)
+	return scramHMAC(b, key, s.h)
 }
 
 func (s *Scram) hash(b []byte) []byte {
@@ -29,7 +29,8 @@ import (
 )
 
 type fakeTransport struct {
-	cbBytes []byte
+	cbBytes   []byte
+	peerCerts []*x509.Certificate
 }
 
 func (ft *fakeTransport) Read(p []byte) (n int, err error)        { return 0, nil }
@@ -42,7 +43,7 @@ func (ft *fakeTransport) EnableCompression(compress.Level)        { return }
 func (ft *fakeTransport) ChannelBindingBytes(transport.ChannelBindingMechanism) []byte {
 	return ft.cbBytes
 }
-func (ft *fakeTransport) PeerCertificates() []*x509.Certificate { return nil }
+func (ft *fakeTransport) PeerCertificates() []*x509.Certificate { return ft.peerCerts }
 
 type scramAuthTestCase struct {
 	id          int
@@ -195,35 +196,35 @@ var tt = []scramAuthTestCase{
 
 func TestScramMechanisms(t *testing.T) {
 	testTr := &fakeTransport{}
-	testStrm := authTestSetup(&model.User{Username: "ortuman", Password: "1234"})
+	testStrm := authTestSetup(&model.User{Username: "ortuman", Domain: "localhost", Password: "1234"})
 	defer authTestTeardown()
 
-	authr := NewScram(testStrm, testTr, ScramSHA1, false)
+	authr := NewScram(testStrm, testTr, ScramSHA1, false, nil)
 	require.Equal(t, authr.Mechanism(), "SCRAM-SHA-1")
 	require.False(t, authr.UsesChannelBinding())
 
-	authr2 := NewScram(testStrm, testTr, ScramSHA1, true)
+	authr2 := NewScram(testStrm, testTr, ScramSHA1, true, nil)
 	require.Equal(t, authr2.Mechanism(), "SCRAM-SHA-1-PLUS")
 	require.True(t, authr2.UsesChannelBinding())
 
-	authr3 := NewScram(testStrm, testTr, ScramSHA256, false)
+	authr3 := NewScram(testStrm, testTr, ScramSHA256, false, nil)
 	require.Equal(t, authr3.Mechanism(), "SCRAM-SHA-256")
 	require.False(t, authr3.UsesChannelBinding())
 
-	authr4 := NewScram(testStrm, testTr, ScramSHA256, true)
+	authr4 := NewScram(testStrm, testTr, ScramSHA256, true, nil)
 	require.Equal(t, authr4.Mechanism(), "SCRAM-SHA-256-PLUS")
 	require.True(t, authr4.UsesChannelBinding())
 
-	authr5 := NewScram(testStrm, testTr, ScramType(99), true)
+	authr5 := NewScram(testStrm, testTr, ScramType(99), true, nil)
 	require.Equal(t, authr5.Mechanism(), "")
 }
 
 func TestScramBadPayload(t *testing.T) {
 	testTr := &fakeTransport{}
-	testStrm := authTestSetup(&model.User{Username: "ortuman", Password: "1234"})
+	testStrm := authTestSetup(&model.User{Username: "ortuman", Domain: "localhost", Password: "1234"})
 	defer authTestTeardown()
 
-	authr := NewScram(testStrm, testTr, ScramSHA1, false)
+	authr := NewScram(testStrm, testTr, ScramSHA1, false, nil)
 
 	auth := xml.NewElementNamespace("auth", "urn:ietf:params:xml:ns:xmpp-sasl")
 	auth.SetAttribute("mechanism", authr.Mechanism())
@@ -239,7 +240,7 @@ func TestScramBadPayload(t *testing.T) {
 
 func TestScramSuccessTestCases(t *testing.T) {
 	for _, tc := range tt {
-		err := processScramTestCase(t, &tc)
+		err := processScramTestCase(t, &tc, &model.User{Username: "ortuman", Domain: "localhost", Password: "1234"})
 		if err != nil {
 			require.Equal(t, tc.expectedErr, err, fmt.Sprintf("TC identifier: %d", tc.id))
 			continue
@@ -247,15 +248,54 @@ func TestScramSuccessTestCases(t *testing.T) {
 	}
 }
 
-func processScramTestCase(t *testing.T, tc *scramAuthTestCase) error {
+func TestScramStoredCredentialsTestCases(t *testing.T) {
+	for _, tc := range tt {
+		user := &model.User{Username: "ortuman", Domain: "localhost"}
+		setUserPassword(user, "1234", true)
+
+		err := processScramTestCase(t, &tc, user)
+		if err != nil {
+			require.Equal(t, tc.expectedErr, err, fmt.Sprintf("TC identifier: %d", tc.id))
+			continue
+		}
+	}
+}
+
+func TestScramMissingCredentials(t *testing.T) {
+	user := &model.User{Username: "ortuman", Domain: "localhost", ScramSHA1: NewScramCredentials("1234", ScramSHA1)}
+	testStrm := authTestSetup(user)
+	defer authTestTeardown()
+
+	authr := NewScram(testStrm, &fakeTransport{}, ScramSHA256, false, nil)
+
+	auth := xml.NewElementNamespace("auth", saslNamespace)
+	auth.SetAttribute("mechanism", authr.Mechanism())
+	auth.SetText(base64.StdEncoding.EncodeToString([]byte("n,,n=ortuman,r=bb769406-eaa4-4f38-a279-2b90e596f6dd")))
+	require.Equal(t, ErrSASLNotAuthorized, authr.ProcessElement(auth))
+}
+
+func TestScramDisabledUser(t *testing.T) {
+	user := &model.User{Username: "ortuman", Domain: "localhost", ScramSHA1: NewScramCredentials("1234", ScramSHA1), Disabled: true}
+	testStrm := authTestSetup(user)
+	defer authTestTeardown()
+
+	authr := NewScram(testStrm, &fakeTransport{}, ScramSHA1, false, nil)
+
+	auth := xml.NewElementNamespace("auth", saslNamespace)
+	auth.SetAttribute("mechanism", authr.Mechanism())
+	auth.SetText(base64.StdEncoding.EncodeToString([]byte("n,,n=ortuman,r=bb769406-eaa4-4f38-a279-2b90e596f6dd")))
+	require.Equal(t, ErrSASLNotAuthorized, authr.ProcessElement(auth))
+}
+
+func processScramTestCase(t *testing.T, tc *scramAuthTestCase, user *model.User) error {
 	tr := &fakeTransport{}
 	if tc.usesCb {
 		tr.cbBytes = tc.cbBytes
 	}
-	testStrm := authTestSetup(&model.User{Username: "ortuman", Password: "1234"})
+	testStrm := authTestSetup(user)
 	defer authTestTeardown()
 
-	authr := NewScram(testStrm, tr, tc.scramType, tc.usesCb)
+	authr := NewScram(testStrm, tr, tc.scramType, tc.usesCb, nil)
 
 	auth := xml.NewElementNamespace("auth", saslNamespace)
 	auth.SetAttribute("mechanism", authr.Mechanism())
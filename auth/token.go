@@ -0,0 +1,150 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xml"
+)
+
+const tokenSize = 32
+
+// Token represents a X-TOKEN authenticator.
+// Users authenticate by means of a previously issued device token
+// instead of their password.
+type Token struct {
+	stm           stream.C2S
+	username      string
+	authenticated bool
+}
+
+// NewToken returns a new token authenticator instance.
+func NewToken(stm stream.C2S) *Token {
+	return &Token{stm: stm}
+}
+
+// Mechanism returns authenticator mechanism name.
+func (t *Token) Mechanism() string {
+	return "X-TOKEN"
+}
+
+// Username returns authenticated username in case
+// authentication process has been completed.
+func (t *Token) Username() string {
+	return t.username
+}
+
+// Authenticated returns whether or not user has been authenticated.
+func (t *Token) Authenticated() bool {
+	return t.authenticated
+}
+
+// UsesChannelBinding returns whether or not token authenticator
+// requires channel binding bytes.
+func (t *Token) UsesChannelBinding() bool {
+	return false
+}
+
+// ProcessElement process an incoming authenticator element.
+func (t *Token) ProcessElement(elem xml.XElement) error {
+	if t.authenticated {
+		return nil
+	}
+	if len(elem.Text()) == 0 {
+		return ErrSASLMalformedRequest
+	}
+	b, err := base64.StdEncoding.DecodeString(elem.Text())
+	if err != nil {
+		return ErrSASLIncorrectEncoding
+	}
+	s := bytes.Split(b, []byte{0})
+	if len(s) != 3 {
+		return ErrSASLIncorrectEncoding
+	}
+	username := string(s[1])
+	token := string(s[2])
+
+	ok, err := verifyToken(username, t.stm.Domain(), token)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrSASLNotAuthorized
+	}
+	t.username = username
+	t.authenticated = true
+
+	t.stm.SendElement(xml.NewElementNamespace("success", saslNamespace))
+	return nil
+}
+
+// Reset resets token authenticator internal state.
+func (t *Token) Reset() {
+	t.username = ""
+	t.authenticated = false
+}
+
+// IssueToken generates a new login token for a user device, replacing
+// any other one previously issued to the same device.
+// Only token hash is kept in storage, so the returned token
+// can't be retrieved again later.
+func IssueToken(username, domain, device string, ttl time.Duration) (string, time.Time, error) {
+	b := make([]byte, tokenSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	expiresAt := time.Now().Add(ttl).UTC()
+
+	err := storage.Instance().InsertOrUpdateAuthToken(&model.AuthToken{
+		Username:  username,
+		Domain:    domain,
+		Device:    device,
+		Hash:      tokenHash(token),
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+func verifyToken(username, domain, token string) (bool, error) {
+	user, err := storage.Instance().FetchUser(username, domain)
+	if err != nil {
+		return false, err
+	}
+	if user == nil || user.Disabled {
+		return false, nil
+	}
+	tokens, err := storage.Instance().FetchAuthTokens(username, domain)
+	if err != nil {
+		return false, err
+	}
+	hash := tokenHash(token)
+	for _, t := range tokens {
+		if !t.IsExpired() && hmac.Equal([]byte(t.Hash), []byte(hash)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func tokenHash(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
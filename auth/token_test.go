@@ -0,0 +1,120 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package auth
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/storage/memstorage"
+	"github.com/ortuman/jackal/xml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthTokenIssue(t *testing.T) {
+	authTestSetup(&model.User{Username: "mariana", Domain: "localhost", Password: "1234"})
+	defer authTestTeardown()
+
+	token, expiresAt, err := IssueToken("mariana", "localhost", "balcony", time.Hour)
+	require.Nil(t, err)
+	require.True(t, len(token) > 0)
+	require.True(t, expiresAt.After(time.Now()))
+
+	tokens, _ := storage.Instance().FetchAuthTokens("mariana", "localhost")
+	require.Equal(t, 1, len(tokens))
+	require.Equal(t, "balcony", tokens[0].Device)
+	require.Equal(t, tokenHash(token), tokens[0].Hash)
+	require.NotEqual(t, token, tokens[0].Hash)
+
+	// re-issuing replaces device token
+	token2, _, err := IssueToken("mariana", "localhost", "balcony", time.Hour)
+	require.Nil(t, err)
+	require.NotEqual(t, token, token2)
+
+	tokens, _ = storage.Instance().FetchAuthTokens("mariana", "localhost")
+	require.Equal(t, 1, len(tokens))
+	require.Equal(t, tokenHash(token2), tokens[0].Hash)
+
+	storage.ActivateMockedError()
+	defer storage.DeactivateMockedError()
+	_, _, err = IssueToken("mariana", "localhost", "balcony", time.Hour)
+	require.Equal(t, memstorage.ErrMockedError, err)
+}
+
+func TestAuthTokenAuthentication(t *testing.T) {
+	user := &model.User{Username: "mariana", Domain: "localhost"}
+	setUserPassword(user, "1234", true)
+	testStm := authTestSetup(user)
+	defer authTestTeardown()
+
+	authr := NewToken(testStm)
+	require.Equal(t, "X-TOKEN", authr.Mechanism())
+	require.False(t, authr.UsesChannelBinding())
+
+	token, _, _ := IssueToken("mariana", "localhost", "balcony", time.Hour)
+
+	elem := xml.NewElementNamespace("auth", saslNamespace)
+	elem.SetAttribute("mechanism", "X-TOKEN")
+
+	// malformed request
+	require.Equal(t, ErrSASLMalformedRequest, authr.ProcessElement(elem))
+
+	// incorrect encoding
+	elem.SetText("bad_encoding")
+	require.Equal(t, ErrSASLIncorrectEncoding, authr.ProcessElement(elem))
+	elem.SetText(base64.StdEncoding.EncodeToString([]byte("mariana\x00" + token)))
+	require.Equal(t, ErrSASLIncorrectEncoding, authr.ProcessElement(elem))
+
+	// invalid token
+	elem.SetText(base64.StdEncoding.EncodeToString([]byte("\x00mariana\x001234")))
+	require.Equal(t, ErrSASLNotAuthorized, authr.ProcessElement(elem))
+
+	// storage error
+	elem.SetText(base64.StdEncoding.EncodeToString([]byte("\x00mariana\x00" + token)))
+	storage.ActivateMockedError()
+	require.Equal(t, memstorage.ErrMockedError, authr.ProcessElement(elem))
+	storage.DeactivateMockedError()
+
+	// valid token
+	require.Nil(t, authr.ProcessElement(elem))
+	require.True(t, authr.Authenticated())
+	require.Equal(t, "mariana", authr.Username())
+	require.Equal(t, "success", testStm.FetchElement().Name())
+
+	// already authenticated
+	require.Nil(t, authr.ProcessElement(elem))
+
+	// revoked token
+	authr.Reset()
+	require.False(t, authr.Authenticated())
+	require.Equal(t, "", authr.Username())
+
+	storage.Instance().DeleteAuthTokens("mariana", "localhost", "balcony")
+	require.Equal(t, ErrSASLNotAuthorized, authr.ProcessElement(elem))
+
+	// expired token
+	token, _, _ = IssueToken("mariana", "localhost", "balcony", -time.Second)
+	elem.SetText(base64.StdEncoding.EncodeToString([]byte("\x00mariana\x00" + token)))
+	require.Equal(t, ErrSASLNotAuthorized, authr.ProcessElement(elem))
+
+	// disabled user
+	token, _, _ = IssueToken("mariana", "localhost", "balcony", time.Hour)
+	elem.SetText(base64.StdEncoding.EncodeToString([]byte("\x00mariana\x00" + token)))
+	require.Nil(t, authr.ProcessElement(elem))
+	testStm.FetchElement()
+
+	authr.Reset()
+	user.Disabled = true
+	storage.Instance().InsertOrUpdateUser(user)
+	require.Equal(t, ErrSASLNotAuthorized, authr.ProcessElement(elem))
+
+	// unknown user
+	elem.SetText(base64.StdEncoding.EncodeToString([]byte("\x00ortuman\x00" + token)))
+	require.Equal(t, ErrSASLNotAuthorized, authr.ProcessElement(elem))
+}
@@ -6,20 +6,27 @@
 package c2s
 
 import (
+	"crypto/x509"
 	"fmt"
 	"strings"
 	"time"
 
-	"github.com/ortuman/jackal/module"
+	"github.com/ortuman/jackal/auth"
 	"github.com/ortuman/jackal/transport"
 	"github.com/ortuman/jackal/transport/compress"
+	"github.com/ortuman/jackal/util"
 )
 
 const (
 	defaultTransportConnectTimeout = time.Duration(5) * time.Second
 	defaultTransportMaxStanzaSize  = 32768
 	defaultTransportPort           = 5222
+	defaultDirectTLSTransportPort  = 5223
 	defaultTransportKeepAlive      = time.Duration(120) * time.Second
+	defaultResumeTimeout           = time.Duration(60) * time.Second
+	defaultBOSHWait                = time.Duration(60) * time.Second
+	defaultBOSHHold                = 1
+	defaultBOSHPolling             = time.Duration(2) * time.Second
 )
 
 // ResourceConflictPolicy represents a resource conflict policy.
@@ -73,14 +80,24 @@ type TransportConfig struct {
 	Port        int
 	KeepAlive   time.Duration
 	URLPath     string
+	DirectTLS   bool
+	BOSH        transport.BOSHConfig
+}
+
+type boshProxyType struct {
+	Wait    int `yaml:"wait"`
+	Hold    int `yaml:"hold"`
+	Polling int `yaml:"polling"`
 }
 
 type transportProxyType struct {
-	Type        string `yaml:"type"`
-	BindAddress string `yaml:"bind_addr"`
-	Port        int    `yaml:"port"`
-	KeepAlive   int    `yaml:"keep_alive"`
-	URLPath     string `yaml:"url_path"`
+	Type        string        `yaml:"type"`
+	BindAddress string        `yaml:"bind_addr"`
+	Port        int           `yaml:"port"`
+	KeepAlive   int           `yaml:"keep_alive"`
+	URLPath     string        `yaml:"url_path"`
+	DirectTLS   bool          `yaml:"direct_tls"`
+	BOSH        boshProxyType `yaml:"bosh"`
 }
 
 // UnmarshalYAML satisfies Unmarshaler interface.
@@ -97,21 +114,85 @@ func (t *TransportConfig) UnmarshalYAML(unmarshal func(interface{}) error) error
 	case "websocket":
 		t.Type = transport.WebSocket
 
+	case "bosh":
+		t.Type = transport.BOSH
+
 	default:
 		return fmt.Errorf("c2s.TransportConfig: unrecognized transport type: %s", p.Type)
 	}
+	if p.DirectTLS && t.Type != transport.Socket {
+		return fmt.Errorf("c2s.TransportConfig: direct_tls not supported by %v transport", t.Type)
+	}
 	t.BindAddress = p.BindAddress
 	t.Port = p.Port
 	t.URLPath = p.URLPath
+	t.DirectTLS = p.DirectTLS
 
 	// assign transport's defaults
 	if t.Port == 0 {
-		t.Port = defaultTransportPort
+		if t.DirectTLS {
+			t.Port = defaultDirectTLSTransportPort
+		} else {
+			t.Port = defaultTransportPort
+		}
 	}
 	t.KeepAlive = time.Duration(p.KeepAlive) * time.Second
 	if t.KeepAlive == 0 {
 		t.KeepAlive = defaultTransportKeepAlive
 	}
+	if t.Type == transport.BOSH {
+		t.BOSH.Wait = time.Duration(p.BOSH.Wait) * time.Second
+		if t.BOSH.Wait == 0 {
+			t.BOSH.Wait = defaultBOSHWait
+		}
+		t.BOSH.Hold = p.BOSH.Hold
+		if t.BOSH.Hold == 0 {
+			t.BOSH.Hold = defaultBOSHHold
+		}
+		t.BOSH.Polling = time.Duration(p.BOSH.Polling) * time.Second
+		if t.BOSH.Polling == 0 {
+			t.BOSH.Polling = defaultBOSHPolling
+		}
+		t.BOSH.Inactivity = t.KeepAlive // session inactivity period
+	}
+	return nil
+}
+
+// ExternalConfig represents SASL EXTERNAL authentication configuration.
+type ExternalConfig struct {
+	ClientCAs     *x509.CertPool
+	Mapping       auth.CertificateMapping
+	AutoProvision bool
+}
+
+type externalProxyType struct {
+	ClientCAFile  string `yaml:"client_ca_path"`
+	Mapping       string `yaml:"mapping"`
+	AutoProvision bool   `yaml:"auto_provision"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (e *ExternalConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := externalProxyType{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	switch p.Mapping {
+	case "", "xmpp_addr":
+		e.Mapping = auth.XMPPAddrMapping
+	case "email":
+		e.Mapping = auth.EmailMapping
+	default:
+		return fmt.Errorf("c2s.ExternalConfig: unrecognized certificate mapping: %s", p.Mapping)
+	}
+	if len(p.ClientCAFile) > 0 {
+		clientCAs, err := util.LoadCertPool(p.ClientCAFile)
+		if err != nil {
+			return err
+		}
+		e.ClientCAs = clientCAs
+	}
+	e.AutoProvision = p.AutoProvision
 	return nil
 }
 
@@ -125,10 +206,12 @@ type TLSConfig struct {
 type Config struct {
 	ID               string
 	ConnectTimeout   time.Duration
+	ResumeTimeout    time.Duration
 	MaxStanzaSize    int
 	ResourceConflict ResourceConflictPolicy
 	Transport        TransportConfig
 	SASL             []string
+	External         ExternalConfig
 	Compression      CompressConfig
 }
 
@@ -137,10 +220,12 @@ type configProxy struct {
 	Domain           string          `yaml:"domain"`
 	TLS              TLSConfig       `yaml:"tls"`
 	ConnectTimeout   int             `yaml:"connect_timeout"`
+	ResumeTimeout    int             `yaml:"resume_timeout"`
 	MaxStanzaSize    int             `yaml:"max_stanza_size"`
 	ResourceConflict string          `yaml:"resource_conflict"`
 	Transport        TransportConfig `yaml:"transport"`
 	SASL             []string        `yaml:"sasl"`
+	External         ExternalConfig  `yaml:"external"`
 	Compression      CompressConfig  `yaml:"compression"`
 }
 
@@ -155,6 +240,10 @@ func (cfg *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	if cfg.ConnectTimeout == 0 {
 		cfg.ConnectTimeout = defaultTransportConnectTimeout
 	}
+	cfg.ResumeTimeout = time.Duration(p.ResumeTimeout) * time.Second
+	if cfg.ResumeTimeout == 0 {
+		cfg.ResumeTimeout = defaultResumeTimeout
+	}
 	cfg.MaxStanzaSize = p.MaxStanzaSize
 	if cfg.MaxStanzaSize == 0 {
 		cfg.MaxStanzaSize = defaultTransportMaxStanzaSize
@@ -175,7 +264,12 @@ func (cfg *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	// validate SASL mechanisms
 	for _, sasl := range p.SASL {
 		switch sasl {
-		case "plain", "digest_md5", "scram_sha_1", "scram_sha_256":
+		case "plain", "digest_md5", "scram_sha_1", "scram_sha_256", "x_token":
+			continue
+		case "external":
+			if p.External.ClientCAs == nil {
+				return fmt.Errorf("c2s.Config: external SASL mechanism requires a client CA")
+			}
 			continue
 		default:
 			return fmt.Errorf("c2s.Config: unrecognized SASL mechanism: %s", sasl)
@@ -183,16 +277,19 @@ func (cfg *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	}
 	cfg.Transport = p.Transport
 	cfg.SASL = p.SASL
+	cfg.External = p.External
 	cfg.Compression = p.Compression
 	return nil
 }
 
 type streamConfig struct {
 	transport        transport.Transport
+	directTLS        bool
 	connectTimeout   time.Duration
+	resumeTimeout    time.Duration
 	maxStanzaSize    int
 	resourceConflict ResourceConflictPolicy
 	sasl             []string
+	external         ExternalConfig
 	compression      CompressConfig
-	modules          *module.Config
 }
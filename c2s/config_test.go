@@ -10,6 +10,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ortuman/jackal/auth"
 	"github.com/ortuman/jackal/transport"
 	"github.com/ortuman/jackal/transport/compress"
 	"github.com/stretchr/testify/require"
@@ -54,6 +55,26 @@ func TestTransportConfig(t *testing.T) {
 	require.Equal(t, transport.WebSocket, s.Type)
 	require.Equal(t, 5222, s.Port)
 	require.Equal(t, time.Second*time.Duration(120), s.KeepAlive)
+
+	err = yaml.Unmarshal([]byte("{type: bosh, url_path: /http-bind, keep_alive: 30, bosh: {wait: 45}}"), &s)
+	require.Nil(t, err)
+
+	require.Equal(t, transport.BOSH, s.Type)
+	require.Equal(t, time.Second*time.Duration(45), s.BOSH.Wait)
+	require.Equal(t, 1, s.BOSH.Hold)
+	require.Equal(t, time.Second*time.Duration(2), s.BOSH.Polling)
+	require.Equal(t, time.Second*time.Duration(30), s.BOSH.Inactivity)
+
+	err = yaml.Unmarshal([]byte("{type: comet}"), &s)
+	require.NotNil(t, err)
+
+	err = yaml.Unmarshal([]byte("{type: socket, direct_tls: true}"), &s)
+	require.Nil(t, err)
+	require.True(t, s.DirectTLS)
+	require.Equal(t, 5223, s.Port)
+
+	err = yaml.Unmarshal([]byte("{type: websocket, direct_tls: true}"), &s)
+	require.NotNil(t, err)
 }
 
 func TestConfig(t *testing.T) {
@@ -67,6 +88,12 @@ func TestConfig(t *testing.T) {
 
 	err = yaml.Unmarshal([]byte("{connect_timeout: 5, resource_conflict: override}"), &s)
 	require.Nil(t, err)
+	require.Equal(t, defaultResumeTimeout, s.ResumeTimeout)
+
+	// stream resumption timeout...
+	err = yaml.Unmarshal([]byte("{connect_timeout: 5, resume_timeout: 120}"), &s)
+	require.Nil(t, err)
+	require.Equal(t, time.Duration(120)*time.Second, s.ResumeTimeout)
 
 	// invalid resource conflict option...
 	err = yaml.Unmarshal([]byte("{connect_timeout: 5, resource_conflict: invalid}"), &s)
@@ -76,17 +103,50 @@ func TestConfig(t *testing.T) {
 	authCfg := `
 connect_timeout: 5
 resource_conflict: reject
-sasl: [plain, digest_md5, scram_sha_1, scram_sha_256]
+sasl: [plain, digest_md5, scram_sha_1, scram_sha_256, x_token]
 `
 	err = yaml.Unmarshal([]byte(authCfg), &s)
 	require.Nil(t, err)
-	require.Equal(t, 4, len(s.SASL))
+	require.Equal(t, 5, len(s.SASL))
 
 	// invalid auth mechanism...
 	err = yaml.Unmarshal([]byte("{id: default, type: c2s, sasl: [invalid]}"), &s)
 	require.NotNil(t, err)
 
+	// external auth mechanism...
+	externalCfg := `
+sasl: [external]
+external:
+  client_ca_path: ../testdata/cert/test.ca.crt
+  mapping: email
+  auto_provision: true
+`
+	err = yaml.Unmarshal([]byte(externalCfg), &s)
+	require.Nil(t, err)
+	require.NotNil(t, s.External.ClientCAs)
+	require.Equal(t, auth.EmailMapping, s.External.Mapping)
+	require.True(t, s.External.AutoProvision)
+
+	// external auth mechanism without client CA...
+	err = yaml.Unmarshal([]byte("{sasl: [external]}"), &s)
+	require.NotNil(t, err)
+
 	// invalid yaml
 	err = yaml.Unmarshal([]byte("type"), &s)
 	require.NotNil(t, err)
 }
+
+func TestExternalConfig(t *testing.T) {
+	e := ExternalConfig{}
+	err := yaml.Unmarshal([]byte("{}"), &e)
+	require.Nil(t, err)
+	require.Nil(t, e.ClientCAs)
+	require.Equal(t, auth.XMPPAddrMapping, e.Mapping)
+	require.False(t, e.AutoProvision)
+
+	err = yaml.Unmarshal([]byte("{mapping: common_name}"), &e)
+	require.NotNil(t, err)
+
+	err = yaml.Unmarshal([]byte("{client_ca_path: ../testdata/cert/not_a_file.crt}"), &e)
+	require.NotNil(t, err)
+}
@@ -16,6 +16,14 @@ func (m *inMap) set(stm stream.C2S) {
 	log.Infof("registered c2s stream... (id: %s)", stm.ID())
 }
 
+func (m *inMap) get(id string) stream.C2S {
+	v, ok := m.m.Load(id)
+	if !ok {
+		return nil
+	}
+	return v.(stream.C2S)
+}
+
 func (m *inMap) delete(stm stream.C2S) {
 	m.m.Delete(stm.ID())
 	log.Infof("unregistered c2s stream... (id: %s)", stm.ID())
@@ -0,0 +1,95 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package c2s
+
+import (
+	"github.com/ortuman/jackal/xml"
+)
+
+const (
+	csiNamespace        = "urn:xmpp:csi:0"
+	chatStatesNamespace = "http://jabber.org/protocol/chatstates"
+)
+
+// maximum number of buffered stanzas after which
+// an inactive stream queue gets flushed.
+const csiMaxQueueSize = 256
+
+type clientState struct {
+	inactive bool
+	queue    []xml.XElement
+}
+
+func (s *inStream) handleClientState(elem xml.XElement) {
+	switch elem.Name() {
+	case "active":
+		s.csi.inactive = false
+		s.flushClientStateQueue()
+	case "inactive":
+		s.csi.inactive = true
+	}
+}
+
+// sendElement writes an outgoing element, buffering non-urgent
+// stanzas while the client has indicated it's inactive.
+func (s *inStream) sendElement(elem xml.XElement) {
+	if s.csi.inactive && s.bufferElement(elem) {
+		return
+	}
+	s.flushClientStateQueue()
+	s.writeElement(elem)
+}
+
+// bufferElement enqueues an availability presence or a chat state notification,
+// superseding any previously buffered one with the same origin, kind and type.
+// It returns false if the element must be delivered right away.
+func (s *inStream) bufferElement(elem xml.XElement) bool {
+	switch stanza := elem.(type) {
+	case *xml.Presence:
+		if !stanza.IsAvailable() && !stanza.IsUnavailable() {
+			return false // subscription and error presences are never delayed
+		}
+	case *xml.Message:
+		if !isChatStateNotification(stanza) {
+			return false
+		}
+	default:
+		return false
+	}
+	for i, queued := range s.csi.queue {
+		if queued.Name() == elem.Name() && queued.From() == elem.From() && queued.Type() == elem.Type() {
+			s.csi.queue = append(s.csi.queue[:i], s.csi.queue[i+1:]...)
+			break
+		}
+	}
+	s.csi.queue = append(s.csi.queue, elem)
+	if len(s.csi.queue) >= csiMaxQueueSize {
+		s.flushClientStateQueue()
+	}
+	return true
+}
+
+func (s *inStream) flushClientStateQueue() {
+	queue := s.csi.queue
+	s.csi.queue = nil
+	for _, elem := range queue {
+		s.writeElement(elem)
+	}
+}
+
+// isChatStateNotification returns whether or not a message
+// only carries a chat state notification (XEP-0085).
+func isChatStateNotification(message *xml.Message) bool {
+	if message.IsMessageWithBody() || message.Elements().Child("subject") != nil {
+		return false
+	}
+	for _, elem := range message.Elements().All() {
+		if elem.Namespace() == chatStatesNamespace {
+			return true
+		}
+	}
+	return false
+}
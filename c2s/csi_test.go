@@ -0,0 +1,186 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package c2s
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/module"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStream_CSIFeature(t *testing.T) {
+	host.Initialize([]host.Config{tUtilHostConfig(t, "localhost")})
+	router.Initialize(&router.Config{})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	module.Initialize(tUtilModulesConfig())
+	defer func() {
+		module.Shutdown()
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Domain: "localhost", Password: "pencil"})
+
+	stm, _ := tUtilStreamSMInit("csi:1", t)
+	defer stm.Disconnect(nil)
+
+	ch := make(chan []xml.XElement, 1)
+	stm.actorCh <- func() { ch <- stm.authenticatedFeatures() }
+
+	var found bool
+	for _, feature := range <-ch {
+		if feature.Name() == "csi" && feature.Namespace() == csiNamespace {
+			found = true
+		}
+	}
+	require.True(t, found)
+}
+
+func TestStream_CSIBuffering(t *testing.T) {
+	host.Initialize([]host.Config{tUtilHostConfig(t, "localhost")})
+	router.Initialize(&router.Config{})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	module.Initialize(tUtilModulesConfig())
+	defer func() {
+		module.Shutdown()
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Domain: "localhost", Password: "pencil"})
+
+	stm, conn := tUtilStreamSMInit("csi:2", t)
+	defer stm.Disconnect(nil)
+
+	conn.inboundWrite([]byte(`<inactive xmlns="urn:xmpp:csi:0"/>`))
+	time.Sleep(time.Millisecond * 100)
+
+	romeo, _ := jid.New("romeo", "localhost", "orchard", true)
+	juliet, _ := jid.New("juliet", "localhost", "balcony", true)
+
+	stm.SendElement(xml.NewPresence(romeo, stm.JID(), xml.AvailableType))
+	stm.SendElement(tUtilCSIChatState(romeo, stm.JID(), "composing"))
+	stm.SendElement(xml.NewPresence(juliet, stm.JID(), xml.AvailableType))
+	stm.SendElement(xml.NewPresence(romeo, stm.JID(), xml.UnavailableType))
+	stm.SendElement(tUtilCSIChatState(romeo, stm.JID(), "paused"))
+	require.Equal(t, 4, tUtilStreamCSIQueueLen(stm))
+
+	// important message flushes the queue
+	stm.SendElement(tUtilSMMessage(stm.JID()))
+
+	elem := conn.outboundRead()
+	require.Equal(t, "presence", elem.Name())
+	require.Equal(t, romeo.String(), elem.From())
+	require.Equal(t, xml.AvailableType, elem.Type())
+
+	elem = conn.outboundRead()
+	require.Equal(t, "presence", elem.Name())
+	require.Equal(t, juliet.String(), elem.From())
+
+	elem = conn.outboundRead()
+	require.Equal(t, "presence", elem.Name())
+	require.Equal(t, romeo.String(), elem.From())
+	require.Equal(t, xml.UnavailableType, elem.Type())
+
+	elem = conn.outboundRead()
+	require.Equal(t, "message", elem.Name())
+	require.NotNil(t, elem.Elements().ChildNamespace("paused", chatStatesNamespace))
+
+	elem = conn.outboundRead()
+	require.Equal(t, "message", elem.Name())
+	require.NotNil(t, elem.Elements().Child("body"))
+	require.Equal(t, 0, tUtilStreamCSIQueueLen(stm))
+
+	// becoming active flushes the queue
+	stm.SendElement(xml.NewPresence(juliet, stm.JID(), xml.UnavailableType))
+	require.Equal(t, 1, tUtilStreamCSIQueueLen(stm))
+
+	conn.inboundWrite([]byte(`<active xmlns="urn:xmpp:csi:0"/>`))
+	elem = conn.outboundRead()
+	require.Equal(t, "presence", elem.Name())
+	require.Equal(t, juliet.String(), elem.From())
+
+	stm.SendElement(xml.NewPresence(romeo, stm.JID(), xml.AvailableType))
+	elem = conn.outboundRead()
+	require.Equal(t, "presence", elem.Name())
+	require.Equal(t, 0, tUtilStreamCSIQueueLen(stm))
+}
+
+func TestStream_CSISubscriptionPresence(t *testing.T) {
+	host.Initialize([]host.Config{tUtilHostConfig(t, "localhost")})
+	router.Initialize(&router.Config{})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	module.Initialize(tUtilModulesConfig())
+	defer func() {
+		module.Shutdown()
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Domain: "localhost", Password: "pencil"})
+
+	stm, conn := tUtilStreamSMInit("csi:3", t)
+
+	conn.inboundWrite([]byte(`<enable xmlns="urn:xmpp:sm:3" resume="true"/>`))
+	_ = conn.outboundRead()
+
+	conn.inboundWrite([]byte(`<inactive xmlns="urn:xmpp:csi:0"/>`))
+	time.Sleep(time.Millisecond * 100)
+
+	romeo, _ := jid.New("romeo", "localhost", "", true)
+
+	// subscription requests are never superseded nor delayed
+	stm.SendElement(xml.NewPresence(romeo, stm.JID(), xml.AvailableType))
+	stm.SendElement(xml.NewPresence(romeo, stm.JID(), xml.SubscribeType))
+
+	elem := conn.outboundRead()
+	require.Equal(t, "presence", elem.Name())
+	require.Equal(t, xml.AvailableType, elem.Type())
+
+	elem = conn.outboundRead()
+	require.Equal(t, "presence", elem.Name())
+	require.Equal(t, xml.SubscribeType, elem.Type())
+	require.Equal(t, 0, tUtilStreamCSIQueueLen(stm))
+
+	// buffered stanzas are kept as unacknowledged before hibernating
+	stm.SendElement(xml.NewPresence(romeo, stm.JID(), xml.UnavailableType))
+	require.Equal(t, 1, tUtilStreamCSIQueueLen(stm))
+	require.Equal(t, 2, tUtilStreamUnackedCount(stm))
+
+	conn.Close()
+	time.Sleep(time.Millisecond * 100)
+	require.Equal(t, hibernated, stm.getState())
+	require.Equal(t, 0, tUtilStreamCSIQueueLen(stm))
+	require.Equal(t, 3, tUtilStreamUnackedCount(stm))
+
+	stm.Disconnect(nil)
+}
+
+func tUtilCSIChatState(from, to *jid.JID, state string) *xml.Message {
+	msg := xml.NewMessageType(uuid.New(), xml.ChatType)
+	msg.SetFromJID(from)
+	msg.SetToJID(to)
+	msg.AppendElement(xml.NewElementNamespace(state, chatStatesNamespace))
+	return msg
+}
+
+func tUtilStreamCSIQueueLen(stm *inStream) int {
+	ch := make(chan int, 1)
+	stm.actorCh <- func() { ch <- len(stm.csi.queue) }
+	return <-ch
+}
@@ -11,20 +11,12 @@ import (
 	"time"
 
 	"github.com/ortuman/jackal/auth"
+	"github.com/ortuman/jackal/component"
 	"github.com/ortuman/jackal/errors"
 	"github.com/ortuman/jackal/host"
 	"github.com/ortuman/jackal/log"
 	"github.com/ortuman/jackal/module"
 	"github.com/ortuman/jackal/module/offline"
-	"github.com/ortuman/jackal/module/roster"
-	"github.com/ortuman/jackal/module/xep0012"
-	"github.com/ortuman/jackal/module/xep0030"
-	"github.com/ortuman/jackal/module/xep0049"
-	"github.com/ortuman/jackal/module/xep0054"
-	"github.com/ortuman/jackal/module/xep0077"
-	"github.com/ortuman/jackal/module/xep0092"
-	"github.com/ortuman/jackal/module/xep0191"
-	"github.com/ortuman/jackal/module/xep0199"
 	"github.com/ortuman/jackal/router"
 	"github.com/ortuman/jackal/session"
 	"github.com/ortuman/jackal/stream"
@@ -41,37 +33,22 @@ const (
 	authenticating
 	authenticated
 	sessionStarted
+	hibernated
 	disconnected
 )
 
 // stream context keys
 const (
-	usernameCtxKey         = "stream:username"
-	domainCtxKey           = "stream:domain"
-	resourceCtxKey         = "stream:resource"
-	jidCtxKey              = "stream:jid"
-	securedCtxKey          = "stream:secured"
-	authenticatedCtxKey    = "stream:authenticated"
-	compressedCtxKey       = "stream:compressed"
-	presenceCtxKey         = "stream:presence"
-	offlineDeliveredCtxKey = "stream:offlineDelivered"
+	usernameCtxKey      = "stream:username"
+	domainCtxKey        = "stream:domain"
+	resourceCtxKey      = "stream:resource"
+	jidCtxKey           = "stream:jid"
+	securedCtxKey       = "stream:secured"
+	authenticatedCtxKey = "stream:authenticated"
+	compressedCtxKey    = "stream:compressed"
+	presenceCtxKey      = "stream:presence"
 )
 
-type modules struct {
-	roster       *roster.Roster
-	offline      *offline.Offline
-	lastActivity *xep0012.LastActivity
-	discoInfo    *xep0030.DiscoInfo
-	private      *xep0049.Private
-	vCard        *xep0054.VCard
-	register     *xep0077.Register
-	version      *xep0092.Version
-	blockingCmd  *xep0191.BlockingCommand
-	ping         *xep0199.Ping
-	iqHandlers   []module.IQHandler
-	all          []module.Module
-}
-
 type inStream struct {
 	cfg            *streamConfig
 	sess           *session.Session
@@ -81,7 +58,8 @@ type inStream struct {
 	ctx            stream.Context
 	authenticators []auth.Authenticator
 	activeAuth     auth.Authenticator
-	mods           modules
+	sm             streamManagement
+	csi            clientState
 	actorCh        chan func()
 	doneCh         chan<- struct{}
 }
@@ -97,8 +75,11 @@ func newStream(id string, cfg *streamConfig) stream.C2S {
 	}
 	inContainer.set(s)
 
+	connections.Inc()
+	connectionsTotal.Inc()
+
 	// initialize stream context
-	secured := !(cfg.transport.Type() == transport.Socket)
+	secured := !(cfg.transport.Type() == transport.Socket) || cfg.directTLS
 	s.ctx.SetBool(secured, securedCtxKey)
 
 	j, _ := jid.New("", "", "", true)
@@ -107,9 +88,6 @@ func newStream(id string, cfg *streamConfig) stream.C2S {
 	// initialize authenticators
 	s.initializeAuthenticators()
 
-	// initialize modules
-	s.initializeModules()
-
 	// start c2s session
 	s.restartSession()
 
@@ -117,7 +95,7 @@ func newStream(id string, cfg *streamConfig) stream.C2S {
 		s.connectTm = time.AfterFunc(cfg.connectTimeout, s.connectTimeout)
 	}
 	go s.loop()
-	go s.doRead() // start reading...
+	go s.doRead(s.sess) // start reading...
 
 	return s
 }
@@ -184,7 +162,7 @@ func (s *inStream) SendElement(elem xml.XElement) {
 	if s.getState() == disconnected {
 		return
 	}
-	s.actorCh <- func() { s.writeElement(elem) }
+	s.actorCh <- func() { s.sendElement(elem) }
 }
 
 // Disconnect disconnects remote peer by closing
@@ -207,91 +185,27 @@ func (s *inStream) initializeAuthenticators() {
 	for _, a := range s.cfg.sasl {
 		switch a {
 		case "plain":
-			authenticators = append(authenticators, auth.NewPlain(s))
+			authenticators = append(authenticators, auth.NewPlain(s, auth.Instance()))
 
 		case "digest_md5":
 			authenticators = append(authenticators, auth.NewDigestMD5(s))
 
 		case "scram_sha_1":
-			authenticators = append(authenticators, auth.NewScram(s, tr, auth.ScramSHA1, false))
-			authenticators = append(authenticators, auth.NewScram(s, tr, auth.ScramSHA1, true))
+			authenticators = append(authenticators, auth.NewScram(s, tr, auth.ScramSHA1, false, auth.Instance()))
+			authenticators = append(authenticators, auth.NewScram(s, tr, auth.ScramSHA1, true, auth.Instance()))
 
 		case "scram_sha_256":
-			authenticators = append(authenticators, auth.NewScram(s, tr, auth.ScramSHA256, false))
-			authenticators = append(authenticators, auth.NewScram(s, tr, auth.ScramSHA256, true))
-		}
-	}
-	s.authenticators = authenticators
-}
-
-func (s *inStream) initializeModules() {
-	var mods modules
-
-	// XEP-0030: Service Discovery (https://xmpp.org/extensions/xep-0030.html)
-	mods.discoInfo = xep0030.New(s)
-	mods.iqHandlers = append(mods.iqHandlers, mods.discoInfo)
-	mods.all = append(mods.all, mods.discoInfo)
+			authenticators = append(authenticators, auth.NewScram(s, tr, auth.ScramSHA256, false, auth.Instance()))
+			authenticators = append(authenticators, auth.NewScram(s, tr, auth.ScramSHA256, true, auth.Instance()))
 
-	// Roster (https://xmpp.org/rfcs/rfc3921.html#roster)
-	mods.roster = roster.New(&s.cfg.modules.Roster, s)
-	mods.iqHandlers = append(mods.iqHandlers, mods.roster)
-	mods.all = append(mods.all, mods.roster)
-
-	// XEP-0012: Last Activity (https://xmpp.org/extensions/xep-0012.html)
-	if _, ok := s.cfg.modules.Enabled["last_activity"]; ok {
-		mods.lastActivity = xep0012.New(s)
-		mods.iqHandlers = append(mods.iqHandlers, mods.lastActivity)
-		mods.all = append(mods.all, mods.lastActivity)
-	}
-
-	// XEP-0049: Private XML Storage (https://xmpp.org/extensions/xep-0049.html)
-	if _, ok := s.cfg.modules.Enabled["private"]; ok {
-		mods.private = xep0049.New(s)
-		mods.iqHandlers = append(mods.iqHandlers, mods.private)
-		mods.all = append(mods.all, mods.private)
-	}
-
-	// XEP-0054: vcard-temp (https://xmpp.org/extensions/xep-0054.html)
-	if _, ok := s.cfg.modules.Enabled["vcard"]; ok {
-		mods.vCard = xep0054.New(s)
-		mods.iqHandlers = append(mods.iqHandlers, mods.vCard)
-		mods.all = append(mods.all, mods.vCard)
-	}
-
-	// XEP-0077: In-band registration (https://xmpp.org/extensions/xep-0077.html)
-	if _, ok := s.cfg.modules.Enabled["registration"]; ok {
-		mods.register = xep0077.New(&s.cfg.modules.Registration, s)
-		mods.iqHandlers = append(mods.iqHandlers, mods.register)
-		mods.all = append(mods.all, mods.register)
-	}
-
-	// XEP-0092: Software Version (https://xmpp.org/extensions/xep-0092.html)
-	if _, ok := s.cfg.modules.Enabled["version"]; ok {
-		mods.version = xep0092.New(&s.cfg.modules.Version, s)
-		mods.iqHandlers = append(mods.iqHandlers, mods.version)
-		mods.all = append(mods.all, mods.version)
-	}
+		case "external":
+			authenticators = append(authenticators, auth.NewExternal(s, tr, s.cfg.external.Mapping, s.cfg.external.AutoProvision))
 
-	// XEP-0191: Blocking Command (https://xmpp.org/extensions/xep-0191.html)
-	if _, ok := s.cfg.modules.Enabled["blocking_command"]; ok {
-		mods.blockingCmd = xep0191.New(s)
-		mods.iqHandlers = append(mods.iqHandlers, mods.blockingCmd)
-		mods.all = append(mods.all, mods.blockingCmd)
-	}
-
-	// XEP-0199: XMPP Ping (https://xmpp.org/extensions/xep-0199.html)
-	if _, ok := s.cfg.modules.Enabled["ping"]; ok {
-		mods.ping = xep0199.New(&s.cfg.modules.Ping, s)
-		mods.iqHandlers = append(mods.iqHandlers, mods.ping)
-		mods.all = append(mods.all, mods.ping)
-	}
-
-	// XEP-0160: Offline message storage (https://xmpp.org/extensions/xep-0160.html)
-	if _, ok := s.cfg.modules.Enabled["offline"]; ok {
-		mods.offline = offline.New(&s.cfg.modules.Offline, s)
-		mods.all = append(mods.all, mods.offline)
+		case "x_token":
+			authenticators = append(authenticators, auth.NewToken(s))
+		}
 	}
-	s.mods = mods
+	s.authenticators = authenticators
 }
 
 func (s *inStream) connectTimeout() {
@@ -299,6 +213,9 @@ func (s *inStream) connectTimeout() {
 }
 
 func (s *inStream) handleElement(elem xml.XElement) {
+	if _, ok := elem.(xml.Stanza); ok && s.sm.enabled {
+		s.sm.inH++
+	}
 	switch s.getState() {
 	case connecting:
 		s.handleConnecting(elem)
@@ -328,6 +245,12 @@ func (s *inStream) handleConnecting(elem xml.XElement) {
 
 	// open stream session
 	s.sess.SetJID(j)
+
+	// reject hosts without an associated certificate
+	if !host.HasCertificate(domain) {
+		s.disconnectWithStreamError(streamerror.ErrHostUnknown)
+		return
+	}
 	s.sess.Open()
 
 	features := xml.NewElementName("stream:features")
@@ -360,20 +283,27 @@ func (s *inStream) unauthenticatedFeatures() []xml.XElement {
 	shouldOfferSASL := (!isSocketTr || (isSocketTr && s.IsSecured()))
 
 	if shouldOfferSASL && len(s.authenticators) > 0 {
+		hasPeerCerts := len(s.cfg.transport.PeerCertificates()) > 0
+
 		mechanisms := xml.NewElementName("mechanisms")
 		mechanisms.SetNamespace(saslNamespace)
 		for _, athr := range s.authenticators {
+			if _, ok := athr.(*auth.External); ok && !hasPeerCerts {
+				continue // no client certificate presented
+			}
 			mechanism := xml.NewElementName("mechanism")
 			mechanism.SetText(athr.Mechanism())
 			mechanisms.AppendElement(mechanism)
 		}
-		features = append(features, mechanisms)
+		if mechanisms.Elements().Count() > 0 {
+			features = append(features, mechanisms)
+		}
 	}
 
 	// allow In-band registration over encrypted stream only
 	allowRegistration := s.IsSecured()
 
-	if reg := s.mods.register; reg != nil && allowRegistration {
+	if reg := module.Lookup(s.Domain(), "registration"); reg != nil && allowRegistration {
 		registerFeature := xml.NewElementNamespace("register", "http://jabber.org/features/iq-register")
 		features = append(features, registerFeature)
 	}
@@ -402,7 +332,13 @@ func (s *inStream) authenticatedFeatures() []xml.XElement {
 	sessElem := xml.NewElementNamespace("session", "urn:ietf:params:xml:ns:xmpp-session")
 	features = append(features, sessElem)
 
-	if s.mods.roster != nil {
+	sm := xml.NewElementNamespace("sm", smNamespace)
+	features = append(features, sm)
+
+	csi := xml.NewElementNamespace("csi", csiNamespace)
+	features = append(features, csi)
+
+	if module.Lookup(s.Domain(), "roster") != nil {
 		ver := xml.NewElementNamespace("ver", "urn:xmpp:features:rosterver")
 		features = append(features, ver)
 	}
@@ -419,8 +355,8 @@ func (s *inStream) handleConnected(elem xml.XElement) {
 
 	case "iq":
 		iq := elem.(*xml.IQ)
-		if reg := s.mods.register; reg.MatchesIQ(iq) {
-			reg.ProcessIQ(iq)
+		if reg, ok := module.Lookup(s.Domain(), "registration").(module.IQHandler); ok && reg.MatchesIQ(iq) {
+			reg.ProcessIQ(iq, s)
 			return
 		} else if iq.Elements().ChildNamespace("query", "jabber:iq:auth") != nil {
 			// don't allow non-SASL authentication
@@ -445,11 +381,15 @@ func (s *inStream) handleAuthenticating(elem xml.XElement) {
 	authr := s.activeAuth
 	s.continueAuthentication(elem, authr)
 	if authr.Authenticated() {
-		s.finishAuthentication(authr.Username())
+		s.finishAuthentication(authr)
 	}
 }
 
 func (s *inStream) handleAuthenticated(elem xml.XElement) {
+	if elem.Namespace() == smNamespace {
+		s.handleStreamManagement(elem)
+		return
+	}
 	switch elem.Name() {
 	case "compress":
 		if elem.Namespace() != compressProtocolNamespace {
@@ -472,9 +412,13 @@ func (s *inStream) handleAuthenticated(elem xml.XElement) {
 }
 
 func (s *inStream) handleSessionStarted(elem xml.XElement) {
-	// reset ping timer deadline
-	if p := s.mods.ping; p != nil {
-		p.ResetDeadline()
+	switch elem.Namespace() {
+	case smNamespace:
+		s.handleStreamManagement(elem)
+		return
+	case csiNamespace:
+		s.handleClientState(elem)
+		return
 	}
 	stanza, ok := elem.(xml.Stanza)
 	if !ok {
@@ -501,7 +445,9 @@ func (s *inStream) proceedStartTLS(elem xml.XElement) {
 
 	s.writeElement(xml.NewElementNamespace("proceed", tlsNamespace))
 
-	s.cfg.transport.StartTLS(&tls.Config{Certificates: host.Certificates()}, false)
+	tlsCfg := &tls.Config{GetCertificate: host.GetCertificate(s.Domain())}
+	setClientAuth(tlsCfg, &s.cfg.external)
+	s.cfg.transport.StartTLS(tlsCfg, false)
 
 	log.Infof("secured stream... id: %s", s.id)
 	s.restartSession()
@@ -548,7 +494,7 @@ func (s *inStream) startAuthentication(elem xml.XElement) {
 				return
 			}
 			if authr.Authenticated() {
-				s.finishAuthentication(authr.Username())
+				s.finishAuthentication(authr)
 			} else {
 				s.activeAuth = authr
 				s.setState(authenticating)
@@ -564,6 +510,9 @@ func (s *inStream) startAuthentication(elem xml.XElement) {
 
 func (s *inStream) continueAuthentication(elem xml.XElement, authr auth.Authenticator) error {
 	err := authr.ProcessElement(elem)
+	if err != nil {
+		authentications.WithLabelValues(authr.Mechanism(), authFailure).Inc()
+	}
 	if saslErr, ok := err.(*auth.SASLError); ok {
 		s.failAuthentication(saslErr.Element())
 	} else if err != nil {
@@ -573,7 +522,10 @@ func (s *inStream) continueAuthentication(elem xml.XElement, authr auth.Authenti
 	return err
 }
 
-func (s *inStream) finishAuthentication(username string) {
+func (s *inStream) finishAuthentication(authr auth.Authenticator) {
+	authentications.WithLabelValues(authr.Mechanism(), authSuccess).Inc()
+
+	username := authr.Username()
 	if s.activeAuth != nil {
 		s.activeAuth.Reset()
 		s.activeAuth = nil
@@ -581,6 +533,7 @@ func (s *inStream) finishAuthentication(username string) {
 	j, _ := jid.New(username, s.Domain(), "", true)
 
 	s.ctx.SetString(username, usernameCtxKey)
+	s.ctx.SetString(authr.Mechanism(), auth.MechanismCtxKey)
 	s.ctx.SetBool(true, authenticatedCtxKey)
 	s.ctx.SetObject(j, jidCtxKey)
 
@@ -613,7 +566,7 @@ func (s *inStream) bindResource(iq *xml.IQ) {
 	}
 	// try binding...
 	var stm stream.C2S
-	stms := router.UserStreams(s.JID().Node())
+	stms := router.UserStreams(s.Username(), s.Domain())
 	for _, s := range stms {
 		if s.Resource() == resource {
 			stm = s
@@ -671,15 +624,11 @@ func (s *inStream) startSession(iq *xml.IQ) {
 	}
 	s.writeElement(iq.ResultIQ())
 
-	// register disco info elements
-	s.mods.discoInfo.RegisterDefaultEntities()
-	for _, mod := range s.mods.all {
-		mod.RegisterDisco(s.mods.discoInfo)
-	}
-	if p := s.mods.ping; p != nil {
-		p.StartPinging()
-	}
 	s.setState(sessionStarted)
+
+	for _, h := range module.StreamHandlers(s.Domain()) {
+		h.StreamStarted(s)
+	}
 }
 
 func (s *inStream) processStanza(stanza xml.Stanza) {
@@ -690,6 +639,10 @@ func (s *inStream) processStanza(stanza xml.Stanza) {
 		s.writeElement(resp)
 		return
 	}
+	if router.IsDeniedByPrivacyList(stanza, s, true) { // denied by privacy list?
+		s.writeElement(xml.NewErrorElementFromElement(stanza, xml.ErrNotAcceptable, nil))
+		return
+	}
 	switch stanza := stanza.(type) {
 	case *xml.Presence:
 		s.processPresence(stanza)
@@ -701,6 +654,26 @@ func (s *inStream) processStanza(stanza xml.Stanza) {
 }
 
 func (s *inStream) processComponentStanza(stanza xml.Stanza) {
+	domain := stanza.ToJID().Domain()
+	if svc := module.Service(domain); svc != nil {
+		svc.ProcessStanza(stanza)
+		return
+	}
+	if component.GetComponent(domain) == nil {
+		// component not connected
+		switch stanza := stanza.(type) {
+		case *xml.IQ:
+			if stanza.IsGet() || stanza.IsSet() {
+				s.writeElement(stanza.ServiceUnavailableError())
+			}
+		case *xml.Message:
+			if !stanza.IsError() {
+				s.writeElement(stanza.ServiceUnavailableError())
+			}
+		}
+		return
+	}
+	router.Route(stanza)
 }
 
 func (s *inStream) processIQ(iq *xml.IQ) {
@@ -721,11 +694,11 @@ func (s *inStream) processIQ(iq *xml.IQ) {
 		}
 		return
 	}
-	for _, handler := range s.mods.iqHandlers {
+	for _, handler := range module.IQHandlers(s.Domain()) {
 		if !handler.MatchesIQ(iq) {
 			continue
 		}
-		handler.ProcessIQ(iq)
+		handler.ProcessIQ(iq, s)
 		return
 	}
 
@@ -746,34 +719,29 @@ func (s *inStream) processPresence(presence *xml.Presence) {
 	if replyOnBehalf && (presence.IsAvailable() || presence.IsUnavailable()) {
 		s.ctx.SetObject(presence, presenceCtxKey)
 	}
-	// deliver subscription presence to roster module
-	if rst := s.mods.roster; rst != nil {
-		rst.ProcessPresence(presence)
-		return
-	}
-	// deliver offline messages
-	if off := s.mods.offline; off != nil && replyOnBehalf && presence.IsAvailable() && presence.Priority() >= 0 {
-		if !s.ctx.Bool(offlineDeliveredCtxKey) {
-			off.DeliverOfflineMessages()
-			s.ctx.SetBool(true, offlineDeliveredCtxKey)
-		}
+	for _, h := range module.PresenceHandlers(s.Domain()) {
+		h.ProcessPresence(presence, s)
 	}
 }
 
 func (s *inStream) processMessage(message *xml.Message) {
 	toJID := message.ToJID()
 
+	for _, h := range module.MessageHandlers(s.Domain()) {
+		h.ProcessMessage(message, s)
+	}
+
 sendMessage:
 	err := router.Route(message)
 	switch err {
 	case nil:
 		break
 	case router.ErrNotAuthenticated:
-		if off := s.mods.offline; off != nil {
+		if off, ok := module.Lookup(s.Domain(), "offline").(*offline.Offline); ok {
 			if (message.IsChat() || message.IsGroupChat()) && message.IsMessageWithBody() {
 				return
 			}
-			off.ArchiveMessage(message)
+			off.ArchiveMessage(message, s)
 		}
 	case router.ErrResourceNotFound:
 		// treat the stanza as if it were addressed to <node@domain>
@@ -800,15 +768,18 @@ func (s *inStream) loop() {
 }
 
 // runs on it's own goroutine
-func (s *inStream) doRead() {
-	elem, sErr := s.sess.Receive()
+func (s *inStream) doRead(sess *session.Session) {
+	elem, sErr := sess.Receive()
 	if sErr == nil {
 		s.actorCh <- func() {
+			if s.sess != sess {
+				return // stream transport has been replaced
+			}
 			s.readElement(elem)
 		}
 	} else {
 		s.actorCh <- func() {
-			if s.getState() == disconnected {
+			if s.getState() == disconnected || s.sess != sess {
 				return
 			}
 			s.handleSessionError(sErr)
@@ -817,6 +788,10 @@ func (s *inStream) doRead() {
 }
 
 func (s *inStream) handleSessionError(sErr *session.Error) {
+	if s.canHibernate() && s.isTransportFailure(sErr) {
+		s.hibernate()
+		return
+	}
 	switch err := sErr.UnderlyingErr.(type) {
 	case nil:
 		s.disconnect(nil)
@@ -831,6 +806,12 @@ func (s *inStream) handleSessionError(sErr *session.Error) {
 }
 
 func (s *inStream) writeElement(elem xml.XElement) {
+	if s.sm.enabled && elem.IsStanza() {
+		s.enqueueUnacked(elem)
+	}
+	if s.getState() == hibernated {
+		return
+	}
 	s.sess.Send(elem)
 }
 
@@ -839,7 +820,7 @@ func (s *inStream) readElement(elem xml.XElement) {
 		s.handleElement(elem)
 	}
 	if s.getState() != disconnected {
-		go s.doRead() // keep reading...
+		go s.doRead(s.sess) // keep reading...
 	}
 }
 
@@ -847,6 +828,15 @@ func (s *inStream) disconnect(err error) {
 	if s.getState() == disconnected {
 		return
 	}
+	if err == streamerror.ErrConnectionTimeout && s.sm.resumable {
+		// unresponsive peer... wait for resumption
+		if s.canHibernate() {
+			s.hibernate()
+		}
+		if s.getState() == hibernated {
+			return
+		}
+	}
 	switch err {
 	case nil:
 		s.disconnectClosingSession(false, true)
@@ -861,13 +851,17 @@ func (s *inStream) disconnect(err error) {
 }
 
 func (s *inStream) isComponentDomain(domain string) bool {
-	return false
+	if module.Service(domain) != nil {
+		return true
+	}
+	return component.IsComponentDomain(domain)
 }
 
 func (s *inStream) disconnectWithStreamError(err *streamerror.Error) {
 	if s.getState() == connecting {
 		s.sess.Open()
 	}
+	s.flushClientStateQueue()
 	s.writeElement(err.Element())
 
 	unregister := err != streamerror.ErrSystemShutdown
@@ -875,10 +869,26 @@ func (s *inStream) disconnectWithStreamError(err *streamerror.Error) {
 }
 
 func (s *inStream) disconnectClosingSession(closeSession, unbind bool) {
-	if presence := s.Presence(); presence != nil && presence.IsAvailable() && s.mods.roster != nil {
-		s.mods.roster.ProcessPresence(xml.NewPresence(s.JID(), s.JID().ToBareJID(), xml.UnavailableType))
+	s.flushClientStateQueue()
+
+	if presence := s.Presence(); presence != nil && presence.IsAvailable() {
+		unavailable := xml.NewPresence(s.JID(), s.JID().ToBareJID(), xml.UnavailableType)
+		for _, h := range module.PresenceHandlers(s.Domain()) {
+			h.ProcessPresence(unavailable, s)
+		}
+	}
+	if s.getState() == sessionStarted || s.getState() == hibernated {
+		for _, h := range module.StreamHandlers(s.Domain()) {
+			h.StreamClosed(s)
+		}
+	}
+	if s.sm.resumeTm != nil {
+		s.sm.resumeTm.Stop()
+	}
+	if s.sm.enabled {
+		s.archiveUnackedMessages()
 	}
-	if closeSession {
+	if closeSession && s.getState() != hibernated {
 		s.sess.Close()
 	}
 	// signal termination...
@@ -889,6 +899,7 @@ func (s *inStream) disconnectClosingSession(closeSession, unbind bool) {
 		router.Unbind(s)
 	}
 	inContainer.delete(s)
+	connections.Dec()
 
 	s.setState(disconnected)
 	s.cfg.transport.Close()
@@ -898,7 +909,7 @@ func (s *inStream) isBlockedJID(j *jid.JID) bool {
 	if j.IsServer() && host.IsLocalHost(j.Domain()) {
 		return false
 	}
-	return router.IsBlockedJID(j, s.Username())
+	return router.IsBlockedJID(j, s.Username(), s.Domain())
 }
 
 func (s *inStream) restartSession() {
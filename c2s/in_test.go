@@ -6,21 +6,30 @@
 package c2s
 
 import (
+	"encoding/base64"
 	"testing"
 	"time"
 
+	"github.com/ortuman/jackal/auth"
 	"github.com/ortuman/jackal/host"
 	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/model/privacymodel"
 	"github.com/ortuman/jackal/module"
 	"github.com/ortuman/jackal/module/offline"
+	_ "github.com/ortuman/jackal/module/roster"
+	_ "github.com/ortuman/jackal/module/xep0012"
+	_ "github.com/ortuman/jackal/module/xep0049"
+	_ "github.com/ortuman/jackal/module/xep0054"
 	"github.com/ortuman/jackal/module/xep0077"
 	"github.com/ortuman/jackal/module/xep0092"
+	_ "github.com/ortuman/jackal/module/xep0191"
 	"github.com/ortuman/jackal/module/xep0199"
 	"github.com/ortuman/jackal/router"
 	"github.com/ortuman/jackal/storage"
 	"github.com/ortuman/jackal/stream"
 	"github.com/ortuman/jackal/transport"
 	"github.com/ortuman/jackal/transport/compress"
+	"github.com/ortuman/jackal/util"
 	"github.com/ortuman/jackal/xml"
 	"github.com/ortuman/jackal/xml/jid"
 	"github.com/pborman/uuid"
@@ -28,10 +37,12 @@ import (
 )
 
 func TestStream_ConnectTimeout(t *testing.T) {
-	host.Initialize([]host.Config{{Name: "localhost"}})
+	host.Initialize([]host.Config{tUtilHostConfig(t, "localhost")})
 	router.Initialize(&router.Config{})
 	storage.Initialize(&storage.Config{Type: storage.Memory})
+	module.Initialize(tUtilModulesConfig())
 	defer func() {
+		module.Shutdown()
 		router.Shutdown()
 		storage.Shutdown()
 		host.Shutdown()
@@ -43,10 +54,12 @@ func TestStream_ConnectTimeout(t *testing.T) {
 }
 
 func TestStream_Disconnect(t *testing.T) {
-	host.Initialize([]host.Config{{Name: "localhost"}})
+	host.Initialize([]host.Config{tUtilHostConfig(t, "localhost")})
 	router.Initialize(&router.Config{})
 	storage.Initialize(&storage.Config{Type: storage.Memory})
+	module.Initialize(tUtilModulesConfig())
 	defer func() {
+		module.Shutdown()
 		router.Shutdown()
 		storage.Shutdown()
 		host.Shutdown()
@@ -60,10 +73,12 @@ func TestStream_Disconnect(t *testing.T) {
 }
 
 func TestStream_Features(t *testing.T) {
-	host.Initialize([]host.Config{{Name: "localhost"}})
+	host.Initialize([]host.Config{tUtilHostConfig(t, "localhost")})
 	router.Initialize(&router.Config{})
 	storage.Initialize(&storage.Config{Type: storage.Memory})
+	module.Initialize(tUtilModulesConfig())
 	defer func() {
+		module.Shutdown()
 		router.Shutdown()
 		storage.Shutdown()
 		host.Shutdown()
@@ -93,19 +108,55 @@ func TestStream_Features(t *testing.T) {
 	elem = conn2.outboundRead()
 	require.Equal(t, "stream:features", elem.Name())
 	require.NotNil(t, elem.Elements().ChildNamespace("mechanisms", saslNamespace))
+
+	// direct TLS features
+	conn3 := newFakeSocketConn()
+	cfg := tUtilInStreamDefaultConfig(transport.NewSocketTransport(conn3, 4096))
+	cfg.directTLS = true
+	stm3 := newStream("abcd5678", cfg).(*inStream)
+	require.True(t, stm3.IsSecured())
+	tUtilStreamOpen(conn3)
+
+	_ = conn3.outboundRead() // read stream opening...
+
+	elem = conn3.outboundRead()
+	require.Equal(t, "stream:features", elem.Name())
+	require.Nil(t, elem.Elements().ChildNamespace("starttls", tlsNamespace))
+	require.NotNil(t, elem.Elements().ChildNamespace("mechanisms", saslNamespace))
 }
 
-func TestStream_TLS(t *testing.T) {
+func TestStream_HostWithoutCertificate(t *testing.T) {
 	host.Initialize([]host.Config{{Name: "localhost"}})
 	router.Initialize(&router.Config{})
 	storage.Initialize(&storage.Config{Type: storage.Memory})
+	module.Initialize(tUtilModulesConfig())
 	defer func() {
+		module.Shutdown()
 		router.Shutdown()
 		storage.Shutdown()
 		host.Shutdown()
 	}()
 
-	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Password: "pencil"})
+	stm, conn := tUtilStreamInit()
+	tUtilStreamOpen(conn)
+
+	require.True(t, conn.waitClose())
+	require.Equal(t, disconnected, stm.getState())
+}
+
+func TestStream_TLS(t *testing.T) {
+	host.Initialize([]host.Config{tUtilHostConfig(t, "localhost")})
+	router.Initialize(&router.Config{})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	module.Initialize(tUtilModulesConfig())
+	defer func() {
+		module.Shutdown()
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Domain: "localhost", Password: "pencil"})
 
 	stm, conn := tUtilStreamInit()
 	tUtilStreamOpen(conn)
@@ -124,16 +175,18 @@ func TestStream_TLS(t *testing.T) {
 }
 
 func TestStream_FailAuthenticate(t *testing.T) {
-	host.Initialize([]host.Config{{Name: "localhost"}})
+	host.Initialize([]host.Config{tUtilHostConfig(t, "localhost")})
 	router.Initialize(&router.Config{})
 	storage.Initialize(&storage.Config{Type: storage.Memory})
+	module.Initialize(tUtilModulesConfig())
 	defer func() {
+		module.Shutdown()
 		router.Shutdown()
 		storage.Shutdown()
 		host.Shutdown()
 	}()
 
-	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Password: "pencil"})
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Domain: "localhost", Password: "pencil"})
 
 	_, conn := tUtilStreamInit()
 	tUtilStreamOpen(conn)
@@ -169,17 +222,60 @@ func TestStream_FailAuthenticate(t *testing.T) {
 	require.NotNil(t, elem.Elements().Child("error"))
 }
 
+func TestStream_TokenAuthenticate(t *testing.T) {
+	host.Initialize([]host.Config{tUtilHostConfig(t, "localhost")})
+	router.Initialize(&router.Config{})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	module.Initialize(tUtilModulesConfig())
+	defer func() {
+		module.Shutdown()
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Domain: "localhost", Password: "pencil"})
+	token, _, _ := auth.IssueToken("user", "localhost", "balcony", time.Hour)
+
+	stm, conn := tUtilStreamInit()
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	_ = conn.outboundRead() // read stream features...
+
+	// invalid token
+	conn.inboundWrite([]byte(`<auth xmlns="urn:ietf:params:xml:ns:xmpp-sasl" mechanism="X-TOKEN">` +
+		base64.StdEncoding.EncodeToString([]byte("\x00user\x00pencil")) + `</auth>`))
+
+	elem := conn.outboundRead()
+	require.Equal(t, "failure", elem.Name())
+	require.NotNil(t, elem.Elements().Child("not-authorized"))
+
+	conn.inboundWrite([]byte(`<auth xmlns="urn:ietf:params:xml:ns:xmpp-sasl" mechanism="X-TOKEN">` +
+		base64.StdEncoding.EncodeToString([]byte("\x00user\x00"+token)) + `</auth>`))
+
+	elem = conn.outboundRead()
+	require.Equal(t, "success", elem.Name())
+
+	time.Sleep(time.Millisecond * 100) // wait until stream internal state changes
+
+	require.True(t, stm.IsAuthenticated())
+	require.Equal(t, "user", stm.Username())
+	require.Equal(t, "X-TOKEN", stm.Context().String(auth.MechanismCtxKey))
+}
+
 func TestStream_Compression(t *testing.T) {
-	host.Initialize([]host.Config{{Name: "localhost"}})
+	host.Initialize([]host.Config{tUtilHostConfig(t, "localhost")})
 	router.Initialize(&router.Config{})
 	storage.Initialize(&storage.Config{Type: storage.Memory})
+	module.Initialize(tUtilModulesConfig())
 	defer func() {
+		module.Shutdown()
 		router.Shutdown()
 		storage.Shutdown()
 		host.Shutdown()
 	}()
 
-	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Password: "pencil"})
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Domain: "localhost", Password: "pencil"})
 
 	stm, conn := tUtilStreamInit()
 	tUtilStreamOpen(conn)
@@ -219,16 +315,18 @@ func TestStream_Compression(t *testing.T) {
 }
 
 func TestStream_StartSession(t *testing.T) {
-	host.Initialize([]host.Config{{Name: "localhost"}})
+	host.Initialize([]host.Config{tUtilHostConfig(t, "localhost")})
 	router.Initialize(&router.Config{})
 	storage.Initialize(&storage.Config{Type: storage.Memory})
+	module.Initialize(tUtilModulesConfig())
 	defer func() {
+		module.Shutdown()
 		router.Shutdown()
 		storage.Shutdown()
 		host.Shutdown()
 	}()
 
-	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Password: "pencil"})
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Domain: "localhost", Password: "pencil"})
 
 	stm, conn := tUtilStreamInit()
 	tUtilStreamOpen(conn)
@@ -247,16 +345,18 @@ func TestStream_StartSession(t *testing.T) {
 }
 
 func TestStream_SendIQ(t *testing.T) {
-	host.Initialize([]host.Config{{Name: "localhost"}})
+	host.Initialize([]host.Config{tUtilHostConfig(t, "localhost")})
 	router.Initialize(&router.Config{})
 	storage.Initialize(&storage.Config{Type: storage.Memory})
+	module.Initialize(tUtilModulesConfig())
 	defer func() {
+		module.Shutdown()
 		router.Shutdown()
 		storage.Shutdown()
 		host.Shutdown()
 	}()
 
-	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Password: "pencil"})
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Domain: "localhost", Password: "pencil"})
 
 	stm, conn := tUtilStreamInit()
 	tUtilStreamOpen(conn)
@@ -289,16 +389,18 @@ func TestStream_SendIQ(t *testing.T) {
 }
 
 func TestStream_SendPresence(t *testing.T) {
-	host.Initialize([]host.Config{{Name: "localhost"}})
+	host.Initialize([]host.Config{tUtilHostConfig(t, "localhost")})
 	router.Initialize(&router.Config{})
 	storage.Initialize(&storage.Config{Type: storage.Memory})
+	module.Initialize(tUtilModulesConfig())
 	defer func() {
+		module.Shutdown()
 		router.Shutdown()
 		storage.Shutdown()
 		host.Shutdown()
 	}()
 
-	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Password: "pencil"})
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Domain: "localhost", Password: "pencil"})
 
 	stm, conn := tUtilStreamInit()
 	tUtilStreamOpen(conn)
@@ -339,16 +441,18 @@ func TestStream_SendPresence(t *testing.T) {
 }
 
 func TestStream_SendMessage(t *testing.T) {
-	host.Initialize([]host.Config{{Name: "localhost"}})
+	host.Initialize([]host.Config{tUtilHostConfig(t, "localhost")})
 	router.Initialize(&router.Config{})
 	storage.Initialize(&storage.Config{Type: storage.Memory})
+	module.Initialize(tUtilModulesConfig())
 	defer func() {
+		module.Shutdown()
 		router.Shutdown()
 		storage.Shutdown()
 		host.Shutdown()
 	}()
 
-	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Password: "pencil"})
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Domain: "localhost", Password: "pencil"})
 
 	stm, conn := tUtilStreamInit()
 	tUtilStreamOpen(conn)
@@ -396,16 +500,18 @@ func TestStream_SendMessage(t *testing.T) {
 }
 
 func TestStream_SendToBlockedJID(t *testing.T) {
-	host.Initialize([]host.Config{{Name: "localhost"}})
+	host.Initialize([]host.Config{tUtilHostConfig(t, "localhost")})
 	router.Initialize(&router.Config{})
 	storage.Initialize(&storage.Config{Type: storage.Memory})
+	module.Initialize(tUtilModulesConfig())
 	defer func() {
+		module.Shutdown()
 		router.Shutdown()
 		storage.Shutdown()
 		host.Shutdown()
 	}()
 
-	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Password: "pencil"})
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Domain: "localhost", Password: "pencil"})
 
 	stm, conn := tUtilStreamInit()
 	tUtilStreamOpen(conn)
@@ -424,6 +530,7 @@ func TestStream_SendToBlockedJID(t *testing.T) {
 
 	storage.Instance().InsertBlockListItems([]model.BlockListItem{{
 		Username: "user",
+		Domain:   "localhost",
 		JID:      "hamlet@localhost",
 	}})
 
@@ -436,6 +543,58 @@ func TestStream_SendToBlockedJID(t *testing.T) {
 	require.NotNil(t, elem.Elements().Child("error"))
 }
 
+func TestStream_SendToPrivacyDeniedJID(t *testing.T) {
+	host.Initialize([]host.Config{tUtilHostConfig(t, "localhost")})
+	router.Initialize(&router.Config{})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	module.Initialize(tUtilModulesConfig())
+	defer func() {
+		module.Shutdown()
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Domain: "localhost", Password: "pencil"})
+
+	stm, conn := tUtilStreamInit()
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	_ = conn.outboundRead() // read stream features...
+
+	tUtilStreamAuthenticate(conn, t)
+
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	_ = conn.outboundRead() // read stream features...
+
+	tUtilStreamStartSession(conn, t)
+
+	require.Equal(t, sessionStarted, stm.getState())
+
+	storage.Instance().InsertOrUpdatePrivacyList(&privacymodel.List{
+		Username: "user",
+		Domain:   "localhost",
+		Name:     "public",
+		Default:  true,
+		Items: []privacymodel.Item{{
+			Type:    privacymodel.JIDType,
+			Value:   "hamlet@localhost",
+			Action:  privacymodel.Deny,
+			Order:   1,
+			Stanzas: []string{privacymodel.PresenceOut},
+		}},
+	})
+
+	// send presence to a denied JID...
+	conn.inboundWrite([]byte(`<presence to="hamlet@localhost"/>`))
+
+	elem := conn.outboundRead()
+	require.Equal(t, "presence", elem.Name())
+	require.Equal(t, xml.ErrorType, elem.Type())
+	require.NotNil(t, elem.Error().Elements().Child(xml.ErrNotAcceptable.Error()))
+}
+
 func tUtilStreamOpen(conn *fakeSocketConn) {
 	s := `<?xml version="1.0"?>
 	<stream:stream xmlns:stream="http://etherx.jabber.org/streams"
@@ -492,6 +651,18 @@ func tUtilStreamInit() (*inStream, *fakeSocketConn) {
 }
 
 func tUtilInStreamDefaultConfig(tr transport.Transport) *streamConfig {
+	return &streamConfig{
+		connectTimeout:   time.Second,
+		resumeTimeout:    time.Second,
+		transport:        tr,
+		maxStanzaSize:    8192,
+		resourceConflict: Reject,
+		compression:      CompressConfig{Level: compress.DefaultCompression},
+		sasl:             []string{"plain", "digest_md5", "scram_sha_1", "scram_sha_256", "x_token"},
+	}
+}
+
+func tUtilModulesConfig() *module.Config {
 	modules := map[string]struct{}{}
 	modules["roster"] = struct{}{}
 	modules["last_activity"] = struct{}{}
@@ -503,19 +674,19 @@ func tUtilInStreamDefaultConfig(tr transport.Transport) *streamConfig {
 	modules["blocking_command"] = struct{}{}
 	modules["offline"] = struct{}{}
 
-	return &streamConfig{
-		connectTimeout:   time.Second,
-		transport:        tr,
-		maxStanzaSize:    8192,
-		resourceConflict: Reject,
-		compression:      CompressConfig{Level: compress.DefaultCompression},
-		sasl:             []string{"plain", "digest_md5", "scram_sha_1", "scram_sha_256"},
-		modules: &module.Config{
-			Enabled:      modules,
-			Offline:      offline.Config{QueueSize: 10},
-			Registration: xep0077.Config{AllowRegistration: true, AllowChange: true},
-			Version:      xep0092.Config{ShowOS: true},
-			Ping:         xep0199.Config{SendInterval: 5, Send: true},
+	return &module.Config{
+		Enabled: modules,
+		Settings: map[string]interface{}{
+			"offline":      offline.Config{QueueSize: 10},
+			"registration": xep0077.Config{AllowRegistration: true, AllowChange: true},
+			"version":      xep0092.Config{ShowOS: true},
+			"ping":         xep0199.Config{SendInterval: 5, Send: true},
 		},
 	}
 }
+
+func tUtilHostConfig(t *testing.T, domain string) host.Config {
+	cer, err := util.LoadCertificate("../testdata/cert/test.server.key", "../testdata/cert/test.server.crt", domain)
+	require.Nil(t, err)
+	return host.Config{Name: domain, Certificate: cer}
+}
@@ -0,0 +1,32 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package c2s
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	connections = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "jackal_c2s_connections",
+		Help: "Number of currently open c2s connections.",
+	})
+	connectionsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "jackal_c2s_connections_total",
+		Help: "Total number of accepted c2s connections.",
+	})
+	authentications = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "jackal_auth_total",
+		Help: "Total number of c2s SASL authentications by mechanism and outcome.",
+	}, []string{"mechanism", "outcome"})
+)
+
+const (
+	authSuccess = "success"
+	authFailure = "failure"
+)
+
+func init() {
+	prometheus.MustRegister(connections, connectionsTotal, authentications)
+}
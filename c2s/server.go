@@ -10,14 +10,12 @@ import (
 	"fmt"
 	"net"
 	"net/http"
-	_ "net/http/pprof" // http profile handlers
 	"strconv"
 	"sync/atomic"
 
 	"github.com/gorilla/websocket"
 	"github.com/ortuman/jackal/host"
 	"github.com/ortuman/jackal/log"
-	"github.com/ortuman/jackal/module"
 	"github.com/ortuman/jackal/transport"
 )
 
@@ -25,10 +23,11 @@ var listenerProvider = net.Listen
 
 type server struct {
 	cfg        *Config
-	modConfig  *module.Config
 	ln         net.Listener
 	wsSrv      *http.Server
 	wsUpgrader *websocket.Upgrader
+	boshSrv    *http.Server
+	boshHdl    *transport.BOSHHandler
 	stmCounter uint64
 	listening  uint32
 }
@@ -38,7 +37,7 @@ func (s *server) start() {
 	port := s.cfg.Transport.Port
 	address := bindAddr + ":" + strconv.Itoa(port)
 
-	log.Infof("%s: listening at %s [transport: %v]", s.cfg.ID, address, s.cfg.Transport.Type)
+	log.Infof("%s: listening at %s [transport: %v, direct_tls: %v]", s.cfg.ID, address, s.cfg.Transport.Type, s.cfg.Transport.DirectTLS)
 
 	var err error
 	switch s.cfg.Transport.Type {
@@ -46,7 +45,8 @@ func (s *server) start() {
 		err = s.listenSocketConn(address)
 	case transport.WebSocket:
 		err = s.listenWebSocketConn(address)
-		break
+	case transport.BOSH:
+		err = s.listenBOSHConn(address)
 	}
 	if err != nil {
 		log.Fatalf("%v", err)
@@ -58,6 +58,14 @@ func (s *server) listenSocketConn(address string) error {
 	if err != nil {
 		return err
 	}
+	if s.cfg.Transport.DirectTLS {
+		tlsCfg := &tls.Config{
+			GetCertificate: host.GetCertificate(""),
+			NextProtos:     []string{xmppClientALPN},
+		}
+		setClientAuth(tlsCfg, &s.cfg.External)
+		ln = tls.NewListener(ln, tlsCfg)
+	}
 	s.ln = ln
 
 	atomic.StoreUint32(&s.listening, 1)
@@ -72,9 +80,10 @@ func (s *server) listenSocketConn(address string) error {
 }
 
 func (s *server) listenWebSocketConn(address string) error {
-	http.HandleFunc(s.cfg.Transport.URLPath, s.websocketUpgrade)
+	mux := http.NewServeMux()
+	mux.HandleFunc(s.cfg.Transport.URLPath, s.websocketUpgrade)
 
-	s.wsSrv = &http.Server{TLSConfig: &tls.Config{Certificates: host.Certificates()}}
+	s.wsSrv = &http.Server{Handler: mux, TLSConfig: &tls.Config{GetCertificate: host.GetCertificate("")}}
 	s.wsUpgrader = &websocket.Upgrader{
 		Subprotocols: []string{"xmpp"},
 		CheckOrigin:  func(r *http.Request) bool { return r.Header.Get("Sec-WebSocket-Protocol") == "xmpp" },
@@ -98,6 +107,22 @@ func (s *server) websocketUpgrade(w http.ResponseWriter, r *http.Request) {
 	s.startStream(transport.NewWebSocketTransport(conn, s.cfg.Transport.KeepAlive))
 }
 
+func (s *server) listenBOSHConn(address string) error {
+	s.boshHdl = transport.NewBOSHHandler(&s.cfg.Transport.BOSH, s.startStream)
+
+	mux := http.NewServeMux()
+	mux.Handle(s.cfg.Transport.URLPath, s.boshHdl)
+	s.boshSrv = &http.Server{Handler: mux, TLSConfig: &tls.Config{GetCertificate: host.GetCertificate("")}}
+
+	// start listening
+	ln, err := listenerProvider("tcp", address)
+	if err != nil {
+		return err
+	}
+	atomic.StoreUint32(&s.listening, 1)
+	return s.boshSrv.ServeTLS(ln, "", "")
+}
+
 func (s *server) shutdown() error {
 	if atomic.CompareAndSwapUint32(&s.listening, 1, 0) {
 		switch s.cfg.Transport.Type {
@@ -105,6 +130,9 @@ func (s *server) shutdown() error {
 			return s.ln.Close()
 		case transport.WebSocket:
 			return s.wsSrv.Close()
+		case transport.BOSH:
+			s.boshHdl.Close()
+			return s.boshSrv.Close()
 		}
 	}
 	return nil
@@ -113,12 +141,14 @@ func (s *server) shutdown() error {
 func (s *server) startStream(tr transport.Transport) {
 	cfg := &streamConfig{
 		transport:        tr,
+		directTLS:        s.cfg.Transport.DirectTLS,
 		resourceConflict: s.cfg.ResourceConflict,
 		connectTimeout:   s.cfg.ConnectTimeout,
+		resumeTimeout:    s.cfg.ResumeTimeout,
 		maxStanzaSize:    s.cfg.MaxStanzaSize,
 		sasl:             s.cfg.SASL,
+		external:         s.cfg.External,
 		compression:      s.cfg.Compression,
-		modules:          s.modConfig,
 	}
 	newStream(s.nextID(), cfg)
 }
@@ -126,3 +156,13 @@ func (s *server) startStream(tr transport.Transport) {
 func (s *server) nextID() string {
 	return fmt.Sprintf("c2s:%s:%d", s.cfg.ID, atomic.AddUint64(&s.stmCounter, 1))
 }
+
+// setClientAuth enables client certificate verification
+// in case a client CA pool has been configured.
+func setClientAuth(tlsCfg *tls.Config, external *ExternalConfig) {
+	if external.ClientCAs == nil {
+		return
+	}
+	tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+	tlsCfg.ClientCAs = external.ClientCAs
+}
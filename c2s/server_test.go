@@ -7,23 +7,27 @@ package c2s
 
 import (
 	"crypto/tls"
+	"errors"
 	"net"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/ortuman/jackal/auth"
 	"github.com/ortuman/jackal/host"
-	"github.com/ortuman/jackal/module"
+	"github.com/ortuman/jackal/model"
 	"github.com/ortuman/jackal/router"
 	"github.com/ortuman/jackal/storage"
 	"github.com/ortuman/jackal/transport"
 	"github.com/ortuman/jackal/util"
+	"github.com/ortuman/jackal/xml"
 	"github.com/stretchr/testify/require"
 )
 
 func TestC2SSocketServer(t *testing.T) {
-	host.Initialize([]host.Config{{Name: "localhost"}})
+	host.Initialize([]host.Config{tUtilHostConfig(t, "localhost")})
 	storage.Initialize(&storage.Config{Type: storage.Memory})
 	router.Initialize(&router.Config{})
 
@@ -38,7 +42,7 @@ func TestC2SSocketServer(t *testing.T) {
 			Port: 9998,
 		},
 	}
-	go Initialize([]Config{cfg}, &module.Config{})
+	go Initialize([]Config{cfg})
 
 	go func() {
 		time.Sleep(time.Millisecond * 150)
@@ -92,7 +96,7 @@ func TestC2SWebSocketServer(t *testing.T) {
 			Port:    9999,
 		},
 	}
-	go Initialize([]Config{cfg}, &module.Config{})
+	go Initialize([]Config{cfg})
 
 	go func() {
 		time.Sleep(time.Millisecond * 150)
@@ -125,3 +129,212 @@ func TestC2SWebSocketServer(t *testing.T) {
 	storage.Shutdown()
 	host.Shutdown()
 }
+
+func TestC2SBOSHServer(t *testing.T) {
+	privKeyFile := "../testdata/cert/test.server.key"
+	certFile := "../testdata/cert/test.server.crt"
+	cer, err := util.LoadCertificate(privKeyFile, certFile, "localhost")
+	require.Nil(t, err)
+
+	host.Initialize([]host.Config{{Name: "localhost", Certificate: cer}})
+	router.Initialize(&router.Config{})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+
+	errCh := make(chan error)
+	cfg := Config{
+		ID:               "srv-1234",
+		ConnectTimeout:   time.Second * time.Duration(5),
+		MaxStanzaSize:    8192,
+		ResourceConflict: Reject,
+		SASL:             []string{"plain"},
+		Transport: TransportConfig{
+			Type:    transport.BOSH,
+			URLPath: "/http-bind",
+			Port:    9997,
+			BOSH:    transport.BOSHConfig{Wait: time.Second, Hold: 1, Inactivity: time.Second},
+		},
+	}
+	go Initialize([]Config{cfg})
+
+	var body xml.XElement
+	go func() {
+		time.Sleep(time.Millisecond * 150)
+		cl := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+		req := `<body xmlns="http://jabber.org/protocol/httpbind" xmlns:xmpp="urn:xmpp:xbosh" rid="1" to="localhost" wait="60" hold="1" xmpp:version="1.0"/>`
+		resp, err := cl.Post("https://127.0.0.1:9997/http-bind", "text/xml; charset=utf-8", strings.NewReader(req))
+		if err != nil {
+			errCh <- err
+			return
+		}
+		body, err = xml.NewParser(resp.Body, xml.DefaultMode, 0).ParseElement()
+		resp.Body.Close()
+
+		Shutdown()
+		errCh <- err
+	}()
+	err = <-errCh
+	require.Nil(t, err)
+
+	require.Equal(t, "body", body.Name())
+	require.NotEqual(t, "", body.Attributes().Get("sid"))
+	require.Equal(t, "localhost", body.Attributes().Get("from"))
+	features := body.Elements().Child("stream:features")
+	require.NotNil(t, features)
+	require.NotNil(t, features.Elements().ChildNamespace("mechanisms", saslNamespace))
+
+	router.Shutdown()
+	storage.Shutdown()
+	host.Shutdown()
+}
+
+func TestC2SDirectTLSServer(t *testing.T) {
+	privKeyFile := "../testdata/cert/test.server.key"
+	certFile := "../testdata/cert/test.server.crt"
+	cer, err := util.LoadCertificate(privKeyFile, certFile, "localhost")
+	require.Nil(t, err)
+
+	host.Initialize([]host.Config{{Name: "localhost", Certificate: cer}})
+	router.Initialize(&router.Config{})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+
+	errCh := make(chan error)
+	cfg := Config{
+		ID:               "srv-1234",
+		ConnectTimeout:   time.Second * time.Duration(5),
+		MaxStanzaSize:    8192,
+		ResourceConflict: Reject,
+		SASL:             []string{"plain"},
+		Transport: TransportConfig{
+			Type:      transport.Socket,
+			Port:      9996,
+			DirectTLS: true,
+		},
+	}
+	go Initialize([]Config{cfg})
+
+	var features xml.XElement
+	go func() {
+		time.Sleep(time.Millisecond * 150)
+
+		conn, err := tls.Dial("tcp", "127.0.0.1:9996", &tls.Config{InsecureSkipVerify: true, NextProtos: []string{"xmpp-client"}})
+		if err != nil {
+			errCh <- err
+			return
+		}
+		if conn.ConnectionState().NegotiatedProtocol != "xmpp-client" {
+			errCh <- errors.New("xmpp-client protocol not negotiated")
+			return
+		}
+		_, err = conn.Write([]byte(`<?xml version="1.0"?><stream:stream xmlns="jabber:client" xmlns:stream="http://etherx.jabber.org/streams" to="localhost" version="1.0">`))
+		if err != nil {
+			errCh <- err
+			return
+		}
+		pr := xml.NewParser(conn, xml.SocketStream, 0)
+		for features == nil && err == nil {
+			var elem xml.XElement
+			elem, err = pr.ParseElement()
+			if elem != nil && elem.Name() == "stream:features" {
+				features = elem
+			}
+		}
+		conn.Close()
+
+		Shutdown()
+		errCh <- err
+	}()
+	err = <-errCh
+	require.Nil(t, err)
+
+	require.NotNil(t, features)
+	require.Nil(t, features.Elements().ChildNamespace("starttls", tlsNamespace))
+	require.NotNil(t, features.Elements().ChildNamespace("mechanisms", saslNamespace))
+
+	router.Shutdown()
+	storage.Shutdown()
+	host.Shutdown()
+}
+
+func TestC2SExternalAuthentication(t *testing.T) {
+	privKeyFile := "../testdata/cert/test.server.key"
+	certFile := "../testdata/cert/test.server.crt"
+	cer, err := util.LoadCertificate(privKeyFile, certFile, "localhost")
+	require.Nil(t, err)
+	clientCer, err := tls.LoadX509KeyPair("../testdata/cert/test.client.crt", "../testdata/cert/test.client.key")
+	require.Nil(t, err)
+	clientCAs, err := util.LoadCertPool("../testdata/cert/test.ca.crt")
+	require.Nil(t, err)
+
+	host.Initialize([]host.Config{{Name: "localhost", Certificate: cer}})
+	router.Initialize(&router.Config{})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Domain: "localhost"})
+
+	errCh := make(chan error)
+	cfg := Config{
+		ID:               "srv-1234",
+		ConnectTimeout:   time.Second * time.Duration(5),
+		MaxStanzaSize:    8192,
+		ResourceConflict: Reject,
+		SASL:             []string{"plain", "external"},
+		External:         ExternalConfig{ClientCAs: clientCAs, Mapping: auth.XMPPAddrMapping},
+		Transport: TransportConfig{
+			Type:      transport.Socket,
+			Port:      9995,
+			DirectTLS: true,
+		},
+	}
+	go Initialize([]Config{cfg})
+
+	var mechanisms []string
+	var result xml.XElement
+	go func() {
+		time.Sleep(time.Millisecond * 150)
+
+		conn, err := tls.Dial("tcp", "127.0.0.1:9995", &tls.Config{InsecureSkipVerify: true, Certificates: []tls.Certificate{clientCer}})
+		if err != nil {
+			errCh <- err
+			return
+		}
+		_, err = conn.Write([]byte(`<?xml version="1.0"?><stream:stream xmlns="jabber:client" xmlns:stream="http://etherx.jabber.org/streams" to="localhost" version="1.0">`))
+		if err != nil {
+			errCh <- err
+			return
+		}
+		pr := xml.NewParser(conn, xml.SocketStream, 0)
+		var features xml.XElement
+		for features == nil && err == nil {
+			var elem xml.XElement
+			elem, err = pr.ParseElement()
+			if elem != nil && elem.Name() == "stream:features" {
+				features = elem
+			}
+		}
+		if features != nil {
+			for _, m := range features.Elements().ChildNamespace("mechanisms", saslNamespace).Elements().All() {
+				mechanisms = append(mechanisms, m.Text())
+			}
+		}
+		if err == nil {
+			_, err = conn.Write([]byte(`<auth xmlns="urn:ietf:params:xml:ns:xmpp-sasl" mechanism="EXTERNAL">=</auth>`))
+		}
+		if err == nil {
+			result, err = pr.ParseElement()
+		}
+		conn.Close()
+
+		Shutdown()
+		errCh <- err
+	}()
+	err = <-errCh
+	require.Nil(t, err)
+
+	require.Equal(t, []string{"PLAIN", "EXTERNAL"}, mechanisms)
+	require.NotNil(t, result)
+	require.Equal(t, "success", result.Name())
+
+	router.Shutdown()
+	storage.Shutdown()
+	host.Shutdown()
+}
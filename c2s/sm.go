@@ -0,0 +1,257 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package c2s
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/ortuman/jackal/errors"
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/module"
+	"github.com/ortuman/jackal/session"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/xml"
+)
+
+const smNamespace = "urn:xmpp:sm:3"
+
+// number of unacknowledged stanzas after which
+// an acknowledgement is requested to the peer.
+const smRequestAckInterval = 10
+
+type streamManagement struct {
+	enabled   bool
+	resumable bool
+	inH       uint32
+	outH      uint32
+	unacked   []xml.XElement
+	resumeTm  *time.Timer
+}
+
+func (s *inStream) handleStreamManagement(elem xml.XElement) {
+	switch elem.Name() {
+	case "enable":
+		s.enableStreamManagement(elem)
+	case "r":
+		if !s.sm.enabled {
+			s.writeElement(smFailedElement("unexpected-request"))
+			return
+		}
+		a := xml.NewElementNamespace("a", smNamespace)
+		a.SetAttribute("h", strconv.FormatUint(uint64(s.sm.inH), 10))
+		s.writeElement(a)
+	case "a":
+		h, err := strconv.ParseUint(elem.Attributes().Get("h"), 10, 32)
+		if err != nil || !s.sm.enabled {
+			s.writeElement(smFailedElement("unexpected-request"))
+			return
+		}
+		if !s.acknowledge(uint32(h)) {
+			// acknowledged stanzas must be within the range of the sent ones
+			s.disconnectWithStreamError(streamerror.ErrUndefinedCondition)
+		}
+	case "resume":
+		if len(s.Resource()) > 0 {
+			s.writeElement(smFailedElement("unexpected-request"))
+			return
+		}
+		s.resumeStream(elem)
+	default:
+		s.writeElement(smFailedElement("feature-not-implemented"))
+	}
+}
+
+func (s *inStream) enableStreamManagement(elem xml.XElement) {
+	if s.sm.enabled || len(s.Resource()) == 0 {
+		s.writeElement(smFailedElement("unexpected-request"))
+		return
+	}
+	s.sm.enabled = true
+
+	enabled := xml.NewElementNamespace("enabled", smNamespace)
+	resume := elem.Attributes().Get("resume")
+	if (resume == "true" || resume == "1") && s.cfg.resumeTimeout > 0 {
+		s.sm.resumable = true
+		enabled.SetAttribute("id", s.id)
+		enabled.SetAttribute("resume", "true")
+		enabled.SetAttribute("max", strconv.Itoa(int(s.cfg.resumeTimeout/time.Second)))
+	}
+	s.writeElement(enabled)
+}
+
+// acknowledge releases unacked stanzas up to h. It returns false
+// if h is lower than a previous acknowledgement or higher
+// than the number of stanzas sent so far.
+func (s *inStream) acknowledge(h uint32) bool {
+	if !s.validAck(h) {
+		return false
+	}
+	n := int(h - s.sm.outH) // counter wraps around at 2^32
+	s.sm.unacked = s.sm.unacked[n:]
+	s.sm.outH = h
+	return true
+}
+
+func (s *inStream) validAck(h uint32) bool {
+	return uint64(h-s.sm.outH) <= uint64(len(s.sm.unacked))
+}
+
+// enqueueUnacked keeps track of an outgoing stanza until
+// being acknowledged by the peer.
+func (s *inStream) enqueueUnacked(elem xml.XElement) {
+	s.sm.unacked = append(s.sm.unacked, elem)
+	if len(s.sm.unacked)%smRequestAckInterval == 0 && s.getState() != hibernated {
+		s.sess.Send(xml.NewElementNamespace("r", smNamespace))
+	}
+}
+
+func (s *inStream) canHibernate() bool {
+	return s.sm.resumable && s.getState() == sessionStarted
+}
+
+// isTransportFailure returns whether or not a session error
+// has been originated by a broken or unresponsive connection.
+func (s *inStream) isTransportFailure(sErr *session.Error) bool {
+	switch err := sErr.UnderlyingErr.(type) {
+	case nil:
+		return !s.sess.IsClosedByPeer()
+	case *streamerror.Error:
+		return err == streamerror.ErrConnectionTimeout
+	case *xml.StanzaError:
+		return false
+	}
+	return true
+}
+
+// hibernate detaches stream from its underlying transport keeping it
+// bound to the router until being resumed or resumption timeout expires.
+func (s *inStream) hibernate() {
+	s.flushClientStateQueue() // keep buffered stanzas as unacknowledged
+	s.setState(hibernated)
+	s.cfg.transport.Close()
+
+	s.sm.resumeTm = time.AfterFunc(s.cfg.resumeTimeout, func() {
+		s.actorCh <- func() {
+			if s.getState() == hibernated {
+				s.disconnectClosingSession(false, true)
+			}
+		}
+	})
+	log.Infof("hibernated stream... id: %s", s.id)
+}
+
+func (s *inStream) resumeStream(elem xml.XElement) {
+	h, err := strconv.ParseUint(elem.Attributes().Get("h"), 10, 32)
+	if err != nil {
+		s.writeElement(smFailedElement("bad-request"))
+		return
+	}
+	prev, ok := inContainer.get(elem.Attributes().Get("previd")).(*inStream)
+	if !ok || prev == s || prev.Username() != s.Username() || prev.Domain() != s.Domain() {
+		s.writeElement(smFailedElement("item-not-found"))
+		return
+	}
+	if failed := prev.takeOver(s, uint32(h)); failed != nil {
+		s.writeElement(failed)
+		return
+	}
+	// transport is now owned by the resumed stream
+	close(s.doneCh)
+	inContainer.delete(s)
+	connections.Dec()
+	s.setState(disconnected)
+}
+
+// takeOver hands over a newly authenticated stream transport
+// to a previously hibernated (or not yet timed out) stream.
+// A <failed/> element is returned if resumption is not possible.
+func (s *inStream) takeOver(stm *inStream, h uint32) xml.XElement {
+	resCh := make(chan xml.XElement, 1)
+	select {
+	case s.actorCh <- func() { resCh <- s.resume(stm, h) }:
+		break
+	case <-s.ctx.Done():
+		return smFailedElement("item-not-found")
+	}
+	select {
+	case failed := <-resCh:
+		return failed
+	case <-s.ctx.Done():
+		return smFailedElement("item-not-found")
+	}
+}
+
+func (s *inStream) resume(stm *inStream, h uint32) xml.XElement {
+	if !s.sm.resumable {
+		return smFailedElement("item-not-found")
+	}
+	if !s.validAck(h) {
+		return smFailedElement("undefined-condition")
+	}
+	switch s.getState() {
+	case sessionStarted:
+		// peer reconnected before the old connection was detected as broken
+		s.cfg.transport.Close()
+	case hibernated:
+		s.sm.resumeTm.Stop()
+		s.sm.resumeTm = nil
+	default:
+		return smFailedElement("item-not-found")
+	}
+	s.cfg.transport = stm.cfg.transport
+	s.sess = stm.sess
+	s.sess.SetJID(s.JID())
+	s.ctx.SetBool(stm.IsSecured(), securedCtxKey)
+	s.ctx.SetBool(stm.IsCompressed(), compressedCtxKey)
+	s.setState(sessionStarted)
+
+	s.acknowledge(h)
+
+	resumed := xml.NewElementNamespace("resumed", smNamespace)
+	resumed.SetAttribute("previd", s.id)
+	resumed.SetAttribute("h", strconv.FormatUint(uint64(s.sm.inH), 10))
+	s.writeElement(resumed)
+
+	// resend unacknowledged stanzas
+	unacked := s.sm.unacked
+	s.sm.unacked = nil
+	for _, elem := range unacked {
+		s.writeElement(elem)
+	}
+	log.Infof("resumed stream... id: %s", s.id)
+
+	go s.doRead(s.sess) // start reading from the new transport...
+	return nil
+}
+
+// archiveUnackedMessages stores every unacknowledged message
+// into offline storage.
+func (s *inStream) archiveUnackedMessages() {
+	if module.Lookup(s.Domain(), "offline") == nil {
+		return
+	}
+	for _, elem := range s.sm.unacked {
+		message, ok := elem.(*xml.Message)
+		if !ok || !message.IsMessageWithBody() {
+			continue
+		}
+		delayed := xml.NewElementFromElement(message)
+		delayed.Delay(s.Domain(), "Offline Storage")
+		if err := storage.Instance().InsertOfflineMessage(delayed, s.Username(), s.Domain()); err != nil {
+			log.Error(err)
+			continue
+		}
+		log.Infof("archived unacknowledged message... id: %s", message.ID())
+	}
+	s.sm.unacked = nil
+}
+
+func smFailedElement(condition string) xml.XElement {
+	failed := xml.NewElementNamespace("failed", smNamespace)
+	failed.AppendElement(xml.NewElementNamespace(condition, "urn:ietf:params:xml:ns:xmpp-stanzas"))
+	return failed
+}
@@ -0,0 +1,321 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package c2s
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/module"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/transport"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+	"github.com/pborman/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStream_SMEnableAndAck(t *testing.T) {
+	host.Initialize([]host.Config{tUtilHostConfig(t, "localhost")})
+	router.Initialize(&router.Config{})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	module.Initialize(tUtilModulesConfig())
+	defer func() {
+		module.Shutdown()
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Domain: "localhost", Password: "pencil"})
+
+	stm, conn := tUtilStreamSMInit("sm:1", t)
+	defer stm.Disconnect(nil)
+
+	// ack request before enabling
+	conn.inboundWrite([]byte(`<r xmlns="urn:xmpp:sm:3"/>`))
+	elem := conn.outboundRead()
+	require.Equal(t, "failed", elem.Name())
+
+	conn.inboundWrite([]byte(`<enable xmlns="urn:xmpp:sm:3"/>`))
+	elem = conn.outboundRead()
+	require.Equal(t, "enabled", elem.Name())
+	require.Equal(t, smNamespace, elem.Namespace())
+	require.Equal(t, "", elem.Attributes().Get("resume"))
+
+	// enable twice
+	conn.inboundWrite([]byte(`<enable xmlns="urn:xmpp:sm:3"/>`))
+	elem = conn.outboundRead()
+	require.Equal(t, "failed", elem.Name())
+
+	iq := xml.NewIQType(uuid.New(), xml.GetType)
+	iq.AppendElement(xml.NewElementNamespace("query", "jabber:iq:roster"))
+	conn.inboundWrite([]byte(iq.String()))
+
+	elem = conn.outboundRead()
+	require.Equal(t, "iq", elem.Name())
+
+	conn.inboundWrite([]byte(`<r xmlns="urn:xmpp:sm:3"/>`))
+	elem = conn.outboundRead()
+	require.Equal(t, "a", elem.Name())
+	require.Equal(t, "1", elem.Attributes().Get("h"))
+
+	require.Equal(t, 1, tUtilStreamUnackedCount(stm))
+
+	conn.inboundWrite([]byte(`<a xmlns="urn:xmpp:sm:3" h="1"/>`))
+	time.Sleep(time.Millisecond * 100)
+
+	require.Equal(t, 0, tUtilStreamUnackedCount(stm))
+}
+
+func TestStream_SMAckOutOfRange(t *testing.T) {
+	host.Initialize([]host.Config{tUtilHostConfig(t, "localhost")})
+	router.Initialize(&router.Config{})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	module.Initialize(tUtilModulesConfig())
+	defer func() {
+		module.Shutdown()
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Domain: "localhost", Password: "pencil"})
+
+	// acknowledging more stanzas than sent
+	stm1, conn1 := tUtilStreamSMInit("sm:6", t)
+
+	conn1.inboundWrite([]byte(`<enable xmlns="urn:xmpp:sm:3"/>`))
+	_ = conn1.outboundRead()
+
+	conn1.inboundWrite([]byte(`<a xmlns="urn:xmpp:sm:3" h="1"/>`))
+	require.True(t, conn1.waitClose())
+	require.Equal(t, disconnected, stm1.getState())
+
+	// acknowledging less stanzas than previously acknowledged
+	stm2, conn2 := tUtilStreamSMInit("sm:7", t)
+
+	conn2.inboundWrite([]byte(`<enable xmlns="urn:xmpp:sm:3"/>`))
+	_ = conn2.outboundRead()
+
+	iq := xml.NewIQType(uuid.New(), xml.GetType)
+	iq.AppendElement(xml.NewElementNamespace("query", "jabber:iq:roster"))
+	conn2.inboundWrite([]byte(iq.String()))
+	_ = conn2.outboundRead()
+
+	conn2.inboundWrite([]byte(`<a xmlns="urn:xmpp:sm:3" h="1"/>`))
+	time.Sleep(time.Millisecond * 100)
+	require.Equal(t, 0, tUtilStreamUnackedCount(stm2))
+	require.Equal(t, sessionStarted, stm2.getState())
+
+	conn2.inboundWrite([]byte(`<a xmlns="urn:xmpp:sm:3" h="0"/>`))
+	require.True(t, conn2.waitClose())
+	require.Equal(t, disconnected, stm2.getState())
+}
+
+func TestStream_SMResume(t *testing.T) {
+	host.Initialize([]host.Config{tUtilHostConfig(t, "localhost")})
+	router.Initialize(&router.Config{})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	module.Initialize(tUtilModulesConfig())
+	defer func() {
+		module.Shutdown()
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Domain: "localhost", Password: "pencil"})
+
+	stm1, conn1 := tUtilStreamSMInit("sm:2", t)
+	defer stm1.Disconnect(nil)
+
+	conn1.inboundWrite([]byte(`<enable xmlns="urn:xmpp:sm:3" resume="true"/>`))
+	elem := conn1.outboundRead()
+	require.Equal(t, "enabled", elem.Name())
+	require.Equal(t, "true", elem.Attributes().Get("resume"))
+	require.Equal(t, "sm:2", elem.Attributes().Get("id"))
+
+	msg1 := tUtilSMMessage(stm1.JID())
+	router.Route(msg1)
+	elem = conn1.outboundRead()
+	require.Equal(t, msg1.ID(), elem.ID())
+
+	// break connection
+	conn1.Close()
+	time.Sleep(time.Millisecond * 100)
+	require.Equal(t, hibernated, stm1.getState())
+
+	msg2 := tUtilSMMessage(stm1.JID())
+	require.Nil(t, router.Route(msg2))
+
+	// resume session from a new connection
+	openConns := testutil.ToFloat64(connections)
+	conn2 := newFakeSocketConn()
+	stm2 := newStream("sm:3", tUtilInStreamDefaultConfig(transport.NewSocketTransport(conn2, 4096))).(*inStream)
+	tUtilStreamOpen(conn2)
+	_ = conn2.outboundRead() // read stream opening...
+	_ = conn2.outboundRead() // read stream features...
+
+	tUtilStreamAuthenticate(conn2, t)
+
+	tUtilStreamOpen(conn2)
+	_ = conn2.outboundRead() // read stream opening...
+	_ = conn2.outboundRead() // read stream features...
+
+	conn2.inboundWrite([]byte(`<resume xmlns="urn:xmpp:sm:3" previd="sm:2" h="0"/>`))
+	elem = conn2.outboundRead()
+	require.Equal(t, "resumed", elem.Name())
+	require.Equal(t, "sm:2", elem.Attributes().Get("previd"))
+
+	elem = conn2.outboundRead()
+	require.Equal(t, msg1.ID(), elem.ID())
+	elem = conn2.outboundRead()
+	require.Equal(t, msg2.ID(), elem.ID())
+
+	time.Sleep(time.Millisecond * 100)
+	require.Equal(t, sessionStarted, stm1.getState())
+	require.Equal(t, disconnected, stm2.getState())
+	require.Equal(t, openConns, testutil.ToFloat64(connections))
+
+	stms := router.UserStreams("user", "localhost")
+	require.Equal(t, 1, len(stms))
+	require.Equal(t, stm1, stms[0])
+
+	// resumed stream keeps working over the new connection
+	conn2.inboundWrite([]byte(`<r xmlns="urn:xmpp:sm:3"/>`))
+	elem = conn2.outboundRead()
+	require.Equal(t, "a", elem.Name())
+}
+
+func TestStream_SMResumeFailed(t *testing.T) {
+	host.Initialize([]host.Config{tUtilHostConfig(t, "localhost")})
+	router.Initialize(&router.Config{})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	module.Initialize(tUtilModulesConfig())
+	defer func() {
+		module.Shutdown()
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Domain: "localhost", Password: "pencil"})
+
+	conn := newFakeSocketConn()
+	stm := newStream("sm:4", tUtilInStreamDefaultConfig(transport.NewSocketTransport(conn, 4096))).(*inStream)
+	defer stm.Disconnect(nil)
+
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	_ = conn.outboundRead() // read stream features...
+
+	tUtilStreamAuthenticate(conn, t)
+
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	_ = conn.outboundRead() // read stream features...
+
+	conn.inboundWrite([]byte(`<resume xmlns="urn:xmpp:sm:3" previd="sm:unknown" h="0"/>`))
+	elem := conn.outboundRead()
+	require.Equal(t, "failed", elem.Name())
+	require.NotNil(t, elem.Elements().Child("item-not-found"))
+	require.Equal(t, authenticated, stm.getState())
+
+	// acknowledging more stanzas than sent by the previous stream
+	stm1, conn1 := tUtilStreamSMInit("sm:8", t)
+	defer stm1.Disconnect(nil)
+
+	conn1.inboundWrite([]byte(`<enable xmlns="urn:xmpp:sm:3" resume="true"/>`))
+	_ = conn1.outboundRead()
+
+	router.Route(tUtilSMMessage(stm1.JID()))
+	_ = conn1.outboundRead()
+
+	conn1.Close()
+	time.Sleep(time.Millisecond * 100)
+	require.Equal(t, hibernated, stm1.getState())
+
+	conn.inboundWrite([]byte(`<resume xmlns="urn:xmpp:sm:3" previd="sm:8" h="2"/>`))
+	elem = conn.outboundRead()
+	require.Equal(t, "failed", elem.Name())
+	require.NotNil(t, elem.Elements().Child("undefined-condition"))
+	require.Equal(t, authenticated, stm.getState())
+	require.Equal(t, hibernated, stm1.getState())
+}
+
+func TestStream_SMResumeTimeout(t *testing.T) {
+	host.Initialize([]host.Config{tUtilHostConfig(t, "localhost")})
+	router.Initialize(&router.Config{})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	module.Initialize(tUtilModulesConfig())
+	defer func() {
+		module.Shutdown()
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Domain: "localhost", Password: "pencil"})
+
+	stm, conn := tUtilStreamSMInit("sm:5", t)
+
+	conn.inboundWrite([]byte(`<enable xmlns="urn:xmpp:sm:3" resume="true"/>`))
+	_ = conn.outboundRead()
+
+	conn.Close()
+	time.Sleep(time.Millisecond * 100)
+	require.Equal(t, hibernated, stm.getState())
+
+	require.Nil(t, router.Route(tUtilSMMessage(stm.JID())))
+
+	time.Sleep(time.Millisecond * 1500) // wait until resumption timeout expires
+	require.Equal(t, disconnected, stm.getState())
+	require.Equal(t, 0, len(router.UserStreams("user", "localhost")))
+
+	cnt, _ := storage.Instance().CountOfflineMessages("user", "localhost")
+	require.Equal(t, 1, cnt)
+}
+
+func tUtilStreamSMInit(id string, t *testing.T) (*inStream, *fakeSocketConn) {
+	conn := newFakeSocketConn()
+	tr := transport.NewSocketTransport(conn, 4096)
+	stm := newStream(id, tUtilInStreamDefaultConfig(tr)).(*inStream)
+
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	_ = conn.outboundRead() // read stream features...
+
+	tUtilStreamAuthenticate(conn, t)
+
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	_ = conn.outboundRead() // read stream features...
+
+	tUtilStreamStartSession(conn, t)
+	return stm, conn
+}
+
+func tUtilSMMessage(to *jid.JID) *xml.Message {
+	from, _ := jid.New("romeo", "localhost", "orchard", true)
+	msg := xml.NewMessageType(uuid.New(), xml.ChatType)
+	msg.SetFromJID(from)
+	msg.SetToJID(to)
+	body := xml.NewElementName("body")
+	body.SetText("Hi!")
+	msg.AppendElement(body)
+	return msg
+}
+
+func tUtilStreamUnackedCount(stm *inStream) int {
+	ch := make(chan int, 1)
+	stm.actorCh <- func() { ch <- len(stm.sm.unacked) }
+	return <-ch
+}
@@ -0,0 +1,101 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package component
+
+import (
+	"sync"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/module"
+	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/stream"
+)
+
+const streamMailboxSize = 256
+
+var (
+	instMu      sync.RWMutex
+	secrets     map[string]string
+	domains     []string
+	srv         *server
+	initialized bool
+)
+
+// Initialize initializes external component sub system.
+func Initialize(cfg *Config) {
+	instMu.Lock()
+	defer instMu.Unlock()
+	if initialized {
+		return
+	}
+	if len(cfg.Hosts) == 0 {
+		return
+	}
+	secrets = make(map[string]string, len(cfg.Hosts))
+	domains = nil
+	for _, h := range cfg.Hosts {
+		secrets[h.Name] = h.Secret
+		domains = append(domains, h.Name)
+	}
+	// announce component domains through local hosts service discovery
+	for _, h := range host.HostNames() {
+		if discoInfo := module.DiscoInfo(h); discoInfo != nil {
+			for _, domain := range domains {
+				discoInfo.ServerEntity().AddItem(xep0030.Item{Jid: domain})
+			}
+		}
+	}
+	srv = &server{cfg: cfg}
+	go srv.start()
+	initialized = true
+}
+
+// Shutdown closes component listener and disconnects every
+// connected component.
+// This method should be used only for testing purposes.
+func Shutdown() {
+	instMu.Lock()
+	defer instMu.Unlock()
+	if initialized {
+		srv.shutdown()
+		srv = nil
+		secrets = nil
+		domains = nil
+		initialized = false
+	}
+}
+
+// IsComponentDomain returns whether or not a domain corresponds
+// to a configured external component.
+func IsComponentDomain(domain string) bool {
+	instMu.RLock()
+	defer instMu.RUnlock()
+	_, ok := secrets[domain]
+	return ok
+}
+
+// Domains returns every configured external component domain.
+func Domains() []string {
+	instMu.RLock()
+	defer instMu.RUnlock()
+	return domains
+}
+
+// GetComponent returns the stream associated to a connected
+// external component domain.
+func GetComponent(domain string) stream.Component {
+	if stm := compContainer.get(domain); stm != nil {
+		return stm
+	}
+	return nil
+}
+
+func componentSecret(domain string) (string, bool) {
+	instMu.RLock()
+	defer instMu.RUnlock()
+	secret, ok := secrets[domain]
+	return secret, ok
+}
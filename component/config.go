@@ -0,0 +1,116 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package component
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ortuman/jackal/transport"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultTransportPort      = 5347
+	defaultTransportKeepAlive = time.Duration(10) * time.Minute
+	defaultConnectTimeout     = time.Duration(5) * time.Second
+	defaultMaxStanzaSize      = 131072
+)
+
+// TransportConfig represents component listener transport configuration.
+type TransportConfig struct {
+	BindAddress string
+	Port        int
+	KeepAlive   time.Duration
+}
+
+type transportConfigProxy struct {
+	BindAddress string `yaml:"bind_addr"`
+	Port        int    `yaml:"port"`
+	KeepAlive   int    `yaml:"keep_alive"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (c *TransportConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := transportConfigProxy{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	c.BindAddress = p.BindAddress
+	c.Port = p.Port
+	if c.Port == 0 {
+		c.Port = defaultTransportPort
+	}
+	if p.KeepAlive > 0 {
+		c.KeepAlive = time.Duration(p.KeepAlive) * time.Second
+	} else {
+		c.KeepAlive = defaultTransportKeepAlive
+	}
+	return nil
+}
+
+// HostConfig represents an external component configuration.
+type HostConfig struct {
+	Name   string `yaml:"name"`
+	Secret string `yaml:"secret"`
+}
+
+// Config represents external components configuration.
+type Config struct {
+	ConnectTimeout time.Duration
+	MaxStanzaSize  int
+	Transport      TransportConfig
+	Hosts          []HostConfig
+}
+
+type configProxy struct {
+	ConnectTimeout int             `yaml:"connect_timeout"`
+	MaxStanzaSize  int             `yaml:"max_stanza_size"`
+	Transport      TransportConfig `yaml:"transport"`
+	Hosts          []HostConfig    `yaml:"hosts"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := configProxy{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	names := make(map[string]struct{}, len(p.Hosts))
+	for _, h := range p.Hosts {
+		if len(h.Name) == 0 {
+			return errors.New("component.Config: must specify a component name")
+		}
+		if len(h.Secret) == 0 {
+			return fmt.Errorf("component.Config: must specify a secret for component %s", h.Name)
+		}
+		if _, ok := names[h.Name]; ok {
+			return fmt.Errorf("component.Config: duplicated component %s", h.Name)
+		}
+		names[h.Name] = struct{}{}
+	}
+	c.Hosts = p.Hosts
+	c.ConnectTimeout = time.Duration(p.ConnectTimeout) * time.Second
+	if c.ConnectTimeout == 0 {
+		c.ConnectTimeout = defaultConnectTimeout
+	}
+	c.Transport = p.Transport
+	if c.Transport.Port == 0 {
+		c.Transport.Port = defaultTransportPort
+		c.Transport.KeepAlive = defaultTransportKeepAlive
+	}
+	c.MaxStanzaSize = p.MaxStanzaSize
+	if c.MaxStanzaSize == 0 {
+		c.MaxStanzaSize = defaultMaxStanzaSize
+	}
+	return nil
+}
+
+type streamConfig struct {
+	connectTimeout time.Duration
+	transport      transport.Transport
+	maxStanzaSize  int
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package component
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestTransportConfig(t *testing.T) {
+	rawCfg := `
+bind_addr 0.0.0.0
+`
+	trCfg := TransportConfig{}
+	err := yaml.Unmarshal([]byte(rawCfg), &trCfg)
+	require.NotNil(t, err)
+
+	rawCfg = `
+bind_addr: 0.0.0.0
+`
+	err = yaml.Unmarshal([]byte(rawCfg), &trCfg)
+	require.Nil(t, err)
+	require.Equal(t, "0.0.0.0", trCfg.BindAddress)
+	require.Equal(t, 5347, trCfg.Port)
+	require.Equal(t, time.Duration(600)*time.Second, trCfg.KeepAlive)
+
+	rawCfg = `
+bind_addr: 127.0.0.1
+port: 5999
+keep_alive: 200
+`
+	err = yaml.Unmarshal([]byte(rawCfg), &trCfg)
+	require.Nil(t, err)
+	require.Equal(t, "127.0.0.1", trCfg.BindAddress)
+	require.Equal(t, 5999, trCfg.Port)
+	require.Equal(t, time.Duration(200)*time.Second, trCfg.KeepAlive)
+}
+
+func TestConfig(t *testing.T) {
+	rawCfg := `
+hosts
+`
+	cfg := Config{}
+	err := yaml.Unmarshal([]byte(rawCfg), &cfg)
+	require.NotNil(t, err)
+
+	rawCfg = `
+hosts:
+  - secret: s3cr3t
+`
+	err = yaml.Unmarshal([]byte(rawCfg), &cfg)
+	require.NotNil(t, err) // missing name
+
+	rawCfg = `
+hosts:
+  - name: bot.jackal.im
+`
+	err = yaml.Unmarshal([]byte(rawCfg), &cfg)
+	require.NotNil(t, err) // missing secret
+
+	rawCfg = `
+hosts:
+  - name: bot.jackal.im
+    secret: s3cr3t
+  - name: bot.jackal.im
+    secret: s3cr3t
+`
+	err = yaml.Unmarshal([]byte(rawCfg), &cfg)
+	require.NotNil(t, err) // duplicated component
+
+	rawCfg = `
+hosts:
+  - name: bot.jackal.im
+    secret: s3cr3t
+`
+	err = yaml.Unmarshal([]byte(rawCfg), &cfg)
+	require.Nil(t, err) // defaults
+	require.Equal(t, defaultConnectTimeout, cfg.ConnectTimeout)
+	require.Equal(t, defaultMaxStanzaSize, cfg.MaxStanzaSize)
+	require.Equal(t, defaultTransportPort, cfg.Transport.Port)
+	require.Equal(t, defaultTransportKeepAlive, cfg.Transport.KeepAlive)
+	require.Equal(t, 1, len(cfg.Hosts))
+	require.Equal(t, "bot.jackal.im", cfg.Hosts[0].Name)
+	require.Equal(t, "s3cr3t", cfg.Hosts[0].Secret)
+
+	rawCfg = `
+connect_timeout: 250
+max_stanza_size: 8192
+transport:
+  port: 5348
+hosts:
+  - name: bot.jackal.im
+    secret: s3cr3t
+  - name: gateway.jackal.im
+    secret: g4t3w4y
+`
+	err = yaml.Unmarshal([]byte(rawCfg), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, time.Duration(250)*time.Second, cfg.ConnectTimeout)
+	require.Equal(t, 8192, cfg.MaxStanzaSize)
+	require.Equal(t, 5348, cfg.Transport.Port)
+	require.Equal(t, 2, len(cfg.Hosts))
+}
@@ -0,0 +1,47 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package component
+
+import (
+	"sync"
+
+	"github.com/ortuman/jackal/errors"
+	"github.com/ortuman/jackal/log"
+)
+
+var compContainer compMap
+
+type compMap struct{ m sync.Map }
+
+func (m *compMap) register(stm *inStream) bool {
+	if _, loaded := m.m.LoadOrStore(stm.Domain(), stm); loaded {
+		return false
+	}
+	log.Infof("registered component stream... (domain: %s)", stm.Domain())
+	return true
+}
+
+func (m *compMap) unregister(stm *inStream) {
+	if s, ok := m.m.Load(stm.Domain()); !ok || s != stm {
+		return
+	}
+	m.m.Delete(stm.Domain())
+	log.Infof("unregistered component stream... (domain: %s)", stm.Domain())
+}
+
+func (m *compMap) get(domain string) *inStream {
+	if s, ok := m.m.Load(domain); ok {
+		return s.(*inStream)
+	}
+	return nil
+}
+
+func (m *compMap) disconnectAll() {
+	m.m.Range(func(_, s interface{}) bool {
+		s.(*inStream).Disconnect(streamerror.ErrSystemShutdown)
+		return true
+	})
+}
@@ -0,0 +1,326 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package component
+
+import (
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ortuman/jackal/errors"
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/module"
+	"github.com/ortuman/jackal/module/roster"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/session"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+)
+
+const (
+	connecting uint32 = iota
+	handshaking
+	authenticated
+	disconnected
+)
+
+type inStream struct {
+	id        string
+	cfg       *streamConfig
+	domain    string
+	state     uint32
+	connectTm *time.Timer
+	sess      *session.Session
+	actorCh   chan func()
+}
+
+func newInStream(cfg *streamConfig) *inStream {
+	s := &inStream{
+		id:      nextInID(),
+		cfg:     cfg,
+		actorCh: make(chan func(), streamMailboxSize),
+	}
+	// start component session
+	s.restartSession()
+
+	if cfg.connectTimeout > 0 {
+		s.connectTm = time.AfterFunc(cfg.connectTimeout, s.connectTimeout)
+	}
+	go s.loop()
+	go s.doRead() // start reading transport...
+	return s
+}
+
+// ID returns component stream identifier.
+func (s *inStream) ID() string {
+	return s.id
+}
+
+// Domain returns component stream domain.
+func (s *inStream) Domain() string {
+	return s.domain
+}
+
+// SendElement writes an XMPP element to the component stream.
+func (s *inStream) SendElement(elem xml.XElement) {
+	if s.getState() == disconnected {
+		return
+	}
+	s.actorCh <- func() { s.writeElement(elem) }
+}
+
+// Disconnect disconnects remote peer by closing the underlying TCP socket connection.
+func (s *inStream) Disconnect(err error) {
+	if s.getState() == disconnected {
+		return
+	}
+	waitCh := make(chan struct{})
+	s.actorCh <- func() {
+		s.disconnect(err)
+		close(waitCh)
+	}
+	<-waitCh
+}
+
+func (s *inStream) connectTimeout() {
+	s.actorCh <- func() { s.disconnect(streamerror.ErrConnectionTimeout) }
+}
+
+// runs on its own goroutine
+func (s *inStream) loop() {
+	for {
+		f := <-s.actorCh
+		f()
+		if s.getState() == disconnected {
+			return
+		}
+	}
+}
+
+// runs on its own goroutine
+func (s *inStream) doRead() {
+	if elem, sErr := s.sess.Receive(); sErr == nil {
+		s.actorCh <- func() {
+			s.readElement(elem)
+		}
+	} else {
+		s.actorCh <- func() {
+			if s.getState() == disconnected {
+				return // already disconnected...
+			}
+			s.handleSessionError(sErr)
+		}
+	}
+}
+
+func (s *inStream) handleElement(elem xml.XElement) {
+	switch s.getState() {
+	case connecting:
+		s.handleConnecting(elem)
+	case handshaking:
+		s.handleHandshaking(elem)
+	case authenticated:
+		s.handleAuthenticated(elem)
+	}
+}
+
+func (s *inStream) handleConnecting(elem xml.XElement) {
+	// cancel connection timeout timer
+	if s.connectTm != nil {
+		s.connectTm.Stop()
+		s.connectTm = nil
+	}
+	domain := elem.To()
+	if _, ok := componentSecret(domain); !ok {
+		s.disconnectWithStreamError(streamerror.ErrHostUnknown)
+		return
+	}
+	s.domain = domain
+
+	// open stream session
+	j, _ := jid.New("", domain, "", true)
+	s.sess.SetJID(j)
+	s.sess.SetRemoteDomain(domain)
+	s.sess.Open()
+
+	s.setState(handshaking)
+}
+
+func (s *inStream) handleHandshaking(elem xml.XElement) {
+	if elem.Name() != "handshake" {
+		s.disconnectWithStreamError(streamerror.ErrNotAuthorized)
+		return
+	}
+	secret, ok := componentSecret(s.domain)
+	if !ok {
+		s.disconnectWithStreamError(streamerror.ErrHostUnknown)
+		return
+	}
+	h := sha1.Sum([]byte(s.sess.StreamID() + secret))
+	expected := hex.EncodeToString(h[:])
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(elem.Text())) != 1 {
+		log.Infof("failed component handshake... (domain: %s)", s.domain)
+		s.disconnectWithStreamError(streamerror.ErrNotAuthorized)
+		return
+	}
+	if !compContainer.register(s) {
+		s.disconnectWithStreamError(streamerror.ErrConflict)
+		return
+	}
+	s.setState(authenticated)
+	s.writeElement(xml.NewElementName("handshake"))
+
+	log.Infof("component stream authenticated... (domain: %s)", s.domain)
+}
+
+func (s *inStream) handleAuthenticated(elem xml.XElement) {
+	stanza, ok := elem.(xml.Stanza)
+	if !ok {
+		s.disconnectWithStreamError(streamerror.ErrUnsupportedStanzaType)
+		return
+	}
+	// components are only allowed to send stanzas on behalf of their domain (or subdomains)
+	if !s.isValidFrom(stanza.FromJID()) {
+		s.disconnectWithStreamError(streamerror.ErrInvalidFrom)
+		return
+	}
+	s.processStanza(stanza)
+}
+
+func (s *inStream) processStanza(stanza xml.Stanza) {
+	toJID := stanza.ToJID()
+	if svc := module.Service(toJID.Domain()); svc != nil {
+		svc.ProcessStanza(stanza)
+		return
+	}
+	if presence, ok := stanza.(*xml.Presence); ok && toJID.IsBare() {
+		if rst, ok := module.Lookup(toJID.Domain(), "roster").(*roster.Roster); ok {
+			rst.PresenceHandler().ProcessPresence(presence)
+			return
+		}
+	}
+	switch router.Route(stanza) {
+	case router.ErrNotExistingAccount, router.ErrResourceNotFound, router.ErrBlockedJID:
+		s.replyWithError(stanza, xml.ErrServiceUnavailable)
+	case router.ErrNotAuthenticated:
+		if _, ok := stanza.(*xml.IQ); ok {
+			s.replyWithError(stanza, xml.ErrServiceUnavailable)
+		}
+	case router.ErrFailedRemoteConnect:
+		s.replyWithError(stanza, xml.ErrRemoteServerNotFound)
+	}
+}
+
+func (s *inStream) isValidFrom(from *jid.JID) bool {
+	domain := from.Domain()
+	return domain == s.domain || strings.HasSuffix(domain, "."+s.domain)
+}
+
+func (s *inStream) replyWithError(stanza xml.Stanza, stanzaErr *xml.StanzaError) {
+	switch stanza := stanza.(type) {
+	case *xml.Presence:
+		return
+	case *xml.IQ:
+		if !stanza.IsGet() && !stanza.IsSet() {
+			return
+		}
+	}
+	if stanza.IsError() {
+		return
+	}
+	s.writeElement(xml.NewErrorElementFromElement(stanza, stanzaErr, nil))
+}
+
+func (s *inStream) writeElement(elem xml.XElement) {
+	s.sess.Send(elem)
+}
+
+func (s *inStream) readElement(elem xml.XElement) {
+	if elem != nil {
+		s.handleElement(elem)
+	}
+	if s.getState() != disconnected {
+		go s.doRead()
+	}
+}
+
+func (s *inStream) handleSessionError(sErr *session.Error) {
+	switch err := sErr.UnderlyingErr.(type) {
+	case nil:
+		s.disconnect(nil)
+	case *streamerror.Error:
+		s.disconnectWithStreamError(err)
+	case *xml.StanzaError:
+		s.writeElement(xml.NewErrorElementFromElement(sErr.Element, err, nil))
+	default:
+		log.Error(err)
+		s.disconnectWithStreamError(streamerror.ErrUndefinedCondition)
+	}
+}
+
+func (s *inStream) disconnect(err error) {
+	if s.getState() == disconnected {
+		return
+	}
+	switch err {
+	case nil:
+		s.disconnectClosingSession(false)
+	default:
+		if stmErr, ok := err.(*streamerror.Error); ok {
+			s.disconnectWithStreamError(stmErr)
+		} else {
+			log.Error(err)
+			s.disconnectClosingSession(false)
+		}
+	}
+}
+
+func (s *inStream) disconnectWithStreamError(err *streamerror.Error) {
+	if s.getState() == connecting {
+		s.sess.Open()
+	}
+	s.writeElement(err.Element())
+	s.disconnectClosingSession(true)
+}
+
+func (s *inStream) disconnectClosingSession(closeSession bool) {
+	if closeSession {
+		s.sess.Close()
+	}
+	compContainer.unregister(s)
+
+	s.setState(disconnected)
+	s.cfg.transport.Close()
+}
+
+func (s *inStream) restartSession() {
+	j, _ := jid.New("", "", "", true)
+	s.sess = session.New(s.id, &session.Config{
+		JID:           j,
+		Transport:     s.cfg.transport,
+		MaxStanzaSize: s.cfg.maxStanzaSize,
+		IsComponent:   true,
+	})
+	s.setState(connecting)
+}
+
+func (s *inStream) setState(state uint32) {
+	atomic.StoreUint32(&s.state, state)
+}
+
+func (s *inStream) getState() uint32 {
+	return atomic.LoadUint32(&s.state)
+}
+
+var inStreamCounter uint64
+
+func nextInID() string {
+	return fmt.Sprintf("component:in:%d", atomic.AddUint64(&inStreamCounter, 1))
+}
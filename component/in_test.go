@@ -0,0 +1,206 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package component
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/transport"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStream_ConnectTimeout(t *testing.T) {
+	stm, _ := tUtilInStreamInit()
+	time.Sleep(time.Millisecond * 1500)
+	require.Equal(t, disconnected, stm.getState())
+}
+
+func TestStream_Disconnect(t *testing.T) {
+	stm, conn := tUtilInStreamInit()
+	stm.Disconnect(nil)
+	require.True(t, conn.waitClose())
+
+	require.Equal(t, disconnected, stm.getState())
+}
+
+func TestStream_HostUnknown(t *testing.T) {
+	tUtilComponentInitialize()
+	defer tUtilComponentShutdown()
+
+	stm, conn := tUtilInStreamInit()
+	tUtilInStreamOpen(conn, "foo.jackal.im")
+	require.True(t, conn.waitClose())
+	require.Equal(t, disconnected, stm.getState())
+}
+
+func TestStream_Handshake(t *testing.T) {
+	tUtilComponentInitialize()
+	defer tUtilComponentShutdown()
+
+	// wrong secret
+	stm, conn := tUtilInStreamInit()
+	tUtilInStreamOpen(conn, "bot.jackal.im")
+
+	elem := conn.outboundRead()
+	require.Equal(t, "stream:stream", elem.Name())
+	require.Equal(t, "jabber:component:accept", elem.Namespace())
+	require.Equal(t, "bot.jackal.im", elem.From())
+	require.Equal(t, handshaking, stm.getState())
+
+	conn.inboundWriteString(fmt.Sprintf("<handshake>%s</handshake>", tUtilHandshake(elem.ID(), "foo")))
+	require.True(t, conn.waitClose())
+	require.Equal(t, disconnected, stm.getState())
+
+	// successful handshake
+	stm, conn = tUtilInStreamInit()
+	tUtilInStreamOpen(conn, "bot.jackal.im")
+
+	elem = conn.outboundRead()
+	conn.inboundWriteString(fmt.Sprintf("<handshake>%s</handshake>", tUtilHandshake(elem.ID(), "s3cr3t")))
+	elem = conn.outboundRead()
+	require.Equal(t, "handshake", elem.Name())
+	require.Equal(t, authenticated, stm.getState())
+	require.Equal(t, stm, GetComponent("bot.jackal.im"))
+
+	// domain already connected
+	stm2, conn2 := tUtilInStreamInit()
+	tUtilInStreamOpen(conn2, "bot.jackal.im")
+
+	elem = conn2.outboundRead()
+	conn2.inboundWriteString(fmt.Sprintf("<handshake>%s</handshake>", tUtilHandshake(elem.ID(), "s3cr3t")))
+	require.True(t, conn2.waitClose())
+	require.Equal(t, disconnected, stm2.getState())
+
+	require.Equal(t, stm, GetComponent("bot.jackal.im"))
+
+	stm.Disconnect(nil)
+	require.True(t, conn.waitClose())
+	require.Nil(t, GetComponent("bot.jackal.im"))
+}
+
+func TestStream_RouteStanzas(t *testing.T) {
+	tUtilComponentInitialize()
+	defer tUtilComponentShutdown()
+
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	router.Initialize(&router.Config{GetComponent: GetComponent})
+	defer func() {
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	stm, conn := tUtilInStreamInit()
+	tUtilInStreamAuthenticate(conn, "bot.jackal.im", "s3cr3t")
+
+	// component -> local user
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	c2sStm := stream.NewMockC2S(uuid.New(), j1)
+	defer c2sStm.Disconnect(nil)
+	router.Bind(c2sStm)
+
+	conn.inboundWriteString(`<message xmlns="jabber:component:accept" id="m1" type="chat" from="weather@bot.jackal.im" to="ortuman@jackal.im/balcony"><body>sunny</body></message>`)
+	elem := c2sStm.FetchElement()
+	require.Equal(t, "message", elem.Name())
+	require.Equal(t, "m1", elem.ID())
+	require.Equal(t, "weather@bot.jackal.im", elem.From())
+
+	// not existing user
+	conn.inboundWriteString(`<iq xmlns="jabber:component:accept" id="i1" type="get" from="bot.jackal.im" to="noelia@jackal.im/yard"><query xmlns="jabber:iq:version"/></iq>`)
+	elem = conn.outboundRead()
+	require.Equal(t, "iq", elem.Name())
+	require.Equal(t, xml.ErrorType, elem.Type())
+	require.NotNil(t, elem.Elements().Child("error").Elements().Child("service-unavailable"))
+
+	// local user -> component
+	msg := xml.NewMessageType("m2", xml.ChatType)
+	msg.SetFromJID(j1)
+	j2, _ := jid.New("weather", "bot.jackal.im", "", true)
+	msg.SetToJID(j2)
+	require.Nil(t, router.Route(msg))
+
+	elem = conn.outboundRead()
+	require.Equal(t, "message", elem.Name())
+	require.Equal(t, "m2", elem.ID())
+
+	// spoofed 'from'
+	conn.inboundWriteString(`<message xmlns="jabber:component:accept" id="m3" from="romeo@example.org" to="ortuman@jackal.im/balcony"><body>hi</body></message>`)
+	require.True(t, conn.waitClose())
+	require.Equal(t, disconnected, stm.getState())
+
+	// local account impersonation
+	stm, conn = tUtilInStreamInit()
+	tUtilInStreamAuthenticate(conn, "bot.jackal.im", "s3cr3t")
+
+	conn.inboundWriteString(`<presence xmlns="jabber:component:accept" type="subscribe" from="noelia@jackal.im" to="ortuman@jackal.im"/>`)
+	require.True(t, conn.waitClose())
+	require.Equal(t, disconnected, stm.getState())
+}
+
+func TestStream_ValidFrom(t *testing.T) {
+	stm := &inStream{domain: "bot.jackal.im"}
+
+	j1, _ := jid.NewWithString("weather@bot.jackal.im/sensor", true)
+	j2, _ := jid.NewWithString("rss.bot.jackal.im", true)
+	j3, _ := jid.NewWithString("alice@jackal.im", true)
+	j4, _ := jid.NewWithString("evilbot.jackal.im", true)
+	require.True(t, stm.isValidFrom(j1))
+	require.True(t, stm.isValidFrom(j2))
+	require.False(t, stm.isValidFrom(j3))
+	require.False(t, stm.isValidFrom(j4))
+}
+
+func tUtilComponentInitialize() {
+	secrets = map[string]string{"bot.jackal.im": "s3cr3t"}
+	domains = []string{"bot.jackal.im"}
+}
+
+func tUtilComponentShutdown() {
+	secrets = nil
+	domains = nil
+}
+
+func tUtilHandshake(streamID, secret string) string {
+	h := sha1.Sum([]byte(streamID + secret))
+	return hex.EncodeToString(h[:])
+}
+
+func tUtilInStreamInit() (*inStream, *fakeSocketConn) {
+	conn := newFakeSocketConn()
+	tr := transport.NewSocketTransport(conn, 4096)
+	stm := newInStream(&streamConfig{
+		transport:      tr,
+		connectTimeout: time.Second,
+		maxStanzaSize:  8192,
+	})
+	return stm, conn
+}
+
+func tUtilInStreamOpen(conn *fakeSocketConn, domain string) {
+	s := `<?xml version="1.0"?>
+	<stream:stream xmlns:stream="http://etherx.jabber.org/streams" xmlns="jabber:component:accept" to="%s">
+`
+	conn.inboundWriteString(fmt.Sprintf(s, domain))
+}
+
+func tUtilInStreamAuthenticate(conn *fakeSocketConn, domain, secret string) {
+	tUtilInStreamOpen(conn, domain)
+	elem := conn.outboundRead()
+	conn.inboundWriteString(fmt.Sprintf("<handshake>%s</handshake>", tUtilHandshake(elem.ID(), secret)))
+	_ = conn.outboundRead() // read handshake...
+}
@@ -0,0 +1,52 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package component
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestComponentSocketServer(t *testing.T) {
+	errCh := make(chan error)
+	cfg := Config{
+		ConnectTimeout: time.Second * time.Duration(5),
+		MaxStanzaSize:  8192,
+		Transport: TransportConfig{
+			Port:      12780,
+			KeepAlive: time.Duration(600) * time.Second,
+		},
+		Hosts: []HostConfig{{Name: "bot.jackal.im", Secret: "s3cr3t"}},
+	}
+	go Initialize(&cfg)
+
+	go func() {
+		time.Sleep(time.Millisecond * 150)
+
+		// test component port...
+		conn, err := net.Dial("tcp", "127.0.0.1:12780")
+		if err != nil {
+			errCh <- err
+			return
+		}
+		xmlHdr := []byte(`<?xml version="1.0" encoding="UTF-8">`)
+		_, err = conn.Write(xmlHdr)
+		if err != nil {
+			errCh <- err
+			return
+		}
+
+		time.Sleep(time.Millisecond * 150) // wait until disconnected
+
+		Shutdown()
+		errCh <- nil
+	}()
+	err := <-errCh
+	require.Nil(t, err)
+}
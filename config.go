@@ -9,7 +9,10 @@ import (
 	"bytes"
 	"io/ioutil"
 
+	"github.com/ortuman/jackal/admin"
+	"github.com/ortuman/jackal/auth"
 	"github.com/ortuman/jackal/c2s"
+	"github.com/ortuman/jackal/component"
 	"github.com/ortuman/jackal/host"
 	"github.com/ortuman/jackal/log"
 	"github.com/ortuman/jackal/module"
@@ -31,14 +34,17 @@ type TLSConfig struct {
 
 // Config represents a global configuration.
 type Config struct {
-	PIDFile      string         `yaml:"pid_path"`
-	Debug        DebugConfig    `yaml:"debug"`
-	Logger       log.Config     `yaml:"logger"`
-	Storage      storage.Config `yaml:"storage"`
-	Hosts        []host.Config  `yaml:"hosts"`
-	Modules      module.Config  `yaml:"modules"`
-	VirtualHosts []c2s.Config   `yaml:"virtual_hosts"`
-	S2S          s2s.Config     `yaml:"s2s"`
+	PIDFile      string           `yaml:"pid_path"`
+	Debug        DebugConfig      `yaml:"debug"`
+	Admin        admin.Config     `yaml:"admin"`
+	Logger       log.Config       `yaml:"logger"`
+	Storage      storage.Config   `yaml:"storage"`
+	Auth         auth.Config      `yaml:"auth"`
+	Hosts        []host.Config    `yaml:"hosts"`
+	Modules      module.Config    `yaml:"modules"`
+	VirtualHosts []c2s.Config     `yaml:"virtual_hosts"`
+	S2S          s2s.Config       `yaml:"s2s"`
+	Components   component.Config `yaml:"components"`
 }
 
 // FromFile loads default global configuration from
@@ -56,3 +62,14 @@ func (cfg *Config) FromFile(configFile string) error {
 func (cfg *Config) FromBuffer(buf *bytes.Buffer) error {
 	return yaml.Unmarshal(buf.Bytes(), cfg)
 }
+
+// isLocalHost returns true if domain matches one of the
+// configured hosts.
+func (cfg *Config) isLocalHost(domain string) bool {
+	for _, h := range cfg.Hosts {
+		if h.Name == domain {
+			return true
+		}
+	}
+	return false
+}
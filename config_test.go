@@ -10,6 +10,7 @@ import (
 	"io/ioutil"
 	"testing"
 
+	"github.com/ortuman/jackal/host"
 	"github.com/stretchr/testify/require"
 )
 
@@ -28,3 +29,10 @@ func TestBadConfigFile(t *testing.T) {
 	err := cfg.FromFile("./testdata/not_a_config.yml")
 	require.NotNil(t, err)
 }
+
+func TestConfigLocalHost(t *testing.T) {
+	cfg := Config{Hosts: []host.Config{{Name: "jackal.im"}, {Name: "example.org"}}}
+	require.True(t, cfg.isLocalHost("jackal.im"))
+	require.True(t, cfg.isLocalHost("example.org"))
+	require.False(t, cfg.isLocalHost("jabber.org"))
+}
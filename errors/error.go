@@ -18,6 +18,9 @@ var (
 	// ErrInvalidXML represents 'invalid-xml' stream error.
 	ErrInvalidXML = newStreamError("invalid-xml")
 
+	// ErrConflict represents 'conflict' stream error.
+	ErrConflict = newStreamError("conflict")
+
 	// ErrInvalidNamespace represents 'invalid-namespace' stream error.
 	ErrInvalidNamespace = newStreamError("invalid-namespace")
 
@@ -15,6 +15,9 @@ func TestStreamError(t *testing.T) {
 	require.Equal(t, "invalid-xml", ErrInvalidXML.Error())
 	require.Equal(t, "invalid-xml", ErrInvalidXML.Element().Elements().All()[0].Name())
 
+	require.Equal(t, "conflict", ErrConflict.Error())
+	require.Equal(t, "conflict", ErrConflict.Element().Elements().All()[0].Name())
+
 	require.Equal(t, "invalid-namespace", ErrInvalidNamespace.Error())
 	require.Equal(t, "invalid-namespace", ErrInvalidNamespace.Element().Elements().All()[0].Name())
 
@@ -7,7 +7,10 @@ package host
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	"log"
+	"sort"
 	"sync"
 
 	"github.com/ortuman/jackal/util"
@@ -18,6 +21,7 @@ const defaultDomain = "localhost"
 var (
 	instMu      sync.RWMutex
 	hosts       = make(map[string]tls.Certificate)
+	defaultHost string
 	initialized bool
 )
 
@@ -30,14 +34,21 @@ func Initialize(configurations []Config) {
 	}
 	if len(configurations) > 0 {
 		for _, h := range configurations {
-			hosts[h.Name] = h.Certificate
+			cer := h.Certificate
+			if len(cer.Certificate) > 0 && cer.Leaf == nil {
+				cer.Leaf, _ = x509.ParseCertificate(cer.Certificate[0])
+			}
+			hosts[h.Name] = cer
 		}
+		defaultHost = configurations[0].Name
 	} else {
 		cer, err := util.LoadCertificate("", "", defaultDomain)
 		if err != nil {
 			log.Fatalf("%v", err)
 		}
+		cer.Leaf, _ = x509.ParseCertificate(cer.Certificate[0])
 		hosts[defaultDomain] = cer
+		defaultHost = defaultDomain
 	}
 	initialized = true
 }
@@ -48,6 +59,7 @@ func Shutdown() {
 	defer instMu.Unlock()
 	if initialized {
 		hosts = make(map[string]tls.Certificate)
+		defaultHost = ""
 		initialized = false
 	}
 }
@@ -60,6 +72,20 @@ func IsLocalHost(domain string) bool {
 	return ok
 }
 
+// HostNames returns the sorted list of all configured local domains.
+func HostNames() []string {
+	instMu.RLock()
+	defer instMu.RUnlock()
+	return sortedHostNames()
+}
+
+// DefaultHostName returns the first configured local domain.
+func DefaultHostName() string {
+	instMu.RLock()
+	defer instMu.RUnlock()
+	return defaultHost
+}
+
 // Certificates returns an array of all configured domain certificates.
 func Certificates() []tls.Certificate {
 	instMu.RLock()
@@ -70,3 +96,73 @@ func Certificates() []tls.Certificate {
 	}
 	return certs
 }
+
+// HasCertificate returns whether or not a local domain
+// has an associated certificate.
+func HasCertificate(domain string) bool {
+	_, ok := Certificate(domain)
+	return ok
+}
+
+// Certificate returns the certificate associated to a local domain.
+func Certificate(domain string) (tls.Certificate, bool) {
+	instMu.RLock()
+	defer instMu.RUnlock()
+	cer, ok := hosts[domain]
+	if !ok || len(cer.Certificate) == 0 {
+		return tls.Certificate{}, false
+	}
+	return cer, true
+}
+
+// GetCertificate returns a tls.Config GetCertificate callback that selects
+// a certificate based on the SNI server name requested by the client.
+//
+// Certificates are selected in the following order:
+//  1. Certificate of the local domain matching the server name.
+//  2. Any local certificate valid for the server name (wildcard or multi-domain certificates).
+//  3. Certificate of the fallback domain, or the default (first configured) host
+//     if no fallback domain was specified.
+func GetCertificate(fallbackDomain string) func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		instMu.RLock()
+		defer instMu.RUnlock()
+		if sn := hello.ServerName; len(sn) > 0 {
+			if cer, ok := hosts[sn]; ok && len(cer.Certificate) > 0 {
+				return &cer, nil
+			}
+			for _, name := range sortedHostNames() {
+				cer := hosts[name]
+				if cer.Leaf != nil && cer.Leaf.VerifyHostname(sn) == nil {
+					return &cer, nil
+				}
+			}
+		}
+		domain := fallbackDomain
+		if len(domain) == 0 {
+			domain = defaultHost
+		}
+		if cer, ok := hosts[domain]; ok && len(cer.Certificate) > 0 {
+			return &cer, nil
+		}
+		return nil, fmt.Errorf("host: no certificate available for '%s'", hello.ServerName)
+	}
+}
+
+// GetClientCertificate returns a tls.Config GetClientCertificate callback
+// that presents the certificate associated to a local domain.
+func GetClientCertificate(domain string) func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return func(_ *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		cer, _ := Certificate(domain)
+		return &cer, nil // an empty certificate means no client authentication
+	}
+}
+
+func sortedHostNames() []string {
+	var names []string
+	for name := range hosts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -6,8 +6,16 @@
 package host
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/ortuman/jackal/util"
 	"github.com/stretchr/testify/require"
@@ -17,6 +25,7 @@ func TestHostInitialize(t *testing.T) {
 	Initialize(nil)
 	require.True(t, IsLocalHost("localhost"))
 	require.False(t, IsLocalHost("jackal.im"))
+	require.Equal(t, "localhost", DefaultHostName())
 	os.RemoveAll("./.cert")
 	Shutdown()
 
@@ -25,6 +34,11 @@ func TestHostInitialize(t *testing.T) {
 	require.True(t, IsLocalHost("jackal.im"))
 	Shutdown()
 
+	Initialize([]Config{{Name: "jackal.im"}, {Name: "example.org"}})
+	require.Equal(t, []string{"example.org", "jackal.im"}, HostNames())
+	require.Equal(t, "jackal.im", DefaultHostName())
+	Shutdown()
+
 	privKeyFile := "../testdata/cert/test.server.key"
 	certFile := "../testdata/cert/test.server.crt"
 	cer, err := util.LoadCertificate(privKeyFile, certFile, "localhost")
@@ -32,4 +46,76 @@ func TestHostInitialize(t *testing.T) {
 
 	Initialize([]Config{{Name: "localhost", Certificate: cer}})
 	require.Equal(t, 1, len(Certificates()))
+	Shutdown()
+}
+
+func TestHostCertificateSelection(t *testing.T) {
+	localCer := tUtilCertificate(t, "localhost")
+	jackalCer := tUtilCertificate(t, "jackal.im", "*.jackal.im")
+
+	Initialize([]Config{
+		{Name: "localhost", Certificate: localCer},
+		{Name: "jackal.im", Certificate: jackalCer},
+		{Name: "example.org"},
+	})
+	defer Shutdown()
+
+	require.True(t, HasCertificate("localhost"))
+	require.True(t, HasCertificate("jackal.im"))
+	require.False(t, HasCertificate("example.org"))
+	require.False(t, HasCertificate("unknown.org"))
+
+	getCertificate := GetCertificate("")
+
+	// exact match
+	cer, err := getCertificate(&tls.ClientHelloInfo{ServerName: "jackal.im"})
+	require.Nil(t, err)
+	require.Equal(t, jackalCer.Certificate, cer.Certificate)
+
+	// wildcard match
+	cer, err = getCertificate(&tls.ClientHelloInfo{ServerName: "conference.jackal.im"})
+	require.Nil(t, err)
+	require.Equal(t, jackalCer.Certificate, cer.Certificate)
+
+	// default host fallback
+	cer, err = getCertificate(&tls.ClientHelloInfo{ServerName: "example.org"})
+	require.Nil(t, err)
+	require.Equal(t, localCer.Certificate, cer.Certificate)
+
+	cer, err = getCertificate(&tls.ClientHelloInfo{})
+	require.Nil(t, err)
+	require.Equal(t, localCer.Certificate, cer.Certificate)
+
+	// domain fallback
+	cer, err = GetCertificate("jackal.im")(&tls.ClientHelloInfo{})
+	require.Nil(t, err)
+	require.Equal(t, jackalCer.Certificate, cer.Certificate)
+
+	_, err = GetCertificate("example.org")(&tls.ClientHelloInfo{ServerName: "example.org"})
+	require.NotNil(t, err)
+
+	// client certificates
+	cer, err = GetClientCertificate("jackal.im")(&tls.CertificateRequestInfo{})
+	require.Nil(t, err)
+	require.Equal(t, jackalCer.Certificate, cer.Certificate)
+
+	cer, err = GetClientCertificate("example.org")(&tls.CertificateRequestInfo{})
+	require.Nil(t, err)
+	require.Equal(t, 0, len(cer.Certificate))
+}
+
+func tUtilCertificate(t *testing.T, dnsNames ...string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.Nil(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
 }
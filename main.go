@@ -10,17 +10,43 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/http/pprof"
 	"os"
 	"path/filepath"
 	"strconv"
 
+	"github.com/ortuman/jackal/admin"
+	"github.com/ortuman/jackal/auth"
 	"github.com/ortuman/jackal/c2s"
+	"github.com/ortuman/jackal/component"
 	"github.com/ortuman/jackal/host"
 	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/module"
 	"github.com/ortuman/jackal/router"
 	"github.com/ortuman/jackal/s2s"
 	"github.com/ortuman/jackal/storage"
 	"github.com/ortuman/jackal/version"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	// built-in modules
+	_ "github.com/ortuman/jackal/module/offline"
+	_ "github.com/ortuman/jackal/module/roster"
+	_ "github.com/ortuman/jackal/module/token"
+	_ "github.com/ortuman/jackal/module/xep0012"
+	_ "github.com/ortuman/jackal/module/xep0016"
+	_ "github.com/ortuman/jackal/module/xep0045"
+	_ "github.com/ortuman/jackal/module/xep0049"
+	_ "github.com/ortuman/jackal/module/xep0050"
+	_ "github.com/ortuman/jackal/module/xep0054"
+	_ "github.com/ortuman/jackal/module/xep0060"
+	_ "github.com/ortuman/jackal/module/xep0077"
+	_ "github.com/ortuman/jackal/module/xep0092"
+	_ "github.com/ortuman/jackal/module/xep0133"
+	_ "github.com/ortuman/jackal/module/xep0191"
+	_ "github.com/ortuman/jackal/module/xep0199"
+	_ "github.com/ortuman/jackal/module/xep0280"
+	_ "github.com/ortuman/jackal/module/xep0313"
+	_ "github.com/ortuman/jackal/module/xep0357"
 )
 
 var logoStr = []string{
@@ -37,6 +63,8 @@ Usage: jackal [options]
 
 Server Options:
     -c, --config <file>    Configuration file path
+    --scram-migrate        Convert stored plaintext passwords into SCRAM credentials and exit
+    --domain-migrate <dom> Bind stored single-domain data to the given local domain and exit
 Common Options:
     -h, --help             Show this message
     -v, --version          Show version
@@ -46,6 +74,8 @@ func main() {
 	var configFile string
 	var showVersion bool
 	var showUsage bool
+	var scramMigrate bool
+	var domainMigrate string
 
 	flag.BoolVar(&showUsage, "help", false, "Show this message")
 	flag.BoolVar(&showUsage, "h", false, "Show this message")
@@ -53,6 +83,8 @@ func main() {
 	flag.BoolVar(&showVersion, "v", false, "Print version information.")
 	flag.StringVar(&configFile, "config", "/etc/jackal/jackal.yml", "Configuration file path.")
 	flag.StringVar(&configFile, "c", "/etc/jackal/jackal.yml", "Configuration file path.")
+	flag.BoolVar(&scramMigrate, "scram-migrate", false, "Convert stored plaintext passwords into SCRAM credentials.")
+	flag.StringVar(&domainMigrate, "domain-migrate", "", "Bind stored single-domain data to the given local domain.")
 	flag.Usage = func() {
 		for i := range logoStr {
 			fmt.Fprintf(os.Stdout, "%s\n", logoStr[i])
@@ -86,10 +118,45 @@ func main() {
 	log.Initialize(&cfg.Logger)
 
 	storage.Initialize(&cfg.Storage)
+	if err := auth.Initialize(&cfg.Auth); err != nil {
+		fmt.Fprintf(os.Stderr, "jackal: %v\n", err)
+		return
+	}
+
+	// migrate plaintext user passwords
+	if scramMigrate {
+		count, err := auth.MigrateScramCredentials()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "jackal: %v\n", err)
+			return
+		}
+		fmt.Fprintf(os.Stdout, "jackal: %d user(s) migrated\n", count)
+		return
+	}
+	// migrate single-domain storage keys
+	if len(domainMigrate) > 0 {
+		if !cfg.isLocalHost(domainMigrate) {
+			fmt.Fprintf(os.Stderr, "jackal: %s is not a configured virtual host\n", domainMigrate)
+			return
+		}
+		count, err := storage.Instance().MigrateDomain(domainMigrate)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "jackal: %v\n", err)
+			return
+		}
+		fmt.Fprintf(os.Stdout, "jackal: %d user(s) migrated\n", count)
+		return
+	}
 
 	host.Initialize(cfg.Hosts)
 
-	router.Initialize(&router.Config{GetS2SOut: s2s.GetS2SOut})
+	router.Initialize(&router.Config{
+		GetS2SOut:    s2s.GetS2SOut,
+		GetComponent: component.GetComponent,
+	})
+
+	// instantiate enabled modules for every local host
+	module.Initialize(&cfg.Modules)
 
 	// create PID file
 	if err := createPIDFile(cfg.PIDFile); err != nil {
@@ -103,19 +170,33 @@ func main() {
 	log.Infof("jackal %v\n", version.ApplicationVersion)
 
 	if cfg.Debug.Port > 0 {
-		go initDebugServer(cfg.Debug.Port)
+		// serve metrics and admin API along with debug handlers
+		mux := http.NewServeMux()
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		mux.Handle("/metrics", promhttp.Handler())
+		if cfg.Admin.Enabled() {
+			mux.Handle(admin.PathPrefix, admin.New(&cfg.Admin))
+		}
+		go initDebugServer(cfg.Debug.Port, mux)
 	}
 	// start serving s2s...
-	s2s.Initialize(&cfg.S2S, &cfg.Modules)
+	s2s.Initialize(&cfg.S2S)
+
+	// start serving external components...
+	component.Initialize(&cfg.Components)
 
 	// start serving c2s...
-	c2s.Initialize(cfg.VirtualHosts, &cfg.Modules)
+	c2s.Initialize(cfg.VirtualHosts)
 }
 
 var debugSrv *http.Server
 
-func initDebugServer(port int) {
-	debugSrv = &http.Server{}
+func initDebugServer(port int, handler http.Handler) {
+	debugSrv = &http.Server{Handler: handler}
 	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
 	if err != nil {
 		log.Fatalf("%v", err)
@@ -0,0 +1,49 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package model
+
+import (
+	"encoding/gob"
+	"time"
+)
+
+// AuthToken represents a login token storage entity issued
+// to a user device. Only token hex encoded SHA-256 hash is stored.
+type AuthToken struct {
+	Username  string
+	Domain    string
+	Device    string
+	Hash      string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+// IsExpired returns whether or not token has expired.
+func (t *AuthToken) IsExpired() bool {
+	return !time.Now().Before(t.ExpiresAt)
+}
+
+// FromGob deserializes an AuthToken entity
+// from it's gob binary representation.
+func (t *AuthToken) FromGob(dec *gob.Decoder) {
+	dec.Decode(&t.Username)
+	dec.Decode(&t.Domain)
+	dec.Decode(&t.Device)
+	dec.Decode(&t.Hash)
+	dec.Decode(&t.ExpiresAt)
+	dec.Decode(&t.CreatedAt)
+}
+
+// ToGob converts an AuthToken entity
+// to it's gob binary representation.
+func (t *AuthToken) ToGob(enc *gob.Encoder) {
+	enc.Encode(&t.Username)
+	enc.Encode(&t.Domain)
+	enc.Encode(&t.Device)
+	enc.Encode(&t.Hash)
+	enc.Encode(&t.ExpiresAt)
+	enc.Encode(&t.CreatedAt)
+}
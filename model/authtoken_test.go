@@ -0,0 +1,40 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package model
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthToken(t *testing.T) {
+	var t1, t2 AuthToken
+	t1 = AuthToken{
+		Username:  "ortuman",
+		Domain:    "jackal.im",
+		Device:    "balcony",
+		Hash:      "a1b2c3",
+		ExpiresAt: time.Now().Add(time.Hour).UTC(),
+		CreatedAt: time.Now().UTC(),
+	}
+	buf := new(bytes.Buffer)
+	t1.ToGob(gob.NewEncoder(buf))
+	t2.FromGob(gob.NewDecoder(buf))
+	require.Equal(t, t1.Username, t2.Username)
+	require.Equal(t, t1.Domain, t2.Domain)
+	require.Equal(t, t1.Device, t2.Device)
+	require.Equal(t, t1.Hash, t2.Hash)
+	require.True(t, t1.ExpiresAt.Equal(t2.ExpiresAt))
+	require.True(t, t1.CreatedAt.Equal(t2.CreatedAt))
+
+	require.False(t, t1.IsExpired())
+	t1.ExpiresAt = time.Now().Add(-time.Second)
+	require.True(t, t1.IsExpired())
+}
@@ -10,6 +10,7 @@ import "encoding/gob"
 // BlockListItem represents block list item storage entity.
 type BlockListItem struct {
 	Username string
+	Domain   string
 	JID      string
 }
 
@@ -18,6 +19,7 @@ type BlockListItem struct {
 func (bli *BlockListItem) FromGob(dec *gob.Decoder) {
 	dec.Decode(&bli.Username)
 	dec.Decode(&bli.JID)
+	dec.Decode(&bli.Domain)
 }
 
 // ToGob converts a BlockListItem entity
@@ -25,4 +27,5 @@ func (bli *BlockListItem) FromGob(dec *gob.Decoder) {
 func (bli *BlockListItem) ToGob(enc *gob.Encoder) {
 	enc.Encode(&bli.Username)
 	enc.Encode(&bli.JID)
+	enc.Encode(&bli.Domain)
 }
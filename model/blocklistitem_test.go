@@ -15,7 +15,7 @@ import (
 
 func TestBlockListItem(t *testing.T) {
 	var bi1, bi2 BlockListItem
-	bi1 = BlockListItem{"ortuman", "romeo@example.net"}
+	bi1 = BlockListItem{Username: "ortuman", Domain: "jackal.im", JID: "romeo@example.net"}
 	buf := new(bytes.Buffer)
 	bi1.ToGob(gob.NewEncoder(buf))
 	bi2.FromGob(gob.NewDecoder(buf))
@@ -0,0 +1,70 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package mammodel
+
+import (
+	"encoding/gob"
+	"time"
+
+	"github.com/ortuman/jackal/xml"
+)
+
+// Message represents an archived message storage entity.
+type Message struct {
+	ID        string
+	Username  string
+	Domain    string
+	JID       string
+	Message   xml.XElement
+	CreatedAt time.Time
+}
+
+// FromGob deserializes a Message entity from it's gob binary representation.
+func (m *Message) FromGob(dec *gob.Decoder) {
+	dec.Decode(&m.ID)
+	dec.Decode(&m.Username)
+	dec.Decode(&m.JID)
+	el := &xml.Element{}
+	el.FromGob(dec)
+	m.Message = el
+	dec.Decode(&m.CreatedAt)
+	dec.Decode(&m.Domain)
+}
+
+// ToGob converts a Message entity to it's gob binary representation.
+func (m *Message) ToGob(enc *gob.Encoder) {
+	enc.Encode(&m.ID)
+	enc.Encode(&m.Username)
+	enc.Encode(&m.JID)
+	xml.NewElementFromElement(m.Message).ToGob(enc)
+	enc.Encode(&m.CreatedAt)
+	enc.Encode(&m.Domain)
+}
+
+// Filter represents a set of archive query constraints.
+type Filter struct {
+	With  string
+	Start time.Time
+	End   time.Time
+}
+
+// Matches returns whether or not an archived message
+// satisfies filter constraints.
+func (f *Filter) Matches(m *Message) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.With) > 0 && f.With != m.JID {
+		return false
+	}
+	if !f.Start.IsZero() && m.CreatedAt.Before(f.Start) {
+		return false
+	}
+	if !f.End.IsZero() && m.CreatedAt.After(f.End) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package mammodel
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/xml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageGob(t *testing.T) {
+	msg := xml.NewElementName("message")
+	body := xml.NewElementName("body")
+	body.SetText("Hi!")
+	msg.AppendElement(body)
+
+	m1 := Message{
+		ID:        "1234",
+		Username:  "ortuman",
+		Domain:    "jackal.im",
+		JID:       "noelia@jackal.im",
+		Message:   msg,
+		CreatedAt: time.Now().UTC(),
+	}
+	buf := new(bytes.Buffer)
+	m1.ToGob(gob.NewEncoder(buf))
+	var m2 Message
+	m2.FromGob(gob.NewDecoder(buf))
+	require.Equal(t, m1.ID, m2.ID)
+	require.Equal(t, m1.Username, m2.Username)
+	require.Equal(t, m1.Domain, m2.Domain)
+	require.Equal(t, m1.JID, m2.JID)
+	require.Equal(t, m1.Message.String(), m2.Message.String())
+	require.True(t, m1.CreatedAt.Equal(m2.CreatedAt))
+}
+
+func TestFilterMatches(t *testing.T) {
+	now := time.Now()
+	m := &Message{JID: "noelia@jackal.im", CreatedAt: now}
+
+	var f *Filter
+	require.True(t, f.Matches(m))
+
+	f = &Filter{With: "romeo@jackal.im"}
+	require.False(t, f.Matches(m))
+
+	f = &Filter{With: "noelia@jackal.im", Start: now.Add(-time.Minute), End: now.Add(time.Minute)}
+	require.True(t, f.Matches(m))
+
+	f = &Filter{Start: now.Add(time.Second)}
+	require.False(t, f.Matches(m))
+
+	f = &Filter{End: now.Add(-time.Second)}
+	require.False(t, f.Matches(m))
+}
@@ -0,0 +1,137 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package mammodel
+
+import "errors"
+
+// ErrItemNotFound will be returned by storage if the requested
+// page 'after' or 'before' message does not exist.
+var ErrItemNotFound = errors.New("mammodel: item not found")
+
+// Page represents an archive result set page request (XEP-0059).
+type Page struct {
+	// After selects messages archived after the one with this identifier.
+	After string
+
+	// Before selects messages archived before the one with this identifier.
+	Before string
+
+	// Index selects messages from this position on, in case no After is set.
+	Index int
+
+	// Last selects the last page of messages.
+	Last bool
+
+	// Max limits the number of selected messages. A negative value means no limit.
+	Max int
+}
+
+// Backwards returns whether or not page should be filled
+// starting from the end of the requested window.
+func (p *Page) Backwards() bool {
+	return p.Last || len(p.Before) > 0
+}
+
+// Range narrows a [from, to) window of message positions
+// returning the range of the ones fitting into the page.
+func (p *Page) Range(from, to int) (int, int) {
+	if to < from {
+		to = from
+	}
+	if p.Max >= 0 && to-from > p.Max {
+		if p.Backwards() {
+			return to - p.Max, to
+		}
+		return from, from + p.Max
+	}
+	return from, to
+}
+
+// ResultSet represents a page of archived messages.
+type ResultSet struct {
+	// Messages contains page messages, in chronological order.
+	Messages []Message
+
+	// FirstIndex is the position of the first page message
+	// within the whole set of filter matching messages.
+	FirstIndex int
+
+	// Count is the number of filter matching messages.
+	Count int
+}
+
+// Complete returns whether or not page reaches the end
+// of the result set in the requested direction.
+func (rs *ResultSet) Complete(page *Page) bool {
+	if page != nil && page.Backwards() {
+		return rs.FirstIndex == 0
+	}
+	return rs.FirstIndex+len(rs.Messages) == rs.Count
+}
+
+// Pager selects a page of messages out of a chronologically ordered
+// sequence, holding no more than a page worth of them at a time.
+type Pager struct {
+	page *Page
+	rs   ResultSet
+	from int
+	to   int
+}
+
+// NewPager returns a pager for a page request.
+// A nil page selects every message.
+func NewPager(page *Page) *Pager {
+	if page == nil {
+		page = &Page{Max: -1}
+	}
+	p := &Pager{page: page, from: page.Index, to: -1}
+	if len(page.After) > 0 {
+		p.from = -1 // until 'after' message is found
+	}
+	return p
+}
+
+// Add feeds the pager with the next filter matching message.
+func (p *Pager) Add(m *Message) {
+	pos := p.rs.Count
+	p.rs.Count++
+
+	switch {
+	case len(p.page.After) > 0 && m.ID == p.page.After:
+		p.from = pos + 1
+		return
+	case len(p.page.Before) > 0 && m.ID == p.page.Before:
+		if p.to == -1 {
+			p.to = pos
+		}
+		return
+	}
+	if p.from == -1 || pos < p.from || p.to != -1 {
+		return // outside requested window
+	}
+	if p.page.Max >= 0 && len(p.rs.Messages) == p.page.Max {
+		if !p.page.Backwards() || p.page.Max == 0 {
+			return
+		}
+		p.rs.Messages = p.rs.Messages[1:]
+	}
+	p.rs.Messages = append(p.rs.Messages, *m)
+	p.rs.FirstIndex = pos - len(p.rs.Messages) + 1
+}
+
+// ResultSet returns the selected page of messages.
+func (p *Pager) ResultSet() (*ResultSet, error) {
+	if p.from == -1 || (len(p.page.Before) > 0 && p.to == -1) {
+		return nil, ErrItemNotFound
+	}
+	if len(p.rs.Messages) == 0 {
+		p.rs.FirstIndex = p.from
+		if p.rs.FirstIndex > p.rs.Count {
+			p.rs.FirstIndex = p.rs.Count
+		}
+	}
+	return &p.rs, nil
+}
@@ -0,0 +1,102 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package mammodel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPageRange(t *testing.T) {
+	from, to := (&Page{Max: -1}).Range(0, 5)
+	require.Equal(t, 0, from)
+	require.Equal(t, 5, to)
+
+	from, to = (&Page{Max: 2}).Range(1, 5)
+	require.Equal(t, 1, from)
+	require.Equal(t, 3, to)
+
+	from, to = (&Page{Max: 2, Last: true}).Range(0, 5)
+	require.Equal(t, 3, from)
+	require.Equal(t, 5, to)
+
+	from, to = (&Page{Max: 2}).Range(4, 2)
+	require.Equal(t, 4, from)
+	require.Equal(t, 4, to)
+}
+
+func TestPager(t *testing.T) {
+	ids := []string{"a", "b", "c", "d", "e"}
+
+	paginate := func(page *Page) (*ResultSet, []string, error) {
+		p := NewPager(page)
+		for _, id := range ids {
+			p.Add(&Message{ID: id})
+		}
+		rs, err := p.ResultSet()
+		if err != nil {
+			return nil, nil, err
+		}
+		var ret []string
+		for _, m := range rs.Messages {
+			ret = append(ret, m.ID)
+		}
+		return rs, ret, nil
+	}
+	rs, sel, err := paginate(nil)
+	require.Nil(t, err)
+	require.Equal(t, ids, sel)
+	require.Equal(t, 5, rs.Count)
+	require.True(t, rs.Complete(nil))
+
+	page := &Page{Max: 2}
+	rs, sel, _ = paginate(page)
+	require.Equal(t, []string{"a", "b"}, sel)
+	require.Equal(t, 0, rs.FirstIndex)
+	require.False(t, rs.Complete(page))
+
+	page = &Page{Max: 2, After: "b"}
+	rs, sel, _ = paginate(page)
+	require.Equal(t, []string{"c", "d"}, sel)
+	require.Equal(t, 2, rs.FirstIndex)
+
+	page = &Page{Max: 2, After: "c"}
+	rs, sel, _ = paginate(page)
+	require.Equal(t, []string{"d", "e"}, sel)
+	require.True(t, rs.Complete(page))
+
+	page = &Page{Max: 2, Last: true}
+	rs, sel, _ = paginate(page)
+	require.Equal(t, []string{"d", "e"}, sel)
+	require.Equal(t, 3, rs.FirstIndex)
+	require.False(t, rs.Complete(page))
+
+	page = &Page{Max: 2, Before: "d"}
+	rs, sel, _ = paginate(page)
+	require.Equal(t, []string{"b", "c"}, sel)
+	require.Equal(t, 1, rs.FirstIndex)
+
+	page = &Page{Max: 2, Before: "b"}
+	rs, sel, _ = paginate(page)
+	require.Equal(t, []string{"a"}, sel)
+	require.True(t, rs.Complete(page))
+
+	page = &Page{Max: 2, Index: 4}
+	rs, sel, _ = paginate(page)
+	require.Equal(t, []string{"e"}, sel)
+	require.Equal(t, 4, rs.FirstIndex)
+
+	// count only
+	rs, sel, _ = paginate(&Page{Max: 0})
+	require.Equal(t, 0, len(sel))
+	require.Equal(t, 5, rs.Count)
+
+	_, _, err = paginate(&Page{Max: 2, After: "z"})
+	require.Equal(t, ErrItemNotFound, err)
+	_, _, err = paginate(&Page{Max: 2, Before: "z"})
+	require.Equal(t, ErrItemNotFound, err)
+}
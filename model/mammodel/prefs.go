@@ -0,0 +1,42 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package mammodel
+
+import "encoding/gob"
+
+// archiving default behavior values
+const (
+	DefaultAlways = "always"
+	DefaultNever  = "never"
+	DefaultRoster = "roster"
+)
+
+// Prefs represents user's archiving preferences storage entity.
+type Prefs struct {
+	Username string
+	Domain   string
+	Default  string
+	Always   []string
+	Never    []string
+}
+
+// FromGob deserializes a Prefs entity from it's gob binary representation.
+func (p *Prefs) FromGob(dec *gob.Decoder) {
+	dec.Decode(&p.Username)
+	dec.Decode(&p.Default)
+	dec.Decode(&p.Always)
+	dec.Decode(&p.Never)
+	dec.Decode(&p.Domain)
+}
+
+// ToGob converts a Prefs entity to it's gob binary representation.
+func (p *Prefs) ToGob(enc *gob.Encoder) {
+	enc.Encode(&p.Username)
+	enc.Encode(&p.Default)
+	enc.Encode(&p.Always)
+	enc.Encode(&p.Never)
+	enc.Encode(&p.Domain)
+}
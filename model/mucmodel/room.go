@@ -0,0 +1,90 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package mucmodel
+
+import (
+	"encoding/gob"
+
+	"github.com/ortuman/jackal/xml/jid"
+)
+
+// room affiliation values
+const (
+	AffiliationOwner   = "owner"
+	AffiliationAdmin   = "admin"
+	AffiliationMember  = "member"
+	AffiliationOutcast = "outcast"
+	AffiliationNone    = "none"
+)
+
+// RoomConfig represents a room configuration.
+type RoomConfig struct {
+	Public        bool
+	Persistent    bool
+	MembersOnly   bool
+	Moderated     bool
+	NonAnonymous  bool
+	ChangeSubject bool
+	AllowInvites  bool
+	Password      string
+	MaxUsers      int
+}
+
+// Room represents a multi-user chat room storage entity.
+type Room struct {
+	JID          string
+	Name         string
+	Description  string
+	Subject      string
+	Config       RoomConfig
+	Affiliations map[string]string
+}
+
+// RoomJID parses and returns room JID.
+func (r *Room) RoomJID() *jid.JID {
+	j, _ := jid.NewWithString(r.JID, true)
+	return j
+}
+
+// Affiliation returns the affiliation associated to a given bare JID.
+func (r *Room) Affiliation(bareJID string) string {
+	if aff, ok := r.Affiliations[bareJID]; ok {
+		return aff
+	}
+	return AffiliationNone
+}
+
+// SetAffiliation sets the affiliation associated to a given bare JID.
+func (r *Room) SetAffiliation(bareJID, affiliation string) {
+	if affiliation == AffiliationNone {
+		delete(r.Affiliations, bareJID)
+		return
+	}
+	if r.Affiliations == nil {
+		r.Affiliations = make(map[string]string)
+	}
+	r.Affiliations[bareJID] = affiliation
+}
+
+// FromGob deserializes a Room entity from it's gob binary representation.
+func (r *Room) FromGob(dec *gob.Decoder) {
+	dec.Decode(&r.JID)
+	dec.Decode(&r.Name)
+	dec.Decode(&r.Description)
+	dec.Decode(&r.Subject)
+	dec.Decode(&r.Config)
+	dec.Decode(&r.Affiliations)
+}
+
+// ToGob converts a Room entity to it's gob binary representation.
+func (r *Room) ToGob(enc *gob.Encoder) {
+	enc.Encode(&r.JID)
+	enc.Encode(&r.Name)
+	enc.Encode(&r.Description)
+	enc.Encode(&r.Subject)
+	enc.Encode(&r.Config)
+	enc.Encode(&r.Affiliations)
+}
@@ -0,0 +1,48 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package mucmodel
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoomAffiliations(t *testing.T) {
+	r := Room{JID: "lobby@conference.jackal.im"}
+	require.Equal(t, AffiliationNone, r.Affiliation("ortuman@jackal.im"))
+
+	r.SetAffiliation("ortuman@jackal.im", AffiliationOwner)
+	require.Equal(t, AffiliationOwner, r.Affiliation("ortuman@jackal.im"))
+
+	r.SetAffiliation("ortuman@jackal.im", AffiliationNone)
+	require.Equal(t, AffiliationNone, r.Affiliation("ortuman@jackal.im"))
+	require.Equal(t, 0, len(r.Affiliations))
+
+	require.Equal(t, "lobby", r.RoomJID().Node())
+}
+
+func TestRoomGob(t *testing.T) {
+	r1 := Room{
+		JID:         "lobby@conference.jackal.im",
+		Name:        "Lobby",
+		Description: "A place to chat",
+		Subject:     "Welcome!",
+		Config: RoomConfig{
+			Public:     true,
+			Persistent: true,
+			MaxUsers:   50,
+		},
+		Affiliations: map[string]string{"ortuman@jackal.im": AffiliationOwner},
+	}
+	buf := new(bytes.Buffer)
+	r1.ToGob(gob.NewEncoder(buf))
+	var r2 Room
+	r2.FromGob(gob.NewDecoder(buf))
+	require.Equal(t, r1, r2)
+}
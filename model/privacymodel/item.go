@@ -0,0 +1,195 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package privacymodel
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/ortuman/jackal/model/rostermodel"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+)
+
+// privacy item type values
+const (
+	JIDType          = "jid"
+	GroupType        = "group"
+	SubscriptionType = "subscription"
+)
+
+// privacy item action values
+const (
+	Allow = "allow"
+	Deny  = "deny"
+)
+
+// privacy item stanza type values
+const (
+	Message     = "message"
+	IQ          = "iq"
+	PresenceIn  = "presence-in"
+	PresenceOut = "presence-out"
+)
+
+// Item represents a privacy list rule.
+type Item struct {
+	Type   string
+	Value  string
+	Action string
+	Order  int
+
+	// Stanzas contains the stanza types to which the rule applies.
+	// An empty set means that it applies to every stanza type.
+	Stanzas []string
+}
+
+// NewItem parses an XML element returning a derived privacy item instance.
+func NewItem(elem xml.XElement) (*Item, error) {
+	if elem.Name() != "item" {
+		return nil, fmt.Errorf("invalid item element name: %s", elem.Name())
+	}
+	it := &Item{}
+	attrs := elem.Attributes()
+
+	it.Action = attrs.Get("action")
+	switch it.Action {
+	case Allow, Deny:
+		break
+	default:
+		return nil, fmt.Errorf("unrecognized 'action' enum type: %s", it.Action)
+	}
+	order, err := strconv.ParseUint(attrs.Get("order"), 10, 32)
+	if err != nil {
+		return nil, errors.New("item 'order' attribute must be an unsigned integer")
+	}
+	it.Order = int(order)
+
+	it.Type = attrs.Get("type")
+	it.Value = attrs.Get("value")
+	switch it.Type {
+	case "":
+		if len(it.Value) > 0 {
+			return nil, errors.New("item 'value' attribute requires a 'type' attribute")
+		}
+	case JIDType:
+		j, err := jid.NewWithString(it.Value, false)
+		if err != nil {
+			return nil, err
+		}
+		it.Value = j.String()
+	case GroupType:
+		if len(it.Value) == 0 {
+			return nil, errors.New("item 'value' attribute is required")
+		}
+	case SubscriptionType:
+		switch it.Value {
+		case rostermodel.SubscriptionNone, rostermodel.SubscriptionFrom, rostermodel.SubscriptionTo, rostermodel.SubscriptionBoth:
+			break
+		default:
+			return nil, fmt.Errorf("unrecognized subscription 'value' enum type: %s", it.Value)
+		}
+	default:
+		return nil, fmt.Errorf("unrecognized 'type' enum type: %s", it.Type)
+	}
+	for _, st := range elem.Elements().All() {
+		switch st.Name() {
+		case Message, IQ, PresenceIn, PresenceOut:
+			it.Stanzas = append(it.Stanzas, st.Name())
+		default:
+			return nil, fmt.Errorf("unrecognized stanza type: %s", st.Name())
+		}
+	}
+	return it, nil
+}
+
+// Element returns a privacy item XML element representation.
+func (it *Item) Element() xml.XElement {
+	item := xml.NewElementName("item")
+	if len(it.Type) > 0 {
+		item.SetAttribute("type", it.Type)
+		item.SetAttribute("value", it.Value)
+	}
+	item.SetAttribute("action", it.Action)
+	item.SetAttribute("order", strconv.Itoa(it.Order))
+	for _, st := range it.Stanzas {
+		item.AppendElement(xml.NewElementName(st))
+	}
+	return item
+}
+
+// AppliesTo returns whether or not the rule applies to a given stanza type.
+// An empty stanza type is only matched by rules applying to every stanza type.
+func (it *Item) AppliesTo(stanzaType string) bool {
+	if len(it.Stanzas) == 0 {
+		return true
+	}
+	for _, st := range it.Stanzas {
+		if st == stanzaType {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches returns whether or not a contact JID matches the rule.
+// ri references contact's roster item, if any.
+func (it *Item) Matches(contact *jid.JID, ri *rostermodel.Item) bool {
+	switch it.Type {
+	case "":
+		return true // fall-through item
+	case JIDType:
+		j, err := jid.NewWithString(it.Value, true)
+		if err != nil {
+			return false
+		}
+		if j.IsFullWithUser() {
+			return contact.Matches(j, jid.MatchesNode|jid.MatchesDomain|jid.MatchesResource)
+		} else if j.IsFullWithServer() {
+			return contact.Matches(j, jid.MatchesDomain|jid.MatchesResource)
+		} else if j.IsBare() {
+			return contact.Matches(j, jid.MatchesNode|jid.MatchesDomain)
+		}
+		return contact.Matches(j, jid.MatchesDomain)
+	case GroupType:
+		if ri != nil {
+			for _, group := range ri.Groups {
+				if group == it.Value {
+					return true
+				}
+			}
+		}
+		return false
+	case SubscriptionType:
+		if ri == nil || len(ri.Subscription) == 0 {
+			return it.Value == rostermodel.SubscriptionNone
+		}
+		return ri.Subscription == it.Value
+	}
+	return false
+}
+
+// FromGob deserializes a privacy Item entity
+// from it's gob binary representation.
+func (it *Item) FromGob(dec *gob.Decoder) {
+	dec.Decode(&it.Type)
+	dec.Decode(&it.Value)
+	dec.Decode(&it.Action)
+	dec.Decode(&it.Order)
+	dec.Decode(&it.Stanzas)
+}
+
+// ToGob converts a privacy Item entity
+// to it's gob binary representation.
+func (it *Item) ToGob(enc *gob.Encoder) {
+	enc.Encode(&it.Type)
+	enc.Encode(&it.Value)
+	enc.Encode(&it.Action)
+	enc.Encode(&it.Order)
+	enc.Encode(&it.Stanzas)
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package privacymodel
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+
+	"github.com/ortuman/jackal/model/rostermodel"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestItemElement(t *testing.T) {
+	elem := xml.NewElementName("item2")
+	_, err := NewItem(elem)
+	require.NotNil(t, err)
+
+	// bad action
+	elem.SetName("item")
+	elem.SetAttribute("action", "foo")
+	_, err = NewItem(elem)
+	require.NotNil(t, err)
+
+	// bad order
+	elem.SetAttribute("action", Deny)
+	elem.SetAttribute("order", "-1")
+	_, err = NewItem(elem)
+	require.NotNil(t, err)
+
+	// value without type
+	elem.SetAttribute("order", "1")
+	elem.SetAttribute("value", "romeo@jackal.im")
+	_, err = NewItem(elem)
+	require.NotNil(t, err)
+
+	// bad type
+	elem.SetAttribute("type", "foo")
+	_, err = NewItem(elem)
+	require.NotNil(t, err)
+
+	// bad subscription
+	elem.SetAttribute("type", SubscriptionType)
+	elem.SetAttribute("value", "foo")
+	_, err = NewItem(elem)
+	require.NotNil(t, err)
+
+	// bad stanza type
+	elem.SetAttribute("type", JIDType)
+	elem.SetAttribute("value", "romeo@jackal.im")
+	elem.AppendElement(xml.NewElementName("foo"))
+	_, err = NewItem(elem)
+	require.NotNil(t, err)
+
+	elem.ClearElements()
+	elem.AppendElement(xml.NewElementName(Message))
+	elem.AppendElement(xml.NewElementName(PresenceIn))
+	it, err := NewItem(elem)
+	require.Nil(t, err)
+	require.Equal(t, JIDType, it.Type)
+	require.Equal(t, "romeo@jackal.im", it.Value)
+	require.Equal(t, Deny, it.Action)
+	require.Equal(t, 1, it.Order)
+	require.Equal(t, []string{Message, PresenceIn}, it.Stanzas)
+
+	itElem := it.Element()
+	require.Equal(t, JIDType, itElem.Attributes().Get("type"))
+	require.Equal(t, "romeo@jackal.im", itElem.Attributes().Get("value"))
+	require.Equal(t, Deny, itElem.Attributes().Get("action"))
+	require.Equal(t, "1", itElem.Attributes().Get("order"))
+	require.Equal(t, 2, itElem.Elements().Count())
+}
+
+func TestItemMatches(t *testing.T) {
+	romeo, _ := jid.New("romeo", "jackal.im", "orchard", true)
+
+	it := Item{Action: Deny}
+	require.True(t, it.Matches(romeo, nil))
+	require.True(t, it.AppliesTo(Message))
+	require.True(t, it.AppliesTo(""))
+
+	it = Item{Type: JIDType, Value: "jackal.im", Stanzas: []string{IQ}}
+	require.True(t, it.Matches(romeo, nil))
+	require.True(t, it.AppliesTo(IQ))
+	require.False(t, it.AppliesTo(Message))
+	require.False(t, it.AppliesTo(""))
+
+	it = Item{Type: JIDType, Value: "romeo@jackal.im"}
+	require.True(t, it.Matches(romeo, nil))
+	it.Value = "romeo@jackal.im/garden"
+	require.False(t, it.Matches(romeo, nil))
+	it.Value = "jackal.im/orchard"
+	require.True(t, it.Matches(romeo, nil))
+
+	ri := &rostermodel.Item{JID: "romeo@jackal.im", Subscription: rostermodel.SubscriptionTo, Groups: []string{"Friends"}}
+	it = Item{Type: GroupType, Value: "Friends"}
+	require.True(t, it.Matches(romeo, ri))
+	require.False(t, it.Matches(romeo, nil))
+
+	it = Item{Type: SubscriptionType, Value: rostermodel.SubscriptionTo}
+	require.True(t, it.Matches(romeo, ri))
+	require.False(t, it.Matches(romeo, nil))
+	it.Value = rostermodel.SubscriptionNone
+	require.True(t, it.Matches(romeo, nil))
+	require.False(t, it.Matches(romeo, ri))
+}
+
+func TestItemGob(t *testing.T) {
+	it1 := Item{Type: JIDType, Value: "romeo@jackal.im", Action: Deny, Order: 3, Stanzas: []string{Message}}
+	buf := new(bytes.Buffer)
+	it1.ToGob(gob.NewEncoder(buf))
+	var it2 Item
+	it2.FromGob(gob.NewDecoder(buf))
+	require.Equal(t, it1, it2)
+}
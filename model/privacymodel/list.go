@@ -0,0 +1,115 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package privacymodel
+
+import (
+	"encoding/gob"
+	"errors"
+	"sort"
+
+	"github.com/ortuman/jackal/model/rostermodel"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+)
+
+// List represents a privacy list (XEP-0016) storage entity.
+type List struct {
+	Username string
+	Domain   string
+	Name     string
+	Default  bool
+	Items    []Item // sorted by order
+}
+
+// NewList parses an XML element returning a derived privacy list instance.
+func NewList(username, domain string, elem xml.XElement) (*List, error) {
+	if elem.Name() != "list" {
+		return nil, errors.New("invalid list element name: " + elem.Name())
+	}
+	l := &List{Username: username, Domain: domain, Name: elem.Attributes().Get("name")}
+	if len(l.Name) == 0 {
+		return nil, errors.New("list 'name' attribute is required")
+	}
+	orders := make(map[int]struct{})
+	for _, itemElem := range elem.Elements().All() {
+		it, err := NewItem(itemElem)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := orders[it.Order]; ok {
+			return nil, errors.New("duplicated item 'order' attribute value")
+		}
+		orders[it.Order] = struct{}{}
+		l.Items = append(l.Items, *it)
+	}
+	sort.Slice(l.Items, func(i, j int) bool { return l.Items[i].Order < l.Items[j].Order })
+	return l, nil
+}
+
+// Element returns a privacy list XML element representation.
+func (l *List) Element() xml.XElement {
+	list := xml.NewElementName("list")
+	list.SetAttribute("name", l.Name)
+	for _, it := range l.Items {
+		list.AppendElement(it.Element())
+	}
+	return list
+}
+
+// RequiresRosterItem returns whether or not any of the list rules
+// depends on contact's roster item.
+func (l *List) RequiresRosterItem() bool {
+	for _, it := range l.Items {
+		if it.Type == GroupType || it.Type == SubscriptionType {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDenied returns whether or not a stanza of a given type exchanged
+// with a contact is denied by the list.
+// ri references contact's roster item, if any.
+func (l *List) IsDenied(contact *jid.JID, stanzaType string, ri *rostermodel.Item) bool {
+	for _, it := range l.Items {
+		if !it.AppliesTo(stanzaType) || !it.Matches(contact, ri) {
+			continue
+		}
+		return it.Action == Deny
+	}
+	return false
+}
+
+// FromGob deserializes a privacy List entity
+// from it's gob binary representation.
+func (l *List) FromGob(dec *gob.Decoder) {
+	dec.Decode(&l.Username)
+	dec.Decode(&l.Name)
+	dec.Decode(&l.Default)
+	var count int
+	dec.Decode(&count)
+	l.Items = nil
+	for i := 0; i < count; i++ {
+		var it Item
+		it.FromGob(dec)
+		l.Items = append(l.Items, it)
+	}
+	dec.Decode(&l.Domain)
+}
+
+// ToGob converts a privacy List entity
+// to it's gob binary representation.
+func (l *List) ToGob(enc *gob.Encoder) {
+	enc.Encode(&l.Username)
+	enc.Encode(&l.Name)
+	enc.Encode(&l.Default)
+	count := len(l.Items)
+	enc.Encode(&count)
+	for _, it := range l.Items {
+		it.ToGob(enc)
+	}
+	enc.Encode(&l.Domain)
+}
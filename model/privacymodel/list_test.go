@@ -0,0 +1,90 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package privacymodel
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+
+	"github.com/ortuman/jackal/model/rostermodel"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListElement(t *testing.T) {
+	_, err := NewList("ortuman", "jackal.im", xml.NewElementName("query"))
+	require.NotNil(t, err)
+
+	elem := xml.NewElementName("list")
+	_, err = NewList("ortuman", "jackal.im", elem)
+	require.NotNil(t, err)
+
+	elem.SetAttribute("name", "public")
+	elem.AppendElement(tUtilItemElement("", "", Allow, "20"))
+	elem.AppendElement(tUtilItemElement(JIDType, "romeo@jackal.im", Deny, "10"))
+
+	l, err := NewList("ortuman", "jackal.im", elem)
+	require.Nil(t, err)
+	require.Equal(t, "ortuman", l.Username)
+	require.Equal(t, "jackal.im", l.Domain)
+	require.Equal(t, "public", l.Name)
+	require.Equal(t, 2, len(l.Items))
+	require.Equal(t, 10, l.Items[0].Order)
+	require.Equal(t, 20, l.Items[1].Order)
+	require.Equal(t, "public", l.Element().Attributes().Get("name"))
+	require.Equal(t, 2, l.Element().Elements().Count())
+
+	// duplicated order
+	elem.AppendElement(tUtilItemElement(GroupType, "Friends", Deny, "10"))
+	_, err = NewList("ortuman", "jackal.im", elem)
+	require.NotNil(t, err)
+}
+
+func TestListIsDenied(t *testing.T) {
+	romeo, _ := jid.New("romeo", "jackal.im", "orchard", true)
+	juliet, _ := jid.New("juliet", "jackal.im", "balcony", true)
+
+	l := List{Items: []Item{
+		{Type: JIDType, Value: "romeo@jackal.im", Action: Deny, Order: 1, Stanzas: []string{Message}},
+		{Type: SubscriptionType, Value: rostermodel.SubscriptionNone, Action: Deny, Order: 2, Stanzas: []string{PresenceIn}},
+		{Action: Allow, Order: 3},
+	}}
+	require.True(t, l.RequiresRosterItem())
+	require.True(t, l.IsDenied(romeo, Message, nil))
+	require.False(t, l.IsDenied(romeo, IQ, nil))
+	require.False(t, l.IsDenied(juliet, Message, nil))
+	require.True(t, l.IsDenied(juliet, PresenceIn, nil))
+	require.False(t, l.IsDenied(juliet, PresenceIn, &rostermodel.Item{Subscription: rostermodel.SubscriptionBoth}))
+
+	l = List{Items: []Item{{Action: Deny, Order: 1}}}
+	require.False(t, l.RequiresRosterItem())
+	require.True(t, l.IsDenied(juliet, "", nil))
+}
+
+func TestListGob(t *testing.T) {
+	l1 := List{Username: "ortuman", Domain: "jackal.im", Name: "public", Default: true, Items: []Item{
+		{Type: JIDType, Value: "romeo@jackal.im", Action: Deny, Order: 1, Stanzas: []string{Message}},
+		{Action: Allow, Order: 2},
+	}}
+	buf := new(bytes.Buffer)
+	l1.ToGob(gob.NewEncoder(buf))
+	var l2 List
+	l2.FromGob(gob.NewDecoder(buf))
+	require.Equal(t, l1, l2)
+}
+
+func tUtilItemElement(typ, value, action, order string) xml.XElement {
+	item := xml.NewElementName("item")
+	if len(typ) > 0 {
+		item.SetAttribute("type", typ)
+		item.SetAttribute("value", value)
+	}
+	item.SetAttribute("action", action)
+	item.SetAttribute("order", order)
+	return item
+}
@@ -0,0 +1,293 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pubsubmodel
+
+import (
+	"encoding/gob"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// node access model values
+const (
+	AccessModelOpen      = "open"
+	AccessModelPresence  = "presence"
+	AccessModelRoster    = "roster"
+	AccessModelWhitelist = "whitelist"
+)
+
+// node publish model values
+const (
+	PublishModelPublishers  = "publishers"
+	PublishModelSubscribers = "subscribers"
+	PublishModelOpen        = "open"
+)
+
+// send last published item values
+const (
+	SendLastPublishedItemNever            = "never"
+	SendLastPublishedItemOnSub            = "on_sub"
+	SendLastPublishedItemOnSubAndPresence = "on_sub_and_presence"
+)
+
+const (
+	defaultMaxItems         = 10
+	defaultNotificationType = "headline"
+
+	rosterGroupsAllowedFieldVar  = "pubsub#roster_groups_allowed"
+	rosterGroupsAllowedSeparator = ";"
+)
+
+// node affiliation values
+const (
+	AffiliationOwner     = "owner"
+	AffiliationPublisher = "publisher"
+	AffiliationMember    = "member"
+	AffiliationOutcast   = "outcast"
+	AffiliationNone      = "none"
+)
+
+// Options represents a pubsub node configuration.
+type Options struct {
+	Title                 string
+	DeliverNotifications  bool
+	DeliverPayloads       bool
+	NotifyConfig          bool
+	NotifyDelete          bool
+	NotifyRetract         bool
+	PersistItems          bool
+	MaxItems              int
+	AccessModel           string
+	PublishModel          string
+	RosterGroupsAllowed   []string
+	SendLastPublishedItem string
+	NotificationType      string
+}
+
+// DefaultOptions returns default pubsub node configuration.
+func DefaultOptions() Options {
+	return Options{
+		DeliverNotifications:  true,
+		DeliverPayloads:       true,
+		NotifyConfig:          false,
+		NotifyDelete:          true,
+		NotifyRetract:         true,
+		PersistItems:          true,
+		MaxItems:              defaultMaxItems,
+		AccessModel:           AccessModelOpen,
+		PublishModel:          PublishModelPublishers,
+		SendLastPublishedItem: SendLastPublishedItemOnSub,
+		NotificationType:      defaultNotificationType,
+	}
+}
+
+// DefaultPEPOptions returns default personal eventing (XEP-0163) node configuration.
+func DefaultPEPOptions() Options {
+	opts := DefaultOptions()
+	opts.AccessModel = AccessModelPresence
+	opts.MaxItems = 1
+	opts.SendLastPublishedItem = SendLastPublishedItemOnSubAndPresence
+	return opts
+}
+
+// NewOptionsFromMap returns a node configuration derived from
+// a pubsub#node_config field map.
+func NewOptionsFromMap(m map[string]string) (*Options, error) {
+	opts := DefaultOptions()
+	if err := opts.SetMap(m); err != nil {
+		return nil, err
+	}
+	return &opts, nil
+}
+
+// SetMap applies every pubsub#node_config field contained into m.
+// Unknown fields are ignored.
+func (o *Options) SetMap(m map[string]string) error {
+	c := *o
+	for k, v := range m {
+		var err error
+		switch k {
+		case "pubsub#title":
+			c.Title = v
+		case "pubsub#deliver_notifications":
+			c.DeliverNotifications, err = parseBool(v)
+		case "pubsub#deliver_payloads":
+			c.DeliverPayloads, err = parseBool(v)
+		case "pubsub#notify_config":
+			c.NotifyConfig, err = parseBool(v)
+		case "pubsub#notify_delete":
+			c.NotifyDelete, err = parseBool(v)
+		case "pubsub#notify_retract":
+			c.NotifyRetract, err = parseBool(v)
+		case "pubsub#persist_items":
+			c.PersistItems, err = parseBool(v)
+		case "pubsub#max_items":
+			if v == "max" {
+				c.MaxItems = 0
+				break
+			}
+			c.MaxItems, err = strconv.Atoi(v)
+			if err == nil && c.MaxItems < 0 {
+				err = fmt.Errorf("pubsubmodel: invalid max_items value: %d", c.MaxItems)
+			}
+		case "pubsub#access_model":
+			switch v {
+			case AccessModelOpen, AccessModelPresence, AccessModelRoster, AccessModelWhitelist:
+				c.AccessModel = v
+			default:
+				err = fmt.Errorf("pubsubmodel: unrecognized access model: %s", v)
+			}
+		case "pubsub#publish_model":
+			switch v {
+			case PublishModelPublishers, PublishModelSubscribers, PublishModelOpen:
+				c.PublishModel = v
+			default:
+				err = fmt.Errorf("pubsubmodel: unrecognized publish model: %s", v)
+			}
+		case rosterGroupsAllowedFieldVar:
+			c.RosterGroupsAllowed = nil
+			if len(v) > 0 {
+				c.RosterGroupsAllowed = strings.Split(v, rosterGroupsAllowedSeparator)
+			}
+		case "pubsub#send_last_published_item":
+			switch v {
+			case SendLastPublishedItemNever, SendLastPublishedItemOnSub, SendLastPublishedItemOnSubAndPresence:
+				c.SendLastPublishedItem = v
+			default:
+				err = fmt.Errorf("pubsubmodel: unrecognized send_last_published_item value: %s", v)
+			}
+		case "pubsub#notification_type":
+			switch v {
+			case "normal", "headline":
+				c.NotificationType = v
+			default:
+				err = fmt.Errorf("pubsubmodel: unrecognized notification type: %s", v)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	*o = c
+	return nil
+}
+
+// Map returns node configuration pubsub#node_config field map representation.
+// Multi-valued fields are joined using a semicolon separator.
+func (o *Options) Map() map[string]string {
+	return map[string]string{
+		"pubsub#title":                    o.Title,
+		"pubsub#deliver_notifications":    boolString(o.DeliverNotifications),
+		"pubsub#deliver_payloads":         boolString(o.DeliverPayloads),
+		"pubsub#notify_config":            boolString(o.NotifyConfig),
+		"pubsub#notify_delete":            boolString(o.NotifyDelete),
+		"pubsub#notify_retract":           boolString(o.NotifyRetract),
+		"pubsub#persist_items":            boolString(o.PersistItems),
+		"pubsub#max_items":                strconv.Itoa(o.MaxItems),
+		"pubsub#access_model":             o.AccessModel,
+		"pubsub#publish_model":            o.PublishModel,
+		rosterGroupsAllowedFieldVar:       strings.Join(o.RosterGroupsAllowed, rosterGroupsAllowedSeparator),
+		"pubsub#send_last_published_item": o.SendLastPublishedItem,
+		"pubsub#notification_type":        o.NotificationType,
+	}
+}
+
+// Node represents a pubsub node storage entity.
+type Node struct {
+	Host          string
+	Name          string
+	Options       Options
+	Affiliations  map[string]string
+	Subscriptions map[string]string
+}
+
+// Affiliation returns the affiliation associated to a given bare JID.
+func (n *Node) Affiliation(bareJID string) string {
+	if aff, ok := n.Affiliations[bareJID]; ok {
+		return aff
+	}
+	return AffiliationNone
+}
+
+// SetAffiliation sets the affiliation associated to a given bare JID.
+func (n *Node) SetAffiliation(bareJID, affiliation string) {
+	if affiliation == AffiliationNone {
+		delete(n.Affiliations, bareJID)
+		return
+	}
+	if n.Affiliations == nil {
+		n.Affiliations = make(map[string]string)
+	}
+	n.Affiliations[bareJID] = affiliation
+}
+
+// Subscription returns the subscription identifier associated to a given JID,
+// or an empty string if not subscribed.
+func (n *Node) Subscription(jid string) string {
+	return n.Subscriptions[jid]
+}
+
+// SetSubscription sets the subscription identifier associated to a given JID.
+// An empty identifier removes the subscription.
+func (n *Node) SetSubscription(jid, subID string) {
+	if len(subID) == 0 {
+		delete(n.Subscriptions, jid)
+		return
+	}
+	if n.Subscriptions == nil {
+		n.Subscriptions = make(map[string]string)
+	}
+	n.Subscriptions[jid] = subID
+}
+
+// Owners returns node owners bare JIDs sorted alphabetically.
+func (n *Node) Owners() []string {
+	var ret []string
+	for j, aff := range n.Affiliations {
+		if aff == AffiliationOwner {
+			ret = append(ret, j)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// FromGob deserializes a Node entity from it's gob binary representation.
+func (n *Node) FromGob(dec *gob.Decoder) {
+	dec.Decode(&n.Host)
+	dec.Decode(&n.Name)
+	dec.Decode(&n.Options)
+	dec.Decode(&n.Affiliations)
+	dec.Decode(&n.Subscriptions)
+}
+
+// ToGob converts a Node entity to it's gob binary representation.
+func (n *Node) ToGob(enc *gob.Encoder) {
+	enc.Encode(&n.Host)
+	enc.Encode(&n.Name)
+	enc.Encode(&n.Options)
+	enc.Encode(&n.Affiliations)
+	enc.Encode(&n.Subscriptions)
+}
+
+func parseBool(s string) (bool, error) {
+	switch s {
+	case "1", "true":
+		return true, nil
+	case "0", "false":
+		return false, nil
+	}
+	return false, fmt.Errorf("pubsubmodel: invalid boolean value: %s", s)
+}
+
+func boolString(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
@@ -0,0 +1,111 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pubsubmodel
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/xml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptions_Map(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Title = "Device configuration"
+	opts.AccessModel = AccessModelRoster
+	opts.RosterGroupsAllowed = []string{"Friends", "Family"}
+
+	opts2, err := NewOptionsFromMap(opts.Map())
+	require.Nil(t, err)
+	require.Equal(t, opts, *opts2)
+
+	opts2, err = NewOptionsFromMap(map[string]string{"pubsub#max_items": "max", "pubsub#unknown": "x"})
+	require.Nil(t, err)
+	require.Equal(t, 0, opts2.MaxItems)
+	require.Equal(t, AccessModelOpen, opts2.AccessModel)
+
+	_, err = NewOptionsFromMap(map[string]string{"pubsub#access_model": "authorize"})
+	require.NotNil(t, err)
+	_, err = NewOptionsFromMap(map[string]string{"pubsub#persist_items": "yes"})
+	require.NotNil(t, err)
+	_, err = NewOptionsFromMap(map[string]string{"pubsub#max_items": "-1"})
+	require.NotNil(t, err)
+
+	// failed update must not modify options
+	err = opts.SetMap(map[string]string{"pubsub#title": "Changed", "pubsub#publish_model": "nobody"})
+	require.NotNil(t, err)
+	require.Equal(t, "Device configuration", opts.Title)
+}
+
+func TestOptions_DefaultPEP(t *testing.T) {
+	opts := DefaultPEPOptions()
+	require.Equal(t, AccessModelPresence, opts.AccessModel)
+	require.Equal(t, 1, opts.MaxItems)
+	require.Equal(t, SendLastPublishedItemOnSubAndPresence, opts.SendLastPublishedItem)
+	require.True(t, opts.DeliverNotifications)
+}
+
+func TestNode_AffiliationsAndSubscriptions(t *testing.T) {
+	n := Node{Host: "pubsub.jackal.im", Name: "princely_musings"}
+	require.Equal(t, AffiliationNone, n.Affiliation("ortuman@jackal.im"))
+
+	n.SetAffiliation("ortuman@jackal.im", AffiliationOwner)
+	n.SetAffiliation("noelia@jackal.im", AffiliationOwner)
+	n.SetAffiliation("romeo@jackal.im", AffiliationPublisher)
+	require.Equal(t, AffiliationPublisher, n.Affiliation("romeo@jackal.im"))
+	require.Equal(t, []string{"noelia@jackal.im", "ortuman@jackal.im"}, n.Owners())
+
+	n.SetAffiliation("romeo@jackal.im", AffiliationNone)
+	require.Equal(t, 2, len(n.Affiliations))
+
+	n.SetSubscription("romeo@jackal.im/garden", "1234")
+	require.Equal(t, "1234", n.Subscription("romeo@jackal.im/garden"))
+	n.SetSubscription("romeo@jackal.im/garden", "")
+	require.Equal(t, "", n.Subscription("romeo@jackal.im/garden"))
+	require.Equal(t, 0, len(n.Subscriptions))
+}
+
+func TestNode_Gob(t *testing.T) {
+	n1 := Node{
+		Host:          "pubsub.jackal.im",
+		Name:          "princely_musings",
+		Options:       DefaultOptions(),
+		Affiliations:  map[string]string{"ortuman@jackal.im": AffiliationOwner},
+		Subscriptions: map[string]string{"noelia@jackal.im": "1234"},
+	}
+	buf := new(bytes.Buffer)
+	n1.ToGob(gob.NewEncoder(buf))
+	var n2 Node
+	n2.FromGob(gob.NewDecoder(buf))
+	require.Equal(t, n1, n2)
+}
+
+func TestItem_Gob(t *testing.T) {
+	entry := xml.NewElementNamespace("entry", "http://www.w3.org/2005/Atom")
+	entry.SetText("Soliloquy")
+
+	i1 := Item{ID: "ae890ac52d0df67ed7cfdf51b644e901", Publisher: "ortuman@jackal.im", Payload: entry, CreatedAt: time.Now().UTC()}
+	buf := new(bytes.Buffer)
+	i1.ToGob(gob.NewEncoder(buf))
+	var i2 Item
+	i2.FromGob(gob.NewDecoder(buf))
+	require.Equal(t, i1.ID, i2.ID)
+	require.Equal(t, i1.Publisher, i2.Publisher)
+	require.Equal(t, i1.Payload.String(), i2.Payload.String())
+	require.True(t, i1.CreatedAt.Equal(i2.CreatedAt))
+
+	// payload-less item
+	i1.Payload = nil
+	buf = new(bytes.Buffer)
+	i1.ToGob(gob.NewEncoder(buf))
+	var i3 Item
+	i3.FromGob(gob.NewDecoder(buf))
+	require.Nil(t, i3.Payload)
+	require.True(t, i1.CreatedAt.Equal(i3.CreatedAt))
+}
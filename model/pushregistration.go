@@ -0,0 +1,52 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package model
+
+import (
+	"encoding/gob"
+
+	"github.com/ortuman/jackal/xml"
+)
+
+// PushRegistration represents a push notifications (XEP-0357)
+// app server registration storage entity.
+type PushRegistration struct {
+	Username string
+	Domain   string
+	JID      string
+	Node     string
+	Options  xml.XElement
+}
+
+// FromGob deserializes a PushRegistration entity
+// from it's gob binary representation.
+func (pr *PushRegistration) FromGob(dec *gob.Decoder) {
+	dec.Decode(&pr.Username)
+	dec.Decode(&pr.JID)
+	dec.Decode(&pr.Node)
+	var hasOptions bool
+	dec.Decode(&hasOptions)
+	if hasOptions {
+		el := &xml.Element{}
+		el.FromGob(dec)
+		pr.Options = el
+	}
+	dec.Decode(&pr.Domain)
+}
+
+// ToGob converts a PushRegistration entity
+// to it's gob binary representation.
+func (pr *PushRegistration) ToGob(enc *gob.Encoder) {
+	enc.Encode(&pr.Username)
+	enc.Encode(&pr.JID)
+	enc.Encode(&pr.Node)
+	hasOptions := pr.Options != nil
+	enc.Encode(&hasOptions)
+	if hasOptions {
+		xml.NewElementFromElement(pr.Options).ToGob(enc)
+	}
+	enc.Encode(&pr.Domain)
+}
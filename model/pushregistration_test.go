@@ -0,0 +1,31 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package model
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+
+	"github.com/ortuman/jackal/xml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushRegistration(t *testing.T) {
+	var pr1, pr2 PushRegistration
+	pr1 = PushRegistration{Username: "ortuman", Domain: "jackal.im", JID: "push.jackal.im", Node: "yxs32uqsflafdk3iuqo"}
+	buf := new(bytes.Buffer)
+	pr1.ToGob(gob.NewEncoder(buf))
+	pr2.FromGob(gob.NewDecoder(buf))
+	require.Equal(t, pr1, pr2)
+
+	var pr3 PushRegistration
+	pr1.Options = xml.NewElementNamespace("x", "jabber:x:data")
+	buf = new(bytes.Buffer)
+	pr1.ToGob(gob.NewEncoder(buf))
+	pr3.FromGob(gob.NewDecoder(buf))
+	require.Equal(t, pr1.Options.String(), pr3.Options.String())
+}
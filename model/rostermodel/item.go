@@ -26,6 +26,7 @@ const (
 // Item represents a roster item storage entity.
 type Item struct {
 	Username     string
+	Domain       string
 	JID          string
 	Name         string
 	Subscription string
@@ -118,6 +119,7 @@ func (ri *Item) FromGob(dec *gob.Decoder) {
 	dec.Decode(&ri.Ask)
 	dec.Decode(&ri.Ver)
 	dec.Decode(&ri.Groups)
+	dec.Decode(&ri.Domain)
 }
 
 // ToGob converts a RosterItem entity
@@ -130,4 +132,5 @@ func (ri *Item) ToGob(enc *gob.Encoder) {
 	enc.Encode(&ri.Ask)
 	enc.Encode(&ri.Ver)
 	enc.Encode(&ri.Groups)
+	enc.Encode(&ri.Domain)
 }
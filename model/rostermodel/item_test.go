@@ -75,6 +75,7 @@ func TestItem_Serialize(t *testing.T) {
 	var ri1 Item
 	ri1 = Item{
 		Username:     "ortuman",
+		Domain:       "jackal.im",
 		JID:          "noelia",
 		Ask:          true,
 		Subscription: "none",
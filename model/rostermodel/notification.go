@@ -16,6 +16,7 @@ import (
 // pending notification.
 type Notification struct {
 	Contact  string
+	Domain   string
 	JID      string
 	Presence *xml.Presence
 }
@@ -30,6 +31,7 @@ func (rn *Notification) FromGob(dec *gob.Decoder) {
 	fromJID, _ := jid.NewWithString(el.From(), true)
 	toJID, _ := jid.NewWithString(el.To(), true)
 	rn.Presence, _ = xml.NewPresenceFromElement(el, fromJID, toJID)
+	dec.Decode(&rn.Domain)
 }
 
 // ToGob converts a Notification entity
@@ -38,4 +40,5 @@ func (rn *Notification) ToGob(enc *gob.Encoder) {
 	enc.Encode(&rn.Contact)
 	enc.Encode(&rn.JID)
 	rn.Presence.ToGob(enc)
+	enc.Encode(&rn.Domain)
 }
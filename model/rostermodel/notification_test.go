@@ -23,6 +23,7 @@ func TestModelRosterNotification(t *testing.T) {
 
 	rn1 = Notification{
 		Contact:  "noelia",
+		Domain:   "jackal.im",
 		JID:      "ortuman@jackal.im",
 		Presence: xml.NewPresence(j1, j2, xml.AvailableType),
 	}
@@ -31,6 +32,7 @@ func TestModelRosterNotification(t *testing.T) {
 	rn2.FromGob(gob.NewDecoder(buf))
 	require.Equal(t, "ortuman@jackal.im", rn2.JID)
 	require.Equal(t, "noelia", rn2.Contact)
+	require.Equal(t, "jackal.im", rn2.Domain)
 	require.NotNil(t, rn1.Presence)
 	require.NotNil(t, rn2.Presence)
 	require.Equal(t, rn1.Presence.String(), rn2.Presence.String())
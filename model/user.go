@@ -6,19 +6,82 @@
 package model
 
 import (
+	"encoding/base64"
 	"encoding/gob"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ortuman/jackal/xml"
 	"github.com/ortuman/jackal/xml/jid"
 )
 
+var errInvalidScramCredentials = errors.New("model: invalid SCRAM credentials format")
+
+// ScramCredentials represents a set of salted SCRAM credentials (RFC 5802).
+type ScramCredentials struct {
+	Salt       []byte
+	Iterations int
+	StoredKey  []byte
+	ServerKey  []byte
+}
+
+// NewScramCredentialsString parses a set of SCRAM credentials from its
+// RFC 5803 textual representation, without the leading mechanism name.
+func NewScramCredentialsString(str string) (*ScramCredentials, error) {
+	sp := strings.Split(str, "$")
+	if len(sp) != 2 {
+		return nil, errInvalidScramCredentials
+	}
+	iterSalt := strings.Split(sp[0], ":")
+	keys := strings.Split(sp[1], ":")
+	if len(iterSalt) != 2 || len(keys) != 2 {
+		return nil, errInvalidScramCredentials
+	}
+	iterations, err := strconv.Atoi(iterSalt[0])
+	if err != nil || iterations <= 0 {
+		return nil, errInvalidScramCredentials
+	}
+	c := &ScramCredentials{Iterations: iterations}
+	if c.Salt, err = base64.StdEncoding.DecodeString(iterSalt[1]); err != nil {
+		return nil, errInvalidScramCredentials
+	}
+	if c.StoredKey, err = base64.StdEncoding.DecodeString(keys[0]); err != nil {
+		return nil, errInvalidScramCredentials
+	}
+	if c.ServerKey, err = base64.StdEncoding.DecodeString(keys[1]); err != nil {
+		return nil, errInvalidScramCredentials
+	}
+	return c, nil
+}
+
+// String returns SCRAM credentials RFC 5803 textual representation,
+// without the leading mechanism name.
+func (c *ScramCredentials) String() string {
+	return fmt.Sprintf("%d:%s$%s:%s", c.Iterations,
+		base64.StdEncoding.EncodeToString(c.Salt),
+		base64.StdEncoding.EncodeToString(c.StoredKey),
+		base64.StdEncoding.EncodeToString(c.ServerKey))
+}
+
 // User represents a user storage entity.
 type User struct {
 	Username       string
+	Domain         string
 	Password       string
+	ScramSHA1      *ScramCredentials
+	ScramSHA256    *ScramCredentials
 	LastPresence   *xml.Presence
 	LastPresenceAt time.Time
+	Disabled       bool
+}
+
+// HasScramCredentials returns whether or not user has any
+// SCRAM credentials set.
+func (u *User) HasScramCredentials() bool {
+	return u.ScramSHA1 != nil || u.ScramSHA256 != nil
 }
 
 // FromGob deserializes a User entity from it's gob binary representation.
@@ -37,6 +100,10 @@ func (u *User) FromGob(dec *gob.Decoder) {
 		u.LastPresence = p
 		dec.Decode(&u.LastPresenceAt)
 	}
+	u.ScramSHA1 = scramCredentialsFromGob(dec)
+	u.ScramSHA256 = scramCredentialsFromGob(dec)
+	dec.Decode(&u.Domain)
+	dec.Decode(&u.Disabled)
 }
 
 // ToGob converts a User entity to it's gob binary representation.
@@ -50,4 +117,28 @@ func (u *User) ToGob(enc *gob.Encoder) {
 		u.LastPresenceAt = time.Now()
 		enc.Encode(&u.LastPresenceAt)
 	}
+	scramCredentialsToGob(u.ScramSHA1, enc)
+	scramCredentialsToGob(u.ScramSHA256, enc)
+	enc.Encode(&u.Domain)
+	enc.Encode(&u.Disabled)
+}
+
+func scramCredentialsFromGob(dec *gob.Decoder) *ScramCredentials {
+	var hasCredentials bool
+	if err := dec.Decode(&hasCredentials); err != nil || !hasCredentials {
+		return nil
+	}
+	var c ScramCredentials
+	if err := dec.Decode(&c); err != nil {
+		return nil
+	}
+	return &c
+}
+
+func scramCredentialsToGob(c *ScramCredentials, enc *gob.Encoder) {
+	hasCredentials := c != nil
+	enc.Encode(&hasCredentials)
+	if hasCredentials {
+		enc.Encode(c)
+	}
 }
@@ -24,15 +24,49 @@ func TestModelUser(t *testing.T) {
 	j2, _ := jid.NewWithString("ortuman@jackal.im", true)
 
 	usr1.Username = "ortuman"
+	usr1.Domain = "jackal.im"
 	usr1.Password = "1234"
 	usr1.LastPresence = xml.NewPresence(j1, j2, xml.AvailableType)
+	usr1.Disabled = true
 
 	buf := new(bytes.Buffer)
 	usr1.ToGob(gob.NewEncoder(buf))
 	usr2 := User{}
 	usr2.FromGob(gob.NewDecoder(buf))
 	require.Equal(t, usr1.Username, usr2.Username)
+	require.Equal(t, usr1.Domain, usr2.Domain)
 	require.Equal(t, usr1.Password, usr2.Password)
 	require.Equal(t, usr1.LastPresence.String(), usr2.LastPresence.String())
 	require.NotEqual(t, time.Time{}, usr2.LastPresenceAt)
+	require.True(t, usr2.Disabled)
+}
+
+func TestModelUserScramCredentials(t *testing.T) {
+	c := &ScramCredentials{
+		Salt:       []byte("salt"),
+		Iterations: 4096,
+		StoredKey:  []byte("stored"),
+		ServerKey:  []byte("server"),
+	}
+	usr1 := User{Username: "ortuman", ScramSHA256: c}
+	require.True(t, usr1.HasScramCredentials())
+
+	buf := new(bytes.Buffer)
+	usr1.ToGob(gob.NewEncoder(buf))
+	usr2 := User{}
+	usr2.FromGob(gob.NewDecoder(buf))
+	require.Equal(t, "", usr2.Password)
+	require.Nil(t, usr2.ScramSHA1)
+	require.Equal(t, c, usr2.ScramSHA256)
+
+	c2, err := NewScramCredentialsString(c.String())
+	require.Nil(t, err)
+	require.Equal(t, c, c2)
+
+	_, err = NewScramCredentialsString("4096:c2FsdA==")
+	require.NotNil(t, err)
+	_, err = NewScramCredentialsString("0:c2FsdA==$c3RvcmVk:c2VydmVy")
+	require.NotNil(t, err)
+	_, err = NewScramCredentialsString("4096:c2FsdA==$c3RvcmVk:???")
+	require.NotNil(t, err)
 }
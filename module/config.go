@@ -7,31 +7,26 @@ package module
 
 import (
 	"fmt"
+	"strings"
 
-	"github.com/ortuman/jackal/module/offline"
-	"github.com/ortuman/jackal/module/roster"
-	"github.com/ortuman/jackal/module/xep0077"
-	"github.com/ortuman/jackal/module/xep0092"
-	"github.com/ortuman/jackal/module/xep0199"
+	"gopkg.in/yaml.v2"
 )
 
+const settingsKeyPrefix = "mod_"
+
 // Config represents C2S modules configuration.
 type Config struct {
-	Enabled      map[string]struct{}
-	Roster       roster.Config
-	Offline      offline.Config
-	Registration xep0077.Config
-	Version      xep0092.Config
-	Ping         xep0199.Config
+	// Enabled contains the name of every enabled module.
+	Enabled map[string]struct{}
+
+	// Settings contains each module configuration section
+	// indexed by module name.
+	Settings map[string]interface{}
 }
 
 type configProxy struct {
-	Enabled      []string       `yaml:"enabled"`
-	Roster       roster.Config  `yaml:"mod_roster"`
-	Offline      offline.Config `yaml:"mod_offline"`
-	Registration xep0077.Config `yaml:"mod_registration"`
-	Version      xep0092.Config `yaml:"mod_version"`
-	Ping         xep0199.Config `yaml:"mod_ping"`
+	Enabled  []string               `yaml:"enabled"`
+	Settings map[string]interface{} `yaml:",inline"`
 }
 
 // UnmarshalYAML satisfies Unmarshaler interface.
@@ -43,20 +38,42 @@ func (cfg *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	// validate modules
 	enabled := make(map[string]struct{}, len(p.Enabled))
 	for _, mod := range p.Enabled {
-		switch mod {
-		case "roster", "last_activity", "private", "vcard", "registration", "version", "blocking_command",
-			"ping", "offline":
-			break
-		default:
+		if !IsRegistered(mod) {
 			return fmt.Errorf("module.Config: unrecognized module: %s", mod)
 		}
 		enabled[mod] = struct{}{}
 	}
+	settings := make(map[string]interface{}, len(p.Settings))
+	for k, v := range p.Settings {
+		if !strings.HasPrefix(k, settingsKeyPrefix) {
+			return fmt.Errorf("module.Config: unrecognized key: %s", k)
+		}
+		settings[k[len(settingsKeyPrefix):]] = v
+	}
 	cfg.Enabled = enabled
-	cfg.Roster = p.Roster
-	cfg.Offline = p.Offline
-	cfg.Registration = p.Registration
-	cfg.Version = p.Version
-	cfg.Ping = p.Ping
+	cfg.Settings = settings
+	return nil
+}
+
+// IsEnabled returns whether or not a module has been enabled.
+func (cfg *Config) IsEnabled(name string) bool {
+	_, ok := cfg.Enabled[name]
+	return ok
+}
+
+// Decode decodes a module configuration section into v.
+// In case no section was provided for the module v is left untouched.
+func (cfg *Config) Decode(name string, v interface{}) error {
+	s, ok := cfg.Settings[name]
+	if !ok {
+		return nil
+	}
+	b, err := yaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err := yaml.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("module.Config: %s: %v", name, err)
+	}
 	return nil
 }
@@ -13,14 +13,52 @@ import (
 )
 
 func TestModuleConfig(t *testing.T) {
-	badCfg := `enabled [roster]`
+	badCfg := `enabled [fake]`
 	cfg := &Config{}
 	err := yaml.Unmarshal([]byte(badCfg), &cfg)
 	require.NotNil(t, err)
 	badMod := `enabled: [bad_mod]`
 	err = yaml.Unmarshal([]byte(badMod), &cfg)
 	require.NotNil(t, err)
-	validMod := `enabled: [roster]`
+	badKey := `
+enabled: [fake]
+fake:
+  service: chat
+`
+	err = yaml.Unmarshal([]byte(badKey), &cfg)
+	require.NotNil(t, err)
+	validMod := `enabled: [fake]`
 	err = yaml.Unmarshal([]byte(validMod), &cfg)
 	require.Nil(t, err)
+	require.True(t, cfg.IsEnabled("fake"))
+	require.False(t, cfg.IsEnabled("fake_handler"))
+
+	fakeMod := `
+enabled: [fake, fake_handler]
+mod_fake:
+  service: chat
+  max_history: 50
+`
+	err = yaml.Unmarshal([]byte(fakeMod), &cfg)
+	require.Nil(t, err)
+	require.True(t, cfg.IsEnabled("fake_handler"))
+
+	var fakeCfg struct {
+		Service    string `yaml:"service"`
+		MaxHistory int    `yaml:"max_history"`
+	}
+	require.Nil(t, cfg.Decode("fake", &fakeCfg))
+	require.Equal(t, "chat", fakeCfg.Service)
+	require.Equal(t, 50, fakeCfg.MaxHistory)
+
+	// missing section
+	fakeCfg.Service = ""
+	require.Nil(t, cfg.Decode("fake_handler", &fakeCfg))
+	require.Equal(t, "", fakeCfg.Service)
+
+	// section type mismatch
+	var badFakeCfg struct {
+		MaxHistory []string `yaml:"max_history"`
+	}
+	require.NotNil(t, cfg.Decode("fake", &badFakeCfg))
 }
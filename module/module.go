@@ -7,6 +7,7 @@ package module
 
 import (
 	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/stream"
 	"github.com/ortuman/jackal/xml"
 )
 
@@ -26,6 +27,82 @@ type IQHandler interface {
 	MatchesIQ(iq *xml.IQ) bool
 
 	// ProcessIQ processes a module IQ taking according actions
-	// over the associated stream.
-	ProcessIQ(iq *xml.IQ)
+	// over the originating stream.
+	ProcessIQ(iq *xml.IQ, stm stream.C2S)
+}
+
+// PresenceHandler represents a module that gets notified of
+// every presence stanza sent by a local stream.
+type PresenceHandler interface {
+	Module
+
+	// ProcessPresence processes a presence sent by the
+	// originating stream.
+	ProcessPresence(presence *xml.Presence, stm stream.C2S)
+}
+
+// MessageHandler represents a module that gets notified of
+// every message stanza sent by a local stream before being routed.
+type MessageHandler interface {
+	Module
+
+	// ProcessMessage processes a message sent by the
+	// originating stream.
+	ProcessMessage(message *xml.Message, stm stream.C2S)
+}
+
+// RouteHandler represents a module that gets notified of every message
+// sent by or addressed to a local host user, right before being delivered
+// to its destination.
+type RouteHandler interface {
+	Module
+
+	// ProcessRoutedMessage processes a message being routed.
+	ProcessRoutedMessage(message *xml.Message)
+}
+
+// DeliveryHandler represents a module that gets notified of
+// every message delivered to a local stream.
+type DeliveryHandler interface {
+	Module
+
+	// ProcessDeliveredMessage processes a message delivered
+	// to the recipient stream.
+	ProcessDeliveredMessage(message *xml.Message, stm stream.C2S)
+}
+
+// StreamHandler represents a module that gets notified of
+// local streams session lifecycle.
+type StreamHandler interface {
+	Module
+
+	// StreamStarted is invoked once a stream session has been started.
+	StreamStarted(stm stream.C2S)
+
+	// StreamClosed is invoked once a started stream session is closed.
+	StreamClosed(stm stream.C2S)
+}
+
+// ServiceHandler represents a module hosting a service entity reachable
+// under its own domain, such as a multi-user chat or a publish-subscribe service.
+type ServiceHandler interface {
+	Module
+
+	// ServiceDomain returns the domain service is reachable at.
+	ServiceDomain() string
+
+	// ProcessStanza processes a stanza addressed to the service
+	// or to any of its hosted entities.
+	ProcessStanza(stanza xml.Stanza)
+}
+
+// Initializer represents a module that needs to interact with
+// other modules of its same host once all of them have been instantiated.
+type Initializer interface {
+	Module
+
+	// Initialize is invoked once every host module has been instantiated,
+	// right before registering disco entities.
+	// lookup returns the enabled host module registered under a given name.
+	Initialize(lookup func(name string) Module) error
 }
@@ -7,72 +7,117 @@ package offline
 
 import (
 	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/module"
 	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/module/xep0357"
 	"github.com/ortuman/jackal/storage"
 	"github.com/ortuman/jackal/stream"
 	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 const offlineNamespace = "msgoffline"
 
+const offlineDeliveredCtxKey = "offline:delivered"
+
+var archivedMessages = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "jackal_offline_messages_archived_total",
+	Help: "Total number of archived offline messages.",
+})
+
 // Config represents Offline Storage module configuration.
 type Config struct {
 	QueueSize int `yaml:"queue_size"`
 }
 
-// Offline represents an offline server stream module.
+func init() {
+	prometheus.MustRegister(archivedMessages)
+
+	module.Register("offline", func(_ string, cfg *module.Config) (module.Module, error) {
+		var config Config
+		if err := cfg.Decode("offline", &config); err != nil {
+			return nil, err
+		}
+		return New(&config), nil
+	})
+}
+
+// Offline represents an offline server module.
 type Offline struct {
 	cfg     *Config
-	stm     stream.C2S
 	actorCh chan func()
+	doneCh  chan chan struct{}
 }
 
-// New returns an offline server stream module.
-func New(config *Config, stm stream.C2S) *Offline {
-	r := &Offline{
+// New returns an offline server module.
+func New(config *Config) *Offline {
+	o := &Offline{
 		cfg:     config,
-		stm:     stm,
-		actorCh: make(chan func(), 32),
+		actorCh: make(chan func(), 64),
+		doneCh:  make(chan chan struct{}),
 	}
-	go r.actorLoop(stm.Context().Done())
-	return r
+	go o.loop()
+	return o
 }
 
 // RegisterDisco registers disco entity features/items
 // associated to offline module.
 func (o *Offline) RegisterDisco(discoInfo *xep0030.DiscoInfo) {
-	discoInfo.Entity(o.stm.Domain(), "").AddFeature(offlineNamespace)
+	discoInfo.ServerEntity().AddFeature(offlineNamespace)
+}
+
+// ProcessPresence delivers archived offline messages once
+// the stream broadcasts its first available presence.
+func (o *Offline) ProcessPresence(presence *xml.Presence, stm stream.C2S) {
+	if !presence.IsAvailable() || presence.Priority() < 0 {
+		return
+	}
+	if !stm.JID().Matches(presence.ToJID(), jid.MatchesBare) || stm.Context().Bool(offlineDeliveredCtxKey) {
+		return
+	}
+	stm.Context().SetBool(true, offlineDeliveredCtxKey)
+	o.DeliverOfflineMessages(stm)
 }
 
 // ArchiveMessage archives a new offline messages into the storage.
-func (o *Offline) ArchiveMessage(message *xml.Message) {
+func (o *Offline) ArchiveMessage(message *xml.Message, stm stream.C2S) {
 	o.actorCh <- func() {
-		o.archiveMessage(message)
+		o.archiveMessage(message, stm)
 	}
 }
 
 // DeliverOfflineMessages delivers every archived offline messages to the peer
 // deleting them from storage.
-func (o *Offline) DeliverOfflineMessages() {
+func (o *Offline) DeliverOfflineMessages(stm stream.C2S) {
 	o.actorCh <- func() {
-		o.deliverOfflineMessages()
+		o.deliverOfflineMessages(stm)
 	}
 }
 
-func (o *Offline) actorLoop(doneCh <-chan struct{}) {
+// Shutdown shuts down offline module.
+func (o *Offline) Shutdown() {
+	ch := make(chan struct{})
+	o.doneCh <- ch
+	<-ch
+}
+
+// runs on it's own goroutine
+func (o *Offline) loop() {
 	for {
 		select {
 		case f := <-o.actorCh:
 			f()
-		case <-doneCh:
+		case ch := <-o.doneCh:
+			close(ch)
 			return
 		}
 	}
 }
 
-func (o *Offline) archiveMessage(message *xml.Message) {
+func (o *Offline) archiveMessage(message *xml.Message, stm stream.C2S) {
 	toJid := message.ToJID()
-	queueSize, err := storage.Instance().CountOfflineMessages(toJid.Node())
+	queueSize, err := storage.Instance().CountOfflineMessages(toJid.Node(), toJid.Domain())
 	if err != nil {
 		log.Error(err)
 		return
@@ -80,21 +125,27 @@ func (o *Offline) archiveMessage(message *xml.Message) {
 	if queueSize >= o.cfg.QueueSize {
 		response := xml.NewElementFromElement(message)
 		response.SetFrom(toJid.String())
-		response.SetTo(o.stm.JID().String())
-		o.stm.SendElement(response.ServiceUnavailableError())
+		response.SetTo(stm.JID().String())
+		stm.SendElement(response.ServiceUnavailableError())
 		return
 	}
 	delayed := xml.NewElementFromElement(message)
-	delayed.Delay(o.stm.Domain(), "Offline Storage")
-	if err := storage.Instance().InsertOfflineMessage(delayed, toJid.Node()); err != nil {
+	delayed.Delay(stm.Domain(), "Offline Storage")
+	if err := storage.Instance().InsertOfflineMessage(delayed, toJid.Node(), toJid.Domain()); err != nil {
 		log.Errorf("%v", err)
 		return
 	}
+	archivedMessages.Inc()
 	log.Infof("archived offline message... id: %s", message.ID())
+
+	// XEP-0357: Push Notifications (https://xmpp.org/extensions/xep-0357.html)
+	if push, ok := module.Lookup(toJid.Domain(), "push").(*xep0357.Push); ok {
+		push.NotifyOfflineMessage(message, queueSize+1)
+	}
 }
 
-func (o *Offline) deliverOfflineMessages() {
-	messages, err := storage.Instance().FetchOfflineMessages(o.stm.Username())
+func (o *Offline) deliverOfflineMessages(stm stream.C2S) {
+	messages, err := storage.Instance().FetchOfflineMessages(stm.Username(), stm.Domain())
 	if err != nil {
 		log.Error(err)
 		return
@@ -105,9 +156,9 @@ func (o *Offline) deliverOfflineMessages() {
 	log.Infof("delivering offline messages... count: %d", len(messages))
 
 	for _, m := range messages {
-		o.stm.SendElement(m)
+		stm.SendElement(m)
 	}
-	if err := storage.Instance().DeleteOfflineMessages(o.stm.Username()); err != nil {
+	if err := storage.Instance().DeleteOfflineMessages(stm.Username(), stm.Domain()); err != nil {
 		log.Error(err)
 	}
 }
@@ -9,11 +9,16 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/module"
+	"github.com/ortuman/jackal/router"
 	"github.com/ortuman/jackal/storage"
 	"github.com/ortuman/jackal/stream"
 	"github.com/ortuman/jackal/xml"
 	"github.com/ortuman/jackal/xml/jid"
 	"github.com/pborman/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 )
 
@@ -27,26 +32,30 @@ func TestOffline_ArchiveMessage(t *testing.T) {
 	stm := stream.NewMockC2S("abcd", j1)
 	stm.SetDomain("jackal.im")
 
-	x := New(&Config{QueueSize: 1}, stm)
+	x := New(&Config{QueueSize: 1})
+	defer x.Shutdown()
+
+	archived := testutil.ToFloat64(archivedMessages)
 
 	msgID := uuid.New()
 	msg := xml.NewMessageType(msgID, "normal")
 	msg.SetFromJID(j1)
 	msg.SetToJID(j2)
-	x.ArchiveMessage(msg)
+	x.ArchiveMessage(msg, stm)
 
 	// wait for insertion...
 	time.Sleep(time.Millisecond * 250)
 
-	msgs, err := storage.Instance().FetchOfflineMessages("juliet")
+	msgs, err := storage.Instance().FetchOfflineMessages("juliet", "jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, 1, len(msgs))
+	require.Equal(t, archived+1, testutil.ToFloat64(archivedMessages))
 
 	msg2 := xml.NewMessageType(msgID, "normal")
 	msg2.SetFromJID(j1)
 	msg2.SetToJID(j2)
 
-	x.ArchiveMessage(msg)
+	x.ArchiveMessage(msg, stm)
 
 	elem := stm.FetchElement()
 	require.NotNil(t, elem)
@@ -56,10 +65,83 @@ func TestOffline_ArchiveMessage(t *testing.T) {
 	stm2 := stream.NewMockC2S("abcd", j2)
 	stm2.SetDomain("jackal.im")
 
-	x2 := New(&Config{QueueSize: 1}, stm2)
-	x2.DeliverOfflineMessages()
+	x.DeliverOfflineMessages(stm2)
 
 	elem = stm2.FetchElement()
 	require.NotNil(t, elem)
 	require.Equal(t, msgID, elem.ID())
 }
+
+func TestOffline_DeliverOnAvailablePresence(t *testing.T) {
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	defer storage.Shutdown()
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("juliet", "jackal.im", "garden", true)
+
+	msg := xml.NewMessageType(uuid.New(), "normal")
+	msg.SetFromJID(j1)
+	msg.SetToJID(j2)
+	storage.Instance().InsertOfflineMessage(msg, "juliet", "jackal.im")
+
+	stm := stream.NewMockC2S("abcd", j2)
+	stm.SetDomain("jackal.im")
+
+	x := New(&Config{QueueSize: 1})
+	defer x.Shutdown()
+
+	// directed presence
+	x.ProcessPresence(xml.NewPresence(j2, j1.ToBareJID(), xml.AvailableType), stm)
+	time.Sleep(time.Millisecond * 250)
+	cnt, _ := storage.Instance().CountOfflineMessages("juliet", "jackal.im")
+	require.Equal(t, 1, cnt)
+
+	x.ProcessPresence(xml.NewPresence(j2, j2.ToBareJID(), xml.AvailableType), stm)
+	elem := stm.FetchElement()
+	require.NotNil(t, elem)
+	require.Equal(t, msg.ID(), elem.ID())
+	require.True(t, stm.Context().Bool(offlineDeliveredCtxKey))
+}
+
+func TestOffline_PushNotification(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	router.Initialize(&router.Config{})
+	module.Initialize(&module.Config{
+		Enabled:  map[string]struct{}{"offline": {}, "push": {}},
+		Settings: map[string]interface{}{"offline": map[string]interface{}{"queue_size": 10}},
+	})
+	defer func() {
+		module.Shutdown()
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("juliet", "jackal.im", "garden", true)
+	pushJID, _ := jid.New("push", "jackal.im", "app", true)
+
+	pushStm := stream.NewMockC2S("push", pushJID)
+	router.Bind(pushStm)
+
+	storage.Instance().InsertOrUpdatePushRegistration(&model.PushRegistration{
+		Username: "juliet",
+		Domain:   "jackal.im",
+		JID:      pushJID.String(),
+		Node:     "yxs32uqsflafdk3iuqo",
+	})
+
+	stm := stream.NewMockC2S("abcd", j1)
+	stm.SetDomain("jackal.im")
+
+	msg := xml.NewMessageType(uuid.New(), "normal")
+	msg.SetFromJID(j1)
+	msg.SetToJID(j2)
+	module.Lookup("jackal.im", "offline").(*Offline).ArchiveMessage(msg, stm)
+
+	elem := pushStm.FetchElement()
+	require.Equal(t, "iq", elem.Name())
+	require.Equal(t, "juliet@jackal.im", elem.From())
+	require.NotNil(t, elem.Elements().ChildNamespace("pubsub", "http://jabber.org/protocol/pubsub"))
+}
@@ -0,0 +1,279 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package module
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/module/xep0030"
+)
+
+// Factory returns a new module instance associated to a local host.
+type Factory func(host string, cfg *Config) (Module, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]Factory)
+)
+
+// Register makes a module available by the provided name.
+// If Register is called twice with the same name or if factory is nil, it panics.
+func Register(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	if factory == nil {
+		panic("module: Register factory is nil")
+	}
+	if _, dup := factories[name]; dup {
+		panic("module: Register called twice for module " + name)
+	}
+	factories[name] = factory
+}
+
+// IsRegistered returns whether or not a module has been
+// registered under the provided name.
+func IsRegistered(name string) bool {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	_, ok := factories[name]
+	return ok
+}
+
+type shutdowner interface {
+	Shutdown()
+}
+
+type hostModules struct {
+	discoInfo        *xep0030.DiscoInfo
+	byName           map[string]Module
+	all              []Module
+	iqHandlers       []IQHandler
+	presenceHandlers []PresenceHandler
+	messageHandlers  []MessageHandler
+	routeHandlers    []RouteHandler
+	deliveryHandlers []DeliveryHandler
+	streamHandlers   []StreamHandler
+	serviceHandlers  []ServiceHandler
+}
+
+func newHostModules(domain string, cfg *Config) (*hostModules, error) {
+	var names []string
+	for name := range cfg.Enabled {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// XEP-0030: Service Discovery (https://xmpp.org/extensions/xep-0030.html)
+	discoInfo := xep0030.New(domain)
+
+	m := &hostModules{
+		discoInfo:  discoInfo,
+		byName:     make(map[string]Module),
+		all:        []Module{discoInfo},
+		iqHandlers: []IQHandler{discoInfo},
+	}
+	for _, name := range names {
+		factoriesMu.RLock()
+		factory := factories[name]
+		factoriesMu.RUnlock()
+		if factory == nil {
+			return nil, fmt.Errorf("module: unrecognized module: %s", name)
+		}
+		mod, err := factory(domain, cfg)
+		if err != nil {
+			m.shutdown()
+			return nil, err
+		}
+		m.byName[name] = mod
+		m.all = append(m.all, mod)
+
+		if h, ok := mod.(IQHandler); ok {
+			m.iqHandlers = append(m.iqHandlers, h)
+		}
+		if h, ok := mod.(PresenceHandler); ok {
+			m.presenceHandlers = append(m.presenceHandlers, h)
+		}
+		if h, ok := mod.(MessageHandler); ok {
+			m.messageHandlers = append(m.messageHandlers, h)
+		}
+		if h, ok := mod.(RouteHandler); ok {
+			m.routeHandlers = append(m.routeHandlers, h)
+		}
+		if h, ok := mod.(DeliveryHandler); ok {
+			m.deliveryHandlers = append(m.deliveryHandlers, h)
+		}
+		if h, ok := mod.(StreamHandler); ok {
+			m.streamHandlers = append(m.streamHandlers, h)
+		}
+		if h, ok := mod.(ServiceHandler); ok {
+			m.serviceHandlers = append(m.serviceHandlers, h)
+		}
+	}
+	// initialize inter-module dependencies
+	for _, mod := range m.all {
+		if i, ok := mod.(Initializer); ok {
+			if err := i.Initialize(m.lookup); err != nil {
+				m.shutdown()
+				return nil, err
+			}
+		}
+	}
+	// register disco info elements
+	for _, mod := range m.all {
+		mod.RegisterDisco(discoInfo)
+	}
+	return m, nil
+}
+
+func (m *hostModules) lookup(name string) Module {
+	return m.byName[name]
+}
+
+func (m *hostModules) shutdown() {
+	for _, mod := range m.all {
+		if s, ok := mod.(shutdowner); ok {
+			s.Shutdown()
+		}
+	}
+}
+
+// singleton interface
+var (
+	instMu      sync.RWMutex
+	hosts       map[string]*hostModules
+	services    map[string]ServiceHandler
+	initialized bool
+)
+
+// Initialize instantiates every enabled module once
+// for each configured local host.
+func Initialize(cfg *Config) {
+	instMu.Lock()
+	defer instMu.Unlock()
+	if initialized {
+		return
+	}
+	hosts = make(map[string]*hostModules)
+	services = make(map[string]ServiceHandler)
+	for _, h := range host.HostNames() {
+		mods, err := newHostModules(h, cfg)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		hosts[h] = mods
+
+		for _, svc := range mods.serviceHandlers {
+			if _, dup := services[svc.ServiceDomain()]; dup {
+				log.Fatalf("module: duplicated service domain: %s", svc.ServiceDomain())
+			}
+			services[svc.ServiceDomain()] = svc
+		}
+	}
+	initialized = true
+}
+
+// Shutdown shuts down every instantiated module.
+func Shutdown() {
+	instMu.Lock()
+	defer instMu.Unlock()
+	if !initialized {
+		return
+	}
+	for _, mods := range hosts {
+		mods.shutdown()
+	}
+	hosts = nil
+	services = nil
+	initialized = false
+}
+
+// Lookup returns the module instance registered under
+// the provided name for a local host.
+func Lookup(host, name string) Module {
+	if mods := hostMods(host); mods != nil {
+		return mods.byName[name]
+	}
+	return nil
+}
+
+// DiscoInfo returns the disco info module associated to a local host.
+func DiscoInfo(host string) *xep0030.DiscoInfo {
+	if mods := hostMods(host); mods != nil {
+		return mods.discoInfo
+	}
+	return nil
+}
+
+// IQHandlers returns every IQ handler module associated to a local host.
+func IQHandlers(host string) []IQHandler {
+	if mods := hostMods(host); mods != nil {
+		return mods.iqHandlers
+	}
+	return nil
+}
+
+// PresenceHandlers returns every presence handler module
+// associated to a local host.
+func PresenceHandlers(host string) []PresenceHandler {
+	if mods := hostMods(host); mods != nil {
+		return mods.presenceHandlers
+	}
+	return nil
+}
+
+// MessageHandlers returns every message handler module
+// associated to a local host.
+func MessageHandlers(host string) []MessageHandler {
+	if mods := hostMods(host); mods != nil {
+		return mods.messageHandlers
+	}
+	return nil
+}
+
+// RouteHandlers returns every route handler module
+// associated to a local host.
+func RouteHandlers(host string) []RouteHandler {
+	if mods := hostMods(host); mods != nil {
+		return mods.routeHandlers
+	}
+	return nil
+}
+
+// DeliveryHandlers returns every delivery handler module
+// associated to a local host.
+func DeliveryHandlers(host string) []DeliveryHandler {
+	if mods := hostMods(host); mods != nil {
+		return mods.deliveryHandlers
+	}
+	return nil
+}
+
+// StreamHandlers returns every stream handler module
+// associated to a local host.
+func StreamHandlers(host string) []StreamHandler {
+	if mods := hostMods(host); mods != nil {
+		return mods.streamHandlers
+	}
+	return nil
+}
+
+// Service returns the service handler module reachable
+// at the provided domain.
+func Service(domain string) ServiceHandler {
+	instMu.RLock()
+	defer instMu.RUnlock()
+	return services[domain]
+}
+
+func hostMods(host string) *hostModules {
+	instMu.RLock()
+	defer instMu.RUnlock()
+	return hosts[host]
+}
@@ -0,0 +1,193 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package module
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xml"
+	"github.com/stretchr/testify/require"
+)
+
+const fakeNamespace = "urn:jackal:fake"
+
+var shutdownCount int32
+
+type fakeModule struct {
+	host string
+}
+
+func (m *fakeModule) RegisterDisco(discoInfo *xep0030.DiscoInfo) {
+	discoInfo.ServerEntity().AddFeature(fakeNamespace)
+}
+
+func (m *fakeModule) Shutdown() {
+	atomic.AddInt32(&shutdownCount, 1)
+}
+
+type fakeHandler struct {
+	fakeModule
+}
+
+func (m *fakeHandler) MatchesIQ(iq *xml.IQ) bool {
+	return iq.Elements().ChildNamespace("query", fakeNamespace) != nil
+}
+
+func (m *fakeHandler) ProcessIQ(iq *xml.IQ, stm stream.C2S) {
+	stm.SendElement(iq.ResultIQ())
+}
+
+func (m *fakeHandler) ProcessPresence(presence *xml.Presence, stm stream.C2S) {}
+
+func (m *fakeHandler) ProcessMessage(message *xml.Message, stm stream.C2S) {}
+
+func (m *fakeHandler) ProcessRoutedMessage(message *xml.Message) {}
+
+func (m *fakeHandler) ProcessDeliveredMessage(message *xml.Message, stm stream.C2S) {}
+
+func (m *fakeHandler) StreamStarted(stm stream.C2S) {}
+
+func (m *fakeHandler) StreamClosed(stm stream.C2S) {}
+
+type fakeService struct {
+	fakeModule
+	stanzas int32
+}
+
+func (m *fakeService) ServiceDomain() string {
+	return "fake." + m.host
+}
+
+func (m *fakeService) ProcessStanza(stanza xml.Stanza) {
+	atomic.AddInt32(&m.stanzas, 1)
+}
+
+type fakeDependent struct {
+	fakeModule
+	dependency Module
+}
+
+func (m *fakeDependent) Initialize(lookup func(name string) Module) error {
+	if m.dependency = lookup("fake"); m.dependency == nil {
+		return errors.New("module: fake dependency not enabled")
+	}
+	return nil
+}
+
+func init() {
+	Register("fake", func(h string, _ *Config) (Module, error) {
+		return &fakeModule{host: h}, nil
+	})
+	Register("fake_handler", func(h string, _ *Config) (Module, error) {
+		return &fakeHandler{fakeModule{host: h}}, nil
+	})
+	Register("fake_service", func(h string, _ *Config) (Module, error) {
+		return &fakeService{fakeModule: fakeModule{host: h}}, nil
+	})
+	Register("fake_dependent", func(h string, _ *Config) (Module, error) {
+		return &fakeDependent{fakeModule: fakeModule{host: h}}, nil
+	})
+	Register("fake_failing", func(_ string, _ *Config) (Module, error) {
+		return nil, errors.New("module: fake failure")
+	})
+}
+
+func TestModule_Register(t *testing.T) {
+	require.True(t, IsRegistered("fake"))
+	require.False(t, IsRegistered("unknown"))
+
+	require.Panics(t, func() { Register("fake", func(_ string, _ *Config) (Module, error) { return nil, nil }) })
+	require.Panics(t, func() { Register("fake_nil", nil) })
+}
+
+func TestModule_Initialize(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}, {Name: "example.org"}})
+	defer host.Shutdown()
+
+	atomic.StoreInt32(&shutdownCount, 0)
+
+	Initialize(&Config{Enabled: map[string]struct{}{"fake": {}, "fake_handler": {}}})
+
+	for _, h := range []string{"jackal.im", "example.org"} {
+		mod, ok := Lookup(h, "fake").(*fakeModule)
+		require.True(t, ok)
+		require.Equal(t, h, mod.host)
+		require.NotNil(t, Lookup(h, "fake_handler"))
+		require.Nil(t, Lookup(h, "unknown"))
+
+		discoInfo := DiscoInfo(h)
+		require.NotNil(t, discoInfo)
+		require.Equal(t, h, discoInfo.Domain())
+		require.Contains(t, discoInfo.ServerEntity().Features(), fakeNamespace)
+
+		// disco info always comes first
+		require.Equal(t, 2, len(IQHandlers(h)))
+		require.Equal(t, discoInfo, IQHandlers(h)[0])
+
+		require.Equal(t, 1, len(PresenceHandlers(h)))
+		require.Equal(t, 1, len(MessageHandlers(h)))
+		require.Equal(t, 1, len(RouteHandlers(h)))
+		require.Equal(t, 1, len(DeliveryHandlers(h)))
+		require.Equal(t, 1, len(StreamHandlers(h)))
+	}
+	// instances are not shared between hosts
+	require.True(t, Lookup("jackal.im", "fake") != Lookup("example.org", "fake"))
+
+	require.Nil(t, Lookup("jabber.org", "fake"))
+	require.Nil(t, DiscoInfo("jabber.org"))
+	require.Nil(t, IQHandlers("jabber.org"))
+
+	Shutdown()
+	require.Equal(t, int32(4), atomic.LoadInt32(&shutdownCount))
+	require.Nil(t, Lookup("jackal.im", "fake"))
+}
+
+func TestModule_Service(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}, {Name: "example.org"}})
+	defer host.Shutdown()
+
+	Initialize(&Config{Enabled: map[string]struct{}{"fake": {}, "fake_service": {}}})
+	defer Shutdown()
+
+	for _, h := range []string{"jackal.im", "example.org"} {
+		svc := Service("fake." + h)
+		require.NotNil(t, svc)
+		require.Equal(t, Lookup(h, "fake_service"), svc)
+	}
+	require.Nil(t, Service("jackal.im"))
+	require.Nil(t, Service("fake.jabber.org"))
+
+	Service("fake.jackal.im").ProcessStanza(xml.NewMessageType("1234", xml.ChatType))
+	require.Equal(t, int32(1), atomic.LoadInt32(&Lookup("jackal.im", "fake_service").(*fakeService).stanzas))
+	require.Equal(t, int32(0), atomic.LoadInt32(&Lookup("example.org", "fake_service").(*fakeService).stanzas))
+}
+
+func TestModule_InitializeFailure(t *testing.T) {
+	_, err := newHostModules("jackal.im", &Config{Enabled: map[string]struct{}{"unknown": {}}})
+	require.NotNil(t, err)
+
+	_, err = newHostModules("jackal.im", &Config{Enabled: map[string]struct{}{"fake_failing": {}}})
+	require.NotNil(t, err)
+}
+
+func TestModule_InitializeDependencies(t *testing.T) {
+	m, err := newHostModules("jackal.im", &Config{Enabled: map[string]struct{}{"fake": {}, "fake_dependent": {}}})
+	require.Nil(t, err)
+	defer m.shutdown()
+
+	dep, ok := m.byName["fake_dependent"].(*fakeDependent)
+	require.True(t, ok)
+	require.Equal(t, m.byName["fake"], dep.dependency)
+
+	// missing dependency
+	_, err = newHostModules("jackal.im", &Config{Enabled: map[string]struct{}{"fake_dependent": {}}})
+	require.NotNil(t, err)
+}
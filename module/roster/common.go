@@ -26,26 +26,26 @@ func insertItem(ri *rostermodel.Item, pushTo *jid.JID, versioning bool) error {
 		return err
 	}
 	ri.Ver = v.Ver
-	return pushItem(ri, pushTo, versioning)
+	return pushItem(ri, pushTo.Node(), pushTo.Domain(), versioning)
 }
 
 func deleteItem(ri *rostermodel.Item, pushTo *jid.JID, versioning bool) error {
-	v, err := storage.Instance().DeleteRosterItem(ri.Username, ri.JID)
+	v, err := storage.Instance().DeleteRosterItem(ri.Username, ri.Domain, ri.JID)
 	if err != nil {
 		return err
 	}
 	ri.Ver = v.Ver
-	return pushItem(ri, pushTo, versioning)
+	return pushItem(ri, pushTo.Node(), pushTo.Domain(), versioning)
 }
 
-func pushItem(ri *rostermodel.Item, to *jid.JID, versioning bool) error {
+func pushItem(ri *rostermodel.Item, username, domain string, versioning bool) error {
 	query := xml.NewElementNamespace("query", rosterNamespace)
 	if versioning {
 		query.SetAttribute("ver", fmt.Sprintf("v%d", ri.Ver))
 	}
 	query.AppendElement(ri.Element())
 
-	stms := router.UserStreams(to.Node())
+	stms := router.UserStreams(username, domain)
 	for _, stm := range stms {
 		if !stm.Context().Bool(rosterRequestedCtxKey) {
 			continue
@@ -58,23 +58,24 @@ func pushItem(ri *rostermodel.Item, to *jid.JID, versioning bool) error {
 	return nil
 }
 
-func deleteNotification(contact string, userJID *jid.JID) (deleted bool, err error) {
-	rn, err := storage.Instance().FetchRosterNotification(contact, userJID.String())
+func deleteNotification(contact, domain string, userJID *jid.JID) (deleted bool, err error) {
+	rn, err := storage.Instance().FetchRosterNotification(contact, domain, userJID.String())
 	if err != nil {
 		return false, err
 	}
 	if rn == nil {
 		return false, nil
 	}
-	if err := storage.Instance().DeleteRosterNotification(contact, userJID.String()); err != nil {
+	if err := storage.Instance().DeleteRosterNotification(contact, domain, userJID.String()); err != nil {
 		return false, err
 	}
 	return true, nil
 }
 
-func insertOrUpdateNotification(contact string, userJID *jid.JID, presence *xml.Presence) error {
+func insertOrUpdateNotification(contact, domain string, userJID *jid.JID, presence *xml.Presence) error {
 	rn := &rostermodel.Notification{
 		Contact:  contact,
+		Domain:   domain,
 		JID:      userJID.String(),
 		Presence: presence,
 	}
@@ -82,7 +83,7 @@ func insertOrUpdateNotification(contact string, userJID *jid.JID, presence *xml.
 }
 
 func routePresencesFrom(from *jid.JID, to *jid.JID, presenceType string) {
-	stms := router.UserStreams(from.Node())
+	stms := router.UserStreams(from.Node(), from.Domain())
 	for _, stm := range stms {
 		p := xml.NewPresence(stm.JID(), to.ToBareJID(), presenceType)
 		if presence := stm.Presence(); presence != nil && presence.IsAvailable() {
@@ -81,7 +81,7 @@ func (ph *PresenceHandler) processSubscribe(presence *xml.Presence) error {
 	log.Infof("processing 'subscribe' - contact: %s (%s)", contactJID, userJID)
 
 	if host.IsLocalHost(userJID.Domain()) {
-		usrRi, err := storage.Instance().FetchRosterItem(userJID.Node(), contactJID.String())
+		usrRi, err := storage.Instance().FetchRosterItem(userJID.Node(), userJID.Domain(), contactJID.String())
 		if err != nil {
 			return err
 		}
@@ -100,6 +100,7 @@ func (ph *PresenceHandler) processSubscribe(presence *xml.Presence) error {
 			// create roster item if not previously created
 			usrRi = &rostermodel.Item{
 				Username:     userJID.Node(),
+				Domain:       userJID.Domain(),
 				JID:          contactJID.String(),
 				Subscription: rostermodel.SubscriptionNone,
 				Ask:          true,
@@ -115,7 +116,7 @@ func (ph *PresenceHandler) processSubscribe(presence *xml.Presence) error {
 
 	if host.IsLocalHost(contactJID.Domain()) {
 		// archive roster approval notification
-		if err := insertOrUpdateNotification(contactJID.Node(), userJID, p); err != nil {
+		if err := insertOrUpdateNotification(contactJID.Node(), contactJID.Domain(), userJID, p); err != nil {
 			return err
 		}
 	}
@@ -130,11 +131,11 @@ func (ph *PresenceHandler) processSubscribed(presence *xml.Presence) error {
 	log.Infof("processing 'subscribed' - user: %s (%s)", userJID, contactJID)
 
 	if host.IsLocalHost(contactJID.Domain()) {
-		_, err := deleteNotification(contactJID.Node(), userJID)
+		_, err := deleteNotification(contactJID.Node(), contactJID.Domain(), userJID)
 		if err != nil {
 			return err
 		}
-		cntRi, err := storage.Instance().FetchRosterItem(contactJID.Node(), userJID.String())
+		cntRi, err := storage.Instance().FetchRosterItem(contactJID.Node(), contactJID.Domain(), userJID.String())
 		if err != nil {
 			return err
 		}
@@ -149,6 +150,7 @@ func (ph *PresenceHandler) processSubscribed(presence *xml.Presence) error {
 			// create roster item if not previously created
 			cntRi = &rostermodel.Item{
 				Username:     contactJID.Node(),
+				Domain:       contactJID.Domain(),
 				JID:          userJID.String(),
 				Subscription: rostermodel.SubscriptionFrom,
 				Ask:          false,
@@ -163,7 +165,7 @@ func (ph *PresenceHandler) processSubscribed(presence *xml.Presence) error {
 	p.AppendElements(presence.Elements().All())
 
 	if host.IsLocalHost(userJID.Domain()) {
-		usrRi, err := storage.Instance().FetchRosterItem(userJID.Node(), contactJID.String())
+		usrRi, err := storage.Instance().FetchRosterItem(userJID.Node(), userJID.Domain(), contactJID.String())
 		if err != nil {
 			return err
 		}
@@ -195,7 +197,7 @@ func (ph *PresenceHandler) processUnsubscribe(presence *xml.Presence) error {
 
 	var usrSub string
 	if host.IsLocalHost(userJID.Domain()) {
-		usrRi, err := storage.Instance().FetchRosterItem(userJID.Node(), contactJID.String())
+		usrRi, err := storage.Instance().FetchRosterItem(userJID.Node(), userJID.Domain(), contactJID.String())
 		if err != nil {
 			return err
 		}
@@ -218,7 +220,7 @@ func (ph *PresenceHandler) processUnsubscribe(presence *xml.Presence) error {
 	p.AppendElements(presence.Elements().All())
 
 	if host.IsLocalHost(contactJID.Domain()) {
-		cntRi, err := storage.Instance().FetchRosterItem(contactJID.Node(), userJID.String())
+		cntRi, err := storage.Instance().FetchRosterItem(contactJID.Node(), contactJID.Domain(), userJID.String())
 		if err != nil {
 			return err
 		}
@@ -250,7 +252,7 @@ func (ph *PresenceHandler) processUnsubscribed(presence *xml.Presence) error {
 
 	var cntSub string
 	if host.IsLocalHost(contactJID.Domain()) {
-		deleted, err := deleteNotification(contactJID.Node(), userJID)
+		deleted, err := deleteNotification(contactJID.Node(), contactJID.Domain(), userJID)
 		if err != nil {
 			return err
 		}
@@ -258,7 +260,7 @@ func (ph *PresenceHandler) processUnsubscribed(presence *xml.Presence) error {
 		if deleted {
 			goto routePresence
 		}
-		cntRi, err := storage.Instance().FetchRosterItem(contactJID.Node(), userJID.String())
+		cntRi, err := storage.Instance().FetchRosterItem(contactJID.Node(), contactJID.Domain(), userJID.String())
 		if err != nil {
 			return err
 		}
@@ -282,7 +284,7 @@ routePresence:
 	p.AppendElements(presence.Elements().All())
 
 	if host.IsLocalHost(userJID.Domain()) {
-		usrRi, err := storage.Instance().FetchRosterItem(userJID.Node(), contactJID.String())
+		usrRi, err := storage.Instance().FetchRosterItem(userJID.Node(), userJID.Domain(), contactJID.String())
 		if err != nil {
 			return err
 		}
@@ -315,11 +317,11 @@ func (ph *PresenceHandler) processProbePresence(presence *xml.Presence) error {
 
 	log.Infof("processing 'probe' - user: %s (%s)", userJID, contactJID)
 
-	ri, err := storage.Instance().FetchRosterItem(userJID.Node(), contactJID.String())
+	ri, err := storage.Instance().FetchRosterItem(userJID.Node(), userJID.Domain(), contactJID.String())
 	if err != nil {
 		return err
 	}
-	usr, err := storage.Instance().FetchUser(userJID.Node())
+	usr, err := storage.Instance().FetchUser(userJID.Node(), userJID.Domain())
 	if err != nil {
 		return err
 	}
@@ -365,7 +367,7 @@ func (ph *PresenceHandler) processAvailablePresence(presence *xml.Presence) erro
 
 func (ph *PresenceHandler) deliverRosterPresences(userJID *jid.JID) error {
 	// first, deliver pending approval notifications...
-	rns, err := storage.Instance().FetchRosterNotifications(userJID.Node())
+	rns, err := storage.Instance().FetchRosterNotifications(userJID.Node(), userJID.Domain())
 	if err != nil {
 		return err
 	}
@@ -377,7 +379,7 @@ func (ph *PresenceHandler) deliverRosterPresences(userJID *jid.JID) error {
 	}
 
 	// deliver roster online presences
-	items, _, err := storage.Instance().FetchRosterItems(userJID.Node())
+	items, _, err := storage.Instance().FetchRosterItems(userJID.Node(), userJID.Domain())
 	if err != nil {
 		return err
 	}
@@ -397,7 +399,7 @@ func (ph *PresenceHandler) deliverRosterPresences(userJID *jid.JID) error {
 
 func (ph *PresenceHandler) broadcastPresence(presence *xml.Presence) error {
 	fromJID := presence.FromJID()
-	itms, _, err := storage.Instance().FetchRosterItems(fromJID.Node())
+	itms, _, err := storage.Instance().FetchRosterItems(fromJID.Node(), fromJID.Domain())
 	if err != nil {
 		return err
 	}
@@ -411,7 +413,7 @@ func (ph *PresenceHandler) broadcastPresence(presence *xml.Presence) error {
 	}
 
 	// update last received presence
-	if usr, err := storage.Instance().FetchUser(fromJID.Node()); err != nil {
+	if usr, err := storage.Instance().FetchUser(fromJID.Node(), fromJID.Domain()); err != nil {
 		return err
 	} else if usr != nil {
 		usr.LastPresence = presence
@@ -46,17 +46,20 @@ func TestPresenceHandler_Available(t *testing.T) {
 	// user entity
 	storage.Instance().InsertOrUpdateUser(&model.User{
 		Username:     "ortuman",
+		Domain:       "jackal.im",
 		LastPresence: xml.NewPresence(j1, j1.ToBareJID(), xml.UnavailableType),
 	})
 
 	// roster items
 	storage.Instance().InsertOrUpdateRosterItem(&rostermodel.Item{
 		Username:     "noelia",
+		Domain:       "jackal.im",
 		JID:          "ortuman@jackal.im",
 		Subscription: rostermodel.SubscriptionBoth,
 	})
 	storage.Instance().InsertOrUpdateRosterItem(&rostermodel.Item{
 		Username:     "ortuman",
+		Domain:       "jackal.im",
 		JID:          "noelia@jackal.im",
 		Subscription: rostermodel.SubscriptionBoth,
 	})
@@ -64,6 +67,7 @@ func TestPresenceHandler_Available(t *testing.T) {
 	// pending notification
 	storage.Instance().InsertOrUpdateRosterNotification(&rostermodel.Notification{
 		Contact:  "ortuman",
+		Domain:   "jackal.im",
 		JID:      j3.ToBareJID().String(),
 		Presence: xml.NewPresence(j3.ToBareJID(), j1.ToBareJID(), xml.SubscribeType),
 	})
@@ -86,7 +90,7 @@ func TestPresenceHandler_Available(t *testing.T) {
 	require.Equal(t, xml.AvailableType, elem.Type())
 
 	// check if last presence was updated
-	usr, err := storage.Instance().FetchUser("ortuman")
+	usr, err := storage.Instance().FetchUser("ortuman", "jackal.im")
 	require.Nil(t, err)
 	require.NotNil(t, usr)
 	require.NotNil(t, usr.LastPresence)
@@ -154,6 +158,7 @@ func TestPresenceHandler_Probe(t *testing.T) {
 
 	storage.Instance().InsertOrUpdateUser(&model.User{
 		Username:     "noelia",
+		Domain:       "jackal.im",
 		LastPresence: xml.NewPresence(j2.ToBareJID(), j2.ToBareJID(), xml.UnavailableType),
 	})
 
@@ -164,6 +169,7 @@ func TestPresenceHandler_Probe(t *testing.T) {
 
 	storage.Instance().InsertOrUpdateRosterItem(&rostermodel.Item{
 		Username:     "noelia",
+		Domain:       "jackal.im",
 		JID:          "ortuman@jackal.im",
 		Subscription: rostermodel.SubscriptionFrom,
 	})
@@ -175,6 +181,7 @@ func TestPresenceHandler_Probe(t *testing.T) {
 	p2 := xml.NewPresence(j2, j2.ToBareJID(), xml.AvailableType)
 	storage.Instance().InsertOrUpdateUser(&model.User{
 		Username:     "noelia",
+		Domain:       "jackal.im",
 		LastPresence: p2,
 	})
 	ph.ProcessPresence(xml.NewPresence(j1, j2, xml.ProbeType))
@@ -198,7 +205,7 @@ func TestPresenceHandler_Subscription(t *testing.T) {
 	ph := NewPresenceHandler(&Config{})
 	ph.ProcessPresence(xml.NewPresence(j1.ToBareJID(), j2.ToBareJID(), xml.SubscribeType))
 
-	rns, err := storage.Instance().FetchRosterNotifications("noelia")
+	rns, err := storage.Instance().FetchRosterNotifications("noelia", "jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, 1, len(rns))
 
@@ -207,11 +214,11 @@ func TestPresenceHandler_Subscription(t *testing.T) {
 
 	// contact request cancellation
 	ph.ProcessPresence(xml.NewPresence(j2.ToBareJID(), j1.ToBareJID(), xml.UnsubscribedType))
-	rns, err = storage.Instance().FetchRosterNotifications("noelia")
+	rns, err = storage.Instance().FetchRosterNotifications("noelia", "jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, 0, len(rns))
 
-	ri, err := storage.Instance().FetchRosterItem("ortuman", "noelia@jackal.im")
+	ri, err := storage.Instance().FetchRosterItem("ortuman", "jackal.im", "noelia@jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, rostermodel.SubscriptionNone, ri.Subscription)
 
@@ -219,7 +226,7 @@ func TestPresenceHandler_Subscription(t *testing.T) {
 	ph.ProcessPresence(xml.NewPresence(j1.ToBareJID(), j2.ToBareJID(), xml.SubscribeType))
 	ph.ProcessPresence(xml.NewPresence(j2.ToBareJID(), j1.ToBareJID(), xml.SubscribedType))
 
-	ri, err = storage.Instance().FetchRosterItem("ortuman", "noelia@jackal.im")
+	ri, err = storage.Instance().FetchRosterItem("ortuman", "jackal.im", "noelia@jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, rostermodel.SubscriptionTo, ri.Subscription)
 
@@ -227,24 +234,24 @@ func TestPresenceHandler_Subscription(t *testing.T) {
 	ph.ProcessPresence(xml.NewPresence(j2.ToBareJID(), j1.ToBareJID(), xml.SubscribeType))
 	ph.ProcessPresence(xml.NewPresence(j1.ToBareJID(), j2.ToBareJID(), xml.SubscribedType))
 
-	ri, err = storage.Instance().FetchRosterItem("noelia", "ortuman@jackal.im")
+	ri, err = storage.Instance().FetchRosterItem("noelia", "jackal.im", "ortuman@jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, rostermodel.SubscriptionBoth, ri.Subscription)
 
 	// user unsubscribes from contact's presence...
 	ph.ProcessPresence(xml.NewPresence(j1.ToBareJID(), j2.ToBareJID(), xml.UnsubscribeType))
 
-	ri, err = storage.Instance().FetchRosterItem("ortuman", "noelia@jackal.im")
+	ri, err = storage.Instance().FetchRosterItem("ortuman", "jackal.im", "noelia@jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, rostermodel.SubscriptionFrom, ri.Subscription)
 
 	// user cancels contact subscription
 	ph.ProcessPresence(xml.NewPresence(j1.ToBareJID(), j2.ToBareJID(), xml.UnsubscribedType))
-	ri, err = storage.Instance().FetchRosterItem("ortuman", "noelia@jackal.im")
+	ri, err = storage.Instance().FetchRosterItem("ortuman", "jackal.im", "noelia@jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, rostermodel.SubscriptionNone, ri.Subscription)
 
-	ri, err = storage.Instance().FetchRosterItem("noelia", "ortuman@jackal.im")
+	ri, err = storage.Instance().FetchRosterItem("noelia", "jackal.im", "ortuman@jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, rostermodel.SubscriptionNone, ri.Subscription)
 }
@@ -12,6 +12,7 @@ import (
 	"github.com/ortuman/jackal/host"
 	"github.com/ortuman/jackal/log"
 	"github.com/ortuman/jackal/model/rostermodel"
+	"github.com/ortuman/jackal/module"
 	"github.com/ortuman/jackal/module/xep0030"
 	"github.com/ortuman/jackal/router"
 	"github.com/ortuman/jackal/storage"
@@ -27,23 +28,33 @@ type Config struct {
 	Versioning bool `yaml:"versioning"`
 }
 
-// Roster represents a roster server stream module.
+func init() {
+	module.Register("roster", func(_ string, cfg *module.Config) (module.Module, error) {
+		var config Config
+		if err := cfg.Decode("roster", &config); err != nil {
+			return nil, err
+		}
+		return New(&config), nil
+	})
+}
+
+// Roster represents a roster server module.
 type Roster struct {
 	cfg     *Config
 	ph      *PresenceHandler
-	stm     stream.C2S
 	actorCh chan func()
+	doneCh  chan chan struct{}
 }
 
-// New returns a roster server stream module.
-func New(cfg *Config, stm stream.C2S) *Roster {
+// New returns a roster server module.
+func New(cfg *Config) *Roster {
 	r := &Roster{
 		cfg:     cfg,
 		ph:      NewPresenceHandler(cfg),
-		stm:     stm,
-		actorCh: make(chan func(), 32),
+		actorCh: make(chan func(), 64),
+		doneCh:  make(chan chan struct{}),
 	}
-	go r.actorLoop(stm.Context().Done())
+	go r.loop()
 	return r
 }
 
@@ -60,21 +71,21 @@ func (r *Roster) MatchesIQ(iq *xml.IQ) bool {
 
 // ProcessIQ processes a roster IQ taking according actions
 // over the associated stream.
-func (r *Roster) ProcessIQ(iq *xml.IQ) {
+func (r *Roster) ProcessIQ(iq *xml.IQ, stm stream.C2S) {
 	r.actorCh <- func() {
 		q := iq.Elements().ChildNamespace("query", rosterNamespace)
 		if iq.IsGet() {
-			r.sendRoster(iq, q)
+			r.sendRoster(iq, q, stm)
 		} else if iq.IsSet() {
-			r.updateRoster(iq, q)
+			r.updateRoster(iq, q, stm)
 		} else {
-			r.stm.SendElement(iq.BadRequestError())
+			stm.SendElement(iq.BadRequestError())
 		}
 	}
 }
 
 // ProcessPresence process an incoming roster presence.
-func (r *Roster) ProcessPresence(presence *xml.Presence) {
+func (r *Roster) ProcessPresence(presence *xml.Presence, _ stream.C2S) {
 	doneCh := make(chan struct{})
 	r.actorCh <- func() {
 		if err := r.ph.ProcessPresence(presence); err != nil {
@@ -85,30 +96,83 @@ func (r *Roster) ProcessPresence(presence *xml.Presence) {
 	<-doneCh
 }
 
-func (r *Roster) actorLoop(doneCh <-chan struct{}) {
+// PresenceHandler returns the presence handler used to process
+// presences originated by remote servers or external components.
+func (r *Roster) PresenceHandler() *PresenceHandler {
+	return r.ph
+}
+
+// InsertOrUpdateItem inserts or updates a user roster item on behalf
+// of its owner, pushing it to every resource that requested the roster.
+func (r *Roster) InsertOrUpdateItem(ri *rostermodel.Item) error {
+	errCh := make(chan error, 1)
+	r.actorCh <- func() {
+		v, err := storage.Instance().InsertOrUpdateRosterItem(ri)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		ri.Ver = v.Ver
+		errCh <- pushItem(ri, ri.Username, ri.Domain, r.cfg.Versioning)
+	}
+	return <-errCh
+}
+
+// DeleteItem deletes a user roster item on behalf of its owner,
+// pushing the removal to every resource that requested the roster.
+func (r *Roster) DeleteItem(username, domain, contactJID string) error {
+	errCh := make(chan error, 1)
+	r.actorCh <- func() {
+		v, err := storage.Instance().DeleteRosterItem(username, domain, contactJID)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		ri := &rostermodel.Item{
+			Username:     username,
+			Domain:       domain,
+			JID:          contactJID,
+			Subscription: rostermodel.SubscriptionRemove,
+			Ver:          v.Ver,
+		}
+		errCh <- pushItem(ri, username, domain, r.cfg.Versioning)
+	}
+	return <-errCh
+}
+
+// Shutdown shuts down roster module.
+func (r *Roster) Shutdown() {
+	ch := make(chan struct{})
+	r.doneCh <- ch
+	<-ch
+}
+
+// runs on it's own goroutine
+func (r *Roster) loop() {
 	for {
 		select {
 		case f := <-r.actorCh:
 			f()
-		case <-doneCh:
+		case ch := <-r.doneCh:
+			close(ch)
 			return
 		}
 	}
 }
 
-func (r *Roster) sendRoster(iq *xml.IQ, query xml.XElement) {
+func (r *Roster) sendRoster(iq *xml.IQ, query xml.XElement, stm stream.C2S) {
 	if query.Elements().Count() > 0 {
-		r.stm.SendElement(iq.BadRequestError())
+		stm.SendElement(iq.BadRequestError())
 		return
 	}
-	userJID := r.stm.JID()
+	userJID := stm.JID()
 
 	log.Infof("retrieving user roster... (%s)", userJID)
 
-	itms, ver, err := storage.Instance().FetchRosterItems(userJID.Node())
+	itms, ver, err := storage.Instance().FetchRosterItems(userJID.Node(), userJID.Domain())
 	if err != nil {
 		log.Error(err)
-		r.stm.SendElement(iq.InternalServerError())
+		stm.SendElement(iq.InternalServerError())
 		return
 	}
 	v := r.parseVer(query.Attributes().Get("ver"))
@@ -124,10 +188,10 @@ func (r *Roster) sendRoster(iq *xml.IQ, query xml.XElement) {
 			q.AppendElement(itm.Element())
 		}
 		res.AppendElement(q)
-		r.stm.SendElement(res)
+		stm.SendElement(res)
 	} else {
 		// push roster changes
-		r.stm.SendElement(res)
+		stm.SendElement(res)
 		for _, itm := range itms {
 			if itm.Ver > v {
 				iq := xml.NewIQType(uuid.New(), xml.SetType)
@@ -135,48 +199,48 @@ func (r *Roster) sendRoster(iq *xml.IQ, query xml.XElement) {
 				q.SetAttribute("ver", fmt.Sprintf("v%d", itm.Ver))
 				q.AppendElement(itm.Element())
 				iq.AppendElement(q)
-				r.stm.SendElement(iq)
+				stm.SendElement(iq)
 			}
 		}
 	}
-	r.stm.Context().SetBool(true, rosterRequestedCtxKey)
+	stm.Context().SetBool(true, rosterRequestedCtxKey)
 }
 
-func (r *Roster) updateRoster(iq *xml.IQ, query xml.XElement) {
+func (r *Roster) updateRoster(iq *xml.IQ, query xml.XElement, stm stream.C2S) {
 	itms := query.Elements().Children("item")
 	if len(itms) != 1 {
-		r.stm.SendElement(iq.BadRequestError())
+		stm.SendElement(iq.BadRequestError())
 		return
 	}
 	ri, err := rostermodel.NewItem(itms[0])
 	if err != nil {
-		r.stm.SendElement(iq.BadRequestError())
+		stm.SendElement(iq.BadRequestError())
 		return
 	}
 	switch ri.Subscription {
 	case rostermodel.SubscriptionRemove:
-		if err := r.removeItem(ri); err != nil {
+		if err := r.removeItem(ri, stm); err != nil {
 			log.Error(err)
-			r.stm.SendElement(iq.InternalServerError())
+			stm.SendElement(iq.InternalServerError())
 			return
 		}
 	default:
-		if err := r.updateItem(ri); err != nil {
+		if err := r.updateItem(ri, stm); err != nil {
 			log.Error(err)
-			r.stm.SendElement(iq.InternalServerError())
+			stm.SendElement(iq.InternalServerError())
 			return
 		}
 	}
-	r.stm.SendElement(iq.ResultIQ())
+	stm.SendElement(iq.ResultIQ())
 }
 
-func (r *Roster) updateItem(ri *rostermodel.Item) error {
-	userJID := r.stm.JID().ToBareJID()
+func (r *Roster) updateItem(ri *rostermodel.Item, stm stream.C2S) error {
+	userJID := stm.JID().ToBareJID()
 	contactJID := ri.ContactJID()
 
 	log.Infof("updating roster item - contact: %s (%s)", contactJID, userJID)
 
-	usrRi, err := storage.Instance().FetchRosterItem(userJID.Node(), contactJID.String())
+	usrRi, err := storage.Instance().FetchRosterItem(userJID.Node(), userJID.Domain(), contactJID.String())
 	if err != nil {
 		return err
 	}
@@ -190,6 +254,7 @@ func (r *Roster) updateItem(ri *rostermodel.Item) error {
 	} else {
 		usrRi = &rostermodel.Item{
 			Username:     userJID.Node(),
+			Domain:       userJID.Domain(),
 			JID:          ri.JID,
 			Name:         ri.Name,
 			Subscription: rostermodel.SubscriptionNone,
@@ -200,15 +265,15 @@ func (r *Roster) updateItem(ri *rostermodel.Item) error {
 	return insertItem(usrRi, userJID, r.cfg.Versioning)
 }
 
-func (r *Roster) removeItem(ri *rostermodel.Item) error {
+func (r *Roster) removeItem(ri *rostermodel.Item, stm stream.C2S) error {
 	var unsubscribe, unsubscribed *xml.Presence
 
-	userJID := r.stm.JID().ToBareJID()
+	userJID := stm.JID().ToBareJID()
 	contactJID := ri.ContactJID()
 
 	log.Infof("removing roster item: %v (%s)", contactJID, userJID)
 
-	usrRi, err := storage.Instance().FetchRosterItem(userJID.Node(), contactJID.String())
+	usrRi, err := storage.Instance().FetchRosterItem(userJID.Node(), userJID.Domain(), contactJID.String())
 	if err != nil {
 		return err
 	}
@@ -227,7 +292,7 @@ func (r *Roster) removeItem(ri *rostermodel.Item) error {
 		usrRi.Subscription = rostermodel.SubscriptionRemove
 		usrRi.Ask = false
 
-		_, err := deleteNotification(contactJID.Node(), userJID)
+		_, err := deleteNotification(contactJID.Node(), contactJID.Domain(), userJID)
 		if err != nil {
 			return err
 		}
@@ -236,7 +301,7 @@ func (r *Roster) removeItem(ri *rostermodel.Item) error {
 		}
 	}
 	if host.IsLocalHost(contactJID.Domain()) {
-		cntRi, err := storage.Instance().FetchRosterItem(contactJID.Node(), userJID.String())
+		cntRi, err := storage.Instance().FetchRosterItem(contactJID.Node(), contactJID.Domain(), userJID.String())
 		if err != nil {
 			return err
 		}
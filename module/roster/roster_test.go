@@ -26,7 +26,8 @@ func TestRoster_MatchesIQ(t *testing.T) {
 	stm.SetUsername("ortuman")
 	stm.SetDomain("jackal.im")
 
-	r := New(&Config{}, stm)
+	r := New(&Config{})
+	defer r.Shutdown()
 	defer stm.Disconnect(nil)
 
 	iq := xml.NewIQType(uuid.New(), xml.GetType)
@@ -45,7 +46,8 @@ func TestRoster_FetchRoster(t *testing.T) {
 	stm.SetUsername("ortuman")
 	stm.SetDomain("jackal.im")
 
-	r := New(&Config{}, stm)
+	r := New(&Config{})
+	defer r.Shutdown()
 	defer stm.Disconnect(nil)
 
 	iq := xml.NewIQType(uuid.New(), xml.ResultType)
@@ -53,17 +55,17 @@ func TestRoster_FetchRoster(t *testing.T) {
 	q.AppendElement(xml.NewElementName("q2"))
 	iq.AppendElement(q)
 
-	r.ProcessIQ(iq)
+	r.ProcessIQ(iq, stm)
 	elem := stm.FetchElement()
 	require.Equal(t, xml.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
 
 	iq.SetType(xml.GetType)
-	r.ProcessIQ(iq)
+	r.ProcessIQ(iq, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, xml.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
 	q.ClearElements()
 
-	r.ProcessIQ(iq)
+	r.ProcessIQ(iq, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, "iq", elem.Name())
 	require.Equal(t, xml.ResultType, elem.Type())
@@ -73,6 +75,7 @@ func TestRoster_FetchRoster(t *testing.T) {
 
 	ri1 := &rostermodel.Item{
 		Username:     "ortuman",
+		Domain:       "jackal.im",
 		JID:          "noelia@jackal.im",
 		Name:         "My Juliet",
 		Subscription: rostermodel.SubscriptionNone,
@@ -83,6 +86,7 @@ func TestRoster_FetchRoster(t *testing.T) {
 
 	ri2 := &rostermodel.Item{
 		Username:     "ortuman",
+		Domain:       "jackal.im",
 		JID:          "romeo@jackal.im",
 		Name:         "Rome",
 		Subscription: rostermodel.SubscriptionNone,
@@ -91,8 +95,8 @@ func TestRoster_FetchRoster(t *testing.T) {
 	}
 	storage.Instance().InsertOrUpdateRosterItem(ri2)
 
-	r = New(&Config{Versioning: true}, stm)
-	r.ProcessIQ(iq)
+	r = New(&Config{Versioning: true})
+	r.ProcessIQ(iq, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, "iq", elem.Name())
 	require.Equal(t, xml.ResultType, elem.Type())
@@ -107,7 +111,7 @@ func TestRoster_FetchRoster(t *testing.T) {
 	q.SetAttribute("ver", "v1")
 	iq.AppendElement(q)
 
-	r.ProcessIQ(iq)
+	r.ProcessIQ(iq, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, "iq", elem.Name())
 	require.Equal(t, xml.ResultType, elem.Type())
@@ -122,8 +126,8 @@ func TestRoster_FetchRoster(t *testing.T) {
 	require.Equal(t, "romeo@jackal.im", item.Attributes().Get("jid"))
 
 	storage.ActivateMockedError()
-	r = New(&Config{}, stm)
-	r.ProcessIQ(iq)
+	r = New(&Config{})
+	r.ProcessIQ(iq, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, xml.ErrInternalServerError.Error(), elem.Error().Elements().All()[0].Name())
 
@@ -149,7 +153,8 @@ func TestRoster_Update(t *testing.T) {
 	stm2.SetAuthenticated(true)
 	stm2.Context().SetBool(true, rosterRequestedCtxKey)
 
-	r := New(&Config{}, stm1)
+	r := New(&Config{})
+	defer r.Shutdown()
 
 	router.Bind(stm1)
 	router.Bind(stm2)
@@ -165,14 +170,14 @@ func TestRoster_Update(t *testing.T) {
 	q.AppendElement(item)
 	iq.AppendElement(q)
 
-	r.ProcessIQ(iq)
+	r.ProcessIQ(iq, stm1)
 	elem := stm1.FetchElement()
 	require.Equal(t, xml.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
 
 	q.ClearElements()
 	q.AppendElement(item)
 
-	r.ProcessIQ(iq)
+	r.ProcessIQ(iq, stm1)
 	elem = stm1.FetchElement()
 	require.Equal(t, "iq", elem.Name())
 	require.Equal(t, xml.ResultType, elem.Type())
@@ -187,13 +192,13 @@ func TestRoster_Update(t *testing.T) {
 	q.ClearElements()
 	q.AppendElement(item)
 
-	r.ProcessIQ(iq)
+	r.ProcessIQ(iq, stm1)
 	elem = stm1.FetchElement()
 	require.Equal(t, "iq", elem.Name())
 	require.Equal(t, xml.ResultType, elem.Type())
 	require.Equal(t, iqID, elem.ID())
 
-	ri, err := storage.Instance().FetchRosterItem("ortuman", "noelia@jackal.im")
+	ri, err := storage.Instance().FetchRosterItem("ortuman", "jackal.im", "noelia@jackal.im")
 	require.Nil(t, err)
 	require.NotNil(t, ri)
 	require.Equal(t, "ortuman", ri.Username)
@@ -214,12 +219,14 @@ func TestRoster_RemoveItem(t *testing.T) {
 	// insert contact's roster item
 	storage.Instance().InsertOrUpdateRosterItem(&rostermodel.Item{
 		Username:     "ortuman",
+		Domain:       "jackal.im",
 		JID:          "noelia@jackal.im",
 		Name:         "My Juliet",
 		Subscription: rostermodel.SubscriptionBoth,
 	})
 	storage.Instance().InsertOrUpdateRosterItem(&rostermodel.Item{
 		Username:     "noelia",
+		Domain:       "jackal.im",
 		JID:          "ortuman@jackal.im",
 		Name:         "My Romeo",
 		Subscription: rostermodel.SubscriptionBoth,
@@ -230,7 +237,8 @@ func TestRoster_RemoveItem(t *testing.T) {
 	stm.SetUsername("ortuman")
 	stm.SetDomain("jackal.im")
 
-	r := New(&Config{}, stm)
+	r := New(&Config{})
+	defer r.Shutdown()
 	defer stm.Disconnect(nil)
 
 	// remove item
@@ -243,11 +251,66 @@ func TestRoster_RemoveItem(t *testing.T) {
 	q.AppendElement(item)
 	iq.AppendElement(q)
 
-	r.ProcessIQ(iq)
+	r.ProcessIQ(iq, stm)
 	elem := stm.FetchElement()
 	require.Equal(t, iqID, elem.ID())
 
-	ri, err := storage.Instance().FetchRosterItem("ortuman", "noelia@jackal.im")
+	ri, err := storage.Instance().FetchRosterItem("ortuman", "jackal.im", "noelia@jackal.im")
 	require.Nil(t, err)
 	require.Nil(t, ri)
 }
+
+func TestRoster_InsertAndDeleteItem(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	router.Initialize(&router.Config{})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	defer func() {
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+
+	stm1 := stream.NewMockC2S(uuid.New(), j1)
+	stm1.SetAuthenticated(true)
+	stm1.Context().SetBool(true, rosterRequestedCtxKey)
+
+	r := New(&Config{Versioning: true})
+	defer r.Shutdown()
+
+	router.Bind(stm1)
+
+	ri := &rostermodel.Item{
+		Username:     "ortuman",
+		Domain:       "jackal.im",
+		JID:          "noelia@jackal.im",
+		Name:         "My Juliet",
+		Subscription: rostermodel.SubscriptionBoth,
+	}
+	require.Nil(t, r.InsertOrUpdateItem(ri))
+
+	// expecting roster push...
+	elem := stm1.FetchElement()
+	require.Equal(t, xml.SetType, elem.Type())
+	query := elem.Elements().ChildNamespace("query", rosterNamespace)
+	require.NotNil(t, query)
+	require.NotEmpty(t, query.Attributes().Get("ver"))
+	require.Equal(t, "noelia@jackal.im", query.Elements().Child("item").Attributes().Get("jid"))
+
+	stored, err := storage.Instance().FetchRosterItem("ortuman", "jackal.im", "noelia@jackal.im")
+	require.Nil(t, err)
+	require.NotNil(t, stored)
+	require.Equal(t, "My Juliet", stored.Name)
+
+	require.Nil(t, r.DeleteItem("ortuman", "jackal.im", "noelia@jackal.im"))
+
+	elem = stm1.FetchElement()
+	query = elem.Elements().ChildNamespace("query", rosterNamespace)
+	require.NotNil(t, query)
+	require.Equal(t, rostermodel.SubscriptionRemove, query.Elements().Child("item").Attributes().Get("subscription"))
+
+	stored, err = storage.Instance().FetchRosterItem("ortuman", "jackal.im", "noelia@jackal.im")
+	require.Nil(t, err)
+	require.Nil(t, stored)
+}
@@ -0,0 +1,183 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package token
+
+import (
+	"strings"
+	"time"
+
+	"github.com/ortuman/jackal/auth"
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/module"
+	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xml"
+)
+
+const tokenNamespace = "urn:xmpp:x-token:0"
+
+const defaultTTL = 30 * 24 * 60 * 60 // 30 days
+
+// Config represents login tokens module configuration.
+type Config struct {
+	// TTL represents issued tokens time to live expressed in seconds.
+	TTL int `yaml:"ttl"`
+}
+
+func init() {
+	module.Register("token", func(_ string, cfg *module.Config) (module.Module, error) {
+		var config Config
+		if err := cfg.Decode("token", &config); err != nil {
+			return nil, err
+		}
+		return New(&config), nil
+	})
+}
+
+// Token represents a login tokens server module.
+// Tokens are issued to password authenticated streams,
+// and can be used later on to log in by means of X-TOKEN
+// SASL mechanism until they expire or get revoked.
+type Token struct {
+	cfg     *Config
+	actorCh chan func()
+	doneCh  chan chan struct{}
+}
+
+// New returns a login tokens IQ handler module.
+func New(config *Config) *Token {
+	if config.TTL <= 0 {
+		config.TTL = defaultTTL
+	}
+	x := &Token{
+		cfg:     config,
+		actorCh: make(chan func(), 64),
+		doneCh:  make(chan chan struct{}),
+	}
+	go x.loop()
+	return x
+}
+
+// RegisterDisco registers disco entity features/items
+// associated to login tokens module.
+func (x *Token) RegisterDisco(discoInfo *xep0030.DiscoInfo) {
+	discoInfo.AccountEntity().AddFeature(tokenNamespace)
+}
+
+// MatchesIQ returns whether or not an IQ should be
+// processed by the login tokens module.
+func (x *Token) MatchesIQ(iq *xml.IQ) bool {
+	e := iq.Elements()
+	return e.ChildNamespace("query", tokenNamespace) != nil ||
+		e.ChildNamespace("generate", tokenNamespace) != nil ||
+		e.ChildNamespace("revoke", tokenNamespace) != nil
+}
+
+// ProcessIQ processes a login tokens IQ taking according actions
+// over the associated stream.
+func (x *Token) ProcessIQ(iq *xml.IQ, stm stream.C2S) {
+	x.actorCh <- func() {
+		if !iq.ToJID().IsBare() || iq.ToJID().Node() != stm.Username() {
+			stm.SendElement(iq.ForbiddenError())
+			return
+		}
+		e := iq.Elements()
+		if q := e.ChildNamespace("query", tokenNamespace); q != nil && iq.IsGet() {
+			x.sendTokens(iq, stm)
+		} else if generate := e.ChildNamespace("generate", tokenNamespace); generate != nil && iq.IsSet() {
+			x.generate(iq, generate, stm)
+		} else if revoke := e.ChildNamespace("revoke", tokenNamespace); revoke != nil && iq.IsSet() {
+			x.revoke(iq, revoke, stm)
+		} else {
+			stm.SendElement(iq.BadRequestError())
+		}
+	}
+}
+
+// Shutdown shuts down login tokens module.
+func (x *Token) Shutdown() {
+	ch := make(chan struct{})
+	x.doneCh <- ch
+	<-ch
+}
+
+// runs on it's own goroutine
+func (x *Token) loop() {
+	for {
+		select {
+		case f := <-x.actorCh:
+			f()
+		case ch := <-x.doneCh:
+			close(ch)
+			return
+		}
+	}
+}
+
+func (x *Token) sendTokens(iq *xml.IQ, stm stream.C2S) {
+	tokens, err := storage.Instance().FetchAuthTokens(stm.Username(), stm.Domain())
+	if err != nil {
+		log.Error(err)
+		stm.SendElement(iq.InternalServerError())
+		return
+	}
+	q := xml.NewElementNamespace("query", tokenNamespace)
+	for _, t := range tokens {
+		tokenElem := xml.NewElementName("token")
+		tokenElem.SetAttribute("device", t.Device)
+		tokenElem.SetAttribute("expiry", t.ExpiresAt.UTC().Format(time.RFC3339))
+		tokenElem.SetAttribute("created", t.CreatedAt.UTC().Format(time.RFC3339))
+		q.AppendElement(tokenElem)
+	}
+	result := iq.ResultIQ()
+	result.AppendElement(q)
+	stm.SendElement(result)
+}
+
+func (x *Token) generate(iq *xml.IQ, generate xml.XElement, stm stream.C2S) {
+	// tokens can't be used to obtain new ones
+	if !strings.HasPrefix(stm.Context().String(auth.MechanismCtxKey), "SCRAM-") {
+		stm.SendElement(iq.NotAllowedError())
+		return
+	}
+	device := generate.Attributes().Get("device")
+	if len(device) == 0 {
+		stm.SendElement(iq.BadRequestError())
+		return
+	}
+	token, expiresAt, err := auth.IssueToken(stm.Username(), stm.Domain(), device, time.Duration(x.cfg.TTL)*time.Second)
+	if err != nil {
+		log.Error(err)
+		stm.SendElement(iq.InternalServerError())
+		return
+	}
+	log.Infof("issued login token... (%s/%s) device: %s", stm.Username(), stm.Resource(), device)
+
+	tokenElem := xml.NewElementNamespace("token", tokenNamespace)
+	tokenElem.SetAttribute("device", device)
+	tokenElem.SetAttribute("expiry", expiresAt.Format(time.RFC3339))
+	tokenElem.SetText(token)
+
+	result := iq.ResultIQ()
+	result.AppendElement(tokenElem)
+	stm.SendElement(result)
+}
+
+func (x *Token) revoke(iq *xml.IQ, revoke xml.XElement, stm stream.C2S) {
+	device := revoke.Attributes().Get("device")
+	if err := storage.Instance().DeleteAuthTokens(stm.Username(), stm.Domain(), device); err != nil {
+		log.Error(err)
+		stm.SendElement(iq.InternalServerError())
+		return
+	}
+	if len(device) > 0 {
+		log.Infof("revoked login token... (%s/%s) device: %s", stm.Username(), stm.Resource(), device)
+	} else {
+		log.Infof("revoked all login tokens... (%s/%s)", stm.Username(), stm.Resource())
+	}
+	stm.SendElement(iq.ResultIQ())
+}
@@ -0,0 +1,173 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package token
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/auth"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToken_Matching(t *testing.T) {
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+
+	x := New(&Config{})
+	defer x.Shutdown()
+
+	require.Equal(t, defaultTTL, x.cfg.TTL)
+
+	require.True(t, x.MatchesIQ(tUtilTokenIQ(j, xml.GetType, "query", "")))
+	require.True(t, x.MatchesIQ(tUtilTokenIQ(j, xml.SetType, "generate", "garden")))
+	require.True(t, x.MatchesIQ(tUtilTokenIQ(j, xml.SetType, "revoke", "garden")))
+
+	iq := xml.NewIQType(uuid.New(), xml.SetType)
+	iq.AppendElement(xml.NewElementNamespace("generate", "urn:xmpp:bogus"))
+	require.False(t, x.MatchesIQ(iq))
+}
+
+func TestToken_Generate(t *testing.T) {
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	defer storage.Shutdown()
+
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	stm := stream.NewMockC2S(uuid.New(), j)
+
+	x := New(&Config{TTL: 3600})
+	defer x.Shutdown()
+
+	// not authenticated by password
+	x.ProcessIQ(tUtilTokenIQ(j, xml.SetType, "generate", "garden"), stm)
+	elem := stm.FetchElement()
+	require.Equal(t, xml.ErrNotAllowed.Error(), elem.Error().Elements().All()[0].Name())
+
+	stm.Context().SetString("X-TOKEN", auth.MechanismCtxKey)
+	x.ProcessIQ(tUtilTokenIQ(j, xml.SetType, "generate", "garden"), stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ErrNotAllowed.Error(), elem.Error().Elements().All()[0].Name())
+
+	stm.Context().SetString("SCRAM-SHA-1", auth.MechanismCtxKey)
+
+	// missing device
+	x.ProcessIQ(tUtilTokenIQ(j, xml.SetType, "generate", ""), stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
+
+	// another user
+	j2, _ := jid.New("noelia", "jackal.im", "balcony", true)
+	x.ProcessIQ(tUtilTokenIQ(j2, xml.SetType, "generate", "garden"), stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+
+	// storage error
+	storage.ActivateMockedError()
+	x.ProcessIQ(tUtilTokenIQ(j, xml.SetType, "generate", "garden"), stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ErrInternalServerError.Error(), elem.Error().Elements().All()[0].Name())
+	storage.DeactivateMockedError()
+
+	x.ProcessIQ(tUtilTokenIQ(j, xml.SetType, "generate", "garden"), stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	tokenElem := elem.Elements().ChildNamespace("token", tokenNamespace)
+	require.NotNil(t, tokenElem)
+	require.Equal(t, "garden", tokenElem.Attributes().Get("device"))
+	require.True(t, len(tokenElem.Text()) > 0)
+
+	expiresAt, err := time.Parse(time.RFC3339, tokenElem.Attributes().Get("expiry"))
+	require.Nil(t, err)
+	require.True(t, expiresAt.After(time.Now().Add(time.Minute*59)))
+
+	// issued token authenticates user
+	user := &model.User{Username: "ortuman", Domain: "jackal.im"}
+	auth.Initialize(&auth.Config{ScramOnly: true})
+	defer auth.Shutdown()
+	auth.SetUserPassword(user, "1234")
+	storage.Instance().InsertOrUpdateUser(user)
+
+	authr := auth.NewToken(stm)
+	authElem := xml.NewElementNamespace("auth", "urn:ietf:params:xml:ns:xmpp-sasl")
+	authElem.SetText(base64.StdEncoding.EncodeToString([]byte("\x00ortuman\x00" + tokenElem.Text())))
+	require.Nil(t, authr.ProcessElement(authElem))
+	require.True(t, authr.Authenticated())
+}
+
+func TestToken_ListAndRevoke(t *testing.T) {
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	defer storage.Shutdown()
+
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	stm := stream.NewMockC2S(uuid.New(), j)
+
+	x := New(&Config{})
+	defer x.Shutdown()
+
+	auth.IssueToken("ortuman", "jackal.im", "garden", time.Hour)
+	auth.IssueToken("ortuman", "jackal.im", "kitchen", time.Hour)
+	auth.IssueToken("ortuman", "jackal.im", "bedroom", time.Hour)
+
+	// bad request
+	x.ProcessIQ(tUtilTokenIQ(j, xml.SetType, "query", ""), stm)
+	elem := stm.FetchElement()
+	require.Equal(t, xml.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
+
+	x.ProcessIQ(tUtilTokenIQ(j, xml.GetType, "query", ""), stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	tokens := elem.Elements().ChildNamespace("query", tokenNamespace).Elements().Children("token")
+	require.Equal(t, 3, len(tokens))
+	require.Equal(t, "garden", tokens[0].Attributes().Get("device"))
+	require.Equal(t, "", tokens[0].Text())
+
+	x.ProcessIQ(tUtilTokenIQ(j, xml.SetType, "revoke", "garden"), stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	stored, _ := storage.Instance().FetchAuthTokens("ortuman", "jackal.im")
+	require.Equal(t, 2, len(stored))
+
+	// revoke all tokens
+	x.ProcessIQ(tUtilTokenIQ(j, xml.SetType, "revoke", ""), stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	stored, _ = storage.Instance().FetchAuthTokens("ortuman", "jackal.im")
+	require.Equal(t, 0, len(stored))
+
+	// storage error
+	storage.ActivateMockedError()
+	defer storage.DeactivateMockedError()
+
+	x.ProcessIQ(tUtilTokenIQ(j, xml.GetType, "query", ""), stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ErrInternalServerError.Error(), elem.Error().Elements().All()[0].Name())
+
+	x.ProcessIQ(tUtilTokenIQ(j, xml.SetType, "revoke", ""), stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ErrInternalServerError.Error(), elem.Error().Elements().All()[0].Name())
+}
+
+func tUtilTokenIQ(from *jid.JID, iqType, name, device string) *xml.IQ {
+	iq := xml.NewIQType(uuid.New(), iqType)
+	iq.SetFromJID(from)
+	iq.SetToJID(from.ToBareJID())
+	elem := xml.NewElementNamespace(name, tokenNamespace)
+	if len(device) > 0 {
+		elem.SetAttribute("device", device)
+	}
+	iq.AppendElement(elem)
+	return iq
+}
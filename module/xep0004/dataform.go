@@ -0,0 +1,91 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0004
+
+import (
+	"fmt"
+
+	"github.com/ortuman/jackal/xml"
+)
+
+// FormNamespace represents data forms namespace.
+const FormNamespace = "jabber:x:data"
+
+// FormTypeFieldVar represents the hidden field used to identify a form type.
+const FormTypeFieldVar = "FORM_TYPE"
+
+// data form type values
+const (
+	Form   = "form"
+	Submit = "submit"
+	Cancel = "cancel"
+	Result = "result"
+)
+
+// DataForm represents a data form entity.
+type DataForm struct {
+	Type         string
+	Title        string
+	Instructions string
+	Fields       Fields
+}
+
+// NewFormFromElement parses an XML element returning a derived data form instance.
+func NewFormFromElement(elem xml.XElement) (*DataForm, error) {
+	if n := elem.Name(); n != "x" {
+		return nil, fmt.Errorf("invalid form element name: %s", n)
+	}
+	if ns := elem.Namespace(); ns != FormNamespace {
+		return nil, fmt.Errorf("invalid form element namespace: %s", ns)
+	}
+	typ := elem.Type()
+	switch typ {
+	case Form, Submit, Cancel, Result:
+		break
+	default:
+		return nil, fmt.Errorf("unrecognized form type: %s", typ)
+	}
+	f := &DataForm{Type: typ}
+	if title := elem.Elements().Child("title"); title != nil {
+		f.Title = title.Text()
+	}
+	if instructions := elem.Elements().Child("instructions"); instructions != nil {
+		f.Instructions = instructions.Text()
+	}
+	for _, fieldElem := range elem.Elements().Children("field") {
+		field, err := NewFieldFromElement(fieldElem)
+		if err != nil {
+			return nil, err
+		}
+		f.Fields = append(f.Fields, *field)
+	}
+	return f, nil
+}
+
+// FormType returns form type field value.
+func (f *DataForm) FormType() string {
+	return f.Fields.ValueForField(FormTypeFieldVar)
+}
+
+// Element returns data form XML element representation.
+func (f *DataForm) Element() xml.XElement {
+	elem := xml.NewElementNamespace("x", FormNamespace)
+	elem.SetType(f.Type)
+	if len(f.Title) > 0 {
+		title := xml.NewElementName("title")
+		title.SetText(f.Title)
+		elem.AppendElement(title)
+	}
+	if len(f.Instructions) > 0 {
+		instructions := xml.NewElementName("instructions")
+		instructions.SetText(f.Instructions)
+		elem.AppendElement(instructions)
+	}
+	for _, field := range f.Fields {
+		elem.AppendElement(field.Element())
+	}
+	return elem
+}
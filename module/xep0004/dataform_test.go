@@ -0,0 +1,89 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0004
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/xml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataForm_FromElement(t *testing.T) {
+	elem := xml.NewElementNamespace("y", FormNamespace)
+	_, err := NewFormFromElement(elem)
+	require.NotNil(t, err)
+
+	elem.SetName("x")
+	elem.SetNamespace("jabber:x:other")
+	_, err = NewFormFromElement(elem)
+	require.NotNil(t, err)
+
+	elem.SetNamespace(FormNamespace)
+	elem.SetType("foo")
+	_, err = NewFormFromElement(elem)
+	require.NotNil(t, err)
+
+	elem.SetType(Submit)
+	title := xml.NewElementName("title")
+	title.SetText("A title")
+	elem.AppendElement(title)
+
+	field := xml.NewElementName("field")
+	field.SetAttribute("type", "bad-type")
+	elem.AppendElement(field)
+	_, err = NewFormFromElement(elem)
+	require.NotNil(t, err)
+
+	elem.RemoveElements("field")
+	field = xml.NewElementName("field")
+	field.SetAttribute("var", FormTypeFieldVar)
+	field.SetAttribute("type", Hidden)
+	value := xml.NewElementName("value")
+	value.SetText("urn:xmpp:test")
+	field.AppendElement(value)
+	elem.AppendElement(field)
+
+	field = xml.NewElementName("field")
+	field.SetAttribute("var", "public")
+	field.SetAttribute("type", Boolean)
+	value = xml.NewElementName("value")
+	value.SetText("1")
+	field.AppendElement(value)
+	elem.AppendElement(field)
+
+	form, err := NewFormFromElement(elem)
+	require.Nil(t, err)
+	require.Equal(t, Submit, form.Type)
+	require.Equal(t, "A title", form.Title)
+	require.Equal(t, "urn:xmpp:test", form.FormType())
+	require.True(t, form.Fields.BoolForField("public"))
+	require.False(t, form.Fields.BoolForField("private"))
+	require.Nil(t, form.Fields.Field("private"))
+}
+
+func TestDataForm_Element(t *testing.T) {
+	form := &DataForm{
+		Type:         Form,
+		Title:        "A title",
+		Instructions: "Fill in the form",
+		Fields: Fields{
+			{Var: FormTypeFieldVar, Type: Hidden, Values: []string{"urn:xmpp:test"}},
+			{Var: "whois", Type: ListSingle, Label: "Who is", Required: true, Options: []Option{
+				{Label: "Moderators", Value: "moderators"},
+				{Label: "Anyone", Value: "anyone"},
+			}},
+		},
+	}
+	elem := form.Element()
+	require.Equal(t, "x", elem.Name())
+	require.Equal(t, FormNamespace, elem.Namespace())
+	require.Equal(t, 2, len(elem.Elements().Children("field")))
+
+	form2, err := NewFormFromElement(elem)
+	require.Nil(t, err)
+	require.Equal(t, form, form2)
+}
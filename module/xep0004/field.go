@@ -0,0 +1,149 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0004
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ortuman/jackal/xml"
+)
+
+// field type values
+const (
+	Boolean     = "boolean"
+	Fixed       = "fixed"
+	Hidden      = "hidden"
+	JidMulti    = "jid-multi"
+	JidSingle   = "jid-single"
+	ListMulti   = "list-multi"
+	ListSingle  = "list-single"
+	TextMulti   = "text-multi"
+	TextPrivate = "text-private"
+	TextSingle  = "text-single"
+)
+
+// Option represents an individual field option.
+type Option struct {
+	Label string
+	Value string
+}
+
+// Field represents a data form field.
+type Field struct {
+	Var         string
+	Type        string
+	Label       string
+	Description string
+	Required    bool
+	Values      []string
+	Options     []Option
+}
+
+// Fields represents a set of data form fields.
+type Fields []Field
+
+// NewFieldFromElement parses an XML element returning a derived field instance.
+func NewFieldFromElement(elem xml.XElement) (*Field, error) {
+	if elem.Name() != "field" {
+		return nil, fmt.Errorf("invalid field element name: %s", elem.Name())
+	}
+	f := &Field{
+		Var:   elem.Attributes().Get("var"),
+		Label: elem.Attributes().Get("label"),
+		Type:  elem.Attributes().Get("type"),
+	}
+	switch f.Type {
+	case "", Boolean, Fixed, Hidden, JidMulti, JidSingle, ListMulti, ListSingle, TextMulti, TextPrivate, TextSingle:
+		break
+	default:
+		return nil, fmt.Errorf("unrecognized field type: %s", f.Type)
+	}
+	if len(f.Var) == 0 && f.Type != Fixed {
+		return nil, errors.New("field 'var' attribute is required")
+	}
+	if desc := elem.Elements().Child("desc"); desc != nil {
+		f.Description = desc.Text()
+	}
+	f.Required = elem.Elements().Child("required") != nil
+
+	for _, value := range elem.Elements().Children("value") {
+		f.Values = append(f.Values, value.Text())
+	}
+	for _, option := range elem.Elements().Children("option") {
+		var value string
+		if v := option.Elements().Child("value"); v != nil {
+			value = v.Text()
+		}
+		f.Options = append(f.Options, Option{Label: option.Attributes().Get("label"), Value: value})
+	}
+	return f, nil
+}
+
+// Element returns field XML element representation.
+func (f *Field) Element() xml.XElement {
+	elem := xml.NewElementName("field")
+	if len(f.Var) > 0 {
+		elem.SetAttribute("var", f.Var)
+	}
+	if len(f.Type) > 0 {
+		elem.SetAttribute("type", f.Type)
+	}
+	if len(f.Label) > 0 {
+		elem.SetAttribute("label", f.Label)
+	}
+	if len(f.Description) > 0 {
+		desc := xml.NewElementName("desc")
+		desc.SetText(f.Description)
+		elem.AppendElement(desc)
+	}
+	if f.Required {
+		elem.AppendElement(xml.NewElementName("required"))
+	}
+	for _, value := range f.Values {
+		v := xml.NewElementName("value")
+		v.SetText(value)
+		elem.AppendElement(v)
+	}
+	for _, option := range f.Options {
+		o := xml.NewElementName("option")
+		if len(option.Label) > 0 {
+			o.SetAttribute("label", option.Label)
+		}
+		v := xml.NewElementName("value")
+		v.SetText(option.Value)
+		o.AppendElement(v)
+		elem.AppendElement(o)
+	}
+	return elem
+}
+
+// Field returns the field associated to a given var.
+func (fs Fields) Field(fieldVar string) *Field {
+	for i := 0; i < len(fs); i++ {
+		if fs[i].Var == fieldVar {
+			return &fs[i]
+		}
+	}
+	return nil
+}
+
+// ValueForField returns the first value associated to a given field var.
+func (fs Fields) ValueForField(fieldVar string) string {
+	if f := fs.Field(fieldVar); f != nil && len(f.Values) > 0 {
+		return f.Values[0]
+	}
+	return ""
+}
+
+// BoolForField returns the boolean value associated to a given field var.
+func (fs Fields) BoolForField(fieldVar string) bool {
+	switch fs.ValueForField(fieldVar) {
+	case "1", "true":
+		return true
+	}
+	return false
+}
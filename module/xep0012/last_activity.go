@@ -11,6 +11,7 @@ import (
 
 	"github.com/ortuman/jackal/log"
 	"github.com/ortuman/jackal/model/rostermodel"
+	"github.com/ortuman/jackal/module"
 	"github.com/ortuman/jackal/module/xep0030"
 	"github.com/ortuman/jackal/router"
 	"github.com/ortuman/jackal/storage"
@@ -21,22 +22,27 @@ import (
 
 const lastActivityNamespace = "jabber:iq:last"
 
-// LastActivity represents a last activity stream module.
+func init() {
+	module.Register("last_activity", func(_ string, _ *module.Config) (module.Module, error) {
+		return New(), nil
+	})
+}
+
+// LastActivity represents a last activity server module.
 type LastActivity struct {
-	stm       stream.C2S
 	startTime time.Time
 }
 
 // New returns a last activity IQ handler module.
-func New(stm stream.C2S) *LastActivity {
-	return &LastActivity{stm: stm, startTime: time.Now()}
+func New() *LastActivity {
+	return &LastActivity{startTime: time.Now()}
 }
 
 // RegisterDisco registers disco entity features/items
 // associated to last activity module.
 func (x *LastActivity) RegisterDisco(discoInfo *xep0030.DiscoInfo) {
-	discoInfo.Entity(x.stm.Domain(), "").AddFeature(lastActivityNamespace)
-	discoInfo.Entity(x.stm.JID().ToBareJID().String(), "").AddFeature(lastActivityNamespace)
+	discoInfo.ServerEntity().AddFeature(lastActivityNamespace)
+	discoInfo.AccountEntity().AddFeature(lastActivityNamespace)
 }
 
 // MatchesIQ returns whether or not an IQ should be
@@ -47,46 +53,46 @@ func (x *LastActivity) MatchesIQ(iq *xml.IQ) bool {
 
 // ProcessIQ processes a last activity IQ taking according actions
 // over the associated stream.
-func (x *LastActivity) ProcessIQ(iq *xml.IQ) {
+func (x *LastActivity) ProcessIQ(iq *xml.IQ, stm stream.C2S) {
 	toJID := iq.ToJID()
 	if toJID.IsServer() {
-		x.sendServerUptime(iq)
+		x.sendServerUptime(iq, stm)
 	} else if toJID.IsBare() {
-		ri, err := storage.Instance().FetchRosterItem(x.stm.Username(), toJID.ToBareJID().String())
+		ri, err := storage.Instance().FetchRosterItem(stm.Username(), stm.Domain(), toJID.ToBareJID().String())
 		if err != nil {
 			log.Error(err)
-			x.stm.SendElement(iq.InternalServerError())
+			stm.SendElement(iq.InternalServerError())
 			return
 		}
 		if ri != nil {
 			switch ri.Subscription {
 			case rostermodel.SubscriptionTo, rostermodel.SubscriptionBoth:
-				x.sendUserLastActivity(iq, toJID)
+				x.sendUserLastActivity(iq, toJID, stm)
 				return
 			}
 		}
-		x.stm.SendElement(iq.ForbiddenError())
+		stm.SendElement(iq.ForbiddenError())
 	}
 }
 
-func (x *LastActivity) sendServerUptime(iq *xml.IQ) {
+func (x *LastActivity) sendServerUptime(iq *xml.IQ, stm stream.C2S) {
 	secs := int(time.Duration(time.Now().UnixNano()-x.startTime.UnixNano()) / time.Second)
-	x.sendReply(iq, secs, "")
+	x.sendReply(iq, stm, secs, "")
 }
 
-func (x *LastActivity) sendUserLastActivity(iq *xml.IQ, to *jid.JID) {
-	if len(router.UserStreams(to.Node())) > 0 { // user online
-		x.sendReply(iq, 0, "")
+func (x *LastActivity) sendUserLastActivity(iq *xml.IQ, to *jid.JID, stm stream.C2S) {
+	if len(router.UserStreams(to.Node(), to.Domain())) > 0 { // user online
+		x.sendReply(iq, stm, 0, "")
 		return
 	}
-	usr, err := storage.Instance().FetchUser(to.Node())
+	usr, err := storage.Instance().FetchUser(to.Node(), to.Domain())
 	if err != nil {
 		log.Error(err)
-		x.stm.SendElement(iq.InternalServerError())
+		stm.SendElement(iq.InternalServerError())
 		return
 	}
 	if usr == nil {
-		x.stm.SendElement(iq.ItemNotFoundError())
+		stm.SendElement(iq.ItemNotFoundError())
 		return
 	}
 	var secs int
@@ -97,14 +103,14 @@ func (x *LastActivity) sendUserLastActivity(iq *xml.IQ, to *jid.JID) {
 			status = st.Text()
 		}
 	}
-	x.sendReply(iq, secs, status)
+	x.sendReply(iq, stm, secs, status)
 }
 
-func (x *LastActivity) sendReply(iq *xml.IQ, secs int, status string) {
+func (x *LastActivity) sendReply(iq *xml.IQ, stm stream.C2S, secs int, status string) {
 	q := xml.NewElementNamespace("query", lastActivityNamespace)
 	q.SetText(status)
 	q.SetAttribute("seconds", strconv.Itoa(secs))
 	res := iq.ResultIQ()
 	res.AppendElement(q)
-	x.stm.SendElement(res)
+	stm.SendElement(res)
 }
@@ -23,7 +23,7 @@ import (
 func TestXEP0012_Matching(t *testing.T) {
 	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
 
-	x := New(nil)
+	x := New()
 
 	// test MatchesIQ
 	iq1 := xml.NewIQType(uuid.New(), xml.GetType)
@@ -54,13 +54,13 @@ func TestXEP0012_GetServerLastActivity(t *testing.T) {
 	stm := stream.NewMockC2S("abcd", j2)
 	defer stm.Disconnect(nil)
 
-	x := New(stm)
+	x := New()
 
 	iq := xml.NewIQType(uuid.New(), xml.GetType)
 	iq.SetToJID(j1)
 	iq.AppendElement(xml.NewElementNamespace("query", lastActivityNamespace))
 
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem := stm.FetchElement()
 	q := elem.Elements().Child("query")
 	require.NotNil(t, q)
@@ -84,14 +84,14 @@ func TestXEP0012_GetOnlineUserLastActivity(t *testing.T) {
 	stm2 := stream.NewMockC2S("abcde", j2)
 	stm2.SetResource("a_res")
 
-	x := New(stm1)
+	x := New()
 
 	iq := xml.NewIQType(uuid.New(), xml.GetType)
 	iq.SetFromJID(j2)
 	iq.SetToJID(j2)
 	iq.AppendElement(xml.NewElementNamespace("query", lastActivityNamespace))
 
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm1)
 	elem := stm1.FetchElement()
 	require.Equal(t, xml.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
 
@@ -102,14 +102,16 @@ func TestXEP0012_GetOnlineUserLastActivity(t *testing.T) {
 
 	storage.Instance().InsertOrUpdateUser(&model.User{
 		Username:     "noelia",
+		Domain:       "jackal.im",
 		LastPresence: p,
 	})
 	storage.Instance().InsertOrUpdateRosterItem(&rostermodel.Item{
 		Username:     "ortuman",
+		Domain:       "jackal.im",
 		JID:          "noelia@jackal.im",
 		Subscription: "both",
 	})
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm1)
 	elem = stm1.FetchElement()
 	q := elem.Elements().ChildNamespace("query", lastActivityNamespace)
 	secs := q.Attributes().Get("seconds")
@@ -118,14 +120,14 @@ func TestXEP0012_GetOnlineUserLastActivity(t *testing.T) {
 	// set as online
 	router.Bind(stm2)
 
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm1)
 	elem = stm1.FetchElement()
 	q = elem.Elements().ChildNamespace("query", lastActivityNamespace)
 	secs = q.Attributes().Get("seconds")
 	require.Equal(t, "0", secs)
 
 	storage.ActivateMockedError()
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm1)
 	elem = stm1.FetchElement()
 	require.Equal(t, xml.ErrInternalServerError.Error(), elem.Error().Elements().All()[0].Name())
 	storage.DeactivateMockedError()
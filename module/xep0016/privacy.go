@@ -0,0 +1,300 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0016
+
+import (
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/model/privacymodel"
+	"github.com/ortuman/jackal/module"
+	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xml"
+	"github.com/pborman/uuid"
+)
+
+const privacyNamespace = "jabber:iq:privacy"
+
+func init() {
+	module.Register("privacy", func(_ string, _ *module.Config) (module.Module, error) {
+		return New(), nil
+	})
+}
+
+// Privacy represents a privacy lists server module.
+type Privacy struct {
+}
+
+// New returns a privacy lists IQ handler module.
+func New() *Privacy {
+	return &Privacy{}
+}
+
+// RegisterDisco registers disco entity features/items
+// associated to privacy lists module.
+func (x *Privacy) RegisterDisco(discoInfo *xep0030.DiscoInfo) {
+	discoInfo.ServerEntity().AddFeature(privacyNamespace)
+}
+
+// MatchesIQ returns whether or not an IQ should be
+// processed by the privacy lists module.
+func (x *Privacy) MatchesIQ(iq *xml.IQ) bool {
+	return iq.Elements().ChildNamespace("query", privacyNamespace) != nil
+}
+
+// ProcessIQ processes a privacy lists IQ taking according actions
+// over the associated stream.
+func (x *Privacy) ProcessIQ(iq *xml.IQ, stm stream.C2S) {
+	toJID := iq.ToJID()
+	if !toJID.IsServer() && toJID.Node() != stm.Username() {
+		stm.SendElement(iq.ForbiddenError())
+		return
+	}
+	q := iq.Elements().ChildNamespace("query", privacyNamespace)
+	if iq.IsGet() {
+		x.getPrivacyLists(iq, q, stm)
+	} else if iq.IsSet() {
+		x.setPrivacyLists(iq, q, stm)
+	} else {
+		stm.SendElement(iq.BadRequestError())
+	}
+}
+
+func (x *Privacy) getPrivacyLists(iq *xml.IQ, q xml.XElement, stm stream.C2S) {
+	lists, err := storage.Instance().FetchPrivacyLists(stm.Username(), stm.Domain())
+	if err != nil {
+		log.Error(err)
+		stm.SendElement(iq.InternalServerError())
+		return
+	}
+	query := xml.NewElementNamespace("query", privacyNamespace)
+
+	switch q.Elements().Count() {
+	case 0:
+		if active := router.ActivePrivacyList(stm); len(active) > 0 {
+			activeEl := xml.NewElementName("active")
+			activeEl.SetAttribute("name", active)
+			query.AppendElement(activeEl)
+		}
+		for _, l := range lists {
+			if l.Default {
+				defaultEl := xml.NewElementName("default")
+				defaultEl.SetAttribute("name", l.Name)
+				query.AppendElement(defaultEl)
+			}
+		}
+		for _, l := range lists {
+			listEl := xml.NewElementName("list")
+			listEl.SetAttribute("name", l.Name)
+			query.AppendElement(listEl)
+		}
+	case 1:
+		listEl := q.Elements().Child("list")
+		if listEl == nil {
+			stm.SendElement(iq.BadRequestError())
+			return
+		}
+		l := findList(lists, listEl.Attributes().Get("name"))
+		if l == nil {
+			stm.SendElement(iq.ItemNotFoundError())
+			return
+		}
+		query.AppendElement(l.Element())
+	default:
+		stm.SendElement(iq.BadRequestError())
+		return
+	}
+	res := iq.ResultIQ()
+	res.AppendElement(query)
+	stm.SendElement(res)
+}
+
+func (x *Privacy) setPrivacyLists(iq *xml.IQ, q xml.XElement, stm stream.C2S) {
+	if q.Elements().Count() != 1 {
+		stm.SendElement(iq.BadRequestError())
+		return
+	}
+	lists, err := storage.Instance().FetchPrivacyLists(stm.Username(), stm.Domain())
+	if err != nil {
+		log.Error(err)
+		stm.SendElement(iq.InternalServerError())
+		return
+	}
+	elem := q.Elements().All()[0]
+	switch elem.Name() {
+	case "active":
+		x.setActiveList(iq, elem.Attributes().Get("name"), lists, stm)
+	case "default":
+		x.setDefaultList(iq, elem.Attributes().Get("name"), lists, stm)
+	case "list":
+		if elem.Elements().Count() == 0 {
+			x.removeList(iq, elem.Attributes().Get("name"), lists, stm)
+		} else {
+			x.updateList(iq, elem, lists, stm)
+		}
+	default:
+		stm.SendElement(iq.BadRequestError())
+	}
+}
+
+func (x *Privacy) setActiveList(iq *xml.IQ, name string, lists []privacymodel.List, stm stream.C2S) {
+	if len(name) > 0 && findList(lists, name) == nil {
+		stm.SendElement(iq.ItemNotFoundError())
+		return
+	}
+	router.SetActivePrivacyList(stm, name)
+	stm.SendElement(iq.ResultIQ())
+}
+
+func (x *Privacy) setDefaultList(iq *xml.IQ, name string, lists []privacymodel.List, stm stream.C2S) {
+	if len(name) > 0 && findList(lists, name) == nil {
+		stm.SendElement(iq.ItemNotFoundError())
+		return
+	}
+	if x.isDefaultListInUse(lists, stm) {
+		stm.SendElement(iq.ConflictError())
+		return
+	}
+	if err := storage.Instance().SetDefaultPrivacyList(stm.Username(), stm.Domain(), name); err != nil {
+		log.Error(err)
+		stm.SendElement(iq.InternalServerError())
+		return
+	}
+	router.ReloadPrivacyLists(stm.Username(), stm.Domain())
+	stm.SendElement(iq.ResultIQ())
+}
+
+func (x *Privacy) removeList(iq *xml.IQ, name string, lists []privacymodel.List, stm stream.C2S) {
+	l := findList(lists, name)
+	if l == nil {
+		stm.SendElement(iq.ItemNotFoundError())
+		return
+	}
+	// list can't be removed while being applied to any other resource
+	for _, s := range router.UserStreams(stm.Username(), stm.Domain()) {
+		if s == stm {
+			continue
+		}
+		active := router.ActivePrivacyList(s)
+		if active == name || (len(active) == 0 && l.Default) {
+			stm.SendElement(iq.ConflictError())
+			return
+		}
+	}
+	if err := storage.Instance().DeletePrivacyList(stm.Username(), stm.Domain(), name); err != nil {
+		log.Error(err)
+		stm.SendElement(iq.InternalServerError())
+		return
+	}
+	if router.ActivePrivacyList(stm) == name {
+		router.SetActivePrivacyList(stm, "")
+	}
+	router.ReloadPrivacyLists(stm.Username(), stm.Domain())
+	stm.SendElement(iq.ResultIQ())
+	x.pushList(name, stm)
+}
+
+func (x *Privacy) updateList(iq *xml.IQ, elem xml.XElement, lists []privacymodel.List, stm stream.C2S) {
+	l, err := privacymodel.NewList(stm.Username(), stm.Domain(), elem)
+	if err != nil {
+		log.Error(err)
+		stm.SendElement(iq.BadRequestError())
+		return
+	}
+	exists, err := x.groupsExist(l, stm)
+	if err != nil {
+		log.Error(err)
+		stm.SendElement(iq.InternalServerError())
+		return
+	}
+	if !exists {
+		stm.SendElement(iq.ItemNotFoundError())
+		return
+	}
+	if prev := findList(lists, l.Name); prev != nil {
+		l.Default = prev.Default
+	}
+	if err := storage.Instance().InsertOrUpdatePrivacyList(l); err != nil {
+		log.Error(err)
+		stm.SendElement(iq.InternalServerError())
+		return
+	}
+	router.ReloadPrivacyLists(stm.Username(), stm.Domain())
+	stm.SendElement(iq.ResultIQ())
+	x.pushList(l.Name, stm)
+}
+
+// isDefaultListInUse returns whether or not current default list
+// is being applied to any other user's resource.
+func (x *Privacy) isDefaultListInUse(lists []privacymodel.List, stm stream.C2S) bool {
+	var hasDefault bool
+	for _, l := range lists {
+		hasDefault = hasDefault || l.Default
+	}
+	if !hasDefault {
+		return false
+	}
+	for _, s := range router.UserStreams(stm.Username(), stm.Domain()) {
+		if s != stm && len(router.ActivePrivacyList(s)) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// groupsExist returns whether or not every group referenced
+// by a list exists within user's roster.
+func (x *Privacy) groupsExist(l *privacymodel.List, stm stream.C2S) (bool, error) {
+	var groups []string
+	for _, it := range l.Items {
+		if it.Type == privacymodel.GroupType {
+			groups = append(groups, it.Value)
+		}
+	}
+	if len(groups) == 0 {
+		return true, nil
+	}
+	ris, _, err := storage.Instance().FetchRosterItems(stm.Username(), stm.Domain())
+	if err != nil {
+		return false, err
+	}
+	rosterGroups := make(map[string]struct{})
+	for _, ri := range ris {
+		for _, group := range ri.Groups {
+			rosterGroups[group] = struct{}{}
+		}
+	}
+	for _, group := range groups {
+		if _, ok := rosterGroups[group]; !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (x *Privacy) pushList(name string, stm stream.C2S) {
+	for _, s := range router.UserStreams(stm.Username(), stm.Domain()) {
+		list := xml.NewElementName("list")
+		list.SetAttribute("name", name)
+		query := xml.NewElementNamespace("query", privacyNamespace)
+		query.AppendElement(list)
+
+		iq := xml.NewIQType(uuid.New(), xml.SetType)
+		iq.SetToJID(s.JID())
+		iq.AppendElement(query)
+		s.SendElement(iq)
+	}
+}
+
+func findList(lists []privacymodel.List, name string) *privacymodel.List {
+	for i := 0; i < len(lists); i++ {
+		if lists[i].Name == name {
+			return &lists[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,297 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0016
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/model/privacymodel"
+	"github.com/ortuman/jackal/model/rostermodel"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXEP0016_Matching(t *testing.T) {
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+
+	x := New()
+
+	iq := xml.NewIQType(uuid.New(), xml.GetType)
+	iq.SetFromJID(j)
+	iq.SetToJID(j.ToBareJID())
+	require.False(t, x.MatchesIQ(iq))
+
+	iq.AppendElement(xml.NewElementNamespace("query", privacyNamespace))
+	require.True(t, x.MatchesIQ(iq))
+}
+
+func TestXEP0016_GetLists(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	router.Initialize(&router.Config{})
+	defer func() {
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	stm := stream.NewMockC2S(uuid.New(), j)
+	router.Bind(stm)
+
+	storage.Instance().InsertOrUpdatePrivacyList(testList("public", true))
+	storage.Instance().InsertOrUpdatePrivacyList(testList("private", false))
+	router.SetActivePrivacyList(stm, "private")
+
+	x := New()
+
+	iq := xml.NewIQType(uuid.New(), xml.GetType)
+	iq.SetFromJID(j)
+	iq.SetToJID(j.ToBareJID())
+	iq.AppendElement(xml.NewElementNamespace("query", privacyNamespace))
+
+	x.ProcessIQ(iq, stm)
+	elem := stm.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+	q := elem.Elements().ChildNamespace("query", privacyNamespace)
+	require.NotNil(t, q)
+	require.Equal(t, "private", q.Elements().Child("active").Attributes().Get("name"))
+	require.Equal(t, "public", q.Elements().Child("default").Attributes().Get("name"))
+	require.Equal(t, 2, len(q.Elements().Children("list")))
+
+	// get a single list
+	list := xml.NewElementName("list")
+	list.SetAttribute("name", "public")
+	q2 := xml.NewElementNamespace("query", privacyNamespace)
+	q2.AppendElement(list)
+
+	iq2 := xml.NewIQType(uuid.New(), xml.GetType)
+	iq2.SetFromJID(j)
+	iq2.SetToJID(j.ToBareJID())
+	iq2.AppendElement(q2)
+
+	x.ProcessIQ(iq2, stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+	l := elem.Elements().ChildNamespace("query", privacyNamespace).Elements().Child("list")
+	require.NotNil(t, l)
+	require.Equal(t, 1, len(l.Elements().Children("item")))
+
+	list.SetAttribute("name", "unknown")
+	x.ProcessIQ(iq2, stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ErrItemNotFound.Error(), elem.Error().Elements().All()[0].Name())
+
+	// forbidden
+	j2, _ := jid.New("hamlet", "jackal.im", "balcony", true)
+	iq.SetToJID(j2.ToBareJID())
+	x.ProcessIQ(iq, stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+
+	storage.ActivateMockedError()
+	iq.SetToJID(j.ToBareJID())
+	x.ProcessIQ(iq, stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ErrInternalServerError.Error(), elem.Error().Elements().All()[0].Name())
+	storage.DeactivateMockedError()
+}
+
+func TestXEP0016_SetActiveAndDefault(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	router.Initialize(&router.Config{})
+	defer func() {
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("ortuman", "jackal.im", "garden", true)
+	stm1 := stream.NewMockC2S(uuid.New(), j1)
+	stm2 := stream.NewMockC2S(uuid.New(), j2)
+	router.Bind(stm1)
+
+	storage.Instance().InsertOrUpdatePrivacyList(testList("public", false))
+
+	x := New()
+
+	// active list
+	x.ProcessIQ(testSetIQ(j1, "active", "unknown"), stm1)
+	elem := stm1.FetchElement()
+	require.Equal(t, xml.ErrItemNotFound.Error(), elem.Error().Elements().All()[0].Name())
+
+	x.ProcessIQ(testSetIQ(j1, "active", "public"), stm1)
+	elem = stm1.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+	require.Equal(t, "public", router.ActivePrivacyList(stm1))
+
+	x.ProcessIQ(testSetIQ(j1, "active", ""), stm1)
+	stm1.FetchElement()
+	require.Equal(t, "", router.ActivePrivacyList(stm1))
+
+	// default list
+	x.ProcessIQ(testSetIQ(j1, "default", "public"), stm1)
+	elem = stm1.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	lists, _ := storage.Instance().FetchPrivacyLists("ortuman", "jackal.im")
+	require.Equal(t, 1, len(lists))
+	require.True(t, lists[0].Default)
+
+	// default list is being used by another resource
+	router.Bind(stm2)
+	x.ProcessIQ(testSetIQ(j1, "default", ""), stm1)
+	elem = stm1.FetchElement()
+	require.Equal(t, xml.ErrConflict.Error(), elem.Error().Elements().All()[0].Name())
+
+	router.Unbind(stm2)
+	x.ProcessIQ(testSetIQ(j1, "default", ""), stm1)
+	elem = stm1.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	lists, _ = storage.Instance().FetchPrivacyLists("ortuman", "jackal.im")
+	require.False(t, lists[0].Default)
+
+	// more than one child element
+	iq := testSetIQ(j1, "active", "public")
+	iq.Elements().ChildNamespace("query", privacyNamespace).(*xml.Element).AppendElement(xml.NewElementName("default"))
+	x.ProcessIQ(iq, stm1)
+	elem = stm1.FetchElement()
+	require.Equal(t, xml.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
+}
+
+func TestXEP0016_EditAndRemoveList(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	router.Initialize(&router.Config{})
+	defer func() {
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("ortuman", "jackal.im", "garden", true)
+	stm1 := stream.NewMockC2S(uuid.New(), j1)
+	stm2 := stream.NewMockC2S(uuid.New(), j2)
+	router.Bind(stm1)
+	router.Bind(stm2)
+
+	x := New()
+
+	// create list
+	listEl := testList("private", false).Element().(*xml.Element)
+	q := xml.NewElementNamespace("query", privacyNamespace)
+	q.AppendElement(listEl)
+	iq := xml.NewIQType(uuid.New(), xml.SetType)
+	iq.SetFromJID(j1)
+	iq.SetToJID(j1.ToBareJID())
+	iq.AppendElement(q)
+
+	x.ProcessIQ(iq, stm1)
+	elem := stm1.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	// list push to every resource
+	elem = stm1.FetchElement()
+	require.Equal(t, xml.SetType, elem.Type())
+	require.Equal(t, "private", elem.Elements().ChildNamespace("query", privacyNamespace).Elements().Child("list").Attributes().Get("name"))
+	elem = stm2.FetchElement()
+	require.Equal(t, xml.SetType, elem.Type())
+
+	lists, _ := storage.Instance().FetchPrivacyLists("ortuman", "jackal.im")
+	require.Equal(t, 1, len(lists))
+
+	// unknown roster group
+	item := xml.NewElementName("item")
+	item.SetAttribute("type", privacymodel.GroupType)
+	item.SetAttribute("value", "Friends")
+	item.SetAttribute("action", privacymodel.Allow)
+	item.SetAttribute("order", "5")
+	listEl.AppendElement(item)
+
+	x.ProcessIQ(iq, stm1)
+	elem = stm1.FetchElement()
+	require.Equal(t, xml.ErrItemNotFound.Error(), elem.Error().Elements().All()[0].Name())
+
+	storage.Instance().InsertOrUpdateRosterItem(&rostermodel.Item{
+		Username:     "ortuman",
+		Domain:       "jackal.im",
+		JID:          "juliet@jackal.im",
+		Subscription: rostermodel.SubscriptionBoth,
+		Groups:       []string{"Friends"},
+	})
+	x.ProcessIQ(iq, stm1)
+	elem = stm1.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+	stm1.FetchElement()
+	stm2.FetchElement()
+
+	lists, _ = storage.Instance().FetchPrivacyLists("ortuman", "jackal.im")
+	require.Equal(t, 2, len(lists[0].Items))
+
+	// remove list being used by another resource
+	router.SetActivePrivacyList(stm2, "private")
+
+	rmList := xml.NewElementName("list")
+	rmList.SetAttribute("name", "private")
+	rmQuery := xml.NewElementNamespace("query", privacyNamespace)
+	rmQuery.AppendElement(rmList)
+	rmIQ := xml.NewIQType(uuid.New(), xml.SetType)
+	rmIQ.SetFromJID(j1)
+	rmIQ.SetToJID(j1.ToBareJID())
+	rmIQ.AppendElement(rmQuery)
+
+	x.ProcessIQ(rmIQ, stm1)
+	elem = stm1.FetchElement()
+	require.Equal(t, xml.ErrConflict.Error(), elem.Error().Elements().All()[0].Name())
+
+	router.SetActivePrivacyList(stm2, "")
+	x.ProcessIQ(rmIQ, stm1)
+	elem = stm1.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	lists, _ = storage.Instance().FetchPrivacyLists("ortuman", "jackal.im")
+	require.Equal(t, 0, len(lists))
+}
+
+func testList(name string, isDefault bool) *privacymodel.List {
+	return &privacymodel.List{
+		Username: "ortuman",
+		Domain:   "jackal.im",
+		Name:     name,
+		Default:  isDefault,
+		Items: []privacymodel.Item{{
+			Type:   privacymodel.JIDType,
+			Value:  "hamlet@jackal.im",
+			Action: privacymodel.Deny,
+			Order:  1,
+		}},
+	}
+}
+
+func testSetIQ(j *jid.JID, name, listName string) *xml.IQ {
+	elem := xml.NewElementName(name)
+	if len(listName) > 0 {
+		elem.SetAttribute("name", listName)
+	}
+	q := xml.NewElementNamespace("query", privacyNamespace)
+	q.AppendElement(elem)
+
+	iq := xml.NewIQType(uuid.New(), xml.SetType)
+	iq.SetFromJID(j)
+	iq.SetToJID(j.ToBareJID())
+	iq.AppendElement(q)
+	return iq
+}
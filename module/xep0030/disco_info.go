@@ -11,6 +11,7 @@ import (
 
 	"github.com/ortuman/jackal/stream"
 	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
 )
 
 const (
@@ -18,51 +19,56 @@ const (
 	discoItemsNamespace = "http://jabber.org/protocol/disco#items"
 )
 
-// DiscoInfo represents a disco info server stream module.
+// DiscoInfo represents a disco info server module.
 type DiscoInfo struct {
-	stm      stream.C2S
+	domain   string
 	mu       sync.RWMutex
 	entities map[string]*Entity
+	account  *Entity
 }
 
-// New returns a disco info IQ handler module.
-func New(stm stream.C2S) *DiscoInfo {
-	return &DiscoInfo{
-		stm:      stm,
+// New returns a disco info IQ handler module associated
+// to a local domain.
+func New(domain string) *DiscoInfo {
+	di := &DiscoInfo{
+		domain:   domain,
 		entities: make(map[string]*Entity),
+		account:  &Entity{},
 	}
-}
-
-// RegisterDisco registers disco entity features/items
-// associated to disco info module.
-func (di *DiscoInfo) RegisterDisco(discoInfo *DiscoInfo) {
-	discoInfo.Entity(di.stm.Domain(), "").AddFeature(discoInfoNamespace)
-	discoInfo.Entity(di.stm.JID().ToBareJID().String(), "").AddFeature(discoItemsNamespace)
-}
-
-// RegisterDefaultEntities register and sets identities for the default
-// domain and account disco entities.
-func (di *DiscoInfo) RegisterDefaultEntities() error {
-	bareJID := di.stm.JID().ToBareJID()
-	srv, err := di.RegisterEntity(di.stm.Domain(), "")
-	if err != nil {
-		return err
-	}
-	acc, err := di.RegisterEntity(bareJID.String(), "")
-	if err != nil {
-		return err
-	}
+	srv, _ := di.RegisterEntity(domain, "")
 	srv.AddIdentity(Identity{
 		Type:     "im",
 		Category: "server",
 		Name:     "jackal",
 	})
-	acc.AddIdentity(Identity{
+	di.account.AddIdentity(Identity{
 		Type:     "registered",
 		Category: "account",
 	})
-	srv.AddItem(Item{Jid: bareJID.String()})
-	return nil
+	return di
+}
+
+// RegisterDisco registers disco entity features/items
+// associated to disco info module.
+func (di *DiscoInfo) RegisterDisco(discoInfo *DiscoInfo) {
+	discoInfo.ServerEntity().AddFeature(discoInfoNamespace)
+	discoInfo.AccountEntity().AddFeature(discoItemsNamespace)
+}
+
+// Domain returns the local domain associated to the disco info module.
+func (di *DiscoInfo) Domain() string {
+	return di.domain
+}
+
+// ServerEntity returns the disco entity associated to the local domain.
+func (di *DiscoInfo) ServerEntity() *Entity {
+	return di.Entity(di.domain, "")
+}
+
+// AccountEntity returns the disco entity shared by every
+// local domain account.
+func (di *DiscoInfo) AccountEntity() *Entity {
+	return di.account
 }
 
 // RegisterEntity registers a new disco entity associated to a jid
@@ -100,22 +106,33 @@ func (di *DiscoInfo) MatchesIQ(iq *xml.IQ) bool {
 
 // ProcessIQ processes a disco info IQ taking according actions
 // over the associated stream.
-func (di *DiscoInfo) ProcessIQ(iq *xml.IQ) {
+func (di *DiscoInfo) ProcessIQ(iq *xml.IQ, stm stream.C2S) {
 	q := iq.Elements().Child("query")
-	ent := di.Entity(iq.ToJID().String(), q.Attributes().Get("node"))
+	ent := di.lookupEntity(iq.ToJID(), q.Attributes().Get("node"), stm)
 	if ent == nil {
-		di.stm.SendElement(iq.ItemNotFoundError())
+		stm.SendElement(iq.ItemNotFoundError())
 		return
 	}
 	switch q.Namespace() {
 	case discoInfoNamespace:
-		di.sendDiscoInfo(ent, iq)
+		di.sendDiscoInfo(ent, iq, stm)
 	case discoItemsNamespace:
-		di.sendDiscoItems(ent, iq)
+		di.sendDiscoItems(ent, iq, stm)
 	}
 }
 
-func (di *DiscoInfo) sendDiscoInfo(ent *Entity, iq *xml.IQ) {
+func (di *DiscoInfo) lookupEntity(toJID *jid.JID, node string, stm stream.C2S) *Entity {
+	if ent := di.Entity(toJID.String(), node); ent != nil {
+		return ent
+	}
+	// requesting entity account
+	if len(node) == 0 && toJID.IsBare() && stm.JID().Matches(toJID, jid.MatchesBare) {
+		return di.account
+	}
+	return nil
+}
+
+func (di *DiscoInfo) sendDiscoInfo(ent *Entity, iq *xml.IQ, stm stream.C2S) {
 	result := iq.ResultIQ()
 	query := xml.NewElementNamespace("query", discoInfoNamespace)
 
@@ -136,14 +153,17 @@ func (di *DiscoInfo) sendDiscoInfo(ent *Entity, iq *xml.IQ) {
 		query.AppendElement(featureEl)
 	}
 	result.AppendElement(query)
-	di.stm.SendElement(result)
+	stm.SendElement(result)
 }
 
-func (di *DiscoInfo) sendDiscoItems(ent *Entity, iq *xml.IQ) {
+func (di *DiscoInfo) sendDiscoItems(ent *Entity, iq *xml.IQ, stm stream.C2S) {
 	result := iq.ResultIQ()
 	query := xml.NewElementNamespace("query", discoItemsNamespace)
 
 	for _, item := range ent.Items() {
+		if item.Visible != nil && !item.Visible(stm.JID()) {
+			continue
+		}
 		itemEl := xml.NewElementName("item")
 		itemEl.SetAttribute("jid", item.Jid)
 		if len(item.Name) > 0 {
@@ -155,7 +175,7 @@ func (di *DiscoInfo) sendDiscoItems(ent *Entity, iq *xml.IQ) {
 		query.AppendElement(itemEl)
 	}
 	result.AppendElement(query)
-	di.stm.SendElement(result)
+	stm.SendElement(result)
 }
 
 func (di *DiscoInfo) entityKey(jid, node string) string {
@@ -18,7 +18,7 @@ import (
 func TestXEP0030_Matching(t *testing.T) {
 	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
 
-	x := New(nil)
+	x := New("jackal.im")
 
 	// test MatchesIQ
 	iq1 := xml.NewIQType(uuid.New(), xml.GetType)
@@ -43,8 +43,9 @@ func TestXEP0030_Matching(t *testing.T) {
 }
 
 func TestXEP0030_SetItems(t *testing.T) {
-	x := New(nil)
-	x.RegisterEntity("jackal.im", "")
+	x := New("jackal.im")
+	_, err := x.RegisterEntity("jackal.im", "")
+	require.NotNil(t, err) // already registered
 
 	its := []Item{
 		{Jid: "j1@jackal.im", Name: "a name", Node: "node1"},
@@ -58,8 +59,9 @@ func TestXEP0030_SetItems(t *testing.T) {
 }
 
 func TestXEP0030_SetIdentities(t *testing.T) {
-	x := New(nil)
-	x.RegisterEntity("jackal.im", "")
+	x := New("jackal.im")
+	_, err := x.RegisterEntity("jackal.im", "")
+	require.NotNil(t, err) // already registered
 
 	ids := []Identity{{
 		Category: "server",
@@ -69,12 +71,14 @@ func TestXEP0030_SetIdentities(t *testing.T) {
 	ent := x.Entity("jackal.im", "")
 	ent.AddIdentity(ids[0])
 
-	require.Equal(t, ids, ent.Identities())
+	require.Equal(t, 2, len(ent.Identities()))
+	require.Equal(t, ids[0], ent.Identities()[1])
 }
 
 func TestXEP0030_SetFeatures(t *testing.T) {
-	x := New(nil)
-	x.RegisterEntity("jackal.im", "")
+	x := New("jackal.im")
+	_, err := x.RegisterEntity("jackal.im", "")
+	require.NotNil(t, err) // already registered
 
 	fs := []Feature{
 		discoInfoNamespace,
@@ -91,15 +95,14 @@ func TestXEP0030_BadToJID(t *testing.T) {
 	j, _ := jid.New("", "example.im", "", true)
 	stm := stream.NewMockC2S("abcd", j)
 
-	x := New(stm)
-	x.RegisterEntity("jackal.im", "")
+	x := New("jackal.im")
 
 	iq1 := xml.NewIQType(uuid.New(), xml.GetType)
 	iq1.SetFromJID(j)
 	iq1.SetToJID(j)
 	iq1.AppendElement(xml.NewElementNamespace("query", discoItemsNamespace))
 
-	x.ProcessIQ(iq1)
+	x.ProcessIQ(iq1, stm)
 	elem := stm.FetchElement()
 	require.Equal(t, xml.ErrItemNotFound.Error(), elem.Error().Elements().All()[0].Name())
 }
@@ -110,15 +113,9 @@ func TestXEP0030_GetFeatures(t *testing.T) {
 	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
 	stm := stream.NewMockC2S("abcd", j)
 
-	x := New(stm)
-	x.RegisterEntity("jackal.im", "")
+	x := New("jackal.im")
 
-	ent := x.Entity("jackal.im", "")
-	ent.AddIdentity(Identity{
-		Category: "server",
-		Type:     "im",
-		Name:     "default",
-	})
+	ent := x.ServerEntity()
 	ent.AddFeature("c")
 	ent.AddFeature("a")
 
@@ -127,7 +124,7 @@ func TestXEP0030_GetFeatures(t *testing.T) {
 	iq1.SetToJID(srvJid)
 	iq1.AppendElement(xml.NewElementNamespace("query", discoInfoNamespace))
 
-	x.ProcessIQ(iq1)
+	x.ProcessIQ(iq1, stm)
 	elem := stm.FetchElement()
 	require.NotNil(t, elem)
 	q := elem.Elements().ChildNamespace("query", discoInfoNamespace)
@@ -142,12 +139,15 @@ func TestXEP0030_GetItems(t *testing.T) {
 	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
 	stm := stream.NewMockC2S("abcd", j)
 
-	x := New(stm)
+	x := New("jackal.im")
 	x.RegisterEntity("jackal.im", "http://jabber.org/protocol/commands")
 
 	ent := x.Entity("jackal.im", "http://jabber.org/protocol/commands")
 	ent.AddItem(Item{Jid: "j1@jackal.im", Name: "a name", Node: "node1"})
 	ent.AddItem(Item{Jid: "j2@jackal.im", Name: "a second name", Node: "node2"})
+	ent.AddItem(Item{Jid: "j3@jackal.im", Name: "a hidden name", Node: "node3", Visible: func(requester *jid.JID) bool {
+		return requester.Node() == "admin"
+	}})
 
 	iq1 := xml.NewIQType(uuid.New(), xml.GetType)
 	iq1.SetFromJID(j)
@@ -156,10 +156,38 @@ func TestXEP0030_GetItems(t *testing.T) {
 	q.SetAttribute("node", "http://jabber.org/protocol/commands")
 	iq1.AppendElement(q)
 
-	x.ProcessIQ(iq1)
+	x.ProcessIQ(iq1, stm)
 	elem := stm.FetchElement()
 	require.NotNil(t, elem)
 	q2 := elem.Elements().ChildNamespace("query", discoItemsNamespace)
 	require.Equal(t, 2, q2.Elements().Count())
 	require.Equal(t, "item", q2.Elements().All()[0].Name())
 }
+
+func TestXEP0030_GetAccountFeatures(t *testing.T) {
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	stm := stream.NewMockC2S("abcd", j)
+
+	x := New("jackal.im")
+	x.RegisterDisco(x)
+
+	iq1 := xml.NewIQType(uuid.New(), xml.GetType)
+	iq1.SetFromJID(j)
+	iq1.SetToJID(j.ToBareJID())
+	iq1.AppendElement(xml.NewElementNamespace("query", discoInfoNamespace))
+
+	x.ProcessIQ(iq1, stm)
+	elem := stm.FetchElement()
+	require.NotNil(t, elem)
+	q := elem.Elements().ChildNamespace("query", discoInfoNamespace)
+	require.Equal(t, 2, q.Elements().Count())
+	require.Equal(t, "account", q.Elements().All()[0].Attributes().Get("category"))
+	require.Equal(t, discoItemsNamespace, q.Elements().All()[1].Attributes().Get("var"))
+
+	// other user account
+	j2, _ := jid.New("noelia", "jackal.im", "", true)
+	iq1.SetToJID(j2)
+	x.ProcessIQ(iq1, stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ErrItemNotFound.Error(), elem.Error().Elements().All()[0].Name())
+}
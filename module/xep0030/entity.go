@@ -8,6 +8,8 @@ package xep0030
 import (
 	"sort"
 	"sync"
+
+	"github.com/ortuman/jackal/xml/jid"
 )
 
 // Feature represents a disco info feature entity.
@@ -25,6 +27,10 @@ type Item struct {
 	Jid  string
 	Name string
 	Node string
+
+	// Visible if set, reports whether or not item
+	// should be listed to a requesting entity.
+	Visible func(requester *jid.JID) bool
 }
 
 // Entity represents a disco info item entity.
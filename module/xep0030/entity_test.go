@@ -39,9 +39,9 @@ func TestEntity_Identities(t *testing.T) {
 func TestEntity_Items(t *testing.T) {
 	e := &Entity{}
 	itms := []Item{
-		{"j0", "n0", "n0"},
-		{"j1", "n1", "n1"},
-		{"j2", "n2", "n2"},
+		{Jid: "j0", Name: "n0", Node: "n0"},
+		{Jid: "j1", Name: "n1", Node: "n1"},
+		{Jid: "j2", Name: "n2", Node: "n2"},
 	}
 	e.AddItem(itms[0])
 	e.AddItem(itms[1])
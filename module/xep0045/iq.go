@@ -0,0 +1,452 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0045
+
+import (
+	"strconv"
+
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/model/mucmodel"
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+)
+
+func (s *service) processIQ(iq *xml.IQ) {
+	if !iq.IsGet() && !iq.IsSet() {
+		return
+	}
+	toJID := iq.ToJID()
+	q := iq.Elements().Child("query")
+	if q == nil {
+		s.sendError(iq, xml.ErrServiceUnavailable)
+		return
+	}
+	if toJID.IsServer() {
+		switch {
+		case iq.IsGet() && q.Namespace() == discoInfoNamespace:
+			s.sendServiceDiscoInfo(iq)
+		case iq.IsGet() && q.Namespace() == discoItemsNamespace:
+			s.sendServiceDiscoItems(iq)
+		default:
+			s.sendError(iq, xml.ErrServiceUnavailable)
+		}
+		return
+	}
+	if !toJID.IsBare() {
+		s.sendError(iq, xml.ErrServiceUnavailable)
+		return
+	}
+	r, err := s.fetchRoom(toJID)
+	if err != nil {
+		log.Error(err)
+		s.sendError(iq, xml.ErrInternalServerError)
+		return
+	}
+	if r == nil {
+		s.sendError(iq, xml.ErrItemNotFound)
+		return
+	}
+	switch q.Namespace() {
+	case discoInfoNamespace:
+		s.sendRoomDiscoInfo(r, iq)
+	case discoItemsNamespace:
+		s.route(s.resultIQ(iq, xml.NewElementNamespace("query", discoItemsNamespace)))
+	case mucOwnerNamespace:
+		s.processOwnerIQ(r, iq, q)
+	case mucAdminNamespace:
+		s.processAdminIQ(r, iq, q)
+	default:
+		s.sendError(iq, xml.ErrServiceUnavailable)
+	}
+}
+
+func (s *service) sendServiceDiscoInfo(iq *xml.IQ) {
+	query := xml.NewElementNamespace("query", discoInfoNamespace)
+	query.AppendElement(identityElement("conference", "text", "Chatrooms"))
+	query.AppendElement(featureElement(discoInfoNamespace))
+	query.AppendElement(featureElement(discoItemsNamespace))
+	query.AppendElement(featureElement(mucNamespace))
+	s.route(s.resultIQ(iq, query))
+}
+
+func (s *service) sendServiceDiscoItems(iq *xml.IQ) {
+	query := xml.NewElementNamespace("query", discoItemsNamespace)
+	for _, r := range s.publicRooms(iq.ToJID().Domain()) {
+		item := xml.NewElementName("item")
+		item.SetAttribute("jid", r.JID)
+		if len(r.Name) > 0 {
+			item.SetAttribute("name", r.Name)
+		}
+		query.AppendElement(item)
+	}
+	s.route(s.resultIQ(iq, query))
+}
+
+func (s *service) sendRoomDiscoInfo(r *room, iq *xml.IQ) {
+	query := xml.NewElementNamespace("query", discoInfoNamespace)
+	query.AppendElement(identityElement("conference", "text", r.Name))
+	query.AppendElement(featureElement(mucNamespace))
+
+	c := &r.Config
+	query.AppendElement(featureElement(mucFeature(c.Public, "muc_public", "muc_hidden")))
+	query.AppendElement(featureElement(mucFeature(c.Persistent, "muc_persistent", "muc_temporary")))
+	query.AppendElement(featureElement(mucFeature(c.MembersOnly, "muc_membersonly", "muc_open")))
+	query.AppendElement(featureElement(mucFeature(c.Moderated, "muc_moderated", "muc_unmoderated")))
+	query.AppendElement(featureElement(mucFeature(c.NonAnonymous, "muc_nonanonymous", "muc_semianonymous")))
+	query.AppendElement(featureElement(mucFeature(len(c.Password) > 0, "muc_passwordprotected", "muc_unsecured")))
+	s.route(s.resultIQ(iq, query))
+}
+
+func (s *service) processOwnerIQ(r *room, iq *xml.IQ, query xml.XElement) {
+	if r.affiliationOf(iq.FromJID()) != mucmodel.AffiliationOwner {
+		s.sendError(iq, xml.ErrForbidden)
+		return
+	}
+	if iq.IsGet() {
+		q := xml.NewElementNamespace("query", mucOwnerNamespace)
+		q.AppendElement(s.configForm(r).Element())
+		s.route(s.resultIQ(iq, q))
+		return
+	}
+	if destroy := query.Elements().Child("destroy"); destroy != nil {
+		s.destroyOccupiedRoom(r, destroy)
+		s.route(s.resultIQ(iq, nil))
+		return
+	}
+	x := query.Elements().ChildNamespace("x", xep0004.FormNamespace)
+	if x == nil {
+		s.sendError(iq, xml.ErrBadRequest)
+		return
+	}
+	form, err := xep0004.NewFormFromElement(x)
+	if err != nil {
+		s.sendError(iq, xml.ErrBadRequest)
+		return
+	}
+	switch form.Type {
+	case xep0004.Submit:
+		wasPersistent := r.Config.Persistent
+		if !s.applyConfigForm(r, form) {
+			s.sendError(iq, xml.ErrNotAcceptable)
+			return
+		}
+		r.locked = false
+		s.saveRoom(r)
+		if wasPersistent && !r.Config.Persistent {
+			if err := storage.Instance().DeleteRoom(r.JID); err != nil {
+				log.Error(err)
+			}
+		}
+	case xep0004.Cancel:
+		if r.locked {
+			s.destroyOccupiedRoom(r, nil)
+		}
+	default:
+		s.sendError(iq, xml.ErrBadRequest)
+		return
+	}
+	s.route(s.resultIQ(iq, nil))
+}
+
+func (s *service) processAdminIQ(r *room, iq *xml.IQ, query xml.XElement) {
+	items := query.Elements().Children("item")
+	if len(items) == 0 {
+		s.sendError(iq, xml.ErrBadRequest)
+		return
+	}
+	fromJID := iq.FromJID()
+	actor := r.occupantByJID(fromJID)
+	actorAffiliation := r.affiliationOf(fromJID)
+
+	if iq.IsGet() {
+		item := items[0]
+		q := xml.NewElementNamespace("query", mucAdminNamespace)
+		switch {
+		case len(item.Attributes().Get("affiliation")) > 0:
+			if actorAffiliation != mucmodel.AffiliationOwner && actorAffiliation != mucmodel.AffiliationAdmin {
+				s.sendError(iq, xml.ErrForbidden)
+				return
+			}
+			affiliation := item.Attributes().Get("affiliation")
+			for j, aff := range r.Affiliations {
+				if aff != affiliation {
+					continue
+				}
+				it := xml.NewElementName("item")
+				it.SetAttribute("affiliation", aff)
+				it.SetAttribute("jid", j)
+				q.AppendElement(it)
+			}
+		case len(item.Attributes().Get("role")) > 0:
+			if actor == nil || actor.role != roleModerator {
+				s.sendError(iq, xml.ErrForbidden)
+				return
+			}
+			role := item.Attributes().Get("role")
+			for _, o := range r.occupants {
+				if o.role != role {
+					continue
+				}
+				it := r.itemElement(o, actor)
+				it.SetAttribute("nick", o.nick)
+				q.AppendElement(it)
+			}
+		default:
+			s.sendError(iq, xml.ErrBadRequest)
+			return
+		}
+		s.route(s.resultIQ(iq, q))
+		return
+	}
+	for _, item := range items {
+		var stanzaErr *xml.StanzaError
+		switch {
+		case len(item.Attributes().Get("affiliation")) > 0:
+			stanzaErr = s.setAffiliation(r, actorAffiliation, item)
+		case len(item.Attributes().Get("role")) > 0:
+			stanzaErr = s.setRole(r, actor, item)
+		default:
+			stanzaErr = xml.ErrBadRequest
+		}
+		if stanzaErr != nil {
+			s.sendError(iq, stanzaErr)
+			return
+		}
+	}
+	s.route(s.resultIQ(iq, nil))
+}
+
+func (s *service) setRole(r *room, actor *occupant, item xml.XElement) *xml.StanzaError {
+	if actor == nil || actor.role != roleModerator {
+		return xml.ErrForbidden
+	}
+	occ := r.occupantByNick(item.Attributes().Get("nick"))
+	if occ == nil {
+		return xml.ErrItemNotFound
+	}
+	role := item.Attributes().Get("role")
+	switch role {
+	case roleModerator, roleParticipant, roleVisitor, roleNone:
+		break
+	default:
+		return xml.ErrBadRequest
+	}
+	switch r.affiliationOf(occ.jid) {
+	case mucmodel.AffiliationOwner, mucmodel.AffiliationAdmin:
+		if role != roleModerator {
+			return xml.ErrNotAllowed
+		}
+	}
+	if role == roleNone {
+		s.removeOccupant(r, occ, statusKicked)
+		return nil
+	}
+	occ.role = role
+	s.broadcastPresence(r, occ)
+	return nil
+}
+
+func (s *service) setAffiliation(r *room, actorAffiliation string, item xml.XElement) *xml.StanzaError {
+	j, err := jid.NewWithString(item.Attributes().Get("jid"), false)
+	if err != nil {
+		return xml.ErrJidMalformed
+	}
+	bareJID := j.ToBareJID().String()
+	affiliation := item.Attributes().Get("affiliation")
+
+	switch affiliation {
+	case mucmodel.AffiliationOwner, mucmodel.AffiliationAdmin:
+		if actorAffiliation != mucmodel.AffiliationOwner {
+			return xml.ErrForbidden
+		}
+	case mucmodel.AffiliationMember, mucmodel.AffiliationOutcast, mucmodel.AffiliationNone:
+		if actorAffiliation != mucmodel.AffiliationOwner && actorAffiliation != mucmodel.AffiliationAdmin {
+			return xml.ErrForbidden
+		}
+		// only owners are allowed to modify owner and admin affiliations
+		switch r.Affiliation(bareJID) {
+		case mucmodel.AffiliationOwner, mucmodel.AffiliationAdmin:
+			if actorAffiliation != mucmodel.AffiliationOwner {
+				return xml.ErrNotAllowed
+			}
+		}
+	default:
+		return xml.ErrBadRequest
+	}
+	if r.Affiliation(bareJID) == mucmodel.AffiliationOwner && affiliation != mucmodel.AffiliationOwner && r.ownersCount() == 1 {
+		return xml.ErrConflict // a room must always have at least one owner
+	}
+	r.SetAffiliation(bareJID, affiliation)
+	s.saveRoom(r)
+
+	for _, occ := range r.occupantsByBareJID(bareJID) {
+		switch {
+		case affiliation == mucmodel.AffiliationOutcast:
+			s.removeOccupant(r, occ, statusBanned)
+		case r.Config.MembersOnly && affiliation == mucmodel.AffiliationNone:
+			s.removeOccupant(r, occ, statusKicked)
+		default:
+			occ.role = r.defaultRole(affiliation)
+			s.broadcastPresence(r, occ)
+		}
+	}
+	return nil
+}
+
+func (s *service) destroyOccupiedRoom(r *room, destroy xml.XElement) {
+	for _, occ := range r.occupants {
+		p := xml.NewPresence(r.occupantJID(occ.nick), occ.jid, xml.UnavailableType)
+		x := xml.NewElementNamespace("x", mucUserNamespace)
+		item := xml.NewElementName("item")
+		item.SetAttribute("affiliation", mucmodel.AffiliationNone)
+		item.SetAttribute("role", roleNone)
+		x.AppendElement(item)
+		if destroy != nil {
+			x.AppendElement(destroy)
+		}
+		p.AppendElement(x)
+		s.route(p)
+	}
+	r.occupants = nil
+	s.destroyRoom(r)
+}
+
+func (s *service) configForm(r *room) *xep0004.DataForm {
+	c := &r.Config
+	whois := "moderators"
+	if c.NonAnonymous {
+		whois = "anyone"
+	}
+	return &xep0004.DataForm{
+		Type:  xep0004.Form,
+		Title: "Configuration for " + r.JID,
+		Fields: xep0004.Fields{
+			{Var: xep0004.FormTypeFieldVar, Type: xep0004.Hidden, Values: []string{roomConfigFormType}},
+			{Var: "muc#roomconfig_roomname", Type: xep0004.TextSingle, Label: "Natural-Language Room Name", Values: []string{r.Name}},
+			{Var: "muc#roomconfig_roomdesc", Type: xep0004.TextSingle, Label: "Short Description of Room", Values: []string{r.Description}},
+			{Var: "muc#roomconfig_persistentroom", Type: xep0004.Boolean, Label: "Make Room Persistent?", Values: []string{boolValue(c.Persistent)}},
+			{Var: "muc#roomconfig_publicroom", Type: xep0004.Boolean, Label: "Make Room Publicly Searchable?", Values: []string{boolValue(c.Public)}},
+			{Var: "muc#roomconfig_membersonly", Type: xep0004.Boolean, Label: "Make Room Members-Only?", Values: []string{boolValue(c.MembersOnly)}},
+			{Var: "muc#roomconfig_moderatedroom", Type: xep0004.Boolean, Label: "Make Room Moderated?", Values: []string{boolValue(c.Moderated)}},
+			{Var: "muc#roomconfig_changesubject", Type: xep0004.Boolean, Label: "Allow Occupants to Change Subject?", Values: []string{boolValue(c.ChangeSubject)}},
+			{Var: "muc#roomconfig_allowinvites", Type: xep0004.Boolean, Label: "Allow Occupants to Invite Others?", Values: []string{boolValue(c.AllowInvites)}},
+			{Var: "muc#roomconfig_passwordprotectedroom", Type: xep0004.Boolean, Label: "Password Required to Enter?", Values: []string{boolValue(len(c.Password) > 0)}},
+			{Var: "muc#roomconfig_roomsecret", Type: xep0004.TextPrivate, Label: "Password", Values: []string{c.Password}},
+			{Var: "muc#roomconfig_whois", Type: xep0004.ListSingle, Label: "Who May Discover Real JIDs?", Values: []string{whois}, Options: []xep0004.Option{
+				{Label: "Moderators Only", Value: "moderators"},
+				{Label: "Anyone", Value: "anyone"},
+			}},
+			{Var: "muc#roomconfig_maxusers", Type: xep0004.ListSingle, Label: "Maximum Number of Occupants", Values: []string{strconv.Itoa(c.MaxUsers)}},
+		},
+	}
+}
+
+// applyConfigForm applies a submitted room configuration form.
+// An empty form is accepted and it's used to create an instant room.
+func (s *service) applyConfigForm(r *room, form *xep0004.DataForm) bool {
+	if len(form.Fields) == 0 {
+		return true
+	}
+	if form.FormType() != roomConfigFormType {
+		return false
+	}
+	c := r.Config
+	name, desc := r.Name, r.Description
+	for _, field := range form.Fields {
+		var value string
+		if len(field.Values) > 0 {
+			value = field.Values[0]
+		}
+		switch field.Var {
+		case "muc#roomconfig_roomname":
+			name = value
+		case "muc#roomconfig_roomdesc":
+			desc = value
+		case "muc#roomconfig_persistentroom":
+			c.Persistent = form.Fields.BoolForField(field.Var)
+		case "muc#roomconfig_publicroom":
+			c.Public = form.Fields.BoolForField(field.Var)
+		case "muc#roomconfig_membersonly":
+			c.MembersOnly = form.Fields.BoolForField(field.Var)
+		case "muc#roomconfig_moderatedroom":
+			c.Moderated = form.Fields.BoolForField(field.Var)
+		case "muc#roomconfig_changesubject":
+			c.ChangeSubject = form.Fields.BoolForField(field.Var)
+		case "muc#roomconfig_allowinvites":
+			c.AllowInvites = form.Fields.BoolForField(field.Var)
+		case "muc#roomconfig_roomsecret":
+			c.Password = value
+		case "muc#roomconfig_whois":
+			switch value {
+			case "anyone":
+				c.NonAnonymous = true
+			case "moderators":
+				c.NonAnonymous = false
+			default:
+				return false
+			}
+		case "muc#roomconfig_maxusers":
+			if len(value) == 0 || value == "none" {
+				c.MaxUsers = 0
+				break
+			}
+			maxUsers, err := strconv.Atoi(value)
+			if err != nil || maxUsers < 0 {
+				return false
+			}
+			c.MaxUsers = maxUsers
+		}
+	}
+	if f := form.Fields.Field("muc#roomconfig_passwordprotectedroom"); f != nil && !form.Fields.BoolForField(f.Var) {
+		c.Password = ""
+	}
+	r.Name = name
+	r.Description = desc
+	r.Config = c
+	return true
+}
+
+func (s *service) resultIQ(iq *xml.IQ, child xml.XElement) *xml.IQ {
+	result := xml.NewIQType(iq.ID(), xml.ResultType)
+	result.SetFromJID(iq.ToJID())
+	result.SetToJID(iq.FromJID())
+	if child != nil {
+		result.AppendElement(child)
+	}
+	return result
+}
+
+func identityElement(category, typ, name string) xml.XElement {
+	identity := xml.NewElementName("identity")
+	identity.SetAttribute("category", category)
+	identity.SetAttribute("type", typ)
+	if len(name) > 0 {
+		identity.SetAttribute("name", name)
+	}
+	return identity
+}
+
+func featureElement(feature string) xml.XElement {
+	f := xml.NewElementName("feature")
+	f.SetAttribute("var", feature)
+	return f
+}
+
+func mucFeature(enabled bool, onFeature, offFeature string) string {
+	if enabled {
+		return onFeature
+	}
+	return offFeature
+}
+
+func boolValue(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
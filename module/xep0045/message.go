@@ -0,0 +1,148 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0045
+
+import (
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/model/mucmodel"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+)
+
+func (s *service) processMessage(message *xml.Message) {
+	toJID := message.ToJID()
+	if toJID.IsServer() {
+		s.sendError(message, xml.ErrServiceUnavailable)
+		return
+	}
+	r, err := s.fetchRoom(toJID)
+	if err != nil {
+		log.Error(err)
+		s.sendError(message, xml.ErrInternalServerError)
+		return
+	}
+	if r == nil || r.locked {
+		s.sendError(message, xml.ErrItemNotFound)
+		return
+	}
+	if x := message.Elements().ChildNamespace("x", mucUserNamespace); x != nil && toJID.IsBare() {
+		if invite := x.Elements().Child("invite"); invite != nil {
+			s.sendInvitation(r, message, invite)
+			return
+		}
+	}
+	occ := r.occupantByJID(message.FromJID())
+	if occ == nil {
+		s.sendError(message, xml.ErrNotAcceptable)
+		return
+	}
+	if toJID.IsFull() {
+		s.sendPrivateMessage(r, occ, message)
+		return
+	}
+	if !message.IsGroupChat() {
+		s.sendError(message, xml.ErrBadRequest)
+		return
+	}
+	if occ.role == roleVisitor {
+		s.sendError(message, xml.ErrForbidden)
+		return
+	}
+	subject := message.Elements().Child("subject")
+	if subject != nil && message.Elements().Child("body") == nil {
+		if !r.Config.ChangeSubject && occ.role != roleModerator {
+			s.sendError(message, xml.ErrForbidden)
+			return
+		}
+		r.Subject = subject.Text()
+		s.saveRoom(r)
+	}
+	s.broadcastMessage(r, occ, message)
+}
+
+func (s *service) broadcastMessage(r *room, occ *occupant, message *xml.Message) {
+	fromJID := r.occupantJID(occ.nick)
+	for _, rcp := range r.occupants {
+		m, err := xml.NewMessageFromElement(message, fromJID, rcp.jid)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		s.route(m)
+	}
+	if message.IsMessageWithBody() {
+		m, _ := xml.NewMessageFromElement(message, fromJID, r.RoomJID())
+		m.Delay(r.JID, "")
+		r.appendHistory(m, s.cfg.MaxHistory)
+	}
+}
+
+func (s *service) sendPrivateMessage(r *room, occ *occupant, message *xml.Message) {
+	if message.IsGroupChat() {
+		s.sendError(message, xml.ErrBadRequest)
+		return
+	}
+	rcp := r.occupantByNick(message.ToJID().Resource())
+	if rcp == nil {
+		s.sendError(message, xml.ErrItemNotFound)
+		return
+	}
+	m, err := xml.NewMessageFromElement(message, r.occupantJID(occ.nick), rcp.jid)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	m.AppendElement(xml.NewElementNamespace("x", mucUserNamespace))
+	s.route(m)
+}
+
+func (s *service) sendInvitation(r *room, message *xml.Message, invite xml.XElement) {
+	fromJID := message.FromJID()
+	occ := r.occupantByJID(fromJID)
+	if occ == nil {
+		s.sendError(message, xml.ErrNotAcceptable)
+		return
+	}
+	affiliation := r.affiliationOf(fromJID)
+	isAdmin := affiliation == mucmodel.AffiliationOwner || affiliation == mucmodel.AffiliationAdmin
+	if !r.Config.AllowInvites && occ.role != roleModerator {
+		s.sendError(message, xml.ErrForbidden)
+		return
+	}
+	inviteeJID, err := jid.NewWithString(invite.Attributes().Get("to"), false)
+	if err != nil {
+		s.sendError(message, xml.ErrJidMalformed)
+		return
+	}
+	if r.Config.MembersOnly {
+		if !isAdmin {
+			s.sendError(message, xml.ErrForbidden)
+			return
+		}
+		if r.affiliationOf(inviteeJID) == mucmodel.AffiliationNone {
+			r.SetAffiliation(inviteeJID.ToBareJID().String(), mucmodel.AffiliationMember)
+			s.saveRoom(r)
+		}
+	}
+	m := xml.NewMessageType(message.ID(), xml.NormalType)
+	m.SetFromJID(r.RoomJID())
+	m.SetToJID(inviteeJID)
+
+	x := xml.NewElementNamespace("x", mucUserNamespace)
+	inv := xml.NewElementName("invite")
+	inv.SetAttribute("from", fromJID.ToBareJID().String())
+	if reason := invite.Elements().Child("reason"); reason != nil {
+		inv.AppendElement(reason)
+	}
+	x.AppendElement(inv)
+	if len(r.Config.Password) > 0 {
+		pass := xml.NewElementName("password")
+		pass.SetText(r.Config.Password)
+		x.AppendElement(pass)
+	}
+	m.AppendElement(x)
+	s.route(m)
+}
@@ -0,0 +1,111 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0045
+
+import (
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/module"
+	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+)
+
+const (
+	mucNamespace        = "http://jabber.org/protocol/muc"
+	mucUserNamespace    = "http://jabber.org/protocol/muc#user"
+	mucAdminNamespace   = "http://jabber.org/protocol/muc#admin"
+	mucOwnerNamespace   = "http://jabber.org/protocol/muc#owner"
+	roomConfigFormType  = "http://jabber.org/protocol/muc#roomconfig"
+	discoInfoNamespace  = "http://jabber.org/protocol/disco#info"
+	discoItemsNamespace = "http://jabber.org/protocol/disco#items"
+)
+
+const (
+	defaultService    = "conference"
+	defaultMaxHistory = 20
+)
+
+// Config represents Multi-User Chat module (XEP-0045) configuration.
+type Config struct {
+	Service    string `yaml:"service"`
+	MaxHistory int    `yaml:"max_history"`
+}
+
+func init() {
+	module.Register("muc", func(domain string, cfg *module.Config) (module.Module, error) {
+		var config Config
+		if err := cfg.Decode("muc", &config); err != nil {
+			return nil, err
+		}
+		return New(domain, &config), nil
+	})
+}
+
+// MUC represents a multi-user chat server module.
+type MUC struct {
+	domain string
+	svc    *service
+}
+
+// New returns a multi-user chat server module associated to a local host,
+// owning its own multi-user chat service.
+func New(domain string, cfg *Config) *MUC {
+	return &MUC{domain: domain, svc: newService(cfg)}
+}
+
+// RegisterDisco registers disco entity features/items
+// associated to multi-user chat module.
+func (x *MUC) RegisterDisco(discoInfo *xep0030.DiscoInfo) {
+	discoInfo.ServerEntity().AddItem(xep0030.Item{Jid: x.ServiceDomain()})
+}
+
+// ServiceDomain returns the domain multi-user chat service is reachable at.
+func (x *MUC) ServiceDomain() string {
+	return x.svc.cfg.Service + "." + x.domain
+}
+
+// ProcessStanza processes a stanza addressed to the multi-user chat
+// service or to any of its rooms.
+func (x *MUC) ProcessStanza(stanza xml.Stanza) {
+	x.svc.processStanza(stanza)
+}
+
+// ProcessPresence makes the originating stream to leave all its joined
+// rooms whenever an unavailable presence is sent on its own behalf.
+func (x *MUC) ProcessPresence(presence *xml.Presence, stm stream.C2S) {
+	toJID := presence.ToJID()
+	if presence.IsUnavailable() && toJID.IsBare() && toJID.Node() == stm.Username() && toJID.Domain() == stm.Domain() {
+		x.leaveRooms(stm.JID())
+	}
+}
+
+// StreamStarted satisfies module.StreamHandler interface.
+func (x *MUC) StreamStarted(_ stream.C2S) {}
+
+// StreamClosed makes a closed stream to leave all its joined rooms.
+func (x *MUC) StreamClosed(stm stream.C2S) {
+	x.leaveRooms(stm.JID())
+}
+
+// Shutdown shuts down multi-user chat service.
+func (x *MUC) Shutdown() {
+	x.svc.shutdown()
+}
+
+func (x *MUC) leaveRooms(occupantJID *jid.JID) {
+	x.svc.leaveRooms(occupantJID)
+
+	// occupant may have joined rooms hosted by any other local host service
+	for _, h := range host.HostNames() {
+		if h == x.domain {
+			continue
+		}
+		if muc, ok := module.Lookup(h, "muc").(*MUC); ok {
+			muc.svc.leaveRooms(occupantJID)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0045
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/model/mucmodel"
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXEP0045_ServiceDomain(t *testing.T) {
+	x1 := New("jackal.im", &Config{})
+	defer x1.Shutdown()
+	x2 := New("example.org", &Config{Service: "muc"})
+	defer x2.Shutdown()
+
+	require.Equal(t, "conference.jackal.im", x1.ServiceDomain())
+	require.Equal(t, "muc.example.org", x2.ServiceDomain())
+}
+
+func TestXEP0045_HostServices(t *testing.T) {
+	muc, shutdown := tUtilMUCInitialize()
+	defer shutdown()
+
+	// shutting down a host service leaves the rest untouched
+	New("example.org", &Config{}).Shutdown()
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	stm1 := tUtilStreamBind(j1)
+
+	muc.ProcessStanza(tUtilJoinPresence(j1, "lobby@conference.jackal.im/ortuman"))
+	elem := stm1.FetchElement()
+	require.Equal(t, "presence", elem.Name())
+	require.NotEqual(t, xml.ErrorType, elem.Type())
+}
+
+func TestXEP0045_CreateRoom(t *testing.T) {
+	muc, shutdown := tUtilMUCInitialize()
+	defer shutdown()
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "garden", true)
+	stm1 := tUtilStreamBind(j1)
+	stm2 := tUtilStreamBind(j2)
+
+	// create room
+	muc.ProcessStanza(tUtilJoinPresence(j1, "lobby@conference.jackal.im/ortuman"))
+	elem := stm1.FetchElement()
+	require.Equal(t, "presence", elem.Name())
+	x := elem.Elements().ChildNamespace("x", mucUserNamespace)
+	require.NotNil(t, x)
+	require.Equal(t, 2, len(x.Elements().Children("status"))) // 110 + 201
+	require.Equal(t, mucmodel.AffiliationOwner, x.Elements().Child("item").Attributes().Get("affiliation"))
+	require.Equal(t, roleModerator, x.Elements().Child("item").Attributes().Get("role"))
+
+	elem = stm1.FetchElement() // subject
+	require.NotNil(t, elem.Elements().Child("subject"))
+
+	// room is locked
+	muc.ProcessStanza(tUtilJoinPresence(j2, "lobby@conference.jackal.im/noelia"))
+	elem = stm2.FetchElement()
+	require.Equal(t, xml.ErrorType, elem.Type())
+	require.NotNil(t, elem.Elements().Child("error").Elements().Child("item-not-found"))
+
+	// create instant room
+	iq := xml.NewIQType(uuid.New(), xml.SetType)
+	iq.SetFromJID(j1)
+	iq.SetToJID(tUtilJID("lobby@conference.jackal.im"))
+	q := xml.NewElementNamespace("query", mucOwnerNamespace)
+	q.AppendElement((&xep0004.DataForm{Type: xep0004.Submit}).Element())
+	iq.AppendElement(q)
+	muc.ProcessStanza(iq)
+	elem = stm1.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	// join room
+	muc.ProcessStanza(tUtilJoinPresence(j2, "lobby@conference.jackal.im/noelia"))
+	elem = stm2.FetchElement() // ortuman's presence
+	require.Equal(t, "lobby@conference.jackal.im/ortuman", elem.From())
+
+	elem = stm2.FetchElement() // self-presence
+	require.Equal(t, "lobby@conference.jackal.im/noelia", elem.From())
+	x = elem.Elements().ChildNamespace("x", mucUserNamespace)
+	require.Equal(t, "110", x.Elements().Child("status").Attributes().Get("code"))
+
+	elem = stm1.FetchElement() // noelia's presence
+	require.Equal(t, "lobby@conference.jackal.im/noelia", elem.From())
+	require.Equal(t, j2.String(), elem.Elements().ChildNamespace("x", mucUserNamespace).Elements().Child("item").Attributes().Get("jid"))
+
+	stm2.FetchElement() // subject
+
+	// nick conflict
+	j3, _ := jid.New("noelia", "jackal.im", "yard", true)
+	stm3 := tUtilStreamBind(j3)
+	muc.ProcessStanza(tUtilJoinPresence(j3, "lobby@conference.jackal.im/ortuman"))
+	elem = stm3.FetchElement()
+	require.NotNil(t, elem.Elements().Child("error").Elements().Child("conflict"))
+}
+
+func TestXEP0045_GroupChat(t *testing.T) {
+	muc, shutdown := tUtilMUCInitialize()
+	defer shutdown()
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "garden", true)
+	stm1 := tUtilStreamBind(j1)
+	stm2 := tUtilStreamBind(j2)
+
+	tUtilCreateInstantRoom(muc, stm1, "lobby@conference.jackal.im")
+
+	msg := xml.NewMessageType(uuid.New(), xml.GroupChatType)
+	msg.SetFromJID(j1)
+	msg.SetToJID(tUtilJID("lobby@conference.jackal.im"))
+	body := xml.NewElementName("body")
+	body.SetText("Hi!")
+	msg.AppendElement(body)
+	muc.ProcessStanza(msg)
+
+	elem := stm1.FetchElement()
+	require.Equal(t, "message", elem.Name())
+	require.Equal(t, "lobby@conference.jackal.im/ortuman", elem.From())
+	require.Equal(t, "Hi!", elem.Elements().Child("body").Text())
+
+	// non-occupant message
+	msg2 := xml.NewMessageType(uuid.New(), xml.GroupChatType)
+	msg2.SetFromJID(j2)
+	msg2.SetToJID(tUtilJID("lobby@conference.jackal.im"))
+	msg2.AppendElement(body)
+	muc.ProcessStanza(msg2)
+	elem = stm2.FetchElement()
+	require.NotNil(t, elem.Elements().Child("error").Elements().Child("not-acceptable"))
+
+	// receive discussion history on join
+	muc.ProcessStanza(tUtilJoinPresence(j2, "lobby@conference.jackal.im/noelia"))
+	stm2.FetchElement() // ortuman's presence
+	stm2.FetchElement() // self-presence
+	elem = stm2.FetchElement()
+	require.Equal(t, "message", elem.Name())
+	require.Equal(t, "Hi!", elem.Elements().Child("body").Text())
+	require.NotNil(t, elem.Elements().Child("delay"))
+}
+
+func TestXEP0045_KickOccupant(t *testing.T) {
+	muc, shutdown := tUtilMUCInitialize()
+	defer shutdown()
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "garden", true)
+	stm1 := tUtilStreamBind(j1)
+	stm2 := tUtilStreamBind(j2)
+
+	tUtilCreateInstantRoom(muc, stm1, "lobby@conference.jackal.im")
+
+	muc.ProcessStanza(tUtilJoinPresence(j2, "lobby@conference.jackal.im/noelia"))
+	stm2.FetchElement() // ortuman's presence
+	stm2.FetchElement() // self-presence
+	stm2.FetchElement() // subject
+	stm1.FetchElement() // noelia's presence
+
+	// participants are not allowed to kick
+	iq := tUtilAdminIQ(j2, "lobby@conference.jackal.im", "ortuman", roleNone)
+	muc.ProcessStanza(iq)
+	elem := stm2.FetchElement()
+	require.NotNil(t, elem.Elements().Child("error").Elements().Child("forbidden"))
+
+	iq = tUtilAdminIQ(j1, "lobby@conference.jackal.im", "noelia", roleNone)
+	muc.ProcessStanza(iq)
+
+	elem = stm1.FetchElement()
+	require.Equal(t, "presence", elem.Name())
+	require.Equal(t, xml.UnavailableType, elem.Type())
+
+	elem = stm2.FetchElement()
+	require.Equal(t, xml.UnavailableType, elem.Type())
+	statuses := elem.Elements().ChildNamespace("x", mucUserNamespace).Elements().Children("status")
+	require.Equal(t, 2, len(statuses))
+	require.Equal(t, "307", statuses[0].Attributes().Get("code"))
+
+	elem = stm1.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+}
+
+func TestXEP0045_PersistentRoom(t *testing.T) {
+	muc, shutdown := tUtilMUCInitialize()
+	defer shutdown()
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	stm1 := tUtilStreamBind(j1)
+
+	muc.ProcessStanza(tUtilJoinPresence(j1, "lobby@conference.jackal.im/ortuman"))
+	stm1.FetchElement() // self-presence
+	stm1.FetchElement() // subject
+
+	form := &xep0004.DataForm{
+		Type: xep0004.Submit,
+		Fields: xep0004.Fields{
+			{Var: xep0004.FormTypeFieldVar, Values: []string{roomConfigFormType}},
+			{Var: "muc#roomconfig_roomname", Values: []string{"The Lobby"}},
+			{Var: "muc#roomconfig_persistentroom", Values: []string{"1"}},
+			{Var: "muc#roomconfig_whois", Values: []string{"anyone"}},
+		},
+	}
+	iq := xml.NewIQType(uuid.New(), xml.SetType)
+	iq.SetFromJID(j1)
+	iq.SetToJID(tUtilJID("lobby@conference.jackal.im"))
+	q := xml.NewElementNamespace("query", mucOwnerNamespace)
+	q.AppendElement(form.Element())
+	iq.AppendElement(q)
+	muc.ProcessStanza(iq)
+	elem := stm1.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	r, err := storage.Instance().FetchRoom("lobby@conference.jackal.im")
+	require.Nil(t, err)
+	require.NotNil(t, r)
+	require.Equal(t, "The Lobby", r.Name)
+	require.True(t, r.Config.NonAnonymous)
+
+	// persistent rooms are listed even when empty
+	muc.ProcessStanza(xml.NewPresence(j1, tUtilJID("lobby@conference.jackal.im/ortuman"), xml.UnavailableType))
+	stm1.FetchElement() // self-unavailable presence
+
+	iq = xml.NewIQType(uuid.New(), xml.GetType)
+	iq.SetFromJID(j1)
+	iq.SetToJID(tUtilJID("conference.jackal.im"))
+	iq.AppendElement(xml.NewElementNamespace("query", discoItemsNamespace))
+	muc.ProcessStanza(iq)
+	elem = stm1.FetchElement()
+	items := elem.Elements().ChildNamespace("query", discoItemsNamespace).Elements().Children("item")
+	require.Equal(t, 1, len(items))
+	require.Equal(t, "lobby@conference.jackal.im", items[0].Attributes().Get("jid"))
+}
+
+func tUtilMUCInitialize() (*MUC, func()) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	router.Initialize(&router.Config{})
+	muc := New("jackal.im", &Config{})
+	return muc, func() {
+		muc.Shutdown()
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}
+}
+
+func tUtilStreamBind(j *jid.JID) *stream.MockC2S {
+	stm := stream.NewMockC2S(uuid.New(), j)
+	router.Bind(stm)
+	return stm
+}
+
+func tUtilCreateInstantRoom(muc *MUC, stm *stream.MockC2S, roomJID string) {
+	muc.ProcessStanza(tUtilJoinPresence(stm.JID(), roomJID+"/"+stm.Username()))
+	stm.FetchElement() // self-presence
+	stm.FetchElement() // subject
+
+	iq := xml.NewIQType(uuid.New(), xml.SetType)
+	iq.SetFromJID(stm.JID())
+	iq.SetToJID(tUtilJID(roomJID))
+	q := xml.NewElementNamespace("query", mucOwnerNamespace)
+	q.AppendElement((&xep0004.DataForm{Type: xep0004.Submit}).Element())
+	iq.AppendElement(q)
+	muc.ProcessStanza(iq)
+	stm.FetchElement() // result
+}
+
+func tUtilJoinPresence(from *jid.JID, to string) *xml.Presence {
+	p := xml.NewPresence(from, tUtilJID(to), xml.AvailableType)
+	p.AppendElement(xml.NewElementNamespace("x", mucNamespace))
+	return p
+}
+
+func tUtilAdminIQ(from *jid.JID, roomJID, nick, role string) *xml.IQ {
+	iq := xml.NewIQType(uuid.New(), xml.SetType)
+	iq.SetFromJID(from)
+	iq.SetToJID(tUtilJID(roomJID))
+	q := xml.NewElementNamespace("query", mucAdminNamespace)
+	item := xml.NewElementName("item")
+	item.SetAttribute("nick", nick)
+	item.SetAttribute("role", role)
+	q.AppendElement(item)
+	iq.AppendElement(q)
+	return iq
+}
+
+func tUtilJID(s string) *jid.JID {
+	j, _ := jid.NewWithString(s, true)
+	return j
+}
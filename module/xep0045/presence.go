@@ -0,0 +1,252 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0045
+
+import (
+	"strconv"
+
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/model/mucmodel"
+	"github.com/ortuman/jackal/xml"
+	"github.com/pborman/uuid"
+)
+
+func (s *service) processPresence(presence *xml.Presence) {
+	toJID := presence.ToJID()
+	if toJID.IsServer() {
+		s.sendError(presence, xml.ErrBadRequest)
+		return
+	}
+	r, err := s.fetchRoom(toJID)
+	if err != nil {
+		log.Error(err)
+		s.sendError(presence, xml.ErrInternalServerError)
+		return
+	}
+	var occ *occupant
+	if r != nil {
+		occ = r.occupantByJID(presence.FromJID())
+	}
+	switch {
+	case presence.IsUnavailable():
+		if occ != nil {
+			s.leaveRoom(r, occ, presence)
+		}
+	case presence.IsAvailable():
+		if len(toJID.Resource()) == 0 {
+			// nickname is required
+			s.sendError(presence, xml.ErrJidMalformed)
+			return
+		}
+		switch {
+		case occ == nil:
+			s.joinRoom(r, presence)
+		case occ.nick != toJID.Resource():
+			s.changeNick(r, occ, presence)
+		default:
+			occ.presence = presence
+			s.broadcastPresence(r, occ)
+		}
+	}
+}
+
+func (s *service) joinRoom(r *room, presence *xml.Presence) {
+	fromJID := presence.FromJID()
+	toJID := presence.ToJID()
+	nick := toJID.Resource()
+
+	created := false
+	if r == nil {
+		r = newRoom(toJID.ToBareJID())
+		r.locked = true
+		r.SetAffiliation(fromJID.ToBareJID().String(), mucmodel.AffiliationOwner)
+		s.rooms[r.JID] = r
+		created = true
+	}
+	affiliation := r.affiliationOf(fromJID)
+	isAdmin := affiliation == mucmodel.AffiliationOwner || affiliation == mucmodel.AffiliationAdmin
+
+	switch {
+	case r.locked && affiliation != mucmodel.AffiliationOwner:
+		s.sendError(presence, xml.ErrItemNotFound)
+		return
+	case affiliation == mucmodel.AffiliationOutcast:
+		s.sendError(presence, xml.ErrForbidden)
+		return
+	case r.Config.MembersOnly && affiliation == mucmodel.AffiliationNone:
+		s.sendError(presence, xml.ErrRegistrationRequired)
+		return
+	case len(r.Config.Password) > 0 && !isAdmin && s.joinPassword(presence) != r.Config.Password:
+		s.sendError(presence, xml.ErrNotAuthorized)
+		return
+	}
+	if o := r.occupantByNick(nick); o != nil {
+		s.sendError(presence, xml.ErrConflict)
+		return
+	}
+	if r.Config.MaxUsers > 0 && len(r.occupants) >= r.Config.MaxUsers && !isAdmin {
+		s.sendError(presence, xml.ErrServiceUnavailable)
+		return
+	}
+	occ := &occupant{
+		jid:      fromJID,
+		nick:     nick,
+		role:     r.defaultRole(affiliation),
+		presence: presence,
+	}
+	// send current occupants presences to the new occupant
+	for _, o := range r.occupants {
+		s.route(s.occupantPresence(r, o, occ))
+	}
+	r.occupants = append(r.occupants, occ)
+
+	// broadcast new occupant presence
+	var selfCodes []int
+	if r.Config.NonAnonymous {
+		selfCodes = append(selfCodes, statusNonAnonymous)
+	}
+	if created {
+		selfCodes = append(selfCodes, statusRoomCreated)
+	}
+	s.broadcastPresence(r, occ, selfCodes...)
+
+	// send discussion history and room subject
+	s.sendHistory(r, occ, presence)
+	s.sendSubject(r, occ)
+}
+
+func (s *service) leaveRoom(r *room, occ *occupant, presence *xml.Presence) {
+	occ.role = roleNone
+	occ.presence = presence
+	s.broadcastPresence(r, occ)
+	r.removeOccupant(occ)
+	s.discardIfEmpty(r)
+}
+
+func (s *service) changeNick(r *room, occ *occupant, presence *xml.Presence) {
+	newNick := presence.ToJID().Resource()
+	if o := r.occupantByNick(newNick); o != nil {
+		s.sendError(presence, xml.ErrConflict)
+		return
+	}
+	for _, rcp := range r.occupants {
+		p := xml.NewPresence(r.occupantJID(occ.nick), rcp.jid, xml.UnavailableType)
+		item := r.itemElement(occ, rcp)
+		item.SetAttribute("nick", newNick)
+		x := xml.NewElementNamespace("x", mucUserNamespace)
+		x.AppendElement(item)
+		x.AppendElement(statusElement(statusNickChanged))
+		if rcp == occ {
+			x.AppendElement(statusElement(statusSelfPresence))
+		}
+		p.AppendElement(x)
+		s.route(p)
+	}
+	occ.nick = newNick
+	occ.presence = presence
+	s.broadcastPresence(r, occ)
+}
+
+// removeOccupant kicks an occupant out of a room notifying
+// every room occupant with the corresponding status code.
+func (s *service) removeOccupant(r *room, occ *occupant, statusCode int) {
+	occ.role = roleNone
+	for _, rcp := range r.occupants {
+		p := xml.NewPresence(r.occupantJID(occ.nick), rcp.jid, xml.UnavailableType)
+		x := r.userElement(occ, rcp, statusCode)
+		if rcp == occ {
+			x.AppendElement(statusElement(statusSelfPresence))
+		}
+		p.AppendElement(x)
+		s.route(p)
+	}
+	r.removeOccupant(occ)
+	s.discardIfEmpty(r)
+}
+
+func (s *service) discardIfEmpty(r *room) {
+	if len(r.occupants) > 0 {
+		return
+	}
+	if r.Config.Persistent && !r.locked {
+		// keep persistent room in storage only
+		delete(s.rooms, r.JID)
+		return
+	}
+	s.destroyRoom(r)
+}
+
+func (s *service) broadcastPresence(r *room, occ *occupant, selfCodes ...int) {
+	for _, rcp := range r.occupants {
+		var p *xml.Presence
+		if rcp == occ {
+			p = s.occupantPresence(r, occ, rcp, append([]int{statusSelfPresence}, selfCodes...)...)
+		} else {
+			p = s.occupantPresence(r, occ, rcp)
+		}
+		s.route(p)
+	}
+}
+
+// occupantPresence returns occupant's presence as seen by a room recipient.
+func (s *service) occupantPresence(r *room, occ *occupant, rcp *occupant, statusCodes ...int) *xml.Presence {
+	presenceType := xml.AvailableType
+	if occ.role == roleNone {
+		presenceType = xml.UnavailableType
+	}
+	p := xml.NewPresence(r.occupantJID(occ.nick), rcp.jid, presenceType)
+	if occ.presence != nil {
+		for _, elem := range occ.presence.Elements().All() {
+			switch elem.Namespace() {
+			case mucNamespace, mucUserNamespace:
+				continue
+			}
+			p.AppendElement(elem)
+		}
+	}
+	p.AppendElement(r.userElement(occ, rcp, statusCodes...))
+	return p
+}
+
+func (s *service) sendHistory(r *room, occ *occupant, presence *xml.Presence) {
+	history := r.history
+	if x := presence.Elements().ChildNamespace("x", mucNamespace); x != nil {
+		if h := x.Elements().Child("history"); h != nil {
+			if maxStanzas, err := strconv.Atoi(h.Attributes().Get("maxstanzas")); err == nil && maxStanzas >= 0 {
+				if maxStanzas < len(history) {
+					history = history[len(history)-maxStanzas:]
+				}
+			}
+		}
+	}
+	for _, msg := range history {
+		m, err := xml.NewMessageFromElement(msg, msg.FromJID(), occ.jid)
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		s.route(m)
+	}
+}
+
+func (s *service) sendSubject(r *room, occ *occupant) {
+	m := xml.NewMessageType(uuid.New(), xml.GroupChatType)
+	m.SetFromJID(r.RoomJID())
+	m.SetToJID(occ.jid)
+	subject := xml.NewElementName("subject")
+	subject.SetText(r.Subject)
+	m.AppendElement(subject)
+	s.route(m)
+}
+
+func (s *service) joinPassword(presence *xml.Presence) string {
+	if x := presence.Elements().ChildNamespace("x", mucNamespace); x != nil {
+		if pass := x.Elements().Child("password"); pass != nil {
+			return pass.Text()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,164 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0045
+
+import (
+	"strconv"
+
+	"github.com/ortuman/jackal/model/mucmodel"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+)
+
+// occupant role values
+const (
+	roleModerator   = "moderator"
+	roleParticipant = "participant"
+	roleVisitor     = "visitor"
+	roleNone        = "none"
+)
+
+// presence status codes
+const (
+	statusNonAnonymous = 100
+	statusSelfPresence = 110
+	statusRoomCreated  = 201
+	statusBanned       = 301
+	statusNickChanged  = 303
+	statusKicked       = 307
+)
+
+type occupant struct {
+	jid      *jid.JID
+	nick     string
+	role     string
+	presence *xml.Presence
+}
+
+type room struct {
+	mucmodel.Room
+	locked    bool
+	occupants []*occupant
+	history   []*xml.Message
+}
+
+func newRoom(roomJID *jid.JID) *room {
+	return &room{
+		Room: mucmodel.Room{
+			JID:  roomJID.String(),
+			Name: roomJID.Node(),
+			Config: mucmodel.RoomConfig{
+				Public:       true,
+				AllowInvites: true,
+			},
+		},
+	}
+}
+
+func (r *room) occupantJID(nick string) *jid.JID {
+	rj := r.RoomJID()
+	j, _ := jid.New(rj.Node(), rj.Domain(), nick, true)
+	return j
+}
+
+func (r *room) occupantByNick(nick string) *occupant {
+	for _, o := range r.occupants {
+		if o.nick == nick {
+			return o
+		}
+	}
+	return nil
+}
+
+func (r *room) occupantByJID(j *jid.JID) *occupant {
+	for _, o := range r.occupants {
+		if o.jid.Matches(j, jid.MatchesBare|jid.MatchesResource) {
+			return o
+		}
+	}
+	return nil
+}
+
+func (r *room) occupantsByBareJID(bareJID string) []*occupant {
+	var ret []*occupant
+	for _, o := range r.occupants {
+		if o.jid.ToBareJID().String() == bareJID {
+			ret = append(ret, o)
+		}
+	}
+	return ret
+}
+
+func (r *room) removeOccupant(occ *occupant) {
+	for i, o := range r.occupants {
+		if o == occ {
+			r.occupants = append(r.occupants[:i], r.occupants[i+1:]...)
+			return
+		}
+	}
+}
+
+func (r *room) affiliationOf(j *jid.JID) string {
+	return r.Affiliation(j.ToBareJID().String())
+}
+
+func (r *room) defaultRole(affiliation string) string {
+	switch affiliation {
+	case mucmodel.AffiliationOwner, mucmodel.AffiliationAdmin:
+		return roleModerator
+	}
+	if r.Config.Moderated && affiliation != mucmodel.AffiliationMember {
+		return roleVisitor
+	}
+	return roleParticipant
+}
+
+func (r *room) ownersCount() int {
+	var cnt int
+	for _, aff := range r.Affiliations {
+		if aff == mucmodel.AffiliationOwner {
+			cnt++
+		}
+	}
+	return cnt
+}
+
+func (r *room) appendHistory(message *xml.Message, maxHistory int) {
+	if maxHistory <= 0 {
+		return
+	}
+	r.history = append(r.history, message)
+	if len(r.history) > maxHistory {
+		r.history = r.history[len(r.history)-maxHistory:]
+	}
+}
+
+// userElement returns a muc#user element describing an occupant
+// as seen by a given recipient.
+func (r *room) userElement(occ *occupant, rcp *occupant, statusCodes ...int) *xml.Element {
+	x := xml.NewElementNamespace("x", mucUserNamespace)
+	x.AppendElement(r.itemElement(occ, rcp))
+	for _, code := range statusCodes {
+		x.AppendElement(statusElement(code))
+	}
+	return x
+}
+
+func (r *room) itemElement(occ *occupant, rcp *occupant) *xml.Element {
+	item := xml.NewElementName("item")
+	item.SetAttribute("affiliation", r.affiliationOf(occ.jid))
+	item.SetAttribute("role", occ.role)
+	if r.Config.NonAnonymous || (rcp != nil && rcp.role == roleModerator) {
+		item.SetAttribute("jid", occ.jid.String())
+	}
+	return item
+}
+
+func statusElement(code int) *xml.Element {
+	status := xml.NewElementName("status")
+	status.SetAttribute("code", strconv.Itoa(code))
+	return status
+}
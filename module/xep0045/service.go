@@ -0,0 +1,173 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0045
+
+import (
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+)
+
+type service struct {
+	cfg     *Config
+	rooms   map[string]*room
+	actorCh chan func()
+	doneCh  chan chan struct{}
+}
+
+func newService(config *Config) *service {
+	cfg := *config
+	if len(cfg.Service) == 0 {
+		cfg.Service = defaultService
+	}
+	if cfg.MaxHistory == 0 {
+		cfg.MaxHistory = defaultMaxHistory
+	}
+	s := &service{
+		cfg:     &cfg,
+		rooms:   make(map[string]*room),
+		actorCh: make(chan func(), 256),
+		doneCh:  make(chan chan struct{}),
+	}
+	go s.loop()
+	return s
+}
+
+func (s *service) processStanza(stanza xml.Stanza) {
+	s.actorCh <- func() {
+		switch stanza := stanza.(type) {
+		case *xml.Presence:
+			s.processPresence(stanza)
+		case *xml.Message:
+			s.processMessage(stanza)
+		case *xml.IQ:
+			s.processIQ(stanza)
+		}
+	}
+}
+
+func (s *service) leaveRooms(occupantJID *jid.JID) {
+	s.actorCh <- func() {
+		for _, r := range s.rooms {
+			if occ := r.occupantByJID(occupantJID); occ != nil {
+				s.leaveRoom(r, occ, xml.NewPresence(occupantJID, r.occupantJID(occ.nick), xml.UnavailableType))
+			}
+		}
+	}
+}
+
+func (s *service) shutdown() {
+	ch := make(chan struct{})
+	s.doneCh <- ch
+	<-ch
+}
+
+// runs on it's own goroutine
+func (s *service) loop() {
+	for {
+		select {
+		case f := <-s.actorCh:
+			f()
+		case ch := <-s.doneCh:
+			close(ch)
+			return
+		}
+	}
+}
+
+// fetchRoom returns an active room, loading it from storage
+// in case it has been previously persisted.
+func (s *service) fetchRoom(roomJID *jid.JID) (*room, error) {
+	k := roomJID.ToBareJID().String()
+	if r := s.rooms[k]; r != nil {
+		return r, nil
+	}
+	sr, err := storage.Instance().FetchRoom(k)
+	if err != nil {
+		return nil, err
+	}
+	if sr == nil {
+		return nil, nil
+	}
+	r := &room{Room: *sr}
+	s.rooms[k] = r
+	return r, nil
+}
+
+func (s *service) saveRoom(r *room) {
+	if !r.Config.Persistent {
+		return
+	}
+	if err := storage.Instance().InsertOrUpdateRoom(&r.Room); err != nil {
+		log.Error(err)
+	}
+}
+
+func (s *service) destroyRoom(r *room) {
+	delete(s.rooms, r.JID)
+	if err := storage.Instance().DeleteRoom(r.JID); err != nil {
+		log.Error(err)
+	}
+}
+
+func (s *service) publicRooms(serviceDomain string) []*room {
+	var ret []*room
+	seen := make(map[string]bool)
+	for k, r := range s.rooms {
+		if r.RoomJID().Domain() != serviceDomain {
+			continue
+		}
+		seen[k] = true
+		if r.Config.Public && !r.locked {
+			ret = append(ret, r)
+		}
+	}
+	storedRooms, err := storage.Instance().FetchRooms(serviceDomain)
+	if err != nil {
+		log.Error(err)
+		return ret
+	}
+	for _, sr := range storedRooms {
+		if !seen[sr.JID] && sr.Config.Public {
+			ret = append(ret, &room{Room: sr})
+		}
+	}
+	return ret
+}
+
+func (s *service) route(stanza xml.Stanza) {
+	switch err := router.Route(stanza); err {
+	case nil, router.ErrNotAuthenticated, router.ErrResourceNotFound, router.ErrBlockedJID:
+		break
+	default:
+		log.Error(err)
+	}
+}
+
+func (s *service) sendError(stanza xml.Stanza, stanzaErr *xml.StanzaError) {
+	if stanza.Type() == xml.ErrorType {
+		return // never reply to an error stanza
+	}
+	errElem := xml.NewErrorElementFromElement(stanza, stanzaErr, nil)
+
+	var errStanza xml.Stanza
+	var err error
+	switch stanza.(type) {
+	case *xml.IQ:
+		errStanza, err = xml.NewIQFromElement(errElem, stanza.ToJID(), stanza.FromJID())
+	case *xml.Presence:
+		errStanza, err = xml.NewPresenceFromElement(errElem, stanza.ToJID(), stanza.FromJID())
+	case *xml.Message:
+		errStanza, err = xml.NewMessageFromElement(errElem, stanza.ToJID(), stanza.FromJID())
+	}
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	s.route(errStanza)
+}
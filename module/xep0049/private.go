@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/module"
 	"github.com/ortuman/jackal/module/xep0030"
 	"github.com/ortuman/jackal/storage"
 	"github.com/ortuman/jackal/stream"
@@ -17,19 +18,25 @@ import (
 
 const privateNamespace = "jabber:iq:private"
 
-// Private represents a private storage server stream module.
+func init() {
+	module.Register("private", func(_ string, _ *module.Config) (module.Module, error) {
+		return New(), nil
+	})
+}
+
+// Private represents a private storage server module.
 type Private struct {
-	stm     stream.C2S
 	actorCh chan func()
+	doneCh  chan chan struct{}
 }
 
 // New returns a private storage IQ handler module.
-func New(stm stream.C2S) *Private {
+func New() *Private {
 	x := &Private{
-		stm:     stm,
-		actorCh: make(chan func(), 32),
+		actorCh: make(chan func(), 64),
+		doneCh:  make(chan chan struct{}),
 	}
-	go x.actorLoop(stm.Context().Done())
+	go x.loop()
 	return x
 }
 
@@ -46,40 +53,49 @@ func (x *Private) MatchesIQ(iq *xml.IQ) bool {
 
 // ProcessIQ processes a private storage IQ taking according actions
 // over the associated stream.
-func (x *Private) ProcessIQ(iq *xml.IQ) {
+func (x *Private) ProcessIQ(iq *xml.IQ, stm stream.C2S) {
 	x.actorCh <- func() {
 		q := iq.Elements().ChildNamespace("query", privateNamespace)
 		toJid := iq.ToJID()
-		validTo := toJid.IsServer() || toJid.Node() == x.stm.Username()
+		validTo := toJid.IsServer() || toJid.Node() == stm.Username()
 		if !validTo {
-			x.stm.SendElement(iq.ForbiddenError())
+			stm.SendElement(iq.ForbiddenError())
 			return
 		}
 		if iq.IsGet() {
-			x.getPrivate(iq, q)
+			x.getPrivate(iq, q, stm)
 		} else if iq.IsSet() {
-			x.setPrivate(iq, q)
+			x.setPrivate(iq, q, stm)
 		} else {
-			x.stm.SendElement(iq.BadRequestError())
+			stm.SendElement(iq.BadRequestError())
 			return
 		}
 	}
 }
 
-func (x *Private) actorLoop(doneCh <-chan struct{}) {
+// Shutdown shuts down private storage module.
+func (x *Private) Shutdown() {
+	ch := make(chan struct{})
+	x.doneCh <- ch
+	<-ch
+}
+
+// runs on it's own goroutine
+func (x *Private) loop() {
 	for {
 		select {
 		case f := <-x.actorCh:
 			f()
-		case <-doneCh:
+		case ch := <-x.doneCh:
+			close(ch)
 			return
 		}
 	}
 }
 
-func (x *Private) getPrivate(iq *xml.IQ, q xml.XElement) {
+func (x *Private) getPrivate(iq *xml.IQ, q xml.XElement, stm stream.C2S) {
 	if q.Elements().Count() != 1 {
-		x.stm.SendElement(iq.NotAcceptableError())
+		stm.SendElement(iq.NotAcceptableError())
 		return
 	}
 	privElem := q.Elements().All()[0]
@@ -87,15 +103,15 @@ func (x *Private) getPrivate(iq *xml.IQ, q xml.XElement) {
 	isValidNS := x.isValidNamespace(privNS)
 
 	if privElem.Elements().Count() > 0 || !isValidNS {
-		x.stm.SendElement(iq.NotAcceptableError())
+		stm.SendElement(iq.NotAcceptableError())
 		return
 	}
-	log.Infof("retrieving private element. ns: %s... (%s/%s)", privNS, x.stm.Username(), x.stm.Resource())
+	log.Infof("retrieving private element. ns: %s... (%s/%s)", privNS, stm.Username(), stm.Resource())
 
-	privElements, err := storage.Instance().FetchPrivateXML(privNS, x.stm.Username())
+	privElements, err := storage.Instance().FetchPrivateXML(privNS, stm.Username(), stm.Domain())
 	if err != nil {
 		log.Errorf("%v", err)
-		x.stm.SendElement(iq.InternalServerError())
+		stm.SendElement(iq.InternalServerError())
 		return
 	}
 	res := iq.ResultIQ()
@@ -107,20 +123,20 @@ func (x *Private) getPrivate(iq *xml.IQ, q xml.XElement) {
 	}
 	res.AppendElement(query)
 
-	x.stm.SendElement(res)
+	stm.SendElement(res)
 }
 
-func (x *Private) setPrivate(iq *xml.IQ, q xml.XElement) {
+func (x *Private) setPrivate(iq *xml.IQ, q xml.XElement, stm stream.C2S) {
 	nsElements := map[string][]xml.XElement{}
 
 	for _, privElement := range q.Elements().All() {
 		ns := privElement.Namespace()
 		if len(ns) == 0 {
-			x.stm.SendElement(iq.BadRequestError())
+			stm.SendElement(iq.BadRequestError())
 			return
 		}
 		if !x.isValidNamespace(privElement.Namespace()) {
-			x.stm.SendElement(iq.NotAcceptableError())
+			stm.SendElement(iq.NotAcceptableError())
 			return
 		}
 		elems := nsElements[ns]
@@ -132,15 +148,15 @@ func (x *Private) setPrivate(iq *xml.IQ, q xml.XElement) {
 		nsElements[ns] = elems
 	}
 	for ns, elements := range nsElements {
-		log.Infof("saving private element. ns: %s... (%s/%s)", ns, x.stm.Username(), x.stm.Resource())
+		log.Infof("saving private element. ns: %s... (%s/%s)", ns, stm.Username(), stm.Resource())
 
-		if err := storage.Instance().InsertOrUpdatePrivateXML(elements, ns, x.stm.Username()); err != nil {
+		if err := storage.Instance().InsertOrUpdatePrivateXML(elements, ns, stm.Username(), stm.Domain()); err != nil {
 			log.Errorf("%v", err)
-			x.stm.SendElement(iq.InternalServerError())
+			stm.SendElement(iq.InternalServerError())
 			return
 		}
 	}
-	x.stm.SendElement(iq.ResultIQ())
+	stm.SendElement(iq.ResultIQ())
 }
 
 func (x *Private) isValidNamespace(ns string) bool {
@@ -24,7 +24,8 @@ func TestXEP0049_Matching(t *testing.T) {
 
 	stm.SetUsername("romeo")
 
-	x := New(stm)
+	x := New()
+	defer x.Shutdown()
 
 	iq := xml.NewIQType(uuid.New(), xml.GetType)
 	iq.SetFromJID(j)
@@ -43,7 +44,8 @@ func TestXEP0049_InvalidIQ(t *testing.T) {
 
 	stm.SetUsername("romeo")
 
-	x := New(stm)
+	x := New()
+	defer x.Shutdown()
 
 	iq := xml.NewIQType(uuid.New(), xml.GetType)
 	iq.SetFromJID(j)
@@ -51,37 +53,37 @@ func TestXEP0049_InvalidIQ(t *testing.T) {
 	q := xml.NewElementNamespace("query", privateNamespace)
 	iq.AppendElement(q)
 
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem := stm.FetchElement()
 	require.Equal(t, xml.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
 
 	iq.SetType(xml.ResultType)
 	stm.SetUsername("ortuman")
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, xml.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
 
 	iq.SetType(xml.GetType)
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, xml.ErrNotAcceptable.Error(), elem.Error().Elements().All()[0].Name())
 
 	exodus := xml.NewElementNamespace("exodus", "exodus:ns")
 	exodus.AppendElement(xml.NewElementName("exodus2"))
 	q.AppendElement(exodus)
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, xml.ErrNotAcceptable.Error(), elem.Error().Elements().All()[0].Name())
 
 	exodus.ClearElements()
 	exodus.SetNamespace("jabber:client")
 	iq.SetType(xml.SetType)
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, xml.ErrNotAcceptable.Error(), elem.Error().Elements().All()[0].Name())
 
 	exodus.SetNamespace("")
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, xml.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
 }
@@ -96,7 +98,8 @@ func TestXEP0049_SetAndGetPrivate(t *testing.T) {
 
 	stm.SetUsername("ortuman")
 
-	x := New(stm)
+	x := New()
+	defer x.Shutdown()
 
 	iqID := uuid.New()
 	iq := xml.NewIQType(iqID, xml.SetType)
@@ -112,13 +115,13 @@ func TestXEP0049_SetAndGetPrivate(t *testing.T) {
 
 	// set error
 	storage.ActivateMockedError()
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem := stm.FetchElement()
 	require.Equal(t, xml.ErrInternalServerError.Error(), elem.Error().Elements().All()[0].Name())
 	storage.DeactivateMockedError()
 
 	// set success
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, xml.ResultType, elem.Type())
 	require.Equal(t, iqID, elem.ID())
@@ -128,13 +131,13 @@ func TestXEP0049_SetAndGetPrivate(t *testing.T) {
 	iq.SetType(xml.GetType)
 
 	storage.ActivateMockedError()
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, xml.ErrInternalServerError.Error(), elem.Error().Elements().All()[0].Name())
 	storage.DeactivateMockedError()
 
 	// get success
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, xml.ResultType, elem.Type())
 	require.Equal(t, iqID, elem.ID())
@@ -145,7 +148,7 @@ func TestXEP0049_SetAndGetPrivate(t *testing.T) {
 
 	// get non existing
 	exodus1.SetNamespace("exodus:ns:2")
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, xml.ResultType, elem.Type())
 	require.Equal(t, iqID, elem.ID())
@@ -0,0 +1,306 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0050
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/module"
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+	"github.com/pborman/uuid"
+)
+
+const commandsNamespace = "http://jabber.org/protocol/commands"
+
+// command specific error conditions
+const (
+	malformedActionError = "malformed-action"
+	badActionError       = "bad-action"
+	badPayloadError      = "bad-payload"
+	badSessionIDError    = "bad-sessionid"
+)
+
+// Config represents Ad-Hoc Commands module configuration.
+type Config struct {
+	// Admins contains the JIDs allowed to execute admin-only commands.
+	Admins []string `yaml:"admins"`
+}
+
+func init() {
+	module.Register("adhoc", func(domain string, cfg *module.Config) (module.Module, error) {
+		var config Config
+		if err := cfg.Decode("adhoc", &config); err != nil {
+			return nil, err
+		}
+		return New(domain, &config)
+	})
+}
+
+// AdHoc represents an ad-hoc commands server module.
+type AdHoc struct {
+	domain    string
+	admins    []*jid.JID
+	mu        sync.RWMutex
+	commands  map[string]*Command
+	discoInfo *xep0030.DiscoInfo
+	sessions  map[string]*Session
+	actorCh   chan func()
+	doneCh    chan chan struct{}
+}
+
+// New returns an ad-hoc commands IQ handler module
+// associated to a local domain.
+func New(domain string, config *Config) (*AdHoc, error) {
+	var admins []*jid.JID
+	for _, admin := range config.Admins {
+		j, err := jid.NewWithString(admin, false)
+		if err != nil {
+			return nil, fmt.Errorf("xep0050: invalid admin jid: %s", admin)
+		}
+		admins = append(admins, j)
+	}
+	x := &AdHoc{
+		domain:   domain,
+		admins:   admins,
+		commands: make(map[string]*Command),
+		sessions: make(map[string]*Session),
+		actorCh:  make(chan func(), 64),
+		doneCh:   make(chan chan struct{}),
+	}
+	go x.loop()
+	return x, nil
+}
+
+// RegisterDisco registers disco entity features/items
+// associated to ad-hoc commands module.
+func (x *AdHoc) RegisterDisco(discoInfo *xep0030.DiscoInfo) {
+	discoInfo.ServerEntity().AddFeature(commandsNamespace)
+	if _, err := discoInfo.RegisterEntity(x.domain, commandsNamespace); err != nil {
+		log.Error(err)
+		return
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.discoInfo = discoInfo
+	for _, cmd := range x.commands {
+		x.registerCommandDisco(cmd)
+	}
+}
+
+// RegisterCommand makes a command available to be executed
+// by requesting entities.
+func (x *AdHoc) RegisterCommand(cmd Command) error {
+	if len(cmd.Node) == 0 {
+		return errors.New("xep0050: command node must be specified")
+	}
+	if cmd.Handler == nil {
+		return fmt.Errorf("xep0050: command handler is nil: %s", cmd.Node)
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if _, ok := x.commands[cmd.Node]; ok {
+		return fmt.Errorf("xep0050: command already registered: %s", cmd.Node)
+	}
+	x.commands[cmd.Node] = &cmd
+	if x.discoInfo != nil {
+		x.registerCommandDisco(&cmd)
+	}
+	return nil
+}
+
+// IsAdmin returns whether or not a JID has been configured as admin.
+func (x *AdHoc) IsAdmin(j *jid.JID) bool {
+	for _, admin := range x.admins {
+		if admin.IsFull() {
+			if j.Matches(admin, jid.MatchesBare|jid.MatchesResource) {
+				return true
+			}
+		} else if j.Matches(admin, jid.MatchesBare) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesIQ returns whether or not an IQ should be
+// processed by the ad-hoc commands module.
+func (x *AdHoc) MatchesIQ(iq *xml.IQ) bool {
+	return iq.IsSet() && iq.ToJID().IsServer() && iq.Elements().ChildNamespace("command", commandsNamespace) != nil
+}
+
+// ProcessIQ processes an ad-hoc command IQ taking according actions
+// over the associated stream.
+func (x *AdHoc) ProcessIQ(iq *xml.IQ, stm stream.C2S) {
+	x.actorCh <- func() {
+		x.processCommand(iq, stm)
+	}
+}
+
+// StreamStarted is invoked once a stream session has been started.
+func (x *AdHoc) StreamStarted(_ stream.C2S) {}
+
+// StreamClosed discards every command session
+// initiated by a closed stream.
+func (x *AdHoc) StreamClosed(stm stream.C2S) {
+	x.actorCh <- func() {
+		for id, sess := range x.sessions {
+			if sess.JID.Matches(stm.JID(), jid.MatchesBare|jid.MatchesResource) {
+				delete(x.sessions, id)
+			}
+		}
+	}
+}
+
+// Shutdown shuts down ad-hoc commands module.
+func (x *AdHoc) Shutdown() {
+	ch := make(chan struct{})
+	x.doneCh <- ch
+	<-ch
+}
+
+// runs on it's own goroutine
+func (x *AdHoc) loop() {
+	for {
+		select {
+		case f := <-x.actorCh:
+			f()
+		case ch := <-x.doneCh:
+			close(ch)
+			return
+		}
+	}
+}
+
+func (x *AdHoc) processCommand(iq *xml.IQ, stm stream.C2S) {
+	cmdEl := iq.Elements().ChildNamespace("command", commandsNamespace)
+	node := cmdEl.Attributes().Get("node")
+
+	x.mu.RLock()
+	cmd := x.commands[node]
+	x.mu.RUnlock()
+	if cmd == nil {
+		stm.SendElement(iq.ItemNotFoundError())
+		return
+	}
+	if cmd.Access == AdminAccess && !x.IsAdmin(stm.JID()) {
+		stm.SendElement(iq.ForbiddenError())
+		return
+	}
+	action := cmdEl.Attributes().Get("action")
+	switch action {
+	case "", Execute, Cancel, Prev, Next, Complete:
+		break
+	default:
+		stm.SendElement(commandError(iq, xml.ErrBadRequest, malformedActionError))
+		return
+	}
+	var form *xep0004.DataForm
+	if formEl := cmdEl.Elements().ChildNamespace("x", xep0004.FormNamespace); formEl != nil {
+		f, err := xep0004.NewFormFromElement(formEl)
+		if err != nil {
+			stm.SendElement(commandError(iq, xml.ErrBadRequest, badPayloadError))
+			return
+		}
+		form = f
+	}
+	var sess *Session
+	if sessionID := cmdEl.Attributes().Get("sessionid"); len(sessionID) > 0 {
+		sess = x.sessions[sessionID]
+		if sess == nil || sess.Node != node || !sess.JID.Matches(stm.JID(), jid.MatchesBare|jid.MatchesResource) {
+			stm.SendElement(commandError(iq, xml.ErrBadRequest, badSessionIDError))
+			return
+		}
+		var ok bool
+		if action, ok = sess.resolveAction(action); !ok {
+			stm.SendElement(commandError(iq, xml.ErrBadRequest, badActionError))
+			return
+		}
+	} else {
+		if len(action) > 0 && action != Execute {
+			stm.SendElement(commandError(iq, xml.ErrBadRequest, badActionError))
+			return
+		}
+		action = Execute
+		sess = &Session{
+			ID:   uuid.New(),
+			Node: node,
+			JID:  stm.JID(),
+			Data: make(map[string]interface{}),
+		}
+	}
+	if action == Cancel {
+		delete(x.sessions, sess.ID)
+		res := iq.ResultIQ()
+		res.AppendElement((&Response{Status: Canceled}).Element(node, sess.ID))
+		stm.SendElement(res)
+		return
+	}
+	resp, err := cmd.Handler(&Request{
+		Session: sess,
+		Action:  action,
+		Form:    form,
+		Stream:  stm,
+	})
+	if err != nil {
+		delete(x.sessions, sess.ID)
+		if stanzaErr, ok := err.(*xml.StanzaError); ok {
+			stm.SendElement(xml.NewErrorElementFromElement(iq, stanzaErr, nil))
+			return
+		}
+		log.Error(err)
+		stm.SendElement(iq.InternalServerError())
+		return
+	}
+	if resp == nil {
+		resp = &Response{Status: Completed}
+	}
+	if resp.Status == Executing {
+		sess.actions = resp.Actions
+		sess.defaultAction = resp.DefaultAction
+		x.sessions[sess.ID] = sess
+	} else {
+		delete(x.sessions, sess.ID)
+	}
+	res := iq.ResultIQ()
+	res.AppendElement(resp.Element(node, sess.ID))
+	stm.SendElement(res)
+}
+
+func (x *AdHoc) registerCommandDisco(cmd *Command) {
+	item := xep0030.Item{
+		Jid:  x.domain,
+		Node: cmd.Node,
+		Name: cmd.Name,
+	}
+	if cmd.Access == AdminAccess {
+		item.Visible = x.IsAdmin
+	}
+	x.discoInfo.Entity(x.domain, commandsNamespace).AddItem(item)
+	ent, err := x.discoInfo.RegisterEntity(x.domain, cmd.Node)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	ent.AddIdentity(xep0030.Identity{
+		Category: "automation",
+		Type:     "command-node",
+		Name:     cmd.Name,
+	})
+	ent.AddFeature(commandsNamespace)
+	ent.AddFeature(xep0004.FormNamespace)
+}
+
+func commandError(iq *xml.IQ, stanzaErr *xml.StanzaError, condition string) xml.XElement {
+	errEl := xml.NewElementNamespace(condition, commandsNamespace)
+	return xml.NewErrorElementFromElement(iq, stanzaErr, []xml.XElement{errEl})
+}
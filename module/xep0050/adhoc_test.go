@@ -0,0 +1,331 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0050
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXEP0050_New(t *testing.T) {
+	_, err := New("jackal.im", &Config{Admins: []string{"admin@jackal.im", "jackal.im/ops"}})
+	require.Nil(t, err)
+
+	_, err = New("jackal.im", &Config{Admins: []string{"ortuman@"}})
+	require.NotNil(t, err)
+}
+
+func TestXEP0050_IsAdmin(t *testing.T) {
+	x, _ := New("jackal.im", &Config{Admins: []string{"admin@jackal.im", "ops@jackal.im/console"}})
+	defer x.Shutdown()
+
+	j1, _ := jid.New("admin", "jackal.im", "balcony", true)
+	j2, _ := jid.New("ops", "jackal.im", "console", true)
+	j3, _ := jid.New("ops", "jackal.im", "garden", true)
+	j4, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+
+	require.True(t, x.IsAdmin(j1))
+	require.True(t, x.IsAdmin(j2))
+	require.False(t, x.IsAdmin(j3))
+	require.False(t, x.IsAdmin(j4))
+}
+
+func TestXEP0050_RegisterCommand(t *testing.T) {
+	x, _ := New("jackal.im", &Config{})
+	defer x.Shutdown()
+
+	handler := func(_ *Request) (*Response, error) { return &Response{Status: Completed}, nil }
+
+	require.Nil(t, x.RegisterCommand(Command{Node: "ping", Name: "Ping", Handler: handler}))
+	require.NotNil(t, x.RegisterCommand(Command{Node: "ping", Name: "Ping", Handler: handler}))
+	require.NotNil(t, x.RegisterCommand(Command{Name: "Ping", Handler: handler}))
+	require.NotNil(t, x.RegisterCommand(Command{Node: "echo", Name: "Echo"}))
+
+	discoInfo := xep0030.New("jackal.im")
+	x.RegisterDisco(discoInfo)
+	require.Contains(t, discoInfo.ServerEntity().Features(), commandsNamespace)
+
+	// commands registered after disco
+	require.Nil(t, x.RegisterCommand(Command{Node: "uptime", Name: "Uptime", Handler: handler}))
+
+	items := discoInfo.Entity("jackal.im", commandsNamespace).Items()
+	require.Equal(t, 2, len(items))
+
+	ent := discoInfo.Entity("jackal.im", "uptime")
+	require.NotNil(t, ent)
+	require.Equal(t, "automation", ent.Identities()[0].Category)
+	require.Equal(t, "command-node", ent.Identities()[0].Type)
+	require.Contains(t, ent.Features(), xep0004.FormNamespace)
+}
+
+func TestXEP0050_Matching(t *testing.T) {
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	srvJID, _ := jid.New("", "jackal.im", "", true)
+
+	x, _ := New("jackal.im", &Config{})
+	defer x.Shutdown()
+
+	iq := tUtilCommandIQ(j, srvJID, "ping", "", "", nil)
+	require.True(t, x.MatchesIQ(iq))
+
+	iq.SetToJID(j.ToBareJID())
+	require.False(t, x.MatchesIQ(iq))
+
+	iq.SetToJID(srvJID)
+	iq.SetType(xml.GetType)
+	require.False(t, x.MatchesIQ(iq))
+}
+
+func TestXEP0050_SingleStageCommand(t *testing.T) {
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	srvJID, _ := jid.New("", "jackal.im", "", true)
+	stm := stream.NewMockC2S(uuid.New(), j)
+
+	x, _ := New("jackal.im", &Config{})
+	defer x.Shutdown()
+
+	x.RegisterCommand(Command{
+		Node: "ping",
+		Name: "Ping",
+		Handler: func(req *Request) (*Response, error) {
+			require.Equal(t, Execute, req.Action)
+			return &Response{Status: Completed, Notes: []Note{{Type: InfoNote, Text: "pong"}}}, nil
+		},
+	})
+	x.RegisterCommand(Command{
+		Node: "fail",
+		Name: "Fail",
+		Handler: func(_ *Request) (*Response, error) {
+			return nil, xml.ErrNotAllowed
+		},
+	})
+	x.RegisterCommand(Command{
+		Node: "crash",
+		Name: "Crash",
+		Handler: func(_ *Request) (*Response, error) {
+			return nil, errors.New("crashed")
+		},
+	})
+
+	x.ProcessIQ(tUtilCommandIQ(j, srvJID, "ping", "", "", nil), stm)
+	elem := stm.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+	cmd := elem.Elements().ChildNamespace("command", commandsNamespace)
+	require.NotNil(t, cmd)
+	require.Equal(t, Completed, cmd.Attributes().Get("status"))
+	require.True(t, len(cmd.Attributes().Get("sessionid")) > 0)
+	require.Equal(t, "pong", cmd.Elements().Child("note").Text())
+
+	// unknown command
+	x.ProcessIQ(tUtilCommandIQ(j, srvJID, "unknown", "", "", nil), stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ErrItemNotFound.Error(), elem.Error().Elements().All()[0].Name())
+
+	// malformed action
+	x.ProcessIQ(tUtilCommandIQ(j, srvJID, "ping", "", "jump", nil), stm)
+	elem = stm.FetchElement()
+	require.NotNil(t, elem.Error().Elements().ChildNamespace(malformedActionError, commandsNamespace))
+
+	// unknown session
+	x.ProcessIQ(tUtilCommandIQ(j, srvJID, "ping", uuid.New(), "", nil), stm)
+	elem = stm.FetchElement()
+	require.NotNil(t, elem.Error().Elements().ChildNamespace(badSessionIDError, commandsNamespace))
+
+	// handler errors
+	x.ProcessIQ(tUtilCommandIQ(j, srvJID, "fail", "", "", nil), stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ErrNotAllowed.Error(), elem.Error().Elements().All()[0].Name())
+
+	x.ProcessIQ(tUtilCommandIQ(j, srvJID, "crash", "", "", nil), stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ErrInternalServerError.Error(), elem.Error().Elements().All()[0].Name())
+}
+
+func TestXEP0050_MultiStageCommand(t *testing.T) {
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("ortuman", "jackal.im", "garden", true)
+	srvJID, _ := jid.New("", "jackal.im", "", true)
+	stm1 := stream.NewMockC2S(uuid.New(), j1)
+	stm2 := stream.NewMockC2S(uuid.New(), j2)
+
+	x, _ := New("jackal.im", &Config{})
+	defer x.Shutdown()
+
+	x.RegisterCommand(Command{
+		Node: "config",
+		Name: "Configure Service",
+		Handler: func(req *Request) (*Response, error) {
+			switch req.Action {
+			case Execute, Prev:
+				req.Session.Data["stage"] = 1
+				return &Response{
+					Status:        Executing,
+					Actions:       []string{Next},
+					DefaultAction: Next,
+					Form:          &xep0004.DataForm{Type: xep0004.Form, Title: "Stage 1"},
+				}, nil
+			case Next:
+				if req.Form == nil {
+					return nil, xml.ErrBadRequest
+				}
+				req.Session.Data["stage"] = 2
+				req.Session.Data["service"] = req.Form.Fields.ValueForField("service")
+				return &Response{
+					Status:  Executing,
+					Actions: []string{Prev, Complete},
+					Form:    &xep0004.DataForm{Type: xep0004.Form, Title: "Stage 2"},
+				}, nil
+			default:
+				return &Response{
+					Status: Completed,
+					Notes:  []Note{{Type: InfoNote, Text: req.Session.Data["service"].(string)}},
+				}, nil
+			}
+		},
+	})
+
+	// stage 1
+	x.ProcessIQ(tUtilCommandIQ(j1, srvJID, "config", "", Execute, nil), stm1)
+	elem := stm1.FetchElement()
+	cmd := elem.Elements().ChildNamespace("command", commandsNamespace)
+	require.Equal(t, Executing, cmd.Attributes().Get("status"))
+	require.Equal(t, Next, cmd.Elements().Child("actions").Attributes().Get("execute"))
+	sessionID := cmd.Attributes().Get("sessionid")
+
+	// session belongs to another resource
+	x.ProcessIQ(tUtilCommandIQ(j2, srvJID, "config", sessionID, "", nil), stm2)
+	elem = stm2.FetchElement()
+	require.NotNil(t, elem.Error().Elements().ChildNamespace(badSessionIDError, commandsNamespace))
+
+	// action not allowed at this stage
+	x.ProcessIQ(tUtilCommandIQ(j1, srvJID, "config", sessionID, Complete, nil), stm1)
+	elem = stm1.FetchElement()
+	require.NotNil(t, elem.Error().Elements().ChildNamespace(badActionError, commandsNamespace))
+
+	// stage 2 (default action)
+	form := &xep0004.DataForm{Type: xep0004.Submit}
+	form.Fields = append(form.Fields, xep0004.Field{Var: "service", Values: []string{"httpd"}})
+	x.ProcessIQ(tUtilCommandIQ(j1, srvJID, "config", sessionID, "", form), stm1)
+	elem = stm1.FetchElement()
+	cmd = elem.Elements().ChildNamespace("command", commandsNamespace)
+	require.Equal(t, Executing, cmd.Attributes().Get("status"))
+	require.Equal(t, sessionID, cmd.Attributes().Get("sessionid"))
+	require.Equal(t, "Stage 2", cmd.Elements().ChildNamespace("x", xep0004.FormNamespace).Elements().Child("title").Text())
+
+	// back to stage 1
+	x.ProcessIQ(tUtilCommandIQ(j1, srvJID, "config", sessionID, Prev, nil), stm1)
+	elem = stm1.FetchElement()
+	cmd = elem.Elements().ChildNamespace("command", commandsNamespace)
+	require.Equal(t, "Stage 1", cmd.Elements().ChildNamespace("x", xep0004.FormNamespace).Elements().Child("title").Text())
+
+	x.ProcessIQ(tUtilCommandIQ(j1, srvJID, "config", sessionID, Next, form), stm1)
+	stm1.FetchElement()
+
+	// complete
+	x.ProcessIQ(tUtilCommandIQ(j1, srvJID, "config", sessionID, Complete, nil), stm1)
+	elem = stm1.FetchElement()
+	cmd = elem.Elements().ChildNamespace("command", commandsNamespace)
+	require.Equal(t, Completed, cmd.Attributes().Get("status"))
+	require.Equal(t, "httpd", cmd.Elements().Child("note").Text())
+
+	// session is over
+	x.ProcessIQ(tUtilCommandIQ(j1, srvJID, "config", sessionID, Complete, nil), stm1)
+	elem = stm1.FetchElement()
+	require.NotNil(t, elem.Error().Elements().ChildNamespace(badSessionIDError, commandsNamespace))
+
+	// cancel
+	x.ProcessIQ(tUtilCommandIQ(j1, srvJID, "config", "", Execute, nil), stm1)
+	elem = stm1.FetchElement()
+	sessionID = elem.Elements().ChildNamespace("command", commandsNamespace).Attributes().Get("sessionid")
+
+	x.ProcessIQ(tUtilCommandIQ(j1, srvJID, "config", sessionID, Cancel, nil), stm1)
+	elem = stm1.FetchElement()
+	require.Equal(t, Canceled, elem.Elements().ChildNamespace("command", commandsNamespace).Attributes().Get("status"))
+
+	// sessions are discarded on stream close
+	x.ProcessIQ(tUtilCommandIQ(j1, srvJID, "config", "", Execute, nil), stm1)
+	elem = stm1.FetchElement()
+	sessionID = elem.Elements().ChildNamespace("command", commandsNamespace).Attributes().Get("sessionid")
+
+	x.StreamClosed(stm1)
+	x.ProcessIQ(tUtilCommandIQ(j1, srvJID, "config", sessionID, Next, form), stm1)
+	elem = stm1.FetchElement()
+	require.NotNil(t, elem.Error().Elements().ChildNamespace(badSessionIDError, commandsNamespace))
+}
+
+func TestXEP0050_AdminCommand(t *testing.T) {
+	j1, _ := jid.New("admin", "jackal.im", "balcony", true)
+	j2, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	srvJID, _ := jid.New("", "jackal.im", "", true)
+	stm1 := stream.NewMockC2S(uuid.New(), j1)
+	stm2 := stream.NewMockC2S(uuid.New(), j2)
+
+	x, _ := New("jackal.im", &Config{Admins: []string{"admin@jackal.im"}})
+	defer x.Shutdown()
+
+	x.RegisterCommand(Command{
+		Node:   "restart",
+		Name:   "Restart Service",
+		Access: AdminAccess,
+		Handler: func(_ *Request) (*Response, error) {
+			return &Response{Status: Completed}, nil
+		},
+	})
+
+	discoInfo := xep0030.New("jackal.im")
+	x.RegisterDisco(discoInfo)
+
+	// admin commands are only listed to admins
+	iq := xml.NewIQType(uuid.New(), xml.GetType)
+	iq.SetFromJID(j2)
+	iq.SetToJID(srvJID)
+	q := xml.NewElementNamespace("query", "http://jabber.org/protocol/disco#items")
+	q.SetAttribute("node", commandsNamespace)
+	iq.AppendElement(q)
+	discoInfo.ProcessIQ(iq, stm2)
+	elem := stm2.FetchElement()
+	require.Equal(t, 0, elem.Elements().Child("query").Elements().Count())
+
+	iq.SetFromJID(j1)
+	discoInfo.ProcessIQ(iq, stm1)
+	elem = stm1.FetchElement()
+	require.Equal(t, 1, elem.Elements().Child("query").Elements().Count())
+
+	x.ProcessIQ(tUtilCommandIQ(j2, srvJID, "restart", "", "", nil), stm2)
+	elem = stm2.FetchElement()
+	require.Equal(t, xml.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+
+	x.ProcessIQ(tUtilCommandIQ(j1, srvJID, "restart", "", "", nil), stm1)
+	elem = stm1.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+}
+
+func tUtilCommandIQ(from, to *jid.JID, node, sessionID, action string, form *xep0004.DataForm) *xml.IQ {
+	cmd := xml.NewElementNamespace("command", commandsNamespace)
+	cmd.SetAttribute("node", node)
+	if len(sessionID) > 0 {
+		cmd.SetAttribute("sessionid", sessionID)
+	}
+	if len(action) > 0 {
+		cmd.SetAttribute("action", action)
+	}
+	if form != nil {
+		cmd.AppendElement(form.Element())
+	}
+	iq := xml.NewIQType(uuid.New(), xml.SetType)
+	iq.SetFromJID(from)
+	iq.SetToJID(to)
+	iq.AppendElement(cmd)
+	return iq
+}
@@ -0,0 +1,154 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0050
+
+import (
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+)
+
+// command actions
+const (
+	Execute  = "execute"
+	Cancel   = "cancel"
+	Prev     = "prev"
+	Next     = "next"
+	Complete = "complete"
+)
+
+// command status values
+const (
+	Executing = "executing"
+	Completed = "completed"
+	Canceled  = "canceled"
+)
+
+// note type values
+const (
+	InfoNote  = "info"
+	WarnNote  = "warn"
+	ErrorNote = "error"
+)
+
+// Access represents the set of entities allowed to execute a command.
+type Access int
+
+const (
+	// AllAccess allows any local account to execute a command.
+	AllAccess Access = iota
+
+	// AdminAccess allows only configured admin JIDs to execute a command.
+	AdminAccess
+)
+
+// Handler processes a command execution stage.
+// Returning an *xml.StanzaError will make the command session fail
+// with such condition, while any other error will be reported as
+// an internal server error.
+type Handler func(req *Request) (*Response, error)
+
+// Command represents an ad-hoc command.
+type Command struct {
+	Node    string
+	Name    string
+	Access  Access
+	Handler Handler
+}
+
+// Session represents an in progress command execution session.
+type Session struct {
+	ID   string
+	Node string
+	JID  *jid.JID
+
+	// Data can be freely used by a command handler to keep
+	// its state across execution stages.
+	Data map[string]interface{}
+
+	actions       []string
+	defaultAction string
+}
+
+// Request represents a command execution stage request.
+type Request struct {
+	Session *Session
+	Action  string
+	Form    *xep0004.DataForm
+	Stream  stream.C2S
+}
+
+// Note represents a command execution note.
+type Note struct {
+	Type string
+	Text string
+}
+
+// Response represents a command execution stage result.
+type Response struct {
+	Status        string
+	Actions       []string
+	DefaultAction string
+	Notes         []Note
+	Form          *xep0004.DataForm
+}
+
+// Element returns response XML element representation.
+func (r *Response) Element(node, sessionID string) xml.XElement {
+	elem := xml.NewElementNamespace("command", commandsNamespace)
+	elem.SetAttribute("node", node)
+	elem.SetAttribute("sessionid", sessionID)
+	elem.SetAttribute("status", r.Status)
+
+	if r.Status == Executing && len(r.Actions) > 0 {
+		actions := xml.NewElementName("actions")
+		if len(r.DefaultAction) > 0 {
+			actions.SetAttribute("execute", r.DefaultAction)
+		}
+		for _, action := range r.Actions {
+			actions.AppendElement(xml.NewElementName(action))
+		}
+		elem.AppendElement(actions)
+	}
+	for _, note := range r.Notes {
+		noteEl := xml.NewElementName("note")
+		noteEl.SetAttribute("type", note.Type)
+		noteEl.SetText(note.Text)
+		elem.AppendElement(noteEl)
+	}
+	if r.Form != nil {
+		elem.AppendElement(r.Form.Element())
+	}
+	return elem
+}
+
+// resolveAction validates an action requested for a following session stage,
+// returning the action that will be actually performed.
+func (s *Session) resolveAction(action string) (string, bool) {
+	switch action {
+	case Cancel:
+		return action, true
+	case "", Execute:
+		if len(s.defaultAction) > 0 {
+			return s.defaultAction, true
+		}
+		if len(s.actions) == 0 {
+			return Complete, true
+		}
+		return Next, true
+	}
+	if len(s.actions) == 0 {
+		// single stage response
+		return action, action == Complete
+	}
+	for _, a := range s.actions {
+		if a == action {
+			return action, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0050
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponse_Element(t *testing.T) {
+	resp := &Response{
+		Status:        Executing,
+		Actions:       []string{Next, Complete},
+		DefaultAction: Next,
+		Notes:         []Note{{Type: InfoNote, Text: "Service 'httpd' has been configured."}},
+		Form:          &xep0004.DataForm{Type: xep0004.Form},
+	}
+	elem := resp.Element("config", "abcd")
+	require.Equal(t, "command", elem.Name())
+	require.Equal(t, commandsNamespace, elem.Namespace())
+	require.Equal(t, "config", elem.Attributes().Get("node"))
+	require.Equal(t, "abcd", elem.Attributes().Get("sessionid"))
+	require.Equal(t, Executing, elem.Attributes().Get("status"))
+
+	actions := elem.Elements().Child("actions")
+	require.NotNil(t, actions)
+	require.Equal(t, Next, actions.Attributes().Get("execute"))
+	require.Equal(t, 2, actions.Elements().Count())
+
+	note := elem.Elements().Child("note")
+	require.NotNil(t, note)
+	require.Equal(t, InfoNote, note.Attributes().Get("type"))
+	require.NotNil(t, elem.Elements().ChildNamespace("x", xep0004.FormNamespace))
+
+	// actions are only included while executing
+	resp.Status = Completed
+	elem = resp.Element("config", "abcd")
+	require.Nil(t, elem.Elements().Child("actions"))
+}
+
+func TestSession_ResolveAction(t *testing.T) {
+	sess := &Session{}
+
+	// single stage
+	action, ok := sess.resolveAction(Execute)
+	require.True(t, ok)
+	require.Equal(t, Complete, action)
+	action, ok = sess.resolveAction(Complete)
+	require.True(t, ok)
+	require.Equal(t, Complete, action)
+	_, ok = sess.resolveAction(Next)
+	require.False(t, ok)
+
+	// multiple stages
+	sess.actions = []string{Prev, Next}
+	action, ok = sess.resolveAction("")
+	require.True(t, ok)
+	require.Equal(t, Next, action)
+	action, ok = sess.resolveAction(Prev)
+	require.True(t, ok)
+	require.Equal(t, Prev, action)
+	_, ok = sess.resolveAction(Complete)
+	require.False(t, ok)
+
+	sess.defaultAction = Prev
+	action, _ = sess.resolveAction(Execute)
+	require.Equal(t, Prev, action)
+
+	action, ok = sess.resolveAction(Cancel)
+	require.True(t, ok)
+	require.Equal(t, Cancel, action)
+}
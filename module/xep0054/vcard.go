@@ -7,6 +7,7 @@ package xep0054
 
 import (
 	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/module"
 	"github.com/ortuman/jackal/module/xep0030"
 	"github.com/ortuman/jackal/storage"
 	"github.com/ortuman/jackal/stream"
@@ -15,29 +16,33 @@ import (
 
 const vCardNamespace = "vcard-temp"
 
-// VCard represents a vCard server stream module.
+func init() {
+	module.Register("vcard", func(_ string, _ *module.Config) (module.Module, error) {
+		return New(), nil
+	})
+}
+
+// VCard represents a vCard server module.
 type VCard struct {
-	stm     stream.C2S
 	actorCh chan func()
+	doneCh  chan chan struct{}
 }
 
 // New returns a vCard IQ handler module.
-func New(stm stream.C2S) *VCard {
+func New() *VCard {
 	v := &VCard{
-		stm:     stm,
-		actorCh: make(chan func(), 32),
-	}
-	if stm != nil {
-		go v.actorLoop(stm.Context().Done())
+		actorCh: make(chan func(), 64),
+		doneCh:  make(chan chan struct{}),
 	}
+	go v.loop()
 	return v
 }
 
 // RegisterDisco registers disco entity features/items
 // associated to vCard module.
 func (x *VCard) RegisterDisco(discoInfo *xep0030.DiscoInfo) {
-	discoInfo.Entity(x.stm.Domain(), "").AddFeature(vCardNamespace)
-	discoInfo.Entity(x.stm.JID().ToBareJID().String(), "").AddFeature(vCardNamespace)
+	discoInfo.ServerEntity().AddFeature(vCardNamespace)
+	discoInfo.AccountEntity().AddFeature(vCardNamespace)
 }
 
 // MatchesIQ returns whether or not an IQ should be
@@ -48,49 +53,58 @@ func (x *VCard) MatchesIQ(iq *xml.IQ) bool {
 
 // ProcessIQ processes a vCard IQ taking according actions
 // over the associated stream.
-func (x *VCard) ProcessIQ(iq *xml.IQ) {
+func (x *VCard) ProcessIQ(iq *xml.IQ, stm stream.C2S) {
 	x.actorCh <- func() {
 		vCard := iq.Elements().ChildNamespace("vCard", vCardNamespace)
 		if iq.IsGet() {
-			x.getVCard(vCard, iq)
+			x.getVCard(vCard, iq, stm)
 		} else if iq.IsSet() {
-			x.setVCard(vCard, iq)
+			x.setVCard(vCard, iq, stm)
 		}
 	}
 }
 
-func (x *VCard) actorLoop(doneCh <-chan struct{}) {
+// Shutdown shuts down vCard module.
+func (x *VCard) Shutdown() {
+	ch := make(chan struct{})
+	x.doneCh <- ch
+	<-ch
+}
+
+// runs on it's own goroutine
+func (x *VCard) loop() {
 	for {
 		select {
 		case f := <-x.actorCh:
 			f()
-		case <-doneCh:
+		case ch := <-x.doneCh:
+			close(ch)
 			return
 		}
 	}
 }
 
-func (x *VCard) getVCard(vCard xml.XElement, iq *xml.IQ) {
+func (x *VCard) getVCard(vCard xml.XElement, iq *xml.IQ, stm stream.C2S) {
 	if vCard.Elements().Count() > 0 {
-		x.stm.SendElement(iq.BadRequestError())
+		stm.SendElement(iq.BadRequestError())
 		return
 	}
 	toJid := iq.ToJID()
 
-	var username string
+	var username, domain string
 	if toJid.IsServer() {
-		username = x.stm.Username()
+		username, domain = stm.Username(), stm.Domain()
 	} else {
-		username = toJid.Node()
+		username, domain = toJid.Node(), toJid.Domain()
 	}
 
-	resElem, err := storage.Instance().FetchVCard(username)
+	resElem, err := storage.Instance().FetchVCard(username, domain)
 	if err != nil {
 		log.Errorf("%v", err)
-		x.stm.SendElement(iq.InternalServerError())
+		stm.SendElement(iq.InternalServerError())
 		return
 	}
-	log.Infof("retrieving vcard... (%s/%s)", x.stm.Username(), x.stm.Resource())
+	log.Infof("retrieving vcard... (%s/%s)", stm.Username(), stm.Resource())
 
 	resultIQ := iq.ResultIQ()
 	if resElem != nil {
@@ -99,22 +113,22 @@ func (x *VCard) getVCard(vCard xml.XElement, iq *xml.IQ) {
 		// empty vCard
 		resultIQ.AppendElement(xml.NewElementNamespace("vCard", vCardNamespace))
 	}
-	x.stm.SendElement(resultIQ)
+	stm.SendElement(resultIQ)
 }
 
-func (x *VCard) setVCard(vCard xml.XElement, iq *xml.IQ) {
+func (x *VCard) setVCard(vCard xml.XElement, iq *xml.IQ, stm stream.C2S) {
 	toJid := iq.ToJID()
-	if toJid.IsServer() || (toJid.IsBare() && toJid.Node() == x.stm.Username()) {
-		log.Infof("saving vcard... (%s/%s)", x.stm.Username(), x.stm.Resource())
+	if toJid.IsServer() || (toJid.IsBare() && toJid.Node() == stm.Username()) {
+		log.Infof("saving vcard... (%s/%s)", stm.Username(), stm.Resource())
 
-		err := storage.Instance().InsertOrUpdateVCard(vCard, x.stm.Username())
+		err := storage.Instance().InsertOrUpdateVCard(vCard, stm.Username(), stm.Domain())
 		if err != nil {
 			log.Errorf("%v", err)
-			x.stm.SendElement(iq.InternalServerError())
+			stm.SendElement(iq.InternalServerError())
 			return
 		}
-		x.stm.SendElement(iq.ResultIQ())
+		stm.SendElement(iq.ResultIQ())
 	} else {
-		x.stm.SendElement(iq.ForbiddenError())
+		stm.SendElement(iq.ForbiddenError())
 	}
 }
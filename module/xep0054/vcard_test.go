@@ -19,7 +19,8 @@ import (
 func TestXEP0054_Matching(t *testing.T) {
 	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
 
-	x := New(nil)
+	x := New()
+	defer x.Shutdown()
 
 	// test MatchesIQ
 	iqID := uuid.New()
@@ -54,9 +55,10 @@ func TestXEP0054_Set(t *testing.T) {
 	iq.SetToJID(j.ToBareJID())
 	iq.AppendElement(testVCard())
 
-	x := New(stm)
+	x := New()
+	defer x.Shutdown()
 
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem := stm.FetchElement()
 	require.NotNil(t, elem)
 	require.Equal(t, xml.ResultType, elem.Type())
@@ -69,7 +71,7 @@ func TestXEP0054_Set(t *testing.T) {
 	iq2.SetToJID(j.ToBareJID())
 	iq2.AppendElement(xml.NewElementNamespace("vCard", vCardNamespace))
 
-	x.ProcessIQ(iq2)
+	x.ProcessIQ(iq2, stm)
 	elem = stm.FetchElement()
 	require.NotNil(t, elem)
 	require.Equal(t, xml.ResultType, elem.Type())
@@ -88,7 +90,8 @@ func TestXEP0054_SetError(t *testing.T) {
 
 	stm.SetUsername("ortuman")
 
-	x := New(stm)
+	x := New()
+	defer x.Shutdown()
 
 	// set other user vCard...
 	iq := xml.NewIQType(uuid.New(), xml.SetType)
@@ -96,7 +99,7 @@ func TestXEP0054_SetError(t *testing.T) {
 	iq.SetToJID(j2.ToBareJID())
 	iq.AppendElement(testVCard())
 
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem := stm.FetchElement()
 	require.Equal(t, xml.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
 
@@ -109,7 +112,7 @@ func TestXEP0054_SetError(t *testing.T) {
 	iq2.SetToJID(j.ToBareJID())
 	iq2.AppendElement(testVCard())
 
-	x.ProcessIQ(iq2)
+	x.ProcessIQ(iq2, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, xml.ErrInternalServerError.Error(), elem.Error().Elements().All()[0].Name())
 }
@@ -129,9 +132,10 @@ func TestXEP0054_Get(t *testing.T) {
 	iqSet.SetToJID(j.ToBareJID())
 	iqSet.AppendElement(testVCard())
 
-	x := New(stm)
+	x := New()
+	defer x.Shutdown()
 
-	x.ProcessIQ(iqSet)
+	x.ProcessIQ(iqSet, stm)
 	_ = stm.FetchElement() // wait until set...
 
 	iqGetID := uuid.New()
@@ -140,7 +144,7 @@ func TestXEP0054_Get(t *testing.T) {
 	iqGet.SetToJID(j.ToBareJID())
 	iqGet.AppendElement(xml.NewElementNamespace("vCard", vCardNamespace))
 
-	x.ProcessIQ(iqGet)
+	x.ProcessIQ(iqGet, stm)
 	elem := stm.FetchElement()
 	require.NotNil(t, elem)
 	vCard := elem.Elements().ChildNamespace("vCard", vCardNamespace)
@@ -154,7 +158,7 @@ func TestXEP0054_Get(t *testing.T) {
 	iqGet2.SetToJID(j2.ToBareJID())
 	iqGet2.AppendElement(xml.NewElementNamespace("vCard", vCardNamespace))
 
-	x.ProcessIQ(iqGet2)
+	x.ProcessIQ(iqGet2, stm)
 	elem = stm.FetchElement()
 	require.NotNil(t, elem)
 	vCard = elem.Elements().ChildNamespace("vCard", vCardNamespace)
@@ -175,9 +179,10 @@ func TestXEP0054_GetError(t *testing.T) {
 	iqSet.SetToJID(j.ToBareJID())
 	iqSet.AppendElement(testVCard())
 
-	x := New(stm)
+	x := New()
+	defer x.Shutdown()
 
-	x.ProcessIQ(iqSet)
+	x.ProcessIQ(iqSet, stm)
 	_ = stm.FetchElement() // wait until set...
 
 	iqGetID := uuid.New()
@@ -188,7 +193,7 @@ func TestXEP0054_GetError(t *testing.T) {
 	vCard.AppendElement(xml.NewElementName("FN"))
 	iqGet.AppendElement(vCard)
 
-	x.ProcessIQ(iqGet)
+	x.ProcessIQ(iqGet, stm)
 	elem := stm.FetchElement()
 	require.Equal(t, xml.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
 
@@ -201,7 +206,7 @@ func TestXEP0054_GetError(t *testing.T) {
 	storage.ActivateMockedError()
 	defer storage.DeactivateMockedError()
 
-	x.ProcessIQ(iqGet2)
+	x.ProcessIQ(iqGet2, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, xml.ErrInternalServerError.Error(), elem.Error().Elements().All()[0].Name())
 }
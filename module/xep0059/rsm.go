@@ -0,0 +1,87 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0059
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/ortuman/jackal/xml"
+)
+
+// RSMNamespace represents result set management namespace.
+const RSMNamespace = "http://jabber.org/protocol/rsm"
+
+// Request represents a result set management request.
+type Request struct {
+	Max       int
+	After     string
+	Before    string
+	LastPage  bool
+	Index     int
+	HasIndex  bool
+	CountOnly bool
+}
+
+// NewRequestFromElement parses an XML element returning a derived result set request.
+func NewRequestFromElement(elem xml.XElement) (*Request, error) {
+	if elem.Name() != "set" || elem.Namespace() != RSMNamespace {
+		return nil, fmt.Errorf("invalid result set element: %s", elem.Name())
+	}
+	req := &Request{Max: -1}
+	if max := elem.Elements().Child("max"); max != nil {
+		n, err := strconv.Atoi(max.Text())
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid result set max value: %s", max.Text())
+		}
+		req.Max = n
+		req.CountOnly = n == 0
+	}
+	if after := elem.Elements().Child("after"); after != nil {
+		req.After = after.Text()
+	}
+	if before := elem.Elements().Child("before"); before != nil {
+		req.Before = before.Text()
+		req.LastPage = len(req.Before) == 0
+	}
+	if index := elem.Elements().Child("index"); index != nil {
+		n, err := strconv.Atoi(index.Text())
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid result set index value: %s", index.Text())
+		}
+		req.Index = n
+		req.HasIndex = true
+	}
+	return req, nil
+}
+
+// Result represents a result set management response.
+type Result struct {
+	First      string
+	FirstIndex int
+	Last       string
+	Count      int
+}
+
+// Element returns result set XML element representation.
+func (r *Result) Element() xml.XElement {
+	set := xml.NewElementNamespace("set", RSMNamespace)
+	if len(r.First) > 0 {
+		first := xml.NewElementName("first")
+		first.SetAttribute("index", strconv.Itoa(r.FirstIndex))
+		first.SetText(r.First)
+		set.AppendElement(first)
+	}
+	if len(r.Last) > 0 {
+		last := xml.NewElementName("last")
+		last.SetText(r.Last)
+		set.AppendElement(last)
+	}
+	count := xml.NewElementName("count")
+	count.SetText(strconv.Itoa(r.Count))
+	set.AppendElement(count)
+	return set
+}
@@ -0,0 +1,45 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0059
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/xml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRSM_RequestFromElement(t *testing.T) {
+	_, err := NewRequestFromElement(xml.NewElementNamespace("set", "urn:xmpp:other"))
+	require.NotNil(t, err)
+
+	set := xml.NewElementNamespace("set", RSMNamespace)
+	max := xml.NewElementName("max")
+	max.SetText("foo")
+	set.AppendElement(max)
+	_, err = NewRequestFromElement(set)
+	require.NotNil(t, err)
+
+	set = xml.NewElementNamespace("set", RSMNamespace)
+	max = xml.NewElementName("max")
+	max.SetText("10")
+	set.AppendElement(max)
+	set.AppendElement(xml.NewElementName("before"))
+	req, err := NewRequestFromElement(set)
+	require.Nil(t, err)
+	require.Equal(t, 10, req.Max)
+	require.True(t, req.LastPage)
+}
+
+func TestRSM_ResultElement(t *testing.T) {
+	r := Result{First: "a", FirstIndex: 0, Last: "b", Count: 5}
+	elem := r.Element()
+	require.Equal(t, RSMNamespace, elem.Namespace())
+	require.Equal(t, "a", elem.Elements().Child("first").Text())
+	require.Equal(t, "0", elem.Elements().Child("first").Attributes().Get("index"))
+	require.Equal(t, "b", elem.Elements().Child("last").Text())
+	require.Equal(t, "5", elem.Elements().Child("count").Text())
+}
@@ -0,0 +1,210 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0060
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"sort"
+	"sync"
+
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+	"github.com/pborman/uuid"
+)
+
+const capsNamespace = "http://jabber.org/protocol/caps"
+
+type capsRequest struct {
+	ver string
+	stm stream.C2S
+}
+
+// entityCaps keeps track of entity capabilities (XEP-0115)
+// announced by local resources, caching disco info features
+// by verification string.
+type entityCaps struct {
+	mu       sync.RWMutex
+	features map[string][]string     // indexed by verification string
+	requests map[string]*capsRequest // indexed by disco info request identifier
+}
+
+func newEntityCaps() *entityCaps {
+	return &entityCaps{
+		features: make(map[string][]string),
+		requests: make(map[string]*capsRequest),
+	}
+}
+
+// isResolved returns whether or not the features associated
+// to a presence capabilities are already known.
+func (ec *entityCaps) isResolved(presence *xml.Presence) bool {
+	ver := capsVer(presence)
+	if len(ver) == 0 {
+		return false
+	}
+	ec.mu.RLock()
+	_, ok := ec.features[ver]
+	ec.mu.RUnlock()
+	return ok
+}
+
+// hasFeature returns whether or not the capabilities announced
+// within a presence include a given feature.
+func (ec *entityCaps) hasFeature(presence *xml.Presence, feature string) bool {
+	if presence == nil || !presence.IsAvailable() {
+		return false
+	}
+	ver := capsVer(presence)
+	if len(ver) == 0 {
+		return false
+	}
+	ec.mu.RLock()
+	defer ec.mu.RUnlock()
+	for _, f := range ec.features[ver] {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// requestFeatures queries a presence originating stream
+// for the disco info features associated to its capabilities.
+func (ec *entityCaps) requestFeatures(presence *xml.Presence, stm stream.C2S) {
+	c := presence.Elements().ChildNamespace("c", capsNamespace)
+	ver := capsVer(presence)
+	if len(ver) == 0 {
+		return
+	}
+	reqID := uuid.New()
+	ec.mu.Lock()
+	ec.requests[reqID] = &capsRequest{ver: ver, stm: stm}
+	ec.mu.Unlock()
+
+	domainJID, _ := jid.New("", stm.Domain(), "", true)
+	iq := xml.NewIQType(reqID, xml.GetType)
+	iq.SetFromJID(domainJID)
+	iq.SetToJID(stm.JID())
+	query := xml.NewElementNamespace("query", discoInfoNamespace)
+	query.SetAttribute("node", c.Attributes().Get("node")+"#"+ver)
+	iq.AppendElement(query)
+	stm.SendElement(iq)
+}
+
+// isFeaturesResponse returns whether or not an IQ corresponds
+// to a previously sent features request response.
+func (ec *entityCaps) isFeaturesResponse(iq *xml.IQ) bool {
+	if !iq.IsResult() && iq.Type() != xml.ErrorType {
+		return false
+	}
+	ec.mu.RLock()
+	defer ec.mu.RUnlock()
+	_, ok := ec.requests[iq.ID()]
+	return ok
+}
+
+// processFeaturesResponse caches the features contained into a features
+// request response, returning the originating stream if they've been
+// successfully verified.
+func (ec *entityCaps) processFeaturesResponse(iq *xml.IQ) stream.C2S {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+	req := ec.requests[iq.ID()]
+	if req == nil {
+		return nil
+	}
+	delete(ec.requests, iq.ID())
+
+	query := iq.Elements().ChildNamespace("query", discoInfoNamespace)
+	if !iq.IsResult() || query == nil || capsVerification(query) != req.ver {
+		return nil
+	}
+	var features []string
+	for _, f := range query.Elements().Children("feature") {
+		features = append(features, f.Attributes().Get("var"))
+	}
+	ec.features[req.ver] = features
+	return req.stm
+}
+
+// removeRequests discards every pending features request
+// associated to a stream.
+func (ec *entityCaps) removeRequests(stm stream.C2S) {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+	for id, req := range ec.requests {
+		if req.stm == stm {
+			delete(ec.requests, id)
+		}
+	}
+}
+
+func capsVer(presence *xml.Presence) string {
+	c := presence.Elements().ChildNamespace("c", capsNamespace)
+	if c == nil || c.Attributes().Get("hash") != "sha-1" {
+		return ""
+	}
+	return c.Attributes().Get("ver")
+}
+
+// capsVerification generates the verification string
+// associated to a disco info query as described in
+// https://xmpp.org/extensions/xep-0115.html#ver-gen
+func capsVerification(query xml.XElement) string {
+	var identities, features []string
+	for _, identity := range query.Elements().Children("identity") {
+		attrs := identity.Attributes()
+		identities = append(identities, attrs.Get("category")+"/"+attrs.Get("type")+"/"+attrs.Get("xml:lang")+"/"+attrs.Get("name"))
+	}
+	for _, feature := range query.Elements().Children("feature") {
+		features = append(features, feature.Attributes().Get("var"))
+	}
+	sort.Strings(identities)
+	sort.Strings(features)
+
+	var forms []*xep0004.DataForm
+	for _, x := range query.Elements().ChildrenNamespace("x", xep0004.FormNamespace) {
+		form, err := xep0004.NewFormFromElement(x)
+		if err != nil {
+			continue
+		}
+		forms = append(forms, form)
+	}
+	sort.Slice(forms, func(i, j int) bool { return forms[i].FormType() < forms[j].FormType() })
+
+	buf := bytes.NewBuffer(nil)
+	for _, s := range identities {
+		buf.WriteString(s + "<")
+	}
+	for _, s := range features {
+		buf.WriteString(s + "<")
+	}
+	for _, form := range forms {
+		buf.WriteString(form.FormType() + "<")
+
+		fields := make(xep0004.Fields, 0, len(form.Fields))
+		for _, field := range form.Fields {
+			if field.Var != xep0004.FormTypeFieldVar {
+				fields = append(fields, field)
+			}
+		}
+		sort.Slice(fields, func(i, j int) bool { return fields[i].Var < fields[j].Var })
+		for _, field := range fields {
+			buf.WriteString(field.Var + "<")
+			values := append([]string(nil), field.Values...)
+			sort.Strings(values)
+			for _, v := range values {
+				buf.WriteString(v + "<")
+			}
+		}
+	}
+	h := sha1.Sum(buf.Bytes())
+	return base64.StdEncoding.EncodeToString(h[:])
+}
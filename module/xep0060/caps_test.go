@@ -0,0 +1,115 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0060
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/xml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntityCaps_Verification(t *testing.T) {
+	// https://xmpp.org/extensions/xep-0115.html#ver-gen-simple
+	q := tUtilDiscoInfoQuery([]string{
+		"http://jabber.org/protocol/caps",
+		"http://jabber.org/protocol/disco#info",
+		"http://jabber.org/protocol/disco#items",
+		"http://jabber.org/protocol/muc",
+	})
+	require.Equal(t, "QgayPKawpkPSDYmwT/WM94uAlu0=", capsVerification(q))
+
+	// https://xmpp.org/extensions/xep-0115.html#ver-gen-complex
+	complexQuery := xml.NewElementNamespace("query", discoInfoNamespace)
+	for _, name := range []string{"Ψ 0.11", "Psi 0.11"} {
+		identity := xml.NewElementName("identity")
+		identity.SetAttribute("category", "client")
+		identity.SetAttribute("type", "pc")
+		if name == "Psi 0.11" {
+			identity.SetAttribute("xml:lang", "en")
+		} else {
+			identity.SetAttribute("xml:lang", "el")
+		}
+		identity.SetAttribute("name", name)
+		complexQuery.AppendElement(identity)
+	}
+	for _, f := range []string{
+		"http://jabber.org/protocol/muc",
+		"http://jabber.org/protocol/disco#info",
+		"http://jabber.org/protocol/caps",
+		"http://jabber.org/protocol/disco#items",
+	} {
+		complexQuery.AppendElement(featureElement(f))
+	}
+	form := &xep0004.DataForm{
+		Type: xep0004.Result,
+		Fields: xep0004.Fields{
+			{Var: xep0004.FormTypeFieldVar, Type: xep0004.Hidden, Values: []string{"urn:xmpp:dataforms:softwareinfo"}},
+			{Var: "ip_version", Values: []string{"ipv6", "ipv4"}},
+			{Var: "os", Values: []string{"Mac"}},
+			{Var: "os_version", Values: []string{"10.5.1"}},
+			{Var: "software", Values: []string{"Psi"}},
+			{Var: "software_version", Values: []string{"0.11"}},
+		},
+	}
+	complexQuery.AppendElement(form.Element())
+	require.Equal(t, "q07IKJEyjvHSyhy//CH0CxmKi8w=", capsVerification(complexQuery))
+}
+
+func TestEntityCaps_Features(t *testing.T) {
+	shutdown := tUtilPubSubInitialize()
+	defer shutdown()
+
+	j1 := tUtilJID("ortuman@jackal.im/balcony")
+	stm1 := tUtilStreamBind(j1)
+
+	ec := newEntityCaps()
+
+	q := tUtilDiscoInfoQuery([]string{"urn:xmpp:avatar:metadata+notify"})
+	p := tUtilCapsPresence(j1, capsVerification(q))
+	require.False(t, ec.isResolved(p))
+
+	ec.requestFeatures(p, stm1)
+	elem := stm1.FetchElement()
+	require.Equal(t, "iq", elem.Name())
+	require.Equal(t, "jackal.im", elem.From())
+	require.Equal(t, "https://jackal.im#"+capsVerification(q), elem.Elements().ChildNamespace("query", discoInfoNamespace).Attributes().Get("node"))
+
+	// bogus response
+	iq := xml.NewIQType(elem.ID(), xml.ResultType)
+	iq.SetFromJID(j1)
+	iq.SetToJID(tUtilJID("jackal.im"))
+	iq.AppendElement(tUtilDiscoInfoQuery([]string{"urn:xmpp:tune+notify"}))
+	require.True(t, ec.isFeaturesResponse(iq))
+	require.Nil(t, ec.processFeaturesResponse(iq))
+	require.False(t, ec.isResolved(p))
+	require.False(t, ec.isFeaturesResponse(iq))
+
+	ec.requestFeatures(p, stm1)
+	elem = stm1.FetchElement()
+	iq = xml.NewIQType(elem.ID(), xml.ResultType)
+	iq.SetFromJID(j1)
+	iq.SetToJID(tUtilJID("jackal.im"))
+	iq.AppendElement(q)
+	require.Equal(t, stm1, ec.processFeaturesResponse(iq))
+	require.True(t, ec.isResolved(p))
+	require.True(t, ec.hasFeature(p, "urn:xmpp:avatar:metadata+notify"))
+	require.False(t, ec.hasFeature(p, "urn:xmpp:tune+notify"))
+}
+
+func tUtilDiscoInfoQuery(features []string) xml.XElement {
+	q := xml.NewElementNamespace("query", discoInfoNamespace)
+	identity := xml.NewElementName("identity")
+	identity.SetAttribute("category", "client")
+	identity.SetAttribute("type", "pc")
+	identity.SetAttribute("name", "Exodus 0.9.1")
+	q.AppendElement(identity)
+	for _, f := range features {
+		q.AppendElement(featureElement(f))
+	}
+	return q
+}
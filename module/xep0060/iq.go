@@ -0,0 +1,881 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0060
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/model/pubsubmodel"
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+	"github.com/pborman/uuid"
+)
+
+var pubSubFeatures = []string{
+	"create-nodes",
+	"instant-nodes",
+	"config-node",
+	"delete-nodes",
+	"purge-nodes",
+	"publish",
+	"retract-items",
+	"retrieve-items",
+	"item-ids",
+	"persistent-items",
+	"publish-options",
+	"subscribe",
+	"retrieve-subscriptions",
+	"retrieve-affiliations",
+	"manage-subscriptions",
+	"modify-affiliations",
+	"retrieve-default",
+	"access-open",
+	"access-presence",
+	"access-roster",
+	"access-whitelist",
+}
+
+func (s *service) processIQ(iq *xml.IQ) {
+	if !iq.IsGet() && !iq.IsSet() {
+		return
+	}
+	nodeHost := iq.ToJID().ToBareJID().String()
+	elems := iq.Elements()
+	switch {
+	case iq.IsGet() && elems.ChildNamespace("query", discoInfoNamespace) != nil:
+		s.sendDiscoInfo(iq, nodeHost, elems.ChildNamespace("query", discoInfoNamespace).Attributes().Get("node"))
+	case iq.IsGet() && elems.ChildNamespace("query", discoItemsNamespace) != nil:
+		s.sendDiscoItems(iq, nodeHost, elems.ChildNamespace("query", discoItemsNamespace).Attributes().Get("node"))
+	case elems.ChildNamespace("pubsub", pubSubNamespace) != nil:
+		s.processPubSubIQ(iq, nodeHost, elems.ChildNamespace("pubsub", pubSubNamespace))
+	case elems.ChildNamespace("pubsub", pubSubOwnerNamespace) != nil:
+		s.processOwnerIQ(iq, nodeHost, elems.ChildNamespace("pubsub", pubSubOwnerNamespace))
+	default:
+		s.sendError(iq, xml.ErrServiceUnavailable)
+	}
+}
+
+func (s *service) sendDiscoInfo(iq *xml.IQ, nodeHost, nodeName string) {
+	query := xml.NewElementNamespace("query", discoInfoNamespace)
+	if len(nodeName) == 0 {
+		query.AppendElement(identityElement("pubsub", "service", "Publish-Subscribe"))
+		query.AppendElement(featureElement(discoInfoNamespace))
+		query.AppendElement(featureElement(discoItemsNamespace))
+		query.AppendElement(featureElement(pubSubNamespace))
+		for _, feature := range pubSubFeatures {
+			query.AppendElement(featureElement(pubSubNamespace + "#" + feature))
+		}
+		s.route(s.resultIQ(iq, query))
+		return
+	}
+	n, ok := s.requireNode(iq, nodeHost, nodeName)
+	if !ok {
+		return
+	}
+	query.SetAttribute("node", n.Name)
+	query.AppendElement(identityElement("pubsub", "leaf", n.Options.Title))
+	query.AppendElement(featureElement(pubSubNamespace))
+	s.route(s.resultIQ(iq, query))
+}
+
+func (s *service) sendDiscoItems(iq *xml.IQ, nodeHost, nodeName string) {
+	query := xml.NewElementNamespace("query", discoItemsNamespace)
+	if len(nodeName) == 0 {
+		nodes, err := storage.Instance().FetchPubSubNodes(nodeHost)
+		if err != nil {
+			log.Error(err)
+			s.sendError(iq, xml.ErrInternalServerError)
+			return
+		}
+		for _, n := range nodes {
+			item := xml.NewElementName("item")
+			item.SetAttribute("jid", nodeHost)
+			item.SetAttribute("node", n.Name)
+			if len(n.Options.Title) > 0 {
+				item.SetAttribute("name", n.Options.Title)
+			}
+			query.AppendElement(item)
+		}
+		s.route(s.resultIQ(iq, query))
+		return
+	}
+	n, ok := s.requireNode(iq, nodeHost, nodeName)
+	if !ok || !s.checkAccess(iq, n) {
+		return
+	}
+	items, err := storage.Instance().FetchPubSubItems(n.Host, n.Name)
+	if err != nil {
+		log.Error(err)
+		s.sendError(iq, xml.ErrInternalServerError)
+		return
+	}
+	query.SetAttribute("node", n.Name)
+	for _, it := range items {
+		item := xml.NewElementName("item")
+		item.SetAttribute("jid", nodeHost)
+		item.SetAttribute("name", it.ID)
+		query.AppendElement(item)
+	}
+	s.route(s.resultIQ(iq, query))
+}
+
+func (s *service) processPubSubIQ(iq *xml.IQ, nodeHost string, pubSub xml.XElement) {
+	elems := pubSub.Elements()
+	if iq.IsSet() {
+		switch {
+		case elems.Child("create") != nil:
+			s.createNode(iq, nodeHost, elems.Child("create"), elems.Child("configure"))
+		case elems.Child("publish") != nil:
+			s.publishItem(iq, nodeHost, elems.Child("publish"), elems.Child("publish-options"))
+		case elems.Child("retract") != nil:
+			s.retractItem(iq, nodeHost, elems.Child("retract"))
+		case elems.Child("subscribe") != nil:
+			s.subscribe(iq, nodeHost, elems.Child("subscribe"))
+		case elems.Child("unsubscribe") != nil:
+			s.unsubscribe(iq, nodeHost, elems.Child("unsubscribe"))
+		default:
+			s.sendError(iq, xml.ErrFeatureNotImplemented)
+		}
+		return
+	}
+	switch {
+	case elems.Child("items") != nil:
+		s.sendItems(iq, nodeHost, elems.Child("items"))
+	case elems.Child("subscriptions") != nil:
+		s.sendEntitySubscriptions(iq, nodeHost, elems.Child("subscriptions"))
+	case elems.Child("affiliations") != nil:
+		s.sendEntityAffiliations(iq, nodeHost, elems.Child("affiliations"))
+	default:
+		s.sendError(iq, xml.ErrFeatureNotImplemented)
+	}
+}
+
+func (s *service) processOwnerIQ(iq *xml.IQ, nodeHost string, pubSub xml.XElement) {
+	elems := pubSub.Elements()
+	switch {
+	case iq.IsGet() && elems.Child("default") != nil:
+		def := xml.NewElementName("default")
+		def.AppendElement(configForm(s.defaultOptions()).Element())
+		s.route(s.resultIQ(iq, pubSubElement(pubSubOwnerNamespace, def)))
+	case elems.Child("configure") != nil:
+		s.processConfigure(iq, nodeHost, elems.Child("configure"))
+	case iq.IsSet() && elems.Child("delete") != nil:
+		s.deleteNode(iq, nodeHost, elems.Child("delete"))
+	case iq.IsSet() && elems.Child("purge") != nil:
+		s.purgeNode(iq, nodeHost, elems.Child("purge"))
+	case elems.Child("subscriptions") != nil:
+		s.processOwnerSubscriptions(iq, nodeHost, elems.Child("subscriptions"))
+	case elems.Child("affiliations") != nil:
+		s.processOwnerAffiliations(iq, nodeHost, elems.Child("affiliations"))
+	default:
+		s.sendError(iq, xml.ErrFeatureNotImplemented)
+	}
+}
+
+func (s *service) createNode(iq *xml.IQ, nodeHost string, create, configure xml.XElement) {
+	fromJID := iq.FromJID()
+	if !host.IsLocalHost(fromJID.Domain()) || (s.pep && fromJID.ToBareJID().String() != nodeHost) {
+		s.sendError(iq, xml.ErrForbidden)
+		return
+	}
+	nodeName := create.Attributes().Get("node")
+	instant := len(nodeName) == 0
+	if instant {
+		nodeName = uuid.New()
+	}
+	n, err := s.fetchNode(nodeHost, nodeName)
+	if err != nil {
+		log.Error(err)
+		s.sendError(iq, xml.ErrInternalServerError)
+		return
+	}
+	if n != nil {
+		s.sendError(iq, xml.ErrConflict)
+		return
+	}
+	opts := s.defaultOptions()
+	if configure != nil {
+		if x := configure.Elements().ChildNamespace("x", xep0004.FormNamespace); x != nil {
+			form, err := xep0004.NewFormFromElement(x)
+			if err != nil || form.Type != xep0004.Submit || !applyConfigForm(&opts, form, nodeConfigFormType) {
+				s.sendError(iq, xml.ErrNotAcceptable)
+				return
+			}
+		}
+	}
+	n = &pubsubmodel.Node{Host: nodeHost, Name: nodeName, Options: opts}
+	n.SetAffiliation(fromJID.ToBareJID().String(), pubsubmodel.AffiliationOwner)
+	if err := s.saveNode(n); err != nil {
+		log.Error(err)
+		s.sendError(iq, xml.ErrInternalServerError)
+		return
+	}
+	if !instant {
+		s.route(s.resultIQ(iq, nil))
+		return
+	}
+	c := xml.NewElementName("create")
+	c.SetAttribute("node", nodeName)
+	s.route(s.resultIQ(iq, pubSubElement(pubSubNamespace, c)))
+}
+
+func (s *service) publishItem(iq *xml.IQ, nodeHost string, publish, publishOptions xml.XElement) {
+	nodeName := publish.Attributes().Get("node")
+	if len(nodeName) == 0 {
+		s.sendErrorCondition(iq, xml.ErrBadRequest, "nodeid-required")
+		return
+	}
+	itemElem := publish.Elements().Child("item")
+	if itemElem == nil || itemElem.Elements().Count() > 1 {
+		s.sendErrorCondition(iq, xml.ErrBadRequest, "item-required")
+		return
+	}
+	var preconditions *xep0004.DataForm
+	if publishOptions != nil {
+		if x := publishOptions.Elements().ChildNamespace("x", xep0004.FormNamespace); x != nil {
+			form, err := xep0004.NewFormFromElement(x)
+			if err != nil || form.Type != xep0004.Submit {
+				s.sendError(iq, xml.ErrBadRequest)
+				return
+			}
+			preconditions = form
+		}
+	}
+	n, err := s.fetchNode(nodeHost, nodeName)
+	if err != nil {
+		log.Error(err)
+		s.sendError(iq, xml.ErrInternalServerError)
+		return
+	}
+	fromBareJID := iq.FromJID().ToBareJID().String()
+	switch {
+	case n == nil:
+		// personal eventing nodes are automatically created on first publish
+		if !s.pep || fromBareJID != nodeHost {
+			s.sendError(iq, xml.ErrItemNotFound)
+			return
+		}
+		n = &pubsubmodel.Node{Host: nodeHost, Name: nodeName, Options: s.defaultOptions()}
+		n.SetAffiliation(fromBareJID, pubsubmodel.AffiliationOwner)
+		if preconditions != nil && !applyConfigForm(&n.Options, preconditions, publishOptionsFormType) {
+			s.sendErrorCondition(iq, xml.ErrConflict, "precondition-not-met")
+			return
+		}
+		if err := s.saveNode(n); err != nil {
+			log.Error(err)
+			s.sendError(iq, xml.ErrInternalServerError)
+			return
+		}
+	case !s.canPublish(n, iq.FromJID()):
+		s.sendError(iq, xml.ErrForbidden)
+		return
+	case preconditions != nil:
+		opts := n.Options
+		if !applyConfigForm(&opts, preconditions, publishOptionsFormType) || !reflect.DeepEqual(opts, n.Options) {
+			s.sendErrorCondition(iq, xml.ErrConflict, "precondition-not-met")
+			return
+		}
+	}
+	item := &pubsubmodel.Item{
+		ID:        itemElem.Attributes().Get("id"),
+		Publisher: iq.FromJID().ToBareJID().String(),
+		CreatedAt: time.Now(),
+	}
+	if len(item.ID) == 0 {
+		item.ID = uuid.New()
+	}
+	if payload := itemElem.Elements().All(); len(payload) > 0 {
+		item.Payload = payload[0]
+	}
+	if n.Options.PersistItems {
+		if err := storage.Instance().InsertOrUpdatePubSubItem(item, n.Host, n.Name, n.Options.MaxItems); err != nil {
+			log.Error(err)
+			s.sendError(iq, xml.ErrInternalServerError)
+			return
+		}
+	}
+	p := xml.NewElementName("publish")
+	p.SetAttribute("node", n.Name)
+	it := xml.NewElementName("item")
+	it.SetAttribute("id", item.ID)
+	p.AppendElement(it)
+	s.route(s.resultIQ(iq, pubSubElement(pubSubNamespace, p)))
+
+	s.notify(n, iq.ToJID().ToBareJID(), s.itemsEventElement(n, item))
+}
+
+func (s *service) retractItem(iq *xml.IQ, nodeHost string, retract xml.XElement) {
+	n, ok := s.requireNode(iq, nodeHost, retract.Attributes().Get("node"))
+	if !ok {
+		return
+	}
+	itemElem := retract.Elements().Child("item")
+	if itemElem == nil || len(itemElem.Attributes().Get("id")) == 0 {
+		s.sendErrorCondition(iq, xml.ErrBadRequest, "item-required")
+		return
+	}
+	itemID := itemElem.Attributes().Get("id")
+	items, err := storage.Instance().FetchPubSubItems(n.Host, n.Name)
+	if err != nil {
+		log.Error(err)
+		s.sendError(iq, xml.ErrInternalServerError)
+		return
+	}
+	var item *pubsubmodel.Item
+	for i := range items {
+		if items[i].ID == itemID {
+			item = &items[i]
+			break
+		}
+	}
+	if item == nil {
+		s.sendError(iq, xml.ErrItemNotFound)
+		return
+	}
+	fromBareJID := iq.FromJID().ToBareJID().String()
+	if n.Affiliation(fromBareJID) != pubsubmodel.AffiliationOwner && item.Publisher != fromBareJID {
+		s.sendError(iq, xml.ErrForbidden)
+		return
+	}
+	if err := storage.Instance().DeletePubSubItem(itemID, n.Host, n.Name); err != nil {
+		log.Error(err)
+		s.sendError(iq, xml.ErrInternalServerError)
+		return
+	}
+	s.route(s.resultIQ(iq, nil))
+
+	notify := retract.Attributes().Get("notify")
+	if n.Options.NotifyRetract || notify == "1" || notify == "true" {
+		items := xml.NewElementName("items")
+		items.SetAttribute("node", n.Name)
+		r := xml.NewElementName("retract")
+		r.SetAttribute("id", itemID)
+		items.AppendElement(r)
+		s.notify(n, iq.ToJID().ToBareJID(), items)
+	}
+}
+
+func (s *service) subscribe(iq *xml.IQ, nodeHost string, subscribe xml.XElement) {
+	subJID, err := jid.NewWithString(subscribe.Attributes().Get("jid"), false)
+	if err != nil {
+		s.sendErrorCondition(iq, xml.ErrBadRequest, "jid-required")
+		return
+	}
+	if subJID.ToBareJID().String() != iq.FromJID().ToBareJID().String() {
+		s.sendErrorCondition(iq, xml.ErrBadRequest, "invalid-jid")
+		return
+	}
+	n, ok := s.requireNode(iq, nodeHost, subscribe.Attributes().Get("node"))
+	if !ok || !s.checkAccess(iq, n) {
+		return
+	}
+	subID := n.Subscription(subJID.String())
+	if len(subID) == 0 {
+		subID = uuid.New()
+		n.SetSubscription(subJID.String(), subID)
+		if err := s.saveNode(n); err != nil {
+			log.Error(err)
+			s.sendError(iq, xml.ErrInternalServerError)
+			return
+		}
+	}
+	s.route(s.resultIQ(iq, pubSubElement(pubSubNamespace, subscriptionElement(n.Name, subJID.String(), subID))))
+
+	if n.Options.SendLastPublishedItem != pubsubmodel.SendLastPublishedItemNever {
+		s.sendLastPublishedItem(n, subJID)
+	}
+}
+
+func (s *service) unsubscribe(iq *xml.IQ, nodeHost string, unsubscribe xml.XElement) {
+	subJID, err := jid.NewWithString(unsubscribe.Attributes().Get("jid"), false)
+	if err != nil {
+		s.sendErrorCondition(iq, xml.ErrBadRequest, "jid-required")
+		return
+	}
+	if subJID.ToBareJID().String() != iq.FromJID().ToBareJID().String() {
+		s.sendError(iq, xml.ErrForbidden)
+		return
+	}
+	n, ok := s.requireNode(iq, nodeHost, unsubscribe.Attributes().Get("node"))
+	if !ok {
+		return
+	}
+	if len(n.Subscription(subJID.String())) == 0 {
+		s.sendErrorCondition(iq, xml.ErrUnexpectedCondition, "not-subscribed")
+		return
+	}
+	n.SetSubscription(subJID.String(), "")
+	if err := s.saveNode(n); err != nil {
+		log.Error(err)
+		s.sendError(iq, xml.ErrInternalServerError)
+		return
+	}
+	s.route(s.resultIQ(iq, nil))
+}
+
+func (s *service) sendItems(iq *xml.IQ, nodeHost string, itemsElem xml.XElement) {
+	n, ok := s.requireNode(iq, nodeHost, itemsElem.Attributes().Get("node"))
+	if !ok || !s.checkAccess(iq, n) {
+		return
+	}
+	items, err := storage.Instance().FetchPubSubItems(n.Host, n.Name)
+	if err != nil {
+		log.Error(err)
+		s.sendError(iq, xml.ErrInternalServerError)
+		return
+	}
+	// filter requested item identifiers
+	if requested := itemsElem.Elements().Children("item"); len(requested) > 0 {
+		var filtered []pubsubmodel.Item
+		for _, it := range items {
+			for _, r := range requested {
+				if r.Attributes().Get("id") == it.ID {
+					filtered = append(filtered, it)
+					break
+				}
+			}
+		}
+		items = filtered
+	}
+	if maxItems, err := strconv.Atoi(itemsElem.Attributes().Get("max_items")); err == nil && maxItems >= 0 && maxItems < len(items) {
+		items = items[len(items)-maxItems:]
+	}
+	ret := xml.NewElementName("items")
+	ret.SetAttribute("node", n.Name)
+	for i := range items {
+		ret.AppendElement(itemElement(&items[i], true))
+	}
+	s.route(s.resultIQ(iq, pubSubElement(pubSubNamespace, ret)))
+}
+
+func (s *service) sendEntitySubscriptions(iq *xml.IQ, nodeHost string, subscriptions xml.XElement) {
+	nodes, ok := s.fetchNodes(iq, nodeHost, subscriptions.Attributes().Get("node"))
+	if !ok {
+		return
+	}
+	fromJID := iq.FromJID()
+	ret := xml.NewElementName("subscriptions")
+	for _, n := range nodes {
+		for _, j := range []string{fromJID.ToBareJID().String(), fromJID.String()} {
+			if subID := n.Subscription(j); len(subID) > 0 {
+				ret.AppendElement(subscriptionElement(n.Name, j, subID))
+			}
+			if fromJID.IsBare() {
+				break
+			}
+		}
+	}
+	s.route(s.resultIQ(iq, pubSubElement(pubSubNamespace, ret)))
+}
+
+func (s *service) sendEntityAffiliations(iq *xml.IQ, nodeHost string, affiliations xml.XElement) {
+	nodes, ok := s.fetchNodes(iq, nodeHost, affiliations.Attributes().Get("node"))
+	if !ok {
+		return
+	}
+	fromBareJID := iq.FromJID().ToBareJID().String()
+	ret := xml.NewElementName("affiliations")
+	for _, n := range nodes {
+		aff := n.Affiliation(fromBareJID)
+		if aff == pubsubmodel.AffiliationNone {
+			continue
+		}
+		a := xml.NewElementName("affiliation")
+		a.SetAttribute("node", n.Name)
+		a.SetAttribute("affiliation", aff)
+		ret.AppendElement(a)
+	}
+	s.route(s.resultIQ(iq, pubSubElement(pubSubNamespace, ret)))
+}
+
+func (s *service) processConfigure(iq *xml.IQ, nodeHost string, configure xml.XElement) {
+	n, ok := s.requireOwnedNode(iq, nodeHost, configure.Attributes().Get("node"))
+	if !ok {
+		return
+	}
+	if iq.IsGet() {
+		c := xml.NewElementName("configure")
+		c.SetAttribute("node", n.Name)
+		c.AppendElement(configForm(n.Options).Element())
+		s.route(s.resultIQ(iq, pubSubElement(pubSubOwnerNamespace, c)))
+		return
+	}
+	x := configure.Elements().ChildNamespace("x", xep0004.FormNamespace)
+	if x == nil {
+		s.sendError(iq, xml.ErrBadRequest)
+		return
+	}
+	form, err := xep0004.NewFormFromElement(x)
+	if err != nil {
+		s.sendError(iq, xml.ErrBadRequest)
+		return
+	}
+	switch form.Type {
+	case xep0004.Submit:
+		if !applyConfigForm(&n.Options, form, nodeConfigFormType) {
+			s.sendError(iq, xml.ErrNotAcceptable)
+			return
+		}
+		if err := s.saveNode(n); err != nil {
+			log.Error(err)
+			s.sendError(iq, xml.ErrInternalServerError)
+			return
+		}
+		s.route(s.resultIQ(iq, nil))
+
+		if n.Options.NotifyConfig {
+			c := xml.NewElementName("configuration")
+			c.SetAttribute("node", n.Name)
+			s.notify(n, iq.ToJID().ToBareJID(), c)
+		}
+	case xep0004.Cancel:
+		s.route(s.resultIQ(iq, nil))
+	default:
+		s.sendError(iq, xml.ErrBadRequest)
+	}
+}
+
+func (s *service) deleteNode(iq *xml.IQ, nodeHost string, del xml.XElement) {
+	n, ok := s.requireOwnedNode(iq, nodeHost, del.Attributes().Get("node"))
+	if !ok {
+		return
+	}
+	if err := storage.Instance().DeletePubSubNode(n.Host, n.Name); err != nil {
+		log.Error(err)
+		s.sendError(iq, xml.ErrInternalServerError)
+		return
+	}
+	s.route(s.resultIQ(iq, nil))
+
+	if n.Options.NotifyDelete {
+		d := xml.NewElementName("delete")
+		d.SetAttribute("node", n.Name)
+		s.notify(n, iq.ToJID().ToBareJID(), d)
+	}
+}
+
+func (s *service) purgeNode(iq *xml.IQ, nodeHost string, purge xml.XElement) {
+	n, ok := s.requireOwnedNode(iq, nodeHost, purge.Attributes().Get("node"))
+	if !ok {
+		return
+	}
+	items, err := storage.Instance().FetchPubSubItems(n.Host, n.Name)
+	if err != nil {
+		log.Error(err)
+		s.sendError(iq, xml.ErrInternalServerError)
+		return
+	}
+	for _, it := range items {
+		if err := storage.Instance().DeletePubSubItem(it.ID, n.Host, n.Name); err != nil {
+			log.Error(err)
+			s.sendError(iq, xml.ErrInternalServerError)
+			return
+		}
+	}
+	s.route(s.resultIQ(iq, nil))
+
+	p := xml.NewElementName("purge")
+	p.SetAttribute("node", n.Name)
+	s.notify(n, iq.ToJID().ToBareJID(), p)
+}
+
+func (s *service) processOwnerSubscriptions(iq *xml.IQ, nodeHost string, subscriptions xml.XElement) {
+	n, ok := s.requireOwnedNode(iq, nodeHost, subscriptions.Attributes().Get("node"))
+	if !ok {
+		return
+	}
+	if iq.IsGet() {
+		ret := xml.NewElementName("subscriptions")
+		ret.SetAttribute("node", n.Name)
+		for _, j := range sortedKeys(n.Subscriptions) {
+			sub := subscriptionElement("", j, n.Subscriptions[j])
+			ret.AppendElement(sub)
+		}
+		s.route(s.resultIQ(iq, pubSubElement(pubSubOwnerNamespace, ret)))
+		return
+	}
+	for _, sub := range subscriptions.Elements().Children("subscription") {
+		j, err := jid.NewWithString(sub.Attributes().Get("jid"), false)
+		if err != nil {
+			s.sendError(iq, xml.ErrJidMalformed)
+			return
+		}
+		switch sub.Attributes().Get("subscription") {
+		case "subscribed":
+			if len(n.Subscription(j.String())) == 0 {
+				n.SetSubscription(j.String(), uuid.New())
+			}
+		case "none":
+			n.SetSubscription(j.String(), "")
+		default:
+			s.sendError(iq, xml.ErrBadRequest)
+			return
+		}
+	}
+	if err := s.saveNode(n); err != nil {
+		log.Error(err)
+		s.sendError(iq, xml.ErrInternalServerError)
+		return
+	}
+	s.route(s.resultIQ(iq, nil))
+}
+
+func (s *service) processOwnerAffiliations(iq *xml.IQ, nodeHost string, affiliations xml.XElement) {
+	n, ok := s.requireOwnedNode(iq, nodeHost, affiliations.Attributes().Get("node"))
+	if !ok {
+		return
+	}
+	if iq.IsGet() {
+		ret := xml.NewElementName("affiliations")
+		ret.SetAttribute("node", n.Name)
+		for _, j := range sortedKeys(n.Affiliations) {
+			a := xml.NewElementName("affiliation")
+			a.SetAttribute("jid", j)
+			a.SetAttribute("affiliation", n.Affiliations[j])
+			ret.AppendElement(a)
+		}
+		s.route(s.resultIQ(iq, pubSubElement(pubSubOwnerNamespace, ret)))
+		return
+	}
+	for _, a := range affiliations.Elements().Children("affiliation") {
+		j, err := jid.NewWithString(a.Attributes().Get("jid"), false)
+		if err != nil {
+			s.sendError(iq, xml.ErrJidMalformed)
+			return
+		}
+		bareJID := j.ToBareJID().String()
+		affiliation := a.Attributes().Get("affiliation")
+		switch affiliation {
+		case pubsubmodel.AffiliationOwner, pubsubmodel.AffiliationPublisher, pubsubmodel.AffiliationMember, pubsubmodel.AffiliationNone:
+			break
+		case pubsubmodel.AffiliationOutcast:
+			// outcasts are no longer allowed to be subscribed
+			for subJID := range n.Subscriptions {
+				if sj, err := jid.NewWithString(subJID, true); err == nil && sj.ToBareJID().String() == bareJID {
+					n.SetSubscription(subJID, "")
+				}
+			}
+		default:
+			s.sendError(iq, xml.ErrBadRequest)
+			return
+		}
+		n.SetAffiliation(bareJID, affiliation)
+	}
+	if len(n.Owners()) == 0 {
+		s.sendError(iq, xml.ErrNotAcceptable) // a node must always have at least one owner
+		return
+	}
+	if err := s.saveNode(n); err != nil {
+		log.Error(err)
+		s.sendError(iq, xml.ErrInternalServerError)
+		return
+	}
+	s.route(s.resultIQ(iq, nil))
+}
+
+// requireNode fetches a node from storage, replying with
+// the appropriate error in case it cannot be retrieved.
+func (s *service) requireNode(iq *xml.IQ, nodeHost, nodeName string) (*pubsubmodel.Node, bool) {
+	if len(nodeName) == 0 {
+		s.sendErrorCondition(iq, xml.ErrBadRequest, "nodeid-required")
+		return nil, false
+	}
+	n, err := s.fetchNode(nodeHost, nodeName)
+	if err != nil {
+		log.Error(err)
+		s.sendError(iq, xml.ErrInternalServerError)
+		return nil, false
+	}
+	if n == nil {
+		s.sendError(iq, xml.ErrItemNotFound)
+		return nil, false
+	}
+	return n, true
+}
+
+func (s *service) requireOwnedNode(iq *xml.IQ, nodeHost, nodeName string) (*pubsubmodel.Node, bool) {
+	n, ok := s.requireNode(iq, nodeHost, nodeName)
+	if !ok {
+		return nil, false
+	}
+	if n.Affiliation(iq.FromJID().ToBareJID().String()) != pubsubmodel.AffiliationOwner {
+		s.sendError(iq, xml.ErrForbidden)
+		return nil, false
+	}
+	return n, true
+}
+
+// fetchNodes returns a single node if name is not empty,
+// or all nodes associated to a given host otherwise.
+func (s *service) fetchNodes(iq *xml.IQ, nodeHost, nodeName string) ([]pubsubmodel.Node, bool) {
+	if len(nodeName) > 0 {
+		n, ok := s.requireNode(iq, nodeHost, nodeName)
+		if !ok {
+			return nil, false
+		}
+		return []pubsubmodel.Node{*n}, true
+	}
+	nodes, err := storage.Instance().FetchPubSubNodes(nodeHost)
+	if err != nil {
+		log.Error(err)
+		s.sendError(iq, xml.ErrInternalServerError)
+		return nil, false
+	}
+	return nodes, true
+}
+
+func (s *service) checkAccess(iq *xml.IQ, n *pubsubmodel.Node) bool {
+	stanzaErr, condition, err := s.accessError(n, iq.FromJID())
+	if err != nil {
+		log.Error(err)
+		s.sendError(iq, xml.ErrInternalServerError)
+		return false
+	}
+	if stanzaErr != nil {
+		s.sendErrorCondition(iq, stanzaErr, condition)
+		return false
+	}
+	return true
+}
+
+func (s *service) resultIQ(iq *xml.IQ, child xml.XElement) *xml.IQ {
+	result := xml.NewIQType(iq.ID(), xml.ResultType)
+	result.SetFromJID(iq.ToJID())
+	result.SetToJID(iq.FromJID())
+	if child != nil {
+		result.AppendElement(child)
+	}
+	return result
+}
+
+func configForm(opts pubsubmodel.Options) *xep0004.DataForm {
+	m := opts.Map()
+	field := func(fieldVar, typ, label string) xep0004.Field {
+		return xep0004.Field{Var: fieldVar, Type: typ, Label: label, Values: []string{m[fieldVar]}}
+	}
+	listField := func(fieldVar, label string, values ...string) xep0004.Field {
+		f := field(fieldVar, xep0004.ListSingle, label)
+		for _, v := range values {
+			f.Options = append(f.Options, xep0004.Option{Value: v})
+		}
+		return f
+	}
+	rosterGroups := xep0004.Field{
+		Var:    "pubsub#roster_groups_allowed",
+		Type:   xep0004.ListMulti,
+		Label:  "Roster groups allowed to subscribe",
+		Values: opts.RosterGroupsAllowed,
+	}
+	return &xep0004.DataForm{
+		Type: xep0004.Form,
+		Fields: xep0004.Fields{
+			{Var: xep0004.FormTypeFieldVar, Type: xep0004.Hidden, Values: []string{nodeConfigFormType}},
+			field("pubsub#title", xep0004.TextSingle, "A friendly name for the node"),
+			field("pubsub#deliver_notifications", xep0004.Boolean, "Whether to deliver event notifications"),
+			field("pubsub#deliver_payloads", xep0004.Boolean, "Whether to deliver payloads with event notifications"),
+			field("pubsub#notify_config", xep0004.Boolean, "Notify subscribers when the node configuration changes"),
+			field("pubsub#notify_delete", xep0004.Boolean, "Notify subscribers when the node is deleted"),
+			field("pubsub#notify_retract", xep0004.Boolean, "Notify subscribers when items are removed from the node"),
+			field("pubsub#persist_items", xep0004.Boolean, "Persist items to storage"),
+			field("pubsub#max_items", xep0004.TextSingle, "Max # of items to persist"),
+			listField("pubsub#access_model", "Specify the subscriber model",
+				pubsubmodel.AccessModelOpen, pubsubmodel.AccessModelPresence, pubsubmodel.AccessModelRoster, pubsubmodel.AccessModelWhitelist),
+			listField("pubsub#publish_model", "Specify the publisher model",
+				pubsubmodel.PublishModelPublishers, pubsubmodel.PublishModelSubscribers, pubsubmodel.PublishModelOpen),
+			rosterGroups,
+			listField("pubsub#send_last_published_item", "When to send the last published item",
+				pubsubmodel.SendLastPublishedItemNever, pubsubmodel.SendLastPublishedItemOnSub, pubsubmodel.SendLastPublishedItemOnSubAndPresence),
+			listField("pubsub#notification_type", "Specify the delivery style for event notifications", "normal", "headline"),
+		},
+	}
+}
+
+// applyConfigForm applies a submitted node configuration
+// or publish options form.
+// An empty form is accepted leaving options untouched.
+func applyConfigForm(opts *pubsubmodel.Options, form *xep0004.DataForm, formType string) bool {
+	if len(form.Fields) == 0 {
+		return true
+	}
+	if form.FormType() != formType {
+		return false
+	}
+	m := make(map[string]string)
+	var rosterGroups []string
+	var hasRosterGroups bool
+	for _, field := range form.Fields {
+		switch field.Var {
+		case xep0004.FormTypeFieldVar:
+			continue
+		case "pubsub#roster_groups_allowed":
+			rosterGroups, hasRosterGroups = field.Values, true
+			continue
+		}
+		var value string
+		if len(field.Values) > 0 {
+			value = field.Values[0]
+		}
+		m[field.Var] = value
+	}
+	c := *opts
+	if err := c.SetMap(m); err != nil {
+		return false
+	}
+	if hasRosterGroups {
+		c.RosterGroupsAllowed = rosterGroups
+	}
+	*opts = c
+	return true
+}
+
+func pubSubElement(namespace string, child xml.XElement) xml.XElement {
+	ps := xml.NewElementNamespace("pubsub", namespace)
+	ps.AppendElement(child)
+	return ps
+}
+
+func subscriptionElement(nodeName, j, subID string) xml.XElement {
+	sub := xml.NewElementName("subscription")
+	if len(nodeName) > 0 {
+		sub.SetAttribute("node", nodeName)
+	}
+	sub.SetAttribute("jid", j)
+	sub.SetAttribute("subid", subID)
+	sub.SetAttribute("subscription", "subscribed")
+	return sub
+}
+
+func identityElement(category, typ, name string) xml.XElement {
+	identity := xml.NewElementName("identity")
+	identity.SetAttribute("category", category)
+	identity.SetAttribute("type", typ)
+	if len(name) > 0 {
+		identity.SetAttribute("name", name)
+	}
+	return identity
+}
+
+func featureElement(feature string) xml.XElement {
+	f := xml.NewElementName("feature")
+	f.SetAttribute("var", feature)
+	return f
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
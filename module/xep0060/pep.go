@@ -0,0 +1,143 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0060
+
+import (
+	"sync"
+
+	"github.com/ortuman/jackal/module"
+	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+)
+
+var pepFeatures = []string{
+	"auto-create",
+	"auto-subscribe",
+	"filtered-notifications",
+	"last-published",
+	"publish",
+	"publish-options",
+	"access-presence",
+}
+
+func init() {
+	module.Register("pep", func(_ string, _ *module.Config) (module.Module, error) {
+		return NewPEP(), nil
+	})
+}
+
+// PEP represents a personal eventing (XEP-0163) server module.
+type PEP struct {
+	s    *service
+	caps *entityCaps
+
+	mu        sync.Mutex
+	delivered map[string]struct{} // indexed by stream identifier
+}
+
+// NewPEP returns a personal eventing IQ handler module.
+func NewPEP() *PEP {
+	caps := newEntityCaps()
+	return &PEP{
+		s:         newPEPService(caps),
+		caps:      caps,
+		delivered: make(map[string]struct{}),
+	}
+}
+
+// RegisterDisco registers disco entity features/items
+// associated to personal eventing module.
+func (x *PEP) RegisterDisco(discoInfo *xep0030.DiscoInfo) {
+	account := discoInfo.AccountEntity()
+	account.AddIdentity(xep0030.Identity{Category: "pubsub", Type: "pep"})
+	for _, feature := range pepFeatures {
+		account.AddFeature(pubSubNamespace + "#" + feature)
+	}
+}
+
+// MatchesIQ returns whether or not an IQ should be
+// processed by the personal eventing module.
+func (x *PEP) MatchesIQ(iq *xml.IQ) bool {
+	if x.caps.isFeaturesResponse(iq) {
+		return true
+	}
+	if !iq.ToJID().IsBare() {
+		return false
+	}
+	elems := iq.Elements()
+	return elems.ChildNamespace("pubsub", pubSubNamespace) != nil || elems.ChildNamespace("pubsub", pubSubOwnerNamespace) != nil
+}
+
+// ProcessIQ processes a personal eventing IQ taking according actions
+// over the associated stream.
+func (x *PEP) ProcessIQ(iq *xml.IQ, stm stream.C2S) {
+	if x.caps.isFeaturesResponse(iq) {
+		if stm := x.caps.processFeaturesResponse(iq); stm != nil {
+			x.sendLastItems(stm)
+		}
+		return
+	}
+	x.s.processStanza(iq)
+}
+
+// ProcessPresence delivers last published items of interest whenever
+// a local resource becomes available announcing its capabilities.
+func (x *PEP) ProcessPresence(presence *xml.Presence, stm stream.C2S) {
+	if !presence.ToJID().IsBare() || !stm.JID().Matches(presence.ToJID(), jid.MatchesBare) {
+		return // not a broadcasted presence
+	}
+	switch {
+	case presence.IsAvailable():
+		if len(capsVer(presence)) == 0 {
+			return
+		}
+		if !x.caps.isResolved(presence) {
+			x.caps.requestFeatures(presence, stm)
+			return
+		}
+		x.sendLastItems(stm)
+
+	case presence.IsUnavailable():
+		x.forget(stm)
+	}
+}
+
+// StreamStarted satisfies stream handler interface.
+func (x *PEP) StreamStarted(_ stream.C2S) {}
+
+// StreamClosed discards every state associated to a closed stream.
+func (x *PEP) StreamClosed(stm stream.C2S) {
+	x.forget(stm)
+}
+
+// Shutdown shuts down personal eventing module.
+func (x *PEP) Shutdown() {
+	x.s.shutdown()
+}
+
+// sendLastItems delivers last published items only once
+// per available stream.
+func (x *PEP) sendLastItems(stm stream.C2S) {
+	x.mu.Lock()
+	_, ok := x.delivered[stm.ID()]
+	x.delivered[stm.ID()] = struct{}{}
+	x.mu.Unlock()
+	if ok {
+		return
+	}
+	x.s.actorCh <- func() {
+		x.s.sendPEPLastItems(stm)
+	}
+}
+
+func (x *PEP) forget(stm stream.C2S) {
+	x.mu.Lock()
+	delete(x.delivered, stm.ID())
+	x.mu.Unlock()
+	x.caps.removeRequests(stm)
+}
@@ -0,0 +1,266 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0060
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/model/pubsubmodel"
+	"github.com/ortuman/jackal/model/rostermodel"
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	avatarNode     = "urn:xmpp:avatar:metadata"
+	deviceListNode = "eu.siacs.conversations.axolotl.devicelist"
+)
+
+type fakeS2SOut struct {
+	elems []xml.XElement
+}
+
+func (f *fakeS2SOut) ID() string                    { return uuid.New() }
+func (f *fakeS2SOut) SendElement(elem xml.XElement) { f.elems = append(f.elems, elem) }
+func (f *fakeS2SOut) Disconnect(err error)          {}
+
+func TestXEP0163_Matching(t *testing.T) {
+	x := NewPEP()
+	defer x.Shutdown()
+
+	j := tUtilJID("ortuman@jackal.im/balcony")
+
+	iq := tUtilPEPPublishIQ(j, avatarNode, "i1", nil)
+	require.True(t, x.MatchesIQ(iq))
+
+	iq.SetToJID(tUtilJID("jackal.im"))
+	require.False(t, x.MatchesIQ(iq))
+
+	iq = xml.NewIQType(uuid.New(), xml.GetType)
+	iq.SetFromJID(j)
+	iq.SetToJID(j.ToBareJID())
+	iq.AppendElement(xml.NewElementNamespace("query", discoInfoNamespace))
+	require.False(t, x.MatchesIQ(iq))
+}
+
+func TestXEP0163_PublishAndNotify(t *testing.T) {
+	shutdown := tUtilPubSubInitialize()
+	defer shutdown()
+
+	x := NewPEP()
+	defer x.Shutdown()
+
+	j1 := tUtilJID("ortuman@jackal.im/balcony")
+	j2 := tUtilJID("noelia@jackal.im/garden")
+	stm1 := tUtilStreamBind(j1)
+	stm2 := tUtilStreamBind(j2)
+
+	tUtilPEPRoster("ortuman", "noelia@jackal.im", rostermodel.SubscriptionFrom)
+	tUtilPEPRoster("noelia", "ortuman@jackal.im", rostermodel.SubscriptionTo)
+
+	tUtilPEPAvailable(x, stm2, []string{avatarNode + "+notify"})
+
+	// auto-create node
+	iq := tUtilPEPPublishIQ(j1, avatarNode, "i1", nil)
+	require.True(t, x.MatchesIQ(iq))
+	x.ProcessIQ(iq, stm1)
+	elem := stm1.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	elem = stm2.FetchElement()
+	require.Equal(t, "message", elem.Name())
+	require.Equal(t, "ortuman@jackal.im", elem.From())
+	require.Equal(t, j2.String(), elem.To())
+	items := elem.Elements().ChildNamespace("event", pubSubEventNamespace).Elements().Child("items")
+	require.Equal(t, avatarNode, items.Attributes().Get("node"))
+	require.Equal(t, "i1", items.Elements().Child("item").Attributes().Get("id"))
+
+	n, _ := storage.Instance().FetchPubSubNode("ortuman@jackal.im", avatarNode)
+	require.NotNil(t, n)
+	require.Equal(t, pubsubmodel.AccessModelPresence, n.Options.AccessModel)
+	require.Equal(t, pubsubmodel.AffiliationOwner, n.Affiliation("ortuman@jackal.im"))
+
+	// only owner is allowed to publish
+	iq = tUtilPEPPublishIQ(j2, avatarNode, "i2", nil)
+	iq.SetToJID(tUtilJID("ortuman@jackal.im"))
+	x.ProcessIQ(iq, stm2)
+	elem = stm2.FetchElement()
+	require.Equal(t, xml.ErrorType, elem.Type())
+	require.NotNil(t, elem.Elements().Child("error").Elements().Child("forbidden"))
+
+	iq = tUtilPEPPublishIQ(j2, "urn:xmpp:tune", "i1", nil)
+	iq.SetToJID(tUtilJID("ortuman@jackal.im"))
+	x.ProcessIQ(iq, stm2)
+	elem = stm2.FetchElement()
+	require.Equal(t, xml.ErrorType, elem.Type())
+	require.NotNil(t, elem.Elements().Child("error").Elements().Child("item-not-found"))
+
+	// contact retrieves items
+	itemsEl := xml.NewElementName("items")
+	itemsEl.SetAttribute("node", avatarNode)
+	iq = tUtilPubSubIQ(j2, xml.GetType, pubSubNamespace, itemsEl)
+	iq.SetToJID(tUtilJID("ortuman@jackal.im"))
+	x.ProcessIQ(iq, stm2)
+	elem = stm2.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+	require.Equal(t, 1, len(elem.Elements().ChildNamespace("pubsub", pubSubNamespace).Elements().Child("items").Elements().Children("item")))
+
+	// non-contact is not authorized
+	j3 := tUtilJID("romeo@jackal.im/orchard")
+	stm3 := tUtilStreamBind(j3)
+	iq.SetFromJID(j3)
+	x.ProcessIQ(iq, stm3)
+	elem = stm3.FetchElement()
+	require.Equal(t, xml.ErrorType, elem.Type())
+	require.NotNil(t, elem.Elements().Child("error").Elements().ChildNamespace("presence-subscription-required", pubSubErrorsNamespace))
+
+	// last published item on available presence
+	stm4 := tUtilStreamBind(tUtilJID("noelia@jackal.im/hall"))
+	tUtilPEPAvailable(x, stm4, []string{avatarNode + "+notify"})
+	elem = stm4.FetchElement()
+	require.Equal(t, "message", elem.Name())
+	items = elem.Elements().ChildNamespace("event", pubSubEventNamespace).Elements().Child("items")
+	require.Equal(t, "i1", items.Elements().Child("item").Attributes().Get("id"))
+}
+
+func TestXEP0163_NotifyRemoteContacts(t *testing.T) {
+	shutdown := tUtilPubSubInitialize()
+	defer shutdown()
+
+	remote := &fakeS2SOut{}
+	router.Shutdown()
+	router.Initialize(&router.Config{
+		GetS2SOut: func(_, _ string) (stream.S2SOut, error) { return remote, nil },
+	})
+
+	x := NewPEP()
+	defer x.Shutdown()
+
+	j1 := tUtilJID("ortuman@jackal.im/balcony")
+	stm1 := tUtilStreamBind(j1)
+
+	tUtilPEPRoster("ortuman", "juliet@example.org", rostermodel.SubscriptionFrom)
+	tUtilPEPRoster("ortuman", "romeo@example.org", rostermodel.SubscriptionTo)
+
+	x.ProcessIQ(tUtilPEPPublishIQ(j1, avatarNode, "i1", nil), stm1)
+	elem := stm1.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	// notified through contact's bare JID, as long as
+	// it's subscribed to owner's presence
+	require.Equal(t, 1, len(remote.elems))
+	elem = remote.elems[0]
+	require.Equal(t, "message", elem.Name())
+	require.Equal(t, "ortuman@jackal.im", elem.From())
+	require.Equal(t, "juliet@example.org", elem.To())
+	items := elem.Elements().ChildNamespace("event", pubSubEventNamespace).Elements().Child("items")
+	require.Equal(t, avatarNode, items.Attributes().Get("node"))
+}
+
+func TestXEP0163_PublishOptions(t *testing.T) {
+	shutdown := tUtilPubSubInitialize()
+	defer shutdown()
+
+	x := NewPEP()
+	defer x.Shutdown()
+
+	j1 := tUtilJID("ortuman@jackal.im/balcony")
+	stm1 := tUtilStreamBind(j1)
+
+	openAccess := map[string]string{"pubsub#access_model": pubsubmodel.AccessModelOpen}
+
+	x.ProcessIQ(tUtilPEPPublishIQ(j1, deviceListNode, "current", openAccess), stm1)
+	elem := stm1.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	n, _ := storage.Instance().FetchPubSubNode("ortuman@jackal.im", deviceListNode)
+	require.NotNil(t, n)
+	require.Equal(t, pubsubmodel.AccessModelOpen, n.Options.AccessModel)
+
+	// preconditions met
+	x.ProcessIQ(tUtilPEPPublishIQ(j1, deviceListNode, "current", openAccess), stm1)
+	elem = stm1.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	// preconditions not met
+	x.ProcessIQ(tUtilPEPPublishIQ(j1, avatarNode, "i1", nil), stm1)
+	stm1.FetchElement()
+
+	x.ProcessIQ(tUtilPEPPublishIQ(j1, avatarNode, "i2", openAccess), stm1)
+	elem = stm1.FetchElement()
+	require.Equal(t, xml.ErrorType, elem.Type())
+	require.NotNil(t, elem.Elements().Child("error").Elements().Child("conflict"))
+	require.NotNil(t, elem.Elements().Child("error").Elements().ChildNamespace("precondition-not-met", pubSubErrorsNamespace))
+
+	// only owner can create nodes
+	j2 := tUtilJID("noelia@jackal.im/garden")
+	stm2 := tUtilStreamBind(j2)
+	create := xml.NewElementName("create")
+	create.SetAttribute("node", "urn:xmpp:tune")
+	iq := tUtilPubSubIQ(j2, xml.SetType, pubSubNamespace, create)
+	iq.SetToJID(tUtilJID("ortuman@jackal.im"))
+	x.ProcessIQ(iq, stm2)
+	elem = stm2.FetchElement()
+	require.Equal(t, xml.ErrorType, elem.Type())
+	require.NotNil(t, elem.Elements().Child("error").Elements().Child("forbidden"))
+}
+
+func tUtilPEPAvailable(x *PEP, stm *stream.MockC2S, features []string) {
+	q := tUtilDiscoInfoQuery(features)
+	p := tUtilCapsPresence(stm.JID(), capsVerification(q))
+	stm.SetPresence(p)
+	x.ProcessPresence(p, stm)
+	if x.caps.isResolved(p) {
+		return
+	}
+	elem := stm.FetchElement() // disco info request
+	iq := xml.NewIQType(elem.ID(), xml.ResultType)
+	iq.SetFromJID(stm.JID())
+	iq.SetToJID(tUtilJID(stm.Domain()))
+	iq.AppendElement(q)
+	x.ProcessIQ(iq, stm)
+}
+
+func tUtilCapsPresence(from *jid.JID, ver string) *xml.Presence {
+	p := xml.NewPresence(from, from.ToBareJID(), xml.AvailableType)
+	c := xml.NewElementNamespace("c", capsNamespace)
+	c.SetAttribute("hash", "sha-1")
+	c.SetAttribute("node", "https://jackal.im")
+	c.SetAttribute("ver", ver)
+	p.AppendElement(c)
+	return p
+}
+
+func tUtilPEPRoster(username, contact, subscription string) {
+	storage.Instance().InsertOrUpdateRosterItem(&rostermodel.Item{
+		Username:     username,
+		Domain:       "jackal.im",
+		JID:          contact,
+		Subscription: subscription,
+	})
+}
+
+func tUtilPEPPublishIQ(from *jid.JID, node, itemID string, publishOptions map[string]string) *xml.IQ {
+	iq := tUtilPublishIQ(from, node, itemID)
+	iq.SetToJID(from.ToBareJID())
+	if publishOptions != nil {
+		form := &xep0004.DataForm{Type: xep0004.Submit}
+		form.Fields = append(form.Fields, xep0004.Field{Var: xep0004.FormTypeFieldVar, Type: xep0004.Hidden, Values: []string{publishOptionsFormType}})
+		for k, v := range publishOptions {
+			form.Fields = append(form.Fields, xep0004.Field{Var: k, Values: []string{v}})
+		}
+		po := xml.NewElementName("publish-options")
+		po.AppendElement(form.Element())
+		iq.Elements().ChildNamespace("pubsub", pubSubNamespace).(*xml.Element).AppendElement(po)
+	}
+	return iq
+}
@@ -0,0 +1,130 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0060
+
+import (
+	"sync"
+
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/module"
+	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/xml"
+)
+
+const (
+	pubSubNamespace        = "http://jabber.org/protocol/pubsub"
+	pubSubOwnerNamespace   = "http://jabber.org/protocol/pubsub#owner"
+	pubSubEventNamespace   = "http://jabber.org/protocol/pubsub#event"
+	pubSubErrorsNamespace  = "http://jabber.org/protocol/pubsub#errors"
+	nodeConfigFormType     = "http://jabber.org/protocol/pubsub#node_config"
+	publishOptionsFormType = "http://jabber.org/protocol/pubsub#publish-options"
+	discoInfoNamespace     = "http://jabber.org/protocol/disco#info"
+	discoItemsNamespace    = "http://jabber.org/protocol/disco#items"
+)
+
+const defaultService = "pubsub"
+
+// Config represents Publish-Subscribe module (XEP-0060) configuration.
+type Config struct {
+	Service string `yaml:"service"`
+}
+
+// singleton interface
+var (
+	instMu      sync.RWMutex
+	inst        *service
+	initialized bool
+)
+
+// Initialize initializes the publish-subscribe service.
+func Initialize(cfg *Config) {
+	instMu.Lock()
+	defer instMu.Unlock()
+	if initialized {
+		return
+	}
+	inst = newService(cfg)
+	initialized = true
+}
+
+// Shutdown shuts down publish-subscribe service.
+func Shutdown() {
+	instMu.Lock()
+	defer instMu.Unlock()
+	if !initialized {
+		return
+	}
+	inst.shutdown()
+	inst = nil
+	initialized = false
+}
+
+// ServiceDomain returns the publish-subscribe service domain
+// associated to a local host domain.
+func ServiceDomain(domain string) string {
+	instMu.RLock()
+	defer instMu.RUnlock()
+	if !initialized {
+		return ""
+	}
+	return inst.cfg.Service + "." + domain
+}
+
+// ProcessStanza processes a stanza addressed to the publish-subscribe service.
+func ProcessStanza(stanza xml.Stanza) {
+	instance().processStanza(stanza)
+}
+
+func instance() *service {
+	instMu.RLock()
+	defer instMu.RUnlock()
+	if inst == nil {
+		log.Fatalf("pubsub service not initialized")
+	}
+	return inst
+}
+
+func init() {
+	module.Register("pubsub", func(domain string, cfg *module.Config) (module.Module, error) {
+		var config Config
+		if err := cfg.Decode("pubsub", &config); err != nil {
+			return nil, err
+		}
+		Initialize(&config)
+		return New(domain), nil
+	})
+}
+
+// PubSub represents a publish-subscribe server module.
+type PubSub struct {
+	domain string
+}
+
+// New returns a publish-subscribe server module associated to a local host.
+func New(domain string) *PubSub {
+	return &PubSub{domain: domain}
+}
+
+// RegisterDisco registers disco entity features/items
+// associated to publish-subscribe module.
+func (x *PubSub) RegisterDisco(discoInfo *xep0030.DiscoInfo) {
+	discoInfo.ServerEntity().AddItem(xep0030.Item{Jid: ServiceDomain(x.domain)})
+}
+
+// ServiceDomain returns the domain publish-subscribe service is reachable at.
+func (x *PubSub) ServiceDomain() string {
+	return ServiceDomain(x.domain)
+}
+
+// ProcessStanza processes a stanza addressed to the publish-subscribe service.
+func (x *PubSub) ProcessStanza(stanza xml.Stanza) {
+	ProcessStanza(stanza)
+}
+
+// Shutdown shuts down publish-subscribe service.
+func (x *PubSub) Shutdown() {
+	Shutdown()
+}
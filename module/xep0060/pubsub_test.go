@@ -0,0 +1,423 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0060
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/model/pubsubmodel"
+	"github.com/ortuman/jackal/model/rostermodel"
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXEP0060_ServiceDomain(t *testing.T) {
+	require.Equal(t, "", ServiceDomain("jackal.im"))
+
+	shutdown := tUtilPubSubInitialize()
+	defer shutdown()
+
+	require.Equal(t, "pubsub.jackal.im", ServiceDomain("jackal.im"))
+	require.Equal(t, "pubsub.jackal.im", New("jackal.im").ServiceDomain())
+	require.Equal(t, "pubsub.example.org", New("example.org").ServiceDomain())
+}
+
+func TestXEP0060_DiscoInfo(t *testing.T) {
+	shutdown := tUtilPubSubInitialize()
+	defer shutdown()
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	stm1 := tUtilStreamBind(j1)
+
+	iq := xml.NewIQType(uuid.New(), xml.GetType)
+	iq.SetFromJID(j1)
+	iq.SetToJID(tUtilJID("pubsub.jackal.im"))
+	iq.AppendElement(xml.NewElementNamespace("query", discoInfoNamespace))
+	ProcessStanza(iq)
+	elem := stm1.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+	q := elem.Elements().ChildNamespace("query", discoInfoNamespace)
+	require.Equal(t, "pubsub", q.Elements().Child("identity").Attributes().Get("category"))
+	require.Equal(t, len(pubSubFeatures)+3, len(q.Elements().Children("feature")))
+
+	// unknown node
+	iq = xml.NewIQType(uuid.New(), xml.GetType)
+	iq.SetFromJID(j1)
+	iq.SetToJID(tUtilJID("pubsub.jackal.im"))
+	nodeQuery := xml.NewElementNamespace("query", discoInfoNamespace)
+	nodeQuery.SetAttribute("node", "princely_musings")
+	iq.AppendElement(nodeQuery)
+	ProcessStanza(iq)
+	elem = stm1.FetchElement()
+	require.Equal(t, xml.ErrorType, elem.Type())
+	require.NotNil(t, elem.Elements().Child("error").Elements().Child("item-not-found"))
+}
+
+func TestXEP0060_CreateAndPublish(t *testing.T) {
+	shutdown := tUtilPubSubInitialize()
+	defer shutdown()
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "garden", true)
+	stm1 := tUtilStreamBind(j1)
+	stm2 := tUtilStreamBind(j2)
+
+	tUtilCreateNode(stm1, "princely_musings", nil)
+
+	// node already exists
+	create := xml.NewElementName("create")
+	create.SetAttribute("node", "princely_musings")
+	ProcessStanza(tUtilPubSubIQ(j1, xml.SetType, pubSubNamespace, create))
+	elem := stm1.FetchElement()
+	require.Equal(t, xml.ErrorType, elem.Type())
+	require.NotNil(t, elem.Elements().Child("error").Elements().Child("conflict"))
+
+	// instant node
+	ProcessStanza(tUtilPubSubIQ(j1, xml.SetType, pubSubNamespace, xml.NewElementName("create")))
+	elem = stm1.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+	instantNode := elem.Elements().ChildNamespace("pubsub", pubSubNamespace).Elements().Child("create").Attributes().Get("node")
+	require.True(t, len(instantNode) > 0)
+
+	// subscribe
+	ProcessStanza(tUtilSubscribeIQ(j2, "princely_musings"))
+	elem = stm2.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+	sub := elem.Elements().ChildNamespace("pubsub", pubSubNamespace).Elements().Child("subscription")
+	require.Equal(t, "subscribed", sub.Attributes().Get("subscription"))
+	require.Equal(t, j2.String(), sub.Attributes().Get("jid"))
+
+	// only publishers are allowed to publish
+	ProcessStanza(tUtilPublishIQ(j2, "princely_musings", "i1"))
+	elem = stm2.FetchElement()
+	require.Equal(t, xml.ErrorType, elem.Type())
+	require.NotNil(t, elem.Elements().Child("error").Elements().Child("forbidden"))
+
+	ProcessStanza(tUtilPublishIQ(j1, "princely_musings", "i1"))
+	elem = stm1.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+	require.Equal(t, "i1", elem.Elements().ChildNamespace("pubsub", pubSubNamespace).Elements().Child("publish").Elements().Child("item").Attributes().Get("id"))
+
+	// notification
+	elem = stm2.FetchElement()
+	require.Equal(t, "message", elem.Name())
+	require.Equal(t, "pubsub.jackal.im", elem.From())
+	items := elem.Elements().ChildNamespace("event", pubSubEventNamespace).Elements().Child("items")
+	require.Equal(t, "princely_musings", items.Attributes().Get("node"))
+	require.NotNil(t, items.Elements().Child("item").Elements().Child("entry"))
+
+	// retrieve items
+	itemsEl := xml.NewElementName("items")
+	itemsEl.SetAttribute("node", "princely_musings")
+	ProcessStanza(tUtilPubSubIQ(j2, xml.GetType, pubSubNamespace, itemsEl))
+	elem = stm2.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+	require.Equal(t, 1, len(elem.Elements().ChildNamespace("pubsub", pubSubNamespace).Elements().Child("items").Elements().Children("item")))
+
+	// retract
+	retract := xml.NewElementName("retract")
+	retract.SetAttribute("node", "princely_musings")
+	it := xml.NewElementName("item")
+	it.SetAttribute("id", "i1")
+	retract.AppendElement(it)
+	ProcessStanza(tUtilPubSubIQ(j1, xml.SetType, pubSubNamespace, retract))
+	elem = stm1.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	elem = stm2.FetchElement()
+	items = elem.Elements().ChildNamespace("event", pubSubEventNamespace).Elements().Child("items")
+	require.Equal(t, "i1", items.Elements().Child("retract").Attributes().Get("id"))
+
+	storedItems, _ := storage.Instance().FetchPubSubItems("pubsub.jackal.im", "princely_musings")
+	require.Equal(t, 0, len(storedItems))
+
+	// unsubscribe
+	unsubscribe := xml.NewElementName("unsubscribe")
+	unsubscribe.SetAttribute("node", "princely_musings")
+	unsubscribe.SetAttribute("jid", j2.String())
+	ProcessStanza(tUtilPubSubIQ(j2, xml.SetType, pubSubNamespace, unsubscribe))
+	elem = stm2.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	ProcessStanza(tUtilPubSubIQ(j2, xml.SetType, pubSubNamespace, unsubscribe))
+	elem = stm2.FetchElement()
+	require.Equal(t, xml.ErrorType, elem.Type())
+	require.NotNil(t, elem.Elements().Child("error").Elements().ChildNamespace("not-subscribed", pubSubErrorsNamespace))
+}
+
+func TestXEP0060_LastPublishedItem(t *testing.T) {
+	shutdown := tUtilPubSubInitialize()
+	defer shutdown()
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "garden", true)
+	stm1 := tUtilStreamBind(j1)
+	stm2 := tUtilStreamBind(j2)
+
+	tUtilCreateNode(stm1, "princely_musings", map[string]string{
+		"pubsub#send_last_published_item": pubsubmodel.SendLastPublishedItemOnSubAndPresence,
+	})
+	ProcessStanza(tUtilPublishIQ(j1, "princely_musings", "i1"))
+	stm1.FetchElement()
+	ProcessStanza(tUtilPublishIQ(j1, "princely_musings", "i2"))
+	stm1.FetchElement()
+
+	ProcessStanza(tUtilSubscribeIQ(j2, "princely_musings"))
+	elem := stm2.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	elem = stm2.FetchElement()
+	items := elem.Elements().ChildNamespace("event", pubSubEventNamespace).Elements().Child("items")
+	require.Equal(t, "i2", items.Elements().Child("item").Attributes().Get("id"))
+
+	// available presence
+	ProcessStanza(xml.NewPresence(j2, tUtilJID("pubsub.jackal.im"), xml.AvailableType))
+	elem = stm2.FetchElement()
+	items = elem.Elements().ChildNamespace("event", pubSubEventNamespace).Elements().Child("items")
+	require.Equal(t, "i2", items.Elements().Child("item").Attributes().Get("id"))
+}
+
+func TestXEP0060_AccessModels(t *testing.T) {
+	shutdown := tUtilPubSubInitialize()
+	defer shutdown()
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "garden", true)
+	stm1 := tUtilStreamBind(j1)
+	stm2 := tUtilStreamBind(j2)
+
+	// presence
+	tUtilCreateNode(stm1, "presence_node", map[string]string{"pubsub#access_model": pubsubmodel.AccessModelPresence})
+
+	ProcessStanza(tUtilSubscribeIQ(j2, "presence_node"))
+	elem := stm2.FetchElement()
+	require.Equal(t, xml.ErrorType, elem.Type())
+	require.NotNil(t, elem.Elements().Child("error").Elements().Child("not-authorized"))
+	require.NotNil(t, elem.Elements().Child("error").Elements().ChildNamespace("presence-subscription-required", pubSubErrorsNamespace))
+
+	storage.Instance().InsertOrUpdateRosterItem(&rostermodel.Item{
+		Username:     "ortuman",
+		Domain:       "jackal.im",
+		JID:          "noelia@jackal.im",
+		Subscription: rostermodel.SubscriptionFrom,
+		Groups:       []string{"family"},
+	})
+	ProcessStanza(tUtilSubscribeIQ(j2, "presence_node"))
+	elem = stm2.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	// roster
+	tUtilCreateNode(stm1, "roster_node", map[string]string{"pubsub#access_model": pubsubmodel.AccessModelRoster})
+
+	ProcessStanza(tUtilSubscribeIQ(j2, "roster_node"))
+	elem = stm2.FetchElement()
+	require.Equal(t, xml.ErrorType, elem.Type())
+	require.NotNil(t, elem.Elements().Child("error").Elements().ChildNamespace("not-in-roster-group", pubSubErrorsNamespace))
+
+	n, _ := storage.Instance().FetchPubSubNode("pubsub.jackal.im", "roster_node")
+	n.Options.RosterGroupsAllowed = []string{"family"}
+	storage.Instance().InsertOrUpdatePubSubNode(n)
+
+	ProcessStanza(tUtilSubscribeIQ(j2, "roster_node"))
+	elem = stm2.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	// whitelist
+	tUtilCreateNode(stm1, "whitelist_node", map[string]string{"pubsub#access_model": pubsubmodel.AccessModelWhitelist})
+
+	ProcessStanza(tUtilSubscribeIQ(j2, "whitelist_node"))
+	elem = stm2.FetchElement()
+	require.Equal(t, xml.ErrorType, elem.Type())
+	require.NotNil(t, elem.Elements().Child("error").Elements().ChildNamespace("closed-node", pubSubErrorsNamespace))
+
+	affiliations := xml.NewElementName("affiliations")
+	affiliations.SetAttribute("node", "whitelist_node")
+	aff := xml.NewElementName("affiliation")
+	aff.SetAttribute("jid", "noelia@jackal.im")
+	aff.SetAttribute("affiliation", pubsubmodel.AffiliationMember)
+	affiliations.AppendElement(aff)
+
+	// only owners can modify affiliations
+	ProcessStanza(tUtilPubSubIQ(j2, xml.SetType, pubSubOwnerNamespace, affiliations))
+	elem = stm2.FetchElement()
+	require.Equal(t, xml.ErrorType, elem.Type())
+	require.NotNil(t, elem.Elements().Child("error").Elements().Child("forbidden"))
+
+	ProcessStanza(tUtilPubSubIQ(j1, xml.SetType, pubSubOwnerNamespace, affiliations))
+	elem = stm1.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	ProcessStanza(tUtilSubscribeIQ(j2, "whitelist_node"))
+	elem = stm2.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	// outcast
+	aff.SetAttribute("affiliation", pubsubmodel.AffiliationOutcast)
+	ProcessStanza(tUtilPubSubIQ(j1, xml.SetType, pubSubOwnerNamespace, affiliations))
+	elem = stm1.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	n, _ = storage.Instance().FetchPubSubNode("pubsub.jackal.im", "whitelist_node")
+	require.Equal(t, 0, len(n.Subscriptions))
+
+	// a node must always have an owner
+	aff.SetAttribute("jid", "ortuman@jackal.im")
+	aff.SetAttribute("affiliation", pubsubmodel.AffiliationNone)
+	ProcessStanza(tUtilPubSubIQ(j1, xml.SetType, pubSubOwnerNamespace, affiliations))
+	elem = stm1.FetchElement()
+	require.Equal(t, xml.ErrorType, elem.Type())
+	require.NotNil(t, elem.Elements().Child("error").Elements().Child("not-acceptable"))
+}
+
+func TestXEP0060_ConfigureAndDelete(t *testing.T) {
+	shutdown := tUtilPubSubInitialize()
+	defer shutdown()
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "garden", true)
+	stm1 := tUtilStreamBind(j1)
+	stm2 := tUtilStreamBind(j2)
+
+	tUtilCreateNode(stm1, "princely_musings", nil)
+	ProcessStanza(tUtilSubscribeIQ(j2, "princely_musings"))
+	stm2.FetchElement()
+
+	// get configuration
+	configure := xml.NewElementName("configure")
+	configure.SetAttribute("node", "princely_musings")
+	ProcessStanza(tUtilPubSubIQ(j1, xml.GetType, pubSubOwnerNamespace, configure))
+	elem := stm1.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+	x := elem.Elements().ChildNamespace("pubsub", pubSubOwnerNamespace).Elements().Child("configure").Elements().ChildNamespace("x", xep0004.FormNamespace)
+	form, err := xep0004.NewFormFromElement(x)
+	require.Nil(t, err)
+	require.Equal(t, nodeConfigFormType, form.FormType())
+	require.Equal(t, "10", form.Fields.ValueForField("pubsub#max_items"))
+
+	// set configuration
+	configure.AppendElement(tUtilConfigForm(map[string]string{
+		"pubsub#title":         "Princely Musings",
+		"pubsub#max_items":     "5",
+		"pubsub#notify_config": "1",
+	}).Element())
+	ProcessStanza(tUtilPubSubIQ(j1, xml.SetType, pubSubOwnerNamespace, configure))
+	elem = stm1.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	elem = stm2.FetchElement()
+	require.NotNil(t, elem.Elements().ChildNamespace("event", pubSubEventNamespace).Elements().Child("configuration"))
+
+	n, _ := storage.Instance().FetchPubSubNode("pubsub.jackal.im", "princely_musings")
+	require.Equal(t, "Princely Musings", n.Options.Title)
+	require.Equal(t, 5, n.Options.MaxItems)
+
+	// invalid configuration
+	configure = xml.NewElementName("configure")
+	configure.SetAttribute("node", "princely_musings")
+	configure.AppendElement(tUtilConfigForm(map[string]string{"pubsub#access_model": "everyone"}).Element())
+	ProcessStanza(tUtilPubSubIQ(j1, xml.SetType, pubSubOwnerNamespace, configure))
+	elem = stm1.FetchElement()
+	require.Equal(t, xml.ErrorType, elem.Type())
+	require.NotNil(t, elem.Elements().Child("error").Elements().Child("not-acceptable"))
+
+	// delete node
+	del := xml.NewElementName("delete")
+	del.SetAttribute("node", "princely_musings")
+	ProcessStanza(tUtilPubSubIQ(j2, xml.SetType, pubSubOwnerNamespace, del))
+	elem = stm2.FetchElement()
+	require.Equal(t, xml.ErrorType, elem.Type())
+	require.NotNil(t, elem.Elements().Child("error").Elements().Child("forbidden"))
+
+	ProcessStanza(tUtilPubSubIQ(j1, xml.SetType, pubSubOwnerNamespace, del))
+	elem = stm1.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	elem = stm2.FetchElement()
+	require.NotNil(t, elem.Elements().ChildNamespace("event", pubSubEventNamespace).Elements().Child("delete"))
+
+	n, _ = storage.Instance().FetchPubSubNode("pubsub.jackal.im", "princely_musings")
+	require.Nil(t, n)
+}
+
+func tUtilPubSubInitialize() func() {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	router.Initialize(&router.Config{})
+	Initialize(&Config{})
+	return func() {
+		Shutdown()
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}
+}
+
+func tUtilStreamBind(j *jid.JID) *stream.MockC2S {
+	stm := stream.NewMockC2S(uuid.New(), j)
+	router.Bind(stm)
+	return stm
+}
+
+func tUtilCreateNode(stm *stream.MockC2S, node string, options map[string]string) {
+	create := xml.NewElementName("create")
+	create.SetAttribute("node", node)
+	iq := tUtilPubSubIQ(stm.JID(), xml.SetType, pubSubNamespace, create)
+	if options != nil {
+		configure := xml.NewElementName("configure")
+		configure.AppendElement(tUtilConfigForm(options).Element())
+		iq.Elements().ChildNamespace("pubsub", pubSubNamespace).(*xml.Element).AppendElement(configure)
+	}
+	ProcessStanza(iq)
+	stm.FetchElement() // result
+}
+
+func tUtilConfigForm(options map[string]string) *xep0004.DataForm {
+	form := &xep0004.DataForm{Type: xep0004.Submit}
+	form.Fields = append(form.Fields, xep0004.Field{Var: xep0004.FormTypeFieldVar, Type: xep0004.Hidden, Values: []string{nodeConfigFormType}})
+	for k, v := range options {
+		form.Fields = append(form.Fields, xep0004.Field{Var: k, Values: []string{v}})
+	}
+	return form
+}
+
+func tUtilPubSubIQ(from *jid.JID, typ, namespace string, child xml.XElement) *xml.IQ {
+	iq := xml.NewIQType(uuid.New(), typ)
+	iq.SetFromJID(from)
+	iq.SetToJID(tUtilJID("pubsub.jackal.im"))
+	ps := xml.NewElementNamespace("pubsub", namespace)
+	ps.AppendElement(child)
+	iq.AppendElement(ps)
+	return iq
+}
+
+func tUtilSubscribeIQ(from *jid.JID, node string) *xml.IQ {
+	subscribe := xml.NewElementName("subscribe")
+	subscribe.SetAttribute("node", node)
+	subscribe.SetAttribute("jid", from.String())
+	return tUtilPubSubIQ(from, xml.SetType, pubSubNamespace, subscribe)
+}
+
+func tUtilPublishIQ(from *jid.JID, node, itemID string) *xml.IQ {
+	publish := xml.NewElementName("publish")
+	publish.SetAttribute("node", node)
+	item := xml.NewElementName("item")
+	item.SetAttribute("id", itemID)
+	item.AppendElement(xml.NewElementNamespace("entry", "http://www.w3.org/2005/Atom"))
+	publish.AppendElement(item)
+	return tUtilPubSubIQ(from, xml.SetType, pubSubNamespace, publish)
+}
+
+func tUtilJID(s string) *jid.JID {
+	j, _ := jid.NewWithString(s, true)
+	return j
+}
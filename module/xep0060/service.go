@@ -0,0 +1,407 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0060
+
+import (
+	"sort"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/model/pubsubmodel"
+	"github.com/ortuman/jackal/model/rostermodel"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+	"github.com/pborman/uuid"
+)
+
+type service struct {
+	cfg     *Config
+	pep     bool
+	caps    *entityCaps
+	actorCh chan func()
+	doneCh  chan chan struct{}
+}
+
+func newService(config *Config) *service {
+	cfg := *config
+	if len(cfg.Service) == 0 {
+		cfg.Service = defaultService
+	}
+	s := &service{
+		cfg:     &cfg,
+		actorCh: make(chan func(), 256),
+		doneCh:  make(chan chan struct{}),
+	}
+	go s.loop()
+	return s
+}
+
+// newPEPService returns a service instance hosting nodes
+// on behalf of local accounts (XEP-0163).
+func newPEPService(caps *entityCaps) *service {
+	s := &service{
+		cfg:     &Config{},
+		pep:     true,
+		caps:    caps,
+		actorCh: make(chan func(), 256),
+		doneCh:  make(chan chan struct{}),
+	}
+	go s.loop()
+	return s
+}
+
+func (s *service) processStanza(stanza xml.Stanza) {
+	s.actorCh <- func() {
+		switch stanza := stanza.(type) {
+		case *xml.Presence:
+			s.processPresence(stanza)
+		case *xml.IQ:
+			s.processIQ(stanza)
+		}
+	}
+}
+
+func (s *service) shutdown() {
+	ch := make(chan struct{})
+	s.doneCh <- ch
+	<-ch
+}
+
+// runs on it's own goroutine
+func (s *service) loop() {
+	for {
+		select {
+		case f := <-s.actorCh:
+			f()
+		case ch := <-s.doneCh:
+			close(ch)
+			return
+		}
+	}
+}
+
+// processPresence delivers last published items of every 'on_sub_and_presence'
+// node to a subscriber becoming available.
+func (s *service) processPresence(presence *xml.Presence) {
+	if !presence.IsAvailable() || !presence.ToJID().IsServer() {
+		return
+	}
+	fromJID := presence.FromJID()
+	nodes, err := storage.Instance().FetchPubSubNodes(presence.ToJID().Domain())
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	for i := range nodes {
+		n := &nodes[i]
+		if n.Options.SendLastPublishedItem != pubsubmodel.SendLastPublishedItemOnSubAndPresence {
+			continue
+		}
+		if len(n.Subscription(fromJID.String())) == 0 && len(n.Subscription(fromJID.ToBareJID().String())) == 0 {
+			continue
+		}
+		s.sendLastPublishedItem(n, fromJID)
+	}
+}
+
+func (s *service) defaultOptions() pubsubmodel.Options {
+	if s.pep {
+		return pubsubmodel.DefaultPEPOptions()
+	}
+	return pubsubmodel.DefaultOptions()
+}
+
+func (s *service) fetchNode(host, name string) (*pubsubmodel.Node, error) {
+	return storage.Instance().FetchPubSubNode(host, name)
+}
+
+func (s *service) saveNode(n *pubsubmodel.Node) error {
+	return storage.Instance().InsertOrUpdatePubSubNode(n)
+}
+
+// accessError returns the error to be reported whenever an entity
+// is not allowed to access a node, or nil if access is granted.
+func (s *service) accessError(n *pubsubmodel.Node, j *jid.JID) (*xml.StanzaError, string, error) {
+	bareJID := j.ToBareJID().String()
+	switch n.Affiliation(bareJID) {
+	case pubsubmodel.AffiliationOwner, pubsubmodel.AffiliationPublisher, pubsubmodel.AffiliationMember:
+		return nil, "", nil
+	case pubsubmodel.AffiliationOutcast:
+		return xml.ErrForbidden, "", nil
+	}
+	switch n.Options.AccessModel {
+	case pubsubmodel.AccessModelOpen:
+		return nil, "", nil
+
+	case pubsubmodel.AccessModelPresence:
+		for _, owner := range n.Owners() {
+			ri, err := s.ownerRosterItem(owner, bareJID)
+			if err != nil {
+				return nil, "", err
+			}
+			if ri != nil && (ri.Subscription == rostermodel.SubscriptionFrom || ri.Subscription == rostermodel.SubscriptionBoth) {
+				return nil, "", nil
+			}
+		}
+		return xml.ErrNotAuthorized, "presence-subscription-required", nil
+
+	case pubsubmodel.AccessModelRoster:
+		for _, owner := range n.Owners() {
+			ri, err := s.ownerRosterItem(owner, bareJID)
+			if err != nil {
+				return nil, "", err
+			}
+			if ri != nil && hasAnyGroup(ri.Groups, n.Options.RosterGroupsAllowed) {
+				return nil, "", nil
+			}
+		}
+		return xml.ErrNotAuthorized, "not-in-roster-group", nil
+	}
+	return xml.ErrNotAllowed, "closed-node", nil
+}
+
+func (s *service) canPublish(n *pubsubmodel.Node, j *jid.JID) bool {
+	switch n.Affiliation(j.ToBareJID().String()) {
+	case pubsubmodel.AffiliationOwner, pubsubmodel.AffiliationPublisher:
+		return true
+	case pubsubmodel.AffiliationOutcast:
+		return false
+	}
+	switch n.Options.PublishModel {
+	case pubsubmodel.PublishModelOpen:
+		return true
+	case pubsubmodel.PublishModelSubscribers:
+		return len(n.Subscription(j.String())) > 0 || len(n.Subscription(j.ToBareJID().String())) > 0
+	}
+	return false
+}
+
+// ownerRosterItem returns the roster item associated to a contact
+// within a local node owner's roster.
+func (s *service) ownerRosterItem(owner, contact string) (*rostermodel.Item, error) {
+	if owner == contact {
+		return &rostermodel.Item{JID: contact, Subscription: rostermodel.SubscriptionBoth}, nil
+	}
+	ownerJID, err := jid.NewWithString(owner, true)
+	if err != nil || !host.IsLocalHost(ownerJID.Domain()) {
+		return nil, nil
+	}
+	return storage.Instance().FetchRosterItem(ownerJID.Node(), ownerJID.Domain(), contact)
+}
+
+// notify sends an event notification to every node subscriber.
+// Personal eventing nodes notify interested contacts' resources as well.
+func (s *service) notify(n *pubsubmodel.Node, fromJID *jid.JID, event xml.XElement) {
+	if !n.Options.DeliverNotifications {
+		return
+	}
+	recipients := make(map[string]*jid.JID)
+	for subJID := range n.Subscriptions {
+		toJID, err := jid.NewWithString(subJID, true)
+		if err != nil {
+			continue
+		}
+		recipients[toJID.String()] = toJID
+	}
+	if s.pep {
+		for _, toJID := range s.interestedJIDs(n) {
+			recipients[toJID.String()] = toJID
+		}
+	}
+	for _, k := range sortedJIDKeys(recipients) {
+		s.sendEvent(n, fromJID, recipients[k], event)
+	}
+}
+
+// interestedJIDs returns every available resource of the node owner,
+// and its local contacts with a 'from' or 'both' subscription, that announced
+// '+notify' interest for the node within its entity capabilities.
+// Remote contacts capabilities are unknown, so their bare JID is returned
+// instead, leaving resources filtering up to the remote server.
+func (s *service) interestedJIDs(n *pubsubmodel.Node) []*jid.JID {
+	ownerJID, err := jid.NewWithString(n.Host, true)
+	if err != nil {
+		return nil
+	}
+	contacts := []*jid.JID{ownerJID}
+	ris, _, err := storage.Instance().FetchRosterItems(ownerJID.Node(), ownerJID.Domain())
+	if err != nil {
+		log.Error(err)
+		return nil
+	}
+	for _, ri := range ris {
+		if ri.Subscription != rostermodel.SubscriptionFrom && ri.Subscription != rostermodel.SubscriptionBoth {
+			continue
+		}
+		contactJID, err := jid.NewWithString(ri.JID, true)
+		if err != nil {
+			continue
+		}
+		contacts = append(contacts, contactJID)
+	}
+	var ret []*jid.JID
+	for _, contactJID := range contacts {
+		if stanzaErr, _, err := s.accessError(n, contactJID); stanzaErr != nil || err != nil {
+			continue
+		}
+		if !host.IsLocalHost(contactJID.Domain()) {
+			ret = append(ret, contactJID)
+			continue
+		}
+		for _, stm := range router.UserStreams(contactJID.Node(), contactJID.Domain()) {
+			if s.caps.hasFeature(stm.Presence(), n.Name+"+notify") {
+				ret = append(ret, stm.JID())
+			}
+		}
+	}
+	return ret
+}
+
+// sendPEPLastItems delivers to a resource the last published item
+// of every node it's interested in, among its own nodes and
+// the ones belonging to its local contacts.
+func (s *service) sendPEPLastItems(stm stream.C2S) {
+	hosts := []string{stm.JID().ToBareJID().String()}
+	ris, _, err := storage.Instance().FetchRosterItems(stm.Username(), stm.Domain())
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	for _, ri := range ris {
+		if ri.Subscription == rostermodel.SubscriptionTo || ri.Subscription == rostermodel.SubscriptionBoth {
+			hosts = append(hosts, ri.JID)
+		}
+	}
+	presence := stm.Presence()
+	for _, h := range hosts {
+		hostJID, err := jid.NewWithString(h, true)
+		if err != nil || !host.IsLocalHost(hostJID.Domain()) {
+			continue
+		}
+		nodes, err := storage.Instance().FetchPubSubNodes(h)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		for i := range nodes {
+			n := &nodes[i]
+			if n.Options.SendLastPublishedItem == pubsubmodel.SendLastPublishedItemNever || !s.caps.hasFeature(presence, n.Name+"+notify") {
+				continue
+			}
+			if stanzaErr, _, err := s.accessError(n, stm.JID()); stanzaErr != nil || err != nil {
+				continue
+			}
+			s.sendLastPublishedItem(n, stm.JID())
+		}
+	}
+}
+
+func (s *service) sendEvent(n *pubsubmodel.Node, fromJID, toJID *jid.JID, event xml.XElement) {
+	msg := xml.NewMessageType(uuid.New(), n.Options.NotificationType)
+	msg.SetFromJID(fromJID)
+	msg.SetToJID(toJID)
+	ev := xml.NewElementNamespace("event", pubSubEventNamespace)
+	ev.AppendElement(event)
+	msg.AppendElement(ev)
+	s.route(msg)
+}
+
+func (s *service) sendLastPublishedItem(n *pubsubmodel.Node, toJID *jid.JID) {
+	items, err := storage.Instance().FetchPubSubItems(n.Host, n.Name)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	if len(items) == 0 {
+		return
+	}
+	hostJID, _ := jid.NewWithString(n.Host, true)
+	s.sendEvent(n, hostJID, toJID, s.itemsEventElement(n, &items[len(items)-1]))
+}
+
+func (s *service) itemsEventElement(n *pubsubmodel.Node, item *pubsubmodel.Item) xml.XElement {
+	items := xml.NewElementName("items")
+	items.SetAttribute("node", n.Name)
+	items.AppendElement(itemElement(item, n.Options.DeliverPayloads))
+	return items
+}
+
+func (s *service) route(stanza xml.Stanza) {
+	switch err := router.Route(stanza); err {
+	case nil, router.ErrNotAuthenticated, router.ErrResourceNotFound, router.ErrBlockedJID:
+		break
+	default:
+		log.Error(err)
+	}
+}
+
+func (s *service) sendError(stanza xml.Stanza, stanzaErr *xml.StanzaError) {
+	s.sendErrorCondition(stanza, stanzaErr, "")
+}
+
+// sendErrorCondition replies a stanza with an error,
+// attaching a pubsub specific error condition if not empty.
+func (s *service) sendErrorCondition(stanza xml.Stanza, stanzaErr *xml.StanzaError, condition string) {
+	if stanza.Type() == xml.ErrorType {
+		return // never reply to an error stanza
+	}
+	var errElements []xml.XElement
+	if len(condition) > 0 {
+		errElements = append(errElements, xml.NewElementNamespace(condition, pubSubErrorsNamespace))
+	}
+	errElem := xml.NewErrorElementFromElement(stanza, stanzaErr, errElements)
+
+	var errStanza xml.Stanza
+	var err error
+	switch stanza.(type) {
+	case *xml.IQ:
+		errStanza, err = xml.NewIQFromElement(errElem, stanza.ToJID(), stanza.FromJID())
+	case *xml.Presence:
+		errStanza, err = xml.NewPresenceFromElement(errElem, stanza.ToJID(), stanza.FromJID())
+	case *xml.Message:
+		errStanza, err = xml.NewMessageFromElement(errElem, stanza.ToJID(), stanza.FromJID())
+	}
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	s.route(errStanza)
+}
+
+func itemElement(item *pubsubmodel.Item, withPayload bool) xml.XElement {
+	elem := xml.NewElementName("item")
+	elem.SetAttribute("id", item.ID)
+	if len(item.Publisher) > 0 {
+		elem.SetAttribute("publisher", item.Publisher)
+	}
+	if withPayload && item.Payload != nil {
+		elem.AppendElement(item.Payload)
+	}
+	return elem
+}
+
+func sortedJIDKeys(m map[string]*jid.JID) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func hasAnyGroup(groups, allowedGroups []string) bool {
+	for _, g := range groups {
+		for _, ag := range allowedGroups {
+			if g == ag {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -6,8 +6,10 @@
 package xep0077
 
 import (
+	"github.com/ortuman/jackal/auth"
 	"github.com/ortuman/jackal/log"
 	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/module"
 	"github.com/ortuman/jackal/module/xep0030"
 	"github.com/ortuman/jackal/storage"
 	"github.com/ortuman/jackal/stream"
@@ -24,26 +26,33 @@ type Config struct {
 	AllowCancel       bool `yaml:"allow_cancel"`
 }
 
-// Register represents an in-band server stream module.
+const registeredCtxKey = "register:registered"
+
+func init() {
+	module.Register("registration", func(_ string, cfg *module.Config) (module.Module, error) {
+		var config Config
+		if err := cfg.Decode("registration", &config); err != nil {
+			return nil, err
+		}
+		return New(&config), nil
+	})
+}
+
+// Register represents an in-band registration server module.
 type Register struct {
-	cfg        *Config
-	stm        stream.C2S
-	registered bool
+	cfg *Config
 }
 
 // New returns an in-band registration IQ handler.
-func New(config *Config, stm stream.C2S) *Register {
-	return &Register{
-		cfg: config,
-		stm: stm,
-	}
+func New(config *Config) *Register {
+	return &Register{cfg: config}
 }
 
 // RegisterDisco registers disco entity features/items
 // associated to register module.
 func (x *Register) RegisterDisco(discoInfo *xep0030.DiscoInfo) {
 	// register disco feature
-	discoInfo.Entity(x.stm.Domain(), "").AddFeature(registerNamespace)
+	discoInfo.ServerEntity().AddFeature(registerNamespace)
 }
 
 // MatchesIQ returns whether or not an IQ should be
@@ -54,54 +63,54 @@ func (x *Register) MatchesIQ(iq *xml.IQ) bool {
 
 // ProcessIQ processes an in-band registration IQ
 // taking according actions over the associated stream.
-func (x *Register) ProcessIQ(iq *xml.IQ) {
-	if !x.isValidToJid(iq.ToJID()) {
-		x.stm.SendElement(iq.ForbiddenError())
+func (x *Register) ProcessIQ(iq *xml.IQ, stm stream.C2S) {
+	if !x.isValidToJid(iq.ToJID(), stm) {
+		stm.SendElement(iq.ForbiddenError())
 		return
 	}
 
 	q := iq.Elements().ChildNamespace("query", registerNamespace)
-	if !x.stm.IsAuthenticated() {
+	if !stm.IsAuthenticated() {
 		if iq.IsGet() {
 			if !x.cfg.AllowRegistration {
-				x.stm.SendElement(iq.NotAllowedError())
+				stm.SendElement(iq.NotAllowedError())
 				return
 			}
 			// ...send registration fields to requester entity...
-			x.sendRegistrationFields(iq, q)
+			x.sendRegistrationFields(iq, q, stm)
 		} else if iq.IsSet() {
-			if !x.registered {
+			if !stm.Context().Bool(registeredCtxKey) {
 				// ...register a new user...
-				x.registerNewUser(iq, q)
+				x.registerNewUser(iq, q, stm)
 			} else {
 				// return a <not-acceptable/> stanza error if an entity attempts to register a second identity
-				x.stm.SendElement(iq.NotAcceptableError())
+				stm.SendElement(iq.NotAcceptableError())
 			}
 		} else {
-			x.stm.SendElement(iq.BadRequestError())
+			stm.SendElement(iq.BadRequestError())
 		}
 	} else if iq.IsSet() {
 		if q.Elements().Child("remove") != nil {
 			// remove user
-			x.cancelRegistration(iq, q)
+			x.cancelRegistration(iq, q, stm)
 		} else {
 			user := q.Elements().Child("username")
 			password := q.Elements().Child("password")
 			if user != nil && password != nil {
 				// change password
-				x.changePassword(password.Text(), user.Text(), iq)
+				x.changePassword(password.Text(), user.Text(), iq, stm)
 			} else {
-				x.stm.SendElement(iq.BadRequestError())
+				stm.SendElement(iq.BadRequestError())
 			}
 		}
 	} else {
-		x.stm.SendElement(iq.BadRequestError())
+		stm.SendElement(iq.BadRequestError())
 	}
 }
 
-func (x *Register) sendRegistrationFields(iq *xml.IQ, query xml.XElement) {
+func (x *Register) sendRegistrationFields(iq *xml.IQ, query xml.XElement, stm stream.C2S) {
 	if query.Elements().Count() > 0 {
-		x.stm.SendElement(iq.BadRequestError())
+		stm.SendElement(iq.BadRequestError())
 		return
 	}
 	result := iq.ResultIQ()
@@ -109,95 +118,84 @@ func (x *Register) sendRegistrationFields(iq *xml.IQ, query xml.XElement) {
 	q.AppendElement(xml.NewElementName("username"))
 	q.AppendElement(xml.NewElementName("password"))
 	result.AppendElement(q)
-	x.stm.SendElement(result)
+	stm.SendElement(result)
 }
 
-func (x *Register) registerNewUser(iq *xml.IQ, query xml.XElement) {
+func (x *Register) registerNewUser(iq *xml.IQ, query xml.XElement, stm stream.C2S) {
 	userEl := query.Elements().Child("username")
 	passwordEl := query.Elements().Child("password")
 	if userEl == nil || passwordEl == nil || len(userEl.Text()) == 0 || len(passwordEl.Text()) == 0 {
-		x.stm.SendElement(iq.BadRequestError())
+		stm.SendElement(iq.BadRequestError())
 		return
 	}
-	exists, err := storage.Instance().UserExists(userEl.Text())
+	exists, err := auth.Instance().UserExists(userEl.Text(), stm.Domain())
 	if err != nil {
 		log.Errorf("%v", err)
-		x.stm.SendElement(iq.InternalServerError())
+		stm.SendElement(iq.InternalServerError())
 		return
 	}
 	if exists {
-		x.stm.SendElement(iq.ConflictError())
+		stm.SendElement(iq.ConflictError())
 		return
 	}
 	user := model.User{
-		Username: userEl.Text(),
-		Password: passwordEl.Text(),
-		LastPresence: xml.NewPresence(x.stm.JID(), x.stm.JID(), xml.UnavailableType),
+		Username:     userEl.Text(),
+		Domain:       stm.Domain(),
+		LastPresence: xml.NewPresence(stm.JID(), stm.JID(), xml.UnavailableType),
 	}
+	auth.SetUserPassword(&user, passwordEl.Text())
+
 	if err := storage.Instance().InsertOrUpdateUser(&user); err != nil {
 		log.Errorf("%v", err)
-		x.stm.SendElement(iq.InternalServerError())
+		stm.SendElement(iq.InternalServerError())
 		return
 	}
-	x.stm.SendElement(iq.ResultIQ())
-	x.registered = true
+	stm.SendElement(iq.ResultIQ())
+	stm.Context().SetBool(true, registeredCtxKey)
 }
 
-func (x *Register) cancelRegistration(iq *xml.IQ, query xml.XElement) {
+func (x *Register) cancelRegistration(iq *xml.IQ, query xml.XElement, stm stream.C2S) {
 	if !x.cfg.AllowCancel {
-		x.stm.SendElement(iq.NotAllowedError())
+		stm.SendElement(iq.NotAllowedError())
 		return
 	}
 	if query.Elements().Count() > 1 {
-		x.stm.SendElement(iq.BadRequestError())
+		stm.SendElement(iq.BadRequestError())
 		return
 	}
-	if err := storage.Instance().DeleteUser(x.stm.Username()); err != nil {
+	if err := storage.Instance().DeleteUser(stm.Username(), stm.Domain()); err != nil {
 		log.Error(err)
-		x.stm.SendElement(iq.InternalServerError())
+		stm.SendElement(iq.InternalServerError())
 		return
 	}
-	x.stm.SendElement(iq.ResultIQ())
+	stm.SendElement(iq.ResultIQ())
 }
 
-func (x *Register) changePassword(password string, username string, iq *xml.IQ) {
+func (x *Register) changePassword(password string, username string, iq *xml.IQ, stm stream.C2S) {
 	if !x.cfg.AllowChange {
-		x.stm.SendElement(iq.NotAllowedError())
+		stm.SendElement(iq.NotAllowedError())
 		return
 	}
-	if username != x.stm.Username() {
-		x.stm.SendElement(iq.NotAllowedError())
+	if username != stm.Username() {
+		stm.SendElement(iq.NotAllowedError())
 		return
 	}
-	if !x.stm.IsSecured() {
+	if !stm.IsSecured() {
 		// channel isn't safe enough to enable a password change
-		x.stm.SendElement(iq.NotAuthorizedError())
+		stm.SendElement(iq.NotAuthorizedError())
 		return
 	}
-	user, err := storage.Instance().FetchUser(username)
-	if err != nil {
+	if err := auth.Instance().SetPassword(username, stm.Domain(), password); err != nil {
 		log.Error(err)
-		x.stm.SendElement(iq.InternalServerError())
-		return
-	}
-	if user == nil {
-		x.stm.SendElement(iq.ResultIQ())
+		stm.SendElement(iq.InternalServerError())
 		return
 	}
-	if user.Password != password {
-		user.Password = password
-		if err := storage.Instance().InsertOrUpdateUser(user); err != nil {
-			log.Error(err)
-			x.stm.SendElement(iq.InternalServerError())
-			return
-		}
-	}
-	x.stm.SendElement(iq.ResultIQ())
+	stm.SendElement(iq.ResultIQ())
 }
 
-func (x *Register) isValidToJid(j *jid.JID) bool {
-	if x.stm.IsAuthenticated() {
+func (x *Register) isValidToJid(j *jid.JID, stm stream.C2S) bool {
+	if stm.IsAuthenticated() {
 		return j.IsServer()
 	}
-	return j.IsServer() || (j.IsBare() && j.Node() == x.stm.Username())
+	return j.IsServer() || (j.IsBare() && j.Node() == stm.Username())
 }
@@ -8,6 +8,7 @@ package xep0077
 import (
 	"testing"
 
+	"github.com/ortuman/jackal/auth"
 	"github.com/ortuman/jackal/model"
 	"github.com/ortuman/jackal/storage"
 	"github.com/ortuman/jackal/stream"
@@ -20,7 +21,7 @@ import (
 func TestXEP0077_Matching(t *testing.T) {
 	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
 
-	x := New(&Config{}, nil)
+	x := New(&Config{})
 
 	// test MatchesIQ
 	iq := xml.NewIQType(uuid.New(), xml.SetType)
@@ -37,14 +38,14 @@ func TestXEP0077_InvalidToJID(t *testing.T) {
 	stm := stream.NewMockC2S("abcd1234", j)
 	defer stm.Disconnect(nil)
 
-	x := New(&Config{}, stm)
+	x := New(&Config{})
 
 	stm.SetUsername("romeo")
 	iq := xml.NewIQType(uuid.New(), xml.SetType)
 	iq.SetFromJID(j)
 	iq.SetToJID(j.ToBareJID())
 
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem := stm.FetchElement()
 	require.Equal(t, xml.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
 
@@ -54,7 +55,7 @@ func TestXEP0077_InvalidToJID(t *testing.T) {
 
 	stm.SetUsername("ortuman")
 	stm.SetAuthenticated(true)
-	x.ProcessIQ(iq2)
+	x.ProcessIQ(iq2, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, "iq", elem.Name())
 	require.Equal(t, xml.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
@@ -66,37 +67,37 @@ func TestXEP0077_NotAuthenticatedErrors(t *testing.T) {
 	stm := stream.NewMockC2S("abcd1234", j)
 	defer stm.Disconnect(nil)
 
-	x := New(&Config{}, stm)
+	x := New(&Config{})
 
 	iq := xml.NewIQType(uuid.New(), xml.ResultType)
 	iq.SetFromJID(j)
 	iq.SetToJID(j.ToBareJID())
 
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem := stm.FetchElement()
 	require.Equal(t, xml.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
 
 	iq.SetType(xml.GetType)
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, xml.ErrNotAllowed.Error(), elem.Error().Elements().All()[0].Name())
 
 	// allow registration...
-	x = New(&Config{AllowRegistration: true}, stm)
+	x = New(&Config{AllowRegistration: true})
 
 	q := xml.NewElementNamespace("query", registerNamespace)
 	q.AppendElement(xml.NewElementName("q2"))
 	iq.AppendElement(q)
 
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, xml.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
 
 	q.ClearElements()
 	iq.SetType(xml.SetType)
-	x.registered = true
+	stm.Context().SetBool(true, registeredCtxKey)
 
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, xml.ErrNotAcceptable.Error(), elem.Error().Elements().All()[0].Name())
 }
@@ -110,20 +111,20 @@ func TestXEP0077_AuthenticatedErrors(t *testing.T) {
 
 	stm.SetAuthenticated(true)
 
-	x := New(&Config{}, stm)
+	x := New(&Config{})
 
 	iq := xml.NewIQType(uuid.New(), xml.ResultType)
 	iq.SetFromJID(j)
 	iq.SetToJID(j.ToBareJID())
 	iq.SetToJID(srvJid)
 
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem := stm.FetchElement()
 	require.Equal(t, xml.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
 
 	iq.SetType(xml.SetType)
 	iq.AppendElement(xml.NewElementNamespace("query", registerNamespace))
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, xml.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
 }
@@ -138,7 +139,7 @@ func TestXEP0077_RegisterUser(t *testing.T) {
 	stm := stream.NewMockC2S("abcd1234", j)
 	defer stm.Disconnect(nil)
 
-	x := New(&Config{AllowRegistration: true}, stm)
+	x := New(&Config{AllowRegistration: true})
 
 	iq := xml.NewIQType(uuid.New(), xml.GetType)
 	iq.SetFromJID(srvJid)
@@ -147,7 +148,7 @@ func TestXEP0077_RegisterUser(t *testing.T) {
 	q := xml.NewElementNamespace("query", registerNamespace)
 	iq.AppendElement(q)
 
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	q2 := stm.FetchElement().Elements().ChildNamespace("query", registerNamespace)
 	require.NotNil(t, q2.Elements().Child("username"))
 	require.NotNil(t, q2.Elements().Child("password"))
@@ -159,31 +160,31 @@ func TestXEP0077_RegisterUser(t *testing.T) {
 
 	// empty fields
 	iq.SetType(xml.SetType)
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem := stm.FetchElement()
 	require.Equal(t, xml.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
 
 	// already existing user...
-	storage.Instance().InsertOrUpdateUser(&model.User{Username: "ortuman", Password: "1234"})
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "ortuman", Domain: "jackal.im", Password: "1234"})
 	username.SetText("ortuman")
 	password.SetText("5678")
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, xml.ErrConflict.Error(), elem.Error().Elements().All()[0].Name())
 
 	// storage error
 	storage.ActivateMockedError()
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, xml.ErrInternalServerError.Error(), elem.Error().Elements().All()[0].Name())
 
 	storage.DeactivateMockedError()
 	username.SetText("juliet")
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, xml.ResultType, elem.Type())
 
-	usr, _ := storage.Instance().FetchUser("ortuman")
+	usr, _ := storage.Instance().FetchUser("ortuman", "jackal.im")
 	require.NotNil(t, usr)
 }
 
@@ -199,9 +200,9 @@ func TestXEP0077_CancelRegistration(t *testing.T) {
 
 	stm.SetAuthenticated(true)
 
-	x := New(&Config{}, stm)
+	x := New(&Config{})
 
-	storage.Instance().InsertOrUpdateUser(&model.User{Username: "ortuman", Password: "1234"})
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "ortuman", Domain: "jackal.im", Password: "1234"})
 
 	iq := xml.NewIQType(uuid.New(), xml.SetType)
 	iq.SetFromJID(srvJid)
@@ -211,14 +212,14 @@ func TestXEP0077_CancelRegistration(t *testing.T) {
 	q.AppendElement(xml.NewElementName("remove"))
 
 	iq.AppendElement(q)
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem := stm.FetchElement()
 	require.Equal(t, xml.ErrNotAllowed.Error(), elem.Error().Elements().All()[0].Name())
 
-	x = New(&Config{AllowCancel: true}, stm)
+	x = New(&Config{AllowCancel: true})
 
 	q.AppendElement(xml.NewElementName("remove2"))
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, xml.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
 	q.ClearElements()
@@ -226,16 +227,16 @@ func TestXEP0077_CancelRegistration(t *testing.T) {
 
 	// storage error
 	storage.ActivateMockedError()
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, xml.ErrInternalServerError.Error(), elem.Error().Elements().All()[0].Name())
 	storage.DeactivateMockedError()
 
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, xml.ResultType, elem.Type())
 
-	usr, _ := storage.Instance().FetchUser("ortuman")
+	usr, _ := storage.Instance().FetchUser("ortuman", "jackal.im")
 	require.Nil(t, usr)
 }
 
@@ -251,9 +252,9 @@ func TestXEP0077_ChangePassword(t *testing.T) {
 
 	stm.SetAuthenticated(true)
 
-	x := New(&Config{}, stm)
+	x := New(&Config{})
 
-	storage.Instance().InsertOrUpdateUser(&model.User{Username: "ortuman", Password: "1234"})
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "ortuman", Domain: "jackal.im", Password: "1234"})
 
 	iq := xml.NewIQType(uuid.New(), xml.SetType)
 	iq.SetFromJID(srvJid)
@@ -268,18 +269,18 @@ func TestXEP0077_ChangePassword(t *testing.T) {
 	q.AppendElement(password)
 	iq.AppendElement(q)
 
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem := stm.FetchElement()
 	require.Equal(t, xml.ErrNotAllowed.Error(), elem.Error().Elements().All()[0].Name())
 
-	x = New(&Config{AllowChange: true}, stm)
+	x = New(&Config{AllowChange: true})
 
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, xml.ErrNotAllowed.Error(), elem.Error().Elements().All()[0].Name())
 
 	username.SetText("ortuman")
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, xml.ErrNotAuthorized.Error(), elem.Error().Elements().All()[0].Name())
 
@@ -288,16 +289,71 @@ func TestXEP0077_ChangePassword(t *testing.T) {
 
 	// storage error
 	storage.ActivateMockedError()
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, xml.ErrInternalServerError.Error(), elem.Error().Elements().All()[0].Name())
 	storage.DeactivateMockedError()
 
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, xml.ResultType, elem.Type())
 
-	usr, _ := storage.Instance().FetchUser("ortuman")
+	usr, _ := storage.Instance().FetchUser("ortuman", "jackal.im")
 	require.NotNil(t, usr)
 	require.Equal(t, "5678", usr.Password)
+
+	// SCRAM credentials
+	auth.Initialize(&auth.Config{ScramOnly: true})
+	defer auth.Shutdown()
+
+	password.SetText("91011")
+	x.ProcessIQ(iq, stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	usr, _ = storage.Instance().FetchUser("ortuman", "jackal.im")
+	require.NotNil(t, usr)
+	require.Equal(t, "", usr.Password)
+	require.True(t, usr.HasScramCredentials())
+	require.True(t, auth.VerifyPassword(usr, "91011"))
+}
+
+func TestXEP0077_RegisterScramUser(t *testing.T) {
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	defer storage.Shutdown()
+
+	srvJid, _ := jid.New("", "jackal.im", "", true)
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+
+	stm := stream.NewMockC2S("abcd1234", j)
+	defer stm.Disconnect(nil)
+
+	auth.Initialize(&auth.Config{ScramOnly: true})
+	defer auth.Shutdown()
+
+	x := New(&Config{AllowRegistration: true})
+
+	iq := xml.NewIQType(uuid.New(), xml.SetType)
+	iq.SetFromJID(srvJid)
+	iq.SetToJID(srvJid)
+
+	q := xml.NewElementNamespace("query", registerNamespace)
+	username := xml.NewElementName("username")
+	username.SetText("juliet")
+	password := xml.NewElementName("password")
+	password.SetText("1234")
+	q.AppendElement(username)
+	q.AppendElement(password)
+	iq.AppendElement(q)
+
+	x.ProcessIQ(iq, stm)
+	elem := stm.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	usr, _ := storage.Instance().FetchUser("juliet", "jackal.im")
+	require.NotNil(t, usr)
+	require.Equal(t, "", usr.Password)
+	require.NotNil(t, usr.ScramSHA1)
+	require.NotNil(t, usr.ScramSHA256)
+	require.True(t, auth.VerifyPassword(usr, "1234"))
 }
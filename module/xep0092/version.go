@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/module"
 	"github.com/ortuman/jackal/module/xep0030"
 	"github.com/ortuman/jackal/stream"
 	"github.com/ortuman/jackal/version"
@@ -23,6 +24,14 @@ var osString string
 func init() {
 	out, _ := exec.Command("uname", "-rs").Output()
 	osString = strings.TrimSpace(string(out))
+
+	module.Register("version", func(_ string, cfg *module.Config) (module.Module, error) {
+		var config Config
+		if err := cfg.Decode("version", &config); err != nil {
+			return nil, err
+		}
+		return New(&config), nil
+	})
 }
 
 // Config represents XMPP Software Version module (XEP-0092) configuration.
@@ -30,24 +39,20 @@ type Config struct {
 	ShowOS bool `yaml:"show_os"`
 }
 
-// Version represents a version server stream module.
+// Version represents a version server module.
 type Version struct {
 	cfg *Config
-	stm stream.C2S
 }
 
 // New returns a version IQ handler module.
-func New(config *Config, stm stream.C2S) *Version {
-	return &Version{
-		cfg: config,
-		stm: stm,
-	}
+func New(config *Config) *Version {
+	return &Version{cfg: config}
 }
 
 // RegisterDisco registers disco entity features/items
 // associated to version module.
 func (x *Version) RegisterDisco(discoInfo *xep0030.DiscoInfo) {
-	discoInfo.Entity(x.stm.Domain(), "").AddFeature(versionNamespace)
+	discoInfo.ServerEntity().AddFeature(versionNamespace)
 }
 
 // MatchesIQ returns whether or not an IQ should be
@@ -58,18 +63,18 @@ func (x *Version) MatchesIQ(iq *xml.IQ) bool {
 
 // ProcessIQ processes a version IQ taking according actions
 // over the associated stream.
-func (x *Version) ProcessIQ(iq *xml.IQ) {
+func (x *Version) ProcessIQ(iq *xml.IQ, stm stream.C2S) {
 	q := iq.Elements().ChildNamespace("query", versionNamespace)
 	if q.Elements().Count() != 0 {
-		x.stm.SendElement(iq.BadRequestError())
+		stm.SendElement(iq.BadRequestError())
 		return
 	}
-	x.sendSoftwareVersion(iq)
+	x.sendSoftwareVersion(iq, stm)
 }
 
-func (x *Version) sendSoftwareVersion(iq *xml.IQ) {
-	username := x.stm.Username()
-	resource := x.stm.Resource()
+func (x *Version) sendSoftwareVersion(iq *xml.IQ, stm stream.C2S) {
+	username := stm.Username()
+	resource := stm.Resource()
 	log.Infof("retrieving software version: %v (%s/%s)", version.ApplicationVersion, username, resource)
 
 	result := iq.ResultIQ()
@@ -89,5 +94,5 @@ func (x *Version) sendSoftwareVersion(iq *xml.IQ) {
 		query.AppendElement(os)
 	}
 	result.AppendElement(query)
-	x.stm.SendElement(result)
+	stm.SendElement(result)
 }
@@ -24,7 +24,7 @@ func TestXEP0092(t *testing.T) {
 	defer stm.Disconnect(nil)
 
 	cfg := Config{}
-	x := New(&cfg, stm)
+	x := New(&cfg)
 
 	// test MatchesIQ
 	iq := xml.NewIQType(uuid.New(), xml.GetType)
@@ -42,13 +42,13 @@ func TestXEP0092(t *testing.T) {
 	require.True(t, x.MatchesIQ(iq))
 
 	qVer.AppendElement(xml.NewElementName("version"))
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem := stm.FetchElement()
 	require.Equal(t, xml.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
 
 	// get version
 	qVer.ClearElements()
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem = stm.FetchElement()
 	ver := elem.Elements().ChildNamespace("query", versionNamespace)
 	require.Equal(t, "jackal", ver.Elements().Child("name").Text())
@@ -58,8 +58,8 @@ func TestXEP0092(t *testing.T) {
 	// show OS
 	cfg.ShowOS = true
 
-	x = New(&cfg, stm)
-	x.ProcessIQ(iq)
+	x = New(&cfg)
+	x.ProcessIQ(iq, stm)
 	elem = stm.FetchElement()
 	ver = elem.Elements().ChildNamespace("query", versionNamespace)
 	require.Equal(t, osString, ver.Elements().Child("os").Text())
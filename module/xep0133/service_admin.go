@@ -0,0 +1,374 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0133
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/ortuman/jackal/auth"
+	"github.com/ortuman/jackal/errors"
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/module"
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/module/xep0050"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+	"github.com/pborman/uuid"
+)
+
+const adminFormType = "http://jabber.org/protocol/admin"
+
+// service administration command nodes
+const (
+	AddUserNode               = adminFormType + "#add-user"
+	DeleteUserNode            = adminFormType + "#delete-user"
+	DisableUserNode           = adminFormType + "#disable-user"
+	ReenableUserNode          = adminFormType + "#reenable-user"
+	ChangeUserPasswordNode    = adminFormType + "#change-user-password"
+	EndUserSessionNode        = adminFormType + "#end-user-session"
+	GetOnlineUsersNumNode     = adminFormType + "#get-online-users-num"
+	GetRegisteredUsersNumNode = adminFormType + "#get-registered-users-num"
+	AnnounceNode              = adminFormType + "#announce"
+)
+
+func init() {
+	module.Register("service_admin", func(domain string, _ *module.Config) (module.Module, error) {
+		return New(domain), nil
+	})
+}
+
+// ServiceAdmin represents a service administration server module.
+type ServiceAdmin struct {
+	domain string
+}
+
+// New returns a service administration module associated
+// to a local domain.
+func New(domain string) *ServiceAdmin {
+	return &ServiceAdmin{domain: domain}
+}
+
+// RegisterDisco registers disco entity features/items
+// associated to service administration module.
+func (x *ServiceAdmin) RegisterDisco(_ *xep0030.DiscoInfo) {}
+
+// Initialize registers every service administration command
+// into the host ad-hoc commands module.
+func (x *ServiceAdmin) Initialize(lookup func(name string) module.Module) error {
+	adHoc, ok := lookup("adhoc").(*xep0050.AdHoc)
+	if !ok {
+		return errors.New("xep0133: adhoc module must be enabled")
+	}
+	return x.RegisterCommands(adHoc)
+}
+
+// RegisterCommands registers every service administration command
+// into an ad-hoc commands module.
+func (x *ServiceAdmin) RegisterCommands(adHoc *xep0050.AdHoc) error {
+	cmds := []xep0050.Command{
+		{Node: AddUserNode, Name: "Add User", Handler: x.formHandler(x.addUserForm, x.addUser)},
+		{Node: DeleteUserNode, Name: "Delete User", Handler: x.formHandler(accountJIDsForm("The Jabber ID(s) to delete"), x.deleteUsers)},
+		{Node: DisableUserNode, Name: "Disable User", Handler: x.formHandler(accountJIDsForm("The Jabber ID(s) to disable"), x.disableUsers)},
+		{Node: ReenableUserNode, Name: "Re-Enable User", Handler: x.formHandler(accountJIDsForm("The Jabber ID(s) to re-enable"), x.reenableUsers)},
+		{Node: ChangeUserPasswordNode, Name: "Change User Password", Handler: x.formHandler(x.changeUserPasswordForm, x.changeUserPassword)},
+		{Node: EndUserSessionNode, Name: "End User Session", Handler: x.formHandler(accountJIDsForm("The Jabber ID(s) for which to end sessions"), x.endUserSessions)},
+		{Node: GetOnlineUsersNumNode, Name: "Get Number of Online Users", Handler: x.getOnlineUsersNum},
+		{Node: GetRegisteredUsersNumNode, Name: "Get Number of Registered Users", Handler: x.getRegisteredUsersNum},
+		{Node: AnnounceNode, Name: "Send Announcement to Online Users", Handler: x.formHandler(x.announceForm, x.announce)},
+	}
+	for _, cmd := range cmds {
+		cmd.Access = xep0050.AdminAccess
+		if err := adHoc.RegisterCommand(cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formHandler returns a two stage command handler that first replies
+// with a form to be filled and processes it once submitted.
+func (x *ServiceAdmin) formHandler(form func() *xep0004.DataForm, process func(req *xep0050.Request) error) xep0050.Handler {
+	return func(req *xep0050.Request) (*xep0050.Response, error) {
+		if req.Action == xep0050.Execute {
+			return &xep0050.Response{Status: xep0050.Executing, Form: form()}, nil
+		}
+		if req.Form == nil || req.Form.Type != xep0004.Submit {
+			return nil, xml.ErrBadRequest
+		}
+		if err := process(req); err != nil {
+			return nil, err
+		}
+		return &xep0050.Response{Status: xep0050.Completed}, nil
+	}
+}
+
+func (x *ServiceAdmin) addUserForm() *xep0004.DataForm {
+	form := newAdminForm("Adding a User", "Fill out this form to add a user.")
+	form.Fields = append(form.Fields, xep0004.Field{Var: "accountjid", Type: xep0004.JidSingle, Label: "The Jabber ID for the account to be added", Required: true})
+	form.Fields = append(form.Fields, xep0004.Field{Var: "password", Type: xep0004.TextPrivate, Label: "The password for this account", Required: true})
+	form.Fields = append(form.Fields, xep0004.Field{Var: "password-verify", Type: xep0004.TextPrivate, Label: "Retype password", Required: true})
+	return form
+}
+
+func (x *ServiceAdmin) addUser(req *xep0050.Request) error {
+	accountJID, err := x.accountJID(req.Form.Fields.ValueForField("accountjid"))
+	if err != nil {
+		return err
+	}
+	password := req.Form.Fields.ValueForField("password")
+	if len(password) == 0 || password != req.Form.Fields.ValueForField("password-verify") {
+		return xml.ErrNotAcceptable
+	}
+	exists, err := auth.Instance().UserExists(accountJID.Node(), accountJID.Domain())
+	if err != nil {
+		return err
+	}
+	if exists {
+		return xml.ErrConflict
+	}
+	user := model.User{Username: accountJID.Node(), Domain: accountJID.Domain()}
+	auth.SetUserPassword(&user, password)
+	if err := storage.Instance().InsertOrUpdateUser(&user); err != nil {
+		return err
+	}
+	log.Infof("service admin: created user... (%s) by: %s", user.Username, req.Session.JID.String())
+	return nil
+}
+
+func (x *ServiceAdmin) deleteUsers(req *xep0050.Request) error {
+	users, err := x.fetchUsers(req.Form)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		if err := storage.Instance().DeleteUser(user.Username, user.Domain); err != nil {
+			return err
+		}
+		for _, stm := range router.UserStreams(user.Username, user.Domain) {
+			stm.Disconnect(streamerror.ErrNotAuthorized)
+		}
+		log.Infof("service admin: deleted user... (%s) by: %s", user.Username, req.Session.JID.String())
+	}
+	return nil
+}
+
+func (x *ServiceAdmin) disableUsers(req *xep0050.Request) error {
+	users, err := x.fetchUsers(req.Form)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		// credentials and tokens are kept, so that account
+		// can be re-enabled afterwards
+		user.Disabled = true
+		if err := storage.Instance().InsertOrUpdateUser(user); err != nil {
+			return err
+		}
+		for _, stm := range router.UserStreams(user.Username, user.Domain) {
+			stm.Disconnect(streamerror.ErrNotAuthorized)
+		}
+		log.Infof("service admin: disabled user... (%s) by: %s", user.Username, req.Session.JID.String())
+	}
+	return nil
+}
+
+func (x *ServiceAdmin) reenableUsers(req *xep0050.Request) error {
+	users, err := x.fetchUsers(req.Form)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		user.Disabled = false
+		if err := storage.Instance().InsertOrUpdateUser(user); err != nil {
+			return err
+		}
+		log.Infof("service admin: re-enabled user... (%s) by: %s", user.Username, req.Session.JID.String())
+	}
+	return nil
+}
+
+func (x *ServiceAdmin) changeUserPasswordForm() *xep0004.DataForm {
+	form := newAdminForm("Changing a User Password", "Fill out this form to change a user's password.")
+	form.Fields = append(form.Fields, xep0004.Field{Var: "accountjid", Type: xep0004.JidSingle, Label: "The Jabber ID for this account", Required: true})
+	form.Fields = append(form.Fields, xep0004.Field{Var: "password", Type: xep0004.TextPrivate, Label: "The password for this account", Required: true})
+	return form
+}
+
+func (x *ServiceAdmin) changeUserPassword(req *xep0050.Request) error {
+	accountJID, err := x.accountJID(req.Form.Fields.ValueForField("accountjid"))
+	if err != nil {
+		return err
+	}
+	password := req.Form.Fields.ValueForField("password")
+	if len(password) == 0 {
+		return xml.ErrNotAcceptable
+	}
+	prv := auth.Instance()
+	exists, err := prv.UserExists(accountJID.Node(), accountJID.Domain())
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return xml.ErrItemNotFound
+	}
+	if err := prv.SetPassword(accountJID.Node(), accountJID.Domain(), password); err != nil {
+		return err
+	}
+	log.Infof("service admin: changed user password... (%s) by: %s", accountJID.Node(), req.Session.JID.String())
+	return nil
+}
+
+func (x *ServiceAdmin) endUserSessions(req *xep0050.Request) error {
+	accountJIDs, err := x.accountJIDs(req.Form)
+	if err != nil {
+		return err
+	}
+	for _, accountJID := range accountJIDs {
+		for _, stm := range router.UserStreams(accountJID.Node(), accountJID.Domain()) {
+			if accountJID.IsFull() && stm.Resource() != accountJID.Resource() {
+				continue
+			}
+			stm.Disconnect(streamerror.ErrPolicyViolation)
+			log.Infof("service admin: ended user session... (%s) by: %s", stm.JID().String(), req.Session.JID.String())
+		}
+	}
+	return nil
+}
+
+func (x *ServiceAdmin) getOnlineUsersNum(_ *xep0050.Request) (*xep0050.Response, error) {
+	online := make(map[string]struct{})
+	for _, stm := range router.LocalStreams() {
+		if stm.JID().Domain() == x.domain {
+			online[stm.Username()] = struct{}{}
+		}
+	}
+	form := &xep0004.DataForm{Type: xep0004.Result}
+	form.Fields = append(form.Fields, xep0004.Field{Var: xep0004.FormTypeFieldVar, Type: xep0004.Hidden, Values: []string{adminFormType}})
+	form.Fields = append(form.Fields, xep0004.Field{Var: "onlineusersnum", Label: "The number of online users", Values: []string{strconv.Itoa(len(online))}})
+	return &xep0050.Response{Status: xep0050.Completed, Form: form}, nil
+}
+
+func (x *ServiceAdmin) getRegisteredUsersNum(_ *xep0050.Request) (*xep0050.Response, error) {
+	count, err := storage.Instance().CountUsers(x.domain)
+	if err != nil {
+		return nil, err
+	}
+	form := &xep0004.DataForm{Type: xep0004.Result}
+	form.Fields = append(form.Fields, xep0004.Field{Var: xep0004.FormTypeFieldVar, Type: xep0004.Hidden, Values: []string{adminFormType}})
+	form.Fields = append(form.Fields, xep0004.Field{Var: "registeredusersnum", Label: "The number of registered users", Values: []string{strconv.Itoa(count)}})
+	return &xep0050.Response{Status: xep0050.Completed, Form: form}, nil
+}
+
+func (x *ServiceAdmin) announceForm() *xep0004.DataForm {
+	form := newAdminForm("Making an Announcement", "Fill out this form to make an announcement to all active users of this service.")
+	form.Fields = append(form.Fields, xep0004.Field{Var: "subject", Type: xep0004.TextSingle, Label: "Subject"})
+	form.Fields = append(form.Fields, xep0004.Field{Var: "announcement", Type: xep0004.TextMulti, Label: "Announcement", Required: true})
+	return form
+}
+
+func (x *ServiceAdmin) announce(req *xep0050.Request) error {
+	var text string
+	if f := req.Form.Fields.Field("announcement"); f != nil {
+		text = strings.Join(f.Values, "\n")
+	}
+	if len(text) == 0 {
+		return xml.ErrNotAcceptable
+	}
+	subject := req.Form.Fields.ValueForField("subject")
+
+	fromJID, _ := jid.New("", x.domain, "", true)
+	for _, stm := range router.LocalStreams() {
+		if stm.JID().Domain() != x.domain {
+			continue
+		}
+		message := xml.NewMessageType(uuid.New(), xml.NormalType)
+		message.SetFromJID(fromJID)
+		message.SetToJID(stm.JID())
+		if len(subject) > 0 {
+			subjectEl := xml.NewElementName("subject")
+			subjectEl.SetText(subject)
+			message.AppendElement(subjectEl)
+		}
+		body := xml.NewElementName("body")
+		body.SetText(text)
+		message.AppendElement(body)
+		stm.SendElement(message)
+	}
+	log.Infof("service admin: sent announcement... (%s)", req.Session.JID.String())
+	return nil
+}
+
+// fetchUsers returns the existing users referenced by a submitted form.
+func (x *ServiceAdmin) fetchUsers(form *xep0004.DataForm) ([]*model.User, error) {
+	accountJIDs, err := x.accountJIDs(form)
+	if err != nil {
+		return nil, err
+	}
+	var users []*model.User
+	for _, accountJID := range accountJIDs {
+		user, err := storage.Instance().FetchUser(accountJID.Node(), accountJID.Domain())
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, xml.ErrItemNotFound
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func (x *ServiceAdmin) accountJIDs(form *xep0004.DataForm) ([]*jid.JID, error) {
+	f := form.Fields.Field("accountjids")
+	if f == nil || len(f.Values) == 0 {
+		return nil, xml.ErrNotAcceptable
+	}
+	var ret []*jid.JID
+	for _, v := range f.Values {
+		accountJID, err := x.accountJID(v)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, accountJID)
+	}
+	return ret, nil
+}
+
+// accountJID validates that a JID belongs to a local domain account.
+func (x *ServiceAdmin) accountJID(str string) (*jid.JID, error) {
+	accountJID, err := jid.NewWithString(str, false)
+	if err != nil || len(accountJID.Node()) == 0 {
+		return nil, xml.ErrJidMalformed
+	}
+	if accountJID.Domain() != x.domain {
+		return nil, xml.ErrNotAllowed
+	}
+	return accountJID, nil
+}
+
+func accountJIDsForm(label string) func() *xep0004.DataForm {
+	return func() *xep0004.DataForm {
+		form := newAdminForm("", "")
+		form.Fields = append(form.Fields, xep0004.Field{Var: "accountjids", Type: xep0004.JidMulti, Label: label, Required: true})
+		return form
+	}
+}
+
+func newAdminForm(title, instructions string) *xep0004.DataForm {
+	form := &xep0004.DataForm{
+		Type:         xep0004.Form,
+		Title:        title,
+		Instructions: instructions,
+	}
+	form.Fields = append(form.Fields, xep0004.Field{Var: xep0004.FormTypeFieldVar, Type: xep0004.Hidden, Values: []string{adminFormType}})
+	return form
+}
@@ -0,0 +1,321 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0133
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/module"
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/module/xep0050"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXEP0133_Initialize(t *testing.T) {
+	x := New("jackal.im")
+
+	err := x.Initialize(func(_ string) module.Module { return nil })
+	require.NotNil(t, err)
+
+	adHoc, _ := xep0050.New("jackal.im", &xep0050.Config{})
+	defer adHoc.Shutdown()
+
+	err = x.Initialize(func(_ string) module.Module { return adHoc })
+	require.Nil(t, err)
+
+	// already registered
+	require.NotNil(t, x.RegisterCommands(adHoc))
+}
+
+func TestXEP0133_AccessControl(t *testing.T) {
+	adHoc, stm, teardown := tUtilSetup(t)
+	defer teardown()
+
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	userStm := stream.NewMockC2S(uuid.New(), j)
+
+	adHoc.ProcessIQ(tUtilCommandIQ(userStm.JID(), GetOnlineUsersNumNode, "", "", nil), userStm)
+	elem := userStm.FetchElement()
+	require.Equal(t, xml.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+
+	adHoc.ProcessIQ(tUtilCommandIQ(stm.JID(), GetOnlineUsersNumNode, "", "", nil), stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+}
+
+func TestXEP0133_AddUser(t *testing.T) {
+	adHoc, stm, teardown := tUtilSetup(t)
+	defer teardown()
+
+	form := tUtilSubmitForm(map[string][]string{
+		"accountjid":      {"ortuman@jackal.im"},
+		"password":        {"1234"},
+		"password-verify": {"1234"},
+	})
+	tUtilExecuteCommand(t, adHoc, stm, AddUserNode, form)
+	elem := stm.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+	require.Equal(t, xep0050.Completed, elem.Elements().Child("command").Attributes().Get("status"))
+
+	user, _ := storage.Instance().FetchUser("ortuman", "jackal.im")
+	require.NotNil(t, user)
+	require.Equal(t, "1234", user.Password)
+
+	// already existing user
+	tUtilExecuteCommand(t, adHoc, stm, AddUserNode, form)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ErrConflict.Error(), elem.Error().Elements().All()[0].Name())
+
+	// password mismatch
+	form = tUtilSubmitForm(map[string][]string{
+		"accountjid":      {"juliet@jackal.im"},
+		"password":        {"1234"},
+		"password-verify": {"4321"},
+	})
+	tUtilExecuteCommand(t, adHoc, stm, AddUserNode, form)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ErrNotAcceptable.Error(), elem.Error().Elements().All()[0].Name())
+
+	// non local account
+	form = tUtilSubmitForm(map[string][]string{
+		"accountjid":      {"juliet@jabber.org"},
+		"password":        {"1234"},
+		"password-verify": {"1234"},
+	})
+	tUtilExecuteCommand(t, adHoc, stm, AddUserNode, form)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ErrNotAllowed.Error(), elem.Error().Elements().All()[0].Name())
+}
+
+func TestXEP0133_ChangeUserPassword(t *testing.T) {
+	adHoc, stm, teardown := tUtilSetup(t)
+	defer teardown()
+
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "ortuman", Domain: "jackal.im", Password: "1234"})
+
+	form := tUtilSubmitForm(map[string][]string{
+		"accountjid": {"ortuman@jackal.im"},
+		"password":   {"5678"},
+	})
+	tUtilExecuteCommand(t, adHoc, stm, ChangeUserPasswordNode, form)
+	elem := stm.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	user, _ := storage.Instance().FetchUser("ortuman", "jackal.im")
+	require.Equal(t, "5678", user.Password)
+
+	form = tUtilSubmitForm(map[string][]string{
+		"accountjid": {"juliet@jackal.im"},
+		"password":   {"5678"},
+	})
+	tUtilExecuteCommand(t, adHoc, stm, ChangeUserPasswordNode, form)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ErrItemNotFound.Error(), elem.Error().Elements().All()[0].Name())
+}
+
+func TestXEP0133_DisableAndDeleteUser(t *testing.T) {
+	adHoc, stm, teardown := tUtilSetup(t)
+	defer teardown()
+
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "ortuman", Domain: "jackal.im", Password: "1234"})
+	storage.Instance().InsertOrUpdateAuthToken(&model.AuthToken{Username: "ortuman", Domain: "jackal.im", Device: "garden", Hash: "a1b2c3", ExpiresAt: time.Now().Add(time.Hour)})
+
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	userStm := stream.NewMockC2S(uuid.New(), j)
+	router.Bind(userStm)
+
+	form := tUtilSubmitForm(map[string][]string{"accountjids": {"ortuman@jackal.im"}})
+	tUtilExecuteCommand(t, adHoc, stm, DisableUserNode, form)
+	elem := stm.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	user, _ := storage.Instance().FetchUser("ortuman", "jackal.im")
+	require.NotNil(t, user)
+	require.True(t, user.Disabled)
+	require.Equal(t, "1234", user.Password)
+	require.True(t, userStm.IsDisconnected())
+	router.Unbind(userStm)
+
+	tokens, _ := storage.Instance().FetchAuthTokens("ortuman", "jackal.im")
+	require.Equal(t, 1, len(tokens))
+
+	tUtilExecuteCommand(t, adHoc, stm, ReenableUserNode, form)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	user, _ = storage.Instance().FetchUser("ortuman", "jackal.im")
+	require.False(t, user.Disabled)
+	require.Equal(t, "1234", user.Password)
+
+	tUtilExecuteCommand(t, adHoc, stm, DeleteUserNode, form)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	exists, _ := storage.Instance().UserExists("ortuman", "jackal.im")
+	require.False(t, exists)
+
+	// unknown user
+	tUtilExecuteCommand(t, adHoc, stm, DeleteUserNode, form)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ErrItemNotFound.Error(), elem.Error().Elements().All()[0].Name())
+}
+
+func TestXEP0133_EndUserSession(t *testing.T) {
+	adHoc, stm, teardown := tUtilSetup(t)
+	defer teardown()
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("ortuman", "jackal.im", "garden", true)
+	stm1 := stream.NewMockC2S(uuid.New(), j1)
+	stm2 := stream.NewMockC2S(uuid.New(), j2)
+	router.Bind(stm1)
+	router.Bind(stm2)
+
+	form := tUtilSubmitForm(map[string][]string{"accountjids": {"ortuman@jackal.im/garden"}})
+	tUtilExecuteCommand(t, adHoc, stm, EndUserSessionNode, form)
+	elem := stm.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	require.True(t, stm2.IsDisconnected())
+	require.False(t, stm1.IsDisconnected())
+	router.Unbind(stm2)
+
+	form = tUtilSubmitForm(map[string][]string{"accountjids": {"ortuman@jackal.im"}})
+	tUtilExecuteCommand(t, adHoc, stm, EndUserSessionNode, form)
+	stm.FetchElement()
+	require.True(t, stm1.IsDisconnected())
+}
+
+func TestXEP0133_UsersNum(t *testing.T) {
+	adHoc, stm, teardown := tUtilSetup(t)
+	defer teardown()
+
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "admin", Domain: "jackal.im", Password: "1234"})
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "ortuman", Domain: "jackal.im", Password: "1234"})
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "juliet", Domain: "jackal.im", Password: "1234"})
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "romeo", Domain: "example.org", Password: "1234"})
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("ortuman", "jackal.im", "garden", true)
+	router.Bind(stream.NewMockC2S(uuid.New(), j1))
+	router.Bind(stream.NewMockC2S(uuid.New(), j2))
+
+	adHoc.ProcessIQ(tUtilCommandIQ(stm.JID(), GetOnlineUsersNumNode, "", "", nil), stm)
+	elem := stm.FetchElement()
+	form, err := xep0004.NewFormFromElement(elem.Elements().Child("command").Elements().ChildNamespace("x", xep0004.FormNamespace))
+	require.Nil(t, err)
+	require.Equal(t, "2", form.Fields.ValueForField("onlineusersnum"))
+
+	adHoc.ProcessIQ(tUtilCommandIQ(stm.JID(), GetRegisteredUsersNumNode, "", "", nil), stm)
+	elem = stm.FetchElement()
+	form, err = xep0004.NewFormFromElement(elem.Elements().Child("command").Elements().ChildNamespace("x", xep0004.FormNamespace))
+	require.Nil(t, err)
+	require.Equal(t, "3", form.Fields.ValueForField("registeredusersnum"))
+}
+
+func TestXEP0133_Announce(t *testing.T) {
+	adHoc, stm, teardown := tUtilSetup(t)
+	defer teardown()
+
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	userStm := stream.NewMockC2S(uuid.New(), j)
+	router.Bind(userStm)
+
+	form := tUtilSubmitForm(map[string][]string{
+		"subject":      {"Maintenance"},
+		"announcement": {"Server will be restarted", "in 5 minutes."},
+	})
+	tUtilExecuteCommand(t, adHoc, stm, AnnounceNode, form)
+
+	elem := userStm.FetchElement()
+	require.Equal(t, "message", elem.Name())
+	require.Equal(t, "jackal.im", elem.From())
+	require.Equal(t, "Maintenance", elem.Elements().Child("subject").Text())
+	require.Equal(t, "Server will be restarted\nin 5 minutes.", elem.Elements().Child("body").Text())
+
+	elem = stm.FetchElement()
+	require.Equal(t, "message", elem.Name()) // admins are online users too
+
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	// missing announcement
+	tUtilExecuteCommand(t, adHoc, stm, AnnounceNode, tUtilSubmitForm(nil))
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ErrNotAcceptable.Error(), elem.Error().Elements().All()[0].Name())
+}
+
+func tUtilSetup(t *testing.T) (*xep0050.AdHoc, *stream.MockC2S, func()) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	router.Initialize(&router.Config{})
+
+	adHoc, _ := xep0050.New("jackal.im", &xep0050.Config{Admins: []string{"admin@jackal.im"}})
+	require.Nil(t, New("jackal.im").RegisterCommands(adHoc))
+
+	j, _ := jid.New("admin", "jackal.im", "console", true)
+	stm := stream.NewMockC2S(uuid.New(), j)
+	router.Bind(stm)
+
+	return adHoc, stm, func() {
+		adHoc.Shutdown()
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}
+}
+
+// tUtilExecuteCommand requests a command form and submits it afterwards.
+func tUtilExecuteCommand(t *testing.T, adHoc *xep0050.AdHoc, stm *stream.MockC2S, node string, form *xep0004.DataForm) {
+	adHoc.ProcessIQ(tUtilCommandIQ(stm.JID(), node, "", "", nil), stm)
+	elem := stm.FetchElement()
+	cmd := elem.Elements().Child("command")
+	require.NotNil(t, cmd)
+	require.Equal(t, xep0050.Executing, cmd.Attributes().Get("status"))
+	require.NotNil(t, cmd.Elements().ChildNamespace("x", xep0004.FormNamespace))
+
+	sessionID := cmd.Attributes().Get("sessionid")
+	adHoc.ProcessIQ(tUtilCommandIQ(stm.JID(), node, sessionID, xep0050.Complete, form), stm)
+}
+
+func tUtilSubmitForm(values map[string][]string) *xep0004.DataForm {
+	form := &xep0004.DataForm{Type: xep0004.Submit}
+	form.Fields = append(form.Fields, xep0004.Field{Var: xep0004.FormTypeFieldVar, Type: xep0004.Hidden, Values: []string{adminFormType}})
+	for k, v := range values {
+		form.Fields = append(form.Fields, xep0004.Field{Var: k, Values: v})
+	}
+	return form
+}
+
+func tUtilCommandIQ(from *jid.JID, node, sessionID, action string, form *xep0004.DataForm) *xml.IQ {
+	cmd := xml.NewElementNamespace("command", "http://jabber.org/protocol/commands")
+	cmd.SetAttribute("node", node)
+	if len(sessionID) > 0 {
+		cmd.SetAttribute("sessionid", sessionID)
+	}
+	if len(action) > 0 {
+		cmd.SetAttribute("action", action)
+	}
+	if form != nil {
+		cmd.AppendElement(form.Element())
+	}
+	srvJID, _ := jid.New("", from.Domain(), "", true)
+
+	iq := xml.NewIQType(uuid.New(), xml.SetType)
+	iq.SetFromJID(from)
+	iq.SetToJID(srvJID)
+	iq.AppendElement(cmd)
+	return iq
+}
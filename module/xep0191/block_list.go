@@ -9,6 +9,7 @@ import (
 	"github.com/ortuman/jackal/log"
 	"github.com/ortuman/jackal/model"
 	"github.com/ortuman/jackal/model/rostermodel"
+	"github.com/ortuman/jackal/module"
 	"github.com/ortuman/jackal/module/roster"
 	"github.com/ortuman/jackal/module/xep0030"
 	"github.com/ortuman/jackal/router"
@@ -25,21 +26,26 @@ const (
 	xep191RequestedContextKey = "xep_191:requested"
 )
 
+func init() {
+	module.Register("blocking_command", func(_ string, _ *module.Config) (module.Module, error) {
+		return New(), nil
+	})
+}
+
 // BlockingCommand returns a blocking command IQ handler module.
 type BlockingCommand struct {
-	stm stream.C2S
 }
 
 // New returns a blocking command IQ handler module.
-func New(stm stream.C2S) *BlockingCommand {
-	return &BlockingCommand{stm: stm}
+func New() *BlockingCommand {
+	return &BlockingCommand{}
 }
 
 // RegisterDisco registers disco entity features/items
 // associated to blocking command module.
 func (x *BlockingCommand) RegisterDisco(discoInfo *xep0030.DiscoInfo) {
-	discoInfo.Entity(x.stm.Domain(), "").AddFeature(blockingCommandNamespace)
-	discoInfo.Entity(x.stm.JID().ToBareJID().String(), "").AddFeature(blockingCommandNamespace)
+	discoInfo.ServerEntity().AddFeature(blockingCommandNamespace)
+	discoInfo.AccountEntity().AddFeature(blockingCommandNamespace)
 }
 
 // MatchesIQ returns whether or not an IQ should be
@@ -54,24 +60,24 @@ func (x *BlockingCommand) MatchesIQ(iq *xml.IQ) bool {
 
 // ProcessIQ processes a blocking command IQ taking according actions
 // over the associated stream.
-func (x *BlockingCommand) ProcessIQ(iq *xml.IQ) {
+func (x *BlockingCommand) ProcessIQ(iq *xml.IQ, stm stream.C2S) {
 	if iq.IsGet() {
-		x.sendBlockList(iq)
+		x.sendBlockList(iq, stm)
 	} else if iq.IsSet() {
 		e := iq.Elements()
 		if block := e.ChildNamespace("block", blockingCommandNamespace); block != nil {
-			x.block(iq, block)
+			x.block(iq, block, stm)
 		} else if unblock := e.ChildNamespace("unblock", blockingCommandNamespace); unblock != nil {
-			x.unblock(iq, unblock)
+			x.unblock(iq, unblock, stm)
 		}
 	}
 }
 
-func (x *BlockingCommand) sendBlockList(iq *xml.IQ) {
-	blItms, err := storage.Instance().FetchBlockListItems(x.stm.Username())
+func (x *BlockingCommand) sendBlockList(iq *xml.IQ, stm stream.C2S) {
+	blItms, err := storage.Instance().FetchBlockListItems(stm.Username(), stm.Domain())
 	if err != nil {
 		log.Error(err)
-		x.stm.SendElement(iq.InternalServerError())
+		stm.SendElement(iq.InternalServerError())
 		return
 	}
 	blockList := xml.NewElementNamespace("blocklist", blockingCommandNamespace)
@@ -82,60 +88,60 @@ func (x *BlockingCommand) sendBlockList(iq *xml.IQ) {
 	}
 	reply := iq.ResultIQ()
 	reply.AppendElement(blockList)
-	x.stm.SendElement(reply)
+	stm.SendElement(reply)
 
-	x.stm.Context().SetBool(true, xep191RequestedContextKey)
+	stm.Context().SetBool(true, xep191RequestedContextKey)
 }
 
-func (x *BlockingCommand) block(iq *xml.IQ, block xml.XElement) {
+func (x *BlockingCommand) block(iq *xml.IQ, block xml.XElement, stm stream.C2S) {
 	var bl []model.BlockListItem
 
 	items := block.Elements().Children("item")
 	if len(items) == 0 {
-		x.stm.SendElement(iq.BadRequestError())
+		stm.SendElement(iq.BadRequestError())
 		return
 	}
 	jds, err := x.extractItemJIDs(items)
 	if err != nil {
 		log.Error(err)
-		x.stm.SendElement(iq.JidMalformedError())
+		stm.SendElement(iq.JidMalformedError())
 		return
 	}
-	blItems, ris, err := x.fetchBlockListAndRosterItems()
+	blItems, ris, err := x.fetchBlockListAndRosterItems(stm)
 	if err != nil {
 		log.Error(err)
-		x.stm.SendElement(iq.InternalServerError())
+		stm.SendElement(iq.InternalServerError())
 		return
 	}
 	for _, j := range jds {
 		if !x.isJIDInBlockList(j, blItems) {
-			x.broadcastPresenceMatchingJID(j, ris, xml.UnavailableType)
-			bl = append(bl, model.BlockListItem{Username: x.stm.Username(), JID: j.String()})
+			x.broadcastPresenceMatchingJID(j, ris, xml.UnavailableType, stm)
+			bl = append(bl, model.BlockListItem{Username: stm.Username(), Domain: stm.Domain(), JID: j.String()})
 		}
 	}
 	if err := storage.Instance().InsertBlockListItems(bl); err != nil {
 		log.Error(err)
-		x.stm.SendElement(iq.InternalServerError())
+		stm.SendElement(iq.InternalServerError())
 		return
 	}
-	router.ReloadBlockList(x.stm.Username())
+	router.ReloadBlockList(stm.Username(), stm.Domain())
 
-	x.stm.SendElement(iq.ResultIQ())
-	x.pushIQ(block)
+	stm.SendElement(iq.ResultIQ())
+	x.pushIQ(block, stm)
 }
 
-func (x *BlockingCommand) unblock(iq *xml.IQ, unblock xml.XElement) {
+func (x *BlockingCommand) unblock(iq *xml.IQ, unblock xml.XElement, stm stream.C2S) {
 	items := unblock.Elements().Children("item")
 	jds, err := x.extractItemJIDs(items)
 	if err != nil {
 		log.Error(err)
-		x.stm.SendElement(iq.JidMalformedError())
+		stm.SendElement(iq.JidMalformedError())
 		return
 	}
-	blItems, ris, err := x.fetchBlockListAndRosterItems()
+	blItems, ris, err := x.fetchBlockListAndRosterItems(stm)
 	if err != nil {
 		log.Error(err)
-		x.stm.SendElement(iq.InternalServerError())
+		stm.SendElement(iq.InternalServerError())
 		return
 	}
 
@@ -143,48 +149,48 @@ func (x *BlockingCommand) unblock(iq *xml.IQ, unblock xml.XElement) {
 	if len(jds) == 0 {
 		for _, blItem := range blItems {
 			j, _ := jid.NewWithString(blItem.JID, true)
-			x.broadcastPresenceMatchingJID(j, ris, xml.AvailableType)
+			x.broadcastPresenceMatchingJID(j, ris, xml.AvailableType, stm)
 		}
 		bl = blItems
 
 	} else {
 		for _, j := range jds {
 			if x.isJIDInBlockList(j, blItems) {
-				x.broadcastPresenceMatchingJID(j, ris, xml.AvailableType)
-				bl = append(bl, model.BlockListItem{Username: x.stm.Username(), JID: j.String()})
+				x.broadcastPresenceMatchingJID(j, ris, xml.AvailableType, stm)
+				bl = append(bl, model.BlockListItem{Username: stm.Username(), Domain: stm.Domain(), JID: j.String()})
 			}
 		}
 	}
 	if err := storage.Instance().DeleteBlockListItems(bl); err != nil {
 		log.Error(err)
-		x.stm.SendElement(iq.InternalServerError())
+		stm.SendElement(iq.InternalServerError())
 		return
 	}
-	router.ReloadBlockList(x.stm.Username())
+	router.ReloadBlockList(stm.Username(), stm.Domain())
 
-	x.stm.SendElement(iq.ResultIQ())
-	x.pushIQ(unblock)
+	stm.SendElement(iq.ResultIQ())
+	x.pushIQ(unblock, stm)
 }
 
-func (x *BlockingCommand) pushIQ(elem xml.XElement) {
-	stms := router.UserStreams(x.stm.JID().Node())
-	for _, stm := range stms {
-		if !stm.Context().Bool(xep191RequestedContextKey) {
+func (x *BlockingCommand) pushIQ(elem xml.XElement, stm stream.C2S) {
+	stms := router.UserStreams(stm.Username(), stm.Domain())
+	for _, s := range stms {
+		if !s.Context().Bool(xep191RequestedContextKey) {
 			continue
 		}
 		iq := xml.NewIQType(uuid.New(), xml.SetType)
 		iq.AppendElement(elem)
-		stm.SendElement(iq)
+		s.SendElement(iq)
 	}
 }
 
-func (x *BlockingCommand) broadcastPresenceMatchingJID(jid *jid.JID, ris []rostermodel.Item, presenceType string) {
+func (x *BlockingCommand) broadcastPresenceMatchingJID(jid *jid.JID, ris []rostermodel.Item, presenceType string, stm stream.C2S) {
 	presences := roster.OnlinePresencesMatchingJID(jid)
 	for _, presence := range presences {
 		if !x.isSubscribedTo(presence.FromJID().ToBareJID(), ris) {
 			continue
 		}
-		p := xml.NewPresence(presence.FromJID(), x.stm.JID().ToBareJID(), presenceType)
+		p := xml.NewPresence(presence.FromJID(), stm.JID().ToBareJID(), presenceType)
 		if presenceType == xml.AvailableType {
 			p.AppendElements(presence.Elements().All())
 		}
@@ -211,12 +217,12 @@ func (x *BlockingCommand) isSubscribedTo(jid *jid.JID, ris []rostermodel.Item) b
 	return false
 }
 
-func (x *BlockingCommand) fetchBlockListAndRosterItems() ([]model.BlockListItem, []rostermodel.Item, error) {
-	blItms, err := storage.Instance().FetchBlockListItems(x.stm.Username())
+func (x *BlockingCommand) fetchBlockListAndRosterItems(stm stream.C2S) ([]model.BlockListItem, []rostermodel.Item, error) {
+	blItms, err := storage.Instance().FetchBlockListItems(stm.Username(), stm.Domain())
 	if err != nil {
 		return nil, nil, err
 	}
-	ris, _, err := storage.Instance().FetchRosterItems(x.stm.Username())
+	ris, _, err := storage.Instance().FetchRosterItems(stm.Username(), stm.Domain())
 	if err != nil {
 		return nil, nil, err
 	}
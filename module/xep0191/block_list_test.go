@@ -24,7 +24,7 @@ import (
 func TestXEP0191_Matching(t *testing.T) {
 	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
 
-	x := New(nil)
+	x := New()
 
 	// test MatchesIQ
 	iq1 := xml.NewIQType(uuid.New(), xml.GetType)
@@ -55,13 +55,15 @@ func TestXEP0191_GetBlockList(t *testing.T) {
 	stm := stream.NewMockC2S(uuid.New(), j)
 	defer stm.Disconnect(nil)
 
-	x := New(stm)
+	x := New()
 
 	storage.Instance().InsertBlockListItems([]model.BlockListItem{{
 		Username: "ortuman",
+		Domain:   "jackal.im",
 		JID:      "hamlet@jackal.im/garden",
 	}, {
 		Username: "ortuman",
+		Domain:   "jackal.im",
 		JID:      "jabber.org",
 	}})
 
@@ -70,7 +72,7 @@ func TestXEP0191_GetBlockList(t *testing.T) {
 	iq1.SetToJID(j)
 	iq1.AppendElement(xml.NewElementNamespace("blocklist", blockingCommandNamespace))
 
-	x.ProcessIQ(iq1)
+	x.ProcessIQ(iq1, stm)
 	elem := stm.FetchElement()
 	bl := elem.Elements().ChildNamespace("blocklist", blockingCommandNamespace)
 	require.NotNil(t, bl)
@@ -79,7 +81,7 @@ func TestXEP0191_GetBlockList(t *testing.T) {
 	require.True(t, stm.Context().Bool(xep191RequestedContextKey))
 
 	storage.ActivateMockedError()
-	x.ProcessIQ(iq1)
+	x.ProcessIQ(iq1, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, xml.ErrInternalServerError.Error(), elem.Error().Elements().All()[0].Name())
 	storage.DeactivateMockedError()
@@ -97,7 +99,7 @@ func TestXEP191_BlockAndUnblock(t *testing.T) {
 
 	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
 	stm1 := stream.NewMockC2S(uuid.New(), j1)
-	x := New(stm1)
+	x := New()
 
 	j2, _ := jid.New("ortuman", "jackal.im", "yard", true)
 	stm2 := stream.NewMockC2S(uuid.New(), j2)
@@ -136,6 +138,7 @@ func TestXEP191_BlockAndUnblock(t *testing.T) {
 
 	storage.Instance().InsertOrUpdateRosterItem(&rostermodel.Item{
 		Username:     "ortuman",
+		Domain:       "jackal.im",
 		JID:          "romeo@jackal.im",
 		Subscription: "both",
 	})
@@ -147,7 +150,7 @@ func TestXEP191_BlockAndUnblock(t *testing.T) {
 	block := xml.NewElementNamespace("block", blockingCommandNamespace)
 	iq.AppendElement(block)
 
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm1)
 	elem := stm1.FetchElement()
 	require.Equal(t, xml.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
 
@@ -159,12 +162,12 @@ func TestXEP191_BlockAndUnblock(t *testing.T) {
 
 	// TEST BLOCK
 	storage.ActivateMockedError()
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm1)
 	elem = stm1.FetchElement()
 	require.Equal(t, xml.ErrInternalServerError.Error(), elem.Error().Elements().All()[0].Name())
 	storage.DeactivateMockedError()
 
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm1)
 
 	// unavailable presence from *@jackal.im/jail
 	elem = stm1.FetchElement()
@@ -198,7 +201,7 @@ func TestXEP191_BlockAndUnblock(t *testing.T) {
 	require.Equal(t, xml.SetType, elem.Type())
 
 	// check storage
-	bl, _ := storage.Instance().FetchBlockListItems("ortuman")
+	bl, _ := storage.Instance().FetchBlockListItems("ortuman", "jackal.im")
 	require.NotNil(t, bl)
 	require.Equal(t, 1, len(bl))
 	require.Equal(t, "jackal.im/jail", bl[0].JID)
@@ -215,12 +218,12 @@ func TestXEP191_BlockAndUnblock(t *testing.T) {
 	iq.AppendElement(unblock)
 
 	storage.ActivateMockedError()
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm1)
 	elem = stm1.FetchElement()
 	require.Equal(t, xml.ErrInternalServerError.Error(), elem.Error().Elements().All()[0].Name())
 	storage.DeactivateMockedError()
 
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm1)
 
 	// receive available presence from *@jackal.im/jail
 	elem = stm1.FetchElement()
@@ -246,9 +249,11 @@ func TestXEP191_BlockAndUnblock(t *testing.T) {
 	// test full unblock
 	storage.Instance().InsertBlockListItems([]model.BlockListItem{{
 		Username: "ortuman",
+		Domain:   "jackal.im",
 		JID:      "hamlet@jackal.im/garden",
 	}, {
 		Username: "ortuman",
+		Domain:   "jackal.im",
 		JID:      "jabber.org",
 	}})
 
@@ -259,8 +264,8 @@ func TestXEP191_BlockAndUnblock(t *testing.T) {
 	unblock = xml.NewElementNamespace("unblock", blockingCommandNamespace)
 	iq.AppendElement(unblock)
 
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm1)
 
-	blItms, _ := storage.Instance().FetchBlockListItems("ortuman")
+	blItms, _ := storage.Instance().FetchBlockListItems("ortuman", "jackal.im")
 	require.Equal(t, 0, len(blItms))
 }
@@ -7,11 +7,11 @@ package xep0199
 
 import (
 	"sync"
-	"sync/atomic"
 	"time"
 
 	"github.com/ortuman/jackal/errors"
 	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/module"
 	"github.com/ortuman/jackal/module/xep0030"
 	"github.com/ortuman/jackal/stream"
 	"github.com/ortuman/jackal/xml"
@@ -26,35 +26,46 @@ type Config struct {
 	SendInterval int  `yaml:"send_interval"`
 }
 
-// Ping represents a ping server stream module.
-type Ping struct {
-	cfg *Config
-	stm stream.C2S
+func init() {
+	module.Register("ping", func(_ string, cfg *module.Config) (module.Module, error) {
+		var config Config
+		if err := cfg.Decode("ping", &config); err != nil {
+			return nil, err
+		}
+		return New(&config), nil
+	})
+}
 
+type pinger struct {
+	stm    stream.C2S
 	pingTm *time.Timer
 	pongCh chan struct{}
+	doneCh chan struct{}
+}
 
-	pingMu sync.RWMutex // guards 'pingID'
-	pingId string
+// Ping represents a ping server module.
+type Ping struct {
+	cfg *Config
 
-	waitingPing uint32
-	pingOnce    sync.Once
+	mu      sync.RWMutex
+	pingers map[string]*pinger // indexed by stream identifier
+	pings   map[string]*pinger // indexed by sent ping identifier
 }
 
 // New returns an ping IQ handler module.
-func New(config *Config, stm stream.C2S) *Ping {
+func New(config *Config) *Ping {
 	return &Ping{
-		cfg:    config,
-		stm:    stm,
-		pongCh: make(chan struct{}, 1),
+		cfg:     config,
+		pingers: make(map[string]*pinger),
+		pings:   make(map[string]*pinger),
 	}
 }
 
 // RegisterDisco registers disco entity features/items
 // associated to ping module.
 func (x *Ping) RegisterDisco(discoInfo *xep0030.DiscoInfo) {
-	discoInfo.Entity(x.stm.Domain(), "").AddFeature(pingNamespace)
-	discoInfo.Entity(x.stm.JID().ToBareJID().String(), "").AddFeature(pingNamespace)
+	discoInfo.ServerEntity().AddFeature(pingNamespace)
+	discoInfo.AccountEntity().AddFeature(pingNamespace)
 }
 
 // MatchesIQ returns whether or not an IQ should be
@@ -65,90 +76,130 @@ func (x *Ping) MatchesIQ(iq *xml.IQ) bool {
 
 // ProcessIQ processes a ping IQ taking according actions
 // over the associated stream.
-func (x *Ping) ProcessIQ(iq *xml.IQ) {
+func (x *Ping) ProcessIQ(iq *xml.IQ, stm stream.C2S) {
 	if x.isPongIQ(iq) {
 		x.handlePongIQ(iq)
 		return
 	}
 	toJid := iq.ToJID()
-	if !toJid.IsServer() && toJid.Node() != x.stm.Username() {
-		x.stm.SendElement(iq.ForbiddenError())
+	if !toJid.IsServer() && toJid.Node() != stm.Username() {
+		stm.SendElement(iq.ForbiddenError())
 		return
 	}
 	p := iq.Elements().ChildNamespace("ping", pingNamespace)
 	if p == nil || p.Elements().Count() > 0 {
-		x.stm.SendElement(iq.BadRequestError())
+		stm.SendElement(iq.BadRequestError())
 		return
 	}
 	log.Infof("received ping... id: %s", iq.ID())
 	if iq.IsGet() {
 		log.Infof("sent pong... id: %s", iq.ID())
-		x.stm.SendElement(iq.ResultIQ())
+		stm.SendElement(iq.ResultIQ())
 	} else {
-		x.stm.SendElement(iq.BadRequestError())
+		stm.SendElement(iq.BadRequestError())
 	}
 }
 
-// StartPinging starts pinging peer every 'send interval' period.
-func (x *Ping) StartPinging() {
-	if x.cfg.Send {
-		x.pingOnce.Do(func() {
-			x.pingTm = time.AfterFunc(time.Second*time.Duration(x.cfg.SendInterval), x.sendPing)
-		})
+// StreamStarted starts pinging peer every 'send interval' period.
+func (x *Ping) StreamStarted(stm stream.C2S) {
+	if !x.cfg.Send {
+		return
+	}
+	p := &pinger{
+		stm:    stm,
+		pongCh: make(chan struct{}, 1),
+		doneCh: make(chan struct{}),
+	}
+	x.mu.Lock()
+	if _, ok := x.pingers[stm.ID()]; ok {
+		x.mu.Unlock()
+		return
 	}
+	x.pingers[stm.ID()] = p
+	p.pingTm = time.AfterFunc(x.sendInterval(), func() { x.sendPing(p) })
+	x.mu.Unlock()
 }
 
-// ResetDeadline resets send ping deadline.
-func (x *Ping) ResetDeadline() {
-	if x.cfg.Send && atomic.LoadUint32(&x.waitingPing) == 1 {
-		x.pingTm.Reset(time.Second * time.Duration(x.cfg.SendInterval))
+// StreamClosed stops pinging a closed stream.
+func (x *Ping) StreamClosed(stm stream.C2S) {
+	x.mu.Lock()
+	p := x.pingers[stm.ID()]
+	if p == nil {
+		x.mu.Unlock()
 		return
 	}
+	delete(x.pingers, stm.ID())
+	for id, pp := range x.pings {
+		if pp == p {
+			delete(x.pings, id)
+		}
+	}
+	x.mu.Unlock()
+
+	p.pingTm.Stop()
+	close(p.doneCh)
 }
 
 func (x *Ping) isPongIQ(iq *xml.IQ) bool {
-	x.pingMu.RLock()
-	defer x.pingMu.RUnlock()
-	return x.pingId == iq.ID() && (iq.IsResult() || iq.Type() == xml.ErrorType)
+	if !iq.IsResult() && iq.Type() != xml.ErrorType {
+		return false
+	}
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	_, ok := x.pings[iq.ID()]
+	return ok
 }
 
-func (x *Ping) sendPing() {
-	atomic.StoreUint32(&x.waitingPing, 0)
+func (x *Ping) sendPing(p *pinger) {
+	pingID := uuid.New()
 
-	x.pingMu.Lock()
-	x.pingId = uuid.New()
-	pingId := x.pingId
-	x.pingMu.Unlock()
+	x.mu.Lock()
+	x.pings[pingID] = p
+	x.mu.Unlock()
 
-	iq := xml.NewIQType(pingId, xml.GetType)
-	iq.SetTo(x.stm.JID().String())
+	iq := xml.NewIQType(pingID, xml.GetType)
+	iq.SetToJID(p.stm.JID())
 	iq.AppendElement(xml.NewElementNamespace("ping", pingNamespace))
 
-	x.stm.SendElement(iq)
+	p.stm.SendElement(iq)
 
-	log.Infof("sent ping... id: %s", pingId)
+	log.Infof("sent ping... id: %s", pingID)
 
-	x.waitForPong()
+	x.waitForPong(p, pingID)
 }
 
-func (x *Ping) waitForPong() {
-	t := time.NewTimer(time.Second * time.Duration(x.cfg.SendInterval))
+func (x *Ping) waitForPong(p *pinger, pingID string) {
+	t := time.NewTimer(x.sendInterval())
+	defer t.Stop()
+
 	select {
-	case <-x.pongCh:
-		return
+	case <-p.pongCh:
+		p.pingTm.Reset(x.sendInterval())
 	case <-t.C:
-		x.stm.Disconnect(streamerror.ErrConnectionTimeout)
+		x.mu.Lock()
+		delete(x.pings, pingID)
+		x.mu.Unlock()
+		p.stm.Disconnect(streamerror.ErrConnectionTimeout)
+	case <-p.doneCh:
 	}
 }
 
 func (x *Ping) handlePongIQ(iq *xml.IQ) {
 	log.Infof("received pong... id: %s", iq.ID())
 
-	x.pingMu.Lock()
-	x.pingId = ""
-	x.pingMu.Unlock()
+	x.mu.Lock()
+	p := x.pings[iq.ID()]
+	delete(x.pings, iq.ID())
+	x.mu.Unlock()
+
+	if p != nil {
+		select {
+		case p.pongCh <- struct{}{}:
+		default:
+		}
+	}
+}
 
-	x.pongCh <- struct{}{}
-	x.pingTm.Reset(time.Second * time.Duration(x.cfg.SendInterval))
-	atomic.StoreUint32(&x.waitingPing, 1)
+func (x *Ping) sendInterval() time.Duration {
+	return time.Second * time.Duration(x.cfg.SendInterval)
 }
@@ -19,7 +19,7 @@ func TestXEP0199_Matching(t *testing.T) {
 	t.Parallel()
 	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
 
-	x := New(&Config{}, nil)
+	x := New(&Config{})
 
 	// test MatchesIQ
 	iqID := uuid.New()
@@ -42,31 +42,31 @@ func TestXEP0199_ReceivePing(t *testing.T) {
 
 	stm.SetUsername("ortuman")
 
-	x := New(&Config{}, stm)
+	x := New(&Config{})
 
 	iqID := uuid.New()
 	iq := xml.NewIQType(iqID, xml.SetType)
 	iq.SetFromJID(j2)
 	iq.SetToJID(j2)
 
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem := stm.FetchElement()
 	require.Equal(t, xml.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
 
 	iq.SetToJID(j1)
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, xml.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
 
 	ping := xml.NewElementNamespace("ping", pingNamespace)
 	iq.AppendElement(ping)
 
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, xml.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
 
 	iq.SetType(xml.GetType)
-	x.ProcessIQ(iq)
+	x.ProcessIQ(iq, stm)
 	elem = stm.FetchElement()
 	require.Equal(t, iqID, elem.ID())
 }
@@ -80,9 +80,10 @@ func TestXEP0199_SendPing(t *testing.T) {
 
 	stm.SetUsername("ortuman")
 
-	x := New(&Config{Send: true, SendInterval: 1}, stm)
+	x := New(&Config{Send: true, SendInterval: 1})
 
-	x.StartPinging()
+	x.StreamStarted(stm)
+	defer x.StreamClosed(stm)
 
 	// wait for ping...
 	elem := stm.FetchElement()
@@ -91,8 +92,9 @@ func TestXEP0199_SendPing(t *testing.T) {
 	require.NotNil(t, elem.Elements().ChildNamespace("ping", pingNamespace))
 
 	// send pong...
-	x.ProcessIQ(xml.NewIQType(elem.ID(), xml.ResultType))
-	x.ResetDeadline()
+	pong := xml.NewIQType(elem.ID(), xml.ResultType)
+	require.True(t, x.MatchesIQ(pong))
+	x.ProcessIQ(pong, stm)
 
 	// wait next ping...
 	elem = stm.FetchElement()
@@ -114,9 +116,10 @@ func TestXEP0199_Disconnect(t *testing.T) {
 
 	stm.SetUsername("ortuman")
 
-	x := New(&Config{Send: true, SendInterval: 1}, stm)
+	x := New(&Config{Send: true, SendInterval: 1})
 
-	x.StartPinging()
+	x.StreamStarted(stm)
+	defer x.StreamClosed(stm)
 
 	// wait next ping...
 	elem := stm.FetchElement()
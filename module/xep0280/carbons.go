@@ -0,0 +1,124 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0280
+
+import (
+	"github.com/ortuman/jackal/module"
+	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+	"github.com/pborman/uuid"
+)
+
+const (
+	carbonsNamespace = "urn:xmpp:carbons:2"
+	forwardNamespace = "urn:xmpp:forward:0"
+	hintsNamespace   = "urn:xmpp:hints"
+)
+
+const carbonsEnabledCtxKey = "carbons:enabled"
+
+func init() {
+	module.Register("carbons", func(_ string, _ *module.Config) (module.Module, error) {
+		return New(), nil
+	})
+}
+
+// Carbons represents a message carbons server module.
+type Carbons struct {
+}
+
+// New returns a message carbons IQ handler module.
+func New() *Carbons {
+	return &Carbons{}
+}
+
+// RegisterDisco registers disco entity features/items
+// associated to message carbons module.
+func (x *Carbons) RegisterDisco(discoInfo *xep0030.DiscoInfo) {
+	discoInfo.ServerEntity().AddFeature(carbonsNamespace)
+}
+
+// MatchesIQ returns whether or not an IQ should be
+// processed by the message carbons module.
+func (x *Carbons) MatchesIQ(iq *xml.IQ) bool {
+	e := iq.Elements()
+	return iq.IsSet() && (e.ChildNamespace("enable", carbonsNamespace) != nil || e.ChildNamespace("disable", carbonsNamespace) != nil)
+}
+
+// ProcessIQ processes a message carbons IQ taking according actions
+// over the associated stream.
+func (x *Carbons) ProcessIQ(iq *xml.IQ, stm stream.C2S) {
+	toJID := iq.ToJID()
+	if !toJID.IsServer() && !stm.JID().Matches(toJID, jid.MatchesBare) {
+		stm.SendElement(iq.ForbiddenError())
+		return
+	}
+	enabled := iq.Elements().ChildNamespace("enable", carbonsNamespace) != nil
+	stm.Context().SetBool(enabled, carbonsEnabledCtxKey)
+	stm.SendElement(iq.ResultIQ())
+}
+
+// ProcessMessage forwards a message sent by the originating stream
+// to every other user's resource with carbons enabled.
+func (x *Carbons) ProcessMessage(message *xml.Message, stm stream.C2S) {
+	if !isCarbonable(message) {
+		return
+	}
+	sendCopies("sent", message, stm.JID().ToBareJID(), stm)
+}
+
+// ProcessDeliveredMessage forwards a message delivered to a stream
+// to every other recipient's resource with carbons enabled.
+func (x *Carbons) ProcessDeliveredMessage(message *xml.Message, stm stream.C2S) {
+	if !isCarbonable(message) {
+		return
+	}
+	sendCopies("received", message, stm.JID().ToBareJID(), stm)
+}
+
+// IsEnabled returns whether or not carbons have been
+// enabled for a given stream.
+func IsEnabled(stm stream.C2S) bool {
+	return stm.Context().Bool(carbonsEnabledCtxKey)
+}
+
+func sendCopies(direction string, message *xml.Message, userJID *jid.JID, exclude stream.C2S) {
+	for _, stm := range router.UserStreams(userJID.Node(), userJID.Domain()) {
+		if stm == exclude || !IsEnabled(stm) {
+			continue
+		}
+		stm.SendElement(carbonCopy(direction, message, userJID, stm.JID()))
+	}
+}
+
+func carbonCopy(direction string, message *xml.Message, from, to *jid.JID) *xml.Message {
+	forwarded := xml.NewElementNamespace("forwarded", forwardNamespace)
+	forwarded.AppendElement(xml.NewElementFromElement(message))
+
+	elem := xml.NewElementNamespace(direction, carbonsNamespace)
+	elem.AppendElement(forwarded)
+
+	m := xml.NewMessageType(uuid.New(), xml.ChatType)
+	m.SetFromJID(from)
+	m.SetToJID(to)
+	m.AppendElement(elem)
+	return m
+}
+
+func isCarbonable(message *xml.Message) bool {
+	if !message.IsChat() {
+		return false
+	}
+	e := message.Elements()
+	if e.ChildNamespace("private", carbonsNamespace) != nil || e.ChildNamespace("no-copy", hintsNamespace) != nil {
+		return false
+	}
+	// do not copy already forwarded carbons
+	return e.ChildNamespace("sent", carbonsNamespace) == nil && e.ChildNamespace("received", carbonsNamespace) == nil
+}
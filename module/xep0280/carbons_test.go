@@ -0,0 +1,163 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0280
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXEP0280_Matching(t *testing.T) {
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+
+	x := New()
+
+	iq := xml.NewIQType(uuid.New(), xml.SetType)
+	iq.SetFromJID(j)
+	iq.SetToJID(j.ToBareJID())
+	require.False(t, x.MatchesIQ(iq))
+
+	iq.AppendElement(xml.NewElementNamespace("enable", carbonsNamespace))
+	require.True(t, x.MatchesIQ(iq))
+
+	iq.SetType(xml.GetType)
+	require.False(t, x.MatchesIQ(iq))
+
+	iq = xml.NewIQType(uuid.New(), xml.SetType)
+	iq.SetFromJID(j)
+	iq.SetToJID(j.ToBareJID())
+	iq.AppendElement(xml.NewElementNamespace("disable", carbonsNamespace))
+	require.True(t, x.MatchesIQ(iq))
+}
+
+func TestXEP0280_EnableAndDisable(t *testing.T) {
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "", true)
+
+	stm := stream.NewMockC2S(uuid.New(), j1)
+	defer stm.Disconnect(nil)
+
+	x := New()
+
+	iq := xml.NewIQType(uuid.New(), xml.SetType)
+	iq.SetFromJID(j1)
+	iq.SetToJID(j1.ToBareJID())
+	iq.AppendElement(xml.NewElementNamespace("enable", carbonsNamespace))
+	x.ProcessIQ(iq, stm)
+	elem := stm.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+	require.True(t, IsEnabled(stm))
+
+	iq = xml.NewIQType(uuid.New(), xml.SetType)
+	iq.SetFromJID(j1)
+	iq.SetToJID(j1.ToBareJID())
+	iq.AppendElement(xml.NewElementNamespace("disable", carbonsNamespace))
+	x.ProcessIQ(iq, stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+	require.False(t, IsEnabled(stm))
+
+	// not allowed
+	iq = xml.NewIQType(uuid.New(), xml.SetType)
+	iq.SetFromJID(j1)
+	iq.SetToJID(j2)
+	iq.AppendElement(xml.NewElementNamespace("enable", carbonsNamespace))
+	x.ProcessIQ(iq, stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+}
+
+func TestXEP0280_SendCopies(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	router.Initialize(&router.Config{})
+	defer func() {
+		router.Shutdown()
+		host.Shutdown()
+	}()
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("ortuman", "jackal.im", "garden", true)
+	j3, _ := jid.New("ortuman", "jackal.im", "yard", true)
+	j4, _ := jid.New("noelia", "jackal.im", "chamber", true)
+
+	stm1 := stream.NewMockC2S(uuid.New(), j1)
+	stm2 := stream.NewMockC2S(uuid.New(), j2)
+	stm3 := stream.NewMockC2S(uuid.New(), j3)
+	defer func() {
+		stm1.Disconnect(nil)
+		stm2.Disconnect(nil)
+		stm3.Disconnect(nil)
+	}()
+	router.Bind(stm1)
+	router.Bind(stm2)
+	router.Bind(stm3)
+
+	stm1.Context().SetBool(true, carbonsEnabledCtxKey)
+	stm2.Context().SetBool(true, carbonsEnabledCtxKey)
+
+	// sent copies
+	x := New()
+
+	msg := tUtilMessage(j1, j4)
+	x.ProcessMessage(msg, stm1)
+
+	elem := stm2.FetchElement()
+	require.Equal(t, "message", elem.Name())
+	require.Equal(t, "ortuman@jackal.im", elem.From())
+	require.Equal(t, j2.String(), elem.To())
+	sent := elem.Elements().ChildNamespace("sent", carbonsNamespace)
+	require.NotNil(t, sent)
+	fwd := sent.Elements().ChildNamespace("forwarded", forwardNamespace)
+	require.NotNil(t, fwd)
+	require.Equal(t, msg.ID(), fwd.Elements().Child("message").ID())
+
+	// received copies
+	msg = tUtilMessage(j4, j2)
+	x.ProcessDeliveredMessage(msg, stm2)
+
+	elem = stm1.FetchElement()
+	received := elem.Elements().ChildNamespace("received", carbonsNamespace)
+	require.NotNil(t, received)
+	require.Equal(t, msg.ID(), received.Elements().ChildNamespace("forwarded", forwardNamespace).Elements().Child("message").ID())
+
+	// private messages
+	msg = tUtilMessage(j4, j2)
+	msg.AppendElement(xml.NewElementNamespace("private", carbonsNamespace))
+	x.ProcessDeliveredMessage(msg, stm2)
+
+	msg = tUtilMessage(j4, j2)
+	msg.AppendElement(xml.NewElementNamespace("no-copy", hintsNamespace))
+	x.ProcessDeliveredMessage(msg, stm2)
+
+	msg = tUtilMessage(j4, j2)
+	msg.SetType(xml.GroupChatType)
+	x.ProcessDeliveredMessage(msg, stm2)
+
+	msg = tUtilMessage(j4, j2)
+	x.ProcessDeliveredMessage(msg, stm2)
+
+	elem = stm1.FetchElement()
+	received = elem.Elements().ChildNamespace("received", carbonsNamespace)
+	require.NotNil(t, received)
+	require.Equal(t, msg.ID(), received.Elements().ChildNamespace("forwarded", forwardNamespace).Elements().Child("message").ID())
+}
+
+func tUtilMessage(from, to *jid.JID) *xml.Message {
+	msg := xml.NewMessageType(uuid.New(), xml.ChatType)
+	msg.SetFromJID(from)
+	msg.SetToJID(to)
+	body := xml.NewElementName("body")
+	body.SetText("Hi!")
+	msg.AppendElement(body)
+	return msg
+}
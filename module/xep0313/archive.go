@@ -0,0 +1,133 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0313
+
+import (
+	"time"
+
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/model/mammodel"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+	"github.com/pborman/uuid"
+)
+
+const (
+	stanzaIDNamespace = "urn:xmpp:sid:0"
+	hintsNamespace    = "urn:xmpp:hints"
+)
+
+// ProcessRoutedMessage stores a routed message into local host's sender
+// and recipient archives according to their archiving preferences.
+// Recipient's archived copy identifier is attached to the message
+// as a stanza-id element before being delivered.
+func (x *MAM) ProcessRoutedMessage(message *xml.Message) {
+	if !isArchivable(message) {
+		return
+	}
+	fromJID := message.FromJID()
+	toJID := message.ToJID()
+
+	// strip any spoofed stanza-id
+	stripStanzaIDs(message, toJID.ToBareJID().String())
+
+	if len(fromJID.Node()) > 0 && fromJID.Domain() == x.domain {
+		archive(fromJID.Node(), fromJID.Domain(), toJID.ToBareJID(), message)
+	}
+	if len(toJID.Node()) > 0 && toJID.Domain() == x.domain {
+		if id := archive(toJID.Node(), toJID.Domain(), fromJID.ToBareJID(), message); len(id) > 0 {
+			sid := xml.NewElementNamespace("stanza-id", stanzaIDNamespace)
+			sid.SetAttribute("id", id)
+			sid.SetAttribute("by", toJID.ToBareJID().String())
+			message.AppendElement(sid)
+		}
+	}
+}
+
+func archive(username, domain string, peer *jid.JID, message *xml.Message) string {
+	ok, err := shouldArchive(username, domain, peer)
+	if err != nil {
+		log.Error(err)
+		return ""
+	}
+	if !ok {
+		return ""
+	}
+	m := &mammodel.Message{
+		ID:        uuid.New(),
+		Username:  username,
+		Domain:    domain,
+		JID:       peer.String(),
+		Message:   xml.NewElementFromElement(message),
+		CreatedAt: time.Now(),
+	}
+	if err := storage.Instance().InsertArchiveMessage(m); err != nil {
+		log.Error(err)
+		return ""
+	}
+	return m.ID
+}
+
+func shouldArchive(username, domain string, peer *jid.JID) (bool, error) {
+	prefs, err := storage.Instance().FetchArchivePrefs(username, domain)
+	if err != nil {
+		return false, err
+	}
+	if prefs == nil {
+		return true, nil
+	}
+	peerJID := peer.String()
+	for _, j := range prefs.Never {
+		if j == peerJID {
+			return false, nil
+		}
+	}
+	for _, j := range prefs.Always {
+		if j == peerJID {
+			return true, nil
+		}
+	}
+	switch prefs.Default {
+	case mammodel.DefaultNever:
+		return false, nil
+	case mammodel.DefaultRoster:
+		ri, err := storage.Instance().FetchRosterItem(username, domain, peerJID)
+		if err != nil {
+			return false, err
+		}
+		return ri != nil, nil
+	}
+	return true, nil
+}
+
+func isArchivable(message *xml.Message) bool {
+	if !(message.IsChat() || message.IsNormal()) || !message.IsMessageWithBody() {
+		return false
+	}
+	return message.Elements().ChildNamespace("no-store", hintsNamespace) == nil
+}
+
+func stripStanzaIDs(message *xml.Message, by string) {
+	var spoofed bool
+	for _, sid := range message.Elements().ChildrenNamespace("stanza-id", stanzaIDNamespace) {
+		if sid.Attributes().Get("by") == by {
+			spoofed = true
+			break
+		}
+	}
+	if !spoofed {
+		return
+	}
+	elems := message.Elements().All()
+	message.ClearElements()
+	for _, elem := range elems {
+		if elem.Name() == "stanza-id" && elem.Namespace() == stanzaIDNamespace && elem.Attributes().Get("by") == by {
+			continue
+		}
+		message.AppendElement(elem)
+	}
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0313
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/model/mammodel"
+	"github.com/ortuman/jackal/model/rostermodel"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/storage/memstorage"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXEP0313_ArchiveMessage(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	defer func() {
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "garden", true)
+
+	x := New("jackal.im")
+
+	// not archivable
+	msg := tUtilMessage(j1, j2, "hi!")
+	msg.SetType(xml.GroupChatType)
+	x.ProcessRoutedMessage(msg)
+	msg = tUtilMessage(j1, j2, "hi!")
+	msg.AppendElement(xml.NewElementNamespace("no-store", hintsNamespace))
+	x.ProcessRoutedMessage(msg)
+
+	rs, _ := storage.Instance().FetchArchiveMessages("ortuman", "jackal.im", nil, nil)
+	require.Equal(t, 0, len(rs.Messages))
+
+	// spoofed stanza-id
+	msg = tUtilMessage(j1, j2, "hi!")
+	sid := xml.NewElementNamespace("stanza-id", stanzaIDNamespace)
+	sid.SetAttribute("id", "spoofed")
+	sid.SetAttribute("by", "noelia@jackal.im")
+	msg.AppendElement(sid)
+	x.ProcessRoutedMessage(msg)
+
+	sids := msg.Elements().ChildrenNamespace("stanza-id", stanzaIDNamespace)
+	require.Equal(t, 1, len(sids))
+	require.NotEqual(t, "spoofed", sids[0].Attributes().Get("id"))
+
+	rs, _ = storage.Instance().FetchArchiveMessages("ortuman", "jackal.im", nil, nil)
+	require.Equal(t, 1, len(rs.Messages))
+	require.Equal(t, "noelia@jackal.im", rs.Messages[0].JID)
+
+	rs, _ = storage.Instance().FetchArchiveMessages("noelia", "jackal.im", nil, nil)
+	require.Equal(t, 1, len(rs.Messages))
+	require.Equal(t, "ortuman@jackal.im", rs.Messages[0].JID)
+	require.Equal(t, sids[0].Attributes().Get("id"), rs.Messages[0].ID)
+
+	// remote recipient
+	j3, _ := jid.New("romeo", "jabber.org", "orchard", true)
+	msg = tUtilMessage(j1, j3, "hi!")
+	x.ProcessRoutedMessage(msg)
+	require.Nil(t, msg.Elements().ChildNamespace("stanza-id", stanzaIDNamespace))
+
+	rs, _ = storage.Instance().FetchArchiveMessages("ortuman", "jackal.im", nil, nil)
+	require.Equal(t, 2, len(rs.Messages))
+
+	// other hosts' users are left to their own host module
+	New("example.org").ProcessRoutedMessage(tUtilMessage(j1, j2, "hi!"))
+
+	rs, _ = storage.Instance().FetchArchiveMessages("ortuman", "jackal.im", nil, nil)
+	require.Equal(t, 2, len(rs.Messages))
+}
+
+func TestXEP0313_ArchivePrefs(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	defer func() {
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+	j1, _ := jid.New("ortuman", "jackal.im", "", true)
+	j2, _ := jid.New("noelia", "jackal.im", "", true)
+	j3, _ := jid.New("romeo", "jackal.im", "", true)
+
+	ok, err := shouldArchive("ortuman", "jackal.im", j2)
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	storage.Instance().InsertOrUpdateArchivePrefs(&mammodel.Prefs{
+		Username: "ortuman",
+		Domain:   "jackal.im",
+		Default:  mammodel.DefaultRoster,
+		Never:    []string{"romeo@jackal.im"},
+	})
+	ok, _ = shouldArchive("ortuman", "jackal.im", j2)
+	require.False(t, ok)
+
+	storage.Instance().InsertOrUpdateRosterItem(&rostermodel.Item{
+		Username:     "ortuman",
+		Domain:       "jackal.im",
+		JID:          "noelia@jackal.im",
+		Subscription: rostermodel.SubscriptionBoth,
+	})
+	ok, _ = shouldArchive("ortuman", "jackal.im", j2)
+	require.True(t, ok)
+
+	storage.Instance().InsertOrUpdateArchivePrefs(&mammodel.Prefs{
+		Username: "ortuman",
+		Domain:   "jackal.im",
+		Default:  mammodel.DefaultNever,
+		Always:   []string{"romeo@jackal.im"},
+	})
+	ok, _ = shouldArchive("ortuman", "jackal.im", j2)
+	require.False(t, ok)
+	ok, _ = shouldArchive("ortuman", "jackal.im", j3)
+	require.True(t, ok)
+
+	// storage error
+	storage.ActivateMockedError()
+	_, err = shouldArchive("ortuman", "jackal.im", j1)
+	require.Equal(t, memstorage.ErrMockedError, err)
+	storage.DeactivateMockedError()
+}
+
+func tUtilMessage(from, to *jid.JID, text string) *xml.Message {
+	msg := xml.NewMessageType(uuid.New(), xml.ChatType)
+	msg.SetFromJID(from)
+	msg.SetToJID(to)
+	body := xml.NewElementName("body")
+	body.SetText(text)
+	msg.AppendElement(body)
+	return msg
+}
@@ -0,0 +1,305 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0313
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/model/mammodel"
+	"github.com/ortuman/jackal/module"
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/module/xep0059"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+	"github.com/pborman/uuid"
+)
+
+const (
+	mamNamespace     = "urn:xmpp:mam:2"
+	forwardNamespace = "urn:xmpp:forward:0"
+	delayNamespace   = "urn:xmpp:delay"
+)
+
+const defaultPageSize = 50
+
+func init() {
+	module.Register("mam", func(domain string, _ *module.Config) (module.Module, error) {
+		return New(domain), nil
+	})
+}
+
+// MAM represents a message archive management server module.
+type MAM struct {
+	domain string
+}
+
+// New returns a message archive management IQ handler module
+// associated to a local host.
+func New(domain string) *MAM {
+	return &MAM{domain: domain}
+}
+
+// RegisterDisco registers disco entity features/items
+// associated to message archive management module.
+func (x *MAM) RegisterDisco(discoInfo *xep0030.DiscoInfo) {
+	entity := discoInfo.AccountEntity()
+	entity.AddFeature(mamNamespace)
+	entity.AddFeature(stanzaIDNamespace)
+}
+
+// MatchesIQ returns whether or not an IQ should be
+// processed by the message archive management module.
+func (x *MAM) MatchesIQ(iq *xml.IQ) bool {
+	e := iq.Elements()
+	return e.ChildNamespace("query", mamNamespace) != nil || e.ChildNamespace("prefs", mamNamespace) != nil
+}
+
+// ProcessIQ processes a message archive management IQ taking according actions
+// over the associated stream.
+func (x *MAM) ProcessIQ(iq *xml.IQ, stm stream.C2S) {
+	if !stm.JID().Matches(iq.ToJID(), jid.MatchesBare) {
+		stm.SendElement(iq.ForbiddenError())
+		return
+	}
+	e := iq.Elements()
+	if q := e.ChildNamespace("query", mamNamespace); q != nil {
+		if iq.IsGet() {
+			x.sendQueryForm(iq, stm)
+		} else if iq.IsSet() {
+			x.queryArchive(iq, q, stm)
+		} else {
+			stm.SendElement(iq.BadRequestError())
+		}
+	} else if prefs := e.ChildNamespace("prefs", mamNamespace); prefs != nil {
+		if iq.IsGet() {
+			x.sendPrefs(iq, stm)
+		} else if iq.IsSet() {
+			x.setPrefs(iq, prefs, stm)
+		} else {
+			stm.SendElement(iq.BadRequestError())
+		}
+	}
+}
+
+func (x *MAM) sendQueryForm(iq *xml.IQ, stm stream.C2S) {
+	form := &xep0004.DataForm{
+		Type: xep0004.Form,
+		Fields: xep0004.Fields{
+			{Var: xep0004.FormTypeFieldVar, Type: xep0004.Hidden, Values: []string{mamNamespace}},
+			{Var: "with", Type: xep0004.JidSingle},
+			{Var: "start", Type: xep0004.TextSingle},
+			{Var: "end", Type: xep0004.TextSingle},
+		},
+	}
+	q := xml.NewElementNamespace("query", mamNamespace)
+	q.AppendElement(form.Element())
+	res := iq.ResultIQ()
+	res.AppendElement(q)
+	stm.SendElement(res)
+}
+
+func (x *MAM) queryArchive(iq *xml.IQ, query xml.XElement, stm stream.C2S) {
+	filter, err := x.queryFilter(query)
+	if err != nil {
+		log.Error(err)
+		stm.SendElement(iq.BadRequestError())
+		return
+	}
+	page := &mammodel.Page{Max: defaultPageSize}
+	if set := query.Elements().ChildNamespace("set", xep0059.RSMNamespace); set != nil {
+		req, err := xep0059.NewRequestFromElement(set)
+		if err != nil {
+			log.Error(err)
+			stm.SendElement(iq.BadRequestError())
+			return
+		}
+		page = x.queryPage(req)
+	}
+	rs, err := storage.Instance().FetchArchiveMessages(stm.Username(), stm.Domain(), filter, page)
+	switch err {
+	case nil:
+		break
+	case mammodel.ErrItemNotFound:
+		stm.SendElement(iq.ItemNotFoundError())
+		return
+	default:
+		log.Error(err)
+		stm.SendElement(iq.InternalServerError())
+		return
+	}
+	queryID := query.Attributes().Get("queryid")
+	for i := 0; i < len(rs.Messages); i++ {
+		stm.SendElement(x.resultMessage(queryID, &rs.Messages[i], stm))
+	}
+	rsmRes := &xep0059.Result{Count: rs.Count}
+	if len(rs.Messages) > 0 {
+		rsmRes.First = rs.Messages[0].ID
+		rsmRes.FirstIndex = rs.FirstIndex
+		rsmRes.Last = rs.Messages[len(rs.Messages)-1].ID
+	}
+	fin := xml.NewElementNamespace("fin", mamNamespace)
+	fin.SetAttribute("complete", strconv.FormatBool(rs.Complete(page)))
+	fin.AppendElement(rsmRes.Element())
+
+	res := iq.ResultIQ()
+	res.AppendElement(fin)
+	stm.SendElement(res)
+}
+
+// queryPage derives archive page from a result set request,
+// never exceeding default page size.
+func (x *MAM) queryPage(req *xep0059.Request) *mammodel.Page {
+	page := &mammodel.Page{
+		After:  req.After,
+		Before: req.Before,
+		Last:   req.LastPage,
+		Max:    defaultPageSize,
+	}
+	if req.HasIndex {
+		page.Index = req.Index
+	}
+	if req.Max >= 0 && req.Max < defaultPageSize {
+		page.Max = req.Max
+	}
+	return page
+}
+
+func (x *MAM) queryFilter(query xml.XElement) (*mammodel.Filter, error) {
+	filter := &mammodel.Filter{}
+	formElem := query.Elements().ChildNamespace("x", xep0004.FormNamespace)
+	if formElem == nil {
+		return filter, nil
+	}
+	form, err := xep0004.NewFormFromElement(formElem)
+	if err != nil {
+		return nil, err
+	}
+	if with := form.Fields.ValueForField("with"); len(with) > 0 {
+		j, err := jid.NewWithString(with, false)
+		if err != nil {
+			return nil, err
+		}
+		filter.With = j.ToBareJID().String()
+	}
+	if start := form.Fields.ValueForField("start"); len(start) > 0 {
+		if filter.Start, err = time.Parse(time.RFC3339, start); err != nil {
+			return nil, err
+		}
+	}
+	if end := form.Fields.ValueForField("end"); len(end) > 0 {
+		if filter.End, err = time.Parse(time.RFC3339, end); err != nil {
+			return nil, err
+		}
+	}
+	return filter, nil
+}
+
+func (x *MAM) resultMessage(queryID string, m *mammodel.Message, stm stream.C2S) *xml.Message {
+	delay := xml.NewElementNamespace("delay", delayNamespace)
+	delay.SetAttribute("stamp", m.CreatedAt.UTC().Format(time.RFC3339))
+
+	forwarded := xml.NewElementNamespace("forwarded", forwardNamespace)
+	forwarded.AppendElement(delay)
+	forwarded.AppendElement(m.Message)
+
+	result := xml.NewElementNamespace("result", mamNamespace)
+	if len(queryID) > 0 {
+		result.SetAttribute("queryid", queryID)
+	}
+	result.SetAttribute("id", m.ID)
+	result.AppendElement(forwarded)
+
+	msg := xml.NewMessageType(uuid.New(), xml.NormalType)
+	msg.SetFromJID(stm.JID().ToBareJID())
+	msg.SetToJID(stm.JID())
+	msg.AppendElement(result)
+	return msg
+}
+
+func (x *MAM) sendPrefs(iq *xml.IQ, stm stream.C2S) {
+	prefs, err := storage.Instance().FetchArchivePrefs(stm.Username(), stm.Domain())
+	if err != nil {
+		log.Error(err)
+		stm.SendElement(iq.InternalServerError())
+		return
+	}
+	if prefs == nil {
+		prefs = &mammodel.Prefs{Username: stm.Username(), Domain: stm.Domain(), Default: mammodel.DefaultAlways}
+	}
+	res := iq.ResultIQ()
+	res.AppendElement(prefsElement(prefs))
+	stm.SendElement(res)
+}
+
+func (x *MAM) setPrefs(iq *xml.IQ, prefsElem xml.XElement, stm stream.C2S) {
+	prefs := &mammodel.Prefs{Username: stm.Username(), Domain: stm.Domain()}
+	switch def := prefsElem.Attributes().Get("default"); def {
+	case mammodel.DefaultAlways, mammodel.DefaultNever, mammodel.DefaultRoster:
+		prefs.Default = def
+	default:
+		stm.SendElement(iq.BadRequestError())
+		return
+	}
+	var err error
+	if always := prefsElem.Elements().Child("always"); always != nil {
+		if prefs.Always, err = prefsJIDs(always); err != nil {
+			stm.SendElement(iq.JidMalformedError())
+			return
+		}
+	}
+	if never := prefsElem.Elements().Child("never"); never != nil {
+		if prefs.Never, err = prefsJIDs(never); err != nil {
+			stm.SendElement(iq.JidMalformedError())
+			return
+		}
+	}
+	if err := storage.Instance().InsertOrUpdateArchivePrefs(prefs); err != nil {
+		log.Error(err)
+		stm.SendElement(iq.InternalServerError())
+		return
+	}
+	res := iq.ResultIQ()
+	res.AppendElement(prefsElement(prefs))
+	stm.SendElement(res)
+}
+
+func prefsJIDs(elem xml.XElement) ([]string, error) {
+	var ret []string
+	for _, jidElem := range elem.Elements().Children("jid") {
+		j, err := jid.NewWithString(jidElem.Text(), false)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, j.ToBareJID().String())
+	}
+	return ret, nil
+}
+
+func prefsElement(prefs *mammodel.Prefs) xml.XElement {
+	elem := xml.NewElementNamespace("prefs", mamNamespace)
+	elem.SetAttribute("default", prefs.Default)
+
+	always := xml.NewElementName("always")
+	for _, j := range prefs.Always {
+		jidElem := xml.NewElementName("jid")
+		jidElem.SetText(j)
+		always.AppendElement(jidElem)
+	}
+	never := xml.NewElementName("never")
+	for _, j := range prefs.Never {
+		jidElem := xml.NewElementName("jid")
+		jidElem.SetText(j)
+		never.AppendElement(jidElem)
+	}
+	elem.AppendElement(always)
+	elem.AppendElement(never)
+	return elem
+}
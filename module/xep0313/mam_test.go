@@ -0,0 +1,238 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0313
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/module/xep0059"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXEP0313_Matching(t *testing.T) {
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+
+	x := New("jackal.im")
+
+	iq := xml.NewIQType(uuid.New(), xml.GetType)
+	iq.SetFromJID(j)
+	iq.SetToJID(j.ToBareJID())
+	require.False(t, x.MatchesIQ(iq))
+
+	iq.AppendElement(xml.NewElementNamespace("query", mamNamespace))
+	require.True(t, x.MatchesIQ(iq))
+
+	iq = xml.NewIQType(uuid.New(), xml.SetType)
+	iq.SetFromJID(j)
+	iq.SetToJID(j.ToBareJID())
+	iq.AppendElement(xml.NewElementNamespace("prefs", mamNamespace))
+	require.True(t, x.MatchesIQ(iq))
+}
+
+func TestXEP0313_Forbidden(t *testing.T) {
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "", true)
+
+	stm := stream.NewMockC2S(uuid.New(), j1)
+	defer stm.Disconnect(nil)
+
+	x := New("jackal.im")
+
+	iq := xml.NewIQType(uuid.New(), xml.SetType)
+	iq.SetFromJID(j1)
+	iq.SetToJID(j2)
+	iq.AppendElement(xml.NewElementNamespace("query", mamNamespace))
+	x.ProcessIQ(iq, stm)
+	elem := stm.FetchElement()
+	require.Equal(t, xml.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+}
+
+func TestXEP0313_QueryForm(t *testing.T) {
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+
+	stm := stream.NewMockC2S(uuid.New(), j)
+	defer stm.Disconnect(nil)
+
+	x := New("jackal.im")
+
+	iq := xml.NewIQType(uuid.New(), xml.GetType)
+	iq.SetFromJID(j)
+	iq.SetToJID(j.ToBareJID())
+	iq.AppendElement(xml.NewElementNamespace("query", mamNamespace))
+	x.ProcessIQ(iq, stm)
+	elem := stm.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+	q := elem.Elements().ChildNamespace("query", mamNamespace)
+	require.NotNil(t, q)
+	form, err := xep0004.NewFormFromElement(q.Elements().ChildNamespace("x", xep0004.FormNamespace))
+	require.Nil(t, err)
+	require.Equal(t, mamNamespace, form.FormType())
+	require.NotNil(t, form.Fields.Field("with"))
+}
+
+func TestXEP0313_QueryArchive(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	defer func() {
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "garden", true)
+	j3, _ := jid.New("romeo", "jackal.im", "orchard", true)
+
+	x := New("jackal.im")
+
+	for i := 0; i < 5; i++ {
+		x.ProcessRoutedMessage(tUtilMessage(j2, j1, "message "+strconv.Itoa(i)))
+	}
+	x.ProcessRoutedMessage(tUtilMessage(j3, j1, "hi!"))
+
+	stm := stream.NewMockC2S(uuid.New(), j1)
+	defer stm.Disconnect(nil)
+
+	// filter by peer and fetch first page
+	form := &xep0004.DataForm{
+		Type: xep0004.Submit,
+		Fields: xep0004.Fields{
+			{Var: xep0004.FormTypeFieldVar, Type: xep0004.Hidden, Values: []string{mamNamespace}},
+			{Var: "with", Values: []string{"noelia@jackal.im"}},
+		},
+	}
+	q := xml.NewElementNamespace("query", mamNamespace)
+	q.SetAttribute("queryid", "q1")
+	q.AppendElement(form.Element())
+	set := xml.NewElementNamespace("set", xep0059.RSMNamespace)
+	max := xml.NewElementName("max")
+	max.SetText("3")
+	set.AppendElement(max)
+	q.AppendElement(set)
+
+	iq := xml.NewIQType(uuid.New(), xml.SetType)
+	iq.SetFromJID(j1)
+	iq.SetToJID(j1.ToBareJID())
+	iq.AppendElement(q)
+	x.ProcessIQ(iq, stm)
+
+	for i := 0; i < 3; i++ {
+		elem := stm.FetchElement()
+		require.Equal(t, "message", elem.Name())
+		res := elem.Elements().ChildNamespace("result", mamNamespace)
+		require.NotNil(t, res)
+		require.Equal(t, "q1", res.Attributes().Get("queryid"))
+		fwd := res.Elements().ChildNamespace("forwarded", forwardNamespace)
+		require.NotNil(t, fwd)
+		require.NotNil(t, fwd.Elements().ChildNamespace("delay", delayNamespace))
+		require.Equal(t, "message "+strconv.Itoa(i), fwd.Elements().Child("message").Elements().Child("body").Text())
+	}
+	elem := stm.FetchElement()
+	require.Equal(t, "iq", elem.Name())
+	require.Equal(t, xml.ResultType, elem.Type())
+	fin := elem.Elements().ChildNamespace("fin", mamNamespace)
+	require.NotNil(t, fin)
+	require.Equal(t, "false", fin.Attributes().Get("complete"))
+	rsm := fin.Elements().ChildNamespace("set", xep0059.RSMNamespace)
+	require.Equal(t, "5", rsm.Elements().Child("count").Text())
+	last := rsm.Elements().Child("last").Text()
+
+	// fetch next page
+	q.RemoveElementsNamespace("set", xep0059.RSMNamespace)
+	set = xml.NewElementNamespace("set", xep0059.RSMNamespace)
+	after := xml.NewElementName("after")
+	after.SetText(last)
+	set.AppendElement(after)
+	q.AppendElement(set)
+
+	iq = xml.NewIQType(uuid.New(), xml.SetType)
+	iq.SetFromJID(j1)
+	iq.SetToJID(j1.ToBareJID())
+	iq.AppendElement(q)
+	x.ProcessIQ(iq, stm)
+
+	stm.FetchElement()
+	stm.FetchElement()
+	elem = stm.FetchElement()
+	fin = elem.Elements().ChildNamespace("fin", mamNamespace)
+	require.NotNil(t, fin)
+	require.Equal(t, "true", fin.Attributes().Get("complete"))
+
+	// unknown 'after' item
+	q.RemoveElementsNamespace("set", xep0059.RSMNamespace)
+	set = xml.NewElementNamespace("set", xep0059.RSMNamespace)
+	after = xml.NewElementName("after")
+	after.SetText("foo")
+	set.AppendElement(after)
+	q.AppendElement(set)
+
+	iq = xml.NewIQType(uuid.New(), xml.SetType)
+	iq.SetFromJID(j1)
+	iq.SetToJID(j1.ToBareJID())
+	iq.AppendElement(q)
+	x.ProcessIQ(iq, stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ErrItemNotFound.Error(), elem.Error().Elements().All()[0].Name())
+}
+
+func TestXEP0313_Prefs(t *testing.T) {
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	defer storage.Shutdown()
+
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+
+	stm := stream.NewMockC2S(uuid.New(), j)
+	defer stm.Disconnect(nil)
+
+	x := New("jackal.im")
+
+	iq := xml.NewIQType(uuid.New(), xml.GetType)
+	iq.SetFromJID(j)
+	iq.SetToJID(j.ToBareJID())
+	iq.AppendElement(xml.NewElementNamespace("prefs", mamNamespace))
+	x.ProcessIQ(iq, stm)
+	elem := stm.FetchElement()
+	prefs := elem.Elements().ChildNamespace("prefs", mamNamespace)
+	require.NotNil(t, prefs)
+	require.Equal(t, "always", prefs.Attributes().Get("default"))
+
+	prefsElem := xml.NewElementNamespace("prefs", mamNamespace)
+	prefsElem.SetAttribute("default", "roster")
+	never := xml.NewElementName("never")
+	jidElem := xml.NewElementName("jid")
+	jidElem.SetText("romeo@jackal.im/orchard")
+	never.AppendElement(jidElem)
+	prefsElem.AppendElement(never)
+
+	iq = xml.NewIQType(uuid.New(), xml.SetType)
+	iq.SetFromJID(j)
+	iq.SetToJID(j.ToBareJID())
+	iq.AppendElement(prefsElem)
+	x.ProcessIQ(iq, stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	p, _ := storage.Instance().FetchArchivePrefs("ortuman", "jackal.im")
+	require.NotNil(t, p)
+	require.Equal(t, "roster", p.Default)
+	require.Equal(t, []string{"romeo@jackal.im"}, p.Never)
+
+	// invalid default mode
+	prefsElem.SetAttribute("default", "sometimes")
+	iq = xml.NewIQType(uuid.New(), xml.SetType)
+	iq.SetFromJID(j)
+	iq.SetToJID(j.ToBareJID())
+	iq.AppendElement(prefsElem)
+	x.ProcessIQ(iq, stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
+}
@@ -0,0 +1,226 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0357
+
+import (
+	"strconv"
+
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/module"
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+	"github.com/pborman/uuid"
+)
+
+const (
+	pushNamespace   = "urn:xmpp:push:0"
+	pubSubNamespace = "http://jabber.org/protocol/pubsub"
+
+	summaryFormType        = "urn:xmpp:push:summary"
+	publishOptionsFormType = "http://jabber.org/protocol/pubsub#publish-options"
+)
+
+// Config represents Push Notifications module configuration.
+type Config struct {
+	// IncludeBody determines whether or not the last message body
+	// is included into the notification summary.
+	IncludeBody bool `yaml:"include_body"`
+}
+
+func init() {
+	module.Register("push", func(_ string, cfg *module.Config) (module.Module, error) {
+		var config Config
+		if err := cfg.Decode("push", &config); err != nil {
+			return nil, err
+		}
+		return New(&config), nil
+	})
+}
+
+// Push represents a push notifications server module.
+type Push struct {
+	cfg     *Config
+	actorCh chan func()
+	doneCh  chan chan struct{}
+}
+
+// New returns a push notifications IQ handler module.
+func New(config *Config) *Push {
+	x := &Push{
+		cfg:     config,
+		actorCh: make(chan func(), 64),
+		doneCh:  make(chan chan struct{}),
+	}
+	go x.loop()
+	return x
+}
+
+// RegisterDisco registers disco entity features/items
+// associated to push notifications module.
+func (x *Push) RegisterDisco(discoInfo *xep0030.DiscoInfo) {
+	discoInfo.AccountEntity().AddFeature(pushNamespace)
+}
+
+// MatchesIQ returns whether or not an IQ should be
+// processed by the push notifications module.
+func (x *Push) MatchesIQ(iq *xml.IQ) bool {
+	e := iq.Elements()
+	return e.ChildNamespace("enable", pushNamespace) != nil || e.ChildNamespace("disable", pushNamespace) != nil
+}
+
+// ProcessIQ processes a push notifications IQ taking according actions
+// over the associated stream.
+func (x *Push) ProcessIQ(iq *xml.IQ, stm stream.C2S) {
+	x.actorCh <- func() {
+		if !iq.IsSet() || !iq.ToJID().IsBare() || iq.ToJID().Node() != stm.Username() {
+			stm.SendElement(iq.BadRequestError())
+			return
+		}
+		e := iq.Elements()
+		if enable := e.ChildNamespace("enable", pushNamespace); enable != nil {
+			x.enable(iq, enable, stm)
+		} else if disable := e.ChildNamespace("disable", pushNamespace); disable != nil {
+			x.disable(iq, disable, stm)
+		}
+	}
+}
+
+// NotifyOfflineMessage publishes a notification summary to every
+// app server registered by the offline message recipient.
+func (x *Push) NotifyOfflineMessage(message *xml.Message, count int) {
+	x.actorCh <- func() {
+		x.notify(message, count)
+	}
+}
+
+// Shutdown shuts down push notifications module.
+func (x *Push) Shutdown() {
+	ch := make(chan struct{})
+	x.doneCh <- ch
+	<-ch
+}
+
+// runs on it's own goroutine
+func (x *Push) loop() {
+	for {
+		select {
+		case f := <-x.actorCh:
+			f()
+		case ch := <-x.doneCh:
+			close(ch)
+			return
+		}
+	}
+}
+
+func (x *Push) enable(iq *xml.IQ, enable xml.XElement, stm stream.C2S) {
+	serviceJID, err := jid.NewWithString(enable.Attributes().Get("jid"), false)
+	if err != nil {
+		stm.SendElement(iq.JidMalformedError())
+		return
+	}
+	node := enable.Attributes().Get("node")
+	if len(node) == 0 {
+		stm.SendElement(iq.BadRequestError())
+		return
+	}
+	var options xml.XElement
+	if formElem := enable.Elements().ChildNamespace("x", xep0004.FormNamespace); formElem != nil {
+		form, err := xep0004.NewFormFromElement(formElem)
+		if err != nil || form.Type != xep0004.Submit || form.FormType() != publishOptionsFormType {
+			stm.SendElement(iq.BadRequestError())
+			return
+		}
+		options = form.Element()
+	}
+	reg := &model.PushRegistration{
+		Username: stm.Username(),
+		Domain:   stm.Domain(),
+		JID:      serviceJID.String(),
+		Node:     node,
+		Options:  options,
+	}
+	if err := storage.Instance().InsertOrUpdatePushRegistration(reg); err != nil {
+		log.Error(err)
+		stm.SendElement(iq.InternalServerError())
+		return
+	}
+	log.Infof("enabled push notifications... (%s/%s) service: %s", stm.Username(), stm.Resource(), reg.JID)
+	stm.SendElement(iq.ResultIQ())
+}
+
+func (x *Push) disable(iq *xml.IQ, disable xml.XElement, stm stream.C2S) {
+	serviceJID, err := jid.NewWithString(disable.Attributes().Get("jid"), false)
+	if err != nil {
+		stm.SendElement(iq.JidMalformedError())
+		return
+	}
+	node := disable.Attributes().Get("node")
+	if err := storage.Instance().DeletePushRegistrations(stm.Username(), stm.Domain(), serviceJID.String(), node); err != nil {
+		log.Error(err)
+		stm.SendElement(iq.InternalServerError())
+		return
+	}
+	log.Infof("disabled push notifications... (%s/%s) service: %s", stm.Username(), stm.Resource(), serviceJID.String())
+	stm.SendElement(iq.ResultIQ())
+}
+
+func (x *Push) notify(message *xml.Message, count int) {
+	toJID := message.ToJID().ToBareJID()
+	regs, err := storage.Instance().FetchPushRegistrations(toJID.Node(), toJID.Domain())
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	for _, reg := range regs {
+		serviceJID, err := jid.NewWithString(reg.JID, true)
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		iq := xml.NewIQType(uuid.New(), xml.SetType)
+		iq.SetFromJID(toJID)
+		iq.SetToJID(serviceJID)
+		iq.AppendElement(x.pubSubElement(&reg, message, count))
+		if err := router.Route(iq); err != nil {
+			log.Errorf("%v", err)
+		}
+	}
+}
+
+func (x *Push) pubSubElement(reg *model.PushRegistration, message *xml.Message, count int) xml.XElement {
+	form := &xep0004.DataForm{Type: xep0004.Submit}
+	form.Fields = append(form.Fields, xep0004.Field{Var: xep0004.FormTypeFieldVar, Type: xep0004.Hidden, Values: []string{summaryFormType}})
+	form.Fields = append(form.Fields, xep0004.Field{Var: "message-count", Values: []string{strconv.Itoa(count)}})
+	form.Fields = append(form.Fields, xep0004.Field{Var: "last-message-sender", Values: []string{message.From()}})
+	if body := message.Elements().Child("body"); body != nil && x.cfg.IncludeBody {
+		form.Fields = append(form.Fields, xep0004.Field{Var: "last-message-body", Values: []string{body.Text()}})
+	}
+	notification := xml.NewElementNamespace("notification", pushNamespace)
+	notification.AppendElement(form.Element())
+
+	item := xml.NewElementName("item")
+	item.AppendElement(notification)
+
+	publish := xml.NewElementName("publish")
+	publish.SetAttribute("node", reg.Node)
+	publish.AppendElement(item)
+
+	pubSub := xml.NewElementNamespace("pubsub", pubSubNamespace)
+	pubSub.AppendElement(publish)
+	if reg.Options != nil {
+		publishOptions := xml.NewElementName("publish-options")
+		publishOptions.AppendElement(reg.Options)
+		pubSub.AppendElement(publishOptions)
+	}
+	return pubSub
+}
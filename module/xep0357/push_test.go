@@ -0,0 +1,184 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0357
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXEP0357_Matching(t *testing.T) {
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+
+	x := New(&Config{})
+	defer x.Shutdown()
+
+	require.True(t, x.MatchesIQ(tUtilPushIQ(j, "enable", "push.jackal.im", "n1")))
+	require.True(t, x.MatchesIQ(tUtilPushIQ(j, "disable", "push.jackal.im", "")))
+
+	iq := xml.NewIQType(uuid.New(), xml.SetType)
+	iq.AppendElement(xml.NewElementNamespace("enable", "urn:xmpp:sm:3"))
+	require.False(t, x.MatchesIQ(iq))
+}
+
+func TestXEP0357_EnableAndDisable(t *testing.T) {
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	defer storage.Shutdown()
+
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	stm := stream.NewMockC2S(uuid.New(), j)
+
+	x := New(&Config{})
+	defer x.Shutdown()
+
+	// missing node
+	x.ProcessIQ(tUtilPushIQ(j, "enable", "push.jackal.im", ""), stm)
+	elem := stm.FetchElement()
+	require.Equal(t, xml.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
+
+	// get type
+	iq := tUtilPushIQ(j, "enable", "push.jackal.im", "n1")
+	iq.SetType(xml.GetType)
+	x.ProcessIQ(iq, stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
+
+	// invalid publish options
+	iq = tUtilPushIQ(j, "enable", "push.jackal.im", "n1")
+	form := &xep0004.DataForm{Type: xep0004.Submit}
+	form.Fields = append(form.Fields, xep0004.Field{Var: xep0004.FormTypeFieldVar, Values: []string{"urn:xmpp:bogus"}})
+	iq.Elements().ChildNamespace("enable", pushNamespace).(*xml.Element).AppendElement(form.Element())
+	x.ProcessIQ(iq, stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
+
+	x.ProcessIQ(tUtilPushIQ(j, "enable", "push.jackal.im", "n1"), stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	iq = tUtilPushIQ(j, "enable", "push.jackal.im", "n2")
+	iq.Elements().ChildNamespace("enable", pushNamespace).(*xml.Element).AppendElement(tUtilPublishOptionsForm("s3cr3t").Element())
+	x.ProcessIQ(iq, stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	regs, _ := storage.Instance().FetchPushRegistrations("ortuman", "jackal.im")
+	require.Equal(t, 2, len(regs))
+	require.Nil(t, regs[0].Options)
+	require.NotNil(t, regs[1].Options)
+
+	x.ProcessIQ(tUtilPushIQ(j, "disable", "push.jackal.im", "n1"), stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	regs, _ = storage.Instance().FetchPushRegistrations("ortuman", "jackal.im")
+	require.Equal(t, 1, len(regs))
+	require.Equal(t, "n2", regs[0].Node)
+
+	x.ProcessIQ(tUtilPushIQ(j, "disable", "push.jackal.im", ""), stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xml.ResultType, elem.Type())
+
+	regs, _ = storage.Instance().FetchPushRegistrations("ortuman", "jackal.im")
+	require.Equal(t, 0, len(regs))
+}
+
+func TestXEP0357_NotifyOfflineMessage(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	router.Initialize(&router.Config{})
+	defer func() {
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "garden", true)
+	pushJID, _ := jid.New("push", "jackal.im", "app", true)
+
+	stm1 := stream.NewMockC2S(uuid.New(), j1)
+	pushStm := stream.NewMockC2S(uuid.New(), pushJID)
+	router.Bind(pushStm)
+
+	x := New(&Config{IncludeBody: true})
+	defer x.Shutdown()
+
+	iq := tUtilPushIQ(j1, "enable", pushJID.String(), "yxs32uqsflafdk3iuqo")
+	iq.Elements().ChildNamespace("enable", pushNamespace).(*xml.Element).AppendElement(tUtilPublishOptionsForm("s3cr3t").Element())
+	x.ProcessIQ(iq, stm1)
+	stm1.FetchElement()
+
+	msg := xml.NewMessageType(uuid.New(), xml.ChatType)
+	msg.SetFromJID(j2)
+	msg.SetToJID(j1.ToBareJID())
+	body := xml.NewElementName("body")
+	body.SetText("Wherefore art thou, Romeo?")
+	msg.AppendElement(body)
+	x.NotifyOfflineMessage(msg, 3)
+
+	elem := pushStm.FetchElement()
+	require.Equal(t, "iq", elem.Name())
+	require.Equal(t, xml.SetType, elem.Type())
+	require.Equal(t, "ortuman@jackal.im", elem.From())
+
+	pubSub := elem.Elements().ChildNamespace("pubsub", pubSubNamespace)
+	require.NotNil(t, pubSub)
+	publish := pubSub.Elements().Child("publish")
+	require.Equal(t, "yxs32uqsflafdk3iuqo", publish.Attributes().Get("node"))
+
+	notification := publish.Elements().Child("item").Elements().ChildNamespace("notification", pushNamespace)
+	require.NotNil(t, notification)
+	form, err := xep0004.NewFormFromElement(notification.Elements().ChildNamespace("x", xep0004.FormNamespace))
+	require.Nil(t, err)
+	require.Equal(t, summaryFormType, form.FormType())
+	require.Equal(t, "3", form.Fields.ValueForField("message-count"))
+	require.Equal(t, j2.String(), form.Fields.ValueForField("last-message-sender"))
+	require.Equal(t, "Wherefore art thou, Romeo?", form.Fields.ValueForField("last-message-body"))
+
+	options, err := xep0004.NewFormFromElement(pubSub.Elements().Child("publish-options").Elements().ChildNamespace("x", xep0004.FormNamespace))
+	require.Nil(t, err)
+	require.Equal(t, "s3cr3t", options.Fields.ValueForField("secret"))
+
+	// body not included
+	x.cfg.IncludeBody = false
+	x.NotifyOfflineMessage(msg, 4)
+
+	elem = pushStm.FetchElement()
+	notification = elem.Elements().ChildNamespace("pubsub", pubSubNamespace).Elements().Child("publish").Elements().Child("item").Elements().Child("notification")
+	form, _ = xep0004.NewFormFromElement(notification.Elements().Child("x"))
+	require.Equal(t, "4", form.Fields.ValueForField("message-count"))
+	require.Nil(t, form.Fields.Field("last-message-body"))
+}
+
+func tUtilPushIQ(from *jid.JID, name, serviceJID, node string) *xml.IQ {
+	iq := xml.NewIQType(uuid.New(), xml.SetType)
+	iq.SetFromJID(from)
+	iq.SetToJID(from.ToBareJID())
+	elem := xml.NewElementNamespace(name, pushNamespace)
+	elem.SetAttribute("jid", serviceJID)
+	if len(node) > 0 {
+		elem.SetAttribute("node", node)
+	}
+	iq.AppendElement(elem)
+	return iq
+}
+
+func tUtilPublishOptionsForm(secret string) *xep0004.DataForm {
+	form := &xep0004.DataForm{Type: xep0004.Submit}
+	form.Fields = append(form.Fields, xep0004.Field{Var: xep0004.FormTypeFieldVar, Type: xep0004.Hidden, Values: []string{publishOptionsFormType}})
+	form.Fields = append(form.Fields, xep0004.Field{Var: "secret", Values: []string{secret}})
+	return form
+}
@@ -0,0 +1,53 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package router
+
+import (
+	"github.com/ortuman/jackal/xml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	routedStanzas = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "jackal_router_stanzas_total",
+		Help: "Total number of routed stanzas by type.",
+	}, []string{"type"})
+	routeErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "jackal_router_errors_total",
+		Help: "Total number of routing errors by error.",
+	}, []string{"error"})
+)
+
+func init() {
+	prometheus.MustRegister(routedStanzas, routeErrors)
+}
+
+var routeErrorLabels = map[error]string{
+	ErrNotExistingAccount:  "not_existing_account",
+	ErrResourceNotFound:    "resource_not_found",
+	ErrNotAuthenticated:    "not_authenticated",
+	ErrBlockedJID:          "blocked_jid",
+	ErrFailedRemoteConnect: "failed_remote_connect",
+}
+
+func trackRoute(stanza xml.Stanza, err error) error {
+	switch stanza.(type) {
+	case *xml.Message:
+		routedStanzas.WithLabelValues("message").Inc()
+	case *xml.Presence:
+		routedStanzas.WithLabelValues("presence").Inc()
+	case *xml.IQ:
+		routedStanzas.WithLabelValues("iq").Inc()
+	}
+	if err != nil {
+		label, ok := routeErrorLabels[err]
+		if !ok {
+			label = "internal"
+		}
+		routeErrors.WithLabelValues(label).Inc()
+	}
+	return err
+}
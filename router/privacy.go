@@ -0,0 +1,183 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package router
+
+import (
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/model/privacymodel"
+	"github.com/ortuman/jackal/model/rostermodel"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+)
+
+// ReloadPrivacyLists reloads in memory privacy lists for a given user
+// and starts applying them for future stanza routing.
+func ReloadPrivacyLists(username, domain string) {
+	instance().reloadPrivacyLists(username, domain)
+}
+
+// SetActivePrivacyList sets the privacy list to be applied to a stream
+// session. An empty name declines the use of any active list.
+func SetActivePrivacyList(stm stream.C2S, name string) {
+	instance().setActivePrivacyList(stm, name)
+}
+
+// ActivePrivacyList returns the active privacy list name
+// associated to a stream session.
+func ActivePrivacyList(stm stream.C2S) string {
+	return instance().activePrivacyList(stm)
+}
+
+// IsDeniedByPrivacyList returns whether or not a stanza exchanged with a stream
+// is denied by its effective privacy list.
+func IsDeniedByPrivacyList(stanza xml.Stanza, stm stream.C2S, outgoing bool) bool {
+	var contact *jid.JID
+	if outgoing {
+		contact = stanza.ToJID()
+	} else {
+		contact = stanza.FromJID()
+	}
+	return instance().isDeniedByPrivacyList(stm.Username(), stm.Domain(), stm, contact, privacyStanzaType(stanza, outgoing))
+}
+
+func (r *router) reloadPrivacyLists(username, domain string) {
+	r.privacyListsMu.Lock()
+	defer r.privacyListsMu.Unlock()
+
+	delete(r.privacyLists, userKey(username, domain))
+	log.Infof("privacy lists reloaded... (username: %s, domain: %s)", username, domain)
+}
+
+func (r *router) setActivePrivacyList(stm stream.C2S, name string) {
+	r.privacyListsMu.Lock()
+	defer r.privacyListsMu.Unlock()
+	if len(name) > 0 {
+		r.activePrivacyLists[stm.ID()] = name
+	} else {
+		delete(r.activePrivacyLists, stm.ID())
+	}
+}
+
+func (r *router) activePrivacyList(stm stream.C2S) string {
+	r.privacyListsMu.RLock()
+	defer r.privacyListsMu.RUnlock()
+	return r.activePrivacyLists[stm.ID()]
+}
+
+// isDeniedByPrivacyList evaluates user's effective privacy list against a contact.
+// In case stm is nil user's default list will be applied.
+func (r *router) isDeniedByPrivacyList(username, domain string, stm stream.C2S, contact *jid.JID, stanzaType string) bool {
+	if contact == nil || !r.isPrivacyContact(username, domain, contact) {
+		return false
+	}
+	l := r.effectivePrivacyList(username, domain, stm)
+	if l == nil {
+		return false
+	}
+	var ri *rostermodel.Item
+	if l.RequiresRosterItem() {
+		var err error
+		ri, err = storage.Instance().FetchRosterItem(username, domain, contact.ToBareJID().String())
+		if err != nil {
+			log.Error(err)
+			return false
+		}
+	}
+	return l.IsDenied(contact, stanzaType, ri)
+}
+
+// isPrivacyContact returns whether or not privacy lists should be applied
+// to stanzas exchanged with a given JID.
+// Local server and user's own resources are never blocked.
+func (r *router) isPrivacyContact(username, domain string, j *jid.JID) bool {
+	if !host.IsLocalHost(j.Domain()) {
+		return true
+	}
+	return !j.IsServer() && (j.Node() != username || j.Domain() != domain)
+}
+
+func (r *router) effectivePrivacyList(username, domain string, stm stream.C2S) *privacymodel.List {
+	var active string
+	if stm != nil {
+		active = r.activePrivacyList(stm)
+	}
+	lists := r.getPrivacyLists(username, domain)
+	for i := 0; i < len(lists); i++ {
+		if (len(active) > 0 && lists[i].Name == active) || (len(active) == 0 && lists[i].Default) {
+			return &lists[i]
+		}
+	}
+	return nil
+}
+
+func (r *router) getPrivacyLists(username, domain string) []privacymodel.List {
+	key := userKey(username, domain)
+	r.privacyListsMu.RLock()
+	lists, ok := r.privacyLists[key]
+	r.privacyListsMu.RUnlock()
+	if ok {
+		return lists
+	}
+	lists, err := storage.Instance().FetchPrivacyLists(username, domain)
+	if err != nil {
+		log.Error(err)
+		return nil
+	}
+	r.privacyListsMu.Lock()
+	r.privacyLists[key] = lists
+	r.privacyListsMu.Unlock()
+	return lists
+}
+
+// senderStream returns the local stream a stanza has been sent from, if any.
+func (r *router) senderStream(stanza xml.Stanza) stream.C2S {
+	fromJID := stanza.FromJID()
+	if fromJID == nil || !fromJID.IsFullWithUser() || !host.IsLocalHost(fromJID.Domain()) {
+		return nil
+	}
+	for _, stm := range r.userStreams(fromJID.Node(), fromJID.Domain()) {
+		if stm.Resource() == fromJID.Resource() {
+			return stm
+		}
+	}
+	return nil
+}
+
+// allowedStreams filters out local streams whose effective privacy list
+// denies an incoming stanza.
+func (r *router) allowedStreams(stanza xml.Stanza, stms []stream.C2S) []stream.C2S {
+	var ret []stream.C2S
+	for _, stm := range stms {
+		if !r.isDeniedByPrivacyList(stm.Username(), stm.Domain(), stm, stanza.FromJID(), privacyStanzaType(stanza, false)) {
+			ret = append(ret, stm)
+		}
+	}
+	return ret
+}
+
+func privacyStanzaType(stanza xml.Stanza, outgoing bool) string {
+	switch stanza.(type) {
+	case *xml.Presence:
+		if outgoing {
+			return privacymodel.PresenceOut
+		}
+		return privacymodel.PresenceIn
+	case *xml.Message:
+		if !outgoing {
+			return privacymodel.Message
+		}
+	case *xml.IQ:
+		if !outgoing {
+			return privacymodel.IQ
+		}
+	}
+	// outgoing messages and IQs are only denied by
+	// those rules applying to every stanza type
+	return ""
+}
@@ -0,0 +1,137 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package router
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/model/privacymodel"
+	"github.com/ortuman/jackal/model/rostermodel"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestC2SManager_PrivacyLists(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	Initialize(&Config{})
+	defer func() {
+		Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	j1, _ := jid.NewWithString("ortuman@jackal.im/balcony", false)
+	j2, _ := jid.NewWithString("ortuman@jackal.im/garden", false)
+	j3, _ := jid.NewWithString("hamlet@jackal.im/balcony", false)
+	j4, _ := jid.NewWithString("juliet@jackal.im/garden", false)
+	stm1 := stream.NewMockC2S(uuid.New(), j1)
+	stm2 := stream.NewMockC2S(uuid.New(), j2)
+	stm3 := stream.NewMockC2S(uuid.New(), j3)
+	Bind(stm1)
+	Bind(stm2)
+	Bind(stm3)
+
+	storage.Instance().InsertOrUpdateRosterItem(&rostermodel.Item{
+		Username:     "ortuman",
+		Domain:       "jackal.im",
+		JID:          "juliet@jackal.im",
+		Subscription: rostermodel.SubscriptionBoth,
+		Groups:       []string{"Friends"},
+	})
+	storage.Instance().InsertOrUpdatePrivacyList(&privacymodel.List{
+		Username: "ortuman",
+		Domain:   "jackal.im",
+		Name:     "public",
+		Default:  true,
+		Items: []privacymodel.Item{{
+			Type:    privacymodel.JIDType,
+			Value:   "hamlet@jackal.im",
+			Action:  privacymodel.Deny,
+			Order:   1,
+			Stanzas: []string{privacymodel.Message},
+		}},
+	})
+	storage.Instance().InsertOrUpdatePrivacyList(&privacymodel.List{
+		Username: "ortuman",
+		Domain:   "jackal.im",
+		Name:     "private",
+		Items: []privacymodel.Item{{
+			Type:   privacymodel.GroupType,
+			Value:  "Friends",
+			Action: privacymodel.Allow,
+			Order:  1,
+		}, {
+			Action: privacymodel.Deny,
+			Order:  2,
+		}},
+	})
+
+	// default list denies incoming messages...
+	msg := xml.NewMessageType(uuid.New(), xml.ChatType)
+	msg.SetFromJID(j3)
+	msg.SetToJID(j1)
+	require.Equal(t, ErrBlockedJID, Route(msg))
+	msg.SetToJID(j1.ToBareJID())
+	require.Equal(t, ErrBlockedJID, Route(msg))
+
+	// ...but not IQs
+	iq := xml.NewIQType(uuid.New(), xml.GetType)
+	iq.SetFromJID(j3)
+	iq.SetToJID(j1)
+	require.Nil(t, Route(iq))
+	require.NotNil(t, stm1.FetchElement())
+
+	// active list applies only to its own stream
+	SetActivePrivacyList(stm1, "private")
+	require.Equal(t, "private", ActivePrivacyList(stm1))
+	require.Equal(t, "", ActivePrivacyList(stm2))
+
+	require.Equal(t, ErrBlockedJID, Route(iq))
+
+	p := xml.NewPresence(j3, j1.ToBareJID(), xml.AvailableType)
+	require.Nil(t, Route(p))
+	require.NotNil(t, stm2.FetchElement())
+
+	// outgoing stanzas
+	p = xml.NewPresence(j1, j3.ToBareJID(), xml.AvailableType)
+	require.True(t, IsDeniedByPrivacyList(p, stm1, true))
+	require.False(t, IsDeniedByPrivacyList(p, stm2, true))
+	require.Equal(t, ErrBlockedJID, Route(p))
+
+	p = xml.NewPresence(j1, j4.ToBareJID(), xml.AvailableType)
+	require.False(t, IsDeniedByPrivacyList(p, stm1, true))
+
+	// user's own resources are never blocked
+	msg = xml.NewMessageType(uuid.New(), xml.ChatType)
+	msg.SetFromJID(j2)
+	msg.SetToJID(j1)
+	require.Nil(t, Route(msg))
+	require.NotNil(t, stm1.FetchElement())
+
+	// offline user default list
+	Unbind(stm1)
+	Unbind(stm2)
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "ortuman", Domain: "jackal.im"})
+
+	msg = xml.NewMessageType(uuid.New(), xml.ChatType)
+	msg.SetFromJID(j3)
+	msg.SetToJID(j1.ToBareJID())
+	require.Equal(t, ErrBlockedJID, Route(msg))
+
+	storage.Instance().SetDefaultPrivacyList("ortuman", "jackal.im", "")
+	ReloadPrivacyLists("ortuman", "jackal.im")
+	require.Equal(t, ErrNotAuthenticated, Route(msg))
+
+	// active list is cleared on unbind
+	require.Equal(t, "", ActivePrivacyList(stm1))
+}
@@ -11,6 +11,8 @@ import (
 
 	"github.com/ortuman/jackal/host"
 	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/model/privacymodel"
+	"github.com/ortuman/jackal/module"
 	"github.com/ortuman/jackal/storage"
 	"github.com/ortuman/jackal/stream"
 	"github.com/ortuman/jackal/xml"
@@ -44,14 +46,22 @@ type Config struct {
 
 	// GetS2SOut if set, acts as an s2s outgoing stream provider.
 	GetS2SOut func(localDomain, remoteDomain string) (stream.S2SOut, error)
+
+	// GetComponent if set, returns the external component stream
+	// associated to a domain or nil if not connected.
+	GetComponent func(domain string) stream.Component
 }
 
 type router struct {
 	cfg          *Config
 	mu           sync.RWMutex
-	localStreams map[string][]stream.C2S
+	localStreams map[string][]stream.C2S // indexed by bare JID
 	blockListsMu sync.RWMutex
 	blockLists   map[string][]*jid.JID
+
+	privacyListsMu     sync.RWMutex
+	privacyLists       map[string][]privacymodel.List // indexed by bare JID
+	activePrivacyLists map[string]string              // indexed by stream identifier
 }
 
 // singleton interface
@@ -72,6 +82,9 @@ func Initialize(cfg *Config) {
 		cfg:          cfg,
 		blockLists:   make(map[string][]*jid.JID),
 		localStreams: make(map[string][]stream.C2S),
+
+		privacyLists:       make(map[string][]privacymodel.List),
+		activePrivacyLists: make(map[string]string),
 	}
 	initialized = true
 }
@@ -101,32 +114,37 @@ func Unbind(stm stream.C2S) {
 }
 
 // UserStreams returns all streams associated to a user.
-func UserStreams(username string) []stream.C2S {
-	return instance().userStreams(username)
+func UserStreams(username, domain string) []stream.C2S {
+	return instance().userStreams(username, domain)
+}
+
+// LocalStreams returns every binded c2s stream.
+func LocalStreams() []stream.C2S {
+	return instance().allLocalStreams()
 }
 
 // IsBlockedJID returns whether or not the passed jid matches any
 // of a user's blocking list JID.
-func IsBlockedJID(jid *jid.JID, username string) bool {
-	return instance().isBlockedJID(jid, username)
+func IsBlockedJID(jid *jid.JID, username, domain string) bool {
+	return instance().isBlockedJID(jid, username, domain)
 }
 
 // ReloadBlockList reloads in memory block list for a given user and starts
 // applying it for future stanza routing.
-func ReloadBlockList(username string) {
-	instance().reloadBlockList(username)
+func ReloadBlockList(username, domain string) {
+	instance().reloadBlockList(username, domain)
 }
 
 // Route routes a stanza applying server rules for handling XML stanzas.
 // (https://xmpp.org/rfcs/rfc3921.html#rules)
 func Route(elem xml.Stanza) error {
-	return instance().route(elem, false)
+	return trackRoute(elem, instance().route(elem, false))
 }
 
 // MustRoute routes a stanza applying server rules for handling XML stanzas
-// ignoring blocking lists.
+// ignoring blocking and privacy lists.
 func MustRoute(elem xml.Stanza) error {
-	return instance().route(elem, true)
+	return trackRoute(elem, instance().route(elem, true))
 }
 
 func instance() *router {
@@ -145,10 +163,11 @@ func (r *router) bind(stm stream.C2S) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if authenticated := r.localStreams[stm.Username()]; authenticated != nil {
-		r.localStreams[stm.Username()] = append(authenticated, stm)
+	key := userKey(stm.Username(), stm.Domain())
+	if authenticated := r.localStreams[key]; authenticated != nil {
+		r.localStreams[key] = append(authenticated, stm)
 	} else {
-		r.localStreams[stm.Username()] = []stream.C2S{stm}
+		r.localStreams[key] = []stream.C2S{stm}
 	}
 	log.Infof("binded c2s stream... (%s/%s)", stm.Username(), stm.Resource())
 	return
@@ -161,7 +180,8 @@ func (r *router) unbind(stm stream.C2S) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if resources := r.localStreams[stm.Username()]; resources != nil {
+	key := userKey(stm.Username(), stm.Domain())
+	if resources := r.localStreams[key]; resources != nil {
 		res := stm.Resource()
 		for i := 0; i < len(resources); i++ {
 			if res == resources[i].Resource() {
@@ -170,22 +190,34 @@ func (r *router) unbind(stm stream.C2S) {
 			}
 		}
 		if len(resources) > 0 {
-			r.localStreams[stm.Username()] = resources
+			r.localStreams[key] = resources
 		} else {
-			delete(r.localStreams, stm.Username())
+			delete(r.localStreams, key)
 		}
 	}
 	log.Infof("unbinded c2s stream... (%s/%s)", stm.Username(), stm.Resource())
+
+	r.setActivePrivacyList(stm, "")
 }
 
-func (r *router) userStreams(username string) []stream.C2S {
+func (r *router) userStreams(username, domain string) []stream.C2S {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	return r.localStreams[username]
+	return r.localStreams[userKey(username, domain)]
 }
 
-func (r *router) isBlockedJID(jid *jid.JID, username string) bool {
-	bl := r.getBlockList(username)
+func (r *router) allLocalStreams() []stream.C2S {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var ret []stream.C2S
+	for _, stms := range r.localStreams {
+		ret = append(ret, stms...)
+	}
+	return ret
+}
+
+func (r *router) isBlockedJID(jid *jid.JID, username, domain string) bool {
+	bl := r.getBlockList(username, domain)
 	for _, blkJID := range bl {
 		if r.jidMatchesBlockedJID(jid, blkJID) {
 			return true
@@ -205,22 +237,23 @@ func (r *router) jidMatchesBlockedJID(j, blockedJID *jid.JID) bool {
 	return j.Matches(blockedJID, jid.MatchesDomain)
 }
 
-func (r *router) reloadBlockList(username string) {
+func (r *router) reloadBlockList(username, domain string) {
 	r.blockListsMu.Lock()
 	defer r.blockListsMu.Unlock()
 
-	delete(r.blockLists, username)
-	log.Infof("block list reloaded... (username: %s)", username)
+	delete(r.blockLists, userKey(username, domain))
+	log.Infof("block list reloaded... (username: %s, domain: %s)", username, domain)
 }
 
-func (r *router) getBlockList(username string) []*jid.JID {
+func (r *router) getBlockList(username, domain string) []*jid.JID {
+	key := userKey(username, domain)
 	r.blockListsMu.RLock()
-	bl := r.blockLists[username]
+	bl := r.blockLists[key]
 	r.blockListsMu.RUnlock()
 	if bl != nil {
 		return bl
 	}
-	blItms, err := storage.Instance().FetchBlockListItems(username)
+	blItms, err := storage.Instance().FetchBlockListItems(username, domain)
 	if err != nil {
 		log.Error(err)
 		return nil
@@ -231,28 +264,41 @@ func (r *router) getBlockList(username string) []*jid.JID {
 		bl = append(bl, j)
 	}
 	r.blockListsMu.Lock()
-	r.blockLists[username] = bl
+	r.blockLists[key] = bl
 	r.blockListsMu.Unlock()
 	return bl
 }
 
 func (r *router) route(stanza xml.Stanza, ignoreBlocking bool) error {
 	toJID := stanza.ToJID()
+	if !ignoreBlocking {
+		if stm := r.senderStream(stanza); stm != nil && r.isDeniedByPrivacyList(stm.Username(), stm.Domain(), stm, toJID, privacyStanzaType(stanza, true)) {
+			return ErrBlockedJID
+		}
+	}
+	if comp := r.component(toJID.Domain()); comp != nil {
+		comp.SendElement(stanza)
+		return nil
+	}
 	if !ignoreBlocking && !toJID.IsServer() {
-		if r.isBlockedJID(stanza.FromJID(), toJID.Node()) {
+		if r.isBlockedJID(stanza.FromJID(), toJID.Node(), toJID.Domain()) {
 			return ErrBlockedJID
 		}
 	}
 	if !host.IsLocalHost(toJID.Domain()) {
 		return r.remoteRoute(stanza)
 	}
-	rcps := r.userStreams(toJID.Node())
+	rcps := r.userStreams(toJID.Node(), toJID.Domain())
 	if len(rcps) == 0 {
-		exists, err := storage.Instance().UserExists(toJID.Node())
+		exists, err := storage.Instance().UserExists(toJID.Node(), toJID.Domain())
 		if err != nil {
 			return err
 		}
 		if exists {
+			if !ignoreBlocking && r.isDeniedByPrivacyList(toJID.Node(), toJID.Domain(), nil, stanza.FromJID(), privacyStanzaType(stanza, false)) {
+				return ErrBlockedJID
+			}
+			r.archiveMessage(stanza)
 			return ErrNotAuthenticated
 		}
 		return ErrNotExistingAccount
@@ -260,12 +306,22 @@ func (r *router) route(stanza xml.Stanza, ignoreBlocking bool) error {
 	if toJID.IsFullWithUser() {
 		for _, stm := range rcps {
 			if stm.Resource() == toJID.Resource() {
+				if !ignoreBlocking && len(r.allowedStreams(stanza, []stream.C2S{stm})) == 0 {
+					return ErrBlockedJID
+				}
+				r.archiveMessage(stanza)
 				stm.SendElement(stanza)
+				r.carbonCopy(stanza, stm)
 				return nil
 			}
 		}
 		return ErrResourceNotFound
 	}
+	if !ignoreBlocking {
+		if rcps = r.allowedStreams(stanza, rcps); len(rcps) == 0 {
+			return ErrBlockedJID
+		}
+	}
 	switch stanza.(type) {
 	case *xml.Message:
 		// send to highest priority stream
@@ -281,7 +337,9 @@ func (r *router) route(stanza xml.Stanza, ignoreBlocking bool) error {
 				highestPriority = p.Priority()
 			}
 		}
+		r.archiveMessage(stanza)
 		stm.SendElement(stanza)
+		r.carbonCopy(stanza, stm)
 
 	default:
 		// broadcast toJID all streams
@@ -304,6 +362,50 @@ func (r *router) remoteRoute(stanza xml.Stanza) error {
 		log.Error(err)
 		return ErrFailedRemoteConnect
 	}
+	r.archiveMessage(stanza)
 	out.SendElement(stanza)
 	return nil
 }
+
+func (r *router) component(domain string) stream.Component {
+	if r.cfg.GetComponent == nil {
+		return nil
+	}
+	return r.cfg.GetComponent(domain)
+}
+
+func (r *router) carbonCopy(stanza xml.Stanza, stm stream.C2S) {
+	message, ok := stanza.(*xml.Message)
+	if !ok {
+		return
+	}
+	for _, h := range module.DeliveryHandlers(stm.Domain()) {
+		h.ProcessDeliveredMessage(message, stm)
+	}
+}
+
+func (r *router) archiveMessage(stanza xml.Stanza) {
+	message, ok := stanza.(*xml.Message)
+	if !ok {
+		return
+	}
+	// sender host goes first, so that its handlers get to see
+	// the message as it was sent
+	var fromDomain string
+	if fromJID := message.FromJID(); fromJID != nil {
+		fromDomain = fromJID.Domain()
+		for _, h := range module.RouteHandlers(fromDomain) {
+			h.ProcessRoutedMessage(message)
+		}
+	}
+	if toDomain := message.ToJID().Domain(); toDomain != fromDomain {
+		for _, h := range module.RouteHandlers(toDomain) {
+			h.ProcessRoutedMessage(message)
+		}
+	}
+}
+
+// userKey returns the key used to index a user's in memory state.
+func userKey(username, domain string) string {
+	return username + "@" + domain
+}
@@ -10,12 +10,15 @@ import (
 
 	"github.com/ortuman/jackal/host"
 	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/module"
+	"github.com/ortuman/jackal/module/xep0030"
 	"github.com/ortuman/jackal/storage"
 	"github.com/ortuman/jackal/storage/memstorage"
 	"github.com/ortuman/jackal/stream"
 	"github.com/ortuman/jackal/xml"
 	"github.com/ortuman/jackal/xml/jid"
 	"github.com/pborman/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 )
 
@@ -27,6 +30,35 @@ func (f *fakeS2SOut) ID() string                    { return uuid.New() }
 func (f *fakeS2SOut) SendElement(elem xml.XElement) { f.elems = append(f.elems, elem) }
 func (f *fakeS2SOut) Disconnect(err error)          {}
 
+type fakeMessageHooks struct {
+	routed    []*xml.Message
+	delivered []stream.C2S
+}
+
+func (f *fakeMessageHooks) RegisterDisco(_ *xep0030.DiscoInfo) {}
+
+func (f *fakeMessageHooks) ProcessRoutedMessage(message *xml.Message) {
+	f.routed = append(f.routed, message)
+}
+
+func (f *fakeMessageHooks) ProcessDeliveredMessage(_ *xml.Message, stm stream.C2S) {
+	f.delivered = append(f.delivered, stm)
+}
+
+func init() {
+	module.Register("fake_message_hooks", func(_ string, _ *module.Config) (module.Module, error) {
+		return &fakeMessageHooks{}, nil
+	})
+}
+
+func tUtilMessageHooksInitialize() {
+	module.Initialize(&module.Config{Enabled: map[string]struct{}{"fake_message_hooks": {}}})
+}
+
+func tUtilMessageHooks(host string) *fakeMessageHooks {
+	return module.Lookup(host, "fake_message_hooks").(*fakeMessageHooks)
+}
+
 func TestC2SManager(t *testing.T) {
 	host.Initialize([]host.Config{{Name: "jackal.im"}})
 	Initialize(&Config{})
@@ -52,16 +84,18 @@ func TestC2SManager(t *testing.T) {
 	Bind(strm4)
 	Bind(strm5)
 
-	require.Equal(t, 2, len(UserStreams("ortuman")))
-	require.Equal(t, 1, len(UserStreams("hamlet")))
-	require.Equal(t, 1, len(UserStreams("romeo")))
-	require.Equal(t, 1, len(UserStreams("juliet")))
+	require.Equal(t, 2, len(UserStreams("ortuman", "jackal.im")))
+	require.Equal(t, 1, len(UserStreams("hamlet", "jackal.im")))
+	require.Equal(t, 1, len(UserStreams("romeo", "jackal.im")))
+	require.Equal(t, 1, len(UserStreams("juliet", "jackal.im")))
+	require.Equal(t, 5, len(LocalStreams()))
 
 	Unbind(strm5)
 	Unbind(strm4)
 	Unbind(strm3)
 	Unbind(strm2)
 	Unbind(strm1)
+	require.Equal(t, 0, len(LocalStreams()))
 }
 
 func TestC2SManager_Routing(t *testing.T) {
@@ -104,7 +138,7 @@ func TestC2SManager_Routing(t *testing.T) {
 	require.Equal(t, memstorage.ErrMockedError, Route(iq))
 	storage.DeactivateMockedError()
 
-	storage.Instance().InsertOrUpdateUser(&model.User{Username: "hamlet", Password: ""})
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "hamlet", Domain: "jackal.im", Password: ""})
 	require.Equal(t, ErrNotAuthenticated, Route(iq))
 
 	stm4 := stream.NewMockC2S(uuid.New(), j4)
@@ -153,6 +187,64 @@ func TestC2SManager_Routing(t *testing.T) {
 	require.Equal(t, msgID, elem.ID())
 }
 
+func TestC2SManager_MultipleHosts(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}, {Name: "example.org"}})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	Initialize(&Config{})
+	defer func() {
+		Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	j1, _ := jid.NewWithString("ortuman@jackal.im/balcony", false)
+	j2, _ := jid.NewWithString("ortuman@example.org/balcony", false)
+	j3, _ := jid.NewWithString("hamlet@jackal.im/garden", false)
+	stm1 := stream.NewMockC2S(uuid.New(), j1)
+	stm2 := stream.NewMockC2S(uuid.New(), j2)
+
+	Bind(stm1)
+	Bind(stm2)
+
+	require.Equal(t, 1, len(UserStreams("ortuman", "jackal.im")))
+	require.Equal(t, 1, len(UserStreams("ortuman", "example.org")))
+	require.Equal(t, stm2, UserStreams("ortuman", "example.org")[0])
+
+	// full JID routing
+	msgID := uuid.New()
+	msg := xml.NewMessageType(msgID, xml.ChatType)
+	msg.SetFromJID(j3)
+	msg.SetToJID(j2)
+	require.Nil(t, Route(msg))
+	require.Equal(t, msgID, stm2.FetchElement().ID())
+
+	// bare JID routing
+	msgID = uuid.New()
+	msg = xml.NewMessageType(msgID, xml.ChatType)
+	msg.SetFromJID(j3)
+	msg.SetToJID(j2.ToBareJID())
+	require.Nil(t, Route(msg))
+	require.Equal(t, msgID, stm2.FetchElement().ID())
+
+	// homonymous user only receives its own stanzas
+	msgID = uuid.New()
+	msg = xml.NewMessageType(msgID, xml.ChatType)
+	msg.SetFromJID(j3)
+	msg.SetToJID(j1.ToBareJID())
+	require.Nil(t, Route(msg))
+	require.Equal(t, msgID, stm1.FetchElement().ID())
+
+	Unbind(stm2)
+	require.Equal(t, 0, len(UserStreams("ortuman", "example.org")))
+	require.Equal(t, 1, len(UserStreams("ortuman", "jackal.im")))
+
+	msg.SetToJID(j2.ToBareJID())
+	require.Equal(t, ErrNotExistingAccount, Route(msg))
+
+	Unbind(stm1)
+	require.Equal(t, 0, len(LocalStreams()))
+}
+
 func TestC2SManager_BlockedJID(t *testing.T) {
 	host.Initialize([]host.Config{{Name: "jackal.im"}})
 	storage.Initialize(&storage.Config{Type: storage.Memory})
@@ -176,53 +268,57 @@ func TestC2SManager_BlockedJID(t *testing.T) {
 	// node + domain + resource
 	bl1 := []model.BlockListItem{{
 		Username: "ortuman",
+		Domain:   "jackal.im",
 		JID:      "hamlet@jackal.im/garden",
 	}}
 	storage.Instance().InsertBlockListItems(bl1)
-	require.False(t, IsBlockedJID(j2, "ortuman"))
-	require.True(t, IsBlockedJID(j3, "ortuman"))
+	require.False(t, IsBlockedJID(j2, "ortuman", "jackal.im"))
+	require.True(t, IsBlockedJID(j3, "ortuman", "jackal.im"))
 
 	storage.Instance().DeleteBlockListItems(bl1)
 
 	// node + domain
 	bl2 := []model.BlockListItem{{
 		Username: "ortuman",
+		Domain:   "jackal.im",
 		JID:      "hamlet@jackal.im",
 	}}
 	storage.Instance().InsertBlockListItems(bl2)
-	ReloadBlockList("ortuman")
+	ReloadBlockList("ortuman", "jackal.im")
 
-	require.True(t, IsBlockedJID(j2, "ortuman"))
-	require.True(t, IsBlockedJID(j3, "ortuman"))
-	require.False(t, IsBlockedJID(j4, "ortuman"))
+	require.True(t, IsBlockedJID(j2, "ortuman", "jackal.im"))
+	require.True(t, IsBlockedJID(j3, "ortuman", "jackal.im"))
+	require.False(t, IsBlockedJID(j4, "ortuman", "jackal.im"))
 
 	storage.Instance().DeleteBlockListItems(bl2)
 
 	// domain + resource
 	bl3 := []model.BlockListItem{{
 		Username: "ortuman",
+		Domain:   "jackal.im",
 		JID:      "jackal.im/balcony",
 	}}
 	storage.Instance().InsertBlockListItems(bl3)
-	ReloadBlockList("ortuman")
+	ReloadBlockList("ortuman", "jackal.im")
 
-	require.True(t, IsBlockedJID(j2, "ortuman"))
-	require.False(t, IsBlockedJID(j3, "ortuman"))
-	require.False(t, IsBlockedJID(j4, "ortuman"))
+	require.True(t, IsBlockedJID(j2, "ortuman", "jackal.im"))
+	require.False(t, IsBlockedJID(j3, "ortuman", "jackal.im"))
+	require.False(t, IsBlockedJID(j4, "ortuman", "jackal.im"))
 
 	storage.Instance().DeleteBlockListItems(bl3)
 
 	// domain
 	bl4 := []model.BlockListItem{{
 		Username: "ortuman",
+		Domain:   "jackal.im",
 		JID:      "jackal.im",
 	}}
 	storage.Instance().InsertBlockListItems(bl4)
-	ReloadBlockList("ortuman")
+	ReloadBlockList("ortuman", "jackal.im")
 
-	require.True(t, IsBlockedJID(j2, "ortuman"))
-	require.True(t, IsBlockedJID(j3, "ortuman"))
-	require.True(t, IsBlockedJID(j4, "ortuman"))
+	require.True(t, IsBlockedJID(j2, "ortuman", "jackal.im"))
+	require.True(t, IsBlockedJID(j3, "ortuman", "jackal.im"))
+	require.True(t, IsBlockedJID(j4, "ortuman", "jackal.im"))
 
 	storage.Instance().DeleteBlockListItems(bl4)
 
@@ -232,3 +328,160 @@ func TestC2SManager_BlockedJID(t *testing.T) {
 	iq.SetToJID(j1)
 	require.Equal(t, ErrBlockedJID, Route(iq))
 }
+
+func TestC2SManager_ArchiveMessage(t *testing.T) {
+	outS2S := fakeS2SOut{}
+	host.Initialize([]host.Config{{Name: "jackal.im"}, {Name: "jabber.org"}})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	tUtilMessageHooksInitialize()
+	Initialize(&Config{
+		GetS2SOut: func(_, _ string) (stream.S2SOut, error) { return &outS2S, nil },
+	})
+	defer func() {
+		Shutdown()
+		module.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+	hooks := tUtilMessageHooks("jackal.im")
+
+	j1, _ := jid.NewWithString("ortuman@jackal.im/balcony", false)
+	j2, _ := jid.NewWithString("hamlet@jackal.im/balcony", false)
+	j3, _ := jid.NewWithString("juliet@example.org/garden", false)
+	stm1 := stream.NewMockC2S(uuid.New(), j1)
+	Bind(stm1)
+
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "hamlet", Domain: "jackal.im", Password: ""})
+
+	msg := xml.NewMessageType(uuid.New(), xml.ChatType)
+	msg.SetFromJID(j2)
+	msg.SetToJID(j1)
+	require.Nil(t, Route(msg))
+	stm1.FetchElement()
+	require.Equal(t, 1, len(hooks.routed))
+
+	// offline recipient
+	msg = xml.NewMessageType(uuid.New(), xml.ChatType)
+	msg.SetFromJID(j1)
+	msg.SetToJID(j2)
+	require.Equal(t, ErrNotAuthenticated, Route(msg))
+	require.Equal(t, 2, len(hooks.routed))
+
+	// remote recipient
+	msg.SetToJID(j3)
+	require.Nil(t, Route(msg))
+	require.Equal(t, 3, len(hooks.routed))
+
+	// non message stanzas are never archived
+	iq := xml.NewIQType(uuid.New(), xml.GetType)
+	iq.SetFromJID(j2)
+	iq.SetToJID(j1)
+	require.Nil(t, Route(iq))
+	require.Equal(t, 3, len(hooks.routed))
+
+	// every involved local host gets notified
+	j4, _ := jid.NewWithString("romeo@jabber.org/orchard", false)
+	stm4 := stream.NewMockC2S(uuid.New(), j4)
+	Bind(stm4)
+
+	msg = xml.NewMessageType(uuid.New(), xml.ChatType)
+	msg.SetFromJID(j1)
+	msg.SetToJID(j4)
+	require.Nil(t, Route(msg))
+	stm4.FetchElement()
+	require.Equal(t, 4, len(hooks.routed))
+	require.Equal(t, 1, len(tUtilMessageHooks("jabber.org").routed))
+}
+
+func TestC2SManager_CarbonCopy(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	tUtilMessageHooksInitialize()
+	Initialize(&Config{})
+	defer func() {
+		Shutdown()
+		module.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+	hooks := tUtilMessageHooks("jackal.im")
+
+	j1, _ := jid.NewWithString("ortuman@jackal.im/balcony", false)
+	j2, _ := jid.NewWithString("hamlet@jackal.im/balcony", false)
+	stm1 := stream.NewMockC2S(uuid.New(), j1)
+	Bind(stm1)
+
+	msg := xml.NewMessageType(uuid.New(), xml.ChatType)
+	msg.SetFromJID(j2)
+	msg.SetToJID(j1)
+	require.Nil(t, Route(msg))
+	stm1.FetchElement()
+
+	msg.SetToJID(j1.ToBareJID())
+	require.Nil(t, Route(msg))
+	stm1.FetchElement()
+
+	require.Equal(t, 2, len(hooks.delivered))
+	require.Equal(t, stm1, hooks.delivered[0])
+}
+
+func TestC2SManager_RouteComponent(t *testing.T) {
+	j1, _ := jid.NewWithString("ortuman@jackal.im/balcony", false)
+	j2, _ := jid.NewWithString("weather@bot.jackal.im", false)
+	comp := stream.NewMockC2S(uuid.New(), j2)
+
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	Initialize(&Config{
+		GetComponent: func(domain string) stream.Component {
+			if domain == "bot.jackal.im" {
+				return comp
+			}
+			return nil
+		},
+	})
+	defer func() {
+		Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	msg := xml.NewMessageType(uuid.New(), xml.ChatType)
+	msg.SetFromJID(j1)
+	msg.SetToJID(j2)
+	require.Nil(t, Route(msg))
+
+	elem := comp.FetchElement()
+	require.Equal(t, msg.ID(), elem.ID())
+}
+
+func TestC2SManager_Metrics(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	Initialize(&Config{})
+	defer func() {
+		Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	j1, _ := jid.NewWithString("ortuman@jackal.im/balcony", false)
+	j2, _ := jid.NewWithString("hamlet@jackal.im/garden", false)
+	j3, _ := jid.NewWithString("juliet@example.org/garden", false)
+
+	routed := testutil.ToFloat64(routedStanzas.WithLabelValues("message"))
+	notExisting := testutil.ToFloat64(routeErrors.WithLabelValues("not_existing_account"))
+	failedRemote := testutil.ToFloat64(routeErrors.WithLabelValues("failed_remote_connect"))
+
+	msg := xml.NewMessageType(uuid.New(), xml.ChatType)
+	msg.SetFromJID(j1)
+	msg.SetToJID(j2)
+	require.Equal(t, ErrNotExistingAccount, Route(msg))
+
+	msg.SetToJID(j3)
+	require.Equal(t, ErrFailedRemoteConnect, MustRoute(msg))
+
+	require.Equal(t, routed+2, testutil.ToFloat64(routedStanzas.WithLabelValues("message")))
+	require.Equal(t, notExisting+1, testutil.ToFloat64(routeErrors.WithLabelValues("not_existing_account")))
+	require.Equal(t, failedRemote+1, testutil.ToFloat64(routeErrors.WithLabelValues("failed_remote_connect")))
+}
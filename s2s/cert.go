@@ -0,0 +1,72 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package s2s
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	"github.com/ortuman/jackal/util"
+)
+
+var errNoPeerCertificate = errors.New("s2s: no peer certificate")
+
+// certValidator validates remote server certificates (XEP-0178).
+type certValidator struct {
+	rootCAs          *x509.CertPool
+	requireValidCert []string
+}
+
+func newCertValidator(cfg *Config) *certValidator {
+	return &certValidator{rootCAs: cfg.RootCAs, requireValidCert: cfg.RequireValidCert}
+}
+
+// validate verifies a peer certificate chain against validator root CAs,
+// or system ones if none has been specified, checking that leaf certificate
+// is issued for the provided domain.
+func (v *certValidator) validate(certs []*x509.Certificate, domain string) error {
+	if len(certs) == 0 {
+		return errNoPeerCertificate
+	}
+	opts := x509.VerifyOptions{
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	if v != nil {
+		opts.Roots = v.rootCAs
+	}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	leaf := certs[0]
+	if _, err := leaf.Verify(opts); err != nil {
+		return err
+	}
+	if leaf.VerifyHostname(domain) == nil {
+		return nil
+	}
+	for _, addr := range util.XMPPAddresses(leaf) {
+		if addr == domain {
+			return nil
+		}
+	}
+	return fmt.Errorf("s2s: certificate is not valid for %s", domain)
+}
+
+// isRequired returns whether or not a valid certificate
+// is required for a given remote domain.
+func (v *certValidator) isRequired(domain string) bool {
+	if v == nil {
+		return false
+	}
+	for _, d := range v.requireValidCert {
+		if d == domain || d == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package s2s
+
+import (
+	"crypto/x509"
+	"testing"
+
+	"github.com/ortuman/jackal/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertValidator_Validate(t *testing.T) {
+	v := tUtilCertValidator(t)
+
+	require.Equal(t, errNoPeerCertificate, v.validate(nil, "jabber.org"))
+
+	certs := tUtilPeerCertificates(t, "jabber.org")
+	require.Nil(t, v.validate(certs, "jabber.org"))
+
+	// certificate issued for a different domain
+	require.NotNil(t, v.validate(certs, "jackal.im"))
+
+	// unknown authority
+	v = &certValidator{rootCAs: x509.NewCertPool()}
+	require.NotNil(t, v.validate(certs, "jabber.org"))
+
+	// nil validator uses system root CAs
+	var nilValidator *certValidator
+	require.NotNil(t, nilValidator.validate(certs, "jabber.org"))
+}
+
+func TestCertValidator_IsRequired(t *testing.T) {
+	var v *certValidator
+	require.False(t, v.isRequired("jabber.org"))
+
+	v = tUtilCertValidator(t, "jabber.org")
+	require.True(t, v.isRequired("jabber.org"))
+	require.False(t, v.isRequired("jackal.im"))
+
+	v = tUtilCertValidator(t, "*")
+	require.True(t, v.isRequired("jackal.im"))
+}
+
+func tUtilCertValidator(t *testing.T, requireValidCert ...string) *certValidator {
+	rootCAs := x509.NewCertPool()
+	for _, cert := range tUtilPeerCertificates(t, "localhost") {
+		rootCAs.AddCert(cert)
+	}
+	return &certValidator{rootCAs: rootCAs, requireValidCert: requireValidCert}
+}
+
+func tUtilPeerCertificates(t *testing.T, domain string) []*x509.Certificate {
+	cer, err := util.LoadCertificate("../testdata/cert/test.server.key", "../testdata/cert/test.server.crt", "localhost")
+	require.Nil(t, err)
+
+	var certs []*x509.Certificate
+	for _, asn1Data := range cer.Certificate {
+		cert, err := x509.ParseCertificate(asn1Data)
+		require.Nil(t, err)
+		cert.DNSNames = []string{domain}
+		certs = append(certs, cert)
+	}
+	return certs
+}
@@ -7,20 +7,22 @@ package s2s
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"time"
 
-	"github.com/ortuman/jackal/module"
 	"github.com/ortuman/jackal/transport"
+	"github.com/ortuman/jackal/util"
 	"github.com/ortuman/jackal/xml"
 	"github.com/pkg/errors"
 )
 
 const (
-	defaultTransportPort      = 5269
-	defaultTransportKeepAlive = time.Duration(10) * time.Minute
-	defaultDialTimeout        = time.Duration(15) * time.Second
-	defaultConnectTimeout     = time.Duration(5) * time.Second
-	defaultMaxStanzaSize      = 131072
+	defaultTransportPort          = 5269
+	defaultDirectTLSTransportPort = 5270
+	defaultTransportKeepAlive     = time.Duration(10) * time.Minute
+	defaultDialTimeout            = time.Duration(15) * time.Second
+	defaultConnectTimeout         = time.Duration(5) * time.Second
+	defaultMaxStanzaSize          = 131072
 )
 
 // TransportConfig represents s2s transport configuration.
@@ -28,12 +30,14 @@ type TransportConfig struct {
 	BindAddress string
 	Port        int
 	KeepAlive   time.Duration
+	DirectTLS   bool
 }
 
 type transportConfigProxy struct {
 	BindAddress string `yaml:"bind_addr"`
 	Port        int    `yaml:"port"`
 	KeepAlive   int    `yaml:"keep_alive"`
+	DirectTLS   bool   `yaml:"direct_tls"`
 }
 
 // UnmarshalYAML satisfies Unmarshaler interface.
@@ -43,9 +47,14 @@ func (c *TransportConfig) UnmarshalYAML(unmarshal func(interface{}) error) error
 		return err
 	}
 	c.BindAddress = p.BindAddress
+	c.DirectTLS = p.DirectTLS
 	c.Port = p.Port
 	if c.Port == 0 {
-		c.Port = defaultTransportPort
+		if c.DirectTLS {
+			c.Port = defaultDirectTLSTransportPort
+		} else {
+			c.Port = defaultTransportPort
+		}
 	}
 	if p.KeepAlive > 0 {
 		c.KeepAlive = time.Duration(p.KeepAlive) * time.Second
@@ -69,6 +78,14 @@ type Config struct {
 	DialbackSecret string
 	MaxStanzaSize  int
 	Transport      TransportConfig
+
+	// RootCAs contains system and configured CA certificates
+	// used to validate remote server certificates.
+	RootCAs *x509.CertPool
+
+	// RequireValidCert contains the set of remote domains whose
+	// certificates must be valid ("*" matches any domain).
+	RequireValidCert []string
 }
 
 type configProxy struct {
@@ -78,6 +95,8 @@ type configProxy struct {
 	DialbackSecret string          `yaml:"dialback_secret"`
 	MaxStanzaSize  int             `yaml:"max_stanza_size"`
 	Transport      TransportConfig `yaml:"transport"`
+	CAPath         string          `yaml:"ca_path"`
+	RequireValid   []string        `yaml:"require_valid_cert"`
 }
 
 // UnmarshalYAML satisfies Unmarshaler interface.
@@ -107,18 +126,31 @@ func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	if c.MaxStanzaSize == 0 {
 		c.MaxStanzaSize = defaultMaxStanzaSize
 	}
+	// load certificate validation roots
+	rootCAs, err := x509.SystemCertPool()
+	if err != nil {
+		rootCAs = x509.NewCertPool()
+	}
+	if len(p.CAPath) > 0 {
+		if err := util.AppendCertsFromFile(rootCAs, p.CAPath); err != nil {
+			return err
+		}
+	}
+	c.RootCAs = rootCAs
+	c.RequireValidCert = p.RequireValid
 	return nil
 }
 
 type streamConfig struct {
-	modConfig      *module.Config
 	keyGen         *keyGen
 	localDomain    string
 	remoteDomain   string
 	connectTimeout time.Duration
 	tls            *tls.Config
+	directTLS      bool
 	transport      transport.Transport
 	maxStanzaSize  int
 	dbVerify       xml.XElement
 	dialer         *dialer
+	certValidator  *certValidator
 }
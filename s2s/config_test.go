@@ -40,6 +40,15 @@ keep_alive: 200
 	require.Equal(t, "127.0.0.1", trCfg.BindAddress)
 	require.Equal(t, 5999, trCfg.Port)
 	require.Equal(t, time.Duration(200)*time.Second, trCfg.KeepAlive)
+
+	rawCfg = `
+direct_tls: true
+`
+	trCfg = TransportConfig{}
+	err = yaml.Unmarshal([]byte(rawCfg), &trCfg)
+	require.Nil(t, err)
+	require.True(t, trCfg.DirectTLS)
+	require.Equal(t, 5270, trCfg.Port)
 }
 
 func TestConfig(t *testing.T) {
@@ -84,4 +93,26 @@ max_stanza_size: 8192
 	require.Equal(t, time.Duration(300)*time.Second, cfg.DialTimeout)
 	require.Equal(t, time.Duration(250)*time.Second, cfg.ConnectTimeout)
 	require.Equal(t, 8192, cfg.MaxStanzaSize)
+	require.NotNil(t, cfg.RootCAs)
+	require.Equal(t, 0, len(cfg.RequireValidCert))
+
+	rawCfg = `
+enabled: true
+dialback_secret: s3cr3t
+ca_path: ../testdata/cert/test.ca.crt
+require_valid_cert:
+  - jabber.org
+`
+	err = yaml.Unmarshal([]byte(rawCfg), &cfg)
+	require.Nil(t, err)
+	require.NotNil(t, cfg.RootCAs)
+	require.Equal(t, []string{"jabber.org"}, cfg.RequireValidCert)
+
+	rawCfg = `
+enabled: true
+dialback_secret: s3cr3t
+ca_path: ../testdata/cert/not_a_file.crt
+`
+	err = yaml.Unmarshal([]byte(rawCfg), &cfg)
+	require.NotNil(t, err)
 }
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/log"
 	"github.com/ortuman/jackal/transport"
 )
 
@@ -20,34 +21,40 @@ type dialer struct {
 	cfg         *Config
 	srvResolve  func(service, proto, name string) (cname string, addrs []*net.SRV, err error)
 	dialTimeout func(network, address string, timeout time.Duration) (net.Conn, error)
+	tlsClient   func(conn net.Conn, cfg *tls.Config, timeout time.Duration) (net.Conn, error)
 }
 
 func newDialer(cfg *Config) *dialer {
-	return &dialer{cfg: cfg, srvResolve: net.LookupSRV, dialTimeout: net.DialTimeout}
+	return &dialer{cfg: cfg, srvResolve: net.LookupSRV, dialTimeout: net.DialTimeout, tlsClient: tlsClientHandshake}
 }
 
 func newDialerCopy(d *dialer) *dialer {
-	return &dialer{cfg: d.cfg, srvResolve: d.srvResolve, dialTimeout: d.dialTimeout}
+	return &dialer{cfg: d.cfg, srvResolve: d.srvResolve, dialTimeout: d.dialTimeout, tlsClient: d.tlsClient}
 }
 
 func (d *dialer) dial(localDomain, remoteDomain string) (*streamConfig, error) {
-	_, addrs, err := d.srvResolve("xmpp-server", "tcp", remoteDomain)
-	if err != nil {
-		return nil, err
-	}
-	var target string
-	if len(addrs) == 1 && addrs[0].Target == "." {
-		target = remoteDomain + ":5269"
-	} else {
-		target = strings.TrimSuffix(addrs[0].Target, ".")
-	}
-	conn, err := d.dialTimeout("tcp", target+":"+strconv.Itoa(int(addrs[0].Port)), d.cfg.DialTimeout)
-	if err != nil {
-		return nil, err
-	}
 	tlsConfig := &tls.Config{
-		ServerName:   remoteDomain,
-		Certificates: host.Certificates(),
+		ServerName:           remoteDomain,
+		GetClientCertificate: host.GetClientCertificate(localDomain),
+
+		// remote certificate is validated once the stream has been secured
+		// so that dialback can still be used in case it's not valid.
+		InsecureSkipVerify: true,
+	}
+	// try direct TLS first (https://xmpp.org/extensions/xep-0368.html)
+	conn := d.dialDirectTLS(remoteDomain, tlsConfig)
+	directTLS := conn != nil
+	if !directTLS {
+		_, addrs, err := d.srvResolve("xmpp-server", "tcp", remoteDomain)
+		if err != nil {
+			dialFailures.Inc()
+			return nil, err
+		}
+		conn, err = d.dialTimeout("tcp", srvTarget(addrs, remoteDomain, defaultTransportPort), d.cfg.DialTimeout)
+		if err != nil {
+			dialFailures.Inc()
+			return nil, err
+		}
 	}
 	tr := transport.NewSocketTransport(conn, d.cfg.Transport.KeepAlive)
 	return &streamConfig{
@@ -56,6 +63,48 @@ func (d *dialer) dial(localDomain, remoteDomain string) (*streamConfig, error) {
 		remoteDomain:  remoteDomain,
 		transport:     tr,
 		tls:           tlsConfig,
+		directTLS:     directTLS,
 		maxStanzaSize: d.cfg.MaxStanzaSize,
+		certValidator: newCertValidator(d.cfg),
 	}, nil
 }
+
+// dialDirectTLS returns a TLS secured connection in case remote
+// domain announces an 'xmpps-server' service, or nil otherwise.
+func (d *dialer) dialDirectTLS(remoteDomain string, tlsConfig *tls.Config) net.Conn {
+	_, addrs, err := d.srvResolve("xmpps-server", "tcp", remoteDomain)
+	if err != nil || len(addrs) == 0 || (len(addrs) == 1 && addrs[0].Target == ".") {
+		return nil
+	}
+	conn, err := d.dialTimeout("tcp", srvTarget(addrs, remoteDomain, defaultDirectTLSTransportPort), d.cfg.DialTimeout)
+	if err != nil {
+		log.Warnf("s2s: direct TLS dial failed (domain: %s): %v", remoteDomain, err)
+		return nil
+	}
+	cfg := tlsConfig.Clone()
+	cfg.NextProtos = []string{xmppServerALPN}
+	tlsConn, err := d.tlsClient(conn, cfg, d.cfg.DialTimeout)
+	if err != nil {
+		log.Warnf("s2s: direct TLS handshake failed (domain: %s): %v", remoteDomain, err)
+		conn.Close()
+		return nil
+	}
+	return tlsConn
+}
+
+func srvTarget(addrs []*net.SRV, remoteDomain string, defaultPort int) string {
+	if len(addrs) == 1 && addrs[0].Target == "." {
+		return remoteDomain + ":" + strconv.Itoa(defaultPort)
+	}
+	return strings.TrimSuffix(addrs[0].Target, ".") + ":" + strconv.Itoa(int(addrs[0].Port))
+}
+
+func tlsClientHandshake(conn net.Conn, cfg *tls.Config, timeout time.Duration) (net.Conn, error) {
+	tlsConn := tls.Client(conn, cfg)
+	tlsConn.SetDeadline(time.Now().Add(timeout))
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, err
+	}
+	tlsConn.SetDeadline(time.Time{})
+	return tlsConn, nil
+}
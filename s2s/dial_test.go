@@ -6,12 +6,13 @@
 package s2s
 
 import (
+	"crypto/tls"
 	"net"
 	"testing"
 	"time"
 
-	"github.com/ortuman/jackal/module"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 )
 
@@ -28,30 +29,35 @@ func TestS2SDial(t *testing.T) {
 	}
 
 	// not enabled
-	Initialize(&cfg, &module.Config{})
+	Initialize(&cfg)
 	out, err := GetS2SOut("jackal.im", "jabber.org")
 	require.Nil(t, out)
 	require.NotNil(t, err)
 	Shutdown()
 
+	mockedErr := errors.New("dialer mocked error")
 	resolver := func(service, proto, name string) (cname string, addrs []*net.SRV, err error) {
+		if service == "xmpps-server" {
+			return "", nil, mockedErr
+		}
 		return "", []*net.SRV{{Target: "xmpp.jabber.org", Port: 5269}}, nil
 	}
-	mockedErr := errors.New("dialer mocked error")
 
 	// resolver error...
 	cfg.Enabled = true
-	Initialize(&cfg, &module.Config{})
+	Initialize(&cfg)
 	defaultDialer.srvResolve = func(_, _, _ string) (cname string, addrs []*net.SRV, err error) {
 		return "", nil, mockedErr
 	}
+	failures := testutil.ToFloat64(dialFailures)
 	out, err = GetS2SOut("jackal.im", "jabber.org")
 	require.Nil(t, out)
 	require.Equal(t, mockedErr, err)
+	require.Equal(t, failures+1, testutil.ToFloat64(dialFailures))
 	Shutdown()
 
 	// dialer error...
-	Initialize(&cfg, &module.Config{})
+	Initialize(&cfg)
 	defaultDialer.srvResolve = resolver
 	defaultDialer.dialTimeout = func(_, _ string, _ time.Duration) (net.Conn, error) {
 		return nil, mockedErr
@@ -59,10 +65,11 @@ func TestS2SDial(t *testing.T) {
 	out, err = GetS2SOut("jackal.im", "jabber.org")
 	require.Nil(t, out)
 	require.Equal(t, mockedErr, err)
+	require.Equal(t, failures+2, testutil.ToFloat64(dialFailures))
 	Shutdown()
 
 	// success
-	Initialize(&cfg, &module.Config{})
+	Initialize(&cfg)
 	defaultDialer.srvResolve = resolver
 	defaultDialer.dialTimeout = func(_, _ string, _ time.Duration) (net.Conn, error) {
 		return newFakeSocketConn(), nil
@@ -72,3 +79,54 @@ func TestS2SDial(t *testing.T) {
 	require.Nil(t, err)
 	Shutdown()
 }
+
+func TestS2SDialDirectTLS(t *testing.T) {
+	cfg := &Config{
+		DialTimeout: time.Second,
+		Transport: TransportConfig{
+			KeepAlive: time.Duration(600) * time.Second,
+		},
+	}
+	d := newDialer(cfg)
+	d.srvResolve = func(service, proto, name string) (cname string, addrs []*net.SRV, err error) {
+		if service == "xmpps-server" {
+			return "", []*net.SRV{{Target: "xmpps.jabber.org.", Port: 5270}}, nil
+		}
+		return "", []*net.SRV{{Target: "xmpp.jabber.org.", Port: 5269}}, nil
+	}
+	var dialed []string
+	d.dialTimeout = func(_, address string, _ time.Duration) (net.Conn, error) {
+		dialed = append(dialed, address)
+		return newFakeSocketConn(), nil
+	}
+	var nextProtos []string
+	d.tlsClient = func(conn net.Conn, cfg *tls.Config, _ time.Duration) (net.Conn, error) {
+		nextProtos = cfg.NextProtos
+		return conn, nil
+	}
+	stmCfg, err := d.dial("jackal.im", "jabber.org")
+	require.Nil(t, err)
+	require.True(t, stmCfg.directTLS)
+	require.Equal(t, []string{"xmpps.jabber.org:5270"}, dialed)
+	require.Equal(t, []string{"xmpp-server"}, nextProtos)
+
+	// handshake failure falls back to STARTTLS
+	dialed = nil
+	d.tlsClient = func(_ net.Conn, _ *tls.Config, _ time.Duration) (net.Conn, error) {
+		return nil, errors.New("tls: handshake failure")
+	}
+	stmCfg, err = d.dial("jackal.im", "jabber.org")
+	require.Nil(t, err)
+	require.False(t, stmCfg.directTLS)
+	require.Equal(t, []string{"xmpps.jabber.org:5270", "xmpp.jabber.org:5269"}, dialed)
+
+	// direct TLS not available
+	dialed = nil
+	d.srvResolve = func(service, proto, name string) (cname string, addrs []*net.SRV, err error) {
+		return "", []*net.SRV{{Target: ".", Port: 0}}, nil
+	}
+	stmCfg, err = d.dial("jackal.im", "jabber.org")
+	require.Nil(t, err)
+	require.False(t, stmCfg.directTLS)
+	require.Equal(t, []string{"jabber.org:5269"}, dialed)
+}
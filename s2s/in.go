@@ -14,6 +14,7 @@ import (
 	"github.com/ortuman/jackal/errors"
 	"github.com/ortuman/jackal/host"
 	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/module"
 	"github.com/ortuman/jackal/module/roster"
 	"github.com/ortuman/jackal/router"
 	"github.com/ortuman/jackal/session"
@@ -35,7 +36,6 @@ type inStream struct {
 	state         uint32
 	connectTm     *time.Timer
 	sess          *session.Session
-	ph            *roster.PresenceHandler
 	secured       uint32
 	authenticated uint32
 	actorCh       chan func()
@@ -50,9 +50,11 @@ func newInStream(cfg *streamConfig) *inStream {
 	// register into stream container
 	inContainer.set(s)
 
-	// initialize modules
-	if _, ok := s.cfg.modConfig.Enabled["roster"]; ok {
-		s.ph = roster.NewPresenceHandler(&s.cfg.modConfig.Roster)
+	connections.WithLabelValues(directionIn).Inc()
+	connectionsTotal.WithLabelValues(directionIn).Inc()
+
+	if cfg.directTLS {
+		atomic.StoreUint32(&s.secured, 1)
 	}
 
 	// start s2s in session
@@ -138,6 +140,11 @@ func (s *inStream) handleConnecting(elem xml.XElement) {
 	j, _ := jid.New("", s.localDomain, "", true)
 	s.sess.SetJID(j)
 
+	// reject hosts without an associated certificate
+	if !host.HasCertificate(s.localDomain) {
+		s.disconnectWithStreamError(streamerror.ErrHostUnknown)
+		return
+	}
 	s.sess.Open()
 
 	features := xml.NewElementName("stream:features")
@@ -153,13 +160,21 @@ func (s *inStream) handleConnecting(elem xml.XElement) {
 		return
 	}
 	if !s.isAuthenticated() {
-		// offer external authentication
-		mechanisms := xml.NewElementName("mechanisms")
-		mechanisms.SetNamespace(saslNamespace)
-		extMech := xml.NewElementName("mechanism")
-		extMech.SetText("EXTERNAL")
-		mechanisms.AppendElement(extMech)
-		features.AppendElement(mechanisms)
+		certErr := s.validatePeerCertificate()
+		if certErr != nil && s.cfg.certValidator.isRequired(s.remoteDomain) {
+			log.Infof("s2s in stream rejected... invalid certificate (domain: %s): %v", s.remoteDomain, certErr)
+			s.disconnectWithStreamError(streamerror.ErrPolicyViolation)
+			return
+		}
+		if certErr == nil {
+			// offer external authentication
+			mechanisms := xml.NewElementName("mechanisms")
+			mechanisms.SetNamespace(saslNamespace)
+			extMech := xml.NewElementName("mechanism")
+			extMech.SetText("EXTERNAL")
+			mechanisms.AppendElement(extMech)
+			features.AppendElement(mechanisms)
+		}
 	}
 	dbBack := xml.NewElementNamespace("dialback", dialbackNamespace)
 	dbBack.AppendElement(xml.NewElementName("errors"))
@@ -188,10 +203,16 @@ func (s *inStream) handleConnected(elem xml.XElement) {
 	default:
 		switch elem := elem.(type) {
 		case xml.Stanza:
-			if presence, ok := elem.(*xml.Presence); ok && s.ph != nil && presence.ToJID().IsBare() {
-				s.ph.ProcessPresence(presence)
+			if svc := module.Service(elem.ToJID().Domain()); svc != nil {
+				svc.ProcessStanza(elem)
 				return
 			}
+			if presence, ok := elem.(*xml.Presence); ok && presence.ToJID().IsBare() {
+				if rst, ok := module.Lookup(presence.ToJID().Domain(), "roster").(*roster.Roster); ok {
+					rst.PresenceHandler().ProcessPresence(presence)
+					return
+				}
+			}
 			router.Route(elem)
 		}
 	}
@@ -209,9 +230,9 @@ func (s *inStream) proceedStartTLS(elem xml.XElement) {
 	s.writeElement(xml.NewElementNamespace("proceed", tlsNamespace))
 
 	s.cfg.transport.StartTLS(&tls.Config{
-		ServerName:   s.localDomain,
-		ClientAuth:   tls.VerifyClientCertIfGiven,
-		Certificates: host.Certificates(),
+		ServerName:     s.localDomain,
+		ClientAuth:     tls.RequestClientCert, // validated at authentication time
+		GetCertificate: host.GetCertificate(s.localDomain),
 	}, false)
 	atomic.StoreUint32(&s.secured, 1)
 
@@ -229,16 +250,15 @@ func (s *inStream) startAuthentication(elem xml.XElement) {
 		return
 	}
 	// validate initiating server certificate
-	certs := s.cfg.transport.PeerCertificates()
-	for _, cert := range certs {
-		for _, dnsName := range cert.DNSNames {
-			if dnsName == s.remoteDomain {
-				s.finishAuthentication()
-				return
-			}
-		}
+	if err := s.validatePeerCertificate(); err != nil {
+		s.failAuthentication("not-authorized", err.Error())
+		return
 	}
-	s.failAuthentication("bad-protocol", "failed to get peer certificate")
+	s.finishAuthentication()
+}
+
+func (s *inStream) validatePeerCertificate() error {
+	return s.cfg.certValidator.validate(s.cfg.transport.PeerCertificates(), s.remoteDomain)
 }
 
 func (s *inStream) finishAuthentication() {
@@ -387,6 +407,7 @@ func (s *inStream) disconnectClosingSession(closeSession bool) {
 		s.sess.Close()
 	}
 	inContainer.delete(s)
+	connections.WithLabelValues(directionIn).Dec()
 
 	s.setState(inDisconnected)
 	s.cfg.transport.Close()
@@ -14,11 +14,6 @@ import (
 	"time"
 
 	"github.com/ortuman/jackal/host"
-	"github.com/ortuman/jackal/module"
-	"github.com/ortuman/jackal/module/offline"
-	"github.com/ortuman/jackal/module/xep0077"
-	"github.com/ortuman/jackal/module/xep0092"
-	"github.com/ortuman/jackal/module/xep0199"
 	"github.com/ortuman/jackal/router"
 	"github.com/ortuman/jackal/storage"
 	"github.com/ortuman/jackal/stream"
@@ -46,7 +41,7 @@ func TestStream_Disconnect(t *testing.T) {
 }
 
 func TestStream_Features(t *testing.T) {
-	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	host.Initialize([]host.Config{tUtilHostConfig(t, "jackal.im")})
 	defer host.Shutdown()
 
 	// unsecured features
@@ -62,7 +57,7 @@ func TestStream_Features(t *testing.T) {
 	require.Equal(t, inConnected, stm.getState())
 
 	// secured features
-	stm, conn = tUtilInStreamInit(t, false)
+	stm, conn = tUtilInStreamInit(t, true)
 	atomic.StoreUint32(&stm.secured, 1)
 	tUtilInStreamOpen(conn)
 
@@ -74,6 +69,27 @@ func TestStream_Features(t *testing.T) {
 	require.NotNil(t, elem.Elements().ChildNamespace("dialback", dialbackNamespace))
 	require.Equal(t, inConnected, stm.getState())
 
+	// secured features (invalid peer certificate)
+	stm, conn = tUtilInStreamInit(t, false)
+	atomic.StoreUint32(&stm.secured, 1)
+	tUtilInStreamOpen(conn)
+
+	elem = conn.outboundRead()
+	require.Equal(t, "stream:stream", elem.Name())
+
+	elem = conn.outboundRead()
+	require.Nil(t, elem.Elements().ChildNamespace("mechanisms", saslNamespace))
+	require.NotNil(t, elem.Elements().ChildNamespace("dialback", dialbackNamespace))
+	require.Equal(t, inConnected, stm.getState())
+
+	// secured features (valid certificate required)
+	cfg, conn := tUtilInStreamDefaultConfig(t, false)
+	cfg.certValidator = tUtilCertValidator(t, "localhost")
+	stm = newInStream(cfg)
+	atomic.StoreUint32(&stm.secured, 1)
+	tUtilInStreamOpen(conn)
+	require.True(t, conn.waitClose())
+
 	// secured features (authenticated)
 	stm, conn = tUtilInStreamInit(t, false)
 	atomic.StoreUint32(&stm.secured, 1)
@@ -89,10 +105,21 @@ func TestStream_Features(t *testing.T) {
 	require.Equal(t, inConnected, stm.getState())
 }
 
-func TestStream_TLS(t *testing.T) {
+func TestStream_HostWithoutCertificate(t *testing.T) {
 	host.Initialize([]host.Config{{Name: "jackal.im"}})
 	defer host.Shutdown()
 
+	stm, conn := tUtilInStreamInit(t, false)
+	tUtilInStreamOpen(conn)
+
+	require.True(t, conn.waitClose())
+	require.Equal(t, inDisconnected, stm.getState())
+}
+
+func TestStream_TLS(t *testing.T) {
+	host.Initialize([]host.Config{tUtilHostConfig(t, "jackal.im")})
+	defer host.Shutdown()
+
 	stm, conn := tUtilInStreamInit(t, false)
 	tUtilInStreamOpen(conn)
 	_ = conn.outboundRead() // read stream opening...
@@ -127,7 +154,7 @@ func TestStream_TLS(t *testing.T) {
 }
 
 func TestStream_Authenticate(t *testing.T) {
-	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	host.Initialize([]host.Config{tUtilHostConfig(t, "jackal.im")})
 	defer host.Shutdown()
 
 	stm, conn := tUtilInStreamInit(t, false)
@@ -178,7 +205,7 @@ func TestStream_Authenticate(t *testing.T) {
 }
 
 func TestStream_DialbackVerify(t *testing.T) {
-	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	host.Initialize([]host.Config{tUtilHostConfig(t, "jackal.im")})
 	defer host.Shutdown()
 
 	stm, conn := tUtilInStreamInit(t, false)
@@ -211,7 +238,7 @@ func TestStream_DialbackVerify(t *testing.T) {
 }
 
 func TestStream_DialbackAuthorize(t *testing.T) {
-	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	host.Initialize([]host.Config{tUtilHostConfig(t, "jackal.im")})
 	defer host.Shutdown()
 
 	stm, conn := tUtilInStreamInit(t, false)
@@ -250,7 +277,10 @@ func TestStream_DialbackAuthorize(t *testing.T) {
 
 	cfg, conn = tUtilInStreamDefaultConfig(t, false)
 	cfg.dialer = &dialer{cfg: &Config{DialTimeout: time.Second}}
-	cfg.dialer.srvResolve = func(_, _, _ string) (cname string, addrs []*net.SRV, err error) {
+	cfg.dialer.srvResolve = func(service, _, _ string) (cname string, addrs []*net.SRV, err error) {
+		if service == "xmpps-server" {
+			return "", nil, errors.New("mocked dialer error")
+		}
 		return "", []*net.SRV{{Target: "jackal.im", Port: 5269}}, nil
 	}
 	outConn := newFakeSocketConn()
@@ -276,7 +306,10 @@ func TestStream_DialbackAuthorize(t *testing.T) {
 	// authorize dialback key
 	cfg, conn = tUtilInStreamDefaultConfig(t, false)
 	cfg.dialer = &dialer{cfg: &Config{DialTimeout: time.Second}}
-	cfg.dialer.srvResolve = func(_, _, _ string) (cname string, addrs []*net.SRV, err error) {
+	cfg.dialer.srvResolve = func(service, _, _ string) (cname string, addrs []*net.SRV, err error) {
+		if service == "xmpps-server" {
+			return "", nil, errors.New("mocked dialer error")
+		}
 		return "", []*net.SRV{{Target: "jackal.im", Port: 5269}}, nil
 	}
 	outConn = newFakeSocketConn()
@@ -334,7 +367,7 @@ func TestStream_DialbackAuthorize(t *testing.T) {
 }
 
 func TestStream_SendElement(t *testing.T) {
-	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	host.Initialize([]host.Config{tUtilHostConfig(t, "jackal.im")})
 	router.Initialize(&router.Config{})
 	storage.Initialize(&storage.Config{Type: storage.Memory})
 	defer func() {
@@ -387,45 +420,23 @@ func tUtilInStreamOpen(conn *fakeSocketConn) {
 }
 
 func tUtilInStreamDefaultConfig(t *testing.T, loadPeerCertificate bool) (*streamConfig, *fakeSocketConn) {
-	modules := map[string]struct{}{}
-	modules["roster"] = struct{}{}
-	modules["last_activity"] = struct{}{}
-	modules["private"] = struct{}{}
-	modules["vcard"] = struct{}{}
-	modules["registration"] = struct{}{}
-	modules["version"] = struct{}{}
-	modules["ping"] = struct{}{}
-	modules["blocking_command"] = struct{}{}
-	modules["offline"] = struct{}{}
-
-	certFile := "../testdata/cert/test.server.crt"
-	certKey := "../testdata/cert/test.server.key"
-	cer, err := util.LoadCertificate(certKey, certFile, "localhost")
-	require.Nil(t, err)
-
 	var peerCerts []*x509.Certificate
 	if loadPeerCertificate {
-		for _, asn1Data := range cer.Certificate {
-			cr, err := x509.ParseCertificate(asn1Data)
-			require.Nil(t, err)
-			cr.DNSNames = []string{"localhost"}
-			peerCerts = append(peerCerts, cr)
-		}
+		peerCerts = tUtilPeerCertificates(t, "localhost")
 	}
-
 	conn := newFakeSocketConnWithPeerCerts(peerCerts)
 	tr := transport.NewSocketTransport(conn, 4096)
 	return &streamConfig{
-		modConfig: &module.Config{
-			Enabled:      modules,
-			Offline:      offline.Config{QueueSize: 10},
-			Registration: xep0077.Config{AllowRegistration: true, AllowChange: true},
-			Version:      xep0092.Config{ShowOS: true},
-			Ping:         xep0199.Config{SendInterval: 5, Send: true},
-		},
 		connectTimeout: time.Second,
 		transport:      tr,
 		maxStanzaSize:  8192,
 		keyGen:         &keyGen{secret: "s3cr3t"},
+		certValidator:  tUtilCertValidator(t),
 	}, conn
 }
+
+func tUtilHostConfig(t *testing.T, domain string) host.Config {
+	cer, err := util.LoadCertificate("../testdata/cert/test.server.key", "../testdata/cert/test.server.crt", domain)
+	require.Nil(t, err)
+	return host.Config{Name: domain, Certificate: cer}
+}
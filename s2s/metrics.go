@@ -0,0 +1,32 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package s2s
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	connections = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "jackal_s2s_connections",
+		Help: "Number of currently open s2s connections by direction.",
+	}, []string{"direction"})
+	connectionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "jackal_s2s_connections_total",
+		Help: "Total number of established s2s connections by direction.",
+	}, []string{"direction"})
+	dialFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "jackal_s2s_dial_failures_total",
+		Help: "Total number of failed s2s dial attempts.",
+	})
+)
+
+const (
+	directionIn  = "in"
+	directionOut = "out"
+)
+
+func init() {
+	prometheus.MustRegister(connections, connectionsTotal, dialFailures)
+}
@@ -28,18 +28,19 @@ const (
 )
 
 type outStream struct {
-	started       uint32
-	id            string
-	cfg           *streamConfig
-	state         uint32
-	sess          *session.Session
-	secured       uint32
-	authenticated uint32
-	actorCh       chan func()
-	sendQueue     []xml.XElement
-	verified      chan xml.XElement
-	verifyCh      chan bool
-	discCh        chan *streamerror.Error
+	started         uint32
+	id              string
+	cfg             *streamConfig
+	state           uint32
+	sess            *session.Session
+	secured         uint32
+	authenticated   uint32
+	dialbackOffered bool
+	actorCh         chan func()
+	sendQueue       []xml.XElement
+	verified        chan xml.XElement
+	verifyCh        chan bool
+	discCh          chan *streamerror.Error
 }
 
 func newOutStream() *outStream {
@@ -90,6 +91,13 @@ func (s *outStream) start(cfg *streamConfig) error {
 	}
 	s.cfg = cfg
 
+	connections.WithLabelValues(directionOut).Inc()
+	connectionsTotal.WithLabelValues(directionOut).Inc()
+
+	if cfg.directTLS {
+		atomic.StoreUint32(&s.secured, 1)
+	}
+
 	// start s2s out session
 	s.restartSession()
 
@@ -180,6 +188,12 @@ func (s *outStream) handleConnected(elem xml.XElement) {
 			return
 		}
 		if !s.isAuthenticated() {
+			certErr := s.cfg.certValidator.validate(s.cfg.transport.PeerCertificates(), s.cfg.remoteDomain)
+			if certErr != nil && s.cfg.certValidator.isRequired(s.cfg.remoteDomain) {
+				log.Infof("s2s out stream rejected... invalid certificate (domainpair: %s): %v", s.ID(), certErr)
+				s.disconnectWithStreamError(streamerror.ErrPolicyViolation)
+				return
+			}
 			var hasExternalAuth bool
 			if mechanisms := elem.Elements().ChildNamespace("mechanisms", saslNamespace); mechanisms != nil {
 				for _, m := range mechanisms.Elements().All() {
@@ -189,20 +203,18 @@ func (s *outStream) handleConnected(elem xml.XElement) {
 					}
 				}
 			}
-			if hasExternalAuth {
+			s.dialbackOffered = elem.Elements().ChildrenNamespace("dialback", dialbackNamespace) != nil
+
+			// use external authentication only if remote certificate is valid
+			if hasExternalAuth && certErr == nil {
 				auth := xml.NewElementNamespace("auth", saslNamespace)
 				auth.SetAttribute("mechanism", "EXTERNAL")
 				auth.SetText("=")
 				s.writeElement(auth)
 				s.setState(outAuthenticating)
 
-			} else if elem.Elements().ChildrenNamespace("dialback", dialbackNamespace) != nil {
-				db := xml.NewElementName("db:result")
-				db.SetFrom(s.cfg.localDomain)
-				db.SetTo(s.cfg.remoteDomain)
-				db.SetText(s.cfg.keyGen.generate(s.cfg.remoteDomain, s.cfg.localDomain, s.sess.StreamID()))
-				s.writeElement(db)
-				s.setState(outValidatingDialbackKey)
+			} else if s.dialbackOffered {
+				s.startDialback()
 
 			} else {
 				// no verification mechanism found... do not allow remote connection
@@ -242,13 +254,27 @@ func (s *outStream) handleAuthenticating(elem xml.XElement) {
 		atomic.StoreUint32(&s.authenticated, 1)
 
 	case "failure":
-		s.disconnectWithStreamError(streamerror.ErrRemoteConnectionFailed)
+		if !s.dialbackOffered {
+			s.disconnectWithStreamError(streamerror.ErrRemoteConnectionFailed)
+			return
+		}
+		log.Infof("s2s out stream external authentication failed... falling back to dialback (domainpair: %s)", s.ID())
+		s.startDialback()
 
 	default:
 		s.disconnectWithStreamError(streamerror.ErrUnsupportedStanzaType)
 	}
 }
 
+func (s *outStream) startDialback() {
+	db := xml.NewElementName("db:result")
+	db.SetFrom(s.cfg.localDomain)
+	db.SetTo(s.cfg.remoteDomain)
+	db.SetText(s.cfg.keyGen.generate(s.cfg.remoteDomain, s.cfg.localDomain, s.sess.StreamID()))
+	s.writeElement(db)
+	s.setState(outValidatingDialbackKey)
+}
+
 func (s *outStream) handleValidatingDialbackKey(elem xml.XElement) {
 	switch elem.Name() {
 	case "db:result":
@@ -339,6 +365,7 @@ func (s *outStream) disconnectClosingSession(closeSession bool) {
 		s.sess.Close()
 	}
 	outContainer.delete(s)
+	connections.WithLabelValues(directionOut).Dec()
 
 	s.setState(outDisconnected)
 	s.cfg.transport.Close()
@@ -11,11 +11,6 @@ import (
 	"time"
 
 	"github.com/ortuman/jackal/host"
-	"github.com/ortuman/jackal/module"
-	"github.com/ortuman/jackal/module/offline"
-	"github.com/ortuman/jackal/module/xep0077"
-	"github.com/ortuman/jackal/module/xep0092"
-	"github.com/ortuman/jackal/module/xep0199"
 	"github.com/ortuman/jackal/transport"
 	"github.com/ortuman/jackal/xml"
 	"github.com/pborman/uuid"
@@ -23,7 +18,7 @@ import (
 )
 
 func TestOutStream_Start(t *testing.T) {
-	cfg, _ := tUtilOutStreamDefaultConfig()
+	cfg, _ := tUtilOutStreamDefaultConfig(t)
 	stm := newOutStream()
 	defer stm.Disconnect(nil)
 
@@ -39,7 +34,7 @@ func TestOutStream_Start(t *testing.T) {
 }
 
 func TestOutStream_Disconnect(t *testing.T) {
-	cfg, conn := tUtilOutStreamDefaultConfig()
+	cfg, conn := tUtilOutStreamDefaultConfig(t)
 	stm := newOutStream()
 	stm.start(cfg)
 	stm.Disconnect(nil)
@@ -100,7 +95,7 @@ func TestOutStream_DBVerify(t *testing.T) {
 	host.Initialize([]host.Config{{Name: "jackal.im"}})
 	defer host.Shutdown()
 
-	cfg, conn := tUtilOutStreamDefaultConfig()
+	cfg, conn := tUtilOutStreamDefaultConfig(t)
 	dbVerify := xml.NewElementName("db:verify")
 	key := uuid.New()
 	dbVerify.SetID("abcde")
@@ -129,7 +124,7 @@ func TestOutStream_DBVerify(t *testing.T) {
 		require.Fail(t, "expecting session error")
 	}
 
-	cfg, conn = tUtilOutStreamDefaultConfig()
+	cfg, conn = tUtilOutStreamDefaultConfig(t)
 	cfg.dbVerify = dbVerify
 	stm = tUtilOutStreamInitWithConfig(t, cfg, conn)
 	atomic.StoreUint32(&stm.secured, 1)
@@ -221,10 +216,13 @@ func TestOutStream_Authenticate(t *testing.T) {
 	conn.inboundWriteString(securedFeaturesWithExternal)
 	_ = conn.outboundRead()
 
+	// fallback to dialback...
 	conn.inboundWriteString(`
 <failure xmlns="urn:ietf:params:xml:ns:xmpp-sasl"/>
 `)
-	require.True(t, conn.waitClose())
+	elem = conn.outboundRead()
+	require.Equal(t, "db:result", elem.Name())
+	require.Equal(t, outValidatingDialbackKey, stm.getState())
 
 	stm, conn = tUtilOutStreamInit(t)
 	tUtilOutStreamOpen(conn)
@@ -252,6 +250,50 @@ func TestOutStream_Authenticate(t *testing.T) {
 	require.Equal(t, iqID, elem.ID())
 }
 
+func TestOutStream_CertificateValidation(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	defer host.Shutdown()
+
+	// invalid certificate: fallback to dialback...
+	cfg, conn := tUtilOutStreamDefaultConfig(t)
+	cfg.remoteDomain = "jackal.im"
+	stm := tUtilOutStreamInitWithConfig(t, cfg, conn)
+	tUtilOutStreamOpen(conn)
+	atomic.StoreUint32(&stm.secured, 1)
+	conn.inboundWriteString(securedFeaturesWithExternal)
+
+	elem := conn.outboundRead()
+	require.Equal(t, "db:result", elem.Name())
+	require.Equal(t, outValidatingDialbackKey, stm.getState())
+
+	// invalid certificate: valid certificate required...
+	cfg, conn = tUtilOutStreamDefaultConfig(t)
+	cfg.remoteDomain = "jackal.im"
+	cfg.certValidator = tUtilCertValidator(t, "jackal.im")
+	stm = tUtilOutStreamInitWithConfig(t, cfg, conn)
+	tUtilOutStreamOpen(conn)
+	atomic.StoreUint32(&stm.secured, 1)
+	conn.inboundWriteString(securedFeaturesWithExternal)
+	require.True(t, conn.waitClose())
+
+	// valid certificate: no fallback without dialback support...
+	stm, conn = tUtilOutStreamInit(t)
+	tUtilOutStreamOpen(conn)
+	atomic.StoreUint32(&stm.secured, 1)
+	conn.inboundWriteString(`
+<stream:features xmlns:stream="http://etherx.jabber.org/streams" version="1.0">
+  <mechanisms xmlns="urn:ietf:params:xml:ns:xmpp-sasl"><mechanism>EXTERNAL</mechanism></mechanisms>
+</stream:features>
+`)
+	elem = conn.outboundRead()
+	require.Equal(t, "auth", elem.Name())
+
+	conn.inboundWriteString(`
+<failure xmlns="urn:ietf:params:xml:ns:xmpp-sasl"/>
+`)
+	require.True(t, conn.waitClose())
+}
+
 func TestOutStream_Dialback(t *testing.T) {
 	host.Initialize([]host.Config{{Name: "jackal.im"}})
 	defer host.Shutdown()
@@ -325,7 +367,7 @@ func tUtilOutStreamInitWithConfig(t *testing.T, cfg *streamConfig, conn *fakeSoc
 }
 
 func tUtilOutStreamInit(t *testing.T) (*outStream, *fakeSocketConn) {
-	cfg, conn := tUtilOutStreamDefaultConfig()
+	cfg, conn := tUtilOutStreamDefaultConfig(t)
 	stm := newOutStream()
 	stm.start(cfg)
 
@@ -336,32 +378,15 @@ func tUtilOutStreamInit(t *testing.T) (*outStream, *fakeSocketConn) {
 	return stm, conn
 }
 
-func tUtilOutStreamDefaultConfig() (*streamConfig, *fakeSocketConn) {
-	modules := map[string]struct{}{}
-	modules["roster"] = struct{}{}
-	modules["last_activity"] = struct{}{}
-	modules["private"] = struct{}{}
-	modules["vcard"] = struct{}{}
-	modules["registration"] = struct{}{}
-	modules["version"] = struct{}{}
-	modules["ping"] = struct{}{}
-	modules["blocking_command"] = struct{}{}
-	modules["offline"] = struct{}{}
-
-	conn := newFakeSocketConn()
+func tUtilOutStreamDefaultConfig(t *testing.T) (*streamConfig, *fakeSocketConn) {
+	conn := newFakeSocketConnWithPeerCerts(tUtilPeerCertificates(t, "jabber.org"))
 	tr := transport.NewSocketTransport(conn, 4096)
 	return &streamConfig{
-		remoteDomain: "jabber.org",
-		modConfig: &module.Config{
-			Enabled:      modules,
-			Offline:      offline.Config{QueueSize: 10},
-			Registration: xep0077.Config{AllowRegistration: true, AllowChange: true},
-			Version:      xep0092.Config{ShowOS: true},
-			Ping:         xep0199.Config{SendInterval: 5, Send: true},
-		},
+		remoteDomain:   "jabber.org",
 		connectTimeout: time.Second,
 		transport:      tr,
 		maxStanzaSize:  8192,
 		keyGen:         &keyGen{secret: "s3cr3t"},
+		certValidator:  tUtilCertValidator(t),
 	}, conn
 }
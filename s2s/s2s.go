@@ -9,7 +9,6 @@ import (
 	"errors"
 	"sync"
 
-	"github.com/ortuman/jackal/module"
 	"github.com/ortuman/jackal/stream"
 )
 
@@ -22,6 +21,10 @@ const (
 	dialbackNamespace = "urn:xmpp:features:dialback"
 )
 
+// xmppServerALPN represents the ALPN protocol identifier used
+// by direct TLS s2s connections (https://xmpp.org/extensions/xep-0368.html).
+const xmppServerALPN = "xmpp-server"
+
 var (
 	instMu        sync.RWMutex
 	defaultDialer *dialer
@@ -30,7 +33,7 @@ var (
 )
 
 // Initialize initializes s2s sub system.
-func Initialize(cfg *Config, modConfig *module.Config) {
+func Initialize(cfg *Config) {
 	instMu.Lock()
 	defer instMu.Unlock()
 	if initialized {
@@ -40,7 +43,7 @@ func Initialize(cfg *Config, modConfig *module.Config) {
 		return
 	}
 	defaultDialer = newDialer(cfg)
-	srv = &server{cfg: cfg, modConfig: modConfig}
+	srv = &server{cfg: cfg}
 	go srv.start()
 	initialized = true
 }
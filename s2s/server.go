@@ -6,12 +6,13 @@
 package s2s
 
 import (
+	"crypto/tls"
 	"net"
 	"strconv"
 	"sync/atomic"
 
+	"github.com/ortuman/jackal/host"
 	"github.com/ortuman/jackal/log"
-	"github.com/ortuman/jackal/module"
 	"github.com/ortuman/jackal/transport"
 )
 
@@ -19,7 +20,6 @@ var listenerProvider = net.Listen
 
 type server struct {
 	cfg       *Config
-	modConfig *module.Config
 	ln        net.Listener
 	listening uint32
 }
@@ -29,7 +29,7 @@ func (s *server) start() {
 	port := s.cfg.Transport.Port
 	address := bindAddr + ":" + strconv.Itoa(port)
 
-	log.Infof("s2s_in: listening at %s", address)
+	log.Infof("s2s_in: listening at %s [direct_tls: %v]", address, s.cfg.Transport.DirectTLS)
 
 	if err := s.listenConn(address); err != nil {
 		log.Fatalf("%v", err)
@@ -47,6 +47,13 @@ func (s *server) listenConn(address string) error {
 	if err != nil {
 		return err
 	}
+	if s.cfg.Transport.DirectTLS {
+		ln = tls.NewListener(ln, &tls.Config{
+			ClientAuth:     tls.RequestClientCert, // validated at authentication time
+			GetCertificate: host.GetCertificate(""),
+			NextProtos:     []string{xmppServerALPN},
+		})
+	}
 	s.ln = ln
 
 	atomic.StoreUint32(&s.listening, 1)
@@ -62,11 +69,12 @@ func (s *server) listenConn(address string) error {
 
 func (s *server) startStream(tr transport.Transport) {
 	newInStream(&streamConfig{
-		modConfig:      s.modConfig,
 		keyGen:         &keyGen{s.cfg.DialbackSecret},
 		transport:      tr,
+		directTLS:      s.cfg.Transport.DirectTLS,
 		connectTimeout: s.cfg.ConnectTimeout,
 		maxStanzaSize:  s.cfg.MaxStanzaSize,
 		dialer:         newDialerCopy(defaultDialer),
+		certValidator:  newCertValidator(s.cfg),
 	})
 }
@@ -6,14 +6,16 @@
 package s2s
 
 import (
+	"crypto/tls"
 	"net"
 	"testing"
 	"time"
 
 	"github.com/ortuman/jackal/host"
-	"github.com/ortuman/jackal/module"
 	"github.com/ortuman/jackal/router"
 	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/util"
+	"github.com/ortuman/jackal/xml"
 	"github.com/stretchr/testify/require"
 )
 
@@ -32,7 +34,7 @@ func TestS2SSocketServer(t *testing.T) {
 			KeepAlive: time.Duration(600) * time.Second,
 		},
 	}
-	go Initialize(&cfg, &module.Config{})
+	go Initialize(&cfg)
 
 	go func() {
 		time.Sleep(time.Millisecond * 150)
@@ -62,3 +64,64 @@ func TestS2SSocketServer(t *testing.T) {
 	storage.Shutdown()
 	host.Shutdown()
 }
+
+func TestS2SDirectTLSServer(t *testing.T) {
+	privKeyFile := "../testdata/cert/test.server.key"
+	certFile := "../testdata/cert/test.server.crt"
+	cer, err := util.LoadCertificate(privKeyFile, certFile, "localhost")
+	require.Nil(t, err)
+
+	host.Initialize([]host.Config{{Name: "localhost", Certificate: cer}})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	router.Initialize(&router.Config{})
+
+	errCh := make(chan error)
+	cfg := Config{
+		Enabled:        true,
+		ConnectTimeout: time.Second * time.Duration(5),
+		MaxStanzaSize:  8192,
+		Transport: TransportConfig{
+			Port:      12779,
+			KeepAlive: time.Duration(600) * time.Second,
+			DirectTLS: true,
+		},
+	}
+	go Initialize(&cfg)
+
+	var features xml.XElement
+	go func() {
+		time.Sleep(time.Millisecond * 150)
+
+		conn, err := tls.Dial("tcp", "127.0.0.1:12779", &tls.Config{InsecureSkipVerify: true, NextProtos: []string{"xmpp-server"}})
+		if err != nil {
+			errCh <- err
+			return
+		}
+		_, err = conn.Write([]byte(`<?xml version="1.0"?><stream:stream xmlns="jabber:server" xmlns:stream="http://etherx.jabber.org/streams" xmlns:db="jabber:server:dialback" from="jabber.org" to="localhost" version="1.0">`))
+		if err != nil {
+			errCh <- err
+			return
+		}
+		pr := xml.NewParser(conn, xml.SocketStream, 0)
+		for features == nil && err == nil {
+			var elem xml.XElement
+			elem, err = pr.ParseElement()
+			if elem != nil && elem.Name() == "stream:features" {
+				features = elem
+			}
+		}
+		conn.Close()
+
+		Shutdown()
+		errCh <- err
+	}()
+	err = <-errCh
+	require.Nil(t, err)
+
+	require.NotNil(t, features)
+	require.Nil(t, features.Elements().ChildNamespace("starttls", tlsNamespace))
+
+	router.Shutdown()
+	storage.Shutdown()
+	host.Shutdown()
+}
@@ -27,6 +27,7 @@ import (
 const (
 	jabberClientNamespace = "jabber:client"
 	jabberServerNamespace = "jabber:server"
+	componentNamespace    = "jabber:component:accept"
 	framedStreamNamespace = "urn:ietf:params:xml:ns:xmpp-framing"
 	streamNamespace       = "http://etherx.jabber.org/streams"
 	dialbackNamespace     = "jabber:server:dialback"
@@ -69,6 +70,10 @@ type Config struct {
 	// IsInitiating defines whether or not this is an initiating
 	// entity session.
 	IsInitiating bool
+
+	// IsComponent defines whether or not this session is established
+	// by an external component (https://xmpp.org/extensions/xep-0114.html).
+	IsComponent bool
 }
 
 // Session represents an XMPP session between the two peers.
@@ -79,8 +84,10 @@ type Session struct {
 	remoteDomain string
 	isServer     bool
 	isInitiating bool
+	isComponent  bool
 	opened       uint32
 	started      uint32
+	closedByPeer uint32
 
 	mu       sync.RWMutex
 	streamID string
@@ -93,7 +100,8 @@ func New(id string, config *Config) *Session {
 	switch config.Transport.Type() {
 	case transport.Socket:
 		parsingMode = xml.SocketStream
-	case transport.WebSocket:
+	case transport.WebSocket, transport.BOSH:
+		// BOSH transport frames its payloads the same way websocket does
 		parsingMode = xml.WebSocketStream
 	}
 	s := &Session{
@@ -103,6 +111,7 @@ func New(id string, config *Config) *Session {
 		remoteDomain: config.RemoteDomain,
 		isServer:     config.IsServer,
 		isInitiating: config.IsInitiating,
+		isComponent:  config.IsComponent,
 		sJID:         config.JID,
 	}
 	if !s.isInitiating {
@@ -151,7 +160,7 @@ func (s *Session) Open() error {
 		}
 		buf.WriteString(`<?xml version="1.0"?>`)
 
-	case transport.WebSocket:
+	case transport.WebSocket, transport.BOSH:
 		ops = xml.NewElementName("open")
 		ops.SetAttribute("xmlns", framedStreamNamespace)
 		includeClosing = true
@@ -170,7 +179,9 @@ func (s *Session) Open() error {
 		ops.SetAttribute("to", s.remoteDomain)
 		s.mu.RUnlock()
 	}
-	ops.SetAttribute("version", "1.0")
+	if !s.isComponent {
+		ops.SetAttribute("version", "1.0")
+	}
 	ops.ToXML(buf, includeClosing)
 
 	openStr := buf.String()
@@ -189,12 +200,18 @@ func (s *Session) Close() error {
 	switch s.tr.Type() {
 	case transport.Socket:
 		io.WriteString(s.tr, "</stream:stream>")
-	case transport.WebSocket:
+	case transport.WebSocket, transport.BOSH:
 		io.WriteString(s.tr, fmt.Sprintf(`<close xmlns="%s" />`, framedStreamNamespace))
 	}
 	return nil
 }
 
+// IsClosedByPeer returns whether or not the session
+// has been gracefully closed by the remote peer.
+func (s *Session) IsClosedByPeer() bool {
+	return atomic.LoadUint32(&s.closedByPeer) == 1
+}
+
 // Send writes an XML element to the underlying session transport.
 func (s *Session) Send(elem xml.XElement) {
 	// clear namespace if sending a stanza
@@ -202,7 +219,12 @@ func (s *Session) Send(elem xml.XElement) {
 		e.SetNamespace("")
 	}
 	log.Debugf("SEND(%s): %v", s.id, elem)
-	elem.ToXML(s.tr, true)
+
+	// write the whole element at once, so that framed
+	// transports never deliver partial elements
+	buf := &strings.Builder{}
+	elem.ToXML(buf, true)
+	io.WriteString(s.tr, buf.String())
 }
 
 // Receive returns next incoming session element.
@@ -276,7 +298,7 @@ func (s *Session) extractAddresses(elem xml.XElement) (*jid.JID, *jid.JID, *Erro
 	var err error
 
 	from := elem.From()
-	if !s.isServer {
+	if !s.isServer && !s.isComponent {
 		// do not validate 'from' address until full user JID has been set
 		if s.jid().IsFullWithUser() {
 			if len(from) > 0 && !s.isValidFrom(from) {
@@ -286,7 +308,7 @@ func (s *Session) extractAddresses(elem xml.XElement) (*jid.JID, *jid.JID, *Erro
 		fromJID = s.jid()
 	} else {
 		j, err := jid.NewWithString(from, false)
-		if err != nil || j.Domain() != s.remoteDomain {
+		if err != nil || !s.isValidRemoteDomain(j.Domain()) {
 			return nil, nil, &Error{UnderlyingErr: streamerror.ErrInvalidFrom}
 		}
 		fromJID = j
@@ -305,6 +327,16 @@ func (s *Session) extractAddresses(elem xml.XElement) (*jid.JID, *jid.JID, *Erro
 	return fromJID, toJID, nil
 }
 
+// isValidRemoteDomain returns whether or not a server or component
+// stream is allowed to send stanzas on behalf of a given domain.
+func (s *Session) isValidRemoteDomain(domain string) bool {
+	if domain == s.remoteDomain {
+		return true
+	}
+	// components may also address stanzas from their subdomains
+	return s.isComponent && strings.HasSuffix(domain, "."+s.remoteDomain)
+}
+
 func (s *Session) isValidFrom(from string) bool {
 	validFrom := false
 	j, err := jid.NewWithString(from, false)
@@ -331,7 +363,7 @@ func (s *Session) validateStreamElement(elem xml.XElement) *Error {
 			return &Error{UnderlyingErr: streamerror.ErrInvalidNamespace}
 		}
 
-	case transport.WebSocket:
+	case transport.WebSocket, transport.BOSH:
 		if elem.Name() != "open" {
 			return &Error{UnderlyingErr: streamerror.ErrUnsupportedStanzaType}
 		}
@@ -339,6 +371,9 @@ func (s *Session) validateStreamElement(elem xml.XElement) *Error {
 			return &Error{UnderlyingErr: streamerror.ErrInvalidNamespace}
 		}
 	}
+	if s.isComponent {
+		return nil // component streams are addressed to its own domain
+	}
 	to := elem.To()
 	if len(to) > 0 && !host.IsLocalHost(to) {
 		return &Error{UnderlyingErr: streamerror.ErrHostUnknown}
@@ -358,6 +393,9 @@ func (s *Session) validateNamespace(elem xml.XElement) *Error {
 }
 
 func (s *Session) namespace() string {
+	if s.isComponent {
+		return componentNamespace
+	}
 	if s.isServer {
 		return jabberServerNamespace
 	}
@@ -376,6 +414,7 @@ func (s *Session) mapErrorToSessionError(err error) *Error {
 		break
 
 	case xml.ErrStreamClosedByPeer:
+		atomic.StoreUint32(&s.closedByPeer, 1)
 		s.Close()
 
 	case xml.ErrTooLargeStanza:
@@ -84,6 +84,15 @@ func TestSession_Open(t *testing.T) {
 	require.Equal(t, "open", elem.Name())
 	require.Equal(t, "urn:ietf:params:xml:ns:xmpp-framing", elem.Attributes().Get("xmlns"))
 
+	// test BOSH session start
+	tr = newFakeTransport(transport.BOSH)
+	sess = New(uuid.New(), &Config{JID: j, Transport: tr})
+	sess.Open()
+	pr = xml.NewParser(tr.wrBuf, xml.WebSocketStream, 0)
+	elem, err = pr.ParseElement()
+	require.Nil(t, err)
+	require.Equal(t, "open", elem.Name())
+
 	// test unsupported transport type
 	tr = newFakeTransport(transport.TransportType(9999))
 	sess = New(uuid.New(), &Config{JID: j, Transport: tr})
@@ -310,6 +319,52 @@ func TestSession_ExtractAddresses(t *testing.T) {
 	require.Equal(t, xml.ErrJidMalformed, err.UnderlyingErr)
 }
 
+func TestSession_Component(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	defer host.Shutdown()
+
+	j, _ := jid.NewWithString("bot.jackal.im", true)
+
+	tr := newFakeTransport(transport.Socket)
+	sess := New(uuid.New(), &Config{JID: j, Transport: tr, RemoteDomain: "bot.jackal.im", IsComponent: true})
+	sess.Open()
+	pr := xml.NewParser(tr.wrBuf, xml.SocketStream, 0)
+	_, _ = pr.ParseElement() // read xml header
+	elem, err := pr.ParseElement()
+	require.Nil(t, err)
+	require.Equal(t, "jabber:component:accept", elem.Namespace())
+	require.Equal(t, "bot.jackal.im", elem.From())
+	require.Equal(t, sess.StreamID(), elem.ID())
+	require.Equal(t, "", elem.Version())
+
+	stm := xml.NewElementNamespace("stream:stream", "jabber:component:accept")
+	stm.SetAttribute("xmlns:stream", "http://etherx.jabber.org/streams")
+	stm.SetTo("bot.jackal.im")
+	require.Nil(t, sess.validateStreamElement(stm))
+
+	iq := xml.NewElementNamespace("iq", "jabber:component:accept")
+	iq.SetFrom("romeo@example.org")
+	iq.SetTo("ortuman@jackal.im")
+	_, _, sErr := sess.extractAddresses(iq)
+	require.NotNil(t, sErr)
+	require.Equal(t, streamerror.ErrInvalidFrom, sErr.UnderlyingErr)
+
+	iq.SetFrom("alice@jackal.im")
+	_, _, sErr = sess.extractAddresses(iq)
+	require.NotNil(t, sErr)
+	require.Equal(t, streamerror.ErrInvalidFrom, sErr.UnderlyingErr)
+
+	iq.SetFrom("rss.bot.jackal.im")
+	_, _, sErr = sess.extractAddresses(iq)
+	require.Nil(t, sErr)
+
+	iq.SetFrom("weather@bot.jackal.im")
+	from, to, sErr := sess.extractAddresses(iq)
+	require.Nil(t, sErr)
+	require.Equal(t, "weather@bot.jackal.im", from.String())
+	require.Equal(t, "ortuman@jackal.im", to.String())
+}
+
 func TestSession_BuildStanza(t *testing.T) {
 	j, _ := jid.NewWithString("ortuman@jackal.im/res", true)
 	tr := newFakeTransport(transport.Socket)
@@ -370,7 +425,9 @@ func TestSession_MapErrorq(t *testing.T) {
 	require.Equal(t, &Error{}, sess.mapErrorToSessionError(nil))
 	require.Equal(t, &Error{}, sess.mapErrorToSessionError(io.EOF))
 	require.Equal(t, &Error{}, sess.mapErrorToSessionError(io.ErrUnexpectedEOF))
+	require.False(t, sess.IsClosedByPeer())
 	require.Equal(t, &Error{}, sess.mapErrorToSessionError(xml.ErrStreamClosedByPeer))
+	require.True(t, sess.IsClosedByPeer())
 
 	require.Equal(t, &Error{UnderlyingErr: streamerror.ErrPolicyViolation}, sess.mapErrorToSessionError(xml.ErrTooLargeStanza))
 	require.Equal(t, &Error{UnderlyingErr: streamerror.ErrInvalidXML}, sess.mapErrorToSessionError(&stdxml.SyntaxError{}))
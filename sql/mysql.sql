@@ -4,28 +4,35 @@
  */
 
 CREATE TABLE IF NOT EXISTS users (
-    username VARCHAR(256) PRIMARY KEY,
+    username VARCHAR(256) NOT NULL,
+    domain VARCHAR(255) NOT NULL,
     password TEXT NOT NULL,
+    scram_sha1 VARCHAR(256) NOT NULL DEFAULT '',
+    scram_sha256 VARCHAR(256) NOT NULL DEFAULT '',
+    disabled BOOL NOT NULL DEFAULT FALSE,
     last_presence TEXT NOT NULL,
     last_presence_at DATETIME NOT NULL,
     updated_at DATETIME NOT NULL,
-    created_at DATETIME NOT NULL
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (username, domain)
 ) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
 
 CREATE TABLE roster_notifications (
     contact VARCHAR(256) NOT NULL,
-    jid VARCHAR(512) NOT NULL,
+    domain VARCHAR(255) NOT NULL,
+    jid VARCHAR(256) NOT NULL,
     elements TEXT NOT NULL,
     updated_at DATETIME NOT NULL,
     created_at DATETIME NOT NULL,
-    PRIMARY KEY (contact, jid)
+    PRIMARY KEY (contact, domain, jid)
 ) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
 
 CREATE INDEX i_roster_notifications_jid ON roster_notifications(jid);
 
 CREATE TABLE roster_items (
     username VARCHAR(256) NOT NULL,
-    jid VARCHAR(512) NOT NULL,
+    domain VARCHAR(255) NOT NULL,
+    jid VARCHAR(256) NOT NULL,
     name TEXT NOT NULL,
     subscription TEXT NOT NULL,
     `groups` TEXT NOT NULL,
@@ -33,52 +40,207 @@ CREATE TABLE roster_items (
     ver INT NOT NULL DEFAULT 0,
     updated_at DATETIME NOT NULL,
     created_at DATETIME NOT NULL,
-    PRIMARY KEY (username, jid)
+    PRIMARY KEY (username, domain, jid)
 ) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
 
-CREATE INDEX i_roster_items_username ON roster_items(username);
+CREATE INDEX i_roster_items_username ON roster_items(username, domain);
 CREATE INDEX i_roster_items_jid ON roster_items(jid);
 
 CREATE TABLE roster_versions (
     username VARCHAR(256) NOT NULL,
+    domain VARCHAR(255) NOT NULL,
     ver INT NOT NULL DEFAULT 0,
     last_deletion_ver INT NOT NULL DEFAULT 0,
     updated_at DATETIME NOT NULL,
     created_at DATETIME NOT NULL,
-    PRIMARY KEY (username)
+    PRIMARY KEY (username, domain)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
 
 CREATE TABLE IF NOT EXISTS blocklist_items (
     username VARCHAR(256) NOT NULL,
-    jid VARCHAR(512) NOT NULL,
+    domain VARCHAR(255) NOT NULL,
+    jid VARCHAR(256) NOT NULL,
     created_at DATETIME NOT NULL,
-    PRIMARY KEY(username, jid)
+    PRIMARY KEY (username, domain, jid)
 ) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
 
-CREATE INDEX i_blocklist_items_username ON blocklist_items(username);
+CREATE INDEX i_blocklist_items_username ON blocklist_items(username, domain);
 
 CREATE TABLE IF NOT EXISTS private_storage (
     username VARCHAR(256) NOT NULL,
-    namespace VARCHAR(512) NOT NULL,
+    domain VARCHAR(255) NOT NULL,
+    namespace VARCHAR(256) NOT NULL,
     data MEDIUMTEXT NOT NULL,
     updated_at DATETIME NOT NULL,
     created_at DATETIME NOT NULL,
-    PRIMARY KEY (username, namespace)
+    PRIMARY KEY (username, domain, namespace)
 ) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
 
-CREATE INDEX i_private_storage_username ON private_storage(username);
+CREATE INDEX i_private_storage_username ON private_storage(username, domain);
 
 CREATE TABLE IF NOT EXISTS vcards (
-    username VARCHAR(256) PRIMARY KEY,
+    username VARCHAR(256) NOT NULL,
+    domain VARCHAR(255) NOT NULL,
     vcard MEDIUMTEXT NOT NULL,
     updated_at DATETIME NOT NULL,
-    created_at DATETIME NOT NULL
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (username, domain)
 ) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
 
 CREATE TABLE IF NOT EXISTS offline_messages (
     username VARCHAR(256) NOT NULL,
+    domain VARCHAR(255) NOT NULL,
     data MEDIUMTEXT NOT NULL,
     created_at DATETIME NOT NULL
 ) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
 
-CREATE INDEX i_offline_messages_username ON offline_messages(username);
+CREATE INDEX i_offline_messages_username ON offline_messages(username, domain);
+
+CREATE TABLE IF NOT EXISTS muc_rooms (
+    jid VARCHAR(384) PRIMARY KEY,
+    service VARCHAR(256) NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    public BOOL NOT NULL,
+    persistent BOOL NOT NULL,
+    members_only BOOL NOT NULL,
+    moderated BOOL NOT NULL,
+    non_anonymous BOOL NOT NULL,
+    change_subject BOOL NOT NULL,
+    allow_invites BOOL NOT NULL,
+    password VARCHAR(256) NOT NULL,
+    max_users INT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE INDEX i_muc_rooms_service ON muc_rooms(service);
+
+CREATE TABLE IF NOT EXISTS muc_room_affiliations (
+    room_jid VARCHAR(384) NOT NULL,
+    jid VARCHAR(384) NOT NULL,
+    affiliation VARCHAR(32) NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (room_jid, jid)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE INDEX i_muc_room_affiliations_room_jid ON muc_room_affiliations(room_jid);
+
+CREATE TABLE IF NOT EXISTS archive_messages (
+    serial BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    username VARCHAR(256) NOT NULL,
+    domain VARCHAR(255) NOT NULL,
+    id VARCHAR(64) NOT NULL,
+    jid VARCHAR(512) NOT NULL,
+    data MEDIUMTEXT NOT NULL,
+    created_at DATETIME(6) NOT NULL
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE INDEX i_archive_messages_username ON archive_messages(username, domain);
+CREATE INDEX i_archive_messages_username_jid ON archive_messages(username, domain, jid(256));
+
+CREATE TABLE IF NOT EXISTS archive_prefs (
+    username VARCHAR(256) NOT NULL,
+    domain VARCHAR(255) NOT NULL,
+    default_mode VARCHAR(16) NOT NULL,
+    always TEXT NOT NULL,
+    never TEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (username, domain)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS pubsub_nodes (
+    host VARCHAR(256) NOT NULL,
+    name VARCHAR(256) NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (host, name)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS pubsub_node_options (
+    host VARCHAR(256) NOT NULL,
+    name VARCHAR(256) NOT NULL,
+    opt_name VARCHAR(128) NOT NULL,
+    opt_value TEXT NOT NULL,
+    PRIMARY KEY (host, name, opt_name)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS pubsub_affiliations (
+    host VARCHAR(256) NOT NULL,
+    name VARCHAR(256) NOT NULL,
+    jid VARCHAR(256) NOT NULL,
+    affiliation VARCHAR(32) NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (host, name, jid)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS pubsub_subscriptions (
+    host VARCHAR(256) NOT NULL,
+    name VARCHAR(256) NOT NULL,
+    jid VARCHAR(256) NOT NULL,
+    subid VARCHAR(64) NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (host, name, jid)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS pubsub_items (
+    host VARCHAR(256) NOT NULL,
+    name VARCHAR(256) NOT NULL,
+    item_id VARCHAR(256) NOT NULL,
+    publisher VARCHAR(512) NOT NULL,
+    payload MEDIUMTEXT NOT NULL,
+    created_at DATETIME(6) NOT NULL,
+    PRIMARY KEY (host, name, item_id)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE INDEX i_pubsub_items_host_name_created_at ON pubsub_items(host, name, created_at);
+
+CREATE TABLE IF NOT EXISTS push_registrations (
+    username VARCHAR(256) NOT NULL,
+    domain VARCHAR(255) NOT NULL,
+    jid VARCHAR(128) NOT NULL,
+    node VARCHAR(128) NOT NULL,
+    options MEDIUMTEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (username, domain, jid, node)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE INDEX i_push_registrations_username ON push_registrations(username, domain);
+
+CREATE TABLE IF NOT EXISTS auth_tokens (
+    username VARCHAR(256) NOT NULL,
+    domain VARCHAR(255) NOT NULL,
+    device VARCHAR(256) NOT NULL,
+    hash VARCHAR(64) NOT NULL,
+    expires_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (username, domain, device)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE INDEX i_auth_tokens_username ON auth_tokens(username, domain);
+
+CREATE TABLE IF NOT EXISTS privacy_lists (
+    username VARCHAR(256) NOT NULL,
+    domain VARCHAR(255) NOT NULL,
+    name VARCHAR(256) NOT NULL,
+    is_default BOOL NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (username, domain, name)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS privacy_list_items (
+    username VARCHAR(256) NOT NULL,
+    domain VARCHAR(255) NOT NULL,
+    list_name VARCHAR(256) NOT NULL,
+    ord INT NOT NULL,
+    type VARCHAR(32) NOT NULL,
+    value TEXT NOT NULL,
+    action VARCHAR(16) NOT NULL,
+    stanzas VARCHAR(128) NOT NULL,
+    PRIMARY KEY (username, domain, list_name, ord)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
@@ -0,0 +1,91 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+/*
+ * Upgrades a single-domain schema to domain-aware storage keys.
+ * Once applied, run 'jackal --domain-migrate <domain>' to bind
+ * every existing entity to its domain.
+ */
+
+ALTER TABLE users
+    ADD COLUMN domain VARCHAR(255) NOT NULL DEFAULT '' AFTER username,
+    DROP PRIMARY KEY,
+    ADD PRIMARY KEY (username, domain);
+
+ALTER TABLE roster_notifications
+    ADD COLUMN domain VARCHAR(255) NOT NULL DEFAULT '' AFTER contact,
+    DROP PRIMARY KEY,
+    MODIFY jid VARCHAR(256) NOT NULL,
+    ADD PRIMARY KEY (contact, domain, jid);
+
+ALTER TABLE roster_items
+    ADD COLUMN domain VARCHAR(255) NOT NULL DEFAULT '' AFTER username,
+    DROP PRIMARY KEY,
+    MODIFY jid VARCHAR(256) NOT NULL,
+    ADD PRIMARY KEY (username, domain, jid),
+    DROP INDEX i_roster_items_username,
+    ADD INDEX i_roster_items_username (username, domain);
+
+ALTER TABLE roster_versions
+    ADD COLUMN domain VARCHAR(255) NOT NULL DEFAULT '' AFTER username,
+    DROP PRIMARY KEY,
+    ADD PRIMARY KEY (username, domain);
+
+ALTER TABLE blocklist_items
+    ADD COLUMN domain VARCHAR(255) NOT NULL DEFAULT '' AFTER username,
+    DROP PRIMARY KEY,
+    MODIFY jid VARCHAR(256) NOT NULL,
+    ADD PRIMARY KEY (username, domain, jid),
+    DROP INDEX i_blocklist_items_username,
+    ADD INDEX i_blocklist_items_username (username, domain);
+
+ALTER TABLE private_storage
+    ADD COLUMN domain VARCHAR(255) NOT NULL DEFAULT '' AFTER username,
+    DROP PRIMARY KEY,
+    MODIFY namespace VARCHAR(256) NOT NULL,
+    ADD PRIMARY KEY (username, domain, namespace),
+    DROP INDEX i_private_storage_username,
+    ADD INDEX i_private_storage_username (username, domain);
+
+ALTER TABLE vcards
+    ADD COLUMN domain VARCHAR(255) NOT NULL DEFAULT '' AFTER username,
+    DROP PRIMARY KEY,
+    ADD PRIMARY KEY (username, domain);
+
+ALTER TABLE offline_messages
+    ADD COLUMN domain VARCHAR(255) NOT NULL DEFAULT '' AFTER username,
+    DROP INDEX i_offline_messages_username,
+    ADD INDEX i_offline_messages_username (username, domain);
+
+ALTER TABLE archive_messages
+    ADD COLUMN domain VARCHAR(255) NOT NULL DEFAULT '' AFTER username,
+    DROP INDEX i_archive_messages_username,
+    ADD INDEX i_archive_messages_username (username, domain),
+    DROP INDEX i_archive_messages_username_jid,
+    ADD INDEX i_archive_messages_username_jid (username, domain, jid(256));
+
+ALTER TABLE archive_prefs
+    ADD COLUMN domain VARCHAR(255) NOT NULL DEFAULT '' AFTER username,
+    DROP PRIMARY KEY,
+    ADD PRIMARY KEY (username, domain);
+
+ALTER TABLE push_registrations
+    ADD COLUMN domain VARCHAR(255) NOT NULL DEFAULT '' AFTER username,
+    DROP PRIMARY KEY,
+    MODIFY jid VARCHAR(128) NOT NULL,
+    MODIFY node VARCHAR(128) NOT NULL,
+    ADD PRIMARY KEY (username, domain, jid, node),
+    DROP INDEX i_push_registrations_username,
+    ADD INDEX i_push_registrations_username (username, domain);
+
+ALTER TABLE privacy_lists
+    ADD COLUMN domain VARCHAR(255) NOT NULL DEFAULT '' AFTER username,
+    DROP PRIMARY KEY,
+    ADD PRIMARY KEY (username, domain, name);
+
+ALTER TABLE privacy_list_items
+    ADD COLUMN domain VARCHAR(255) NOT NULL DEFAULT '' AFTER username,
+    DROP PRIMARY KEY,
+    ADD PRIMARY KEY (username, domain, list_name, ord);
@@ -0,0 +1,72 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+/*
+ * Upgrades a single-domain schema to domain-aware storage keys.
+ * Once applied, run 'jackal --domain-migrate <domain>' to bind
+ * every existing entity to its domain.
+ */
+
+ALTER TABLE users ADD COLUMN domain VARCHAR(256) NOT NULL DEFAULT '';
+ALTER TABLE users DROP CONSTRAINT users_pkey;
+ALTER TABLE users ADD PRIMARY KEY (username, domain);
+
+ALTER TABLE roster_notifications ADD COLUMN domain VARCHAR(256) NOT NULL DEFAULT '';
+ALTER TABLE roster_notifications DROP CONSTRAINT roster_notifications_pkey;
+ALTER TABLE roster_notifications ADD PRIMARY KEY (contact, domain, jid);
+
+ALTER TABLE roster_items ADD COLUMN domain VARCHAR(256) NOT NULL DEFAULT '';
+ALTER TABLE roster_items DROP CONSTRAINT roster_items_pkey;
+ALTER TABLE roster_items ADD PRIMARY KEY (username, domain, jid);
+DROP INDEX IF EXISTS i_roster_items_username;
+CREATE INDEX i_roster_items_username ON roster_items(username, domain);
+
+ALTER TABLE roster_versions ADD COLUMN domain VARCHAR(256) NOT NULL DEFAULT '';
+ALTER TABLE roster_versions DROP CONSTRAINT roster_versions_pkey;
+ALTER TABLE roster_versions ADD PRIMARY KEY (username, domain);
+
+ALTER TABLE blocklist_items ADD COLUMN domain VARCHAR(256) NOT NULL DEFAULT '';
+ALTER TABLE blocklist_items DROP CONSTRAINT blocklist_items_pkey;
+ALTER TABLE blocklist_items ADD PRIMARY KEY (username, domain, jid);
+DROP INDEX IF EXISTS i_blocklist_items_username;
+CREATE INDEX i_blocklist_items_username ON blocklist_items(username, domain);
+
+ALTER TABLE private_storage ADD COLUMN domain VARCHAR(256) NOT NULL DEFAULT '';
+ALTER TABLE private_storage DROP CONSTRAINT private_storage_pkey;
+ALTER TABLE private_storage ADD PRIMARY KEY (username, domain, namespace);
+DROP INDEX IF EXISTS i_private_storage_username;
+CREATE INDEX i_private_storage_username ON private_storage(username, domain);
+
+ALTER TABLE vcards ADD COLUMN domain VARCHAR(256) NOT NULL DEFAULT '';
+ALTER TABLE vcards DROP CONSTRAINT vcards_pkey;
+ALTER TABLE vcards ADD PRIMARY KEY (username, domain);
+
+ALTER TABLE offline_messages ADD COLUMN domain VARCHAR(256) NOT NULL DEFAULT '';
+DROP INDEX IF EXISTS i_offline_messages_username;
+CREATE INDEX i_offline_messages_username ON offline_messages(username, domain);
+
+ALTER TABLE archive_messages ADD COLUMN domain VARCHAR(256) NOT NULL DEFAULT '';
+DROP INDEX IF EXISTS i_archive_messages_username;
+DROP INDEX IF EXISTS i_archive_messages_username_jid;
+CREATE INDEX i_archive_messages_username ON archive_messages(username, domain);
+CREATE INDEX i_archive_messages_username_jid ON archive_messages(username, domain, jid);
+
+ALTER TABLE archive_prefs ADD COLUMN domain VARCHAR(256) NOT NULL DEFAULT '';
+ALTER TABLE archive_prefs DROP CONSTRAINT archive_prefs_pkey;
+ALTER TABLE archive_prefs ADD PRIMARY KEY (username, domain);
+
+ALTER TABLE push_registrations ADD COLUMN domain VARCHAR(256) NOT NULL DEFAULT '';
+ALTER TABLE push_registrations DROP CONSTRAINT push_registrations_pkey;
+ALTER TABLE push_registrations ADD PRIMARY KEY (username, domain, jid, node);
+DROP INDEX IF EXISTS i_push_registrations_username;
+CREATE INDEX i_push_registrations_username ON push_registrations(username, domain);
+
+ALTER TABLE privacy_lists ADD COLUMN domain VARCHAR(256) NOT NULL DEFAULT '';
+ALTER TABLE privacy_lists DROP CONSTRAINT privacy_lists_pkey;
+ALTER TABLE privacy_lists ADD PRIMARY KEY (username, domain, name);
+
+ALTER TABLE privacy_list_items ADD COLUMN domain VARCHAR(256) NOT NULL DEFAULT '';
+ALTER TABLE privacy_list_items DROP CONSTRAINT privacy_list_items_pkey;
+ALTER TABLE privacy_list_items ADD PRIMARY KEY (username, domain, list_name, ord);
@@ -0,0 +1,73 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+
+	"github.com/dgraph-io/badger"
+	"github.com/ortuman/jackal/model/mammodel"
+)
+
+// InsertArchiveMessage inserts a new message entity into user's archive.
+func (b *Storage) InsertArchiveMessage(message *mammodel.Message) error {
+	return b.db.Update(func(tx *badger.Txn) error {
+		return b.insertOrUpdate(message, b.archiveMessageKey(message), tx)
+	})
+}
+
+// FetchArchiveMessages retrieves from storage, in chronological order,
+// a page of user's archived messages satisfying filter constraints.
+func (b *Storage) FetchArchiveMessages(username, domain string, filter *mammodel.Filter, page *mammodel.Page) (*mammodel.ResultSet, error) {
+	// archive is iterated in chronological order, keeping
+	// no more than a page worth of messages in memory
+	pager := mammodel.NewPager(page)
+	err := b.forEachKeyAndValue([]byte("archiveMessages:"+userID(username, domain)+":"), func(_, val []byte) error {
+		var msg mammodel.Message
+		msg.FromGob(gob.NewDecoder(bytes.NewReader(val)))
+		if filter.Matches(&msg) {
+			pager.Add(&msg)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pager.ResultSet()
+}
+
+// InsertOrUpdateArchivePrefs inserts a new archiving preferences entity
+// into storage, or updates it in case it's been previously inserted.
+func (b *Storage) InsertOrUpdateArchivePrefs(prefs *mammodel.Prefs) error {
+	return b.db.Update(func(tx *badger.Txn) error {
+		return b.insertOrUpdate(prefs, b.archivePrefsKey(prefs.Username, prefs.Domain), tx)
+	})
+}
+
+// FetchArchivePrefs retrieves from storage user's archiving preferences.
+func (b *Storage) FetchArchivePrefs(username, domain string) (*mammodel.Prefs, error) {
+	var prefs mammodel.Prefs
+	err := b.fetch(&prefs, b.archivePrefsKey(username, domain))
+	switch err {
+	case nil:
+		return &prefs, nil
+	case errBadgerDBEntityNotFound:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+func (b *Storage) archiveMessageKey(message *mammodel.Message) []byte {
+	// timestamp prefixed keys keep archive iteration in chronological order
+	return []byte(fmt.Sprintf("archiveMessages:%s:%020d:%s", userID(message.Username, message.Domain), message.CreatedAt.UnixNano(), message.ID))
+}
+
+func (b *Storage) archivePrefsKey(username, domain string) []byte {
+	return []byte("archivePrefs:" + userID(username, domain))
+}
@@ -0,0 +1,70 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/model/mammodel"
+	"github.com/ortuman/jackal/xml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerDB_ArchiveMessages(t *testing.T) {
+	t.Parallel()
+
+	h := tUtilBadgerDBSetup()
+	defer tUtilBadgerDBTeardown(h)
+
+	now := time.Now()
+	m1 := mammodel.Message{ID: "b", Username: "ortuman", Domain: "jackal.im", JID: "noelia@jackal.im", Message: xml.NewElementName("message"), CreatedAt: now}
+	m2 := mammodel.Message{ID: "a", Username: "ortuman", Domain: "jackal.im", JID: "romeo@jackal.im", Message: xml.NewElementName("message"), CreatedAt: now.Add(time.Second)}
+	m3 := mammodel.Message{ID: "c", Username: "ortuman2", Domain: "jackal.im", JID: "romeo@jackal.im", Message: xml.NewElementName("message"), CreatedAt: now}
+
+	require.Nil(t, h.db.InsertArchiveMessage(&m1))
+	require.Nil(t, h.db.InsertArchiveMessage(&m2))
+	require.Nil(t, h.db.InsertArchiveMessage(&m3))
+
+	rs, err := h.db.FetchArchiveMessages("ortuman", "jackal.im", nil, nil)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(rs.Messages))
+	require.Equal(t, "b", rs.Messages[0].ID)
+	require.Equal(t, "a", rs.Messages[1].ID)
+
+	rs, err = h.db.FetchArchiveMessages("ortuman", "jackal.im", &mammodel.Filter{Start: now.Add(time.Millisecond)}, nil)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(rs.Messages))
+	require.Equal(t, "a", rs.Messages[0].ID)
+
+	rs, err = h.db.FetchArchiveMessages("ortuman", "jackal.im", nil, &mammodel.Page{Max: 1, Last: true})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(rs.Messages))
+	require.Equal(t, "a", rs.Messages[0].ID)
+	require.Equal(t, 1, rs.FirstIndex)
+	require.Equal(t, 2, rs.Count)
+
+	_, err = h.db.FetchArchiveMessages("ortuman", "jackal.im", nil, &mammodel.Page{After: "c", Max: 1})
+	require.Equal(t, mammodel.ErrItemNotFound, err)
+}
+
+func TestBadgerDB_ArchivePrefs(t *testing.T) {
+	t.Parallel()
+
+	h := tUtilBadgerDBSetup()
+	defer tUtilBadgerDBTeardown(h)
+
+	prefs, err := h.db.FetchArchivePrefs("ortuman", "jackal.im")
+	require.Nil(t, err)
+	require.Nil(t, prefs)
+
+	p := mammodel.Prefs{Username: "ortuman", Domain: "jackal.im", Default: mammodel.DefaultNever, Always: []string{"noelia@jackal.im"}}
+	require.Nil(t, h.db.InsertOrUpdateArchivePrefs(&p))
+
+	prefs, err = h.db.FetchArchivePrefs("ortuman", "jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, &p, prefs)
+}
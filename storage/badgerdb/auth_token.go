@@ -0,0 +1,45 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"github.com/dgraph-io/badger"
+	"github.com/ortuman/jackal/model"
+)
+
+// InsertOrUpdateAuthToken inserts a new auth token entity into storage,
+// or updates it in case it's been previously inserted for the same device.
+func (b *Storage) InsertOrUpdateAuthToken(token *model.AuthToken) error {
+	return b.db.Update(func(tx *badger.Txn) error {
+		return b.insertOrUpdate(token, b.authTokenKey(token.Username, token.Domain, token.Device), tx)
+	})
+}
+
+// DeleteAuthTokens deletes from storage a user device auth token.
+// In case device is empty every token associated to the user
+// will be deleted.
+func (b *Storage) DeleteAuthTokens(username, domain, device string) error {
+	return b.db.Update(func(tx *badger.Txn) error {
+		if len(device) > 0 {
+			return b.delete(b.authTokenKey(username, domain, device), tx)
+		}
+		return b.deletePrefix(b.authTokenKey(username, domain, ""), tx)
+	})
+}
+
+// FetchAuthTokens retrieves from storage all auth token
+// entities associated to a given user.
+func (b *Storage) FetchAuthTokens(username, domain string) ([]model.AuthToken, error) {
+	var tokens []model.AuthToken
+	if err := b.fetchAll(&tokens, b.authTokenKey(username, domain, "")); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (b *Storage) authTokenKey(username, domain, device string) []byte {
+	return []byte("authTokens:" + userID(username, domain) + ":" + device)
+}
@@ -0,0 +1,48 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerDB_AuthTokens(t *testing.T) {
+	t.Parallel()
+
+	h := tUtilBadgerDBSetup()
+	defer tUtilBadgerDBTeardown(h)
+
+	expiresAt := time.Now().Add(time.Hour).UTC()
+	t1 := model.AuthToken{Username: "ortuman", Domain: "jackal.im", Device: "balcony", Hash: "01", ExpiresAt: expiresAt, CreatedAt: expiresAt}
+	t2 := model.AuthToken{Username: "ortuman", Domain: "jackal.im", Device: "garden", Hash: "02", ExpiresAt: expiresAt, CreatedAt: expiresAt}
+	t3 := model.AuthToken{Username: "ortuman", Domain: "jackal.im", Device: "balcony", Hash: "03", ExpiresAt: expiresAt, CreatedAt: expiresAt}
+
+	require.Nil(t, h.db.InsertOrUpdateAuthToken(&t1))
+	require.Nil(t, h.db.InsertOrUpdateAuthToken(&t2))
+	require.Nil(t, h.db.InsertOrUpdateAuthToken(&t3))
+
+	tokens, err := h.db.FetchAuthTokens("ortuman", "jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, 2, len(tokens))
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].Device < tokens[j].Device })
+	require.Equal(t, "balcony", tokens[0].Device)
+	require.Equal(t, "03", tokens[0].Hash)
+	require.True(t, expiresAt.Equal(tokens[0].ExpiresAt))
+	require.Equal(t, "garden", tokens[1].Device)
+
+	require.Nil(t, h.db.DeleteAuthTokens("ortuman", "jackal.im", "balcony"))
+	tokens, _ = h.db.FetchAuthTokens("ortuman", "jackal.im")
+	require.Equal(t, 1, len(tokens))
+
+	require.Nil(t, h.db.DeleteAuthTokens("ortuman", "jackal.im", ""))
+	tokens, _ = h.db.FetchAuthTokens("ortuman", "jackal.im")
+	require.Equal(t, 0, len(tokens))
+}
@@ -204,3 +204,8 @@ func (b *Storage) forEachKeyAndValue(prefix []byte, f func(k, v []byte) error) e
 		return nil
 	})
 }
+
+// userID returns the identifier used to scope user entity keys.
+func userID(username, domain string) string {
+	return username + "@" + domain
+}
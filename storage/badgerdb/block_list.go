@@ -15,7 +15,7 @@ import (
 func (b *Storage) InsertBlockListItems(items []model.BlockListItem) error {
 	return b.db.Update(func(tx *badger.Txn) error {
 		for _, item := range items {
-			if err := b.insertOrUpdate(&item, b.blockListItemKey(item.Username, item.JID), tx); err != nil {
+			if err := b.insertOrUpdate(&item, b.blockListItemKey(item.Username, item.Domain, item.JID), tx); err != nil {
 				return err
 			}
 		}
@@ -27,7 +27,7 @@ func (b *Storage) InsertBlockListItems(items []model.BlockListItem) error {
 func (b *Storage) DeleteBlockListItems(items []model.BlockListItem) error {
 	return b.db.Update(func(tx *badger.Txn) error {
 		for _, item := range items {
-			if err := b.delete(b.blockListItemKey(item.Username, item.JID), tx); err != nil {
+			if err := b.delete(b.blockListItemKey(item.Username, item.Domain, item.JID), tx); err != nil {
 				return err
 			}
 		}
@@ -37,14 +37,14 @@ func (b *Storage) DeleteBlockListItems(items []model.BlockListItem) error {
 
 // FetchBlockListItems retrieves from storage all block list item entities
 // associated to a given user.
-func (b *Storage) FetchBlockListItems(username string) ([]model.BlockListItem, error) {
+func (b *Storage) FetchBlockListItems(username, domain string) ([]model.BlockListItem, error) {
 	var blItems []model.BlockListItem
-	if err := b.fetchAll(&blItems, []byte("blockListItems:"+username)); err != nil {
+	if err := b.fetchAll(&blItems, []byte("blockListItems:"+userID(username, domain)+":")); err != nil {
 		return nil, err
 	}
 	return blItems, nil
 }
 
-func (b *Storage) blockListItemKey(username, jid string) []byte {
-	return []byte("blockListItems:" + username + ":" + jid)
+func (b *Storage) blockListItemKey(username, domain, jid string) []byte {
+	return []byte("blockListItems:" + userID(username, domain) + ":" + jid)
 }
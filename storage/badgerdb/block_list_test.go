@@ -20,30 +20,30 @@ func TestBadgerDB_BlockListItems(t *testing.T) {
 	defer tUtilBadgerDBTeardown(h)
 
 	items := []model.BlockListItem{
-		{"ortuman", "juliet@jackal.im"},
-		{"ortuman", "user@jackal.im"},
-		{"ortuman", "romeo@jackal.im"},
+		{"ortuman", "jackal.im", "juliet@jackal.im"},
+		{"ortuman", "jackal.im", "user@jackal.im"},
+		{"ortuman", "jackal.im", "romeo@jackal.im"},
 	}
 	sort.Slice(items, func(i, j int) bool { return items[i].JID < items[j].JID })
 
 	err := h.db.InsertBlockListItems(items)
 	require.Nil(t, err)
 
-	sItems, err := h.db.FetchBlockListItems("ortuman")
+	sItems, err := h.db.FetchBlockListItems("ortuman", "jackal.im")
 	sort.Slice(sItems, func(i, j int) bool { return sItems[i].JID < sItems[j].JID })
 	require.Nil(t, err)
 	require.Equal(t, items, sItems)
 
 	items = append(items[:1], items[2:]...)
-	h.db.DeleteBlockListItems([]model.BlockListItem{{"ortuman", "romeo@jackal.im"}})
+	h.db.DeleteBlockListItems([]model.BlockListItem{{"ortuman", "jackal.im", "romeo@jackal.im"}})
 
-	sItems, err = h.db.FetchBlockListItems("ortuman")
+	sItems, err = h.db.FetchBlockListItems("ortuman", "jackal.im")
 	sort.Slice(items, func(i, j int) bool { return items[i].JID < items[j].JID })
 	require.Nil(t, err)
 	require.Equal(t, items, sItems)
 
 	err = h.db.DeleteBlockListItems(items)
 	require.Nil(t, err)
-	sItems, _ = h.db.FetchBlockListItems("ortuman")
+	sItems, _ = h.db.FetchBlockListItems("ortuman", "jackal.im")
 	require.Equal(t, 0, len(sItems))
 }
@@ -0,0 +1,106 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"bytes"
+	"encoding/gob"
+	"reflect"
+	"strings"
+
+	"github.com/dgraph-io/badger"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/model/mammodel"
+	"github.com/ortuman/jackal/model/privacymodel"
+	"github.com/ortuman/jackal/model/rostermodel"
+)
+
+const usersPrefix = "users:"
+
+// domainEntity describes a set of user entities sharing a key prefix.
+// newEntity is nil for those entities not keeping track of its domain.
+type domainEntity struct {
+	prefix    string
+	newEntity func() interface{}
+}
+
+var domainEntities = []domainEntity{
+	{usersPrefix, func() interface{} { return &model.User{} }},
+	{"rosterItems:", func() interface{} { return &rostermodel.Item{} }},
+	{"rosterVersions:", nil},
+	{"rosterNotifications:", func() interface{} { return &rostermodel.Notification{} }},
+	{"vCards:", nil},
+	{"privateElements:", nil},
+	{"offlineMessages:", nil},
+	{"blockListItems:", func() interface{} { return &model.BlockListItem{} }},
+	{"archiveMessages:", func() interface{} { return &mammodel.Message{} }},
+	{"archivePrefs:", func() interface{} { return &mammodel.Prefs{} }},
+	{"pushRegistrations:", func() interface{} { return &model.PushRegistration{} }},
+	{"privacyLists:", func() interface{} { return &privacymodel.List{} }},
+}
+
+// MigrateDomain associates every stored entity not yet bound to a domain
+// to the given one, returning the number of migrated users.
+func (b *Storage) MigrateDomain(domain string) (int, error) {
+	var count int
+	for _, de := range domainEntities {
+		n, err := b.migrateDomainEntities(de, domain)
+		if err != nil {
+			return count, err
+		}
+		if de.prefix == usersPrefix {
+			count = n
+		}
+	}
+	return count, nil
+}
+
+func (b *Storage) migrateDomainEntities(de domainEntity, domain string) (int, error) {
+	var keys, vals [][]byte
+	if err := b.forEachKeyAndValue([]byte(de.prefix), func(k, v []byte) error {
+		if !isLegacyUserKey(string(k[len(de.prefix):])) {
+			return nil
+		}
+		keys = append(keys, append([]byte(nil), k...))
+		vals = append(vals, append([]byte(nil), v...))
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+	for i, k := range keys {
+		// legacy keys are in 'prefix:username[:suffix]' form
+		username := string(k[len(de.prefix):])
+		var suffix string
+		if idx := strings.IndexByte(username, ':'); idx != -1 {
+			username, suffix = username[:idx], username[idx:]
+		}
+		newKey := []byte(de.prefix + userID(username, domain) + suffix)
+
+		if err := b.db.Update(func(tx *badger.Txn) error {
+			if de.newEntity != nil {
+				entity := de.newEntity()
+				entity.(model.GobDeserializer).FromGob(gob.NewDecoder(bytes.NewReader(vals[i])))
+				reflect.ValueOf(entity).Elem().FieldByName("Domain").SetString(domain)
+				if err := b.insertOrUpdate(entity, newKey, tx); err != nil {
+					return err
+				}
+			} else if err := tx.Set(newKey, vals[i]); err != nil {
+				return err
+			}
+			return b.delete(k, tx)
+		}); err != nil {
+			return i, err
+		}
+	}
+	return len(keys), nil
+}
+
+func isLegacyUserKey(key string) bool {
+	if idx := strings.IndexByte(key, ':'); idx != -1 {
+		key = key[:idx]
+	}
+	return !strings.Contains(key, "@")
+}
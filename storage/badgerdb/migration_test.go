@@ -0,0 +1,73 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/badger"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/model/rostermodel"
+	"github.com/ortuman/jackal/xml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerDB_MigrateDomain(t *testing.T) {
+	t.Parallel()
+
+	h := tUtilBadgerDBSetup()
+	defer tUtilBadgerDBTeardown(h)
+
+	// legacy single domain entities
+	usr := model.User{Username: "ortuman", Password: "1234"}
+	ri := rostermodel.Item{Username: "ortuman", JID: "noelia@jackal.im", Subscription: rostermodel.SubscriptionBoth}
+	vCard := xml.NewElementNamespace("vCard", "vcard-temp")
+	err := h.db.db.Update(func(tx *badger.Txn) error {
+		if err := h.db.insertOrUpdate(&usr, []byte("users:ortuman"), tx); err != nil {
+			return err
+		}
+		if err := h.db.insertOrUpdate(&ri, []byte("rosterItems:ortuman:noelia@jackal.im"), tx); err != nil {
+			return err
+		}
+		return h.db.insertOrUpdate(vCard, []byte("vCards:ortuman"), tx)
+	})
+	require.Nil(t, err)
+
+	// already migrated entity
+	require.Nil(t, h.db.InsertOrUpdateUser(&model.User{Username: "noelia", Domain: "example.org", Password: "5678"}))
+
+	count, err := h.db.MigrateDomain("jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, 1, count)
+
+	usr2, err := h.db.FetchUser("ortuman", "jackal.im")
+	require.Nil(t, err)
+	require.NotNil(t, usr2)
+	require.Equal(t, "jackal.im", usr2.Domain)
+	require.Equal(t, "1234", usr2.Password)
+
+	ris, _, err := h.db.FetchRosterItems("ortuman", "jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(ris))
+	require.Equal(t, "jackal.im", ris[0].Domain)
+	require.Equal(t, "noelia@jackal.im", ris[0].JID)
+
+	vCard2, err := h.db.FetchVCard("ortuman", "jackal.im")
+	require.Nil(t, err)
+	require.NotNil(t, vCard2)
+
+	users, err := h.db.FetchUsers()
+	require.Nil(t, err)
+	require.Equal(t, 2, len(users))
+
+	usr3, _ := h.db.FetchUser("noelia", "example.org")
+	require.NotNil(t, usr3)
+
+	// nothing left to migrate
+	count, err = h.db.MigrateDomain("jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, 0, count)
+}
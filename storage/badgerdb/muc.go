@@ -0,0 +1,58 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"github.com/dgraph-io/badger"
+	"github.com/ortuman/jackal/model/mucmodel"
+	"github.com/ortuman/jackal/xml/jid"
+)
+
+// InsertOrUpdateRoom inserts a new room entity into storage,
+// or updates it in case it's been previously inserted.
+func (b *Storage) InsertOrUpdateRoom(room *mucmodel.Room) error {
+	return b.db.Update(func(tx *badger.Txn) error {
+		return b.insertOrUpdate(room, b.roomKey(room.JID), tx)
+	})
+}
+
+// DeleteRoom deletes a room entity from storage.
+func (b *Storage) DeleteRoom(roomJID string) error {
+	return b.db.Update(func(tx *badger.Txn) error {
+		return b.delete(b.roomKey(roomJID), tx)
+	})
+}
+
+// FetchRoom retrieves from storage a room entity.
+func (b *Storage) FetchRoom(roomJID string) (*mucmodel.Room, error) {
+	var room mucmodel.Room
+	err := b.fetch(&room, b.roomKey(roomJID))
+	switch err {
+	case nil:
+		return &room, nil
+	case errBadgerDBEntityNotFound:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+// FetchRooms retrieves from storage all rooms associated to a MUC service domain.
+func (b *Storage) FetchRooms(service string) ([]mucmodel.Room, error) {
+	var rooms []mucmodel.Room
+	if err := b.fetchAll(&rooms, []byte("mucRooms:"+service+":")); err != nil {
+		return nil, err
+	}
+	return rooms, nil
+}
+
+func (b *Storage) roomKey(roomJID string) []byte {
+	var service string
+	if j, err := jid.NewWithString(roomJID, true); err == nil {
+		service = j.Domain()
+	}
+	return []byte("mucRooms:" + service + ":" + roomJID)
+}
@@ -0,0 +1,46 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/model/mucmodel"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerDB_Room(t *testing.T) {
+	t.Parallel()
+
+	h := tUtilBadgerDBSetup()
+	defer tUtilBadgerDBTeardown(h)
+
+	r1 := mucmodel.Room{
+		JID:          "lobby@conference.jackal.im",
+		Name:         "Lobby",
+		Config:       mucmodel.RoomConfig{Persistent: true, Public: true},
+		Affiliations: map[string]string{"ortuman@jackal.im": mucmodel.AffiliationOwner},
+	}
+	r2 := mucmodel.Room{JID: "lobby@conference.example.org", Name: "Lobby"}
+
+	require.Nil(t, h.db.InsertOrUpdateRoom(&r1))
+	require.Nil(t, h.db.InsertOrUpdateRoom(&r2))
+
+	r3, err := h.db.FetchRoom("lobby@conference.jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, r1, *r3)
+
+	rooms, err := h.db.FetchRooms("conference.jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(rooms))
+	require.Equal(t, r1, rooms[0])
+
+	require.Nil(t, h.db.DeleteRoom("lobby@conference.jackal.im"))
+
+	r3, err = h.db.FetchRoom("lobby@conference.jackal.im")
+	require.Nil(t, err)
+	require.Nil(t, r3)
+}
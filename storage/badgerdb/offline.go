@@ -12,16 +12,16 @@ import (
 
 // InsertOfflineMessage inserts a new message element into
 // user's offline queue.
-func (b *Storage) InsertOfflineMessage(message xml.XElement, username string) error {
+func (b *Storage) InsertOfflineMessage(message xml.XElement, username, domain string) error {
 	return b.db.Update(func(tx *badger.Txn) error {
-		return b.insertOrUpdate(message, b.offlineMessageKey(username, message.ID()), tx)
+		return b.insertOrUpdate(message, b.offlineMessageKey(username, domain, message.ID()), tx)
 	})
 }
 
 // CountOfflineMessages returns current length of user's offline queue.
-func (b *Storage) CountOfflineMessages(username string) (int, error) {
+func (b *Storage) CountOfflineMessages(username, domain string) (int, error) {
 	cnt := 0
-	prefix := []byte("offlineMessages:" + username)
+	prefix := []byte("offlineMessages:" + userID(username, domain) + ":")
 	err := b.forEachKey(prefix, func(key []byte) error {
 		cnt++
 		return nil
@@ -30,9 +30,9 @@ func (b *Storage) CountOfflineMessages(username string) (int, error) {
 }
 
 // FetchOfflineMessages retrieves from storage current user offline queue.
-func (b *Storage) FetchOfflineMessages(username string) ([]xml.XElement, error) {
+func (b *Storage) FetchOfflineMessages(username, domain string) ([]xml.XElement, error) {
 	var msgs []xml.Element
-	if err := b.fetchAll(&msgs, []byte("offlineMessages:"+username)); err != nil {
+	if err := b.fetchAll(&msgs, []byte("offlineMessages:"+userID(username, domain)+":")); err != nil {
 		return nil, err
 	}
 	switch len(msgs) {
@@ -48,12 +48,12 @@ func (b *Storage) FetchOfflineMessages(username string) ([]xml.XElement, error)
 }
 
 // DeleteOfflineMessages clears a user offline queue.
-func (b *Storage) DeleteOfflineMessages(username string) error {
+func (b *Storage) DeleteOfflineMessages(username, domain string) error {
 	return b.db.Update(func(tx *badger.Txn) error {
-		return b.deletePrefix([]byte("offlineMessages:"+username), tx)
+		return b.deletePrefix([]byte("offlineMessages:"+userID(username, domain)+":"), tx)
 	})
 }
 
-func (b *Storage) offlineMessageKey(username, identifier string) []byte {
-	return []byte("offlineMessages:" + username + ":" + identifier)
+func (b *Storage) offlineMessageKey(username, domain, identifier string) []byte {
+	return []byte("offlineMessages:" + userID(username, domain) + ":" + identifier)
 }
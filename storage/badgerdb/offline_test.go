@@ -29,23 +29,23 @@ func TestBadgerDB_OfflineMessages(t *testing.T) {
 	b2.SetText("what's up?!")
 	msg1.AppendElement(b1)
 
-	require.NoError(t, h.db.InsertOfflineMessage(msg1, "ortuman"))
-	require.NoError(t, h.db.InsertOfflineMessage(msg2, "ortuman"))
+	require.NoError(t, h.db.InsertOfflineMessage(msg1, "ortuman", "jackal.im"))
+	require.NoError(t, h.db.InsertOfflineMessage(msg2, "ortuman", "jackal.im"))
 
-	cnt, err := h.db.CountOfflineMessages("ortuman")
+	cnt, err := h.db.CountOfflineMessages("ortuman", "jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, 2, cnt)
 
-	msgs, err := h.db.FetchOfflineMessages("ortuman")
+	msgs, err := h.db.FetchOfflineMessages("ortuman", "jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, 2, len(msgs))
 
-	msgs2, err := h.db.FetchOfflineMessages("ortuman2")
+	msgs2, err := h.db.FetchOfflineMessages("ortuman2", "jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, 0, len(msgs2))
 
-	require.NoError(t, h.db.DeleteOfflineMessages("ortuman"))
-	cnt, err = h.db.CountOfflineMessages("ortuman")
+	require.NoError(t, h.db.DeleteOfflineMessages("ortuman", "jackal.im"))
+	cnt, err = h.db.CountOfflineMessages("ortuman", "jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, 0, cnt)
 }
@@ -0,0 +1,63 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"github.com/dgraph-io/badger"
+	"github.com/ortuman/jackal/model/privacymodel"
+)
+
+// InsertOrUpdatePrivacyList inserts a new privacy list entity into storage,
+// or updates it in case it's been previously inserted.
+func (b *Storage) InsertOrUpdatePrivacyList(list *privacymodel.List) error {
+	return b.db.Update(func(tx *badger.Txn) error {
+		return b.insertOrUpdate(list, b.privacyListKey(list.Username, list.Domain, list.Name), tx)
+	})
+}
+
+// DeletePrivacyList deletes a privacy list entity from storage.
+func (b *Storage) DeletePrivacyList(username, domain, name string) error {
+	return b.db.Update(func(tx *badger.Txn) error {
+		return b.delete(b.privacyListKey(username, domain, name), tx)
+	})
+}
+
+// FetchPrivacyLists retrieves from storage all privacy list entities
+// associated to a given user.
+func (b *Storage) FetchPrivacyLists(username, domain string) ([]privacymodel.List, error) {
+	var lists []privacymodel.List
+	if err := b.fetchAll(&lists, b.privacyListKey(username, domain, "")); err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+// SetDefaultPrivacyList marks a user privacy list as the default one,
+// unmarking any previous one. An empty name just declines
+// the use of a default list.
+func (b *Storage) SetDefaultPrivacyList(username, domain, name string) error {
+	lists, err := b.FetchPrivacyLists(username, domain)
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *badger.Txn) error {
+		for i := range lists {
+			isDefault := lists[i].Name == name
+			if lists[i].Default == isDefault {
+				continue
+			}
+			lists[i].Default = isDefault
+			if err := b.insertOrUpdate(&lists[i], b.privacyListKey(username, domain, lists[i].Name), tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (b *Storage) privacyListKey(username, domain, name string) []byte {
+	return []byte("privacyLists:" + userID(username, domain) + ":" + name)
+}
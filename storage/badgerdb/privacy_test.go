@@ -0,0 +1,45 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/ortuman/jackal/model/privacymodel"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerDB_PrivacyLists(t *testing.T) {
+	t.Parallel()
+
+	h := tUtilBadgerDBSetup()
+	defer tUtilBadgerDBTeardown(h)
+
+	l1 := privacymodel.List{Username: "ortuman", Domain: "jackal.im", Name: "public", Items: []privacymodel.Item{
+		{Type: privacymodel.JIDType, Value: "romeo@jackal.im", Action: privacymodel.Deny, Order: 1, Stanzas: []string{privacymodel.Message}},
+	}}
+	l2 := privacymodel.List{Username: "ortuman", Domain: "jackal.im", Name: "private", Items: []privacymodel.Item{
+		{Action: privacymodel.Deny, Order: 1},
+	}}
+	require.Nil(t, h.db.InsertOrUpdatePrivacyList(&l1))
+	require.Nil(t, h.db.InsertOrUpdatePrivacyList(&l2))
+
+	lists, err := h.db.FetchPrivacyLists("ortuman", "jackal.im")
+	require.Nil(t, err)
+	sort.Slice(lists, func(i, j int) bool { return lists[i].Name > lists[j].Name })
+	require.Equal(t, []privacymodel.List{l1, l2}, lists)
+
+	require.Nil(t, h.db.SetDefaultPrivacyList("ortuman", "jackal.im", "public"))
+	lists, _ = h.db.FetchPrivacyLists("ortuman", "jackal.im")
+	for _, l := range lists {
+		require.Equal(t, l.Name == "public", l.Default)
+	}
+	require.Nil(t, h.db.DeletePrivacyList("ortuman", "jackal.im", "public"))
+	lists, _ = h.db.FetchPrivacyLists("ortuman", "jackal.im")
+	require.Equal(t, 1, len(lists))
+	require.Equal(t, "private", lists[0].Name)
+}
@@ -12,18 +12,18 @@ import (
 
 // InsertOrUpdatePrivateXML inserts a new private element into storage,
 // or updates it in case it's been previously inserted.
-func (b *Storage) InsertOrUpdatePrivateXML(privateXML []xml.XElement, namespace string, username string) error {
+func (b *Storage) InsertOrUpdatePrivateXML(privateXML []xml.XElement, namespace, username, domain string) error {
 	r := xml.NewElementName("r")
 	r.AppendElements(privateXML)
 	return b.db.Update(func(tx *badger.Txn) error {
-		return b.insertOrUpdate(r, b.privateStorageKey(username, namespace), tx)
+		return b.insertOrUpdate(r, b.privateStorageKey(username, domain, namespace), tx)
 	})
 }
 
 // FetchPrivateXML retrieves from storage a private element.
-func (b *Storage) FetchPrivateXML(namespace string, username string) ([]xml.XElement, error) {
+func (b *Storage) FetchPrivateXML(namespace, username, domain string) ([]xml.XElement, error) {
 	var r xml.Element
-	err := b.fetch(&r, b.privateStorageKey(username, namespace))
+	err := b.fetch(&r, b.privateStorageKey(username, domain, namespace))
 	switch err {
 	case nil:
 		return r.Elements().All(), nil
@@ -34,6 +34,6 @@ func (b *Storage) FetchPrivateXML(namespace string, username string) ([]xml.XEle
 	}
 }
 
-func (b *Storage) privateStorageKey(username, namespace string) []byte {
-	return []byte("privateElements:" + username + ":" + namespace)
+func (b *Storage) privateStorageKey(username, domain, namespace string) []byte {
+	return []byte("privateElements:" + userID(username, domain) + ":" + namespace)
 }
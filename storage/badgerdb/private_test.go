@@ -21,13 +21,13 @@ func TestBadgerDB_PrivateXML(t *testing.T) {
 	pv1 := xml.NewElementNamespace("ex1", "exodus:ns")
 	pv2 := xml.NewElementNamespace("ex2", "exodus:ns")
 
-	require.NoError(t, h.db.InsertOrUpdatePrivateXML([]xml.XElement{pv1, pv2}, "exodus:ns", "ortuman"))
+	require.NoError(t, h.db.InsertOrUpdatePrivateXML([]xml.XElement{pv1, pv2}, "exodus:ns", "ortuman", "jackal.im"))
 
-	prvs, err := h.db.FetchPrivateXML("exodus:ns", "ortuman")
+	prvs, err := h.db.FetchPrivateXML("exodus:ns", "ortuman", "jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, 2, len(prvs))
 
-	prvs2, err := h.db.FetchPrivateXML("exodus:ns", "ortuman2")
+	prvs2, err := h.db.FetchPrivateXML("exodus:ns", "ortuman2", "jackal.im")
 	require.Nil(t, prvs2)
 	require.Nil(t, err)
 }
@@ -0,0 +1,156 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"bytes"
+	"encoding/gob"
+
+	"github.com/dgraph-io/badger"
+	"github.com/ortuman/jackal/model/pubsubmodel"
+)
+
+// pubSubItems represents the whole set of items published into a node.
+// Items are stored altogether under a single key, since node names
+// may contain any character and thus can't be safely used as a key prefix.
+type pubSubItems []pubsubmodel.Item
+
+func (p *pubSubItems) FromGob(dec *gob.Decoder) {
+	var count int
+	dec.Decode(&count)
+	items := make([]pubsubmodel.Item, count)
+	for i := 0; i < count; i++ {
+		items[i].FromGob(dec)
+	}
+	*p = items
+}
+
+func (p *pubSubItems) ToGob(enc *gob.Encoder) {
+	count := len(*p)
+	enc.Encode(&count)
+	for i := 0; i < count; i++ {
+		(*p)[i].ToGob(enc)
+	}
+}
+
+// InsertOrUpdatePubSubNode inserts a new pubsub node entity into storage,
+// or updates it in case it's been previously inserted.
+func (b *Storage) InsertOrUpdatePubSubNode(node *pubsubmodel.Node) error {
+	return b.db.Update(func(tx *badger.Txn) error {
+		return b.insertOrUpdate(node, b.pubSubNodeKey(node.Host, node.Name), tx)
+	})
+}
+
+// DeletePubSubNode deletes a pubsub node entity from storage
+// along with all its published items.
+func (b *Storage) DeletePubSubNode(host, name string) error {
+	return b.db.Update(func(tx *badger.Txn) error {
+		if err := b.delete(b.pubSubItemsKey(host, name), tx); err != nil {
+			return err
+		}
+		return b.delete(b.pubSubNodeKey(host, name), tx)
+	})
+}
+
+// FetchPubSubNode retrieves from storage a pubsub node entity.
+func (b *Storage) FetchPubSubNode(host, name string) (*pubsubmodel.Node, error) {
+	var node pubsubmodel.Node
+	err := b.fetch(&node, b.pubSubNodeKey(host, name))
+	switch err {
+	case nil:
+		return &node, nil
+	case errBadgerDBEntityNotFound:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+// FetchPubSubNodes retrieves from storage all pubsub node entities
+// associated to a given host.
+func (b *Storage) FetchPubSubNodes(host string) ([]pubsubmodel.Node, error) {
+	var nodes []pubsubmodel.Node
+	if err := b.fetchAll(&nodes, []byte("pubsubNodes:"+host+"/")); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// InsertOrUpdatePubSubItem inserts a new item entity into a pubsub node,
+// or updates it in case it's been previously inserted.
+// Oldest node items will be discarded beyond maxItems (if greater than zero).
+func (b *Storage) InsertOrUpdatePubSubItem(item *pubsubmodel.Item, host, name string, maxItems int) error {
+	return b.db.Update(func(tx *badger.Txn) error {
+		items, err := b.fetchPubSubItems(host, name, tx)
+		if err != nil {
+			return err
+		}
+		for i, it := range items {
+			if it.ID == item.ID {
+				items = append(items[:i], items[i+1:]...)
+				break
+			}
+		}
+		items = append(items, *item)
+		if maxItems > 0 && len(items) > maxItems {
+			items = items[len(items)-maxItems:]
+		}
+		return b.insertOrUpdate(&items, b.pubSubItemsKey(host, name), tx)
+	})
+}
+
+// DeletePubSubItem deletes a pubsub node item entity from storage.
+func (b *Storage) DeletePubSubItem(itemID, host, name string) error {
+	return b.db.Update(func(tx *badger.Txn) error {
+		items, err := b.fetchPubSubItems(host, name, tx)
+		if err != nil {
+			return err
+		}
+		for i, it := range items {
+			if it.ID == itemID {
+				items = append(items[:i], items[i+1:]...)
+				return b.insertOrUpdate(&items, b.pubSubItemsKey(host, name), tx)
+			}
+		}
+		return nil
+	})
+}
+
+// FetchPubSubItems retrieves from storage, in chronological order,
+// all items published into a pubsub node.
+func (b *Storage) FetchPubSubItems(host, name string) ([]pubsubmodel.Item, error) {
+	var items pubSubItems
+	err := b.fetch(&items, b.pubSubItemsKey(host, name))
+	switch err {
+	case nil:
+		if len(items) == 0 {
+			return nil, nil
+		}
+		return items, nil
+	case errBadgerDBEntityNotFound:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+func (b *Storage) fetchPubSubItems(host, name string, tx *badger.Txn) (pubSubItems, error) {
+	val, err := b.getVal(b.pubSubItemsKey(host, name), tx)
+	if err != nil || val == nil {
+		return nil, err
+	}
+	var items pubSubItems
+	items.FromGob(gob.NewDecoder(bytes.NewReader(val)))
+	return items, nil
+}
+
+func (b *Storage) pubSubNodeKey(host, name string) []byte {
+	return []byte("pubsubNodes:" + host + "/" + name)
+}
+
+func (b *Storage) pubSubItemsKey(host, name string) []byte {
+	return []byte("pubsubItems:" + host + "/" + name)
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/model/pubsubmodel"
+	"github.com/ortuman/jackal/xml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerDB_PubSubNode(t *testing.T) {
+	t.Parallel()
+
+	h := tUtilBadgerDBSetup()
+	defer tUtilBadgerDBTeardown(h)
+
+	n1 := pubsubmodel.Node{
+		Host:          "pubsub.jackal.im",
+		Name:          "princely_musings",
+		Options:       pubsubmodel.DefaultOptions(),
+		Affiliations:  map[string]string{"ortuman@jackal.im": pubsubmodel.AffiliationOwner},
+		Subscriptions: map[string]string{"noelia@jackal.im": "1234"},
+	}
+	n2 := pubsubmodel.Node{Host: "pubsub.example.org", Name: "princely_musings", Options: pubsubmodel.DefaultOptions()}
+
+	require.Nil(t, h.db.InsertOrUpdatePubSubNode(&n1))
+	require.Nil(t, h.db.InsertOrUpdatePubSubNode(&n2))
+
+	n3, err := h.db.FetchPubSubNode("pubsub.jackal.im", "princely_musings")
+	require.Nil(t, err)
+	require.Equal(t, n1, *n3)
+
+	nodes, err := h.db.FetchPubSubNodes("pubsub.jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(nodes))
+	require.Equal(t, n1, nodes[0])
+
+	require.Nil(t, h.db.DeletePubSubNode("pubsub.jackal.im", "princely_musings"))
+
+	n3, err = h.db.FetchPubSubNode("pubsub.jackal.im", "princely_musings")
+	require.Nil(t, err)
+	require.Nil(t, n3)
+}
+
+func TestBadgerDB_PubSubItems(t *testing.T) {
+	t.Parallel()
+
+	h := tUtilBadgerDBSetup()
+	defer tUtilBadgerDBTeardown(h)
+
+	payload := xml.NewElementNamespace("entry", "http://www.w3.org/2005/Atom")
+	now := time.Now().UTC()
+
+	require.Nil(t, h.db.InsertOrUpdatePubSubItem(&pubsubmodel.Item{ID: "1", Payload: payload, CreatedAt: now}, "pubsub.jackal.im", "princely_musings", 2))
+	require.Nil(t, h.db.InsertOrUpdatePubSubItem(&pubsubmodel.Item{ID: "2", Payload: payload, CreatedAt: now}, "pubsub.jackal.im", "princely_musings", 2))
+	require.Nil(t, h.db.InsertOrUpdatePubSubItem(&pubsubmodel.Item{ID: "3", Payload: payload, CreatedAt: now}, "pubsub.jackal.im", "princely_musings", 2))
+
+	items, err := h.db.FetchPubSubItems("pubsub.jackal.im", "princely_musings")
+	require.Nil(t, err)
+	require.Equal(t, 2, len(items))
+	require.Equal(t, "2", items[0].ID)
+	require.Equal(t, "3", items[1].ID)
+	require.Equal(t, payload.String(), items[1].Payload.String())
+
+	require.Nil(t, h.db.DeletePubSubItem("2", "pubsub.jackal.im", "princely_musings"))
+	items, err = h.db.FetchPubSubItems("pubsub.jackal.im", "princely_musings")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(items))
+	require.Equal(t, "3", items[0].ID)
+
+	require.Nil(t, h.db.DeletePubSubNode("pubsub.jackal.im", "princely_musings"))
+	items, err = h.db.FetchPubSubItems("pubsub.jackal.im", "princely_musings")
+	require.Nil(t, err)
+	require.Nil(t, items)
+}
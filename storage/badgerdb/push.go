@@ -0,0 +1,45 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"github.com/dgraph-io/badger"
+	"github.com/ortuman/jackal/model"
+)
+
+// InsertOrUpdatePushRegistration inserts a new push registration entity
+// into storage, or updates it in case it's been previously inserted.
+func (b *Storage) InsertOrUpdatePushRegistration(reg *model.PushRegistration) error {
+	return b.db.Update(func(tx *badger.Txn) error {
+		return b.insertOrUpdate(reg, b.pushRegistrationKey(reg.Username, reg.Domain, reg.JID, reg.Node), tx)
+	})
+}
+
+// DeletePushRegistrations deletes from storage a user push registration
+// entity. In case node is empty every registration associated to
+// the app server jid will be deleted.
+func (b *Storage) DeletePushRegistrations(username, domain, jid, node string) error {
+	return b.db.Update(func(tx *badger.Txn) error {
+		if len(node) > 0 {
+			return b.delete(b.pushRegistrationKey(username, domain, jid, node), tx)
+		}
+		return b.deletePrefix(b.pushRegistrationKey(username, domain, jid, ""), tx)
+	})
+}
+
+// FetchPushRegistrations retrieves from storage all push registration
+// entities associated to a given user.
+func (b *Storage) FetchPushRegistrations(username, domain string) ([]model.PushRegistration, error) {
+	var regs []model.PushRegistration
+	if err := b.fetchAll(&regs, []byte("pushRegistrations:"+userID(username, domain)+":")); err != nil {
+		return nil, err
+	}
+	return regs, nil
+}
+
+func (b *Storage) pushRegistrationKey(username, domain, jid, node string) []byte {
+	return []byte("pushRegistrations:" + userID(username, domain) + ":" + jid + ":" + node)
+}
@@ -0,0 +1,42 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerDB_PushRegistrations(t *testing.T) {
+	t.Parallel()
+
+	h := tUtilBadgerDBSetup()
+	defer tUtilBadgerDBTeardown(h)
+
+	r1 := model.PushRegistration{Username: "ortuman", Domain: "jackal.im", JID: "push.jackal.im", Node: "n1"}
+	r2 := model.PushRegistration{Username: "ortuman", Domain: "jackal.im", JID: "push.jackal.im", Node: "n2"}
+	r3 := model.PushRegistration{Username: "ortuman", Domain: "jackal.im", JID: "push.example.org", Node: "n1"}
+
+	require.Nil(t, h.db.InsertOrUpdatePushRegistration(&r1))
+	require.Nil(t, h.db.InsertOrUpdatePushRegistration(&r2))
+	require.Nil(t, h.db.InsertOrUpdatePushRegistration(&r3))
+
+	regs, err := h.db.FetchPushRegistrations("ortuman", "jackal.im")
+	require.Nil(t, err)
+	sort.Slice(regs, func(i, j int) bool { return regs[i].JID+regs[i].Node < regs[j].JID+regs[j].Node })
+	require.Equal(t, []model.PushRegistration{r3, r1, r2}, regs)
+
+	require.Nil(t, h.db.DeletePushRegistrations("ortuman", "jackal.im", "push.example.org", "n1"))
+	regs, _ = h.db.FetchPushRegistrations("ortuman", "jackal.im")
+	require.Equal(t, 2, len(regs))
+
+	require.Nil(t, h.db.DeletePushRegistrations("ortuman", "jackal.im", "push.jackal.im", ""))
+	regs, _ = h.db.FetchPushRegistrations("ortuman", "jackal.im")
+	require.Equal(t, 0, len(regs))
+}
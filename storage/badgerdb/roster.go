@@ -14,38 +14,38 @@ import (
 // or updates it in case it's been previously inserted.
 func (b *Storage) InsertOrUpdateRosterItem(ri *rostermodel.Item) (rostermodel.Version, error) {
 	if err := b.db.Update(func(tx *badger.Txn) error {
-		return b.insertOrUpdate(ri, b.rosterItemKey(ri.Username, ri.JID), tx)
+		return b.insertOrUpdate(ri, b.rosterItemKey(ri.Username, ri.Domain, ri.JID), tx)
 	}); err != nil {
 		return rostermodel.Version{}, err
 	}
-	return b.updateRosterVer(ri.Username, false)
+	return b.updateRosterVer(ri.Username, ri.Domain, false)
 }
 
 // DeleteRosterItem deletes a roster item entity from storage.
-func (b *Storage) DeleteRosterItem(user, contact string) (rostermodel.Version, error) {
+func (b *Storage) DeleteRosterItem(user, domain, contact string) (rostermodel.Version, error) {
 	if err := b.db.Update(func(tx *badger.Txn) error {
-		return b.delete(b.rosterItemKey(user, contact), tx)
+		return b.delete(b.rosterItemKey(user, domain, contact), tx)
 	}); err != nil {
 		return rostermodel.Version{}, err
 	}
-	return b.updateRosterVer(user, true)
+	return b.updateRosterVer(user, domain, true)
 }
 
 // FetchRosterItems retrieves from storage all roster item entities
 // associated to a given user.
-func (b *Storage) FetchRosterItems(user string) ([]rostermodel.Item, rostermodel.Version, error) {
+func (b *Storage) FetchRosterItems(user, domain string) ([]rostermodel.Item, rostermodel.Version, error) {
 	var ris []rostermodel.Item
-	if err := b.fetchAll(&ris, []byte("rosterItems:"+user)); err != nil {
+	if err := b.fetchAll(&ris, []byte("rosterItems:"+userID(user, domain)+":")); err != nil {
 		return nil, rostermodel.Version{}, err
 	}
-	ver, err := b.fetchRosterVer(user)
+	ver, err := b.fetchRosterVer(user, domain)
 	return ris, ver, err
 }
 
 // FetchRosterItem retrieves from storage a roster item entity.
-func (b *Storage) FetchRosterItem(user, contact string) (*rostermodel.Item, error) {
+func (b *Storage) FetchRosterItem(user, domain, contact string) (*rostermodel.Item, error) {
 	var ri rostermodel.Item
-	err := b.fetch(&ri, b.rosterItemKey(user, contact))
+	err := b.fetch(&ri, b.rosterItemKey(user, domain, contact))
 	switch err {
 	case nil:
 		return &ri, nil
@@ -60,21 +60,21 @@ func (b *Storage) FetchRosterItem(user, contact string) (*rostermodel.Item, erro
 // into storage, or updates it in case it's been previously inserted.
 func (b *Storage) InsertOrUpdateRosterNotification(rn *rostermodel.Notification) error {
 	return b.db.Update(func(tx *badger.Txn) error {
-		return b.insertOrUpdate(rn, b.rosterNotificationKey(rn.Contact, rn.JID), tx)
+		return b.insertOrUpdate(rn, b.rosterNotificationKey(rn.Contact, rn.Domain, rn.JID), tx)
 	})
 }
 
 // DeleteRosterNotification deletes a roster notification entity from storage.
-func (b *Storage) DeleteRosterNotification(contact, jid string) error {
+func (b *Storage) DeleteRosterNotification(contact, domain, jid string) error {
 	return b.db.Update(func(tx *badger.Txn) error {
-		return b.delete(b.rosterNotificationKey(contact, jid), tx)
+		return b.delete(b.rosterNotificationKey(contact, domain, jid), tx)
 	})
 }
 
 // FetchRosterNotification retrieves from storage a roster notification entity.
-func (b *Storage) FetchRosterNotification(contact string, jid string) (*rostermodel.Notification, error) {
+func (b *Storage) FetchRosterNotification(contact, domain, jid string) (*rostermodel.Notification, error) {
 	var rn rostermodel.Notification
-	err := b.fetch(&rn, b.rosterNotificationKey(contact, jid))
+	err := b.fetch(&rn, b.rosterNotificationKey(contact, domain, jid))
 	switch err {
 	case nil:
 		return &rn, nil
@@ -87,16 +87,16 @@ func (b *Storage) FetchRosterNotification(contact string, jid string) (*rostermo
 
 // FetchRosterNotifications retrieves from storage all roster notifications
 // associated to a given user.
-func (b *Storage) FetchRosterNotifications(contact string) ([]rostermodel.Notification, error) {
+func (b *Storage) FetchRosterNotifications(contact, domain string) ([]rostermodel.Notification, error) {
 	var rns []rostermodel.Notification
-	if err := b.fetchAll(&rns, []byte("rosterNotifications:"+contact)); err != nil {
+	if err := b.fetchAll(&rns, []byte("rosterNotifications:"+userID(contact, domain)+":")); err != nil {
 		return nil, err
 	}
 	return rns, nil
 }
 
-func (b *Storage) updateRosterVer(username string, isDeletion bool) (rostermodel.Version, error) {
-	v, err := b.fetchRosterVer(username)
+func (b *Storage) updateRosterVer(username, domain string, isDeletion bool) (rostermodel.Version, error) {
+	v, err := b.fetchRosterVer(username, domain)
 	if err != nil {
 		return rostermodel.Version{}, err
 	}
@@ -105,16 +105,16 @@ func (b *Storage) updateRosterVer(username string, isDeletion bool) (rostermodel
 		v.DeletionVer = v.Ver
 	}
 	if err := b.db.Update(func(tx *badger.Txn) error {
-		return b.insertOrUpdate(&v, b.rosterVersionKey(username), tx)
+		return b.insertOrUpdate(&v, b.rosterVersionKey(username, domain), tx)
 	}); err != nil {
 		return rostermodel.Version{}, err
 	}
 	return v, nil
 }
 
-func (b *Storage) fetchRosterVer(username string) (rostermodel.Version, error) {
+func (b *Storage) fetchRosterVer(username, domain string) (rostermodel.Version, error) {
 	var ver rostermodel.Version
-	err := b.fetch(&ver, b.rosterVersionKey(username))
+	err := b.fetch(&ver, b.rosterVersionKey(username, domain))
 	switch err {
 	case nil, errBadgerDBEntityNotFound:
 		return ver, nil
@@ -123,14 +123,14 @@ func (b *Storage) fetchRosterVer(username string) (rostermodel.Version, error) {
 	}
 }
 
-func (b *Storage) rosterItemKey(user, contact string) []byte {
-	return []byte("rosterItems:" + user + ":" + contact)
+func (b *Storage) rosterItemKey(user, domain, contact string) []byte {
+	return []byte("rosterItems:" + userID(user, domain) + ":" + contact)
 }
 
-func (b *Storage) rosterVersionKey(username string) []byte {
-	return []byte("rosterVersions:" + username)
+func (b *Storage) rosterVersionKey(username, domain string) []byte {
+	return []byte("rosterVersions:" + userID(username, domain))
 }
 
-func (b *Storage) rosterNotificationKey(contact, jid string) []byte {
-	return []byte("rosterNotifications:" + contact + ":" + jid)
+func (b *Storage) rosterNotificationKey(contact, domain, jid string) []byte {
+	return []byte("rosterNotifications:" + userID(contact, domain) + ":" + jid)
 }
@@ -21,11 +21,13 @@ func TestBadgerDB_RosterItems(t *testing.T) {
 
 	ri1 := &rostermodel.Item{
 		Username:     "ortuman",
+		Domain:       "jackal.im",
 		JID:          "juliet",
 		Subscription: "both",
 	}
 	ri2 := &rostermodel.Item{
 		Username:     "ortuman",
+		Domain:       "jackal.im",
 		JID:          "romeo",
 		Subscription: "both",
 	}
@@ -34,24 +36,24 @@ func TestBadgerDB_RosterItems(t *testing.T) {
 	_, err = h.db.InsertOrUpdateRosterItem(ri2)
 	require.NoError(t, err)
 
-	ris, _, err := h.db.FetchRosterItems("ortuman")
+	ris, _, err := h.db.FetchRosterItems("ortuman", "jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, 2, len(ris))
 
-	ris2, _, err := h.db.FetchRosterItems("ortuman2")
+	ris2, _, err := h.db.FetchRosterItems("ortuman2", "jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, 0, len(ris2))
 
-	ri3, err := h.db.FetchRosterItem("ortuman", "juliet")
+	ri3, err := h.db.FetchRosterItem("ortuman", "jackal.im", "juliet")
 	require.Nil(t, err)
 	require.Equal(t, ri1, ri3)
 
-	_, err = h.db.DeleteRosterItem("ortuman", "juliet")
+	_, err = h.db.DeleteRosterItem("ortuman", "jackal.im", "juliet")
 	require.NoError(t, err)
-	_, err = h.db.DeleteRosterItem("ortuman", "romeo")
+	_, err = h.db.DeleteRosterItem("ortuman", "jackal.im", "romeo")
 	require.NoError(t, err)
 
-	ris, _, err = h.db.FetchRosterItems("ortuman")
+	ris, _, err = h.db.FetchRosterItems("ortuman", "jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, 0, len(ris))
 }
@@ -64,34 +66,36 @@ func TestBadgerDB_RosterNotifications(t *testing.T) {
 
 	rn1 := rostermodel.Notification{
 		Contact:  "ortuman",
+		Domain:   "jackal.im",
 		JID:      "juliet@jackal.im",
 		Presence: &xml.Presence{},
 	}
 	rn2 := rostermodel.Notification{
 		Contact:  "ortuman",
+		Domain:   "jackal.im",
 		JID:      "romeo@jackal.im",
 		Presence: &xml.Presence{},
 	}
 	require.NoError(t, h.db.InsertOrUpdateRosterNotification(&rn1))
 	require.NoError(t, h.db.InsertOrUpdateRosterNotification(&rn2))
 
-	rns, err := h.db.FetchRosterNotifications("ortuman")
+	rns, err := h.db.FetchRosterNotifications("ortuman", "jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, 2, len(rns))
 
-	rns2, err := h.db.FetchRosterNotifications("ortuman2")
+	rns2, err := h.db.FetchRosterNotifications("ortuman2", "jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, 0, len(rns2))
 
-	require.NoError(t, h.db.DeleteRosterNotification(rn1.Contact, rn1.JID))
+	require.NoError(t, h.db.DeleteRosterNotification(rn1.Contact, "jackal.im", rn1.JID))
 
-	rns, err = h.db.FetchRosterNotifications("ortuman")
+	rns, err = h.db.FetchRosterNotifications("ortuman", "jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, 1, len(rns))
 
-	require.NoError(t, h.db.DeleteRosterNotification(rn2.Contact, rn2.JID))
+	require.NoError(t, h.db.DeleteRosterNotification(rn2.Contact, "jackal.im", rn2.JID))
 
-	rns, err = h.db.FetchRosterNotifications("ortuman")
+	rns, err = h.db.FetchRosterNotifications("ortuman", "jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, 0, len(rns))
 }
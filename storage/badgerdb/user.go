@@ -6,6 +6,8 @@
 package badgerdb
 
 import (
+	"bytes"
+
 	"github.com/dgraph-io/badger"
 	"github.com/ortuman/jackal/model"
 )
@@ -14,21 +16,24 @@ import (
 // or updates it in case it's been previously inserted.
 func (b *Storage) InsertOrUpdateUser(user *model.User) error {
 	return b.db.Update(func(tx *badger.Txn) error {
-		return b.insertOrUpdate(user, b.userKey(user.Username), tx)
+		return b.insertOrUpdate(user, b.userKey(user.Username, user.Domain), tx)
 	})
 }
 
 // DeleteUser deletes a user entity from storage.
-func (b *Storage) DeleteUser(username string) error {
+func (b *Storage) DeleteUser(username, domain string) error {
 	return b.db.Update(func(tx *badger.Txn) error {
-		return b.delete(b.userKey(username), tx)
+		if err := b.deletePrefix(b.authTokenKey(username, domain, ""), tx); err != nil {
+			return err
+		}
+		return b.delete(b.userKey(username, domain), tx)
 	})
 }
 
 // FetchUser retrieves from storage a user entity.
-func (b *Storage) FetchUser(username string) (*model.User, error) {
+func (b *Storage) FetchUser(username, domain string) (*model.User, error) {
 	var usr model.User
-	err := b.fetch(&usr, b.userKey(username))
+	err := b.fetch(&usr, b.userKey(username, domain))
 	switch err {
 	case nil:
 		return &usr, nil
@@ -39,9 +44,18 @@ func (b *Storage) FetchUser(username string) (*model.User, error) {
 	}
 }
 
+// FetchUsers retrieves from storage all user entities.
+func (b *Storage) FetchUsers() ([]model.User, error) {
+	var users []model.User
+	if err := b.fetchAll(&users, []byte("users:")); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
 // UserExists returns whether or not a user exists within storage.
-func (b *Storage) UserExists(username string) (bool, error) {
-	err := b.fetch(nil, b.userKey(username))
+func (b *Storage) UserExists(username, domain string) (bool, error) {
+	err := b.fetch(nil, b.userKey(username, domain))
 	switch err {
 	case nil:
 		return true, nil
@@ -52,6 +66,22 @@ func (b *Storage) UserExists(username string) (bool, error) {
 	}
 }
 
-func (b *Storage) userKey(username string) []byte {
-	return []byte("users:" + username)
+// CountUsers returns the number of users registered within a domain.
+func (b *Storage) CountUsers(domain string) (int, error) {
+	var count int
+	suffix := []byte("@" + domain)
+	err := b.forEachKey([]byte("users:"), func(k []byte) error {
+		if bytes.HasSuffix(k, suffix) {
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (b *Storage) userKey(username, domain string) []byte {
+	return []byte("users:" + userID(username, domain))
 }
@@ -18,28 +18,49 @@ func TestBadgerDB_User(t *testing.T) {
 	h := tUtilBadgerDBSetup()
 	defer tUtilBadgerDBTeardown(h)
 
-	usr := model.User{Username: "ortuman", Password: "1234"}
+	usr := model.User{Username: "ortuman", Domain: "jackal.im", Password: "1234"}
 
 	err := h.db.InsertOrUpdateUser(&usr)
 	require.Nil(t, err)
 
-	usr2, err := h.db.FetchUser("ortuman")
+	scramUsr := model.User{
+		Username:    "noelia",
+		Domain:      "jackal.im",
+		ScramSHA256: &model.ScramCredentials{Salt: []byte("salt"), Iterations: 4096, StoredKey: []byte("k1"), ServerKey: []byte("k2")},
+	}
+	err = h.db.InsertOrUpdateUser(&scramUsr)
+	require.Nil(t, err)
+
+	users, err := h.db.FetchUsers()
+	require.Nil(t, err)
+	require.Equal(t, 2, len(users))
+	require.Equal(t, "noelia", users[0].Username)
+	require.Equal(t, scramUsr.ScramSHA256, users[0].ScramSHA256)
+	require.Equal(t, "ortuman", users[1].Username)
+
+	count, err := h.db.CountUsers("jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, 2, count)
+	count, _ = h.db.CountUsers("example.org")
+	require.Equal(t, 0, count)
+
+	usr2, err := h.db.FetchUser("ortuman", "jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, "ortuman", usr2.Username)
 	require.Equal(t, "1234", usr2.Password)
 
-	exists, err := h.db.UserExists("ortuman")
+	exists, err := h.db.UserExists("ortuman", "jackal.im")
 	require.Nil(t, err)
 	require.True(t, exists)
 
-	usr3, err := h.db.FetchUser("ortuman2")
+	usr3, err := h.db.FetchUser("ortuman2", "jackal.im")
 	require.Nil(t, usr3)
 	require.Nil(t, err)
 
-	err = h.db.DeleteUser("ortuman")
+	err = h.db.DeleteUser("ortuman", "jackal.im")
 	require.Nil(t, err)
 
-	exists, err = h.db.UserExists("ortuman")
+	exists, err = h.db.UserExists("ortuman", "jackal.im")
 	require.Nil(t, err)
 	require.False(t, exists)
 }
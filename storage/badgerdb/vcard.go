@@ -12,17 +12,17 @@ import (
 
 // InsertOrUpdateVCard inserts a new vCard element into storage,
 // or updates it in case it's been previously inserted.
-func (b *Storage) InsertOrUpdateVCard(vCard xml.XElement, username string) error {
+func (b *Storage) InsertOrUpdateVCard(vCard xml.XElement, username, domain string) error {
 	return b.db.Update(func(tx *badger.Txn) error {
-		return b.insertOrUpdate(vCard, b.vCardKey(username), tx)
+		return b.insertOrUpdate(vCard, b.vCardKey(username, domain), tx)
 	})
 }
 
 // FetchVCard retrieves from storage a vCard element associated
 // to a given user.
-func (b *Storage) FetchVCard(username string) (xml.XElement, error) {
+func (b *Storage) FetchVCard(username, domain string) (xml.XElement, error) {
 	var vCard xml.Element
-	err := b.fetch(&vCard, b.vCardKey(username))
+	err := b.fetch(&vCard, b.vCardKey(username, domain))
 	switch err {
 	case nil:
 		return &vCard, nil
@@ -33,6 +33,6 @@ func (b *Storage) FetchVCard(username string) (xml.XElement, error) {
 	}
 }
 
-func (b *Storage) vCardKey(username string) []byte {
-	return []byte("vCards:" + username)
+func (b *Storage) vCardKey(username, domain string) []byte {
+	return []byte("vCards:" + userID(username, domain))
 }
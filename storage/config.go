@@ -10,10 +10,14 @@ import (
 	"fmt"
 
 	"github.com/ortuman/jackal/storage/badgerdb"
+	"github.com/ortuman/jackal/storage/pgsql"
 	"github.com/ortuman/jackal/storage/sql"
 )
 
-const defaultMySQLPoolSize = 16
+const (
+	defaultMySQLPoolSize = 16
+	defaultPgSQLPoolSize = 16
+)
 
 // StorageType represents a storage manager type.
 type StorageType int
@@ -27,18 +31,23 @@ const (
 
 	// Memory represents a in-memstorage storage type.
 	Memory
+
+	// PgSQL represents a PostgreSQL storage type.
+	PgSQL
 )
 
 // Config represents an storage manager configuration.
 type Config struct {
 	Type     StorageType
 	MySQL    *sql.Config
+	PgSQL    *pgsql.Config
 	BadgerDB *badgerdb.Config
 }
 
 type storageProxyType struct {
 	Type     string           `yaml:"type"`
 	MySQL    *sql.Config      `yaml:"mysql"`
+	PgSQL    *pgsql.Config    `yaml:"pgsql"`
 	BadgerDB *badgerdb.Config `yaml:"badgerdb"`
 }
 
@@ -61,6 +70,21 @@ func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 			c.MySQL.PoolSize = defaultMySQLPoolSize
 		}
 
+	case "pgsql":
+		if p.PgSQL == nil {
+			return errors.New("storage.Config: couldn't read PostgreSQL configuration")
+		}
+		c.Type = PgSQL
+
+		// assign storage defaults
+		c.PgSQL = p.PgSQL
+		if c.PgSQL.PoolSize == 0 {
+			c.PgSQL.PoolSize = defaultPgSQLPoolSize
+		}
+		if len(c.PgSQL.SSLMode) == 0 {
+			c.PgSQL.SSLMode = "disable"
+		}
+
 	case "badgerdb":
 		if p.BadgerDB == nil {
 			return errors.New("storage.Config: couldn't read BadgerDB configuration")
@@ -60,6 +60,47 @@ func TestStorageConfig(t *testing.T) {
 	err = yaml.Unmarshal([]byte(invalidMySQLCfg), &cfg)
 	require.NotNil(t, err)
 
+	pgSQLCfg := `
+  type: pgsql
+  pgsql:
+    host: 127.0.0.1:5432
+    user: jackal
+    password: password
+    database: jackaldb
+    ssl_mode: require
+    pool_size: 8
+`
+
+	err = yaml.Unmarshal([]byte(pgSQLCfg), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, PgSQL, cfg.Type)
+	require.Equal(t, "jackal", cfg.PgSQL.User)
+	require.Equal(t, "password", cfg.PgSQL.Password)
+	require.Equal(t, "jackaldb", cfg.PgSQL.Database)
+	require.Equal(t, "require", cfg.PgSQL.SSLMode)
+	require.Equal(t, 8, cfg.PgSQL.PoolSize)
+
+	pgSQLCfg2 := `
+  type: pgsql
+  pgsql:
+    host: 127.0.0.1:5432
+    user: jackal
+    password: password
+    database: jackaldb
+`
+
+	err = yaml.Unmarshal([]byte(pgSQLCfg2), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, PgSQL, cfg.Type)
+	require.Equal(t, "disable", cfg.PgSQL.SSLMode)
+	require.Equal(t, defaultPgSQLPoolSize, cfg.PgSQL.PoolSize)
+
+	invalidPgSQLCfg := `
+  type: pgsql
+`
+	err = yaml.Unmarshal([]byte(invalidPgSQLCfg), &cfg)
+	require.NotNil(t, err)
+
 	invalidCfg := `
   type: invalid
 `
@@ -0,0 +1,59 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memstorage
+
+import (
+	"github.com/ortuman/jackal/model/mammodel"
+	"github.com/ortuman/jackal/xml"
+)
+
+// InsertArchiveMessage inserts a new message entity into user's archive.
+func (m *Storage) InsertArchiveMessage(message *mammodel.Message) error {
+	return m.inWriteLock(func() error {
+		msg := *message
+		msg.Message = xml.NewElementFromElement(message.Message)
+		key := userKey(message.Username, message.Domain)
+		m.archiveMessages[key] = append(m.archiveMessages[key], msg)
+		return nil
+	})
+}
+
+// FetchArchiveMessages retrieves from storage, in chronological order,
+// a page of user's archived messages satisfying filter constraints.
+func (m *Storage) FetchArchiveMessages(username, domain string, filter *mammodel.Filter, page *mammodel.Page) (*mammodel.ResultSet, error) {
+	var ret *mammodel.ResultSet
+	err := m.inReadLock(func() error {
+		pager := mammodel.NewPager(page)
+		for _, msg := range m.archiveMessages[userKey(username, domain)] {
+			if filter.Matches(&msg) {
+				pager.Add(&msg)
+			}
+		}
+		var err error
+		ret, err = pager.ResultSet()
+		return err
+	})
+	return ret, err
+}
+
+// InsertOrUpdateArchivePrefs inserts a new archiving preferences entity
+// into storage, or updates it in case it's been previously inserted.
+func (m *Storage) InsertOrUpdateArchivePrefs(prefs *mammodel.Prefs) error {
+	return m.inWriteLock(func() error {
+		m.archivePrefs[userKey(prefs.Username, prefs.Domain)] = prefs
+		return nil
+	})
+}
+
+// FetchArchivePrefs retrieves from storage user's archiving preferences.
+func (m *Storage) FetchArchivePrefs(username, domain string) (*mammodel.Prefs, error) {
+	var ret *mammodel.Prefs
+	err := m.inReadLock(func() error {
+		ret = m.archivePrefs[userKey(username, domain)]
+		return nil
+	})
+	return ret, err
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memstorage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/model/mammodel"
+	"github.com/ortuman/jackal/xml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockStorageInsertArchiveMessage(t *testing.T) {
+	now := time.Now()
+	m1 := mammodel.Message{ID: "1", Username: "ortuman", Domain: "jackal.im", JID: "noelia@jackal.im", Message: xml.NewElementName("message"), CreatedAt: now}
+	m2 := mammodel.Message{ID: "2", Username: "ortuman", Domain: "jackal.im", JID: "romeo@jackal.im", Message: xml.NewElementName("message"), CreatedAt: now.Add(time.Second)}
+
+	s := New()
+	s.ActivateMockedError()
+	require.Equal(t, ErrMockedError, s.InsertArchiveMessage(&m1))
+	s.DeactivateMockedError()
+	require.Nil(t, s.InsertArchiveMessage(&m1))
+	require.Nil(t, s.InsertArchiveMessage(&m2))
+
+	s.ActivateMockedError()
+	_, err := s.FetchArchiveMessages("ortuman", "jackal.im", nil, nil)
+	require.Equal(t, ErrMockedError, err)
+	s.DeactivateMockedError()
+
+	rs, err := s.FetchArchiveMessages("ortuman", "jackal.im", nil, nil)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(rs.Messages))
+	require.Equal(t, "1", rs.Messages[0].ID)
+	require.Equal(t, "2", rs.Messages[1].ID)
+
+	rs, _ = s.FetchArchiveMessages("ortuman", "jackal.im", &mammodel.Filter{With: "romeo@jackal.im"}, nil)
+	require.Equal(t, 1, len(rs.Messages))
+	require.Equal(t, "2", rs.Messages[0].ID)
+
+	rs, _ = s.FetchArchiveMessages("ortuman", "jackal.im", nil, &mammodel.Page{After: "1", Max: 1})
+	require.Equal(t, 1, len(rs.Messages))
+	require.Equal(t, "2", rs.Messages[0].ID)
+	require.Equal(t, 1, rs.FirstIndex)
+	require.Equal(t, 2, rs.Count)
+
+	_, err = s.FetchArchiveMessages("ortuman", "jackal.im", nil, &mammodel.Page{Before: "3", Max: 1})
+	require.Equal(t, mammodel.ErrItemNotFound, err)
+}
+
+func TestMockStorageInsertArchivePrefs(t *testing.T) {
+	prefs := mammodel.Prefs{Username: "ortuman", Domain: "jackal.im", Default: mammodel.DefaultRoster}
+
+	s := New()
+	s.ActivateMockedError()
+	require.Equal(t, ErrMockedError, s.InsertOrUpdateArchivePrefs(&prefs))
+	s.DeactivateMockedError()
+	require.Nil(t, s.InsertOrUpdateArchivePrefs(&prefs))
+
+	s.ActivateMockedError()
+	_, err := s.FetchArchivePrefs("ortuman", "jackal.im")
+	require.Equal(t, ErrMockedError, err)
+	s.DeactivateMockedError()
+
+	p, err := s.FetchArchivePrefs("ortuman", "jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, &prefs, p)
+}
@@ -0,0 +1,58 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memstorage
+
+import "github.com/ortuman/jackal/model"
+
+// InsertOrUpdateAuthToken inserts a new auth token entity into storage,
+// or updates it in case it's been previously inserted for the same device.
+func (m *Storage) InsertOrUpdateAuthToken(token *model.AuthToken) error {
+	return m.inWriteLock(func() error {
+		key := userKey(token.Username, token.Domain)
+		tokens := m.authTokens[key]
+		for i, t := range tokens {
+			if t.Device == token.Device {
+				tokens[i] = *token
+				return nil
+			}
+		}
+		m.authTokens[key] = append(tokens, *token)
+		return nil
+	})
+}
+
+// DeleteAuthTokens deletes from storage a user device auth token.
+// In case device is empty every token associated to the user
+// will be deleted.
+func (m *Storage) DeleteAuthTokens(username, domain, device string) error {
+	return m.inWriteLock(func() error {
+		key := userKey(username, domain)
+		var tokens []model.AuthToken
+		for _, t := range m.authTokens[key] {
+			if len(device) == 0 || t.Device == device {
+				continue
+			}
+			tokens = append(tokens, t)
+		}
+		if len(tokens) > 0 {
+			m.authTokens[key] = tokens
+		} else {
+			delete(m.authTokens, key)
+		}
+		return nil
+	})
+}
+
+// FetchAuthTokens retrieves from storage all auth token
+// entities associated to a given user.
+func (m *Storage) FetchAuthTokens(username, domain string) ([]model.AuthToken, error) {
+	var ret []model.AuthToken
+	err := m.inReadLock(func() error {
+		ret = append(ret, m.authTokens[userKey(username, domain)]...)
+		return nil
+	})
+	return ret, err
+}
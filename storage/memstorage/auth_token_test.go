@@ -0,0 +1,49 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memstorage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockStorageAuthTokens(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour)
+	t1 := model.AuthToken{Username: "ortuman", Domain: "jackal.im", Device: "balcony", Hash: "01", ExpiresAt: expiresAt}
+	t2 := model.AuthToken{Username: "ortuman", Domain: "jackal.im", Device: "garden", Hash: "02", ExpiresAt: expiresAt}
+	t3 := model.AuthToken{Username: "ortuman", Domain: "jackal.im", Device: "balcony", Hash: "03", ExpiresAt: expiresAt}
+
+	s := New()
+	s.ActivateMockedError()
+	require.Equal(t, ErrMockedError, s.InsertOrUpdateAuthToken(&t1))
+	_, err := s.FetchAuthTokens("ortuman", "jackal.im")
+	require.Equal(t, ErrMockedError, err)
+	require.Equal(t, ErrMockedError, s.DeleteAuthTokens("ortuman", "jackal.im", ""))
+	s.DeactivateMockedError()
+
+	require.Nil(t, s.InsertOrUpdateAuthToken(&t1))
+	require.Nil(t, s.InsertOrUpdateAuthToken(&t2))
+
+	tokens, _ := s.FetchAuthTokens("ortuman", "jackal.im")
+	require.Equal(t, []model.AuthToken{t1, t2}, tokens)
+
+	// replace device token
+	require.Nil(t, s.InsertOrUpdateAuthToken(&t3))
+	tokens, _ = s.FetchAuthTokens("ortuman", "jackal.im")
+	require.Equal(t, []model.AuthToken{t3, t2}, tokens)
+
+	require.Nil(t, s.DeleteAuthTokens("ortuman", "jackal.im", "garden"))
+	tokens, _ = s.FetchAuthTokens("ortuman", "jackal.im")
+	require.Equal(t, []model.AuthToken{t3}, tokens)
+
+	require.Nil(t, s.InsertOrUpdateAuthToken(&t2))
+	require.Nil(t, s.DeleteAuthTokens("ortuman", "jackal.im", ""))
+	tokens, _ = s.FetchAuthTokens("ortuman", "jackal.im")
+	require.Equal(t, 0, len(tokens))
+}
@@ -12,16 +12,17 @@ import "github.com/ortuman/jackal/model"
 func (m *Storage) InsertBlockListItems(items []model.BlockListItem) error {
 	return m.inWriteLock(func() error {
 		for _, item := range items {
-			bl := m.blockListItems[item.Username]
+			key := userKey(item.Username, item.Domain)
+			bl := m.blockListItems[key]
 			if bl != nil {
 				for _, blItem := range bl {
 					if blItem.JID == item.JID {
 						goto done
 					}
 				}
-				m.blockListItems[item.Username] = append(bl, item)
+				m.blockListItems[key] = append(bl, item)
 			} else {
-				m.blockListItems[item.Username] = []model.BlockListItem{item}
+				m.blockListItems[key] = []model.BlockListItem{item}
 			}
 		done:
 		}
@@ -33,10 +34,11 @@ func (m *Storage) InsertBlockListItems(items []model.BlockListItem) error {
 func (m *Storage) DeleteBlockListItems(items []model.BlockListItem) error {
 	return m.inWriteLock(func() error {
 		for _, itm := range items {
-			bl := m.blockListItems[itm.Username]
+			key := userKey(itm.Username, itm.Domain)
+			bl := m.blockListItems[key]
 			for i, blItem := range bl {
 				if blItem.JID == itm.JID {
-					m.blockListItems[itm.Username] = append(bl[:i], bl[i+1:]...)
+					m.blockListItems[key] = append(bl[:i], bl[i+1:]...)
 					break
 				}
 			}
@@ -47,10 +49,10 @@ func (m *Storage) DeleteBlockListItems(items []model.BlockListItem) error {
 
 // FetchBlockListItems retrieves from storage all block list item entities
 // associated to a given user.
-func (m *Storage) FetchBlockListItems(username string) ([]model.BlockListItem, error) {
+func (m *Storage) FetchBlockListItems(username, domain string) ([]model.BlockListItem, error) {
 	var ret []model.BlockListItem
 	err := m.inReadLock(func() error {
-		ret = m.blockListItems[username]
+		ret = m.blockListItems[userKey(username, domain)]
 		return nil
 	})
 	return ret, err
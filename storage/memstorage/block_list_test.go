@@ -14,9 +14,9 @@ import (
 
 func TestMockStorageInsertOrUpdateBlockListItems(t *testing.T) {
 	items := []model.BlockListItem{
-		{"ortuman", "user@jackal.im"},
-		{"ortuman", "romeo@jackal.im"},
-		{"ortuman", "juliet@jackal.im"},
+		{"ortuman", "jackal.im", "user@jackal.im"},
+		{"ortuman", "jackal.im", "romeo@jackal.im"},
+		{"ortuman", "jackal.im", "juliet@jackal.im"},
 	}
 	s := New()
 	s.ActivateMockedError()
@@ -26,32 +26,32 @@ func TestMockStorageInsertOrUpdateBlockListItems(t *testing.T) {
 	s.InsertBlockListItems(items)
 
 	s.ActivateMockedError()
-	_, err := s.FetchBlockListItems("ortuman")
+	_, err := s.FetchBlockListItems("ortuman", "jackal.im")
 	require.Equal(t, ErrMockedError, err)
 	s.DeactivateMockedError()
 
-	sItems, _ := s.FetchBlockListItems("ortuman")
+	sItems, _ := s.FetchBlockListItems("ortuman", "jackal.im")
 	require.Equal(t, items, sItems)
 }
 
 func TestMockStorageDeleteBlockListItems(t *testing.T) {
 	items := []model.BlockListItem{
-		{"ortuman", "user@jackal.im"},
-		{"ortuman", "romeo@jackal.im"},
-		{"ortuman", "juliet@jackal.im"},
+		{"ortuman", "jackal.im", "user@jackal.im"},
+		{"ortuman", "jackal.im", "romeo@jackal.im"},
+		{"ortuman", "jackal.im", "juliet@jackal.im"},
 	}
 	s := New()
 	s.InsertBlockListItems(items)
 
-	delItems := []model.BlockListItem{{"ortuman", "romeo@jackal.im"}}
+	delItems := []model.BlockListItem{{"ortuman", "jackal.im", "romeo@jackal.im"}}
 	s.ActivateMockedError()
 	require.Equal(t, ErrMockedError, s.DeleteBlockListItems(delItems))
 	s.DeactivateMockedError()
 
 	s.DeleteBlockListItems(delItems)
-	sItems, _ := s.FetchBlockListItems("ortuman")
+	sItems, _ := s.FetchBlockListItems("ortuman", "jackal.im")
 	require.Equal(t, []model.BlockListItem{
-		{"ortuman", "user@jackal.im"},
-		{"ortuman", "juliet@jackal.im"},
+		{"ortuman", "jackal.im", "user@jackal.im"},
+		{"ortuman", "jackal.im", "juliet@jackal.im"},
 	}, sItems)
 }
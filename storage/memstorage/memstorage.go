@@ -11,6 +11,10 @@ import (
 	"sync/atomic"
 
 	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/model/mammodel"
+	"github.com/ortuman/jackal/model/mucmodel"
+	"github.com/ortuman/jackal/model/privacymodel"
+	"github.com/ortuman/jackal/model/pubsubmodel"
 	"github.com/ortuman/jackal/model/rostermodel"
 	"github.com/ortuman/jackal/xml"
 )
@@ -31,6 +35,14 @@ type Storage struct {
 	privateXML          map[string][]xml.XElement
 	offlineMessages     map[string][]xml.XElement
 	blockListItems      map[string][]model.BlockListItem
+	rooms               map[string]*mucmodel.Room
+	archiveMessages     map[string][]mammodel.Message
+	archivePrefs        map[string]*mammodel.Prefs
+	pubSubNodes         map[string]*pubsubmodel.Node
+	pubSubItems         map[string][]pubsubmodel.Item
+	pushRegistrations   map[string][]model.PushRegistration
+	privacyLists        map[string][]privacymodel.List
+	authTokens          map[string][]model.AuthToken
 }
 
 // New returns a new in memory storage instance.
@@ -44,6 +56,14 @@ func New() *Storage {
 		privateXML:          make(map[string][]xml.XElement),
 		offlineMessages:     make(map[string][]xml.XElement),
 		blockListItems:      make(map[string][]model.BlockListItem),
+		rooms:               make(map[string]*mucmodel.Room),
+		archiveMessages:     make(map[string][]mammodel.Message),
+		archivePrefs:        make(map[string]*mammodel.Prefs),
+		pubSubNodes:         make(map[string]*pubsubmodel.Node),
+		pubSubItems:         make(map[string][]pubsubmodel.Item),
+		pushRegistrations:   make(map[string][]model.PushRegistration),
+		privacyLists:        make(map[string][]privacymodel.List),
+		authTokens:          make(map[string][]model.AuthToken),
 	}
 }
 
@@ -61,6 +81,13 @@ func (m *Storage) DeactivateMockedError() {
 	atomic.StoreUint32(&m.mockErr, 0)
 }
 
+// MigrateDomain associates every stored entity not yet bound to a domain
+// to the given one. In memory storage is never persisted, so there is
+// nothing to migrate.
+func (m *Storage) MigrateDomain(_ string) (int, error) {
+	return 0, nil
+}
+
 func (m *Storage) inWriteLock(f func() error) error {
 	if atomic.LoadUint32(&m.mockErr) == 1 {
 		return ErrMockedError
@@ -80,3 +107,8 @@ func (m *Storage) inReadLock(f func() error) error {
 	m.mu.RUnlock()
 	return err
 }
+
+// userKey returns the key used to index a user entity.
+func userKey(username, domain string) string {
+	return username + "@" + domain
+}
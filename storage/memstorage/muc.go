@@ -0,0 +1,51 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memstorage
+
+import (
+	"github.com/ortuman/jackal/model/mucmodel"
+)
+
+// InsertOrUpdateRoom inserts a new room entity into storage,
+// or updates it in case it's been previously inserted.
+func (m *Storage) InsertOrUpdateRoom(room *mucmodel.Room) error {
+	return m.inWriteLock(func() error {
+		m.rooms[room.JID] = room
+		return nil
+	})
+}
+
+// DeleteRoom deletes a room entity from storage.
+func (m *Storage) DeleteRoom(roomJID string) error {
+	return m.inWriteLock(func() error {
+		delete(m.rooms, roomJID)
+		return nil
+	})
+}
+
+// FetchRoom retrieves from storage a room entity.
+func (m *Storage) FetchRoom(roomJID string) (*mucmodel.Room, error) {
+	var ret *mucmodel.Room
+	err := m.inReadLock(func() error {
+		ret = m.rooms[roomJID]
+		return nil
+	})
+	return ret, err
+}
+
+// FetchRooms retrieves from storage all rooms associated to a MUC service domain.
+func (m *Storage) FetchRooms(service string) ([]mucmodel.Room, error) {
+	var ret []mucmodel.Room
+	err := m.inReadLock(func() error {
+		for _, room := range m.rooms {
+			if room.RoomJID().Domain() == service {
+				ret = append(ret, *room)
+			}
+		}
+		return nil
+	})
+	return ret, err
+}
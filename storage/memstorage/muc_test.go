@@ -0,0 +1,60 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memstorage
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/model/mucmodel"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockStorageInsertOrUpdateRoom(t *testing.T) {
+	r := mucmodel.Room{JID: "lobby@conference.jackal.im", Name: "Lobby"}
+	s := New()
+	s.ActivateMockedError()
+	require.Equal(t, ErrMockedError, s.InsertOrUpdateRoom(&r))
+	s.DeactivateMockedError()
+	require.Nil(t, s.InsertOrUpdateRoom(&r))
+
+	s.ActivateMockedError()
+	_, err := s.FetchRoom("lobby@conference.jackal.im")
+	require.Equal(t, ErrMockedError, err)
+	s.DeactivateMockedError()
+
+	r2, err := s.FetchRoom("lobby@conference.jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, &r, r2)
+}
+
+func TestMockStorageFetchRooms(t *testing.T) {
+	s := New()
+	s.InsertOrUpdateRoom(&mucmodel.Room{JID: "lobby@conference.jackal.im"})
+	s.InsertOrUpdateRoom(&mucmodel.Room{JID: "lobby@conference.example.org"})
+
+	s.ActivateMockedError()
+	_, err := s.FetchRooms("conference.jackal.im")
+	require.Equal(t, ErrMockedError, err)
+	s.DeactivateMockedError()
+
+	rooms, err := s.FetchRooms("conference.jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(rooms))
+	require.Equal(t, "lobby@conference.jackal.im", rooms[0].JID)
+}
+
+func TestMockStorageDeleteRoom(t *testing.T) {
+	s := New()
+	s.InsertOrUpdateRoom(&mucmodel.Room{JID: "lobby@conference.jackal.im"})
+
+	s.ActivateMockedError()
+	require.Equal(t, ErrMockedError, s.DeleteRoom("lobby@conference.jackal.im"))
+	s.DeactivateMockedError()
+	require.Nil(t, s.DeleteRoom("lobby@conference.jackal.im"))
+
+	r, _ := s.FetchRoom("lobby@conference.jackal.im")
+	require.Nil(t, r)
+}
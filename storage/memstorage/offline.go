@@ -9,39 +9,40 @@ import "github.com/ortuman/jackal/xml"
 
 // InsertOfflineMessage inserts a new message element into
 // user's offline queue.
-func (m *Storage) InsertOfflineMessage(message xml.XElement, username string) error {
+func (m *Storage) InsertOfflineMessage(message xml.XElement, username, domain string) error {
 	return m.inWriteLock(func() error {
-		msgs := m.offlineMessages[username]
+		key := userKey(username, domain)
+		msgs := m.offlineMessages[key]
 		msgs = append(msgs, xml.NewElementFromElement(message))
-		m.offlineMessages[username] = msgs
+		m.offlineMessages[key] = msgs
 		return nil
 	})
 }
 
 // CountOfflineMessages returns current length of user's offline queue.
-func (m *Storage) CountOfflineMessages(username string) (int, error) {
+func (m *Storage) CountOfflineMessages(username, domain string) (int, error) {
 	var ret int
 	err := m.inReadLock(func() error {
-		ret = len(m.offlineMessages[username])
+		ret = len(m.offlineMessages[userKey(username, domain)])
 		return nil
 	})
 	return ret, err
 }
 
 // FetchOfflineMessages retrieves from storage current user offline queue.
-func (m *Storage) FetchOfflineMessages(username string) ([]xml.XElement, error) {
+func (m *Storage) FetchOfflineMessages(username, domain string) ([]xml.XElement, error) {
 	var ret []xml.XElement
 	err := m.inReadLock(func() error {
-		ret = m.offlineMessages[username]
+		ret = m.offlineMessages[userKey(username, domain)]
 		return nil
 	})
 	return ret, err
 }
 
 // DeleteOfflineMessages clears a user offline queue.
-func (m *Storage) DeleteOfflineMessages(username string) error {
+func (m *Storage) DeleteOfflineMessages(username, domain string) error {
 	return m.inWriteLock(func() error {
-		delete(m.offlineMessages, username)
+		delete(m.offlineMessages, userKey(username, domain))
 		return nil
 	})
 }
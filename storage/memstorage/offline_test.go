@@ -23,9 +23,9 @@ func TestMockStorageInsertOfflineMessage(t *testing.T) {
 
 	s := New()
 	s.ActivateMockedError()
-	require.Equal(t, ErrMockedError, s.InsertOfflineMessage(m, "ortuman"))
+	require.Equal(t, ErrMockedError, s.InsertOfflineMessage(m, "ortuman", "jackal.im"))
 	s.DeactivateMockedError()
-	require.Nil(t, s.InsertOfflineMessage(m, "ortuman"))
+	require.Nil(t, s.InsertOfflineMessage(m, "ortuman", "jackal.im"))
 }
 
 func TestMockStorageCountOfflineMessages(t *testing.T) {
@@ -36,13 +36,13 @@ func TestMockStorageCountOfflineMessages(t *testing.T) {
 	m, _ := xml.NewMessageFromElement(message, j, j)
 
 	s := New()
-	s.InsertOfflineMessage(m, "ortuman")
+	s.InsertOfflineMessage(m, "ortuman", "jackal.im")
 
 	s.ActivateMockedError()
-	_, err := s.CountOfflineMessages("ortuman")
+	_, err := s.CountOfflineMessages("ortuman", "jackal.im")
 	require.Equal(t, ErrMockedError, err)
 	s.DeactivateMockedError()
-	cnt, _ := s.CountOfflineMessages("ortuman")
+	cnt, _ := s.CountOfflineMessages("ortuman", "jackal.im")
 	require.Equal(t, 1, cnt)
 }
 
@@ -54,13 +54,13 @@ func TestMockStorageFetchOfflineMessages(t *testing.T) {
 	m, _ := xml.NewMessageFromElement(message, j, j)
 
 	s := New()
-	s.InsertOfflineMessage(m, "ortuman")
+	s.InsertOfflineMessage(m, "ortuman", "jackal.im")
 
 	s.ActivateMockedError()
-	_, err := s.FetchOfflineMessages("ortuman")
+	_, err := s.FetchOfflineMessages("ortuman", "jackal.im")
 	require.Equal(t, ErrMockedError, err)
 	s.DeactivateMockedError()
-	elems, _ := s.FetchOfflineMessages("ortuman")
+	elems, _ := s.FetchOfflineMessages("ortuman", "jackal.im")
 	require.Equal(t, 1, len(elems))
 }
 
@@ -72,13 +72,13 @@ func TestMockStorageDeleteOfflineMessages(t *testing.T) {
 	m, _ := xml.NewMessageFromElement(message, j, j)
 
 	s := New()
-	s.InsertOfflineMessage(m, "ortuman")
+	s.InsertOfflineMessage(m, "ortuman", "jackal.im")
 
 	s.ActivateMockedError()
-	require.Equal(t, ErrMockedError, s.DeleteOfflineMessages("ortuman"))
+	require.Equal(t, ErrMockedError, s.DeleteOfflineMessages("ortuman", "jackal.im"))
 	s.DeactivateMockedError()
-	require.Nil(t, s.DeleteOfflineMessages("ortuman"))
+	require.Nil(t, s.DeleteOfflineMessages("ortuman", "jackal.im"))
 
-	elems, _ := s.FetchOfflineMessages("ortuman")
+	elems, _ := s.FetchOfflineMessages("ortuman", "jackal.im")
 	require.Equal(t, 0, len(elems))
 }
@@ -0,0 +1,64 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memstorage
+
+import "github.com/ortuman/jackal/model/privacymodel"
+
+// InsertOrUpdatePrivacyList inserts a new privacy list entity into storage,
+// or updates it in case it's been previously inserted.
+func (m *Storage) InsertOrUpdatePrivacyList(list *privacymodel.List) error {
+	return m.inWriteLock(func() error {
+		key := userKey(list.Username, list.Domain)
+		lists := m.privacyLists[key]
+		for i, l := range lists {
+			if l.Name == list.Name {
+				lists[i] = *list
+				return nil
+			}
+		}
+		m.privacyLists[key] = append(lists, *list)
+		return nil
+	})
+}
+
+// DeletePrivacyList deletes a privacy list entity from storage.
+func (m *Storage) DeletePrivacyList(username, domain, name string) error {
+	return m.inWriteLock(func() error {
+		key := userKey(username, domain)
+		lists := m.privacyLists[key]
+		for i, l := range lists {
+			if l.Name == name {
+				m.privacyLists[key] = append(lists[:i], lists[i+1:]...)
+				break
+			}
+		}
+		return nil
+	})
+}
+
+// FetchPrivacyLists retrieves from storage all privacy list entities
+// associated to a given user.
+func (m *Storage) FetchPrivacyLists(username, domain string) ([]privacymodel.List, error) {
+	var ret []privacymodel.List
+	err := m.inReadLock(func() error {
+		ret = append(ret, m.privacyLists[userKey(username, domain)]...)
+		return nil
+	})
+	return ret, err
+}
+
+// SetDefaultPrivacyList marks a user privacy list as the default one,
+// unmarking any previous one. An empty name just declines
+// the use of a default list.
+func (m *Storage) SetDefaultPrivacyList(username, domain, name string) error {
+	return m.inWriteLock(func() error {
+		lists := m.privacyLists[userKey(username, domain)]
+		for i := range lists {
+			lists[i].Default = lists[i].Name == name
+		}
+		return nil
+	})
+}
@@ -0,0 +1,55 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memstorage
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/model/privacymodel"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockStoragePrivacyLists(t *testing.T) {
+	l1 := privacymodel.List{Username: "ortuman", Domain: "jackal.im", Name: "public", Items: []privacymodel.Item{
+		{Type: privacymodel.JIDType, Value: "romeo@jackal.im", Action: privacymodel.Deny, Order: 1},
+	}}
+	l2 := privacymodel.List{Username: "ortuman", Domain: "jackal.im", Name: "private", Items: []privacymodel.Item{
+		{Action: privacymodel.Deny, Order: 1},
+	}}
+	s := New()
+	s.ActivateMockedError()
+	require.Equal(t, ErrMockedError, s.InsertOrUpdatePrivacyList(&l1))
+	_, err := s.FetchPrivacyLists("ortuman", "jackal.im")
+	require.Equal(t, ErrMockedError, err)
+	require.Equal(t, ErrMockedError, s.SetDefaultPrivacyList("ortuman", "jackal.im", "public"))
+	require.Equal(t, ErrMockedError, s.DeletePrivacyList("ortuman", "jackal.im", "public"))
+	s.DeactivateMockedError()
+
+	require.Nil(t, s.InsertOrUpdatePrivacyList(&l1))
+	require.Nil(t, s.InsertOrUpdatePrivacyList(&l2))
+
+	lists, _ := s.FetchPrivacyLists("ortuman", "jackal.im")
+	require.Equal(t, []privacymodel.List{l1, l2}, lists)
+
+	l1.Items = append(l1.Items, privacymodel.Item{Action: privacymodel.Allow, Order: 2})
+	require.Nil(t, s.InsertOrUpdatePrivacyList(&l1))
+
+	require.Nil(t, s.SetDefaultPrivacyList("ortuman", "jackal.im", "private"))
+	lists, _ = s.FetchPrivacyLists("ortuman", "jackal.im")
+	require.Equal(t, 2, len(lists))
+	require.Equal(t, 2, len(lists[0].Items))
+	require.False(t, lists[0].Default)
+	require.True(t, lists[1].Default)
+
+	require.Nil(t, s.SetDefaultPrivacyList("ortuman", "jackal.im", ""))
+	lists, _ = s.FetchPrivacyLists("ortuman", "jackal.im")
+	require.False(t, lists[1].Default)
+
+	require.Nil(t, s.DeletePrivacyList("ortuman", "jackal.im", "public"))
+	lists, _ = s.FetchPrivacyLists("ortuman", "jackal.im")
+	require.Equal(t, 1, len(lists))
+	require.Equal(t, "private", lists[0].Name)
+}
@@ -17,10 +17,10 @@ func TestMockStorageInsertPrivateXML(t *testing.T) {
 
 	s := New()
 	s.ActivateMockedError()
-	err := s.InsertOrUpdatePrivateXML([]xml.XElement{private}, "exodus:ns", "ortuman")
+	err := s.InsertOrUpdatePrivateXML([]xml.XElement{private}, "exodus:ns", "ortuman", "jackal.im")
 	require.Equal(t, ErrMockedError, err)
 	s.DeactivateMockedError()
-	err = s.InsertOrUpdatePrivateXML([]xml.XElement{private}, "exodus:ns", "ortuman")
+	err = s.InsertOrUpdatePrivateXML([]xml.XElement{private}, "exodus:ns", "ortuman", "jackal.im")
 	require.Nil(t, err)
 }
 
@@ -28,12 +28,12 @@ func TestMockStorageFetchPrivateXML(t *testing.T) {
 	private := xml.NewElementNamespace("exodus", "exodus:ns")
 
 	s := New()
-	s.InsertOrUpdatePrivateXML([]xml.XElement{private}, "exodus:ns", "ortuman")
+	s.InsertOrUpdatePrivateXML([]xml.XElement{private}, "exodus:ns", "ortuman", "jackal.im")
 
 	s.ActivateMockedError()
-	_, err := s.FetchPrivateXML("exodus:ns", "ortuman")
+	_, err := s.FetchPrivateXML("exodus:ns", "ortuman", "jackal.im")
 	require.Equal(t, ErrMockedError, err)
 	s.DeactivateMockedError()
-	elems, _ := s.FetchPrivateXML("exodus:ns", "ortuman")
+	elems, _ := s.FetchPrivateXML("exodus:ns", "ortuman", "jackal.im")
 	require.Equal(t, 1, len(elems))
 }
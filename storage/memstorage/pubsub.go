@@ -0,0 +1,127 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memstorage
+
+import (
+	"github.com/ortuman/jackal/model/pubsubmodel"
+)
+
+// InsertOrUpdatePubSubNode inserts a new pubsub node entity into storage,
+// or updates it in case it's been previously inserted.
+func (m *Storage) InsertOrUpdatePubSubNode(node *pubsubmodel.Node) error {
+	return m.inWriteLock(func() error {
+		m.pubSubNodes[pubSubNodeKey(node.Host, node.Name)] = copyPubSubNode(node)
+		return nil
+	})
+}
+
+// DeletePubSubNode deletes a pubsub node entity from storage
+// along with all its published items.
+func (m *Storage) DeletePubSubNode(host, name string) error {
+	return m.inWriteLock(func() error {
+		k := pubSubNodeKey(host, name)
+		delete(m.pubSubNodes, k)
+		delete(m.pubSubItems, k)
+		return nil
+	})
+}
+
+// FetchPubSubNode retrieves from storage a pubsub node entity.
+func (m *Storage) FetchPubSubNode(host, name string) (*pubsubmodel.Node, error) {
+	var ret *pubsubmodel.Node
+	err := m.inReadLock(func() error {
+		if node := m.pubSubNodes[pubSubNodeKey(host, name)]; node != nil {
+			ret = copyPubSubNode(node)
+		}
+		return nil
+	})
+	return ret, err
+}
+
+// FetchPubSubNodes retrieves from storage all pubsub node entities
+// associated to a given host.
+func (m *Storage) FetchPubSubNodes(host string) ([]pubsubmodel.Node, error) {
+	var ret []pubsubmodel.Node
+	err := m.inReadLock(func() error {
+		for _, node := range m.pubSubNodes {
+			if node.Host == host {
+				ret = append(ret, *copyPubSubNode(node))
+			}
+		}
+		return nil
+	})
+	return ret, err
+}
+
+// InsertOrUpdatePubSubItem inserts a new item entity into a pubsub node,
+// or updates it in case it's been previously inserted.
+// Oldest node items will be discarded beyond maxItems (if greater than zero).
+func (m *Storage) InsertOrUpdatePubSubItem(item *pubsubmodel.Item, host, name string, maxItems int) error {
+	return m.inWriteLock(func() error {
+		k := pubSubNodeKey(host, name)
+		items := m.pubSubItems[k]
+		for i, it := range items {
+			if it.ID == item.ID {
+				items = append(items[:i], items[i+1:]...)
+				break
+			}
+		}
+		items = append(items, *item)
+		if maxItems > 0 && len(items) > maxItems {
+			items = items[len(items)-maxItems:]
+		}
+		m.pubSubItems[k] = items
+		return nil
+	})
+}
+
+// DeletePubSubItem deletes a pubsub node item entity from storage.
+func (m *Storage) DeletePubSubItem(itemID, host, name string) error {
+	return m.inWriteLock(func() error {
+		k := pubSubNodeKey(host, name)
+		items := m.pubSubItems[k]
+		for i, it := range items {
+			if it.ID == itemID {
+				m.pubSubItems[k] = append(items[:i], items[i+1:]...)
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+// FetchPubSubItems retrieves from storage, in chronological order,
+// all items published into a pubsub node.
+func (m *Storage) FetchPubSubItems(host, name string) ([]pubsubmodel.Item, error) {
+	var ret []pubsubmodel.Item
+	err := m.inReadLock(func() error {
+		items := m.pubSubItems[pubSubNodeKey(host, name)]
+		if len(items) > 0 {
+			ret = make([]pubsubmodel.Item, len(items))
+			copy(ret, items)
+		}
+		return nil
+	})
+	return ret, err
+}
+
+func pubSubNodeKey(host, name string) string {
+	return host + "/" + name
+}
+
+func copyPubSubNode(node *pubsubmodel.Node) *pubsubmodel.Node {
+	n := *node
+	n.Options.RosterGroupsAllowed = append([]string(nil), node.Options.RosterGroupsAllowed...)
+	n.Affiliations = nil
+	for j, aff := range node.Affiliations {
+		n.SetAffiliation(j, aff)
+	}
+	n.Subscriptions = nil
+	for j, subID := range node.Subscriptions {
+		n.SetSubscription(j, subID)
+	}
+	return &n
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memstorage
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/model/pubsubmodel"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockStorageInsertOrUpdatePubSubNode(t *testing.T) {
+	n := pubsubmodel.Node{Host: "pubsub.jackal.im", Name: "princely_musings", Options: pubsubmodel.DefaultOptions()}
+	n.SetAffiliation("ortuman@jackal.im", pubsubmodel.AffiliationOwner)
+
+	s := New()
+	s.ActivateMockedError()
+	require.Equal(t, ErrMockedError, s.InsertOrUpdatePubSubNode(&n))
+	s.DeactivateMockedError()
+	require.Nil(t, s.InsertOrUpdatePubSubNode(&n))
+
+	s.ActivateMockedError()
+	_, err := s.FetchPubSubNode("pubsub.jackal.im", "princely_musings")
+	require.Equal(t, ErrMockedError, err)
+	s.DeactivateMockedError()
+
+	n2, err := s.FetchPubSubNode("pubsub.jackal.im", "princely_musings")
+	require.Nil(t, err)
+	require.Equal(t, &n, n2)
+
+	// stored entity must not be aliased
+	n2.SetAffiliation("noelia@jackal.im", pubsubmodel.AffiliationMember)
+	n3, _ := s.FetchPubSubNode("pubsub.jackal.im", "princely_musings")
+	require.Equal(t, pubsubmodel.AffiliationNone, n3.Affiliation("noelia@jackal.im"))
+
+	n4, err := s.FetchPubSubNode("pubsub.jackal.im", "unknown")
+	require.Nil(t, err)
+	require.Nil(t, n4)
+}
+
+func TestMockStorageFetchPubSubNodes(t *testing.T) {
+	s := New()
+	s.InsertOrUpdatePubSubNode(&pubsubmodel.Node{Host: "pubsub.jackal.im", Name: "a"})
+	s.InsertOrUpdatePubSubNode(&pubsubmodel.Node{Host: "pubsub.jackal.im", Name: "b"})
+	s.InsertOrUpdatePubSubNode(&pubsubmodel.Node{Host: "pubsub.example.org", Name: "a"})
+
+	s.ActivateMockedError()
+	_, err := s.FetchPubSubNodes("pubsub.jackal.im")
+	require.Equal(t, ErrMockedError, err)
+	s.DeactivateMockedError()
+
+	nodes, err := s.FetchPubSubNodes("pubsub.jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, 2, len(nodes))
+}
+
+func TestMockStoragePubSubItems(t *testing.T) {
+	s := New()
+	s.InsertOrUpdatePubSubNode(&pubsubmodel.Node{Host: "pubsub.jackal.im", Name: "princely_musings"})
+
+	s.ActivateMockedError()
+	require.Equal(t, ErrMockedError, s.InsertOrUpdatePubSubItem(&pubsubmodel.Item{ID: "1"}, "pubsub.jackal.im", "princely_musings", 2))
+	s.DeactivateMockedError()
+
+	require.Nil(t, s.InsertOrUpdatePubSubItem(&pubsubmodel.Item{ID: "1"}, "pubsub.jackal.im", "princely_musings", 2))
+	require.Nil(t, s.InsertOrUpdatePubSubItem(&pubsubmodel.Item{ID: "2"}, "pubsub.jackal.im", "princely_musings", 2))
+	require.Nil(t, s.InsertOrUpdatePubSubItem(&pubsubmodel.Item{ID: "1", Publisher: "ortuman@jackal.im"}, "pubsub.jackal.im", "princely_musings", 2))
+	require.Nil(t, s.InsertOrUpdatePubSubItem(&pubsubmodel.Item{ID: "3"}, "pubsub.jackal.im", "princely_musings", 2))
+
+	items, err := s.FetchPubSubItems("pubsub.jackal.im", "princely_musings")
+	require.Nil(t, err)
+	require.Equal(t, 2, len(items))
+	require.Equal(t, "1", items[0].ID)
+	require.Equal(t, "ortuman@jackal.im", items[0].Publisher)
+	require.Equal(t, "3", items[1].ID)
+
+	require.Nil(t, s.DeletePubSubItem("1", "pubsub.jackal.im", "princely_musings"))
+	items, _ = s.FetchPubSubItems("pubsub.jackal.im", "princely_musings")
+	require.Equal(t, 1, len(items))
+
+	s.ActivateMockedError()
+	require.Equal(t, ErrMockedError, s.DeletePubSubNode("pubsub.jackal.im", "princely_musings"))
+	s.DeactivateMockedError()
+
+	require.Nil(t, s.DeletePubSubNode("pubsub.jackal.im", "princely_musings"))
+	items, _ = s.FetchPubSubItems("pubsub.jackal.im", "princely_musings")
+	require.Equal(t, 0, len(items))
+	n, _ := s.FetchPubSubNode("pubsub.jackal.im", "princely_musings")
+	require.Nil(t, n)
+}
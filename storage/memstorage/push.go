@@ -0,0 +1,58 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memstorage
+
+import "github.com/ortuman/jackal/model"
+
+// InsertOrUpdatePushRegistration inserts a new push registration entity
+// into storage, or updates it in case it's been previously inserted.
+func (m *Storage) InsertOrUpdatePushRegistration(reg *model.PushRegistration) error {
+	return m.inWriteLock(func() error {
+		key := userKey(reg.Username, reg.Domain)
+		regs := m.pushRegistrations[key]
+		for i, r := range regs {
+			if r.JID == reg.JID && r.Node == reg.Node {
+				regs[i] = *reg
+				return nil
+			}
+		}
+		m.pushRegistrations[key] = append(regs, *reg)
+		return nil
+	})
+}
+
+// DeletePushRegistrations deletes from storage a user push registration
+// entity. In case node is empty every registration associated to
+// the app server jid will be deleted.
+func (m *Storage) DeletePushRegistrations(username, domain, jid, node string) error {
+	return m.inWriteLock(func() error {
+		key := userKey(username, domain)
+		var regs []model.PushRegistration
+		for _, r := range m.pushRegistrations[key] {
+			if r.JID == jid && (len(node) == 0 || r.Node == node) {
+				continue
+			}
+			regs = append(regs, r)
+		}
+		if len(regs) > 0 {
+			m.pushRegistrations[key] = regs
+		} else {
+			delete(m.pushRegistrations, key)
+		}
+		return nil
+	})
+}
+
+// FetchPushRegistrations retrieves from storage all push registration
+// entities associated to a given user.
+func (m *Storage) FetchPushRegistrations(username, domain string) ([]model.PushRegistration, error) {
+	var ret []model.PushRegistration
+	err := m.inReadLock(func() error {
+		ret = append(ret, m.pushRegistrations[userKey(username, domain)]...)
+		return nil
+	})
+	return ret, err
+}
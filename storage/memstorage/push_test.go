@@ -0,0 +1,47 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memstorage
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockStoragePushRegistrations(t *testing.T) {
+	r1 := model.PushRegistration{Username: "ortuman", Domain: "jackal.im", JID: "push.jackal.im", Node: "n1"}
+	r2 := model.PushRegistration{Username: "ortuman", Domain: "jackal.im", JID: "push.jackal.im", Node: "n2"}
+	r3 := model.PushRegistration{Username: "ortuman", Domain: "jackal.im", JID: "push.example.org", Node: "n1"}
+
+	s := New()
+	s.ActivateMockedError()
+	require.Equal(t, ErrMockedError, s.InsertOrUpdatePushRegistration(&r1))
+	_, err := s.FetchPushRegistrations("ortuman", "jackal.im")
+	require.Equal(t, ErrMockedError, err)
+	require.Equal(t, ErrMockedError, s.DeletePushRegistrations("ortuman", "jackal.im", "push.jackal.im", ""))
+	s.DeactivateMockedError()
+
+	require.Nil(t, s.InsertOrUpdatePushRegistration(&r1))
+	require.Nil(t, s.InsertOrUpdatePushRegistration(&r2))
+	require.Nil(t, s.InsertOrUpdatePushRegistration(&r3))
+	require.Nil(t, s.InsertOrUpdatePushRegistration(&r1))
+
+	regs, _ := s.FetchPushRegistrations("ortuman", "jackal.im")
+	require.Equal(t, []model.PushRegistration{r1, r2, r3}, regs)
+
+	require.Nil(t, s.DeletePushRegistrations("ortuman", "jackal.im", "push.jackal.im", "n2"))
+	regs, _ = s.FetchPushRegistrations("ortuman", "jackal.im")
+	require.Equal(t, []model.PushRegistration{r1, r3}, regs)
+
+	require.Nil(t, s.DeletePushRegistrations("ortuman", "jackal.im", "push.example.org", ""))
+	regs, _ = s.FetchPushRegistrations("ortuman", "jackal.im")
+	require.Equal(t, []model.PushRegistration{r1}, regs)
+
+	require.Nil(t, s.DeletePushRegistrations("ortuman", "jackal.im", "push.jackal.im", ""))
+	regs, _ = s.FetchPushRegistrations("ortuman", "jackal.im")
+	require.Equal(t, 0, len(regs))
+}
@@ -14,7 +14,8 @@ import (
 func (m *Storage) InsertOrUpdateRosterItem(ri *rostermodel.Item) (rostermodel.Version, error) {
 	var v rostermodel.Version
 	err := m.inWriteLock(func() error {
-		ris := m.rosterItems[ri.Username]
+		key := userKey(ri.Username, ri.Domain)
+		ris := m.rosterItems[key]
 		if ris != nil {
 			for i, r := range ris {
 				if r.JID == ri.JID {
@@ -28,32 +29,33 @@ func (m *Storage) InsertOrUpdateRosterItem(ri *rostermodel.Item) (rostermodel.Ve
 		}
 
 	done:
-		ver := m.rosterVersions[ri.Username]
+		ver := m.rosterVersions[key]
 		ver.Ver++
-		m.rosterVersions[ri.Username] = ver
+		m.rosterVersions[key] = ver
 		ris[len(ris)-1].Ver = ver.Ver
-		m.rosterItems[ri.Username] = ris
+		m.rosterItems[key] = ris
 		return nil
 	})
 	return v, err
 }
 
 // DeleteRosterItem deletes a roster item entity from storage.
-func (m *Storage) DeleteRosterItem(user, contact string) (rostermodel.Version, error) {
+func (m *Storage) DeleteRosterItem(user, domain, contact string) (rostermodel.Version, error) {
 	var v rostermodel.Version
 	err := m.inWriteLock(func() error {
-		ris := m.rosterItems[user]
+		key := userKey(user, domain)
+		ris := m.rosterItems[key]
 		for i, ri := range ris {
 			if ri.JID == contact {
-				m.rosterItems[user] = append(ris[:i], ris[i+1:]...)
+				m.rosterItems[key] = append(ris[:i], ris[i+1:]...)
 				goto done
 			}
 		}
 	done:
-		v = m.rosterVersions[user]
+		v = m.rosterVersions[key]
 		v.Ver++
 		v.DeletionVer = v.Ver
-		m.rosterVersions[user] = v
+		m.rosterVersions[key] = v
 		return nil
 	})
 	return v, err
@@ -61,22 +63,23 @@ func (m *Storage) DeleteRosterItem(user, contact string) (rostermodel.Version, e
 
 // FetchRosterItems retrieves from storage all roster item entities
 // associated to a given user.
-func (m *Storage) FetchRosterItems(user string) ([]rostermodel.Item, rostermodel.Version, error) {
+func (m *Storage) FetchRosterItems(user, domain string) ([]rostermodel.Item, rostermodel.Version, error) {
 	var ris []rostermodel.Item
 	var v rostermodel.Version
 	err := m.inReadLock(func() error {
-		ris = m.rosterItems[user]
-		v = m.rosterVersions[user]
+		key := userKey(user, domain)
+		ris = m.rosterItems[key]
+		v = m.rosterVersions[key]
 		return nil
 	})
 	return ris, v, err
 }
 
 // FetchRosterItem retrieves from storage a roster item entity.
-func (m *Storage) FetchRosterItem(user, contact string) (*rostermodel.Item, error) {
+func (m *Storage) FetchRosterItem(user, domain, contact string) (*rostermodel.Item, error) {
 	var ret *rostermodel.Item
 	err := m.inReadLock(func() error {
-		ris := m.rosterItems[user]
+		ris := m.rosterItems[userKey(user, domain)]
 		for _, ri := range ris {
 			if ri.JID == contact {
 				ret = &ri
@@ -92,7 +95,8 @@ func (m *Storage) FetchRosterItem(user, contact string) (*rostermodel.Item, erro
 // into storage, or updates it in case it's been previously inserted.
 func (m *Storage) InsertOrUpdateRosterNotification(rn *rostermodel.Notification) error {
 	return m.inWriteLock(func() error {
-		rns := m.rosterNotifications[rn.Contact]
+		key := userKey(rn.Contact, rn.Domain)
+		rns := m.rosterNotifications[key]
 		if rns != nil {
 			for i, r := range rns {
 				if r.JID == rn.JID {
@@ -105,18 +109,19 @@ func (m *Storage) InsertOrUpdateRosterNotification(rn *rostermodel.Notification)
 			rns = []rostermodel.Notification{*rn}
 		}
 	done:
-		m.rosterNotifications[rn.Contact] = rns
+		m.rosterNotifications[key] = rns
 		return nil
 	})
 }
 
 // DeleteRosterNotification deletes a roster notification entity from storage.
-func (m *Storage) DeleteRosterNotification(contact, jid string) error {
+func (m *Storage) DeleteRosterNotification(contact, domain, jid string) error {
 	return m.inWriteLock(func() error {
-		rns := m.rosterNotifications[contact]
+		key := userKey(contact, domain)
+		rns := m.rosterNotifications[key]
 		for i, rn := range rns {
 			if rn.JID == jid {
-				m.rosterNotifications[contact] = append(rns[:i], rns[i+1:]...)
+				m.rosterNotifications[key] = append(rns[:i], rns[i+1:]...)
 				return nil
 			}
 		}
@@ -125,10 +130,10 @@ func (m *Storage) DeleteRosterNotification(contact, jid string) error {
 }
 
 // FetchRosterNotification retrieves from storage a roster notification entity.
-func (m *Storage) FetchRosterNotification(contact string, jid string) (*rostermodel.Notification, error) {
+func (m *Storage) FetchRosterNotification(contact, domain, jid string) (*rostermodel.Notification, error) {
 	var ret *rostermodel.Notification
 	err := m.inReadLock(func() error {
-		rns := m.rosterNotifications[contact]
+		rns := m.rosterNotifications[userKey(contact, domain)]
 		for _, rn := range rns {
 			if rn.JID == jid {
 				ret = &rn
@@ -142,10 +147,10 @@ func (m *Storage) FetchRosterNotification(contact string, jid string) (*rostermo
 
 // FetchRosterNotifications retrieves from storage all roster notifications
 // associated to a given user.
-func (m *Storage) FetchRosterNotifications(contact string) ([]rostermodel.Notification, error) {
+func (m *Storage) FetchRosterNotifications(contact, domain string) ([]rostermodel.Notification, error) {
 	var ret []rostermodel.Notification
 	err := m.inReadLock(func() error {
-		ret = m.rosterNotifications[contact]
+		ret = m.rosterNotifications[userKey(contact, domain)]
 		return nil
 	})
 	return ret, err
@@ -16,7 +16,7 @@ import (
 
 func TestMockStorageInsertRosterItem(t *testing.T) {
 	g := []string{"general", "friends"}
-	ri := rostermodel.Item{"user", "contact", "a name", "both", false, 1, g}
+	ri := rostermodel.Item{"user", "jackal.im", "contact", "a name", "both", false, 1, g}
 
 	s := New()
 	s.ActivateMockedError()
@@ -32,20 +32,20 @@ func TestMockStorageInsertRosterItem(t *testing.T) {
 
 func TestMockStorageFetchRosterItem(t *testing.T) {
 	g := []string{"general", "friends"}
-	ri := rostermodel.Item{"user", "contact", "a name", "both", false, 1, g}
+	ri := rostermodel.Item{"user", "jackal.im", "contact", "a name", "both", false, 1, g}
 
 	s := New()
 	s.InsertOrUpdateRosterItem(&ri)
 
 	s.ActivateMockedError()
-	_, err := s.FetchRosterItem("user", "contact")
+	_, err := s.FetchRosterItem("user", "jackal.im", "contact")
 	require.Equal(t, ErrMockedError, err)
 	s.DeactivateMockedError()
 
-	ri3, _ := s.FetchRosterItem("user", "contact2")
+	ri3, _ := s.FetchRosterItem("user", "jackal.im", "contact2")
 	require.Nil(t, ri3)
 
-	ri4, _ := s.FetchRosterItem("user", "contact")
+	ri4, _ := s.FetchRosterItem("user", "jackal.im", "contact")
 	require.NotNil(t, ri4)
 	require.Equal(t, "user", ri4.Username)
 	require.Equal(t, "contact", ri4.JID)
@@ -53,43 +53,44 @@ func TestMockStorageFetchRosterItem(t *testing.T) {
 
 func TestMockStorageFetchRosterItems(t *testing.T) {
 	g := []string{"general", "friends"}
-	ri := rostermodel.Item{"user", "contact", "a name", "both", false, 1, g}
-	ri2 := rostermodel.Item{"user", "contact2", "a name 2", "both", false, 2, g}
+	ri := rostermodel.Item{"user", "jackal.im", "contact", "a name", "both", false, 1, g}
+	ri2 := rostermodel.Item{"user", "jackal.im", "contact2", "a name 2", "both", false, 2, g}
 
 	s := New()
 	s.InsertOrUpdateRosterItem(&ri)
 	s.InsertOrUpdateRosterItem(&ri2)
 
 	s.ActivateMockedError()
-	_, _, err := s.FetchRosterItems("user")
+	_, _, err := s.FetchRosterItems("user", "jackal.im")
 	require.Equal(t, ErrMockedError, err)
 	s.DeactivateMockedError()
-	ris, _, _ := s.FetchRosterItems("user")
+	ris, _, _ := s.FetchRosterItems("user", "jackal.im")
 	require.Equal(t, 2, len(ris))
 }
 
 func TestMockStorageDeleteRosterItem(t *testing.T) {
 	g := []string{"general", "friends"}
-	ri := rostermodel.Item{"user", "contact", "a name", "both", false, 1, g}
+	ri := rostermodel.Item{"user", "jackal.im", "contact", "a name", "both", false, 1, g}
 	s := New()
 	s.InsertOrUpdateRosterItem(&ri)
 
 	s.ActivateMockedError()
-	_, err := s.DeleteRosterItem("user", "contact")
+	_, err := s.DeleteRosterItem("user", "jackal.im", "contact")
 	require.Equal(t, ErrMockedError, err)
 	s.DeactivateMockedError()
-	_, err = s.DeleteRosterItem("user", "contact")
+	_, err = s.DeleteRosterItem("user", "jackal.im", "contact")
 	require.Nil(t, err)
-	_, err = s.DeleteRosterItem("user2", "contact")
+	_, err = s.DeleteRosterItem("user2", "jackal.im", "contact")
 	require.Nil(t, err) // delete not existing roster item...
 
-	ri2, _ := s.FetchRosterItem("user", "contact")
+	ri2, _ := s.FetchRosterItem("user", "jackal.im", "contact")
 	require.Nil(t, ri2)
 }
 
 func TestMockStorageInsertRosterNotification(t *testing.T) {
 	rn := rostermodel.Notification{
 		"ortuman",
+		"jackal.im",
 		"romeo",
 		&xml.Presence{},
 	}
@@ -103,11 +104,13 @@ func TestMockStorageInsertRosterNotification(t *testing.T) {
 func TestMockStorageFetchRosterNotifications(t *testing.T) {
 	rn1 := rostermodel.Notification{
 		"romeo",
+		"jackal.im",
 		"ortuman@jackal.im",
 		&xml.Presence{},
 	}
 	rn2 := rostermodel.Notification{
 		"romeo",
+		"jackal.im",
 		"ortuman2@jackal.im",
 		&xml.Presence{},
 	}
@@ -121,10 +124,10 @@ func TestMockStorageFetchRosterNotifications(t *testing.T) {
 	s.InsertOrUpdateRosterNotification(&rn2)
 
 	s.ActivateMockedError()
-	_, err := s.FetchRosterNotifications("romeo")
+	_, err := s.FetchRosterNotifications("romeo", "jackal.im")
 	require.Equal(t, ErrMockedError, err)
 	s.DeactivateMockedError()
-	rns, err := s.FetchRosterNotifications("romeo")
+	rns, err := s.FetchRosterNotifications("romeo", "jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, 2, len(rns))
 	require.Equal(t, "ortuman@jackal.im", rns[0].JID)
@@ -134,6 +137,7 @@ func TestMockStorageFetchRosterNotifications(t *testing.T) {
 func TestMockStorageDeleteRosterNotification(t *testing.T) {
 	rn1 := rostermodel.Notification{
 		"ortuman",
+		"jackal.im",
 		"romeo",
 		&xml.Presence{},
 	}
@@ -141,13 +145,13 @@ func TestMockStorageDeleteRosterNotification(t *testing.T) {
 	s.InsertOrUpdateRosterNotification(&rn1)
 
 	s.ActivateMockedError()
-	require.Equal(t, ErrMockedError, s.DeleteRosterNotification("ortuman", "romeo"))
+	require.Equal(t, ErrMockedError, s.DeleteRosterNotification("ortuman", "jackal.im", "romeo"))
 	s.DeactivateMockedError()
-	require.Nil(t, s.DeleteRosterNotification("ortuman", "romeo"))
+	require.Nil(t, s.DeleteRosterNotification("ortuman", "jackal.im", "romeo"))
 
-	rns, err := s.FetchRosterNotifications("romeo")
+	rns, err := s.FetchRosterNotifications("romeo", "jackal.im")
 	require.Nil(t, err)
 	require.Equal(t, 0, len(rns))
 	// delete not existing roster notification...
-	require.Nil(t, s.DeleteRosterNotification("ortuman2", "romeo"))
+	require.Nil(t, s.DeleteRosterNotification("ortuman2", "jackal.im", "romeo"))
 }
@@ -5,40 +5,74 @@
 
 package memstorage
 
-import "github.com/ortuman/jackal/model"
+import (
+	"sort"
+
+	"github.com/ortuman/jackal/model"
+)
 
 // InsertOrUpdateUser inserts a new user entity into storage,
 // or updates it in case it's been previously inserted.
 func (m *Storage) InsertOrUpdateUser(user *model.User) error {
 	return m.inWriteLock(func() error {
-		m.users[user.Username] = user
+		m.users[userKey(user.Username, user.Domain)] = user
 		return nil
 	})
 }
 
 // DeleteUser deletes a user entity from storage.
-func (m *Storage) DeleteUser(username string) error {
+func (m *Storage) DeleteUser(username, domain string) error {
 	return m.inWriteLock(func() error {
-		delete(m.users, username)
+		delete(m.users, userKey(username, domain))
+		delete(m.authTokens, userKey(username, domain))
 		return nil
 	})
 }
 
 // FetchUser retrieves from storage a user entity.
-func (m *Storage) FetchUser(username string) (*model.User, error) {
+func (m *Storage) FetchUser(username, domain string) (*model.User, error) {
 	var ret *model.User
 	err := m.inReadLock(func() error {
-		ret = m.users[username]
+		ret = m.users[userKey(username, domain)]
+		return nil
+	})
+	return ret, err
+}
+
+// FetchUsers retrieves from storage all user entities.
+func (m *Storage) FetchUsers() ([]model.User, error) {
+	var ret []model.User
+	err := m.inReadLock(func() error {
+		for _, usr := range m.users {
+			ret = append(ret, *usr)
+		}
 		return nil
 	})
+	sort.Slice(ret, func(i, j int) bool {
+		return userKey(ret[i].Username, ret[i].Domain) < userKey(ret[j].Username, ret[j].Domain)
+	})
 	return ret, err
 }
 
 // UserExists returns whether or not a user exists within storage.
-func (m *Storage) UserExists(username string) (bool, error) {
+func (m *Storage) UserExists(username, domain string) (bool, error) {
 	var ret bool
 	err := m.inReadLock(func() error {
-		ret = m.users[username] != nil
+		ret = m.users[userKey(username, domain)] != nil
+		return nil
+	})
+	return ret, err
+}
+
+// CountUsers returns the number of users registered within a domain.
+func (m *Storage) CountUsers(domain string) (int, error) {
+	var ret int
+	err := m.inReadLock(func() error {
+		for _, usr := range m.users {
+			if usr.Domain == domain {
+				ret++
+			}
+		}
 		return nil
 	})
 	return ret, err
@@ -13,7 +13,7 @@ import (
 )
 
 func TestMockStorageInsertUser(t *testing.T) {
-	u := model.User{Username: "ortuman", Password: "1234"}
+	u := model.User{Username: "ortuman", Domain: "jackal.im", Password: "1234"}
 	s := New()
 	s.ActivateMockedError()
 	err := s.InsertOrUpdateUser(&u)
@@ -26,39 +26,77 @@ func TestMockStorageInsertUser(t *testing.T) {
 func TestMockStorageUserExists(t *testing.T) {
 	s := New()
 	s.ActivateMockedError()
-	ok, err := s.UserExists("ortuman")
+	ok, err := s.UserExists("ortuman", "jackal.im")
 	require.Equal(t, ErrMockedError, err)
 	s.DeactivateMockedError()
-	ok, err = s.UserExists("ortuman")
+	ok, err = s.UserExists("ortuman", "jackal.im")
 	require.Nil(t, err)
 	require.False(t, ok)
 }
 
 func TestMockStorageFetchUser(t *testing.T) {
-	u := model.User{Username: "ortuman", Password: "1234"}
+	u := model.User{Username: "ortuman", Domain: "jackal.im", Password: "1234"}
 	s := New()
 	_ = s.InsertOrUpdateUser(&u)
 
 	s.ActivateMockedError()
-	_, err := s.FetchUser("ortuman")
+	_, err := s.FetchUser("ortuman", "jackal.im")
 	require.Equal(t, ErrMockedError, err)
 	s.DeactivateMockedError()
-	usr, _ := s.FetchUser("romeo")
+	usr, _ := s.FetchUser("romeo", "jackal.im")
 	require.Nil(t, usr)
-	usr, _ = s.FetchUser("ortuman")
+	usr, _ = s.FetchUser("ortuman", "jackal.im")
 	require.NotNil(t, usr)
 }
 
 func TestMockStorageDeleteUser(t *testing.T) {
-	u := model.User{Username: "ortuman", Password: "1234"}
+	u := model.User{Username: "ortuman", Domain: "jackal.im", Password: "1234"}
 	s := New()
 	_ = s.InsertOrUpdateUser(&u)
 
 	s.ActivateMockedError()
-	require.Equal(t, ErrMockedError, s.DeleteUser("ortuman"))
+	require.Equal(t, ErrMockedError, s.DeleteUser("ortuman", "jackal.im"))
 	s.DeactivateMockedError()
-	require.Nil(t, s.DeleteUser("ortuman"))
+	require.Nil(t, s.DeleteUser("ortuman", "jackal.im"))
 
-	usr, _ := s.FetchUser("ortuman")
+	usr, _ := s.FetchUser("ortuman", "jackal.im")
 	require.Nil(t, usr)
 }
+
+func TestMockStorageFetchUsers(t *testing.T) {
+	s := New()
+	_ = s.InsertOrUpdateUser(&model.User{Username: "romeo", Domain: "jackal.im", Password: "1234"})
+	_ = s.InsertOrUpdateUser(&model.User{Username: "ortuman", Domain: "jackal.im", Password: "1234"})
+
+	s.ActivateMockedError()
+	_, err := s.FetchUsers()
+	require.Equal(t, ErrMockedError, err)
+	s.DeactivateMockedError()
+	users, err := s.FetchUsers()
+	require.Nil(t, err)
+	require.Equal(t, 2, len(users))
+	require.Equal(t, "ortuman", users[0].Username)
+	require.Equal(t, "romeo", users[1].Username)
+}
+
+func TestMockStorageUserDomains(t *testing.T) {
+	s := New()
+	_ = s.InsertOrUpdateUser(&model.User{Username: "ortuman", Domain: "jackal.im", Password: "1234"})
+	_ = s.InsertOrUpdateUser(&model.User{Username: "ortuman", Domain: "example.org", Password: "5678"})
+
+	usr, _ := s.FetchUser("ortuman", "jackal.im")
+	require.NotNil(t, usr)
+	require.Equal(t, "1234", usr.Password)
+	usr, _ = s.FetchUser("ortuman", "example.org")
+	require.NotNil(t, usr)
+	require.Equal(t, "5678", usr.Password)
+
+	count, _ := s.CountUsers("jackal.im")
+	require.Equal(t, 1, count)
+
+	require.Nil(t, s.DeleteUser("ortuman", "example.org"))
+	ok, _ := s.UserExists("ortuman", "example.org")
+	require.False(t, ok)
+	ok, _ = s.UserExists("ortuman", "jackal.im")
+	require.True(t, ok)
+}
@@ -20,9 +20,9 @@ func TestMockStorageInsertVCard(t *testing.T) {
 
 	s := New()
 	s.ActivateMockedError()
-	require.Equal(t, ErrMockedError, s.InsertOrUpdateVCard(vCard, "ortuman"))
+	require.Equal(t, ErrMockedError, s.InsertOrUpdateVCard(vCard, "ortuman", "jackal.im"))
 	s.DeactivateMockedError()
-	require.Nil(t, s.InsertOrUpdateVCard(vCard, "ortuman"))
+	require.Nil(t, s.InsertOrUpdateVCard(vCard, "ortuman", "jackal.im"))
 }
 
 func TestMockStorageFetchVCard(t *testing.T) {
@@ -32,12 +32,12 @@ func TestMockStorageFetchVCard(t *testing.T) {
 	vCard.AppendElement(fn)
 
 	s := New()
-	s.InsertOrUpdateVCard(vCard, "ortuman")
+	s.InsertOrUpdateVCard(vCard, "ortuman", "jackal.im")
 
 	s.ActivateMockedError()
-	_, err := s.FetchVCard("ortuman")
+	_, err := s.FetchVCard("ortuman", "jackal.im")
 	require.Equal(t, ErrMockedError, err)
 	s.DeactivateMockedError()
-	elem, _ := s.FetchVCard("ortuman")
+	elem, _ := s.FetchVCard("ortuman", "jackal.im")
 	require.NotNil(t, elem)
 }
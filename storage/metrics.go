@@ -0,0 +1,349 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package storage
+
+import (
+	"time"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/model/mammodel"
+	"github.com/ortuman/jackal/model/mucmodel"
+	"github.com/ortuman/jackal/model/privacymodel"
+	"github.com/ortuman/jackal/model/pubsubmodel"
+	"github.com/ortuman/jackal/model/rostermodel"
+	"github.com/ortuman/jackal/xml"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var latency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "jackal_storage_latency_seconds",
+	Help:    "Storage call latency by method, expressed in seconds.",
+	Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+}, []string{"method"})
+
+func init() {
+	prometheus.MustRegister(latency)
+}
+
+// measuredStorage decorates a storage backend
+// recording every call latency.
+type measuredStorage struct {
+	Storage
+}
+
+func newMeasuredStorage(s Storage) *measuredStorage {
+	return &measuredStorage{Storage: s}
+}
+
+func observe(method string, start time.Time) {
+	latency.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+// InsertOrUpdateUser satisfies Storage interface.
+func (s *measuredStorage) InsertOrUpdateUser(user *model.User) error {
+	defer observe("InsertOrUpdateUser", time.Now())
+	return s.Storage.InsertOrUpdateUser(user)
+}
+
+// DeleteUser satisfies Storage interface.
+func (s *measuredStorage) DeleteUser(username, domain string) error {
+	defer observe("DeleteUser", time.Now())
+	return s.Storage.DeleteUser(username, domain)
+}
+
+// FetchUser satisfies Storage interface.
+func (s *measuredStorage) FetchUser(username, domain string) (*model.User, error) {
+	defer observe("FetchUser", time.Now())
+	return s.Storage.FetchUser(username, domain)
+}
+
+// FetchUsers satisfies Storage interface.
+func (s *measuredStorage) FetchUsers() ([]model.User, error) {
+	defer observe("FetchUsers", time.Now())
+	return s.Storage.FetchUsers()
+}
+
+// UserExists satisfies Storage interface.
+func (s *measuredStorage) UserExists(username, domain string) (bool, error) {
+	defer observe("UserExists", time.Now())
+	return s.Storage.UserExists(username, domain)
+}
+
+// CountUsers satisfies Storage interface.
+func (s *measuredStorage) CountUsers(domain string) (int, error) {
+	defer observe("CountUsers", time.Now())
+	return s.Storage.CountUsers(domain)
+}
+
+// InsertOrUpdateRosterItem satisfies Storage interface.
+func (s *measuredStorage) InsertOrUpdateRosterItem(ri *rostermodel.Item) (rostermodel.Version, error) {
+	defer observe("InsertOrUpdateRosterItem", time.Now())
+	return s.Storage.InsertOrUpdateRosterItem(ri)
+}
+
+// DeleteRosterItem satisfies Storage interface.
+func (s *measuredStorage) DeleteRosterItem(username, domain, jid string) (rostermodel.Version, error) {
+	defer observe("DeleteRosterItem", time.Now())
+	return s.Storage.DeleteRosterItem(username, domain, jid)
+}
+
+// FetchRosterItems satisfies Storage interface.
+func (s *measuredStorage) FetchRosterItems(username, domain string) ([]rostermodel.Item, rostermodel.Version, error) {
+	defer observe("FetchRosterItems", time.Now())
+	return s.Storage.FetchRosterItems(username, domain)
+}
+
+// FetchRosterItem satisfies Storage interface.
+func (s *measuredStorage) FetchRosterItem(username, domain, jid string) (*rostermodel.Item, error) {
+	defer observe("FetchRosterItem", time.Now())
+	return s.Storage.FetchRosterItem(username, domain, jid)
+}
+
+// InsertOrUpdateRosterNotification satisfies Storage interface.
+func (s *measuredStorage) InsertOrUpdateRosterNotification(rn *rostermodel.Notification) error {
+	defer observe("InsertOrUpdateRosterNotification", time.Now())
+	return s.Storage.InsertOrUpdateRosterNotification(rn)
+}
+
+// DeleteRosterNotification satisfies Storage interface.
+func (s *measuredStorage) DeleteRosterNotification(contact, domain, jid string) error {
+	defer observe("DeleteRosterNotification", time.Now())
+	return s.Storage.DeleteRosterNotification(contact, domain, jid)
+}
+
+// FetchRosterNotification satisfies Storage interface.
+func (s *measuredStorage) FetchRosterNotification(contact, domain, jid string) (*rostermodel.Notification, error) {
+	defer observe("FetchRosterNotification", time.Now())
+	return s.Storage.FetchRosterNotification(contact, domain, jid)
+}
+
+// FetchRosterNotifications satisfies Storage interface.
+func (s *measuredStorage) FetchRosterNotifications(contact, domain string) ([]rostermodel.Notification, error) {
+	defer observe("FetchRosterNotifications", time.Now())
+	return s.Storage.FetchRosterNotifications(contact, domain)
+}
+
+// InsertOfflineMessage satisfies Storage interface.
+func (s *measuredStorage) InsertOfflineMessage(message xml.XElement, username, domain string) error {
+	defer observe("InsertOfflineMessage", time.Now())
+	return s.Storage.InsertOfflineMessage(message, username, domain)
+}
+
+// CountOfflineMessages satisfies Storage interface.
+func (s *measuredStorage) CountOfflineMessages(username, domain string) (int, error) {
+	defer observe("CountOfflineMessages", time.Now())
+	return s.Storage.CountOfflineMessages(username, domain)
+}
+
+// FetchOfflineMessages satisfies Storage interface.
+func (s *measuredStorage) FetchOfflineMessages(username, domain string) ([]xml.XElement, error) {
+	defer observe("FetchOfflineMessages", time.Now())
+	return s.Storage.FetchOfflineMessages(username, domain)
+}
+
+// DeleteOfflineMessages satisfies Storage interface.
+func (s *measuredStorage) DeleteOfflineMessages(username, domain string) error {
+	defer observe("DeleteOfflineMessages", time.Now())
+	return s.Storage.DeleteOfflineMessages(username, domain)
+}
+
+// InsertOrUpdateVCard satisfies Storage interface.
+func (s *measuredStorage) InsertOrUpdateVCard(vCard xml.XElement, username, domain string) error {
+	defer observe("InsertOrUpdateVCard", time.Now())
+	return s.Storage.InsertOrUpdateVCard(vCard, username, domain)
+}
+
+// FetchVCard satisfies Storage interface.
+func (s *measuredStorage) FetchVCard(username, domain string) (xml.XElement, error) {
+	defer observe("FetchVCard", time.Now())
+	return s.Storage.FetchVCard(username, domain)
+}
+
+// FetchPrivateXML satisfies Storage interface.
+func (s *measuredStorage) FetchPrivateXML(namespace, username, domain string) ([]xml.XElement, error) {
+	defer observe("FetchPrivateXML", time.Now())
+	return s.Storage.FetchPrivateXML(namespace, username, domain)
+}
+
+// InsertOrUpdatePrivateXML satisfies Storage interface.
+func (s *measuredStorage) InsertOrUpdatePrivateXML(privateXML []xml.XElement, namespace, username, domain string) error {
+	defer observe("InsertOrUpdatePrivateXML", time.Now())
+	return s.Storage.InsertOrUpdatePrivateXML(privateXML, namespace, username, domain)
+}
+
+// InsertBlockListItems satisfies Storage interface.
+func (s *measuredStorage) InsertBlockListItems(items []model.BlockListItem) error {
+	defer observe("InsertBlockListItems", time.Now())
+	return s.Storage.InsertBlockListItems(items)
+}
+
+// DeleteBlockListItems satisfies Storage interface.
+func (s *measuredStorage) DeleteBlockListItems(items []model.BlockListItem) error {
+	defer observe("DeleteBlockListItems", time.Now())
+	return s.Storage.DeleteBlockListItems(items)
+}
+
+// FetchBlockListItems satisfies Storage interface.
+func (s *measuredStorage) FetchBlockListItems(username, domain string) ([]model.BlockListItem, error) {
+	defer observe("FetchBlockListItems", time.Now())
+	return s.Storage.FetchBlockListItems(username, domain)
+}
+
+// InsertOrUpdateRoom satisfies Storage interface.
+func (s *measuredStorage) InsertOrUpdateRoom(room *mucmodel.Room) error {
+	defer observe("InsertOrUpdateRoom", time.Now())
+	return s.Storage.InsertOrUpdateRoom(room)
+}
+
+// DeleteRoom satisfies Storage interface.
+func (s *measuredStorage) DeleteRoom(roomJID string) error {
+	defer observe("DeleteRoom", time.Now())
+	return s.Storage.DeleteRoom(roomJID)
+}
+
+// FetchRoom satisfies Storage interface.
+func (s *measuredStorage) FetchRoom(roomJID string) (*mucmodel.Room, error) {
+	defer observe("FetchRoom", time.Now())
+	return s.Storage.FetchRoom(roomJID)
+}
+
+// FetchRooms satisfies Storage interface.
+func (s *measuredStorage) FetchRooms(service string) ([]mucmodel.Room, error) {
+	defer observe("FetchRooms", time.Now())
+	return s.Storage.FetchRooms(service)
+}
+
+// InsertArchiveMessage satisfies Storage interface.
+func (s *measuredStorage) InsertArchiveMessage(message *mammodel.Message) error {
+	defer observe("InsertArchiveMessage", time.Now())
+	return s.Storage.InsertArchiveMessage(message)
+}
+
+// FetchArchiveMessages satisfies Storage interface.
+func (s *measuredStorage) FetchArchiveMessages(username, domain string, filter *mammodel.Filter, page *mammodel.Page) (*mammodel.ResultSet, error) {
+	defer observe("FetchArchiveMessages", time.Now())
+	return s.Storage.FetchArchiveMessages(username, domain, filter, page)
+}
+
+// InsertOrUpdateArchivePrefs satisfies Storage interface.
+func (s *measuredStorage) InsertOrUpdateArchivePrefs(prefs *mammodel.Prefs) error {
+	defer observe("InsertOrUpdateArchivePrefs", time.Now())
+	return s.Storage.InsertOrUpdateArchivePrefs(prefs)
+}
+
+// FetchArchivePrefs satisfies Storage interface.
+func (s *measuredStorage) FetchArchivePrefs(username, domain string) (*mammodel.Prefs, error) {
+	defer observe("FetchArchivePrefs", time.Now())
+	return s.Storage.FetchArchivePrefs(username, domain)
+}
+
+// InsertOrUpdatePubSubNode satisfies Storage interface.
+func (s *measuredStorage) InsertOrUpdatePubSubNode(node *pubsubmodel.Node) error {
+	defer observe("InsertOrUpdatePubSubNode", time.Now())
+	return s.Storage.InsertOrUpdatePubSubNode(node)
+}
+
+// DeletePubSubNode satisfies Storage interface.
+func (s *measuredStorage) DeletePubSubNode(host, name string) error {
+	defer observe("DeletePubSubNode", time.Now())
+	return s.Storage.DeletePubSubNode(host, name)
+}
+
+// FetchPubSubNode satisfies Storage interface.
+func (s *measuredStorage) FetchPubSubNode(host, name string) (*pubsubmodel.Node, error) {
+	defer observe("FetchPubSubNode", time.Now())
+	return s.Storage.FetchPubSubNode(host, name)
+}
+
+// FetchPubSubNodes satisfies Storage interface.
+func (s *measuredStorage) FetchPubSubNodes(host string) ([]pubsubmodel.Node, error) {
+	defer observe("FetchPubSubNodes", time.Now())
+	return s.Storage.FetchPubSubNodes(host)
+}
+
+// InsertOrUpdatePubSubItem satisfies Storage interface.
+func (s *measuredStorage) InsertOrUpdatePubSubItem(item *pubsubmodel.Item, host, name string, maxItems int) error {
+	defer observe("InsertOrUpdatePubSubItem", time.Now())
+	return s.Storage.InsertOrUpdatePubSubItem(item, host, name, maxItems)
+}
+
+// DeletePubSubItem satisfies Storage interface.
+func (s *measuredStorage) DeletePubSubItem(itemID, host, name string) error {
+	defer observe("DeletePubSubItem", time.Now())
+	return s.Storage.DeletePubSubItem(itemID, host, name)
+}
+
+// FetchPubSubItems satisfies Storage interface.
+func (s *measuredStorage) FetchPubSubItems(host, name string) ([]pubsubmodel.Item, error) {
+	defer observe("FetchPubSubItems", time.Now())
+	return s.Storage.FetchPubSubItems(host, name)
+}
+
+// InsertOrUpdatePushRegistration satisfies Storage interface.
+func (s *measuredStorage) InsertOrUpdatePushRegistration(reg *model.PushRegistration) error {
+	defer observe("InsertOrUpdatePushRegistration", time.Now())
+	return s.Storage.InsertOrUpdatePushRegistration(reg)
+}
+
+// DeletePushRegistrations satisfies Storage interface.
+func (s *measuredStorage) DeletePushRegistrations(username, domain, jid, node string) error {
+	defer observe("DeletePushRegistrations", time.Now())
+	return s.Storage.DeletePushRegistrations(username, domain, jid, node)
+}
+
+// FetchPushRegistrations satisfies Storage interface.
+func (s *measuredStorage) FetchPushRegistrations(username, domain string) ([]model.PushRegistration, error) {
+	defer observe("FetchPushRegistrations", time.Now())
+	return s.Storage.FetchPushRegistrations(username, domain)
+}
+
+// InsertOrUpdateAuthToken satisfies Storage interface.
+func (s *measuredStorage) InsertOrUpdateAuthToken(token *model.AuthToken) error {
+	defer observe("InsertOrUpdateAuthToken", time.Now())
+	return s.Storage.InsertOrUpdateAuthToken(token)
+}
+
+// DeleteAuthTokens satisfies Storage interface.
+func (s *measuredStorage) DeleteAuthTokens(username, domain, device string) error {
+	defer observe("DeleteAuthTokens", time.Now())
+	return s.Storage.DeleteAuthTokens(username, domain, device)
+}
+
+// FetchAuthTokens satisfies Storage interface.
+func (s *measuredStorage) FetchAuthTokens(username, domain string) ([]model.AuthToken, error) {
+	defer observe("FetchAuthTokens", time.Now())
+	return s.Storage.FetchAuthTokens(username, domain)
+}
+
+// InsertOrUpdatePrivacyList satisfies Storage interface.
+func (s *measuredStorage) InsertOrUpdatePrivacyList(list *privacymodel.List) error {
+	defer observe("InsertOrUpdatePrivacyList", time.Now())
+	return s.Storage.InsertOrUpdatePrivacyList(list)
+}
+
+// DeletePrivacyList satisfies Storage interface.
+func (s *measuredStorage) DeletePrivacyList(username, domain, name string) error {
+	defer observe("DeletePrivacyList", time.Now())
+	return s.Storage.DeletePrivacyList(username, domain, name)
+}
+
+// FetchPrivacyLists satisfies Storage interface.
+func (s *measuredStorage) FetchPrivacyLists(username, domain string) ([]privacymodel.List, error) {
+	defer observe("FetchPrivacyLists", time.Now())
+	return s.Storage.FetchPrivacyLists(username, domain)
+}
+
+// SetDefaultPrivacyList satisfies Storage interface.
+func (s *measuredStorage) SetDefaultPrivacyList(username, domain, name string) error {
+	defer observe("SetDefaultPrivacyList", time.Now())
+	return s.Storage.SetDefaultPrivacyList(username, domain, name)
+}
+
+// MigrateDomain satisfies Storage interface.
+func (s *measuredStorage) MigrateDomain(domain string) (int, error) {
+	defer observe("MigrateDomain", time.Now())
+	return s.Storage.MigrateDomain(domain)
+}
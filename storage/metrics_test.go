@@ -0,0 +1,47 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package storage
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/storage/memstorage"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMeasuredStorage(t *testing.T) {
+	Initialize(&Config{Type: Memory})
+	defer Shutdown()
+
+	_, ok := Instance().(*measuredStorage)
+	require.True(t, ok)
+
+	inserts := tUtilLatencyCount("InsertOrUpdateUser")
+	fetches := tUtilLatencyCount("FetchUser")
+
+	require.Nil(t, Instance().InsertOrUpdateUser(&model.User{Username: "ortuman", Domain: "jackal.im"}))
+	usr, err := Instance().FetchUser("ortuman", "jackal.im")
+	require.Nil(t, err)
+	require.NotNil(t, usr)
+
+	require.Equal(t, inserts+1, tUtilLatencyCount("InsertOrUpdateUser"))
+	require.Equal(t, fetches+1, tUtilLatencyCount("FetchUser"))
+
+	ActivateMockedError()
+	_, err = Instance().FetchUser("ortuman", "jackal.im")
+	require.Equal(t, memstorage.ErrMockedError, err)
+	DeactivateMockedError()
+	require.Equal(t, fetches+2, tUtilLatencyCount("FetchUser"))
+}
+
+func tUtilLatencyCount(method string) uint64 {
+	var m dto.Metric
+	latency.WithLabelValues(method).(prometheus.Histogram).Write(&m)
+	return m.GetHistogram().GetSampleCount()
+}
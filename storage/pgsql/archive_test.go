@@ -0,0 +1,181 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pgsql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ortuman/jackal/model/mammodel"
+	"github.com/ortuman/jackal/xml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPgSQLStorageInsertArchiveMessage(t *testing.T) {
+	now := time.Now()
+	msg := mammodel.Message{ID: "1234", Username: "ortuman", Domain: "jackal.im", JID: "noelia@jackal.im", Message: xml.NewElementName("message"), CreatedAt: now}
+
+	s, mock := NewMock()
+	mock.ExpectExec("INSERT INTO archive_messages (.+)").
+		WithArgs("ortuman", "jackal.im", "1234", "noelia@jackal.im", "<message/>", now).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := s.InsertArchiveMessage(&msg)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = NewMock()
+	mock.ExpectExec("INSERT INTO archive_messages (.+)").
+		WithArgs("ortuman", "jackal.im", "1234", "noelia@jackal.im", "<message/>", now).
+		WillReturnError(errPgSQLStorage)
+
+	err = s.InsertArchiveMessage(&msg)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
+
+func TestPgSQLStorageFetchArchiveMessages(t *testing.T) {
+	var columns = []string{"id", "username", "domain", "jid", "data", "created_at"}
+	now := time.Now()
+
+	s, mock := NewMock()
+	mock.ExpectQuery("SELECT COUNT(.+) FROM archive_messages (.+)").
+		WithArgs("ortuman", "jackal.im", "noelia@jackal.im", now).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery("SELECT (.+) FROM archive_messages (.+) ORDER BY serial LIMIT 2").
+		WithArgs("ortuman", "jackal.im", "noelia@jackal.im", now).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("1", "ortuman", "jackal.im", "noelia@jackal.im", "<message><body>Hi!</body></message>", now).
+			AddRow("2", "ortuman", "jackal.im", "noelia@jackal.im", "<message><body>Bye!</body></message>", now))
+
+	rs, err := s.FetchArchiveMessages("ortuman", "jackal.im", &mammodel.Filter{With: "noelia@jackal.im", Start: now}, nil)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, 2, rs.Count)
+	require.Equal(t, 2, len(rs.Messages))
+	require.Equal(t, "Bye!", rs.Messages[1].Message.Elements().Child("body").Text())
+
+	// page after a message
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT COUNT(.+) FROM archive_messages (.+)").
+		WithArgs("ortuman", "jackal.im").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery("SELECT serial FROM archive_messages (.+)").
+		WithArgs("ortuman", "jackal.im", "1").
+		WillReturnRows(sqlmock.NewRows([]string{"serial"}).AddRow(10))
+	mock.ExpectQuery("SELECT COUNT(.+) FROM archive_messages (.+)").
+		WithArgs("ortuman", "jackal.im", 10).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery("SELECT (.+) FROM archive_messages (.+) ORDER BY serial LIMIT 1").
+		WithArgs("ortuman", "jackal.im", 10).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("2", "ortuman", "jackal.im", "noelia@jackal.im", "<message><body>Bye!</body></message>", now))
+
+	rs, err = s.FetchArchiveMessages("ortuman", "jackal.im", nil, &mammodel.Page{After: "1", Max: 1})
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, 3, rs.Count)
+	require.Equal(t, 1, rs.FirstIndex)
+	require.Equal(t, 1, len(rs.Messages))
+	require.Equal(t, "2", rs.Messages[0].ID)
+
+	// last page
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT COUNT(.+) FROM archive_messages (.+)").
+		WithArgs("ortuman", "jackal.im").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery("SELECT (.+) FROM archive_messages (.+) ORDER BY serial DESC LIMIT 2").
+		WithArgs("ortuman", "jackal.im").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("3", "ortuman", "jackal.im", "noelia@jackal.im", "<message/>", now).
+			AddRow("2", "ortuman", "jackal.im", "noelia@jackal.im", "<message/>", now))
+
+	rs, err = s.FetchArchiveMessages("ortuman", "jackal.im", nil, &mammodel.Page{Last: true, Max: 2})
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, 1, rs.FirstIndex)
+	require.Equal(t, "2", rs.Messages[0].ID)
+	require.Equal(t, "3", rs.Messages[1].ID)
+
+	// unknown page message
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT COUNT(.+) FROM archive_messages (.+)").
+		WithArgs("ortuman", "jackal.im").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery("SELECT serial FROM archive_messages (.+)").
+		WithArgs("ortuman", "jackal.im", "9").
+		WillReturnRows(sqlmock.NewRows([]string{"serial"}))
+
+	_, err = s.FetchArchiveMessages("ortuman", "jackal.im", nil, &mammodel.Page{Before: "9", Max: 1})
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, mammodel.ErrItemNotFound, err)
+
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT COUNT(.+) FROM archive_messages (.+)").
+		WithArgs("ortuman", "jackal.im").
+		WillReturnError(errPgSQLStorage)
+
+	_, err = s.FetchArchiveMessages("ortuman", "jackal.im", nil, nil)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
+
+func TestPgSQLStorageInsertArchivePrefs(t *testing.T) {
+	prefs := mammodel.Prefs{Username: "ortuman", Domain: "jackal.im", Default: mammodel.DefaultRoster, Always: []string{"a@jackal.im", "b@jackal.im"}}
+
+	s, mock := NewMock()
+	mock.ExpectExec("INSERT INTO archive_prefs (.+) ON CONFLICT (.+)").
+		WithArgs("ortuman", "jackal.im", "roster", "a@jackal.im;b@jackal.im", "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := s.InsertOrUpdateArchivePrefs(&prefs)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = NewMock()
+	mock.ExpectExec("INSERT INTO archive_prefs (.+) ON CONFLICT (.+)").
+		WillReturnError(errPgSQLStorage)
+
+	err = s.InsertOrUpdateArchivePrefs(&prefs)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
+
+func TestPgSQLStorageFetchArchivePrefs(t *testing.T) {
+	var columns = []string{"username", "domain", "default_mode", "always", "never"}
+
+	s, mock := NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM archive_prefs (.+)").
+		WithArgs("ortuman", "jackal.im").
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	prefs, err := s.FetchArchivePrefs("ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Nil(t, prefs)
+
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM archive_prefs (.+)").
+		WithArgs("ortuman", "jackal.im").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("ortuman", "jackal.im", "never", "", "romeo@jackal.im"))
+
+	prefs, err = s.FetchArchivePrefs("ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, mammodel.DefaultNever, prefs.Default)
+	require.Nil(t, prefs.Always)
+	require.Equal(t, []string{"romeo@jackal.im"}, prefs.Never)
+
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM archive_prefs (.+)").
+		WithArgs("ortuman", "jackal.im").
+		WillReturnError(errPgSQLStorage)
+
+	_, err = s.FetchArchivePrefs("ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
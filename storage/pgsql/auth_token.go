@@ -0,0 +1,59 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pgsql
+
+import (
+	sq "github.com/Masterminds/squirrel"
+	"github.com/ortuman/jackal/model"
+)
+
+// InsertOrUpdateAuthToken inserts a new auth token entity into storage,
+// or updates it in case it's been previously inserted for the same device.
+func (s *Storage) InsertOrUpdateAuthToken(token *model.AuthToken) error {
+	_, err := psql.Insert("auth_tokens").
+		Columns("username", "domain", "device", "hash", "expires_at", "updated_at", "created_at").
+		Values(token.Username, token.Domain, token.Device, token.Hash, token.ExpiresAt, nowExpr, nowExpr).
+		Suffix("ON CONFLICT (username, domain, device) DO UPDATE SET hash = EXCLUDED.hash, expires_at = EXCLUDED.expires_at, updated_at = NOW(), created_at = NOW()").
+		RunWith(s.db).Exec()
+	return err
+}
+
+// DeleteAuthTokens deletes from storage a user device auth token.
+// In case device is empty every token associated to the user
+// will be deleted.
+func (s *Storage) DeleteAuthTokens(username, domain, device string) error {
+	cond := sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}}
+	if len(device) > 0 {
+		cond = append(cond, sq.Eq{"device": device})
+	}
+	_, err := psql.Delete("auth_tokens").Where(cond).RunWith(s.db).Exec()
+	return err
+}
+
+// FetchAuthTokens retrieves from storage all auth token
+// entities associated to a given user.
+func (s *Storage) FetchAuthTokens(username, domain string) ([]model.AuthToken, error) {
+	q := psql.Select("username", "domain", "device", "hash", "expires_at", "created_at").
+		From("auth_tokens").
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}}).
+		OrderBy("created_at")
+
+	rows, err := q.RunWith(s.db).Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ret []model.AuthToken
+	for rows.Next() {
+		var token model.AuthToken
+		if err := rows.Scan(&token.Username, &token.Domain, &token.Device, &token.Hash, &token.ExpiresAt, &token.CreatedAt); err != nil {
+			return nil, err
+		}
+		ret = append(ret, token)
+	}
+	return ret, nil
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pgsql
+
+import (
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/ortuman/jackal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPgSQLStorageInsertAuthToken(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour)
+	token := &model.AuthToken{Username: "ortuman", Domain: "jackal.im", Device: "balcony", Hash: "a1b2c3", ExpiresAt: expiresAt}
+
+	s, mock := NewMock()
+	mock.ExpectExec("INSERT INTO auth_tokens (.+) ON CONFLICT (.+)").
+		WithArgs("ortuman", "jackal.im", "balcony", "a1b2c3", expiresAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := s.InsertOrUpdateAuthToken(token)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = NewMock()
+	mock.ExpectExec("INSERT INTO auth_tokens (.+) ON CONFLICT (.+)").
+		WillReturnError(errPgSQLStorage)
+
+	err = s.InsertOrUpdateAuthToken(token)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
+
+func TestPgSQLStorageDeleteAuthTokens(t *testing.T) {
+	s, mock := NewMock()
+	mock.ExpectExec("DELETE FROM auth_tokens (.+)").
+		WithArgs("ortuman", "jackal.im", "balcony").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := s.DeleteAuthTokens("ortuman", "jackal.im", "balcony")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = NewMock()
+	mock.ExpectExec("DELETE FROM auth_tokens (.+)").
+		WithArgs("ortuman", "jackal.im").
+		WillReturnError(errPgSQLStorage)
+
+	err = s.DeleteAuthTokens("ortuman", "jackal.im", "")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
+
+func TestPgSQLStorageFetchAuthTokens(t *testing.T) {
+	var tokenColumns = []string{"username", "domain", "device", "hash", "expires_at", "created_at"}
+	now := time.Now()
+
+	s, mock := NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM auth_tokens (.+)").
+		WithArgs("ortuman", "jackal.im").
+		WillReturnRows(sqlmock.NewRows(tokenColumns).
+			AddRow("ortuman", "jackal.im", "balcony", "a1b2c3", now.Add(time.Hour), now).
+			AddRow("ortuman", "jackal.im", "garden", "d4e5f6", now.Add(time.Hour), now))
+
+	tokens, err := s.FetchAuthTokens("ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, 2, len(tokens))
+	require.Equal(t, "balcony", tokens[0].Device)
+	require.Equal(t, "d4e5f6", tokens[1].Hash)
+
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM auth_tokens (.+)").
+		WithArgs("ortuman", "jackal.im").
+		WillReturnError(errPgSQLStorage)
+
+	_, err = s.FetchAuthTokens("ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
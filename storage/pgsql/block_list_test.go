@@ -0,0 +1,87 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pgsql
+
+import (
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/ortuman/jackal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPgSQLStorageInsertBlockListItems(t *testing.T) {
+	s, mock := NewMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO blocklist_items (.+) ON CONFLICT (.+)").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := s.InsertBlockListItems([]model.BlockListItem{{Username: "ortuman", Domain: "jackal.im", JID: "noelia@jackal.im"}})
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = NewMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO blocklist_items (.+) ON CONFLICT (.+)").WillReturnError(errPgSQLStorage)
+	mock.ExpectRollback()
+
+	err = s.InsertBlockListItems([]model.BlockListItem{{Username: "ortuman", Domain: "jackal.im", JID: "noelia@jackal.im"}})
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
+
+func TestPgSQLFetchBlockListItems(t *testing.T) {
+	var blockListColumns = []string{"username", "domain", "jid"}
+	s, mock := NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM blocklist_items (.+)").
+		WithArgs("ortuman", "jackal.im").
+		WillReturnRows(sqlmock.NewRows(blockListColumns).AddRow("ortuman", "jackal.im", "noelia@jackal.im"))
+
+	_, err := s.FetchBlockListItems("ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM blocklist_items (.+)").
+		WithArgs("ortuman", "jackal.im").
+		WillReturnError(errPgSQLStorage)
+
+	_, err = s.FetchBlockListItems("ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
+
+func TestPgSQLStorageDeleteBlockListItems(t *testing.T) {
+	s, mock := NewMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM blocklist_items (.+)").
+		WithArgs("ortuman", "jackal.im").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	s, mock = NewMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM blocklist_items (.+)").
+		WithArgs("ortuman", "jackal.im", "noelia@jackal.im").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	delItems := []model.BlockListItem{{Username: "ortuman", Domain: "jackal.im", JID: "noelia@jackal.im"}}
+	err := s.DeleteBlockListItems(delItems)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = NewMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM blocklist_items (.+)").
+		WillReturnError(errPgSQLStorage)
+	mock.ExpectRollback()
+
+	err = s.DeleteBlockListItems(delItems)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
@@ -0,0 +1,44 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pgsql
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPgSQLStorageMigrateDomain(t *testing.T) {
+	s, mock := NewMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users SET domain = (.+) WHERE domain = (.+)").
+		WithArgs("jackal.im", "").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	for _, table := range domainTables {
+		mock.ExpectExec("UPDATE "+table+" SET domain = (.+) WHERE domain = (.+)").
+			WithArgs("jackal.im", "").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+
+	n, err := s.MigrateDomain("jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, 2, n)
+
+	s, mock = NewMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE users SET domain = (.+) WHERE domain = (.+)").
+		WithArgs("jackal.im", "").
+		WillReturnError(errPgSQLStorage)
+	mock.ExpectRollback()
+
+	n, err = s.MigrateDomain("jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+	require.Equal(t, 0, n)
+}
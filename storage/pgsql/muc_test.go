@@ -0,0 +1,136 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pgsql
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ortuman/jackal/model/mucmodel"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPgSQLStorageInsertRoom(t *testing.T) {
+	room := mucmodel.Room{
+		JID:          "lobby@conference.jackal.im",
+		Name:         "Lobby",
+		Config:       mucmodel.RoomConfig{Persistent: true, MaxUsers: 50},
+		Affiliations: map[string]string{"ortuman@jackal.im": mucmodel.AffiliationOwner},
+	}
+	s, mock := NewMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO muc_rooms (.+) ON CONFLICT (.+)").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("DELETE FROM muc_room_affiliations (.+)").
+		WithArgs("lobby@conference.jackal.im").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO muc_room_affiliations (.+)").
+		WithArgs("lobby@conference.jackal.im", "ortuman@jackal.im", mucmodel.AffiliationOwner).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err := s.InsertOrUpdateRoom(&room)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = NewMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO muc_rooms (.+) ON CONFLICT (.+)").
+		WillReturnError(errPgSQLStorage)
+	mock.ExpectRollback()
+
+	err = s.InsertOrUpdateRoom(&room)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
+
+func TestPgSQLStorageDeleteRoom(t *testing.T) {
+	s, mock := NewMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM muc_room_affiliations (.+)").
+		WithArgs("lobby@conference.jackal.im").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM muc_rooms (.+)").
+		WithArgs("lobby@conference.jackal.im").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := s.DeleteRoom("lobby@conference.jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = NewMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM muc_room_affiliations (.+)").
+		WithArgs("lobby@conference.jackal.im").WillReturnError(errPgSQLStorage)
+	mock.ExpectRollback()
+
+	err = s.DeleteRoom("lobby@conference.jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
+
+func TestPgSQLStorageFetchRoom(t *testing.T) {
+	s, mock := NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM muc_rooms (.+)").
+		WithArgs("lobby@conference.jackal.im").
+		WillReturnRows(sqlmock.NewRows(roomColumns))
+
+	room, err := s.FetchRoom("lobby@conference.jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Nil(t, room)
+
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM muc_rooms (.+)").
+		WithArgs("lobby@conference.jackal.im").
+		WillReturnRows(sqlmock.NewRows(roomColumns).
+			AddRow("lobby@conference.jackal.im", "Lobby", "", "", true, true, false, false, false, true, true, "", 50))
+	mock.ExpectQuery("SELECT (.+) FROM muc_room_affiliations (.+)").
+		WithArgs("lobby@conference.jackal.im").
+		WillReturnRows(sqlmock.NewRows([]string{"jid", "affiliation"}).AddRow("ortuman@jackal.im", "owner"))
+
+	room, err = s.FetchRoom("lobby@conference.jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.NotNil(t, room)
+	require.Equal(t, "Lobby", room.Name)
+	require.True(t, room.Config.Persistent)
+	require.Equal(t, 50, room.Config.MaxUsers)
+	require.Equal(t, mucmodel.AffiliationOwner, room.Affiliation("ortuman@jackal.im"))
+
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM muc_rooms (.+)").
+		WithArgs("lobby@conference.jackal.im").
+		WillReturnError(errPgSQLStorage)
+
+	_, err = s.FetchRoom("lobby@conference.jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
+
+func TestPgSQLStorageFetchRooms(t *testing.T) {
+	s, mock := NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM muc_rooms (.+)").
+		WithArgs("conference.jackal.im").
+		WillReturnRows(sqlmock.NewRows(roomColumns).
+			AddRow("lobby@conference.jackal.im", "Lobby", "", "", true, true, false, false, false, true, true, "", 50))
+	mock.ExpectQuery("SELECT (.+) FROM muc_room_affiliations (.+)").
+		WithArgs("lobby@conference.jackal.im").
+		WillReturnRows(sqlmock.NewRows([]string{"jid", "affiliation"}))
+
+	rooms, err := s.FetchRooms("conference.jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, 1, len(rooms))
+
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM muc_rooms (.+)").
+		WithArgs("conference.jackal.im").
+		WillReturnError(errPgSQLStorage)
+
+	_, err = s.FetchRooms("conference.jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
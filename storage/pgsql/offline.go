@@ -0,0 +1,76 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pgsql
+
+import (
+	sq "github.com/Masterminds/squirrel"
+	"github.com/ortuman/jackal/xml"
+)
+
+// InsertOfflineMessage inserts a new message element into
+// user's offline queue.
+func (s *Storage) InsertOfflineMessage(message xml.XElement, username, domain string) error {
+	q := psql.Insert("offline_messages").
+		Columns("username", "domain", "data", "created_at").
+		Values(username, domain, message.String(), nowExpr)
+	_, err := q.RunWith(s.db).Exec()
+	return err
+}
+
+// CountOfflineMessages returns current length of user's offline queue.
+func (s *Storage) CountOfflineMessages(username, domain string) (int, error) {
+	q := psql.Select("COUNT(*)").
+		From("offline_messages").
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}})
+
+	var count int
+	err := q.RunWith(s.db).Scan(&count)
+	switch err {
+	case nil:
+		return count, nil
+	default:
+		return 0, err
+	}
+}
+
+// FetchOfflineMessages retrieves from storage current user offline queue.
+func (s *Storage) FetchOfflineMessages(username, domain string) ([]xml.XElement, error) {
+	q := psql.Select("data").
+		From("offline_messages").
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}}).
+		OrderBy("created_at")
+
+	rows, err := q.RunWith(s.db).Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buf := s.pool.Get()
+	defer s.pool.Put(buf)
+
+	buf.WriteString("<root>")
+	for rows.Next() {
+		var msg string
+		rows.Scan(&msg)
+		buf.WriteString(msg)
+	}
+	buf.WriteString("</root>")
+
+	parser := xml.NewParser(buf, xml.DefaultMode, 0)
+	rootEl, err := parser.ParseElement()
+	if err != nil {
+		return nil, err
+	}
+	return rootEl.Elements().All(), nil
+}
+
+// DeleteOfflineMessages clears a user offline queue.
+func (s *Storage) DeleteOfflineMessages(username, domain string) error {
+	q := psql.Delete("offline_messages").Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}})
+	_, err := q.RunWith(s.db).Exec()
+	return err
+}
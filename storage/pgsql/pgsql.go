@@ -0,0 +1,131 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pgsql
+
+import (
+	"database/sql"
+	"net/url"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	sq "github.com/Masterminds/squirrel"
+	_ "github.com/lib/pq" // SQL driver
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/pool"
+)
+
+var (
+	nowExpr = sq.Expr("NOW()")
+
+	// statement builder using PostgreSQL positional placeholders ($1, $2, ...)
+	psql = sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+)
+
+type rowScanner interface {
+	Scan(...interface{}) error
+}
+
+type rowsScanner interface {
+	rowScanner
+	Next() bool
+}
+
+// Config represents PostgreSQL storage configuration.
+type Config struct {
+	Host     string `yaml:"host"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Database string `yaml:"database"`
+	SSLMode  string `yaml:"ssl_mode"`
+	PoolSize int    `yaml:"pool_size"`
+}
+
+// Storage represents a PostgreSQL storage sub system.
+type Storage struct {
+	db     *sql.DB
+	pool   *pool.BufferPool
+	doneCh chan chan bool
+}
+
+// New returns a PostgreSQL storage instance.
+func New(cfg *Config) *Storage {
+	var err error
+	s := &Storage{
+		pool:   pool.NewBufferPool(),
+		doneCh: make(chan chan bool),
+	}
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.User, cfg.Password),
+		Host:     cfg.Host,
+		Path:     cfg.Database,
+		RawQuery: url.Values{"sslmode": []string{cfg.SSLMode}}.Encode(),
+	}
+	s.db, err = sql.Open("postgres", dsn.String())
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	s.db.SetMaxOpenConns(cfg.PoolSize) // set max opened connection count
+
+	if err := s.db.Ping(); err != nil {
+		log.Fatalf("%v", err)
+	}
+	go s.loop()
+
+	return s
+}
+
+// NewMock returns a mocked PostgreSQL storage instance.
+func NewMock() (*Storage, sqlmock.Sqlmock) {
+	var err error
+	var sqlMock sqlmock.Sqlmock
+	s := &Storage{
+		pool: pool.NewBufferPool(),
+	}
+	s.db, sqlMock, err = sqlmock.New()
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	return s, sqlMock
+}
+
+// Shutdown shuts down PostgreSQL storage sub system.
+func (s *Storage) Shutdown() {
+	ch := make(chan bool)
+	s.doneCh <- ch
+	<-ch
+}
+
+func (s *Storage) loop() {
+	tc := time.NewTicker(time.Second * 15)
+	defer tc.Stop()
+	for {
+		select {
+		case <-tc.C:
+			err := s.db.Ping()
+			if err != nil {
+				log.Error(err)
+			}
+		case ch := <-s.doneCh:
+			s.db.Close()
+			close(ch)
+			return
+		}
+	}
+}
+
+func (s *Storage) inTransaction(f func(tx *sql.Tx) error) error {
+	tx, txErr := s.db.Begin()
+	if txErr != nil {
+		return txErr
+	}
+	if err := f(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	tx.Commit()
+	return nil
+}
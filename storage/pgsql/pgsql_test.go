@@ -0,0 +1,14 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pgsql
+
+import (
+	"errors"
+)
+
+var (
+	errPgSQLStorage = errors.New("PostgreSQL storage error")
+)
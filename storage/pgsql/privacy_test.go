@@ -0,0 +1,127 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pgsql
+
+import (
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/ortuman/jackal/model/privacymodel"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPgSQLStorageInsertPrivacyList(t *testing.T) {
+	l := &privacymodel.List{Username: "ortuman", Domain: "jackal.im", Name: "public", Items: []privacymodel.Item{
+		{Type: privacymodel.JIDType, Value: "romeo@jackal.im", Action: privacymodel.Deny, Order: 1, Stanzas: []string{privacymodel.Message, privacymodel.IQ}},
+	}}
+	s, mock := NewMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO privacy_lists (.+) ON CONFLICT (.+)").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM privacy_list_items (.+)").
+		WithArgs("ortuman", "jackal.im", "public").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO privacy_list_items (.+)").
+		WithArgs("ortuman", "jackal.im", "public", 1, privacymodel.JIDType, "romeo@jackal.im", privacymodel.Deny, "message,iq").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := s.InsertOrUpdatePrivacyList(l)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = NewMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO privacy_lists (.+) ON CONFLICT (.+)").
+		WillReturnError(errPgSQLStorage)
+	mock.ExpectRollback()
+
+	err = s.InsertOrUpdatePrivacyList(l)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
+
+func TestPgSQLStorageDeletePrivacyList(t *testing.T) {
+	s, mock := NewMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM privacy_list_items (.+)").
+		WithArgs("ortuman", "jackal.im", "public").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM privacy_lists (.+)").
+		WithArgs("ortuman", "jackal.im", "public").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := s.DeletePrivacyList("ortuman", "jackal.im", "public")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = NewMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM privacy_list_items (.+)").
+		WillReturnError(errPgSQLStorage)
+	mock.ExpectRollback()
+
+	err = s.DeletePrivacyList("ortuman", "jackal.im", "public")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
+
+func TestPgSQLStorageFetchPrivacyLists(t *testing.T) {
+	var listColumns = []string{"username", "domain", "name", "is_default"}
+	var itemColumns = []string{"list_name", "ord", "type", "value", "action", "stanzas"}
+
+	s, mock := NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM privacy_lists (.+)").
+		WithArgs("ortuman", "jackal.im").
+		WillReturnRows(sqlmock.NewRows(listColumns).
+			AddRow("ortuman", "jackal.im", "public", true).
+			AddRow("ortuman", "jackal.im", "private", false))
+	mock.ExpectQuery("SELECT (.+) FROM privacy_list_items (.+)").
+		WithArgs("ortuman", "jackal.im").
+		WillReturnRows(sqlmock.NewRows(itemColumns).
+			AddRow("private", 1, "", "", privacymodel.Deny, "").
+			AddRow("public", 1, privacymodel.JIDType, "romeo@jackal.im", privacymodel.Deny, "message,iq").
+			AddRow("public", 2, "", "", privacymodel.Allow, ""))
+
+	lists, err := s.FetchPrivacyLists("ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, 2, len(lists))
+	require.True(t, lists[0].Default)
+	require.Equal(t, 2, len(lists[0].Items))
+	require.Equal(t, []string{privacymodel.Message, privacymodel.IQ}, lists[0].Items[0].Stanzas)
+	require.Equal(t, 1, len(lists[1].Items))
+	require.Nil(t, lists[1].Items[0].Stanzas)
+
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM privacy_lists (.+)").
+		WithArgs("ortuman", "jackal.im").
+		WillReturnError(errPgSQLStorage)
+
+	_, err = s.FetchPrivacyLists("ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
+
+func TestPgSQLStorageSetDefaultPrivacyList(t *testing.T) {
+	s, mock := NewMock()
+	mock.ExpectExec("UPDATE privacy_lists SET (.+)").
+		WithArgs("public", "ortuman", "jackal.im").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	err := s.SetDefaultPrivacyList("ortuman", "jackal.im", "public")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = NewMock()
+	mock.ExpectExec("UPDATE privacy_lists SET (.+)").
+		WillReturnError(errPgSQLStorage)
+
+	err = s.SetDefaultPrivacyList("ortuman", "jackal.im", "public")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
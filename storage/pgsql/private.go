@@ -0,0 +1,62 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pgsql
+
+import (
+	"database/sql"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/ortuman/jackal/xml"
+)
+
+// InsertOrUpdatePrivateXML inserts a new private element into storage,
+// or updates it in case it's been previously inserted.
+func (s *Storage) InsertOrUpdatePrivateXML(privateXML []xml.XElement, namespace, username, domain string) error {
+	buf := s.pool.Get()
+	defer s.pool.Put(buf)
+	for _, elem := range privateXML {
+		elem.ToXML(buf, true)
+	}
+	rawXML := buf.String()
+
+	q := psql.Insert("private_storage").
+		Columns("username", "domain", "namespace", "data", "updated_at", "created_at").
+		Values(username, domain, namespace, rawXML, nowExpr, nowExpr).
+		Suffix("ON CONFLICT (username, domain, namespace) DO UPDATE SET data = EXCLUDED.data, updated_at = NOW()")
+
+	_, err := q.RunWith(s.db).Exec()
+	return err
+}
+
+// FetchPrivateXML retrieves from storage a private element.
+func (s *Storage) FetchPrivateXML(namespace, username, domain string) ([]xml.XElement, error) {
+	q := psql.Select("data").
+		From("private_storage").
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}, sq.Eq{"namespace": namespace}})
+
+	var privateXML string
+	err := q.RunWith(s.db).QueryRow().Scan(&privateXML)
+	switch err {
+	case nil:
+		buf := s.pool.Get()
+		defer s.pool.Put(buf)
+		buf.WriteString("<root>")
+		buf.WriteString(privateXML)
+		buf.WriteString("</root>")
+
+		parser := xml.NewParser(buf, xml.DefaultMode, 0)
+		rootEl, err := parser.ParseElement()
+		if err != nil {
+			return nil, err
+		}
+		return rootEl.Elements().All(), nil
+
+	case sql.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pgsql
+
+import (
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/ortuman/jackal/xml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPgSQLStorageInsertPrivateXML(t *testing.T) {
+	private := xml.NewElementNamespace("exodus", "exodus:ns")
+	rawXML := private.String()
+
+	s, mock := NewMock()
+	mock.ExpectExec("INSERT INTO private_storage (.+) ON CONFLICT (.+)").
+		WithArgs("ortuman", "jackal.im", "exodus:ns", rawXML).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := s.InsertOrUpdatePrivateXML([]xml.XElement{private}, "exodus:ns", "ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = NewMock()
+	mock.ExpectExec("INSERT INTO private_storage (.+) ON CONFLICT (.+)").
+		WithArgs("ortuman", "jackal.im", "exodus:ns", rawXML).
+		WillReturnError(errPgSQLStorage)
+
+	err = s.InsertOrUpdatePrivateXML([]xml.XElement{private}, "exodus:ns", "ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
+
+func TestPgSQLStorageFetchPrivateXML(t *testing.T) {
+	var privateColumns = []string{"data"}
+
+	s, mock := NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM private_storage (.+)").
+		WithArgs("ortuman", "jackal.im", "exodus:ns").
+		WillReturnRows(sqlmock.NewRows(privateColumns).AddRow("<exodus xmlns='exodus:ns'><stuff/></exodus>"))
+
+	elems, err := s.FetchPrivateXML("exodus:ns", "ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, 1, len(elems))
+
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM private_storage (.+)").
+		WithArgs("ortuman", "jackal.im", "exodus:ns").
+		WillReturnRows(sqlmock.NewRows(privateColumns).AddRow("<exodus xmlns='exodus:ns'><stuff/>"))
+
+	elems, err = s.FetchPrivateXML("exodus:ns", "ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.NotNil(t, err)
+	require.Equal(t, 0, len(elems))
+
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM private_storage (.+)").
+		WithArgs("ortuman", "jackal.im", "exodus:ns").
+		WillReturnRows(sqlmock.NewRows(privateColumns).AddRow(""))
+
+	elems, err = s.FetchPrivateXML("exodus:ns", "ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, 0, len(elems))
+
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM private_storage (.+)").
+		WithArgs("ortuman", "jackal.im", "exodus:ns").
+		WillReturnRows(sqlmock.NewRows(privateColumns))
+
+	elems, err = s.FetchPrivateXML("exodus:ns", "ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, 0, len(elems))
+
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM private_storage (.+)").
+		WithArgs("ortuman", "jackal.im", "exodus:ns").
+		WillReturnError(errPgSQLStorage)
+
+	elems, err = s.FetchPrivateXML("exodus:ns", "ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+	require.Equal(t, 0, len(elems))
+}
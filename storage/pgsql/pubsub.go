@@ -0,0 +1,261 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pgsql
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/ortuman/jackal/model/pubsubmodel"
+	"github.com/ortuman/jackal/xml"
+)
+
+// InsertOrUpdatePubSubNode inserts a new pubsub node entity into storage,
+// or updates it in case it's been previously inserted.
+func (s *Storage) InsertOrUpdatePubSubNode(node *pubsubmodel.Node) error {
+	return s.inTransaction(func(tx *sql.Tx) error {
+		_, err := psql.Insert("pubsub_nodes").
+			Columns("host", "name", "updated_at", "created_at").
+			Values(node.Host, node.Name, nowExpr, nowExpr).
+			Suffix("ON CONFLICT (host, name) DO UPDATE SET updated_at = NOW()").
+			RunWith(tx).Exec()
+		if err != nil {
+			return err
+		}
+		nodeCond := sq.And{sq.Eq{"host": node.Host}, sq.Eq{"name": node.Name}}
+
+		// options
+		if _, err := psql.Delete("pubsub_node_options").Where(nodeCond).RunWith(tx).Exec(); err != nil {
+			return err
+		}
+		opts := node.Options.Map()
+		for _, optName := range sortedKeys(opts) {
+			_, err := psql.Insert("pubsub_node_options").
+				Columns("host", "name", "opt_name", "opt_value").
+				Values(node.Host, node.Name, optName, opts[optName]).
+				RunWith(tx).Exec()
+			if err != nil {
+				return err
+			}
+		}
+		// affiliations
+		if _, err := psql.Delete("pubsub_affiliations").Where(nodeCond).RunWith(tx).Exec(); err != nil {
+			return err
+		}
+		for _, j := range sortedKeys(node.Affiliations) {
+			_, err := psql.Insert("pubsub_affiliations").
+				Columns("host", "name", "jid", "affiliation", "created_at").
+				Values(node.Host, node.Name, j, node.Affiliations[j], nowExpr).
+				RunWith(tx).Exec()
+			if err != nil {
+				return err
+			}
+		}
+		// subscriptions
+		if _, err := psql.Delete("pubsub_subscriptions").Where(nodeCond).RunWith(tx).Exec(); err != nil {
+			return err
+		}
+		for _, j := range sortedKeys(node.Subscriptions) {
+			_, err := psql.Insert("pubsub_subscriptions").
+				Columns("host", "name", "jid", "subid", "created_at").
+				Values(node.Host, node.Name, j, node.Subscriptions[j], nowExpr).
+				RunWith(tx).Exec()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeletePubSubNode deletes a pubsub node entity from storage
+// along with all its published items.
+func (s *Storage) DeletePubSubNode(host, name string) error {
+	return s.inTransaction(func(tx *sql.Tx) error {
+		nodeCond := sq.And{sq.Eq{"host": host}, sq.Eq{"name": name}}
+		for _, table := range []string{"pubsub_items", "pubsub_subscriptions", "pubsub_affiliations", "pubsub_node_options", "pubsub_nodes"} {
+			if _, err := psql.Delete(table).Where(nodeCond).RunWith(tx).Exec(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// FetchPubSubNode retrieves from storage a pubsub node entity.
+func (s *Storage) FetchPubSubNode(host, name string) (*pubsubmodel.Node, error) {
+	q := psql.Select("host", "name").
+		From("pubsub_nodes").
+		Where(sq.And{sq.Eq{"host": host}, sq.Eq{"name": name}})
+
+	var node pubsubmodel.Node
+	err := q.RunWith(s.db).QueryRow().Scan(&node.Host, &node.Name)
+	switch err {
+	case nil:
+		if err := s.fetchPubSubNodeDetails(&node); err != nil {
+			return nil, err
+		}
+		return &node, nil
+	case sql.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+// FetchPubSubNodes retrieves from storage all pubsub node entities
+// associated to a given host.
+func (s *Storage) FetchPubSubNodes(host string) ([]pubsubmodel.Node, error) {
+	q := psql.Select("host", "name").
+		From("pubsub_nodes").
+		Where(sq.Eq{"host": host}).
+		OrderBy("created_at")
+
+	rows, err := q.RunWith(s.db).Query()
+	if err != nil {
+		return nil, err
+	}
+	var nodes []pubsubmodel.Node
+	for rows.Next() {
+		var node pubsubmodel.Node
+		if err := rows.Scan(&node.Host, &node.Name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	rows.Close()
+
+	for i := 0; i < len(nodes); i++ {
+		if err := s.fetchPubSubNodeDetails(&nodes[i]); err != nil {
+			return nil, err
+		}
+	}
+	return nodes, nil
+}
+
+// InsertOrUpdatePubSubItem inserts a new item entity into a pubsub node,
+// or updates it in case it's been previously inserted.
+// Oldest node items will be discarded beyond maxItems (if greater than zero).
+func (s *Storage) InsertOrUpdatePubSubItem(item *pubsubmodel.Item, host, name string, maxItems int) error {
+	var payload string
+	if item.Payload != nil {
+		payload = item.Payload.String()
+	}
+	return s.inTransaction(func(tx *sql.Tx) error {
+		_, err := psql.Insert("pubsub_items").
+			Columns("host", "name", "item_id", "publisher", "payload", "created_at").
+			Values(host, name, item.ID, item.Publisher, payload, item.CreatedAt).
+			Suffix("ON CONFLICT (host, name, item_id) DO UPDATE SET publisher = EXCLUDED.publisher, " +
+				"payload = EXCLUDED.payload, created_at = EXCLUDED.created_at").
+			RunWith(tx).Exec()
+		if err != nil || maxItems <= 0 {
+			return err
+		}
+		// discard oldest items
+		_, err = psql.Delete("pubsub_items").
+			Where(sq.And{
+				sq.Eq{"host": host},
+				sq.Eq{"name": name},
+				sq.Expr("item_id NOT IN (SELECT item_id FROM pubsub_items "+
+					"WHERE host = ? AND name = ? ORDER BY created_at DESC LIMIT ?)", host, name, maxItems),
+			}).
+			RunWith(tx).Exec()
+		return err
+	})
+}
+
+// DeletePubSubItem deletes a pubsub node item entity from storage.
+func (s *Storage) DeletePubSubItem(itemID, host, name string) error {
+	_, err := psql.Delete("pubsub_items").
+		Where(sq.And{sq.Eq{"host": host}, sq.Eq{"name": name}, sq.Eq{"item_id": itemID}}).
+		RunWith(s.db).Exec()
+	return err
+}
+
+// FetchPubSubItems retrieves from storage, in chronological order,
+// all items published into a pubsub node.
+func (s *Storage) FetchPubSubItems(host, name string) ([]pubsubmodel.Item, error) {
+	q := psql.Select("item_id", "publisher", "payload", "created_at").
+		From("pubsub_items").
+		Where(sq.And{sq.Eq{"host": host}, sq.Eq{"name": name}}).
+		OrderBy("created_at")
+
+	rows, err := q.RunWith(s.db).Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ret []pubsubmodel.Item
+	for rows.Next() {
+		var item pubsubmodel.Item
+		var payload string
+		if err := rows.Scan(&item.ID, &item.Publisher, &payload, &item.CreatedAt); err != nil {
+			return nil, err
+		}
+		if len(payload) > 0 {
+			parser := xml.NewParser(strings.NewReader(payload), xml.DefaultMode, 0)
+			if item.Payload, err = parser.ParseElement(); err != nil {
+				return nil, err
+			}
+		}
+		ret = append(ret, item)
+	}
+	return ret, nil
+}
+
+func (s *Storage) fetchPubSubNodeDetails(node *pubsubmodel.Node) error {
+	nodeCond := sq.And{sq.Eq{"host": node.Host}, sq.Eq{"name": node.Name}}
+
+	// options
+	opts := make(map[string]string)
+	err := s.scanPubSubPairs(psql.Select("opt_name", "opt_value").From("pubsub_node_options").Where(nodeCond), func(k, v string) {
+		opts[k] = v
+	})
+	if err != nil {
+		return err
+	}
+	node.Options = pubsubmodel.DefaultOptions()
+	if err := node.Options.SetMap(opts); err != nil {
+		return err
+	}
+	// affiliations
+	err = s.scanPubSubPairs(psql.Select("jid", "affiliation").From("pubsub_affiliations").Where(nodeCond), node.SetAffiliation)
+	if err != nil {
+		return err
+	}
+	// subscriptions
+	return s.scanPubSubPairs(psql.Select("jid", "subid").From("pubsub_subscriptions").Where(nodeCond), node.SetSubscription)
+}
+
+func (s *Storage) scanPubSubPairs(q sq.SelectBuilder, f func(k, v string)) error {
+	rows, err := q.RunWith(s.db).Query()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var k, v string
+		if err := rows.Scan(&k, &v); err != nil {
+			return err
+		}
+		f(k, v)
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,245 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pgsql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ortuman/jackal/model/pubsubmodel"
+	"github.com/ortuman/jackal/xml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPgSQLStorageInsertPubSubNode(t *testing.T) {
+	node := pubsubmodel.Node{
+		Host:          "pubsub.jackal.im",
+		Name:          "princely_musings",
+		Options:       pubsubmodel.DefaultOptions(),
+		Affiliations:  map[string]string{"ortuman@jackal.im": pubsubmodel.AffiliationOwner},
+		Subscriptions: map[string]string{"noelia@jackal.im": "1234"},
+	}
+	s, mock := NewMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO pubsub_nodes (.+) ON CONFLICT (.+) DO UPDATE (.+)").
+		WithArgs("pubsub.jackal.im", "princely_musings").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("DELETE FROM pubsub_node_options (.+)").
+		WithArgs("pubsub.jackal.im", "princely_musings").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	for range node.Options.Map() {
+		mock.ExpectExec("INSERT INTO pubsub_node_options (.+)").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	mock.ExpectExec("DELETE FROM pubsub_affiliations (.+)").
+		WithArgs("pubsub.jackal.im", "princely_musings").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO pubsub_affiliations (.+)").
+		WithArgs("pubsub.jackal.im", "princely_musings", "ortuman@jackal.im", pubsubmodel.AffiliationOwner).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("DELETE FROM pubsub_subscriptions (.+)").
+		WithArgs("pubsub.jackal.im", "princely_musings").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO pubsub_subscriptions (.+)").
+		WithArgs("pubsub.jackal.im", "princely_musings", "noelia@jackal.im", "1234").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err := s.InsertOrUpdatePubSubNode(&node)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = NewMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO pubsub_nodes (.+) ON CONFLICT (.+) DO UPDATE (.+)").
+		WillReturnError(errPgSQLStorage)
+	mock.ExpectRollback()
+
+	err = s.InsertOrUpdatePubSubNode(&node)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
+
+func TestPgSQLStorageDeletePubSubNode(t *testing.T) {
+	s, mock := NewMock()
+	mock.ExpectBegin()
+	for _, table := range []string{"pubsub_items", "pubsub_subscriptions", "pubsub_affiliations", "pubsub_node_options", "pubsub_nodes"} {
+		mock.ExpectExec("DELETE FROM "+table+" (.+)").
+			WithArgs("pubsub.jackal.im", "princely_musings").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+
+	err := s.DeletePubSubNode("pubsub.jackal.im", "princely_musings")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = NewMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM pubsub_items (.+)").
+		WithArgs("pubsub.jackal.im", "princely_musings").
+		WillReturnError(errPgSQLStorage)
+	mock.ExpectRollback()
+
+	err = s.DeletePubSubNode("pubsub.jackal.im", "princely_musings")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
+
+func TestPgSQLStorageFetchPubSubNode(t *testing.T) {
+	s, mock := NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM pubsub_nodes (.+)").
+		WithArgs("pubsub.jackal.im", "princely_musings").
+		WillReturnRows(sqlmock.NewRows([]string{"host", "name"}))
+
+	node, err := s.FetchPubSubNode("pubsub.jackal.im", "princely_musings")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Nil(t, node)
+
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM pubsub_nodes (.+)").
+		WithArgs("pubsub.jackal.im", "princely_musings").
+		WillReturnRows(sqlmock.NewRows([]string{"host", "name"}).AddRow("pubsub.jackal.im", "princely_musings"))
+	mock.ExpectQuery("SELECT (.+) FROM pubsub_node_options (.+)").
+		WithArgs("pubsub.jackal.im", "princely_musings").
+		WillReturnRows(sqlmock.NewRows([]string{"opt_name", "opt_value"}).
+			AddRow("pubsub#title", "Princely Musings").
+			AddRow("pubsub#access_model", pubsubmodel.AccessModelWhitelist))
+	mock.ExpectQuery("SELECT (.+) FROM pubsub_affiliations (.+)").
+		WithArgs("pubsub.jackal.im", "princely_musings").
+		WillReturnRows(sqlmock.NewRows([]string{"jid", "affiliation"}).AddRow("ortuman@jackal.im", "owner"))
+	mock.ExpectQuery("SELECT (.+) FROM pubsub_subscriptions (.+)").
+		WithArgs("pubsub.jackal.im", "princely_musings").
+		WillReturnRows(sqlmock.NewRows([]string{"jid", "subid"}).AddRow("noelia@jackal.im", "1234"))
+
+	node, err = s.FetchPubSubNode("pubsub.jackal.im", "princely_musings")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.NotNil(t, node)
+	require.Equal(t, "Princely Musings", node.Options.Title)
+	require.Equal(t, pubsubmodel.AccessModelWhitelist, node.Options.AccessModel)
+	require.True(t, node.Options.PersistItems) // default value
+	require.Equal(t, pubsubmodel.AffiliationOwner, node.Affiliation("ortuman@jackal.im"))
+	require.Equal(t, "1234", node.Subscription("noelia@jackal.im"))
+
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM pubsub_nodes (.+)").
+		WithArgs("pubsub.jackal.im", "princely_musings").
+		WillReturnError(errPgSQLStorage)
+
+	_, err = s.FetchPubSubNode("pubsub.jackal.im", "princely_musings")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
+
+func TestPgSQLStorageFetchPubSubNodes(t *testing.T) {
+	s, mock := NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM pubsub_nodes (.+)").
+		WithArgs("pubsub.jackal.im").
+		WillReturnRows(sqlmock.NewRows([]string{"host", "name"}).AddRow("pubsub.jackal.im", "princely_musings"))
+	mock.ExpectQuery("SELECT (.+) FROM pubsub_node_options (.+)").
+		WithArgs("pubsub.jackal.im", "princely_musings").
+		WillReturnRows(sqlmock.NewRows([]string{"opt_name", "opt_value"}))
+	mock.ExpectQuery("SELECT (.+) FROM pubsub_affiliations (.+)").
+		WithArgs("pubsub.jackal.im", "princely_musings").
+		WillReturnRows(sqlmock.NewRows([]string{"jid", "affiliation"}))
+	mock.ExpectQuery("SELECT (.+) FROM pubsub_subscriptions (.+)").
+		WithArgs("pubsub.jackal.im", "princely_musings").
+		WillReturnRows(sqlmock.NewRows([]string{"jid", "subid"}))
+
+	nodes, err := s.FetchPubSubNodes("pubsub.jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, 1, len(nodes))
+	require.Equal(t, pubsubmodel.DefaultOptions(), nodes[0].Options)
+
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM pubsub_nodes (.+)").
+		WithArgs("pubsub.jackal.im").
+		WillReturnError(errPgSQLStorage)
+
+	_, err = s.FetchPubSubNodes("pubsub.jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
+
+func TestPgSQLStorageInsertPubSubItem(t *testing.T) {
+	payload := xml.NewElementNamespace("entry", "http://www.w3.org/2005/Atom")
+	item := pubsubmodel.Item{ID: "1234", Publisher: "ortuman@jackal.im", Payload: payload, CreatedAt: time.Now()}
+
+	s, mock := NewMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO pubsub_items (.+) ON CONFLICT (.+) DO UPDATE (.+)").
+		WithArgs("pubsub.jackal.im", "princely_musings", "1234", "ortuman@jackal.im", payload.String(), item.CreatedAt).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("DELETE FROM pubsub_items WHERE (.+) NOT IN (.+) LIMIT (.+)").
+		WithArgs("pubsub.jackal.im", "princely_musings", "pubsub.jackal.im", "princely_musings", 10).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := s.InsertOrUpdatePubSubItem(&item, "pubsub.jackal.im", "princely_musings", 10)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = NewMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO pubsub_items (.+) ON CONFLICT (.+) DO UPDATE (.+)").
+		WillReturnError(errPgSQLStorage)
+	mock.ExpectRollback()
+
+	err = s.InsertOrUpdatePubSubItem(&item, "pubsub.jackal.im", "princely_musings", 0)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
+
+func TestPgSQLStorageDeletePubSubItem(t *testing.T) {
+	s, mock := NewMock()
+	mock.ExpectExec("DELETE FROM pubsub_items (.+)").
+		WithArgs("pubsub.jackal.im", "princely_musings", "1234").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := s.DeletePubSubItem("1234", "pubsub.jackal.im", "princely_musings")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = NewMock()
+	mock.ExpectExec("DELETE FROM pubsub_items (.+)").
+		WithArgs("pubsub.jackal.im", "princely_musings", "1234").
+		WillReturnError(errPgSQLStorage)
+
+	err = s.DeletePubSubItem("1234", "pubsub.jackal.im", "princely_musings")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
+
+func TestPgSQLStorageFetchPubSubItems(t *testing.T) {
+	var itemColumns = []string{"item_id", "publisher", "payload", "created_at"}
+
+	s, mock := NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM pubsub_items (.+)").
+		WithArgs("pubsub.jackal.im", "princely_musings").
+		WillReturnRows(sqlmock.NewRows(itemColumns).
+			AddRow("1234", "ortuman@jackal.im", "<entry xmlns='http://www.w3.org/2005/Atom'/>", time.Now()).
+			AddRow("5678", "ortuman@jackal.im", "", time.Now()))
+
+	items, err := s.FetchPubSubItems("pubsub.jackal.im", "princely_musings")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, 2, len(items))
+	require.Equal(t, "entry", items[0].Payload.Name())
+	require.Nil(t, items[1].Payload)
+
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM pubsub_items (.+)").
+		WithArgs("pubsub.jackal.im", "princely_musings").
+		WillReturnError(errPgSQLStorage)
+
+	_, err = s.FetchPubSubItems("pubsub.jackal.im", "princely_musings")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pgsql
+
+import (
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/ortuman/jackal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPgSQLStorageInsertPushRegistration(t *testing.T) {
+	reg := &model.PushRegistration{Username: "ortuman", Domain: "jackal.im", JID: "push.jackal.im", Node: "n1"}
+
+	s, mock := NewMock()
+	mock.ExpectExec("INSERT INTO push_registrations (.+) ON CONFLICT (.+)").
+		WithArgs("ortuman", "jackal.im", "push.jackal.im", "n1", "").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := s.InsertOrUpdatePushRegistration(reg)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = NewMock()
+	mock.ExpectExec("INSERT INTO push_registrations (.+) ON CONFLICT (.+)").
+		WillReturnError(errPgSQLStorage)
+
+	err = s.InsertOrUpdatePushRegistration(reg)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
+
+func TestPgSQLStorageDeletePushRegistrations(t *testing.T) {
+	s, mock := NewMock()
+	mock.ExpectExec("DELETE FROM push_registrations (.+)").
+		WithArgs("ortuman", "jackal.im", "push.jackal.im", "n1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := s.DeletePushRegistrations("ortuman", "jackal.im", "push.jackal.im", "n1")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = NewMock()
+	mock.ExpectExec("DELETE FROM push_registrations (.+)").
+		WithArgs("ortuman", "jackal.im", "push.jackal.im").
+		WillReturnError(errPgSQLStorage)
+
+	err = s.DeletePushRegistrations("ortuman", "jackal.im", "push.jackal.im", "")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
+
+func TestPgSQLStorageFetchPushRegistrations(t *testing.T) {
+	var pushColumns = []string{"username", "domain", "jid", "node", "options"}
+
+	s, mock := NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM push_registrations (.+)").
+		WithArgs("ortuman", "jackal.im").
+		WillReturnRows(sqlmock.NewRows(pushColumns).
+			AddRow("ortuman", "jackal.im", "push.jackal.im", "n1", "").
+			AddRow("ortuman", "jackal.im", "push.jackal.im", "n2", `<x xmlns="jabber:x:data" type="submit"/>`))
+
+	regs, err := s.FetchPushRegistrations("ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, 2, len(regs))
+	require.Nil(t, regs[0].Options)
+	require.Equal(t, "x", regs[1].Options.Name())
+
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM push_registrations (.+)").
+		WithArgs("ortuman", "jackal.im").
+		WillReturnError(errPgSQLStorage)
+
+	_, err = s.FetchPushRegistrations("ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
@@ -0,0 +1,232 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pgsql
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ortuman/jackal/model/rostermodel"
+	"github.com/ortuman/jackal/xml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPgSQLStorageInsertRosterItem(t *testing.T) {
+	g := []string{"general", "friends"}
+	ri := rostermodel.Item{
+		Username:     "user",
+		Domain:       "jackal.im",
+		JID:          "contact",
+		Name:         "a name",
+		Subscription: "both",
+		Ask:          false,
+		Ver:          1,
+		Groups:       g,
+	}
+
+	args := []driver.Value{
+		ri.Username,
+		ri.Domain,
+		ri.JID,
+		ri.Name,
+		ri.Subscription,
+		"general;friends",
+		ri.Ask,
+		ri.Username,
+		ri.Domain,
+	}
+
+	s, mock := NewMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO roster_versions (.+) ON CONFLICT (.+)").
+		WithArgs("user", "jackal.im").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO roster_items (.+) ON CONFLICT (.+)").
+		WithArgs(args...).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT (.+) FROM roster_versions (.+)").
+		WithArgs("user", "jackal.im").
+		WillReturnRows(sqlmock.NewRows([]string{"ver", "deletionVer"}).AddRow(1, 0))
+
+	_, err := s.InsertOrUpdateRosterItem(&ri)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+}
+
+func TestPgSQLStorageDeleteRosterItem(t *testing.T) {
+	s, mock := NewMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO roster_versions (.+) ON CONFLICT (.+)").
+		WithArgs("user", "jackal.im").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM roster_items (.+)").
+		WithArgs("user", "jackal.im", "contact").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT (.+) FROM roster_versions (.+)").
+		WithArgs("user", "jackal.im").
+		WillReturnRows(sqlmock.NewRows([]string{"ver", "deletionVer"}).AddRow(1, 0))
+
+	_, err := s.DeleteRosterItem("user", "jackal.im", "contact")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = NewMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO roster_versions (.+)").
+		WithArgs("user", "jackal.im").WillReturnError(errPgSQLStorage)
+	mock.ExpectRollback()
+
+	_, err = s.DeleteRosterItem("user", "jackal.im", "contact")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
+
+func TestPgSQLStorageFetchRosterItems(t *testing.T) {
+	var riColumns = []string{"user", "domain", "contact", "name", "subscription", "groups", "ask", "ver"}
+
+	s, mock := NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM roster_items (.+)").
+		WithArgs("ortuman", "jackal.im").
+		WillReturnRows(sqlmock.NewRows(riColumns).AddRow("ortuman", "jackal.im", "romeo", "Romeo", "both", "", false, 0))
+	mock.ExpectQuery("SELECT (.+) FROM roster_versions (.+)").
+		WithArgs("ortuman", "jackal.im").
+		WillReturnRows(sqlmock.NewRows([]string{"ver", "deletionVer"}).AddRow(0, 0))
+
+	rosterItems, _, err := s.FetchRosterItems("ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, 1, len(rosterItems))
+
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM roster_items (.+)").
+		WithArgs("ortuman", "jackal.im").
+		WillReturnError(errPgSQLStorage)
+
+	_, _, err = s.FetchRosterItems("ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM roster_items (.+)").
+		WithArgs("ortuman", "jackal.im", "romeo").
+		WillReturnRows(sqlmock.NewRows(riColumns).AddRow("ortuman", "jackal.im", "romeo", "Romeo", "both", "", false, 0))
+
+	ri, err := s.FetchRosterItem("ortuman", "jackal.im", "romeo")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM roster_items (.+)").
+		WithArgs("ortuman", "jackal.im", "romeo").
+		WillReturnRows(sqlmock.NewRows(riColumns))
+
+	ri, err = s.FetchRosterItem("ortuman", "jackal.im", "romeo")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, ri)
+
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM roster_items (.+)").
+		WithArgs("ortuman", "jackal.im", "romeo").
+		WillReturnError(errPgSQLStorage)
+
+	_, err = s.FetchRosterItem("ortuman", "jackal.im", "romeo")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
+
+func TestPgSQLStorageInsertRosterNotification(t *testing.T) {
+	rn := rostermodel.Notification{
+		Contact:  "ortuman",
+		Domain:   "jackal.im",
+		JID:      "romeo",
+		Presence: &xml.Presence{},
+	}
+	presenceXML := rn.Presence.String()
+
+	args := []driver.Value{
+		rn.Contact,
+		rn.Domain,
+		rn.JID,
+		presenceXML,
+	}
+	s, mock := NewMock()
+	mock.ExpectExec("INSERT INTO roster_notifications (.+) ON CONFLICT (.+)").
+		WithArgs(args...).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := s.InsertOrUpdateRosterNotification(&rn)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = NewMock()
+	mock.ExpectExec("INSERT INTO roster_notifications (.+) ON CONFLICT (.+)").
+		WithArgs(args...).
+		WillReturnError(errPgSQLStorage)
+
+	err = s.InsertOrUpdateRosterNotification(&rn)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
+
+func TestPgSQLStorageDeleteRosterNotification(t *testing.T) {
+	s, mock := NewMock()
+	mock.ExpectExec("DELETE FROM roster_notifications (.+)").
+		WithArgs("user", "jackal.im", "contact").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := s.DeleteRosterNotification("user", "jackal.im", "contact")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = NewMock()
+	mock.ExpectExec("DELETE FROM roster_notifications (.+)").
+		WithArgs("user", "jackal.im", "contact").WillReturnError(errPgSQLStorage)
+
+	err = s.DeleteRosterNotification("user", "jackal.im", "contact")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
+
+func TestPgSQLStorageFetchRosterNotifications(t *testing.T) {
+	var rnColumns = []string{"user", "domain", "contact", "elements"}
+
+	s, mock := NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM roster_notifications (.+)").
+		WithArgs("ortuman", "jackal.im").
+		WillReturnRows(sqlmock.NewRows(rnColumns).AddRow("romeo", "jackal.im", "contact", "<priority>8</priority>"))
+
+	rosterNotifications, err := s.FetchRosterNotifications("ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, 1, len(rosterNotifications))
+
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM roster_notifications (.+)").
+		WithArgs("ortuman", "jackal.im").
+		WillReturnRows(sqlmock.NewRows(rnColumns))
+
+	rosterNotifications, err = s.FetchRosterNotifications("ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, 0, len(rosterNotifications))
+
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM roster_notifications (.+)").
+		WithArgs("ortuman", "jackal.im").
+		WillReturnError(errPgSQLStorage)
+
+	_, err = s.FetchRosterNotifications("ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM roster_notifications (.+)").
+		WithArgs("ortuman", "jackal.im").
+		WillReturnRows(sqlmock.NewRows(rnColumns).AddRow("romeo", "jackal.im", "contact", "<priority>8"))
+
+	_, err = s.FetchRosterNotifications("ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.NotNil(t, err)
+}
@@ -0,0 +1,193 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pgsql
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+)
+
+// InsertOrUpdateUser inserts a new user entity into storage,
+// or updates it in case it's been previously inserted.
+func (s *Storage) InsertOrUpdateUser(u *model.User) error {
+	var presenceXML string
+	if u.LastPresence != nil {
+		buf := s.pool.Get()
+		u.LastPresence.ToXML(buf, true)
+		presenceXML = buf.String()
+		s.pool.Put(buf)
+	}
+	columns := []string{"username", "domain", "password", "scram_sha1", "scram_sha256", "disabled", "updated_at", "created_at"}
+	values := []interface{}{u.Username, u.Domain, u.Password, scramCredentialsString(u.ScramSHA1), scramCredentialsString(u.ScramSHA256), u.Disabled, nowExpr, nowExpr}
+
+	if len(presenceXML) > 0 {
+		columns = append(columns, []string{"last_presence", "last_presence_at"}...)
+		values = append(values, []interface{}{presenceXML, nowExpr}...)
+	}
+	var suffix string
+	if len(presenceXML) > 0 {
+		suffix = "ON CONFLICT (username, domain) DO UPDATE SET password = EXCLUDED.password, scram_sha1 = EXCLUDED.scram_sha1, scram_sha256 = EXCLUDED.scram_sha256, disabled = EXCLUDED.disabled, last_presence = EXCLUDED.last_presence, last_presence_at = NOW(), updated_at = NOW()"
+	} else {
+		suffix = "ON CONFLICT (username, domain) DO UPDATE SET password = EXCLUDED.password, scram_sha1 = EXCLUDED.scram_sha1, scram_sha256 = EXCLUDED.scram_sha256, disabled = EXCLUDED.disabled, updated_at = NOW()"
+	}
+	q := psql.Insert("users").
+		Columns(columns...).
+		Values(values...).
+		Suffix(suffix)
+	_, err := q.RunWith(s.db).Exec()
+	return err
+}
+
+// FetchUser retrieves from storage a user entity.
+func (s *Storage) FetchUser(username, domain string) (*model.User, error) {
+	q := psql.Select(userColumns...).
+		From("users").
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}})
+
+	var usr model.User
+	err := s.scanUserEntity(&usr, q.RunWith(s.db).QueryRow())
+	switch err {
+	case nil:
+		return &usr, nil
+	case sql.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+// FetchUsers retrieves from storage all user entities.
+func (s *Storage) FetchUsers() ([]model.User, error) {
+	q := psql.Select(userColumns...).
+		From("users").
+		OrderBy("domain", "username")
+
+	rows, err := q.RunWith(s.db).Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []model.User
+	for rows.Next() {
+		var usr model.User
+		if err := s.scanUserEntity(&usr, rows); err != nil {
+			return nil, err
+		}
+		users = append(users, usr)
+	}
+	return users, nil
+}
+
+// DeleteUser deletes a user entity from storage.
+func (s *Storage) DeleteUser(username, domain string) error {
+	cond := sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}}
+	return s.inTransaction(func(tx *sql.Tx) error {
+		var err error
+		_, err = psql.Delete("offline_messages").Where(cond).RunWith(tx).Exec()
+		if err != nil {
+			return err
+		}
+		_, err = psql.Delete("roster_items").Where(cond).RunWith(tx).Exec()
+		if err != nil {
+			return err
+		}
+		_, err = psql.Delete("roster_versions").Where(cond).RunWith(tx).Exec()
+		if err != nil {
+			return err
+		}
+		_, err = psql.Delete("private_storage").Where(cond).RunWith(tx).Exec()
+		if err != nil {
+			return err
+		}
+		_, err = psql.Delete("vcards").Where(cond).RunWith(tx).Exec()
+		if err != nil {
+			return err
+		}
+		_, err = psql.Delete("auth_tokens").Where(cond).RunWith(tx).Exec()
+		if err != nil {
+			return err
+		}
+		_, err = psql.Delete("users").Where(cond).RunWith(tx).Exec()
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+// UserExists returns whether or not a user exists within storage.
+func (s *Storage) UserExists(username, domain string) (bool, error) {
+	q := psql.Select("COUNT(*)").From("users").Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}})
+	var count int
+	err := q.RunWith(s.db).QueryRow().Scan(&count)
+	switch err {
+	case nil:
+		return count > 0, nil
+	default:
+		return false, err
+	}
+}
+
+// CountUsers returns the number of users registered within a domain.
+func (s *Storage) CountUsers(domain string) (int, error) {
+	q := psql.Select("COUNT(*)").From("users").Where(sq.Eq{"domain": domain})
+	var count int
+	if err := q.RunWith(s.db).QueryRow().Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+var userColumns = []string{"username", "domain", "password", "scram_sha1", "scram_sha256", "disabled", "last_presence", "last_presence_at"}
+
+func (s *Storage) scanUserEntity(usr *model.User, scanner rowScanner) error {
+	var scramSHA1, scramSHA256, presenceXML string
+	var presenceAt time.Time
+
+	err := scanner.Scan(&usr.Username, &usr.Domain, &usr.Password, &scramSHA1, &scramSHA256, &usr.Disabled, &presenceXML, &presenceAt)
+	if err != nil {
+		return err
+	}
+	if usr.ScramSHA1, err = parseScramCredentials(scramSHA1); err != nil {
+		return err
+	}
+	if usr.ScramSHA256, err = parseScramCredentials(scramSHA256); err != nil {
+		return err
+	}
+	if len(presenceXML) > 0 {
+		parser := xml.NewParser(strings.NewReader(presenceXML), xml.DefaultMode, 0)
+		lastPresence, err := parser.ParseElement()
+		if err != nil {
+			return err
+		}
+		fromJID, _ := jid.NewWithString(lastPresence.From(), true)
+		toJID, _ := jid.NewWithString(lastPresence.To(), true)
+		usr.LastPresence, _ = xml.NewPresenceFromElement(lastPresence, fromJID, toJID)
+		usr.LastPresenceAt = presenceAt
+	}
+	return nil
+}
+
+func scramCredentialsString(c *model.ScramCredentials) string {
+	if c == nil {
+		return ""
+	}
+	return c.String()
+}
+
+func parseScramCredentials(str string) (*model.ScramCredentials, error) {
+	if len(str) == 0 {
+		return nil, nil
+	}
+	return model.NewScramCredentialsString(str)
+}
@@ -0,0 +1,180 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pgsql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/xml"
+	"github.com/ortuman/jackal/xml/jid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPgSQLStorageInsertUser(t *testing.T) {
+	from, _ := jid.NewWithString("ortuman@jackal.im/Psi+", true)
+	to, _ := jid.NewWithString("ortuman@jackal.im", true)
+	p := xml.NewPresence(from, to, xml.UnavailableType)
+
+	user := model.User{Username: "ortuman", Domain: "jackal.im", Password: "1234", LastPresence: p}
+
+	s, mock := NewMock()
+	mock.ExpectExec("INSERT INTO users (.+) ON CONFLICT (.+)").
+		WithArgs("ortuman", "jackal.im", "1234", "", "", false, p.String()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := s.InsertOrUpdateUser(&user)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = NewMock()
+	mock.ExpectExec("INSERT INTO users (.+) ON CONFLICT (.+)").
+		WithArgs("ortuman", "jackal.im", "1234", "", "", false, p.String()).
+		WillReturnError(errPgSQLStorage)
+	err = s.InsertOrUpdateUser(&user)
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
+
+func TestPgSQLStorageDeleteUser(t *testing.T) {
+	s, mock := NewMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM offline_messages (.+)").
+		WithArgs("ortuman", "jackal.im").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM roster_items (.+)").
+		WithArgs("ortuman", "jackal.im").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM roster_versions (.+)").
+		WithArgs("ortuman", "jackal.im").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM private_storage (.+)").
+		WithArgs("ortuman", "jackal.im").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM vcards (.+)").
+		WithArgs("ortuman", "jackal.im").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM auth_tokens (.+)").
+		WithArgs("ortuman", "jackal.im").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM users (.+)").
+		WithArgs("ortuman", "jackal.im").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := s.DeleteUser("ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = NewMock()
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM offline_messages (.+)").
+		WithArgs("ortuman", "jackal.im").WillReturnError(errPgSQLStorage)
+	mock.ExpectRollback()
+
+	err = s.DeleteUser("ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
+
+func TestPgSQLStorageFetchUser(t *testing.T) {
+	from, _ := jid.NewWithString("ortuman@jackal.im/Psi+", true)
+	to, _ := jid.NewWithString("ortuman@jackal.im", true)
+	p := xml.NewPresence(from, to, xml.UnavailableType)
+
+	var userColumns = []string{"username", "domain", "password", "scram_sha1", "scram_sha256", "disabled", "last_presence", "last_presence_at"}
+
+	s, mock := NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM users (.+)").
+		WithArgs("ortuman", "jackal.im").
+		WillReturnRows(sqlmock.NewRows(userColumns))
+
+	usr, err := s.FetchUser("ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, usr)
+
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM users (.+)").
+		WithArgs("ortuman", "jackal.im").
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow("ortuman", "jackal.im", "1234", "", "", false, p.String(), time.Now()))
+	_, err = s.FetchUser("ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM users (.+)").
+		WithArgs("ortuman", "jackal.im").WillReturnError(errPgSQLStorage)
+	_, err = s.FetchUser("ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
+
+func TestPgSQLStorageFetchUsers(t *testing.T) {
+	c := &model.ScramCredentials{Salt: []byte("salt"), Iterations: 4096, StoredKey: []byte("k1"), ServerKey: []byte("k2")}
+
+	var userColumns = []string{"username", "domain", "password", "scram_sha1", "scram_sha256", "disabled", "last_presence", "last_presence_at"}
+
+	s, mock := NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM users ORDER BY domain, username").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow("noelia", "jackal.im", "", "", c.String(), true, "", time.Now()).
+			AddRow("ortuman", "jackal.im", "1234", "", "", false, "", time.Now()))
+
+	users, err := s.FetchUsers()
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, 2, len(users))
+	require.Nil(t, users[0].ScramSHA1)
+	require.Equal(t, c, users[0].ScramSHA256)
+	require.True(t, users[0].Disabled)
+	require.Equal(t, "1234", users[1].Password)
+
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM users ORDER BY domain, username").
+		WillReturnError(errPgSQLStorage)
+	_, err = s.FetchUsers()
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
+
+func TestPgSQLStorageUserExists(t *testing.T) {
+	countColums := []string{"count"}
+
+	s, mock := NewMock()
+	mock.ExpectQuery("SELECT COUNT(.+) FROM users (.+)").
+		WithArgs("ortuman", "jackal.im").
+		WillReturnRows(sqlmock.NewRows(countColums).AddRow(1))
+
+	ok, err := s.UserExists("ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT COUNT(.+) FROM users (.+)").
+		WithArgs("romeo", "jackal.im").
+		WillReturnError(errPgSQLStorage)
+	_, err = s.UserExists("romeo", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
+
+func TestPgSQLStorageCountUsers(t *testing.T) {
+	countColums := []string{"count"}
+
+	s, mock := NewMock()
+	mock.ExpectQuery("SELECT COUNT(.+) FROM users (.+)").
+		WithArgs("jackal.im").
+		WillReturnRows(sqlmock.NewRows(countColums).AddRow(2))
+
+	count, err := s.CountUsers("jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, 2, count)
+
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT COUNT(.+) FROM users (.+)").
+		WithArgs("jackal.im").
+		WillReturnError(errPgSQLStorage)
+	_, err = s.CountUsers("jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
@@ -0,0 +1,45 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pgsql
+
+import (
+	"database/sql"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/ortuman/jackal/xml"
+)
+
+// InsertOrUpdateVCard inserts a new vCard element into storage,
+// or updates it in case it's been previously inserted.
+func (s *Storage) InsertOrUpdateVCard(vCard xml.XElement, username, domain string) error {
+	rawXML := vCard.String()
+	q := psql.Insert("vcards").
+		Columns("username", "domain", "vcard", "updated_at", "created_at").
+		Values(username, domain, rawXML, nowExpr, nowExpr).
+		Suffix("ON CONFLICT (username, domain) DO UPDATE SET vcard = EXCLUDED.vcard, updated_at = NOW()")
+
+	_, err := q.RunWith(s.db).Exec()
+	return err
+}
+
+// FetchVCard retrieves from storage a vCard element associated
+// to a given user.
+func (s *Storage) FetchVCard(username, domain string) (xml.XElement, error) {
+	q := psql.Select("vcard").From("vcards").Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}})
+
+	var vCard string
+	err := q.RunWith(s.db).QueryRow().Scan(&vCard)
+	switch err {
+	case nil:
+		parser := xml.NewParser(strings.NewReader(vCard), xml.DefaultMode, 0)
+		return parser.ParseElement()
+	case sql.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pgsql
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ortuman/jackal/xml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPgSQLStorageInsertVCard(t *testing.T) {
+	vCard := xml.NewElementName("vCard")
+	rawXML := vCard.String()
+
+	s, mock := NewMock()
+	mock.ExpectExec("INSERT INTO vcards (.+) ON CONFLICT (.+)").
+		WithArgs("ortuman", "jackal.im", rawXML).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := s.InsertOrUpdateVCard(vCard, "ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.NotNil(t, vCard)
+
+	s, mock = NewMock()
+	mock.ExpectExec("INSERT INTO vcards (.+) ON CONFLICT (.+)").
+		WithArgs("ortuman", "jackal.im", rawXML).
+		WillReturnError(errPgSQLStorage)
+
+	err = s.InsertOrUpdateVCard(vCard, "ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errPgSQLStorage, err)
+}
+
+func TestPgSQLStorageFetchVCard(t *testing.T) {
+	var vCardColumns = []string{"vcard"}
+
+	s, mock := NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM vcards (.+)").
+		WithArgs("ortuman", "jackal.im").
+		WillReturnRows(sqlmock.NewRows(vCardColumns).AddRow("<vCard><FN>Miguel Ángel</FN></vCard>"))
+
+	vCard, err := s.FetchVCard("ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.NotNil(t, vCard)
+
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM vcards (.+)").
+		WithArgs("ortuman", "jackal.im").
+		WillReturnRows(sqlmock.NewRows(vCardColumns))
+
+	vCard, err = s.FetchVCard("ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Nil(t, vCard)
+
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM vcards (.+)").
+		WithArgs("ortuman", "jackal.im").
+		WillReturnError(errPgSQLStorage)
+
+	vCard, _ = s.FetchVCard("ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, vCard)
+}
@@ -0,0 +1,178 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sql
+
+import (
+	"database/sql"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/ortuman/jackal/model/mammodel"
+	"github.com/ortuman/jackal/xml"
+)
+
+// InsertArchiveMessage inserts a new message entity into user's archive.
+func (s *Storage) InsertArchiveMessage(message *mammodel.Message) error {
+	q := sq.Insert("archive_messages").
+		Columns("username", "domain", "id", "jid", "data", "created_at").
+		Values(message.Username, message.Domain, message.ID, message.JID, message.Message.String(), message.CreatedAt)
+	_, err := q.RunWith(s.db).Exec()
+	return err
+}
+
+// FetchArchiveMessages retrieves from storage, in chronological order,
+// a page of user's archived messages satisfying filter constraints.
+func (s *Storage) FetchArchiveMessages(username, domain string, filter *mammodel.Filter, page *mammodel.Page) (*mammodel.ResultSet, error) {
+	if page == nil {
+		page = &mammodel.Page{Max: -1}
+	}
+	conds := sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}}
+	if filter != nil {
+		if len(filter.With) > 0 {
+			conds = append(conds, sq.Eq{"jid": filter.With})
+		}
+		if !filter.Start.IsZero() {
+			conds = append(conds, sq.GtOrEq{"created_at": filter.Start})
+		}
+		if !filter.End.IsZero() {
+			conds = append(conds, sq.LtOrEq{"created_at": filter.End})
+		}
+	}
+	count, err := s.countArchiveMessages(conds)
+	if err != nil {
+		return nil, err
+	}
+	// resolve requested window positions, bounding it by serial
+	from, to := page.Index, count
+	if from > count {
+		from = count
+	}
+	window := sq.And{conds}
+	if len(page.After) > 0 {
+		serial, err := s.archiveMessageSerial(conds, page.After)
+		if err != nil {
+			return nil, err
+		}
+		if from, err = s.countArchiveMessages(sq.And{conds, sq.LtOrEq{"serial": serial}}); err != nil {
+			return nil, err
+		}
+		window = append(window, sq.Gt{"serial": serial})
+	}
+	if len(page.Before) > 0 {
+		serial, err := s.archiveMessageSerial(conds, page.Before)
+		if err != nil {
+			return nil, err
+		}
+		if to, err = s.countArchiveMessages(sq.And{conds, sq.Lt{"serial": serial}}); err != nil {
+			return nil, err
+		}
+		window = append(window, sq.Lt{"serial": serial})
+	}
+	from, to = page.Range(from, to)
+
+	rs := &mammodel.ResultSet{FirstIndex: from, Count: count}
+	if to == from {
+		return rs, nil
+	}
+	q := sq.Select("id", "username", "domain", "jid", "data", "created_at").
+		From("archive_messages").
+		Where(window).
+		Limit(uint64(to - from))
+	if page.Backwards() {
+		q = q.OrderBy("serial DESC")
+	} else {
+		q = q.OrderBy("serial")
+		if len(page.After) == 0 && from > 0 {
+			q = q.Offset(uint64(from))
+		}
+	}
+	rows, err := q.RunWith(s.db).Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var msg mammodel.Message
+		var data string
+		if err := rows.Scan(&msg.ID, &msg.Username, &msg.Domain, &msg.JID, &data, &msg.CreatedAt); err != nil {
+			return nil, err
+		}
+		parser := xml.NewParser(strings.NewReader(data), xml.DefaultMode, 0)
+		if msg.Message, err = parser.ParseElement(); err != nil {
+			return nil, err
+		}
+		rs.Messages = append(rs.Messages, msg)
+	}
+	if page.Backwards() {
+		// restore chronological order
+		for i, j := 0, len(rs.Messages)-1; i < j; i, j = i+1, j-1 {
+			rs.Messages[i], rs.Messages[j] = rs.Messages[j], rs.Messages[i]
+		}
+	}
+	return rs, nil
+}
+
+func (s *Storage) countArchiveMessages(conds sq.Sqlizer) (int, error) {
+	var count int
+	q := sq.Select("COUNT(*)").From("archive_messages").Where(conds)
+	if err := q.RunWith(s.db).QueryRow().Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (s *Storage) archiveMessageSerial(conds sq.Sqlizer, id string) (int64, error) {
+	var serial int64
+	q := sq.Select("serial").From("archive_messages").Where(sq.And{conds, sq.Eq{"id": id}})
+	err := q.RunWith(s.db).QueryRow().Scan(&serial)
+	switch err {
+	case nil:
+		return serial, nil
+	case sql.ErrNoRows:
+		return 0, mammodel.ErrItemNotFound
+	default:
+		return 0, err
+	}
+}
+
+// InsertOrUpdateArchivePrefs inserts a new archiving preferences entity
+// into storage, or updates it in case it's been previously inserted.
+func (s *Storage) InsertOrUpdateArchivePrefs(prefs *mammodel.Prefs) error {
+	always := strings.Join(prefs.Always, ";")
+	never := strings.Join(prefs.Never, ";")
+	q := sq.Insert("archive_prefs").
+		Columns("username", "domain", "default_mode", "always", "never", "updated_at", "created_at").
+		Values(prefs.Username, prefs.Domain, prefs.Default, always, never, nowExpr, nowExpr).
+		Suffix("ON DUPLICATE KEY UPDATE default_mode = ?, always = ?, never = ?, updated_at = NOW()", prefs.Default, always, never)
+	_, err := q.RunWith(s.db).Exec()
+	return err
+}
+
+// FetchArchivePrefs retrieves from storage user's archiving preferences.
+func (s *Storage) FetchArchivePrefs(username, domain string) (*mammodel.Prefs, error) {
+	q := sq.Select("username", "domain", "default_mode", "always", "never").
+		From("archive_prefs").
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}})
+
+	var prefs mammodel.Prefs
+	var always, never string
+	err := q.RunWith(s.db).QueryRow().Scan(&prefs.Username, &prefs.Domain, &prefs.Default, &always, &never)
+	switch err {
+	case nil:
+		if len(always) > 0 {
+			prefs.Always = strings.Split(always, ";")
+		}
+		if len(never) > 0 {
+			prefs.Never = strings.Split(never, ";")
+		}
+		return &prefs, nil
+	case sql.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
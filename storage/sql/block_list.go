@@ -19,8 +19,8 @@ func (s *Storage) InsertBlockListItems(items []model.BlockListItem) error {
 		for _, item := range items {
 			_, err := sq.Insert("blocklist_items").
 				Options("IGNORE").
-				Columns("username", "jid", "created_at").
-				Values(item.Username, item.JID, nowExpr).
+				Columns("username", "domain", "jid", "created_at").
+				Values(item.Username, item.Domain, item.JID, nowExpr).
 				RunWith(tx).Exec()
 			if err != nil {
 				return err
@@ -35,7 +35,7 @@ func (s *Storage) DeleteBlockListItems(items []model.BlockListItem) error {
 	return s.inTransaction(func(tx *sql.Tx) error {
 		for _, item := range items {
 			_, err := sq.Delete("blocklist_items").
-				Where(sq.And{sq.Eq{"username": item.Username}, sq.Eq{"jid": item.JID}}).
+				Where(sq.And{sq.Eq{"username": item.Username}, sq.Eq{"domain": item.Domain}, sq.Eq{"jid": item.JID}}).
 				RunWith(tx).Exec()
 			if err != nil {
 				return err
@@ -47,10 +47,10 @@ func (s *Storage) DeleteBlockListItems(items []model.BlockListItem) error {
 
 // FetchBlockListItems retrieves from storage all block list item entities
 // associated to a given user.
-func (s *Storage) FetchBlockListItems(username string) ([]model.BlockListItem, error) {
-	q := sq.Select("username", "jid").
+func (s *Storage) FetchBlockListItems(username, domain string) ([]model.BlockListItem, error) {
+	q := sq.Select("username", "domain", "jid").
 		From("blocklist_items").
-		Where(sq.Eq{"username": username}).
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}}).
 		OrderBy("created_at")
 
 	rows, err := q.RunWith(s.db).Query()
@@ -65,7 +65,7 @@ func (s *Storage) scanBlockListItemEntities(scanner rowsScanner) ([]model.BlockL
 	var ret []model.BlockListItem
 	for scanner.Next() {
 		var it model.BlockListItem
-		scanner.Scan(&it.Username, &it.JID)
+		scanner.Scan(&it.Username, &it.Domain, &it.JID)
 		ret = append(ret, it)
 	}
 	return ret, nil
@@ -20,7 +20,7 @@ func TestMySQLStorageInsertBlockListItems(t *testing.T) {
 		WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectCommit()
 
-	err := s.InsertBlockListItems([]model.BlockListItem{{"ortuman", "noelia@jackal.im"}})
+	err := s.InsertBlockListItems([]model.BlockListItem{{"ortuman", "jackal.im", "noelia@jackal.im"}})
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 
@@ -29,28 +29,28 @@ func TestMySQLStorageInsertBlockListItems(t *testing.T) {
 	mock.ExpectExec("INSERT IGNORE INTO blocklist_items (.+)").WillReturnError(errMySQLStorage)
 	mock.ExpectRollback()
 
-	err = s.InsertBlockListItems([]model.BlockListItem{{"ortuman", "noelia@jackal.im"}})
+	err = s.InsertBlockListItems([]model.BlockListItem{{"ortuman", "jackal.im", "noelia@jackal.im"}})
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, errMySQLStorage, err)
 }
 
 func TestMySQLFetchBlockListItems(t *testing.T) {
-	var blockListColumns = []string{"username", "jid"}
+	var blockListColumns = []string{"username", "domain", "jid"}
 	s, mock := NewMock()
 	mock.ExpectQuery("SELECT (.+) FROM blocklist_items (.+)").
-		WithArgs("ortuman").
-		WillReturnRows(sqlmock.NewRows(blockListColumns).AddRow("ortuman", "noelia@jackal.im"))
+		WithArgs("ortuman", "jackal.im").
+		WillReturnRows(sqlmock.NewRows(blockListColumns).AddRow("ortuman", "jackal.im", "noelia@jackal.im"))
 
-	_, err := s.FetchBlockListItems("ortuman")
+	_, err := s.FetchBlockListItems("ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 
 	s, mock = NewMock()
 	mock.ExpectQuery("SELECT (.+) FROM blocklist_items (.+)").
-		WithArgs("ortuman").
+		WithArgs("ortuman", "jackal.im").
 		WillReturnError(errMySQLStorage)
 
-	_, err = s.FetchBlockListItems("ortuman")
+	_, err = s.FetchBlockListItems("ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, errMySQLStorage, err)
 }
@@ -59,18 +59,18 @@ func TestMySQLStorageDeleteBlockListItems(t *testing.T) {
 	s, mock := NewMock()
 	mock.ExpectBegin()
 	mock.ExpectExec("DELETE FROM blocklist_items (.+)").
-		WithArgs("ortuman").
+		WithArgs("ortuman", "jackal.im").
 		WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectCommit()
 
 	s, mock = NewMock()
 	mock.ExpectBegin()
 	mock.ExpectExec("DELETE FROM blocklist_items (.+)").
-		WithArgs("ortuman", "noelia@jackal.im").
+		WithArgs("ortuman", "jackal.im", "noelia@jackal.im").
 		WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectCommit()
 
-	delItems := []model.BlockListItem{{"ortuman", "noelia@jackal.im"}}
+	delItems := []model.BlockListItem{{"ortuman", "jackal.im", "noelia@jackal.im"}}
 	err := s.DeleteBlockListItems(delItems)
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
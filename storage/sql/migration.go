@@ -0,0 +1,54 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sql
+
+import (
+	"database/sql"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// domainTables contains every user table keeping track of its domain.
+var domainTables = []string{
+	"roster_items",
+	"roster_versions",
+	"roster_notifications",
+	"offline_messages",
+	"private_storage",
+	"vcards",
+	"blocklist_items",
+	"archive_messages",
+	"archive_prefs",
+	"push_registrations",
+	"privacy_lists",
+	"privacy_list_items",
+}
+
+// MigrateDomain associates every stored entity not yet bound to a domain
+// to the given one, returning the number of migrated users.
+func (s *Storage) MigrateDomain(domain string) (int, error) {
+	var count int64
+	err := s.inTransaction(func(tx *sql.Tx) error {
+		res, err := sq.Update("users").Set("domain", domain).Where(sq.Eq{"domain": ""}).RunWith(tx).Exec()
+		if err != nil {
+			return err
+		}
+		if count, err = res.RowsAffected(); err != nil {
+			return err
+		}
+		for _, table := range domainTables {
+			_, err := sq.Update(table).Set("domain", domain).Where(sq.Eq{"domain": ""}).RunWith(tx).Exec()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int(count), nil
+}
@@ -0,0 +1,157 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sql
+
+import (
+	"database/sql"
+	"sort"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/ortuman/jackal/model/mucmodel"
+)
+
+var roomColumns = []string{
+	"jid", "name", "description", "subject",
+	"public", "persistent", "members_only", "moderated", "non_anonymous",
+	"change_subject", "allow_invites", "password", "max_users",
+}
+
+// InsertOrUpdateRoom inserts a new room entity into storage,
+// or updates it in case it's been previously inserted.
+func (s *Storage) InsertOrUpdateRoom(room *mucmodel.Room) error {
+	c := &room.Config
+	return s.inTransaction(func(tx *sql.Tx) error {
+		q := sq.Insert("muc_rooms").
+			Columns("jid", "service", "name", "description", "subject",
+				"public", "persistent", "members_only", "moderated", "non_anonymous",
+				"change_subject", "allow_invites", "password", "max_users", "updated_at", "created_at").
+			Values(room.JID, room.RoomJID().Domain(), room.Name, room.Description, room.Subject,
+				c.Public, c.Persistent, c.MembersOnly, c.Moderated, c.NonAnonymous,
+				c.ChangeSubject, c.AllowInvites, c.Password, c.MaxUsers, nowExpr, nowExpr).
+			Suffix("ON DUPLICATE KEY UPDATE name = ?, description = ?, subject = ?, "+
+				"public = ?, persistent = ?, members_only = ?, moderated = ?, non_anonymous = ?, "+
+				"change_subject = ?, allow_invites = ?, password = ?, max_users = ?, updated_at = NOW()",
+				room.Name, room.Description, room.Subject,
+				c.Public, c.Persistent, c.MembersOnly, c.Moderated, c.NonAnonymous,
+				c.ChangeSubject, c.AllowInvites, c.Password, c.MaxUsers)
+
+		if _, err := q.RunWith(tx).Exec(); err != nil {
+			return err
+		}
+		_, err := sq.Delete("muc_room_affiliations").Where(sq.Eq{"room_jid": room.JID}).RunWith(tx).Exec()
+		if err != nil {
+			return err
+		}
+		// insert affiliations in a deterministic order
+		jids := make([]string, 0, len(room.Affiliations))
+		for j := range room.Affiliations {
+			jids = append(jids, j)
+		}
+		sort.Strings(jids)
+
+		for _, j := range jids {
+			_, err := sq.Insert("muc_room_affiliations").
+				Columns("room_jid", "jid", "affiliation", "created_at").
+				Values(room.JID, j, room.Affiliations[j], nowExpr).
+				RunWith(tx).Exec()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteRoom deletes a room entity from storage.
+func (s *Storage) DeleteRoom(roomJID string) error {
+	return s.inTransaction(func(tx *sql.Tx) error {
+		_, err := sq.Delete("muc_room_affiliations").Where(sq.Eq{"room_jid": roomJID}).RunWith(tx).Exec()
+		if err != nil {
+			return err
+		}
+		_, err = sq.Delete("muc_rooms").Where(sq.Eq{"jid": roomJID}).RunWith(tx).Exec()
+		return err
+	})
+}
+
+// FetchRoom retrieves from storage a room entity.
+func (s *Storage) FetchRoom(roomJID string) (*mucmodel.Room, error) {
+	q := sq.Select(roomColumns...).
+		From("muc_rooms").
+		Where(sq.Eq{"jid": roomJID})
+
+	var room mucmodel.Room
+	err := s.scanRoomEntity(&room, q.RunWith(s.db).QueryRow())
+	switch err {
+	case nil:
+		if err := s.fetchRoomAffiliations(&room); err != nil {
+			return nil, err
+		}
+		return &room, nil
+	case sql.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+// FetchRooms retrieves from storage all rooms associated to a MUC service domain.
+func (s *Storage) FetchRooms(service string) ([]mucmodel.Room, error) {
+	q := sq.Select(roomColumns...).
+		From("muc_rooms").
+		Where(sq.Eq{"service": service}).
+		OrderBy("created_at")
+
+	rows, err := q.RunWith(s.db).Query()
+	if err != nil {
+		return nil, err
+	}
+	var rooms []mucmodel.Room
+	for rows.Next() {
+		var room mucmodel.Room
+		if err := s.scanRoomEntity(&room, rows); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		rooms = append(rooms, room)
+	}
+	rows.Close()
+
+	for i := 0; i < len(rooms); i++ {
+		if err := s.fetchRoomAffiliations(&rooms[i]); err != nil {
+			return nil, err
+		}
+	}
+	return rooms, nil
+}
+
+func (s *Storage) fetchRoomAffiliations(room *mucmodel.Room) error {
+	q := sq.Select("jid", "affiliation").
+		From("muc_room_affiliations").
+		Where(sq.Eq{"room_jid": room.JID})
+
+	rows, err := q.RunWith(s.db).Query()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var j, affiliation string
+		if err := rows.Scan(&j, &affiliation); err != nil {
+			return err
+		}
+		room.SetAffiliation(j, affiliation)
+	}
+	return nil
+}
+
+func (s *Storage) scanRoomEntity(room *mucmodel.Room, scanner rowScanner) error {
+	c := &room.Config
+	return scanner.Scan(&room.JID, &room.Name, &room.Description, &room.Subject,
+		&c.Public, &c.Persistent, &c.MembersOnly, &c.Moderated, &c.NonAnonymous,
+		&c.ChangeSubject, &c.AllowInvites, &c.Password, &c.MaxUsers)
+}
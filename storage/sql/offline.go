@@ -12,19 +12,19 @@ import (
 
 // InsertOfflineMessage inserts a new message element into
 // user's offline queue.
-func (s *Storage) InsertOfflineMessage(message xml.XElement, username string) error {
+func (s *Storage) InsertOfflineMessage(message xml.XElement, username, domain string) error {
 	q := sq.Insert("offline_messages").
-		Columns("username", "data", "created_at").
-		Values(username, message.String(), nowExpr)
+		Columns("username", "domain", "data", "created_at").
+		Values(username, domain, message.String(), nowExpr)
 	_, err := q.RunWith(s.db).Exec()
 	return err
 }
 
 // CountOfflineMessages returns current length of user's offline queue.
-func (s *Storage) CountOfflineMessages(username string) (int, error) {
+func (s *Storage) CountOfflineMessages(username, domain string) (int, error) {
 	q := sq.Select("COUNT(*)").
 		From("offline_messages").
-		Where(sq.Eq{"username": username}).
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}}).
 		OrderBy("created_at")
 
 	var count int
@@ -38,10 +38,10 @@ func (s *Storage) CountOfflineMessages(username string) (int, error) {
 }
 
 // FetchOfflineMessages retrieves from storage current user offline queue.
-func (s *Storage) FetchOfflineMessages(username string) ([]xml.XElement, error) {
+func (s *Storage) FetchOfflineMessages(username, domain string) ([]xml.XElement, error) {
 	q := sq.Select("data").
 		From("offline_messages").
-		Where(sq.Eq{"username": username}).
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}}).
 		OrderBy("created_at")
 
 	rows, err := q.RunWith(s.db).Query()
@@ -70,8 +70,8 @@ func (s *Storage) FetchOfflineMessages(username string) ([]xml.XElement, error)
 }
 
 // DeleteOfflineMessages clears a user offline queue.
-func (s *Storage) DeleteOfflineMessages(username string) error {
-	q := sq.Delete("offline_messages").Where(sq.Eq{"username": username})
+func (s *Storage) DeleteOfflineMessages(username, domain string) error {
+	q := sq.Delete("offline_messages").Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}})
 	_, err := q.RunWith(s.db).Exec()
 	return err
 }
@@ -25,19 +25,19 @@ func TestMySQLStorageInsertOfflineMessages(t *testing.T) {
 
 	s, mock := NewMock()
 	mock.ExpectExec("INSERT INTO offline_messages (.+)").
-		WithArgs("ortuman", messageXML).
+		WithArgs("ortuman", "jackal.im", messageXML).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	err := s.InsertOfflineMessage(m, "ortuman")
+	err := s.InsertOfflineMessage(m, "ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 
 	s, mock = NewMock()
 	mock.ExpectExec("INSERT INTO offline_messages (.+)").
-		WithArgs("ortuman", messageXML).
+		WithArgs("ortuman", "jackal.im", messageXML).
 		WillReturnError(errMySQLStorage)
 
-	err = s.InsertOfflineMessage(m, "ortuman")
+	err = s.InsertOfflineMessage(m, "ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.NotNil(t, err)
 }
@@ -47,28 +47,28 @@ func TestMySQLStorageCountOfflineMessages(t *testing.T) {
 
 	s, mock := NewMock()
 	mock.ExpectQuery("SELECT COUNT(.+) FROM offline_messages (.+)").
-		WithArgs("ortuman").
+		WithArgs("ortuman", "jackal.im").
 		WillReturnRows(sqlmock.NewRows(countColums).AddRow(1))
 
-	cnt, _ := s.CountOfflineMessages("ortuman")
+	cnt, _ := s.CountOfflineMessages("ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, 1, cnt)
 
 	s, mock = NewMock()
 	mock.ExpectQuery("SELECT COUNT(.+) FROM offline_messages (.+)").
-		WithArgs("ortuman").
+		WithArgs("ortuman", "jackal.im").
 		WillReturnRows(sqlmock.NewRows(countColums))
 
-	cnt, _ = s.CountOfflineMessages("ortuman")
+	cnt, _ = s.CountOfflineMessages("ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, 0, cnt)
 
 	s, mock = NewMock()
 	mock.ExpectQuery("SELECT COUNT(.+) FROM offline_messages (.+)").
-		WithArgs("ortuman").
+		WithArgs("ortuman", "jackal.im").
 		WillReturnError(errMySQLStorage)
 
-	_, err := s.CountOfflineMessages("ortuman")
+	_, err := s.CountOfflineMessages("ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, errMySQLStorage, err)
 }
@@ -78,37 +78,37 @@ func TestMySQLStorageFetchOfflineMessages(t *testing.T) {
 
 	s, mock := NewMock()
 	mock.ExpectQuery("SELECT (.+) FROM offline_messages (.+)").
-		WithArgs("ortuman").
+		WithArgs("ortuman", "jackal.im").
 		WillReturnRows(sqlmock.NewRows(offlineMessagesColumns).AddRow("<message id='abc'><body>Hi!</body></message>"))
 
-	msgs, _ := s.FetchOfflineMessages("ortuman")
+	msgs, _ := s.FetchOfflineMessages("ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, 1, len(msgs))
 
 	s, mock = NewMock()
 	mock.ExpectQuery("SELECT (.+) FROM offline_messages (.+)").
-		WithArgs("ortuman").
+		WithArgs("ortuman", "jackal.im").
 		WillReturnRows(sqlmock.NewRows(offlineMessagesColumns))
 
-	msgs, _ = s.FetchOfflineMessages("ortuman")
+	msgs, _ = s.FetchOfflineMessages("ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, 0, len(msgs))
 
 	s, mock = NewMock()
 	mock.ExpectQuery("SELECT (.+) FROM offline_messages (.+)").
-		WithArgs("ortuman").
+		WithArgs("ortuman", "jackal.im").
 		WillReturnRows(sqlmock.NewRows(offlineMessagesColumns).AddRow("<message id='abc'><body>Hi!"))
 
-	_, err := s.FetchOfflineMessages("ortuman")
+	_, err := s.FetchOfflineMessages("ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.NotNil(t, err)
 
 	s, mock = NewMock()
 	mock.ExpectQuery("SELECT (.+) FROM offline_messages (.+)").
-		WithArgs("ortuman").
+		WithArgs("ortuman", "jackal.im").
 		WillReturnError(errMySQLStorage)
 
-	_, err = s.FetchOfflineMessages("ortuman")
+	_, err = s.FetchOfflineMessages("ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, errMySQLStorage, err)
 }
@@ -116,17 +116,17 @@ func TestMySQLStorageFetchOfflineMessages(t *testing.T) {
 func TestMySQLStorageDeleteOfflineMessages(t *testing.T) {
 	s, mock := NewMock()
 	mock.ExpectExec("DELETE FROM offline_messages (.+)").
-		WithArgs("ortuman").WillReturnResult(sqlmock.NewResult(0, 1))
+		WithArgs("ortuman", "jackal.im").WillReturnResult(sqlmock.NewResult(0, 1))
 
-	err := s.DeleteOfflineMessages("ortuman")
+	err := s.DeleteOfflineMessages("ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 
 	s, mock = NewMock()
 	mock.ExpectExec("DELETE FROM offline_messages (.+)").
-		WithArgs("ortuman").WillReturnError(errMySQLStorage)
+		WithArgs("ortuman", "jackal.im").WillReturnError(errMySQLStorage)
 
-	err = s.DeleteOfflineMessages("ortuman")
+	err = s.DeleteOfflineMessages("ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, errMySQLStorage, err)
 }
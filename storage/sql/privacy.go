@@ -0,0 +1,123 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sql
+
+import (
+	"database/sql"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/ortuman/jackal/model/privacymodel"
+)
+
+// InsertOrUpdatePrivacyList inserts a new privacy list entity into storage,
+// or updates it in case it's been previously inserted.
+func (s *Storage) InsertOrUpdatePrivacyList(list *privacymodel.List) error {
+	return s.inTransaction(func(tx *sql.Tx) error {
+		_, err := sq.Insert("privacy_lists").
+			Columns("username", "domain", "name", "is_default", "updated_at", "created_at").
+			Values(list.Username, list.Domain, list.Name, list.Default, nowExpr, nowExpr).
+			Suffix("ON DUPLICATE KEY UPDATE is_default = ?, updated_at = NOW()", list.Default).
+			RunWith(tx).Exec()
+		if err != nil {
+			return err
+		}
+		listCond := sq.And{sq.Eq{"username": list.Username}, sq.Eq{"domain": list.Domain}, sq.Eq{"list_name": list.Name}}
+		if _, err := sq.Delete("privacy_list_items").Where(listCond).RunWith(tx).Exec(); err != nil {
+			return err
+		}
+		for _, it := range list.Items {
+			_, err := sq.Insert("privacy_list_items").
+				Columns("username", "domain", "list_name", "ord", "type", "value", "action", "stanzas").
+				Values(list.Username, list.Domain, list.Name, it.Order, it.Type, it.Value, it.Action, strings.Join(it.Stanzas, ",")).
+				RunWith(tx).Exec()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeletePrivacyList deletes a privacy list entity from storage.
+func (s *Storage) DeletePrivacyList(username, domain, name string) error {
+	return s.inTransaction(func(tx *sql.Tx) error {
+		_, err := sq.Delete("privacy_list_items").
+			Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}, sq.Eq{"list_name": name}}).
+			RunWith(tx).Exec()
+		if err != nil {
+			return err
+		}
+		_, err = sq.Delete("privacy_lists").
+			Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}, sq.Eq{"name": name}}).
+			RunWith(tx).Exec()
+		return err
+	})
+}
+
+// FetchPrivacyLists retrieves from storage all privacy list entities
+// associated to a given user.
+func (s *Storage) FetchPrivacyLists(username, domain string) ([]privacymodel.List, error) {
+	rows, err := sq.Select("username", "domain", "name", "is_default").
+		From("privacy_lists").
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}}).
+		OrderBy("created_at").
+		RunWith(s.db).Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lists []privacymodel.List
+	for rows.Next() {
+		var l privacymodel.List
+		if err := rows.Scan(&l.Username, &l.Domain, &l.Name, &l.Default); err != nil {
+			return nil, err
+		}
+		lists = append(lists, l)
+	}
+	if len(lists) == 0 {
+		return nil, nil
+	}
+	itemRows, err := sq.Select("list_name", "ord", "type", "value", "action", "stanzas").
+		From("privacy_list_items").
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}}).
+		OrderBy("list_name", "ord").
+		RunWith(s.db).Query()
+	if err != nil {
+		return nil, err
+	}
+	defer itemRows.Close()
+
+	for itemRows.Next() {
+		var listName, stanzas string
+		var it privacymodel.Item
+		if err := itemRows.Scan(&listName, &it.Order, &it.Type, &it.Value, &it.Action, &stanzas); err != nil {
+			return nil, err
+		}
+		if len(stanzas) > 0 {
+			it.Stanzas = strings.Split(stanzas, ",")
+		}
+		for i := range lists {
+			if lists[i].Name == listName {
+				lists[i].Items = append(lists[i].Items, it)
+				break
+			}
+		}
+	}
+	return lists, nil
+}
+
+// SetDefaultPrivacyList marks a user privacy list as the default one,
+// unmarking any previous one. An empty name just declines
+// the use of a default list.
+func (s *Storage) SetDefaultPrivacyList(username, domain, name string) error {
+	_, err := sq.Update("privacy_lists").
+		Set("is_default", sq.Expr("name = ?", name)).
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}}).
+		RunWith(s.db).Exec()
+	return err
+}
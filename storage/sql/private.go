@@ -14,7 +14,7 @@ import (
 
 // InsertOrUpdatePrivateXML inserts a new private element into storage,
 // or updates it in case it's been previously inserted.
-func (s *Storage) InsertOrUpdatePrivateXML(privateXML []xml.XElement, namespace string, username string) error {
+func (s *Storage) InsertOrUpdatePrivateXML(privateXML []xml.XElement, namespace, username, domain string) error {
 	buf := s.pool.Get()
 	defer s.pool.Put(buf)
 	for _, elem := range privateXML {
@@ -23,8 +23,8 @@ func (s *Storage) InsertOrUpdatePrivateXML(privateXML []xml.XElement, namespace
 	rawXML := buf.String()
 
 	q := sq.Insert("private_storage").
-		Columns("username", "namespace", "data", "updated_at", "created_at").
-		Values(username, namespace, rawXML, nowExpr, nowExpr).
+		Columns("username", "domain", "namespace", "data", "updated_at", "created_at").
+		Values(username, domain, namespace, rawXML, nowExpr, nowExpr).
 		Suffix("ON DUPLICATE KEY UPDATE data = ?, updated_at = NOW()", rawXML)
 
 	_, err := q.RunWith(s.db).Exec()
@@ -32,10 +32,10 @@ func (s *Storage) InsertOrUpdatePrivateXML(privateXML []xml.XElement, namespace
 }
 
 // FetchPrivateXML retrieves from storage a private element.
-func (s *Storage) FetchPrivateXML(namespace string, username string) ([]xml.XElement, error) {
+func (s *Storage) FetchPrivateXML(namespace, username, domain string) ([]xml.XElement, error) {
 	q := sq.Select("data").
 		From("private_storage").
-		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"namespace": namespace}})
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}, sq.Eq{"namespace": namespace}})
 
 	var privateXML string
 	err := q.RunWith(s.db).QueryRow().Scan(&privateXML)
@@ -19,19 +19,19 @@ func TestMySQLStorageInsertPrivateXML(t *testing.T) {
 
 	s, mock := NewMock()
 	mock.ExpectExec("INSERT INTO private_storage (.+) ON DUPLICATE KEY UPDATE (.+)").
-		WithArgs("ortuman", "exodus:ns", rawXML, rawXML).
+		WithArgs("ortuman", "jackal.im", "exodus:ns", rawXML, rawXML).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	err := s.InsertOrUpdatePrivateXML([]xml.XElement{private}, "exodus:ns", "ortuman")
+	err := s.InsertOrUpdatePrivateXML([]xml.XElement{private}, "exodus:ns", "ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 
 	s, mock = NewMock()
 	mock.ExpectExec("INSERT INTO private_storage (.+) ON DUPLICATE KEY UPDATE (.+)").
-		WithArgs("ortuman", "exodus:ns", rawXML, rawXML).
+		WithArgs("ortuman", "jackal.im", "exodus:ns", rawXML, rawXML).
 		WillReturnError(errMySQLStorage)
 
-	err = s.InsertOrUpdatePrivateXML([]xml.XElement{private}, "exodus:ns", "ortuman")
+	err = s.InsertOrUpdatePrivateXML([]xml.XElement{private}, "exodus:ns", "ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, errMySQLStorage, err)
 }
@@ -41,50 +41,50 @@ func TestMySQLStorageFetchPrivateXML(t *testing.T) {
 
 	s, mock := NewMock()
 	mock.ExpectQuery("SELECT (.+) FROM private_storage (.+)").
-		WithArgs("ortuman", "exodus:ns").
+		WithArgs("ortuman", "jackal.im", "exodus:ns").
 		WillReturnRows(sqlmock.NewRows(privateColumns).AddRow("<exodus xmlns='exodus:ns'><stuff/></exodus>"))
 
-	elems, err := s.FetchPrivateXML("exodus:ns", "ortuman")
+	elems, err := s.FetchPrivateXML("exodus:ns", "ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 	require.Equal(t, 1, len(elems))
 
 	s, mock = NewMock()
 	mock.ExpectQuery("SELECT (.+) FROM private_storage (.+)").
-		WithArgs("ortuman", "exodus:ns").
+		WithArgs("ortuman", "jackal.im", "exodus:ns").
 		WillReturnRows(sqlmock.NewRows(privateColumns).AddRow("<exodus xmlns='exodus:ns'><stuff/>"))
 
-	elems, err = s.FetchPrivateXML("exodus:ns", "ortuman")
+	elems, err = s.FetchPrivateXML("exodus:ns", "ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.NotNil(t, err)
 	require.Equal(t, 0, len(elems))
 
 	s, mock = NewMock()
 	mock.ExpectQuery("SELECT (.+) FROM private_storage (.+)").
-		WithArgs("ortuman", "exodus:ns").
+		WithArgs("ortuman", "jackal.im", "exodus:ns").
 		WillReturnRows(sqlmock.NewRows(privateColumns).AddRow(""))
 
-	elems, err = s.FetchPrivateXML("exodus:ns", "ortuman")
+	elems, err = s.FetchPrivateXML("exodus:ns", "ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 	require.Equal(t, 0, len(elems))
 
 	s, mock = NewMock()
 	mock.ExpectQuery("SELECT (.+) FROM private_storage (.+)").
-		WithArgs("ortuman", "exodus:ns").
+		WithArgs("ortuman", "jackal.im", "exodus:ns").
 		WillReturnRows(sqlmock.NewRows(privateColumns))
 
-	elems, err = s.FetchPrivateXML("exodus:ns", "ortuman")
+	elems, err = s.FetchPrivateXML("exodus:ns", "ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 	require.Equal(t, 0, len(elems))
 
 	s, mock = NewMock()
 	mock.ExpectQuery("SELECT (.+) FROM private_storage (.+)").
-		WithArgs("ortuman", "exodus:ns").
+		WithArgs("ortuman", "jackal.im", "exodus:ns").
 		WillReturnError(errMySQLStorage)
 
-	elems, err = s.FetchPrivateXML("exodus:ns", "ortuman")
+	elems, err = s.FetchPrivateXML("exodus:ns", "ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, errMySQLStorage, err)
 	require.Equal(t, 0, len(elems))
@@ -0,0 +1,73 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sql
+
+import (
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/xml"
+)
+
+// InsertOrUpdatePushRegistration inserts a new push registration entity
+// into storage, or updates it in case it's been previously inserted.
+func (s *Storage) InsertOrUpdatePushRegistration(reg *model.PushRegistration) error {
+	var options string
+	if reg.Options != nil {
+		options = reg.Options.String()
+	}
+	_, err := sq.Insert("push_registrations").
+		Columns("username", "domain", "jid", "node", "options", "updated_at", "created_at").
+		Values(reg.Username, reg.Domain, reg.JID, reg.Node, options, nowExpr, nowExpr).
+		Suffix("ON DUPLICATE KEY UPDATE options = ?, updated_at = NOW()", options).
+		RunWith(s.db).Exec()
+	return err
+}
+
+// DeletePushRegistrations deletes from storage a user push registration
+// entity. In case node is empty every registration associated to
+// the app server jid will be deleted.
+func (s *Storage) DeletePushRegistrations(username, domain, jid, node string) error {
+	cond := sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}, sq.Eq{"jid": jid}}
+	if len(node) > 0 {
+		cond = append(cond, sq.Eq{"node": node})
+	}
+	_, err := sq.Delete("push_registrations").Where(cond).RunWith(s.db).Exec()
+	return err
+}
+
+// FetchPushRegistrations retrieves from storage all push registration
+// entities associated to a given user.
+func (s *Storage) FetchPushRegistrations(username, domain string) ([]model.PushRegistration, error) {
+	q := sq.Select("username", "domain", "jid", "node", "options").
+		From("push_registrations").
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}}).
+		OrderBy("created_at")
+
+	rows, err := q.RunWith(s.db).Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ret []model.PushRegistration
+	for rows.Next() {
+		var reg model.PushRegistration
+		var options string
+		if err := rows.Scan(&reg.Username, &reg.Domain, &reg.JID, &reg.Node, &options); err != nil {
+			return nil, err
+		}
+		if len(options) > 0 {
+			parser := xml.NewParser(strings.NewReader(options), xml.DefaultMode, 0)
+			if reg.Options, err = parser.ParseElement(); err != nil {
+				return nil, err
+			}
+		}
+		ret = append(ret, reg)
+	}
+	return ret, nil
+}
@@ -20,8 +20,8 @@ import (
 func (s *Storage) InsertOrUpdateRosterItem(ri *rostermodel.Item) (rostermodel.Version, error) {
 	err := s.inTransaction(func(tx *sql.Tx) error {
 		q := sq.Insert("roster_versions").
-			Columns("username", "created_at", "updated_at").
-			Values(ri.Username, nowExpr, nowExpr).
+			Columns("username", "domain", "created_at", "updated_at").
+			Values(ri.Username, ri.Domain, nowExpr, nowExpr).
 			Suffix("ON DUPLICATE KEY UPDATE ver = ver + 1, updated_at = NOW()")
 
 		if _, err := q.RunWith(tx).Exec(); err != nil {
@@ -29,10 +29,10 @@ func (s *Storage) InsertOrUpdateRosterItem(ri *rostermodel.Item) (rostermodel.Ve
 		}
 		groups := strings.Join(ri.Groups, ";")
 
-		verExpr := sq.Expr("(SELECT ver FROM roster_versions WHERE username = ?)", ri.Username)
+		verExpr := sq.Expr("(SELECT ver FROM roster_versions WHERE username = ? AND domain = ?)", ri.Username, ri.Domain)
 		q = sq.Insert("roster_items").
-			Columns("username", "jid", "name", "subscription", "`groups`", "ask", "ver", "created_at", "updated_at").
-			Values(ri.Username, ri.JID, ri.Name, ri.Subscription, groups, ri.Ask, verExpr, nowExpr, nowExpr).
+			Columns("username", "domain", "jid", "name", "subscription", "`groups`", "ask", "ver", "created_at", "updated_at").
+			Values(ri.Username, ri.Domain, ri.JID, ri.Name, ri.Subscription, groups, ri.Ask, verExpr, nowExpr, nowExpr).
 			Suffix("ON DUPLICATE KEY UPDATE name = ?, subscription = ?, `groups` = ?, ask = ?, ver = ver + 1, updated_at = NOW()", ri.Name, ri.Subscription, groups, ri.Ask)
 
 		_, err := q.RunWith(tx).Exec()
@@ -41,37 +41,37 @@ func (s *Storage) InsertOrUpdateRosterItem(ri *rostermodel.Item) (rostermodel.Ve
 	if err != nil {
 		return rostermodel.Version{}, err
 	}
-	return s.fetchRosterVer(ri.Username)
+	return s.fetchRosterVer(ri.Username, ri.Domain)
 }
 
 // DeleteRosterItem deletes a roster item entity from storage.
-func (s *Storage) DeleteRosterItem(username, jid string) (rostermodel.Version, error) {
+func (s *Storage) DeleteRosterItem(username, domain, jid string) (rostermodel.Version, error) {
 	err := s.inTransaction(func(tx *sql.Tx) error {
 		q := sq.Insert("roster_versions").
-			Columns("username", "created_at", "updated_at").
-			Values(username, nowExpr, nowExpr).
+			Columns("username", "domain", "created_at", "updated_at").
+			Values(username, domain, nowExpr, nowExpr).
 			Suffix("ON DUPLICATE KEY UPDATE ver = ver + 1, last_deletion_ver = ver, updated_at = NOW()")
 
 		if _, err := q.RunWith(tx).Exec(); err != nil {
 			return err
 		}
 		_, err := sq.Delete("roster_items").
-			Where(sq.And{sq.Eq{"username": username}, sq.Eq{"jid": jid}}).
+			Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}, sq.Eq{"jid": jid}}).
 			RunWith(tx).Exec()
 		return err
 	})
 	if err != nil {
 		return rostermodel.Version{}, err
 	}
-	return s.fetchRosterVer(username)
+	return s.fetchRosterVer(username, domain)
 }
 
 // FetchRosterItems retrieves from storage all roster item entities
 // associated to a given user.
-func (s *Storage) FetchRosterItems(username string) ([]rostermodel.Item, rostermodel.Version, error) {
-	q := sq.Select("username", "jid", "name", "subscription", "`groups`", "ask", "ver").
+func (s *Storage) FetchRosterItems(username, domain string) ([]rostermodel.Item, rostermodel.Version, error) {
+	q := sq.Select("username", "domain", "jid", "name", "subscription", "`groups`", "ask", "ver").
 		From("roster_items").
-		Where(sq.Eq{"username": username}).
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}}).
 		OrderBy("created_at DESC")
 
 	rows, err := q.RunWith(s.db).Query()
@@ -84,7 +84,7 @@ func (s *Storage) FetchRosterItems(username string) ([]rostermodel.Item, rosterm
 	if err != nil {
 		return nil, rostermodel.Version{}, err
 	}
-	ver, err := s.fetchRosterVer(username)
+	ver, err := s.fetchRosterVer(username, domain)
 	if err != nil {
 		return nil, rostermodel.Version{}, err
 	}
@@ -92,10 +92,10 @@ func (s *Storage) FetchRosterItems(username string) ([]rostermodel.Item, rosterm
 }
 
 // FetchRosterItem retrieves from storage a roster item entity.
-func (s *Storage) FetchRosterItem(username, jid string) (*rostermodel.Item, error) {
-	q := sq.Select("username", "jid", "name", "subscription", "`groups`", "ask", "ver").
+func (s *Storage) FetchRosterItem(username, domain, jid string) (*rostermodel.Item, error) {
+	q := sq.Select("username", "domain", "jid", "name", "subscription", "`groups`", "ask", "ver").
 		From("roster_items").
-		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"jid": jid}})
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}, sq.Eq{"jid": jid}})
 
 	var ri rostermodel.Item
 	err := s.scanRosterItemEntity(&ri, q.RunWith(s.db).QueryRow())
@@ -114,8 +114,8 @@ func (s *Storage) FetchRosterItem(username, jid string) (*rostermodel.Item, erro
 func (s *Storage) InsertOrUpdateRosterNotification(rn *rostermodel.Notification) error {
 	presenceXML := rn.Presence.String()
 	q := sq.Insert("roster_notifications").
-		Columns("contact", "jid", "elements", "updated_at", "created_at").
-		Values(rn.Contact, rn.JID, presenceXML, nowExpr, nowExpr).
+		Columns("contact", "domain", "jid", "elements", "updated_at", "created_at").
+		Values(rn.Contact, rn.Domain, rn.JID, presenceXML, nowExpr, nowExpr).
 		Suffix("ON DUPLICATE KEY UPDATE elements = ?, updated_at = NOW()", presenceXML)
 	_, err := q.RunWith(s.db).Exec()
 	return err
@@ -123,10 +123,10 @@ func (s *Storage) InsertOrUpdateRosterNotification(rn *rostermodel.Notification)
 
 // FetchRosterNotifications retrieves from storage all roster notifications
 // associated to a given user.
-func (s *Storage) FetchRosterNotifications(contact string) ([]rostermodel.Notification, error) {
-	q := sq.Select("contact", "jid", "elements").
+func (s *Storage) FetchRosterNotifications(contact, domain string) ([]rostermodel.Notification, error) {
+	q := sq.Select("contact", "domain", "jid", "elements").
 		From("roster_notifications").
-		Where(sq.Eq{"contact": contact}).
+		Where(sq.And{sq.Eq{"contact": contact}, sq.Eq{"domain": domain}}).
 		OrderBy("created_at")
 
 	rows, err := q.RunWith(s.db).Query()
@@ -147,10 +147,10 @@ func (s *Storage) FetchRosterNotifications(contact string) ([]rostermodel.Notifi
 }
 
 // FetchRosterNotification retrieves from storage a roster notification entity.
-func (s *Storage) FetchRosterNotification(contact string, jid string) (*rostermodel.Notification, error) {
-	q := sq.Select("contact", "jid", "elements").
+func (s *Storage) FetchRosterNotification(contact, domain, jid string) (*rostermodel.Notification, error) {
+	q := sq.Select("contact", "domain", "jid", "elements").
 		From("roster_notifications").
-		Where(sq.And{sq.Eq{"contact": contact}, sq.Eq{"jid": jid}})
+		Where(sq.And{sq.Eq{"contact": contact}, sq.Eq{"domain": domain}, sq.Eq{"jid": jid}})
 
 	var rn rostermodel.Notification
 	err := s.scanRosterNotificationEntity(&rn, q.RunWith(s.db).QueryRow())
@@ -165,16 +165,16 @@ func (s *Storage) FetchRosterNotification(contact string, jid string) (*rostermo
 }
 
 // DeleteRosterNotification deletes a roster notification entity from storage.
-func (s *Storage) DeleteRosterNotification(contact, jid string) error {
-	q := sq.Delete("roster_notifications").Where(sq.And{sq.Eq{"contact": contact}, sq.Eq{"jid": jid}})
+func (s *Storage) DeleteRosterNotification(contact, domain, jid string) error {
+	q := sq.Delete("roster_notifications").Where(sq.And{sq.Eq{"contact": contact}, sq.Eq{"domain": domain}, sq.Eq{"jid": jid}})
 	_, err := q.RunWith(s.db).Exec()
 	return err
 }
 
-func (s *Storage) fetchRosterVer(username string) (rostermodel.Version, error) {
+func (s *Storage) fetchRosterVer(username, domain string) (rostermodel.Version, error) {
 	q := sq.Select("IFNULL(MAX(ver), 0)", "IFNULL(MAX(last_deletion_ver), 0)").
 		From("roster_versions").
-		Where(sq.Eq{"username": username})
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}})
 
 	var ver rostermodel.Version
 	row := q.RunWith(s.db).QueryRow()
@@ -189,7 +189,7 @@ func (s *Storage) fetchRosterVer(username string) (rostermodel.Version, error) {
 
 func (s *Storage) scanRosterNotificationEntity(rn *rostermodel.Notification, scanner rowScanner) error {
 	var presenceXML string
-	scanner.Scan(&rn.Contact, &rn.JID, &presenceXML)
+	scanner.Scan(&rn.Contact, &rn.Domain, &rn.JID, &presenceXML)
 
 	parser := xml.NewParser(strings.NewReader(presenceXML), xml.DefaultMode, 0)
 	elem, err := parser.ParseElement()
@@ -204,7 +204,7 @@ func (s *Storage) scanRosterNotificationEntity(rn *rostermodel.Notification, sca
 
 func (s *Storage) scanRosterItemEntity(ri *rostermodel.Item, scanner rowScanner) error {
 	var groups string
-	if err := scanner.Scan(&ri.Username, &ri.JID, &ri.Name, &ri.Subscription, &groups, &ri.Ask, &ri.Ver); err != nil {
+	if err := scanner.Scan(&ri.Username, &ri.Domain, &ri.JID, &ri.Name, &ri.Subscription, &groups, &ri.Ask, &ri.Ver); err != nil {
 		return err
 	}
 	ri.Groups = strings.Split(groups, ";")
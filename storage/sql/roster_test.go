@@ -17,16 +17,18 @@ import (
 
 func TestMySQLStorageInsertRosterItem(t *testing.T) {
 	g := []string{"general", "friends"}
-	ri := rostermodel.Item{"user", "contact", "a name", "both", false, 1, g}
+	ri := rostermodel.Item{"user", "jackal.im", "contact", "a name", "both", false, 1, g}
 
 	args := []driver.Value{
 		ri.Username,
+		ri.Domain,
 		ri.JID,
 		ri.Name,
 		ri.Subscription,
 		"general;friends",
 		ri.Ask,
 		ri.Username,
+		ri.Domain,
 		ri.Name,
 		ri.Subscription,
 		"general;friends",
@@ -36,13 +38,13 @@ func TestMySQLStorageInsertRosterItem(t *testing.T) {
 	s, mock := NewMock()
 	mock.ExpectBegin()
 	mock.ExpectExec("INSERT INTO roster_versions (.+) ON DUPLICATE KEY UPDATE (.+)").
-		WithArgs("user").WillReturnResult(sqlmock.NewResult(0, 1))
+		WithArgs("user", "jackal.im").WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectExec("INSERT INTO roster_items (.+) ON DUPLICATE KEY UPDATE (.+)").
 		WithArgs(args...).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 	mock.ExpectQuery("SELECT (.+) FROM roster_versions (.+)").
-		WithArgs("user").
+		WithArgs("user", "jackal.im").
 		WillReturnRows(sqlmock.NewRows([]string{"ver", "deletionVer"}).AddRow(1, 0))
 
 	_, err := s.InsertOrUpdateRosterItem(&ri)
@@ -54,78 +56,78 @@ func TestMySQLStorageDeleteRosterItem(t *testing.T) {
 	s, mock := NewMock()
 	mock.ExpectBegin()
 	mock.ExpectExec("INSERT INTO roster_versions (.+) ON DUPLICATE KEY UPDATE (.+)").
-		WithArgs("user").WillReturnResult(sqlmock.NewResult(0, 1))
+		WithArgs("user", "jackal.im").WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectExec("DELETE FROM roster_items (.+)").
-		WithArgs("user", "contact").WillReturnResult(sqlmock.NewResult(0, 1))
+		WithArgs("user", "jackal.im", "contact").WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectCommit()
 	mock.ExpectQuery("SELECT (.+) FROM roster_versions (.+)").
-		WithArgs("user").
+		WithArgs("user", "jackal.im").
 		WillReturnRows(sqlmock.NewRows([]string{"ver", "deletionVer"}).AddRow(1, 0))
 
-	_, err := s.DeleteRosterItem("user", "contact")
+	_, err := s.DeleteRosterItem("user", "jackal.im", "contact")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 
 	s, mock = NewMock()
 	mock.ExpectBegin()
 	mock.ExpectExec("INSERT INTO roster_versions (.+)").
-		WithArgs("user").WillReturnError(errMySQLStorage)
+		WithArgs("user", "jackal.im").WillReturnError(errMySQLStorage)
 	mock.ExpectRollback()
 
-	_, err = s.DeleteRosterItem("user", "contact")
+	_, err = s.DeleteRosterItem("user", "jackal.im", "contact")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, errMySQLStorage, err)
 }
 
 func TestMySQLStorageFetchRosterItems(t *testing.T) {
-	var riColumns = []string{"user", "contact", "name", "subscription", "`groups`", "ask", "ver"}
+	var riColumns = []string{"user", "domain", "contact", "name", "subscription", "`groups`", "ask", "ver"}
 
 	s, mock := NewMock()
 	mock.ExpectQuery("SELECT (.+) FROM roster_items (.+)").
-		WithArgs("ortuman").
-		WillReturnRows(sqlmock.NewRows(riColumns).AddRow("ortuman", "romeo", "Romeo", "both", "", false, 0))
+		WithArgs("ortuman", "jackal.im").
+		WillReturnRows(sqlmock.NewRows(riColumns).AddRow("ortuman", "jackal.im", "romeo", "Romeo", "both", "", false, 0))
 	mock.ExpectQuery("SELECT (.+) FROM roster_versions (.+)").
-		WithArgs("ortuman").
+		WithArgs("ortuman", "jackal.im").
 		WillReturnRows(sqlmock.NewRows([]string{"ver", "deletionVer"}).AddRow(0, 0))
 
-	rosterItems, _, err := s.FetchRosterItems("ortuman")
+	rosterItems, _, err := s.FetchRosterItems("ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 	require.Equal(t, 1, len(rosterItems))
 
 	s, mock = NewMock()
 	mock.ExpectQuery("SELECT (.+) FROM roster_items (.+)").
-		WithArgs("ortuman").
+		WithArgs("ortuman", "jackal.im").
 		WillReturnError(errMySQLStorage)
 
-	_, _, err = s.FetchRosterItems("ortuman")
+	_, _, err = s.FetchRosterItems("ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, errMySQLStorage, err)
 
 	s, mock = NewMock()
 	mock.ExpectQuery("SELECT (.+) FROM roster_items (.+)").
-		WithArgs("ortuman", "romeo").
-		WillReturnRows(sqlmock.NewRows(riColumns).AddRow("ortuman", "romeo", "Romeo", "both", "", false, 0))
+		WithArgs("ortuman", "jackal.im", "romeo").
+		WillReturnRows(sqlmock.NewRows(riColumns).AddRow("ortuman", "jackal.im", "romeo", "Romeo", "both", "", false, 0))
 
-	ri, err := s.FetchRosterItem("ortuman", "romeo")
+	ri, err := s.FetchRosterItem("ortuman", "jackal.im", "romeo")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 
 	s, mock = NewMock()
 	mock.ExpectQuery("SELECT (.+) FROM roster_items (.+)").
-		WithArgs("ortuman", "romeo").
+		WithArgs("ortuman", "jackal.im", "romeo").
 		WillReturnRows(sqlmock.NewRows(riColumns))
 
-	ri, err = s.FetchRosterItem("ortuman", "romeo")
+	ri, err = s.FetchRosterItem("ortuman", "jackal.im", "romeo")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, ri)
 
 	s, mock = NewMock()
 	mock.ExpectQuery("SELECT (.+) FROM roster_items (.+)").
-		WithArgs("ortuman", "romeo").
+		WithArgs("ortuman", "jackal.im", "romeo").
 		WillReturnError(errMySQLStorage)
 
-	_, err = s.FetchRosterItem("ortuman", "romeo")
+	_, err = s.FetchRosterItem("ortuman", "jackal.im", "romeo")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, errMySQLStorage, err)
 }
@@ -133,6 +135,7 @@ func TestMySQLStorageFetchRosterItems(t *testing.T) {
 func TestMySQLStorageInsertRosterNotification(t *testing.T) {
 	rn := rostermodel.Notification{
 		"ortuman",
+		"jackal.im",
 		"romeo",
 		&xml.Presence{},
 	}
@@ -140,6 +143,7 @@ func TestMySQLStorageInsertRosterNotification(t *testing.T) {
 
 	args := []driver.Value{
 		rn.Contact,
+		rn.Domain,
 		rn.JID,
 		presenceXML,
 		presenceXML,
@@ -166,59 +170,59 @@ func TestMySQLStorageInsertRosterNotification(t *testing.T) {
 func TestMySQLStorageDeleteRosterNotification(t *testing.T) {
 	s, mock := NewMock()
 	mock.ExpectExec("DELETE FROM roster_notifications (.+)").
-		WithArgs("user", "contact").WillReturnResult(sqlmock.NewResult(0, 1))
+		WithArgs("user", "jackal.im", "contact").WillReturnResult(sqlmock.NewResult(0, 1))
 
-	err := s.DeleteRosterNotification("user", "contact")
+	err := s.DeleteRosterNotification("user", "jackal.im", "contact")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 
 	s, mock = NewMock()
 	mock.ExpectExec("DELETE FROM roster_notifications (.+)").
-		WithArgs("user", "contact").WillReturnError(errMySQLStorage)
+		WithArgs("user", "jackal.im", "contact").WillReturnError(errMySQLStorage)
 
-	err = s.DeleteRosterNotification("user", "contact")
+	err = s.DeleteRosterNotification("user", "jackal.im", "contact")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, errMySQLStorage, err)
 }
 
 func TestMySQLStorageFetchRosterNotifications(t *testing.T) {
-	var rnColumns = []string{"user", "contact", "elements"}
+	var rnColumns = []string{"user", "domain", "contact", "elements"}
 
 	s, mock := NewMock()
 	mock.ExpectQuery("SELECT (.+) FROM roster_notifications (.+)").
-		WithArgs("ortuman").
-		WillReturnRows(sqlmock.NewRows(rnColumns).AddRow("romeo", "contact", "<priority>8</priority>"))
+		WithArgs("ortuman", "jackal.im").
+		WillReturnRows(sqlmock.NewRows(rnColumns).AddRow("romeo", "jackal.im", "contact", "<priority>8</priority>"))
 
-	rosterNotifications, err := s.FetchRosterNotifications("ortuman")
+	rosterNotifications, err := s.FetchRosterNotifications("ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 	require.Equal(t, 1, len(rosterNotifications))
 
 	s, mock = NewMock()
 	mock.ExpectQuery("SELECT (.+) FROM roster_notifications (.+)").
-		WithArgs("ortuman").
+		WithArgs("ortuman", "jackal.im").
 		WillReturnRows(sqlmock.NewRows(rnColumns))
 
-	rosterNotifications, err = s.FetchRosterNotifications("ortuman")
+	rosterNotifications, err = s.FetchRosterNotifications("ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 	require.Equal(t, 0, len(rosterNotifications))
 
 	s, mock = NewMock()
 	mock.ExpectQuery("SELECT (.+) FROM roster_notifications (.+)").
-		WithArgs("ortuman").
+		WithArgs("ortuman", "jackal.im").
 		WillReturnError(errMySQLStorage)
 
-	_, err = s.FetchRosterNotifications("ortuman")
+	_, err = s.FetchRosterNotifications("ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, errMySQLStorage, err)
 
 	s, mock = NewMock()
 	mock.ExpectQuery("SELECT (.+) FROM roster_notifications (.+)").
-		WithArgs("ortuman").
-		WillReturnRows(sqlmock.NewRows(rnColumns).AddRow("romeo", "contact", "<priority>8"))
+		WithArgs("ortuman", "jackal.im").
+		WillReturnRows(sqlmock.NewRows(rnColumns).AddRow("romeo", "jackal.im", "contact", "<priority>8"))
 
-	_, err = s.FetchRosterNotifications("ortuman")
+	_, err = s.FetchRosterNotifications("ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.NotNil(t, err)
 }
@@ -26,8 +26,11 @@ func (s *Storage) InsertOrUpdateUser(u *model.User) error {
 		presenceXML = buf.String()
 		s.pool.Put(buf)
 	}
-	columns := []string{"username", "password", "updated_at", "created_at"}
-	values := []interface{}{u.Username, u.Password, nowExpr, nowExpr}
+	scramSHA1 := scramCredentialsString(u.ScramSHA1)
+	scramSHA256 := scramCredentialsString(u.ScramSHA256)
+
+	columns := []string{"username", "domain", "password", "scram_sha1", "scram_sha256", "disabled", "updated_at", "created_at"}
+	values := []interface{}{u.Username, u.Domain, u.Password, scramSHA1, scramSHA256, u.Disabled, nowExpr, nowExpr}
 
 	if len(presenceXML) > 0 {
 		columns = append(columns, []string{"last_presence", "last_presence_at"}...)
@@ -36,11 +39,11 @@ func (s *Storage) InsertOrUpdateUser(u *model.User) error {
 	var suffix string
 	var suffixArgs []interface{}
 	if len(presenceXML) > 0 {
-		suffix = "ON DUPLICATE KEY UPDATE password = ?, last_presence = ?, last_presence_at = NOW(), updated_at = NOW()"
-		suffixArgs = []interface{}{u.Password, presenceXML}
+		suffix = "ON DUPLICATE KEY UPDATE password = ?, scram_sha1 = ?, scram_sha256 = ?, disabled = ?, last_presence = ?, last_presence_at = NOW(), updated_at = NOW()"
+		suffixArgs = []interface{}{u.Password, scramSHA1, scramSHA256, u.Disabled, presenceXML}
 	} else {
-		suffix = "ON DUPLICATE KEY UPDATE password = ?, updated_at = NOW()"
-		suffixArgs = []interface{}{u.Password}
+		suffix = "ON DUPLICATE KEY UPDATE password = ?, scram_sha1 = ?, scram_sha256 = ?, disabled = ?, updated_at = NOW()"
+		suffixArgs = []interface{}{u.Password, scramSHA1, scramSHA256, u.Disabled}
 	}
 	q := sq.Insert("users").
 		Columns(columns...).
@@ -51,29 +54,15 @@ func (s *Storage) InsertOrUpdateUser(u *model.User) error {
 }
 
 // FetchUser retrieves from storage a user entity.
-func (s *Storage) FetchUser(username string) (*model.User, error) {
-	q := sq.Select("username", "password", "last_presence", "last_presence_at").
+func (s *Storage) FetchUser(username, domain string) (*model.User, error) {
+	q := sq.Select(userColumns...).
 		From("users").
-		Where(sq.Eq{"username": username})
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}})
 
-	var presenceXML string
-	var presenceAt time.Time
 	var usr model.User
-
-	err := q.RunWith(s.db).QueryRow().Scan(&usr.Username, &usr.Password, &presenceXML, &presenceAt)
+	err := s.scanUserEntity(&usr, q.RunWith(s.db).QueryRow())
 	switch err {
 	case nil:
-		if len(presenceXML) > 0 {
-			parser := xml.NewParser(strings.NewReader(presenceXML), xml.DefaultMode, 0)
-			if lastPresence, err := parser.ParseElement(); err != nil {
-				return nil, err
-			} else {
-				fromJID, _ := jid.NewWithString(lastPresence.From(), true)
-				toJID, _ := jid.NewWithString(lastPresence.To(), true)
-				usr.LastPresence, _ = xml.NewPresenceFromElement(lastPresence, fromJID, toJID)
-				usr.LastPresenceAt = presenceAt
-			}
-		}
 		return &usr, nil
 	case sql.ErrNoRows:
 		return nil, nil
@@ -82,31 +71,59 @@ func (s *Storage) FetchUser(username string) (*model.User, error) {
 	}
 }
 
+// FetchUsers retrieves from storage all user entities.
+func (s *Storage) FetchUsers() ([]model.User, error) {
+	q := sq.Select(userColumns...).
+		From("users").
+		OrderBy("domain", "username")
+
+	rows, err := q.RunWith(s.db).Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []model.User
+	for rows.Next() {
+		var usr model.User
+		if err := s.scanUserEntity(&usr, rows); err != nil {
+			return nil, err
+		}
+		users = append(users, usr)
+	}
+	return users, nil
+}
+
 // DeleteUser deletes a user entity from storage.
-func (s *Storage) DeleteUser(username string) error {
+func (s *Storage) DeleteUser(username, domain string) error {
+	cond := sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}}
 	return s.inTransaction(func(tx *sql.Tx) error {
 		var err error
-		_, err = sq.Delete("offline_messages").Where(sq.Eq{"username": username}).RunWith(tx).Exec()
+		_, err = sq.Delete("offline_messages").Where(cond).RunWith(tx).Exec()
+		if err != nil {
+			return err
+		}
+		_, err = sq.Delete("roster_items").Where(cond).RunWith(tx).Exec()
 		if err != nil {
 			return err
 		}
-		_, err = sq.Delete("roster_items").Where(sq.Eq{"username": username}).RunWith(tx).Exec()
+		_, err = sq.Delete("roster_versions").Where(cond).RunWith(tx).Exec()
 		if err != nil {
 			return err
 		}
-		_, err = sq.Delete("roster_versions").Where(sq.Eq{"username": username}).RunWith(tx).Exec()
+		_, err = sq.Delete("private_storage").Where(cond).RunWith(tx).Exec()
 		if err != nil {
 			return err
 		}
-		_, err = sq.Delete("private_storage").Where(sq.Eq{"username": username}).RunWith(tx).Exec()
+		_, err = sq.Delete("vcards").Where(cond).RunWith(tx).Exec()
 		if err != nil {
 			return err
 		}
-		_, err = sq.Delete("vcards").Where(sq.Eq{"username": username}).RunWith(tx).Exec()
+		_, err = sq.Delete("auth_tokens").Where(cond).RunWith(tx).Exec()
 		if err != nil {
 			return err
 		}
-		_, err = sq.Delete("users").Where(sq.Eq{"username": username}).RunWith(tx).Exec()
+		_, err = sq.Delete("users").Where(cond).RunWith(tx).Exec()
 		if err != nil {
 			return err
 		}
@@ -115,8 +132,8 @@ func (s *Storage) DeleteUser(username string) error {
 }
 
 // UserExists returns whether or not a user exists within storage.
-func (s *Storage) UserExists(username string) (bool, error) {
-	q := sq.Select("COUNT(*)").From("users").Where(sq.Eq{"username": username})
+func (s *Storage) UserExists(username, domain string) (bool, error) {
+	q := sq.Select("COUNT(*)").From("users").Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}})
 	var count int
 	err := q.RunWith(s.db).QueryRow().Scan(&count)
 	switch err {
@@ -126,3 +143,57 @@ func (s *Storage) UserExists(username string) (bool, error) {
 		return false, err
 	}
 }
+
+// CountUsers returns the number of users registered within a domain.
+func (s *Storage) CountUsers(domain string) (int, error) {
+	q := sq.Select("COUNT(*)").From("users").Where(sq.Eq{"domain": domain})
+	var count int
+	if err := q.RunWith(s.db).QueryRow().Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+var userColumns = []string{"username", "domain", "password", "scram_sha1", "scram_sha256", "disabled", "last_presence", "last_presence_at"}
+
+func (s *Storage) scanUserEntity(usr *model.User, scanner rowScanner) error {
+	var scramSHA1, scramSHA256, presenceXML string
+	var presenceAt time.Time
+
+	err := scanner.Scan(&usr.Username, &usr.Domain, &usr.Password, &scramSHA1, &scramSHA256, &usr.Disabled, &presenceXML, &presenceAt)
+	if err != nil {
+		return err
+	}
+	if usr.ScramSHA1, err = parseScramCredentials(scramSHA1); err != nil {
+		return err
+	}
+	if usr.ScramSHA256, err = parseScramCredentials(scramSHA256); err != nil {
+		return err
+	}
+	if len(presenceXML) > 0 {
+		parser := xml.NewParser(strings.NewReader(presenceXML), xml.DefaultMode, 0)
+		lastPresence, err := parser.ParseElement()
+		if err != nil {
+			return err
+		}
+		fromJID, _ := jid.NewWithString(lastPresence.From(), true)
+		toJID, _ := jid.NewWithString(lastPresence.To(), true)
+		usr.LastPresence, _ = xml.NewPresenceFromElement(lastPresence, fromJID, toJID)
+		usr.LastPresenceAt = presenceAt
+	}
+	return nil
+}
+
+func scramCredentialsString(c *model.ScramCredentials) string {
+	if c == nil {
+		return ""
+	}
+	return c.String()
+}
+
+func parseScramCredentials(str string) (*model.ScramCredentials, error) {
+	if len(str) == 0 {
+		return nil, nil
+	}
+	return model.NewScramCredentialsString(str)
+}
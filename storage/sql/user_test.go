@@ -21,11 +21,11 @@ func TestMySQLStorageInsertUser(t *testing.T) {
 	to, _ := jid.NewWithString("ortuman@jackal.im", true)
 	p := xml.NewPresence(from, to, xml.UnavailableType)
 
-	user := model.User{Username: "ortuman", Password: "1234", LastPresence: p}
+	user := model.User{Username: "ortuman", Domain: "jackal.im", Password: "1234", LastPresence: p}
 
 	s, mock := NewMock()
 	mock.ExpectExec("INSERT INTO users (.+) ON DUPLICATE KEY UPDATE (.+)").
-		WithArgs("ortuman", "1234", p.String(), "1234", p.String()).
+		WithArgs("ortuman", "jackal.im", "1234", "", "", false, p.String(), "1234", "", "", false, p.String()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	err := s.InsertOrUpdateUser(&user)
@@ -34,7 +34,7 @@ func TestMySQLStorageInsertUser(t *testing.T) {
 
 	s, mock = NewMock()
 	mock.ExpectExec("INSERT INTO users (.+) ON DUPLICATE KEY UPDATE (.+)").
-		WithArgs("ortuman", "1234", p.String(), "1234", p.String()).
+		WithArgs("ortuman", "jackal.im", "1234", "", "", false, p.String(), "1234", "", "", false, p.String()).
 		WillReturnError(errMySQLStorage)
 	err = s.InsertOrUpdateUser(&user)
 	require.Nil(t, mock.ExpectationsWereMet())
@@ -45,30 +45,32 @@ func TestMySQLStorageDeleteUser(t *testing.T) {
 	s, mock := NewMock()
 	mock.ExpectBegin()
 	mock.ExpectExec("DELETE FROM offline_messages (.+)").
-		WithArgs("ortuman").WillReturnResult(sqlmock.NewResult(0, 1))
+		WithArgs("ortuman", "jackal.im").WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectExec("DELETE FROM roster_items (.+)").
-		WithArgs("ortuman").WillReturnResult(sqlmock.NewResult(0, 1))
+		WithArgs("ortuman", "jackal.im").WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectExec("DELETE FROM roster_versions (.+)").
-		WithArgs("ortuman").WillReturnResult(sqlmock.NewResult(0, 1))
+		WithArgs("ortuman", "jackal.im").WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectExec("DELETE FROM private_storage (.+)").
-		WithArgs("ortuman").WillReturnResult(sqlmock.NewResult(0, 1))
+		WithArgs("ortuman", "jackal.im").WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectExec("DELETE FROM vcards (.+)").
-		WithArgs("ortuman").WillReturnResult(sqlmock.NewResult(0, 1))
+		WithArgs("ortuman", "jackal.im").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM auth_tokens (.+)").
+		WithArgs("ortuman", "jackal.im").WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectExec("DELETE FROM users (.+)").
-		WithArgs("ortuman").WillReturnResult(sqlmock.NewResult(0, 1))
+		WithArgs("ortuman", "jackal.im").WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectCommit()
 
-	err := s.DeleteUser("ortuman")
+	err := s.DeleteUser("ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 
 	s, mock = NewMock()
 	mock.ExpectBegin()
 	mock.ExpectExec("DELETE FROM offline_messages (.+)").
-		WithArgs("ortuman").WillReturnError(errMySQLStorage)
+		WithArgs("ortuman", "jackal.im").WillReturnError(errMySQLStorage)
 	mock.ExpectRollback()
 
-	err = s.DeleteUser("ortuman")
+	err = s.DeleteUser("ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, errMySQLStorage, err)
 }
@@ -78,29 +80,57 @@ func TestMySQLStorageFetchUser(t *testing.T) {
 	to, _ := jid.NewWithString("ortuman@jackal.im", true)
 	p := xml.NewPresence(from, to, xml.UnavailableType)
 
-	var userColumns = []string{"username", "password", "last_presence", "last_presence_at"}
+	var userColumns = []string{"username", "domain", "password", "scram_sha1", "scram_sha256", "disabled", "last_presence", "last_presence_at"}
 
 	s, mock := NewMock()
 	mock.ExpectQuery("SELECT (.+) FROM users (.+)").
-		WithArgs("ortuman").
+		WithArgs("ortuman", "jackal.im").
 		WillReturnRows(sqlmock.NewRows(userColumns))
 
-	usr, err := s.FetchUser("ortuman")
+	usr, err := s.FetchUser("ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, usr)
 
 	s, mock = NewMock()
 	mock.ExpectQuery("SELECT (.+) FROM users (.+)").
-		WithArgs("ortuman").
-		WillReturnRows(sqlmock.NewRows(userColumns).AddRow("ortuman", "1234", p.String(), time.Now()))
-	_, err = s.FetchUser("ortuman")
+		WithArgs("ortuman", "jackal.im").
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow("ortuman", "jackal.im", "1234", "", "", false, p.String(), time.Now()))
+	_, err = s.FetchUser("ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 
 	s, mock = NewMock()
 	mock.ExpectQuery("SELECT (.+) FROM users (.+)").
-		WithArgs("ortuman").WillReturnError(errMySQLStorage)
-	_, err = s.FetchUser("ortuman")
+		WithArgs("ortuman", "jackal.im").WillReturnError(errMySQLStorage)
+	_, err = s.FetchUser("ortuman", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorageFetchUsers(t *testing.T) {
+	c := &model.ScramCredentials{Salt: []byte("salt"), Iterations: 4096, StoredKey: []byte("k1"), ServerKey: []byte("k2")}
+
+	var userColumns = []string{"username", "domain", "password", "scram_sha1", "scram_sha256", "disabled", "last_presence", "last_presence_at"}
+
+	s, mock := NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM users ORDER BY domain, username").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow("noelia", "jackal.im", "", "", c.String(), true, "", time.Now()).
+			AddRow("ortuman", "jackal.im", "1234", "", "", false, "", time.Now()))
+
+	users, err := s.FetchUsers()
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, 2, len(users))
+	require.Nil(t, users[0].ScramSHA1)
+	require.Equal(t, c, users[0].ScramSHA256)
+	require.True(t, users[0].Disabled)
+	require.Equal(t, "1234", users[1].Password)
+
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT (.+) FROM users ORDER BY domain, username").
+		WillReturnError(errMySQLStorage)
+	_, err = s.FetchUsers()
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, errMySQLStorage, err)
 }
@@ -110,19 +140,41 @@ func TestMySQLStorageUserExists(t *testing.T) {
 
 	s, mock := NewMock()
 	mock.ExpectQuery("SELECT COUNT(.+) FROM users (.+)").
-		WithArgs("ortuman").
+		WithArgs("ortuman", "jackal.im").
 		WillReturnRows(sqlmock.NewRows(countColums).AddRow(1))
 
-	ok, err := s.UserExists("ortuman")
+	ok, err := s.UserExists("ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 	require.True(t, ok)
 
 	s, mock = NewMock()
 	mock.ExpectQuery("SELECT COUNT(.+) FROM users (.+)").
-		WithArgs("romeo").
+		WithArgs("romeo", "jackal.im").
+		WillReturnError(errMySQLStorage)
+	_, err = s.UserExists("romeo", "jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorageCountUsers(t *testing.T) {
+	countColums := []string{"count"}
+
+	s, mock := NewMock()
+	mock.ExpectQuery("SELECT COUNT(.+) FROM users (.+)").
+		WithArgs("jackal.im").
+		WillReturnRows(sqlmock.NewRows(countColums).AddRow(2))
+
+	count, err := s.CountUsers("jackal.im")
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, 2, count)
+
+	s, mock = NewMock()
+	mock.ExpectQuery("SELECT COUNT(.+) FROM users (.+)").
+		WithArgs("jackal.im").
 		WillReturnError(errMySQLStorage)
-	_, err = s.UserExists("romeo")
+	_, err = s.CountUsers("jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, errMySQLStorage, err)
 }
@@ -15,11 +15,11 @@ import (
 
 // InsertOrUpdateVCard inserts a new vCard element into storage,
 // or updates it in case it's been previously inserted.
-func (s *Storage) InsertOrUpdateVCard(vCard xml.XElement, username string) error {
+func (s *Storage) InsertOrUpdateVCard(vCard xml.XElement, username, domain string) error {
 	rawXML := vCard.String()
 	q := sq.Insert("vcards").
-		Columns("username", "vcard", "updated_at", "created_at").
-		Values(username, rawXML, nowExpr, nowExpr).
+		Columns("username", "domain", "vcard", "updated_at", "created_at").
+		Values(username, domain, rawXML, nowExpr, nowExpr).
 		Suffix("ON DUPLICATE KEY UPDATE vcard = ?, updated_at = NOW()", rawXML)
 
 	_, err := q.RunWith(s.db).Exec()
@@ -28,8 +28,8 @@ func (s *Storage) InsertOrUpdateVCard(vCard xml.XElement, username string) error
 
 // FetchVCard retrieves from storage a vCard element associated
 // to a given user.
-func (s *Storage) FetchVCard(username string) (xml.XElement, error) {
-	q := sq.Select("vcard").From("vcards").Where(sq.Eq{"username": username})
+func (s *Storage) FetchVCard(username, domain string) (xml.XElement, error) {
+	q := sq.Select("vcard").From("vcards").Where(sq.And{sq.Eq{"username": username}, sq.Eq{"domain": domain}})
 
 	var vCard string
 	err := q.RunWith(s.db).QueryRow().Scan(&vCard)
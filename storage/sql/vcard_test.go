@@ -19,20 +19,20 @@ func TestMySQLStorageInsertVCard(t *testing.T) {
 
 	s, mock := NewMock()
 	mock.ExpectExec("INSERT INTO vcards (.+) ON DUPLICATE KEY UPDATE (.+)").
-		WithArgs("ortuman", rawXML, rawXML).
+		WithArgs("ortuman", "jackal.im", rawXML, rawXML).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
-	err := s.InsertOrUpdateVCard(vCard, "ortuman")
+	err := s.InsertOrUpdateVCard(vCard, "ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 	require.NotNil(t, vCard)
 
 	s, mock = NewMock()
 	mock.ExpectExec("INSERT INTO vcards (.+) ON DUPLICATE KEY UPDATE (.+)").
-		WithArgs("ortuman", rawXML, rawXML).
+		WithArgs("ortuman", "jackal.im", rawXML, rawXML).
 		WillReturnError(errMySQLStorage)
 
-	err = s.InsertOrUpdateVCard(vCard, "ortuman")
+	err = s.InsertOrUpdateVCard(vCard, "ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Equal(t, errMySQLStorage, err)
 }
@@ -42,30 +42,30 @@ func TestMySQLStorageFetchVCard(t *testing.T) {
 
 	s, mock := NewMock()
 	mock.ExpectQuery("SELECT (.+) FROM vcards (.+)").
-		WithArgs("ortuman").
+		WithArgs("ortuman", "jackal.im").
 		WillReturnRows(sqlmock.NewRows(vCardColumns).AddRow("<vCard><FN>Miguel Ángel</FN></vCard>"))
 
-	vCard, err := s.FetchVCard("ortuman")
+	vCard, err := s.FetchVCard("ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 	require.NotNil(t, vCard)
 
 	s, mock = NewMock()
 	mock.ExpectQuery("SELECT (.+) FROM vcards (.+)").
-		WithArgs("ortuman").
+		WithArgs("ortuman", "jackal.im").
 		WillReturnRows(sqlmock.NewRows(vCardColumns))
 
-	vCard, err = s.FetchVCard("ortuman")
+	vCard, err = s.FetchVCard("ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
 	require.Nil(t, vCard)
 
 	s, mock = NewMock()
 	mock.ExpectQuery("SELECT (.+) FROM vcards (.+)").
-		WithArgs("ortuman").
+		WithArgs("ortuman", "jackal.im").
 		WillReturnError(errMySQLStorage)
 
-	vCard, _ = s.FetchVCard("ortuman")
+	vCard, _ = s.FetchVCard("ortuman", "jackal.im")
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, vCard)
 }
@@ -10,9 +10,14 @@ import (
 
 	"github.com/ortuman/jackal/log"
 	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/model/mammodel"
+	"github.com/ortuman/jackal/model/mucmodel"
+	"github.com/ortuman/jackal/model/privacymodel"
+	"github.com/ortuman/jackal/model/pubsubmodel"
 	"github.com/ortuman/jackal/model/rostermodel"
 	"github.com/ortuman/jackal/storage/badgerdb"
 	"github.com/ortuman/jackal/storage/memstorage"
+	"github.com/ortuman/jackal/storage/pgsql"
 	"github.com/ortuman/jackal/storage/sql"
 	"github.com/ortuman/jackal/xml"
 )
@@ -23,13 +28,19 @@ type userStorage interface {
 	InsertOrUpdateUser(user *model.User) error
 
 	// DeleteUser deletes a user entity from storage.
-	DeleteUser(username string) error
+	DeleteUser(username, domain string) error
 
 	// FetchUser retrieves from storage a user entity.
-	FetchUser(username string) (*model.User, error)
+	FetchUser(username, domain string) (*model.User, error)
+
+	// FetchUsers retrieves from storage all user entities.
+	FetchUsers() ([]model.User, error)
 
 	// UserExists returns whether or not a user exists within storage.
-	UserExists(username string) (bool, error)
+	UserExists(username, domain string) (bool, error)
+
+	// CountUsers returns the number of users registered within a domain.
+	CountUsers(domain string) (int, error)
 }
 
 type rosterStorage interface {
@@ -38,62 +49,62 @@ type rosterStorage interface {
 	InsertOrUpdateRosterItem(ri *rostermodel.Item) (rostermodel.Version, error)
 
 	// DeleteRosterItem deletes a roster item entity from storage.
-	DeleteRosterItem(username, jid string) (rostermodel.Version, error)
+	DeleteRosterItem(username, domain, jid string) (rostermodel.Version, error)
 
 	// FetchRosterItems retrieves from storage all roster item entities
 	// associated to a given user.
-	FetchRosterItems(username string) ([]rostermodel.Item, rostermodel.Version, error)
+	FetchRosterItems(username, domain string) ([]rostermodel.Item, rostermodel.Version, error)
 
 	// FetchRosterItem retrieves from storage a roster item entity.
-	FetchRosterItem(username, jid string) (*rostermodel.Item, error)
+	FetchRosterItem(username, domain, jid string) (*rostermodel.Item, error)
 
 	// InsertOrUpdateRosterNotification inserts a new roster notification entity
 	// into storage, or updates it in case it's been previously inserted.
 	InsertOrUpdateRosterNotification(rn *rostermodel.Notification) error
 
 	// DeleteRosterNotification deletes a roster notification entity from storage.
-	DeleteRosterNotification(contact, jid string) error
+	DeleteRosterNotification(contact, domain, jid string) error
 
 	// FetchRosterNotification retrieves from storage a roster notification entity.
-	FetchRosterNotification(contact string, jid string) (*rostermodel.Notification, error)
+	FetchRosterNotification(contact, domain, jid string) (*rostermodel.Notification, error)
 
 	// FetchRosterNotifications retrieves from storage all roster notifications
 	// associated to a given user.
-	FetchRosterNotifications(contact string) ([]rostermodel.Notification, error)
+	FetchRosterNotifications(contact, domain string) ([]rostermodel.Notification, error)
 }
 
 type offlineStorage interface {
 	// InsertOfflineMessage inserts a new message element into
 	// user's offline queue.
-	InsertOfflineMessage(message xml.XElement, username string) error
+	InsertOfflineMessage(message xml.XElement, username, domain string) error
 
 	// CountOfflineMessages returns current length of user's offline queue.
-	CountOfflineMessages(username string) (int, error)
+	CountOfflineMessages(username, domain string) (int, error)
 
 	// FetchOfflineMessages retrieves from storage current user offline queue.
-	FetchOfflineMessages(username string) ([]xml.XElement, error)
+	FetchOfflineMessages(username, domain string) ([]xml.XElement, error)
 
 	// DeleteOfflineMessages clears a user offline queue.
-	DeleteOfflineMessages(username string) error
+	DeleteOfflineMessages(username, domain string) error
 }
 
 type vCardStorage interface {
 	// InsertOrUpdateVCard inserts a new vCard element into storage,
 	// or updates it in case it's been previously inserted.
-	InsertOrUpdateVCard(vCard xml.XElement, username string) error
+	InsertOrUpdateVCard(vCard xml.XElement, username, domain string) error
 
 	// FetchVCard retrieves from storage a vCard element associated
 	// to a given user.
-	FetchVCard(username string) (xml.XElement, error)
+	FetchVCard(username, domain string) (xml.XElement, error)
 }
 
 type privateStorage interface {
 	// FetchPrivateXML retrieves from storage a private element.
-	FetchPrivateXML(namespace string, username string) ([]xml.XElement, error)
+	FetchPrivateXML(namespace, username, domain string) ([]xml.XElement, error)
 
 	// InsertOrUpdatePrivateXML inserts a new private element into storage,
 	// or updates it in case it's been previously inserted.
-	InsertOrUpdatePrivateXML(privateXML []xml.XElement, namespace string, username string) error
+	InsertOrUpdatePrivateXML(privateXML []xml.XElement, namespace, username, domain string) error
 }
 
 type blockListStorage interface {
@@ -106,7 +117,116 @@ type blockListStorage interface {
 
 	// FetchBlockListItems retrieves from storage all block list item entities
 	// associated to a given user.
-	FetchBlockListItems(username string) ([]model.BlockListItem, error)
+	FetchBlockListItems(username, domain string) ([]model.BlockListItem, error)
+}
+
+type mucStorage interface {
+	// InsertOrUpdateRoom inserts a new room entity into storage,
+	// or updates it in case it's been previously inserted.
+	InsertOrUpdateRoom(room *mucmodel.Room) error
+
+	// DeleteRoom deletes a room entity from storage.
+	DeleteRoom(roomJID string) error
+
+	// FetchRoom retrieves from storage a room entity.
+	FetchRoom(roomJID string) (*mucmodel.Room, error)
+
+	// FetchRooms retrieves from storage all room entities
+	// associated to a given service domain.
+	FetchRooms(service string) ([]mucmodel.Room, error)
+}
+
+type archiveStorage interface {
+	// InsertArchiveMessage inserts a new message entity into user's archive.
+	InsertArchiveMessage(message *mammodel.Message) error
+
+	// FetchArchiveMessages retrieves from storage, in chronological order,
+	// a page of user's archived messages satisfying filter constraints.
+	FetchArchiveMessages(username, domain string, filter *mammodel.Filter, page *mammodel.Page) (*mammodel.ResultSet, error)
+
+	// InsertOrUpdateArchivePrefs inserts a new archiving preferences entity
+	// into storage, or updates it in case it's been previously inserted.
+	InsertOrUpdateArchivePrefs(prefs *mammodel.Prefs) error
+
+	// FetchArchivePrefs retrieves from storage user's archiving preferences.
+	FetchArchivePrefs(username, domain string) (*mammodel.Prefs, error)
+}
+
+type pubSubStorage interface {
+	// InsertOrUpdatePubSubNode inserts a new pubsub node entity into storage,
+	// or updates it in case it's been previously inserted.
+	InsertOrUpdatePubSubNode(node *pubsubmodel.Node) error
+
+	// DeletePubSubNode deletes a pubsub node entity from storage
+	// along with all its published items.
+	DeletePubSubNode(host, name string) error
+
+	// FetchPubSubNode retrieves from storage a pubsub node entity.
+	FetchPubSubNode(host, name string) (*pubsubmodel.Node, error)
+
+	// FetchPubSubNodes retrieves from storage all pubsub node entities
+	// associated to a given host.
+	FetchPubSubNodes(host string) ([]pubsubmodel.Node, error)
+
+	// InsertOrUpdatePubSubItem inserts a new item entity into a pubsub node,
+	// or updates it in case it's been previously inserted.
+	// Oldest node items will be discarded beyond maxItems (if greater than zero).
+	InsertOrUpdatePubSubItem(item *pubsubmodel.Item, host, name string, maxItems int) error
+
+	// DeletePubSubItem deletes a pubsub node item entity from storage.
+	DeletePubSubItem(itemID, host, name string) error
+
+	// FetchPubSubItems retrieves from storage, in chronological order,
+	// all items published into a pubsub node.
+	FetchPubSubItems(host, name string) ([]pubsubmodel.Item, error)
+}
+
+type pushStorage interface {
+	// InsertOrUpdatePushRegistration inserts a new push registration entity
+	// into storage, or updates it in case it's been previously inserted.
+	InsertOrUpdatePushRegistration(reg *model.PushRegistration) error
+
+	// DeletePushRegistrations deletes from storage a user push registration
+	// entity. In case node is empty every registration associated to
+	// the app server jid will be deleted.
+	DeletePushRegistrations(username, domain, jid, node string) error
+
+	// FetchPushRegistrations retrieves from storage all push registration
+	// entities associated to a given user.
+	FetchPushRegistrations(username, domain string) ([]model.PushRegistration, error)
+}
+
+type authTokenStorage interface {
+	// InsertOrUpdateAuthToken inserts a new auth token entity into storage,
+	// or updates it in case it's been previously inserted for the same device.
+	InsertOrUpdateAuthToken(token *model.AuthToken) error
+
+	// DeleteAuthTokens deletes from storage a user device auth token.
+	// In case device is empty every token associated to the user
+	// will be deleted.
+	DeleteAuthTokens(username, domain, device string) error
+
+	// FetchAuthTokens retrieves from storage all auth token
+	// entities associated to a given user.
+	FetchAuthTokens(username, domain string) ([]model.AuthToken, error)
+}
+
+type privacyStorage interface {
+	// InsertOrUpdatePrivacyList inserts a new privacy list entity into storage,
+	// or updates it in case it's been previously inserted.
+	InsertOrUpdatePrivacyList(list *privacymodel.List) error
+
+	// DeletePrivacyList deletes a privacy list entity from storage.
+	DeletePrivacyList(username, domain, name string) error
+
+	// FetchPrivacyLists retrieves from storage all privacy list entities
+	// associated to a given user.
+	FetchPrivacyLists(username, domain string) ([]privacymodel.List, error)
+
+	// SetDefaultPrivacyList marks a user privacy list as the default one,
+	// unmarking any previous one. An empty name just declines
+	// the use of a default list.
+	SetDefaultPrivacyList(username, domain, name string) error
 }
 
 // Storage represents an entity storage interface.
@@ -117,6 +237,16 @@ type Storage interface {
 	vCardStorage
 	privateStorage
 	blockListStorage
+	mucStorage
+	archiveStorage
+	pubSubStorage
+	pushStorage
+	privacyStorage
+	authTokenStorage
+
+	// MigrateDomain associates every stored entity not yet bound to a domain
+	// to the given one, returning the number of migrated users.
+	MigrateDomain(domain string) (int, error)
 
 	// Shutdown shuts down storage sub system.
 	Shutdown()
@@ -135,17 +265,21 @@ func Initialize(cfg *Config) {
 	if initialized {
 		return
 	}
+	var backend Storage
 	switch cfg.Type {
 	case BadgerDB:
-		inst = badgerdb.New(cfg.BadgerDB)
+		backend = badgerdb.New(cfg.BadgerDB)
 	case MySQL:
-		inst = sql.New(cfg.MySQL)
+		backend = sql.New(cfg.MySQL)
+	case PgSQL:
+		backend = pgsql.New(cfg.PgSQL)
 	case Memory:
-		inst = memstorage.New()
+		backend = memstorage.New()
 	default:
 		// should not be reached
-		break
+		return
 	}
+	inst = newMeasuredStorage(backend)
 	initialized = true
 }
 
@@ -175,9 +309,8 @@ func ActivateMockedError() {
 	instMu.Lock()
 	defer instMu.Unlock()
 
-	switch inst := inst.(type) {
-	case *memstorage.Storage:
-		inst.ActivateMockedError()
+	if ms, ok := backendInstance().(*memstorage.Storage); ok {
+		ms.ActivateMockedError()
 	}
 }
 
@@ -187,8 +320,14 @@ func DeactivateMockedError() {
 	instMu.Lock()
 	defer instMu.Unlock()
 
-	switch inst := inst.(type) {
-	case *memstorage.Storage:
-		inst.DeactivateMockedError()
+	if ms, ok := backendInstance().(*memstorage.Storage); ok {
+		ms.DeactivateMockedError()
 	}
 }
+
+func backendInstance() Storage {
+	if ms, ok := inst.(*measuredStorage); ok {
+		return ms.Storage
+	}
+	return inst
+}
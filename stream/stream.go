@@ -54,6 +54,13 @@ type S2SOut interface {
 	InOutStream
 }
 
+// Component represents an external component XMPP stream.
+type Component interface {
+	InOutStream
+
+	Domain() string
+}
+
 // MockC2S represents a mocked c2s stream.
 type MockC2S struct {
 	id      string
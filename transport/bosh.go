@@ -0,0 +1,564 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package transport
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ortuman/jackal/transport/compress"
+	"github.com/ortuman/jackal/xml"
+	"github.com/pborman/uuid"
+)
+
+const (
+	boshNamespace         = "http://jabber.org/protocol/httpbind"
+	xboshNamespace        = "urn:xmpp:xbosh"
+	streamNamespace       = "http://etherx.jabber.org/streams"
+	framedStreamNamespace = "urn:ietf:params:xml:ns:xmpp-framing"
+	jabberClientNamespace = "jabber:client"
+)
+
+const (
+	boshVersion        = "1.11"
+	boshMaxRequestSize = 1 << 20
+)
+
+// BOSH terminal binding conditions
+const (
+	badRequestCondition         = "bad-request"
+	improperAddressingCondition = "improper-addressing"
+	itemNotFoundCondition       = "item-not-found"
+	policyViolationCondition    = "policy-violation"
+	remoteStreamErrorCondition  = "remote-stream-error"
+)
+
+var errInvalidBOSHBody = errors.New("transport: invalid BOSH body")
+
+// BOSHConfig represents a BOSH transport configuration.
+type BOSHConfig struct {
+	// Wait defines the longest time a request can be held
+	// by the connection manager.
+	Wait time.Duration
+
+	// Hold defines the maximum number of requests the connection
+	// manager is allowed to keep waiting at any one time.
+	Hold int
+
+	// Polling defines the shortest allowable interval between
+	// empty requests on polling sessions.
+	Polling time.Duration
+
+	// Inactivity defines the longest allowable inactivity period,
+	// after which the session is terminated.
+	Inactivity time.Duration
+}
+
+// BOSHHandler represents an HTTP handler that dispatches incoming
+// BOSH requests to its corresponding session transport.
+type BOSHHandler struct {
+	cfg      *BOSHConfig
+	startFn  func(Transport)
+	mu       sync.RWMutex
+	sessions map[string]*boshTransport
+}
+
+// NewBOSHHandler returns a BOSH HTTP handler that invokes startFn
+// every time a new session is created.
+func NewBOSHHandler(cfg *BOSHConfig, startFn func(Transport)) *BOSHHandler {
+	return &BOSHHandler{
+		cfg:      cfg,
+		startFn:  startFn,
+		sessions: make(map[string]*boshTransport),
+	}
+}
+
+// ServeHTTP satisfies http.Handler interface.
+func (h *BOSHHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+
+	switch r.Method {
+	case http.MethodOptions:
+		return
+	case http.MethodPost:
+		break
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var resp []byte
+
+	body, rid, err := parseBOSHBody(http.MaxBytesReader(w, r.Body, boshMaxRequestSize))
+	switch {
+	case err != nil:
+		resp = terminateBody(badRequestCondition)
+
+	case len(body.Attributes().Get("sid")) == 0:
+		if len(body.To()) == 0 {
+			resp = terminateBody(improperAddressingCondition)
+			break
+		}
+		resp = h.createSession(body, rid, r.TLS).request(r, body, rid)
+
+	default:
+		h.mu.RLock()
+		tr := h.sessions[body.Attributes().Get("sid")]
+		h.mu.RUnlock()
+		if tr == nil {
+			resp = terminateBody(itemNotFoundCondition)
+			break
+		}
+		resp = tr.request(r, body, rid)
+	}
+	if resp == nil {
+		return // client went away
+	}
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	w.Write(resp)
+}
+
+// Close terminates every active BOSH session.
+func (h *BOSHHandler) Close() {
+	h.mu.RLock()
+	var trs []*boshTransport
+	for _, tr := range h.sessions {
+		trs = append(trs, tr)
+	}
+	h.mu.RUnlock()
+	for _, tr := range trs {
+		tr.Close()
+	}
+}
+
+func (h *BOSHHandler) createSession(body xml.XElement, rid int64, tlsState *tls.ConnectionState) *boshTransport {
+	wait := h.cfg.Wait
+	if w, err := strconv.Atoi(body.Attributes().Get("wait")); err == nil && w >= 0 && time.Duration(w)*time.Second < wait {
+		wait = time.Duration(w) * time.Second
+	}
+	hold := h.cfg.Hold
+	if hl, err := strconv.Atoi(body.Attributes().Get("hold")); err == nil && hl >= 0 && hl < hold {
+		hold = hl
+	}
+	tr := &boshTransport{
+		sid:        uuid.New(),
+		domain:     body.To(),
+		wait:       wait,
+		hold:       hold,
+		polling:    h.cfg.Polling,
+		inactivity: h.cfg.Inactivity,
+		tlsState:   tlsState,
+		rid:        rid - 1,
+		pending:    make(map[int64]*boshRequest),
+		responses:  make(map[int64][]byte),
+		onClose:    h.closeSession,
+	}
+	tr.cond = sync.NewCond(&tr.mu)
+	tr.writeOpen()
+
+	h.mu.Lock()
+	h.sessions[tr.sid] = tr
+	h.mu.Unlock()
+
+	h.startFn(tr)
+	return tr
+}
+
+func (h *BOSHHandler) closeSession(sid string) {
+	h.mu.Lock()
+	delete(h.sessions, sid)
+	h.mu.Unlock()
+}
+
+type boshRequest struct {
+	rid    int64
+	body   xml.XElement
+	respCh chan []byte
+	tm     *time.Timer
+}
+
+type boshTransport struct {
+	sid        string
+	domain     string
+	wait       time.Duration
+	hold       int
+	polling    time.Duration
+	inactivity time.Duration
+	tlsState   *tls.ConnectionState
+	onClose    func(sid string)
+
+	mu         sync.Mutex
+	cond       *sync.Cond
+	rb         bytes.Buffer
+	rid        int64
+	pending    map[int64]*boshRequest
+	held       []*boshRequest
+	out        []xml.XElement
+	responses  map[int64][]byte
+	streamID   string
+	from       string
+	opened     bool
+	created    bool
+	terminated bool
+	closed     bool
+	lastPoll   time.Time
+	inactTm    *time.Timer
+}
+
+func (t *boshTransport) Read(p []byte) (n int, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for t.rb.Len() == 0 && !t.closed {
+		t.cond.Wait()
+	}
+	if t.rb.Len() == 0 {
+		return 0, io.EOF
+	}
+	return t.rb.Read(p)
+}
+
+// ReadByte satisfies io.ByteReader interface, preventing XML decoder from
+// buffering elements beyond a stream restart.
+func (t *boshTransport) ReadByte() (byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for t.rb.Len() == 0 && !t.closed {
+		t.cond.Wait()
+	}
+	if t.rb.Len() == 0 {
+		return 0, io.EOF
+	}
+	return t.rb.ReadByte()
+}
+
+func (t *boshTransport) Write(p []byte) (n int, err error) {
+	pr := xml.NewParser(bytes.NewReader(p), xml.DefaultMode, 0)
+	var elems []xml.XElement
+	for {
+		elem, err := pr.ParseElement()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		if elem != nil {
+			elems = append(elems, elem)
+		}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return 0, io.ErrClosedPipe
+	}
+	for _, elem := range elems {
+		switch {
+		case elem.Name() == "open" && elem.Namespace() == framedStreamNamespace:
+			t.streamID = elem.ID()
+			t.from = elem.From()
+			t.opened = true
+		case elem.Name() == "close" && elem.Namespace() == framedStreamNamespace:
+			t.terminated = true
+		case elem.Name() == "stream:error":
+			// stream errors are always followed by the stream closing
+			t.out = append(t.out, elem)
+			t.terminated = true
+		default:
+			t.out = append(t.out, elem)
+		}
+	}
+	t.dispatch()
+	return len(p), nil
+}
+
+func (t *boshTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil
+	}
+	t.terminated = true
+	t.dispatch()
+	t.closed = true
+	if t.inactTm != nil {
+		t.inactTm.Stop()
+	}
+	t.cond.Broadcast()
+	t.onClose(t.sid)
+	return nil
+}
+
+func (t *boshTransport) Type() TransportType {
+	return BOSH
+}
+
+func (t *boshTransport) WriteString(s string) (int, error) {
+	return t.Write([]byte(s))
+}
+
+func (t *boshTransport) StartTLS(_ *tls.Config, _ bool) {
+}
+
+func (t *boshTransport) EnableCompression(level compress.Level) {
+}
+
+func (t *boshTransport) ChannelBindingBytes(mechanism ChannelBindingMechanism) []byte {
+	// requests may be delivered over different connections,
+	// thus there's no such a thing as a channel to bind to.
+	return nil
+}
+
+func (t *boshTransport) PeerCertificates() []*x509.Certificate {
+	if t.tlsState != nil {
+		return t.tlsState.PeerCertificates
+	}
+	return nil
+}
+
+// request processes an incoming request body and waits until a response
+// is available, returning nil if the client goes away in the meantime.
+func (t *boshTransport) request(r *http.Request, body xml.XElement, rid int64) []byte {
+	req := &boshRequest{rid: rid, body: body, respCh: make(chan []byte, 1)}
+
+	t.mu.Lock()
+	t.enqueue(req)
+	t.mu.Unlock()
+
+	select {
+	case resp := <-req.respCh:
+		return resp
+	case <-r.Context().Done():
+		return nil
+	}
+}
+
+func (t *boshTransport) enqueue(req *boshRequest) {
+	if t.closed {
+		req.respCh <- terminateBody(itemNotFoundCondition)
+		return
+	}
+	if t.inactTm != nil {
+		t.inactTm.Stop()
+		t.inactTm = nil
+	}
+	switch {
+	case req.rid <= t.rid:
+		// retransmission of an already answered request
+		if resp, ok := t.responses[req.rid]; ok {
+			req.respCh <- resp
+			return
+		}
+		t.fail(req, itemNotFoundCondition)
+
+	case req.rid > t.rid+int64(t.hold)+1:
+		t.fail(req, itemNotFoundCondition)
+
+	default:
+		// requests are processed in order, no matter the order
+		// in which they arrived
+		t.pending[req.rid] = req
+		for next := t.pending[t.rid+1]; next != nil; next = t.pending[t.rid+1] {
+			delete(t.pending, next.rid)
+			t.rid = next.rid
+			if !t.process(next) {
+				return
+			}
+		}
+		t.dispatch()
+	}
+}
+
+func (t *boshTransport) process(req *boshRequest) bool {
+	elems := req.body.Elements().All()
+	isRestart := req.body.Attributes().Get("xmpp:restart") == "true"
+	isTerminate := req.body.Type() == "terminate"
+
+	// polling sessions must not send consecutive empty requests
+	// faster than the agreed polling interval
+	if len(elems) == 0 && !isRestart && !isTerminate && t.hold == 0 {
+		if !t.lastPoll.IsZero() && time.Since(t.lastPoll) < t.polling {
+			t.fail(req, policyViolationCondition)
+			return false
+		}
+	}
+	if isRestart {
+		t.writeOpen()
+	}
+	for _, elem := range elems {
+		elem.ToXML(&t.rb, true)
+	}
+	if isTerminate {
+		xml.NewElementNamespace("close", framedStreamNamespace).ToXML(&t.rb, true)
+		t.terminated = true
+	}
+	t.cond.Broadcast()
+
+	req.tm = time.AfterFunc(t.wait, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.respond(req)
+	})
+	t.held = append(t.held, req)
+	return true
+}
+
+// writeOpen feeds the reading side with a framed stream opening element.
+func (t *boshTransport) writeOpen() {
+	open := xml.NewElementNamespace("open", framedStreamNamespace)
+	open.SetAttribute("to", t.domain)
+	open.SetAttribute("version", "1.0")
+	open.ToXML(&t.rb, true)
+}
+
+func (t *boshTransport) dispatch() {
+	if t.terminated {
+		for len(t.held) > 0 {
+			t.respond(t.held[0])
+		}
+		return
+	}
+	// session creation response is delayed until stream has been opened
+	if !t.created {
+		if t.opened && len(t.out) > 0 && len(t.held) > 0 {
+			t.respond(t.held[0])
+		}
+		return
+	}
+	for len(t.held) > t.hold {
+		t.respond(t.held[0])
+	}
+	if len(t.out) > 0 && len(t.held) > 0 {
+		t.respond(t.held[0])
+	}
+}
+
+func (t *boshTransport) respond(req *boshRequest) {
+	idx := -1
+	for i, r := range t.held {
+		if r == req {
+			idx = i
+			break
+		}
+	}
+	if idx == -1 {
+		return // already answered
+	}
+	t.held = append(t.held[:idx], t.held[idx+1:]...)
+	if req.tm != nil {
+		req.tm.Stop()
+	}
+	body := xml.NewElementNamespace("body", boshNamespace)
+	if !t.created {
+		body.SetAttribute("xmlns:xmpp", xboshNamespace)
+		body.SetAttribute("xmlns:stream", streamNamespace)
+		body.SetAttribute("sid", t.sid)
+		body.SetAttribute("wait", strconv.Itoa(int(t.wait/time.Second)))
+		body.SetAttribute("hold", strconv.Itoa(t.hold))
+		body.SetAttribute("requests", strconv.Itoa(t.hold+1))
+		body.SetAttribute("polling", strconv.Itoa(int(t.polling/time.Second)))
+		body.SetAttribute("inactivity", strconv.Itoa(int(t.inactivity/time.Second)))
+		body.SetAttribute("ver", boshVersion)
+		body.SetAttribute("from", t.from)
+		body.SetAttribute("authid", t.streamID)
+		body.SetAttribute("xmpp:version", "1.0")
+		body.SetAttribute("xmpp:restartlogic", "true")
+		t.created = true
+	}
+	if t.terminated {
+		body.SetAttribute("type", "terminate")
+	}
+	for _, elem := range t.out {
+		if elem.IsStanza() && len(elem.Namespace()) == 0 {
+			e := xml.NewElementFromElement(elem)
+			e.SetNamespace(jabberClientNamespace)
+			elem = e
+		} else if t.terminated && elem.Name() == "stream:error" {
+			body.SetAttribute("condition", remoteStreamErrorCondition)
+		}
+		body.AppendElement(elem)
+	}
+	if len(t.out) == 0 && req.body.Elements().Count() == 0 {
+		t.lastPoll = time.Now()
+	} else {
+		t.lastPoll = time.Time{}
+	}
+	t.out = nil
+
+	buf := &bytes.Buffer{}
+	body.ToXML(buf, true)
+	resp := buf.Bytes()
+
+	// keep last responses in case of retransmission
+	t.responses[req.rid] = resp
+	delete(t.responses, req.rid-int64(t.hold)-1)
+
+	req.respCh <- resp
+
+	if len(t.held) == 0 && !t.terminated && t.inactivity > 0 {
+		t.inactTm = time.AfterFunc(t.inactivity, t.inactivityTimeout)
+	}
+}
+
+func (t *boshTransport) fail(req *boshRequest, condition string) {
+	req.respCh <- terminateBody(condition)
+	t.terminated = true
+	t.dispatch()
+	t.closeReading()
+}
+
+func (t *boshTransport) inactivityTimeout() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.held) == 0 {
+		t.closeReading()
+	}
+}
+
+// closeReading makes the reading side of the transport
+// to report EOF once the pending data has been consumed.
+func (t *boshTransport) closeReading() {
+	t.closed = true
+	t.cond.Broadcast()
+	t.onClose(t.sid)
+}
+
+func parseBOSHBody(r io.Reader) (xml.XElement, int64, error) {
+	pr := xml.NewParser(r, xml.DefaultMode, 0)
+	for {
+		elem, err := pr.ParseElement()
+		if err != nil {
+			return nil, 0, err
+		}
+		if elem == nil {
+			continue
+		}
+		if elem.Name() != "body" || elem.Namespace() != boshNamespace {
+			return nil, 0, errInvalidBOSHBody
+		}
+		rid, err := strconv.ParseInt(elem.Attributes().Get("rid"), 10, 64)
+		if err != nil {
+			return nil, 0, err
+		}
+		return elem, rid, nil
+	}
+}
+
+func terminateBody(condition string) []byte {
+	body := xml.NewElementNamespace("body", boshNamespace)
+	body.SetAttribute("type", "terminate")
+	body.SetAttribute("condition", condition)
+	buf := &bytes.Buffer{}
+	body.ToXML(buf, true)
+	return buf.Bytes()
+}
@@ -0,0 +1,240 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package transport
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/xml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBOSHTransport_Session(t *testing.T) {
+	srv, trCh := tUtilBOSHServer(&BOSHConfig{Wait: time.Second, Hold: 1, Inactivity: time.Second})
+	defer srv.Close()
+
+	respCh := tUtilBOSHRequestAsync(srv.URL, `<body xmlns="http://jabber.org/protocol/httpbind" rid="100" to="localhost" wait="60" hold="1" xmpp:version="1.0" xmlns:xmpp="urn:xmpp:xbosh"/>`)
+
+	tr := <-trCh
+	require.Equal(t, BOSH, tr.Type())
+	pr := xml.NewParser(tr, xml.WebSocketStream, 0)
+	elem, err := pr.ParseElement()
+	require.Nil(t, err)
+	require.Equal(t, "open", elem.Name())
+	require.Equal(t, "localhost", elem.To())
+
+	tr.WriteString(`<open xmlns="urn:ietf:params:xml:ns:xmpp-framing" id="abcd" from="localhost" version="1.0"/>`)
+	tr.WriteString(`<stream:features xmlns:stream="http://etherx.jabber.org/streams"/>`)
+
+	body := <-respCh
+	require.NotNil(t, body)
+	sid := body.Attributes().Get("sid")
+	require.NotEqual(t, "", sid)
+	require.Equal(t, "abcd", body.Attributes().Get("authid"))
+	require.Equal(t, "localhost", body.Attributes().Get("from"))
+	require.Equal(t, "1", body.Attributes().Get("wait")) // server's wait is shorter
+	require.Equal(t, "2", body.Attributes().Get("requests"))
+	require.NotNil(t, body.Elements().Child("stream:features"))
+
+	// client payload
+	respCh = tUtilBOSHRequestAsync(srv.URL, tUtilBOSHBody(sid, 101, "", `<message xmlns="jabber:client" to="ortuman@localhost"><body>hi!</body></message>`))
+	elem, err = pr.ParseElement()
+	require.Nil(t, err)
+	require.Equal(t, "message", elem.Name())
+	require.Equal(t, "ortuman@localhost", elem.To())
+
+	// server payload
+	tr.WriteString(`<message from="ortuman@localhost"><body>hello!</body></message>`)
+	body = <-respCh
+	require.NotNil(t, body)
+	msg := body.Elements().Child("message")
+	require.NotNil(t, msg)
+	require.Equal(t, "jabber:client", msg.Namespace())
+
+	// retransmission
+	body = tUtilBOSHRequest(srv.URL, tUtilBOSHBody(sid, 101, "", ""))
+	require.NotNil(t, body)
+	require.NotNil(t, body.Elements().Child("message"))
+
+	// empty request is held until wait expires
+	start := time.Now()
+	body = tUtilBOSHRequest(srv.URL, tUtilBOSHBody(sid, 102, "", ""))
+	require.NotNil(t, body)
+	require.Equal(t, 0, body.Elements().Count())
+	require.True(t, time.Since(start) >= time.Second)
+
+	// stream restart
+	respCh = tUtilBOSHRequestAsync(srv.URL, tUtilBOSHBody(sid, 103, `xmpp:restart="true"`, ""))
+	pr = xml.NewParser(tr, xml.WebSocketStream, 0)
+	elem, err = pr.ParseElement()
+	require.Nil(t, err)
+	require.Equal(t, "open", elem.Name())
+	tr.WriteString(`<stream:features xmlns:stream="http://etherx.jabber.org/streams"/>`)
+	body = <-respCh
+	require.NotNil(t, body)
+	require.NotNil(t, body.Elements().Child("stream:features"))
+
+	// session termination
+	body = tUtilBOSHRequest(srv.URL, tUtilBOSHBody(sid, 104, `type="terminate"`, `<presence xmlns="jabber:client" type="unavailable"/>`))
+	require.NotNil(t, body)
+	require.Equal(t, "terminate", body.Type())
+
+	elem, err = pr.ParseElement()
+	require.Nil(t, err)
+	require.Equal(t, "presence", elem.Name())
+	_, err = pr.ParseElement()
+	require.Equal(t, xml.ErrStreamClosedByPeer, err)
+}
+
+func TestBOSHTransport_InOrderDelivery(t *testing.T) {
+	srv, trCh := tUtilBOSHServer(&BOSHConfig{Wait: time.Second, Hold: 1})
+	defer srv.Close()
+
+	sid, tr, pr := tUtilBOSHSession(t, srv.URL, trCh, 1)
+
+	respCh2 := tUtilBOSHRequestAsync(srv.URL, tUtilBOSHBody(sid, 3, "", `<iq xmlns="jabber:client" id="2" type="get"/>`))
+	time.Sleep(time.Millisecond * 100)
+	respCh1 := tUtilBOSHRequestAsync(srv.URL, tUtilBOSHBody(sid, 2, "", `<iq xmlns="jabber:client" id="1" type="get"/>`))
+
+	elem, err := pr.ParseElement()
+	require.Nil(t, err)
+	require.Equal(t, "1", elem.ID())
+	elem, err = pr.ParseElement()
+	require.Nil(t, err)
+	require.Equal(t, "2", elem.ID())
+
+	// hold limit exceeded: oldest request is answered right away
+	body := <-respCh1
+	require.NotNil(t, body)
+	require.Equal(t, 0, body.Elements().Count())
+
+	tr.WriteString(`<iq id="1" type="result"/>`)
+	body = <-respCh2
+	require.NotNil(t, body)
+	require.Equal(t, "1", body.Elements().Child("iq").ID())
+
+	// out of window request
+	body = tUtilBOSHRequest(srv.URL, tUtilBOSHBody(sid, 10, "", ""))
+	require.NotNil(t, body)
+	require.Equal(t, "terminate", body.Type())
+	require.Equal(t, itemNotFoundCondition, body.Attributes().Get("condition"))
+}
+
+func TestBOSHTransport_Polling(t *testing.T) {
+	srv, trCh := tUtilBOSHServer(&BOSHConfig{Wait: time.Second, Hold: 1, Polling: time.Second})
+	defer srv.Close()
+
+	sid, _, _ := tUtilBOSHSession(t, srv.URL, trCh, 0)
+
+	body := tUtilBOSHRequest(srv.URL, tUtilBOSHBody(sid, 2, "", ""))
+	require.NotNil(t, body)
+	require.Equal(t, "", body.Type())
+
+	body = tUtilBOSHRequest(srv.URL, tUtilBOSHBody(sid, 3, "", ""))
+	require.NotNil(t, body)
+	require.Equal(t, "terminate", body.Type())
+	require.Equal(t, policyViolationCondition, body.Attributes().Get("condition"))
+}
+
+func TestBOSHTransport_Close(t *testing.T) {
+	srv, trCh := tUtilBOSHServer(&BOSHConfig{Wait: time.Second * 5, Hold: 1})
+	defer srv.Close()
+
+	sid, tr, pr := tUtilBOSHSession(t, srv.URL, trCh, 1)
+
+	respCh := tUtilBOSHRequestAsync(srv.URL, tUtilBOSHBody(sid, 2, "", ""))
+	time.Sleep(time.Millisecond * 100)
+
+	tr.WriteString(`<stream:error><policy-violation xmlns="urn:ietf:params:xml:ns:xmpp-streams"/></stream:error>`)
+	tr.WriteString(`<close xmlns="urn:ietf:params:xml:ns:xmpp-framing"/>`)
+	body := <-respCh
+	require.NotNil(t, body)
+	require.Equal(t, "terminate", body.Type())
+	require.Equal(t, remoteStreamErrorCondition, body.Attributes().Get("condition"))
+	require.NotNil(t, body.Elements().Child("stream:error"))
+
+	tr.Close()
+	_, err := pr.ParseElement()
+	require.NotNil(t, err)
+
+	// session is gone
+	body = tUtilBOSHRequest(srv.URL, tUtilBOSHBody(sid, 3, "", ""))
+	require.NotNil(t, body)
+	require.Equal(t, itemNotFoundCondition, body.Attributes().Get("condition"))
+}
+
+func TestBOSHTransport_BadRequest(t *testing.T) {
+	srv, _ := tUtilBOSHServer(&BOSHConfig{Wait: time.Second, Hold: 1})
+	defer srv.Close()
+
+	body := tUtilBOSHRequest(srv.URL, `<body xmlns="http://jabber.org/protocol/httpbind" to="localhost"/>`)
+	require.NotNil(t, body)
+	require.Equal(t, badRequestCondition, body.Attributes().Get("condition"))
+
+	body = tUtilBOSHRequest(srv.URL, `<iq xmlns="jabber:client"/>`)
+	require.NotNil(t, body)
+	require.Equal(t, badRequestCondition, body.Attributes().Get("condition"))
+
+	body = tUtilBOSHRequest(srv.URL, `<body xmlns="http://jabber.org/protocol/httpbind" rid="1"/>`)
+	require.NotNil(t, body)
+	require.Equal(t, improperAddressingCondition, body.Attributes().Get("condition"))
+
+	body = tUtilBOSHRequest(srv.URL, tUtilBOSHBody("1234", 1, "", ""))
+	require.NotNil(t, body)
+	require.Equal(t, itemNotFoundCondition, body.Attributes().Get("condition"))
+
+	resp, err := http.Get(srv.URL)
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func tUtilBOSHServer(cfg *BOSHConfig) (*httptest.Server, <-chan Transport) {
+	trCh := make(chan Transport, 1)
+	h := NewBOSHHandler(cfg, func(tr Transport) { trCh <- tr })
+	return httptest.NewServer(h), trCh
+}
+
+func tUtilBOSHSession(t *testing.T, url string, trCh <-chan Transport, hold int) (string, Transport, *xml.Parser) {
+	respCh := tUtilBOSHRequestAsync(url, fmt.Sprintf(`<body xmlns="http://jabber.org/protocol/httpbind" rid="1" to="localhost" hold="%d"/>`, hold))
+	tr := <-trCh
+	pr := xml.NewParser(tr, xml.WebSocketStream, 0)
+	_, err := pr.ParseElement()
+	require.Nil(t, err)
+	tr.WriteString(`<open xmlns="urn:ietf:params:xml:ns:xmpp-framing" id="abcd" from="localhost" version="1.0"/>`)
+	tr.WriteString(`<stream:features xmlns:stream="http://etherx.jabber.org/streams"/>`)
+	body := <-respCh
+	require.NotNil(t, body)
+	return body.Attributes().Get("sid"), tr, pr
+}
+
+func tUtilBOSHBody(sid string, rid int, attrs, payload string) string {
+	return fmt.Sprintf(`<body xmlns="http://jabber.org/protocol/httpbind" xmlns:xmpp="urn:xmpp:xbosh" sid="%s" rid="%d" %s>%s</body>`, sid, rid, attrs, payload)
+}
+
+func tUtilBOSHRequestAsync(url, body string) <-chan xml.XElement {
+	respCh := make(chan xml.XElement, 1)
+	go func() { respCh <- tUtilBOSHRequest(url, body) }()
+	return respCh
+}
+
+func tUtilBOSHRequest(url, body string) xml.XElement {
+	resp, err := http.Post(url, "text/xml; charset=utf-8", bytes.NewReader([]byte(body)))
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	elem, err := xml.NewParser(resp.Body, xml.DefaultMode, 0).ParseElement()
+	if err != nil {
+		return nil
+	}
+	return elem
+}
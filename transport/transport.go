@@ -22,6 +22,9 @@ const (
 
 	// WebSocket represents a websocket transport type.
 	WebSocket
+
+	// BOSH represents a BOSH (HTTP long-polling) transport type.
+	BOSH
 )
 
 // String returns TransportType string representation.
@@ -31,6 +34,8 @@ func (tt TransportType) String() string {
 		return "socket"
 	case WebSocket:
 		return "websocket"
+	case BOSH:
+		return "bosh"
 	}
 	return ""
 }
@@ -11,8 +11,10 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/asn1"
 	"encoding/pem"
 	"fmt"
+	"io/ioutil"
 	"math/big"
 	"os"
 	"time"
@@ -27,6 +29,11 @@ const (
 	selfSignedCertFile       = selfSignedCertFolder + "cert.pem"
 )
 
+var (
+	oidSubjectAltName = asn1.ObjectIdentifier{2, 5, 29, 17}
+	oidXMPPAddr       = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 8, 5}
+)
+
 // LoadCertificate loads a certificate given a private key and certificate PEM files.
 // Only in case the associated domain is localhost and no files are specified
 // a self signed certificate will be automatically generated.
@@ -54,6 +61,77 @@ func LoadCertificate(keyFile, certFile, domain string) (tls.Certificate, error)
 	return cer, nil
 }
 
+// LoadCertPool loads a certificate pool from a PEM encoded CA certificates file.
+func LoadCertPool(caFile string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	if err := AppendCertsFromFile(pool, caFile); err != nil {
+		return nil, err
+	}
+	return pool, nil
+}
+
+// AppendCertsFromFile appends PEM encoded CA certificates file contents
+// to an existing certificate pool.
+func AppendCertsFromFile(pool *x509.CertPool, caFile string) error {
+	b, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return err
+	}
+	if !pool.AppendCertsFromPEM(b) {
+		return fmt.Errorf("no valid certificates found in '%s'", caFile)
+	}
+	return nil
+}
+
+// XMPPAddresses returns all id-on-xmppAddr subject alternative names
+// contained in a certificate (RFC 6120, section 13.7.1.4).
+func XMPPAddresses(cert *x509.Certificate) []string {
+	var addrs []string
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidSubjectAltName) {
+			continue
+		}
+		var seq asn1.RawValue
+		if _, err := asn1.Unmarshal(ext.Value, &seq); err != nil {
+			return nil
+		}
+		rest := seq.Bytes
+		for len(rest) > 0 {
+			var gn asn1.RawValue
+			var err error
+			if rest, err = asn1.Unmarshal(rest, &gn); err != nil {
+				return addrs
+			}
+			if gn.Class != asn1.ClassContextSpecific || gn.Tag != 0 { // otherName
+				continue
+			}
+			if addr, ok := parseXMPPAddr(gn.Bytes); ok {
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	return addrs
+}
+
+// parseXMPPAddr decodes an otherName
+// { type-id OID, value [0] EXPLICIT UTF8String } structure.
+func parseXMPPAddr(b []byte) (string, bool) {
+	var typeID asn1.ObjectIdentifier
+	rest, err := asn1.Unmarshal(b, &typeID)
+	if err != nil || !typeID.Equal(oidXMPPAddr) {
+		return "", false
+	}
+	var val asn1.RawValue
+	if _, err := asn1.Unmarshal(rest, &val); err != nil || val.Class != asn1.ClassContextSpecific || val.Tag != 0 {
+		return "", false
+	}
+	var addr asn1.RawValue
+	if _, err := asn1.Unmarshal(val.Bytes, &addr); err != nil || addr.Tag != asn1.TagUTF8String {
+		return "", false
+	}
+	return string(addr.Bytes), true
+}
+
 func generateSelfSignedCertificate(keyFile, certFile, domain string) error {
 	if err := os.MkdirAll(selfSignedCertFolder, os.ModePerm); err != nil {
 		return err
@@ -6,6 +6,9 @@
 package util
 
 import (
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
 	"os"
 	"testing"
 
@@ -34,3 +37,38 @@ func TestLoadCertificate(t *testing.T) {
 		require.Equal(t, "must specify a private key and a server certificate for the domain 'jackal.im'", err.Error())
 	})
 }
+
+func TestLoadCertPool(t *testing.T) {
+	pool, err := LoadCertPool("../testdata/cert/test.ca.crt")
+	require.Nil(t, err)
+	require.NotNil(t, pool)
+
+	cert := tUtilLoadCertificate(t, "../testdata/cert/test.client.crt")
+	_, err = cert.Verify(x509.VerifyOptions{Roots: pool, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}})
+	require.Nil(t, err)
+
+	_, err = LoadCertPool("../testdata/cert/test.server.key")
+	require.NotNil(t, err)
+
+	_, err = LoadCertPool("../testdata/cert/not_a_file.crt")
+	require.NotNil(t, err)
+}
+
+func TestXMPPAddresses(t *testing.T) {
+	cert := tUtilLoadCertificate(t, "../testdata/cert/test.client.crt")
+	require.Equal(t, []string{"user@localhost"}, XMPPAddresses(cert))
+	require.Equal(t, []string{"device@localhost"}, cert.EmailAddresses)
+
+	cert = tUtilLoadCertificate(t, "../testdata/cert/test.server.crt")
+	require.Equal(t, 0, len(XMPPAddresses(cert)))
+}
+
+func tUtilLoadCertificate(t *testing.T, certFile string) *x509.Certificate {
+	b, err := ioutil.ReadFile(certFile)
+	require.Nil(t, err)
+	block, _ := pem.Decode(b)
+	require.NotNil(t, block)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.Nil(t, err)
+	return cert
+}